@@ -69,10 +69,6 @@ func main() {
 		return
 	}
 
-	if err := cfg.ValidateAndAdjust(); err != nil {
-		panicf("invalid config, err:%v", err)
-	}
-
 	if err := cfgParser.ParseConfigFromToml(); err != nil {
 		panicf("fail to parse config from toml file, err:%v", err)
 	}
@@ -81,6 +77,10 @@ func main() {
 		panicf("fail to parse config from environment variable, err:%v", err)
 	}
 
+	if err := cfg.ValidateAndAdjust(); err != nil {
+		panicf("invalid config, err:%v", err)
+	}
+
 	cfgByte, err := toml.Marshal(cfg)
 	if err != nil {
 		panicf("fail to marshal server config, err:%v", err)
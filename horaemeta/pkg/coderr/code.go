@@ -28,7 +28,9 @@ const (
 	Ok                     = 0
 	InvalidParams          = http.StatusBadRequest
 	BadRequest             = http.StatusBadRequest
+	Forbidden              = http.StatusForbidden
 	NotFound               = http.StatusNotFound
+	Conflict               = http.StatusConflict
 	TooManyRequests        = http.StatusTooManyRequests
 	Internal               = http.StatusInternalServerError
 	ErrNotImplemented      = http.StatusNotImplemented
@@ -37,6 +39,9 @@ const (
 	HTTPCodeUpperBound   = Code(1000)
 	PrintHelpUsage       = 1001
 	ClusterAlreadyExists = 1002
+	TableQuotaExceeded   = 1003
+	// SchemaNotProvisioned means the schema doesn't exist and it is not allowed to be created implicitly.
+	SchemaNotProvisioned = 1004
 )
 
 // ToHTTPCode converts the Code to http code.
@@ -47,6 +52,14 @@ func (c Code) ToHTTPCode() int {
 		return int(c)
 	}
 
-	// TODO: use switch to convert the code to http code.
-	return int(c)
+	switch c {
+	case ClusterAlreadyExists:
+		return http.StatusConflict
+	case TableQuotaExceeded:
+		return http.StatusTooManyRequests
+	case SchemaNotProvisioned:
+		return http.StatusNotFound
+	default:
+		return int(c)
+	}
 }
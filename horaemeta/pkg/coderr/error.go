@@ -25,12 +25,14 @@ import (
 	"github.com/pkg/errors"
 )
 
-var _ CodeError = &codeError{code: 0, desc: "", cause: nil}
+var _ CodeError = &codeError{code: 0, desc: "", cause: nil, definition: nil}
 
 // CodeError is an error with code.
 type CodeError interface {
 	error
 	Code() Code
+	// Desc returns the description of the error without its cause.
+	Desc() string
 	// WithCausef should generate a new CodeError instance with the provided cause details.
 	WithCausef(format string, a ...any) CodeError
 	// WithCause should generate a new CodeError instance with the provided cause details.
@@ -39,6 +41,7 @@ type CodeError interface {
 
 // Is checks whether the cause of `err` is the kind of error specified by the `expectCode`.
 // Returns false if the cause of `err` is not CodeError.
+// Note that the errors defined with the same code are not distinguished, use `errors.Is` with the defined error to match the specific one.
 func Is(err error, expectCode Code) bool {
 	code, b := GetCauseCode(err)
 	if b && code == expectCode {
@@ -65,9 +68,10 @@ func GetCauseCode(err error) (Code, bool) {
 // The provided code should be defined in the code.go in this package.
 func NewCodeError(code Code, desc string) CodeError {
 	return &codeError{
-		code:  code,
-		desc:  desc,
-		cause: nil,
+		code:       code,
+		desc:       desc,
+		cause:      nil,
+		definition: nil,
 	}
 }
 
@@ -76,6 +80,25 @@ type codeError struct {
 	code  Code
 	desc  string
 	cause error
+	// definition is the error defined by NewCodeError which this error is generated from, nil if this error is the definition itself.
+	definition *codeError
+}
+
+func (e *codeError) getDefinition() *codeError {
+	if e.definition != nil {
+		return e.definition
+	}
+	return e
+}
+
+// Is reports whether the target is the same defined error as this one, so that `errors.Is` can match the errors generated by
+// WithCause or WithCausef against their definition.
+func (e *codeError) Is(target error) bool {
+	t, ok := target.(*codeError)
+	if !ok {
+		return false
+	}
+	return e.getDefinition() == t.getDefinition()
 }
 
 func (e *codeError) Error() string {
@@ -86,21 +109,27 @@ func (e *codeError) Code() Code {
 	return e.code
 }
 
+func (e *codeError) Desc() string {
+	return e.desc
+}
+
 func (e *codeError) WithCausef(format string, a ...any) CodeError {
 	errMsg := fmt.Sprintf(format, a...)
 	causeWithStack := errors.WithStack(errors.New(errMsg))
 	return &codeError{
-		code:  e.code,
-		desc:  e.desc,
-		cause: causeWithStack,
+		code:       e.code,
+		desc:       e.desc,
+		cause:      causeWithStack,
+		definition: e.getDefinition(),
 	}
 }
 
 func (e *codeError) WithCause(cause error) CodeError {
 	causeWithStack := errors.WithStack(cause)
 	return &codeError{
-		code:  e.code,
-		desc:  e.desc,
-		cause: causeWithStack,
+		code:       e.code,
+		desc:       e.desc,
+		cause:      causeWithStack,
+		definition: e.getDefinition(),
 	}
 }
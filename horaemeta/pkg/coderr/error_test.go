@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coderr
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorsIs(t *testing.T) {
+	re := require.New(t)
+
+	errFoo := NewCodeError(NotFound, "foo")
+	errBar := NewCodeError(NotFound, "bar")
+
+	err := errors.WithMessage(errFoo.WithCausef("cause:%d", 1), "wrapped")
+	re.True(errors.Is(err, errFoo))
+	re.True(errors.Is(errFoo.WithCause(err), errFoo))
+	// The errors defined with the same code are distinguished by errors.Is, but not by Is.
+	re.False(errors.Is(err, errBar))
+	re.True(Is(err, errBar.Code()))
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coderr
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRecentErrorsCapacity is the default number of the recent errors kept in memory.
+const DefaultRecentErrorsCapacity = 256
+
+// RecentError is an error recorded in the recent errors buffer.
+type RecentError struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	// Source describes where the error occurs, e.g. the http handler or the procedure.
+	Source string `json:"source"`
+	// Timestamp is the unix timestamp in milliseconds when the error is recorded.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// RecentErrors is a bounded ring buffer of the recent errors, the oldest error is overwritten once it is full.
+// It is safe for concurrent use.
+type RecentErrors struct {
+	lock   sync.Mutex
+	errors []RecentError
+	// next is the position to record the next error.
+	next int
+	full bool
+}
+
+func NewRecentErrors(capacity int) *RecentErrors {
+	if capacity <= 0 {
+		capacity = DefaultRecentErrorsCapacity
+	}
+	return &RecentErrors{
+		lock:   sync.Mutex{},
+		errors: make([]RecentError, capacity),
+		next:   0,
+		full:   false,
+	}
+}
+
+// Record records the error occurred in the source, and the code of the error is Internal if it isn't caused by a CodeError.
+func (r *RecentErrors) Record(source string, err error) {
+	if err == nil {
+		return
+	}
+	code, ok := GetCauseCode(err)
+	if !ok {
+		code = Internal
+	}
+	recentError := RecentError{
+		Code:      code,
+		Message:   err.Error(),
+		Source:    source,
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.errors[r.next] = recentError
+	r.next = (r.next + 1) % len(r.errors)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List returns the recorded errors, and the newest one comes first.
+func (r *RecentErrors) List() []RecentError {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.errors)
+	}
+	result := make([]RecentError, 0, size)
+	for i := 1; i <= size; i++ {
+		result = append(result, r.errors[(r.next-i+len(r.errors))%len(r.errors)])
+	}
+	return result
+}
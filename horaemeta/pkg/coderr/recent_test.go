@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coderr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentErrors(t *testing.T) {
+	re := require.New(t)
+
+	recentErrors := NewRecentErrors(3)
+	re.Empty(recentErrors.List())
+
+	testErr := NewCodeError(BadRequest, "test")
+	recentErrors.Record("source0", testErr.WithCausef("0"))
+	recentErrors.Record("source1", errors.New("1"))
+	recentErrors.Record("source2", nil)
+	errs := recentErrors.List()
+	re.Len(errs, 2)
+	re.Equal("source1", errs[0].Source)
+	re.Equal(Code(Internal), errs[0].Code)
+	re.Equal("source0", errs[1].Source)
+	re.Equal(Code(BadRequest), errs[1].Code)
+
+	// The oldest errors are overwritten once the buffer is full.
+	recentErrors.Record("source3", errors.New("3"))
+	recentErrors.Record("source4", errors.New("4"))
+	errs = recentErrors.List()
+	re.Len(errs, 3)
+	re.Equal("source4", errs[0].Source)
+	re.Equal("source3", errs[1].Source)
+	re.Equal("source1", errs[2].Source)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				recentErrors.Record("concurrent", errors.New("concurrent"))
+				_ = recentErrors.List()
+			}
+		}()
+	}
+	wg.Wait()
+	re.Len(recentErrors.List(), 3)
+}
@@ -22,7 +22,9 @@ package cluster
 import (
 	"context"
 	"strings"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
@@ -45,32 +47,48 @@ type Cluster struct {
 	logger   *zap.Logger
 	metadata *metadata.ClusterMetadata
 
+	dispatch         eventdispatch.Dispatch
+	dispatchAudit    *eventdispatch.Audit
 	procedureFactory *coordinator.Factory
 	procedureManager procedure.Manager
+	procedureStorage procedure.Storage
 	schedulerManager manager.SchedulerManager
 	nodeInspector    *inspector.NodeInspector
 }
 
-func NewCluster(logger *zap.Logger, metadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string) (*Cluster, error) {
-	procedureStorage := procedure.NewEtcdStorageImpl(client, rootPath, uint32(metadata.GetClusterID()))
-	procedureManager, err := procedure.NewManagerImpl(logger, metadata)
+// NewCluster creates the cluster whose procedures are kept under the clusterRootPath, while the shards are watched under the rootPath shared
+// with the HoraeDB nodes.
+func NewCluster(ctx context.Context, logger *zap.Logger, metadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath, clusterRootPath string, schedulerConcurrency int, procedureTimeouts procedure.Timeouts, procedureStorageOptions procedure.StorageOptions, recentErrors *coderr.RecentErrors) (*Cluster, error) {
+	procedureStorage, err := procedure.NewStorage(ctx, client, clusterRootPath, uint32(metadata.GetClusterID()), procedureStorageOptions)
+	if err != nil {
+		return nil, errors.WithMessage(err, "create procedure storage")
+	}
+	procedureManager, err := procedure.NewManagerImpl(logger, metadata, procedureTimeouts, recentErrors)
 	if err != nil {
 		return nil, errors.WithMessage(err, "create procedure manager")
 	}
-	dispatch := eventdispatch.NewDispatchImpl()
+	dispatchAudit := eventdispatch.NewAudit(eventdispatch.DefaultAuditMaxProcedures, eventdispatch.DefaultAuditMaxRecordsPerProcedure)
+	dispatch := eventdispatch.NewAuditedDispatch(eventdispatch.NewDispatchImpl(), dispatchAudit)
 
-	procedureIDRootPath := strings.Join([]string{rootPath, metadata.Name(), defaultProcedurePrefixKey}, "/")
-	procedureFactory := coordinator.NewFactory(logger, id.NewAllocatorImpl(logger, client, procedureIDRootPath, defaultAllocStep), dispatch, procedureStorage, metadata)
+	procedureIDRootPath := strings.Join([]string{clusterRootPath, metadata.Name(), defaultProcedurePrefixKey}, "/")
+	shardPicker, err := coordinator.NewShardPicker(metadata.GetShardPicker())
+	if err != nil {
+		return nil, errors.WithMessage(err, "create shard picker")
+	}
+	procedureFactory := coordinator.NewFactory(logger, id.NewAllocatorImpl(logger, client, procedureIDRootPath, defaultAllocStep), dispatch, procedureStorage, metadata, shardPicker)
 
-	schedulerManager := manager.NewManager(logger, procedureManager, procedureFactory, metadata, client, rootPath, metadata.GetTopologyType(), metadata.GetProcedureExecutingBatchSize())
+	schedulerManager := manager.NewManager(logger, procedureManager, procedureFactory, metadata, client, rootPath, metadata.GetTopologyType(), metadata.GetProcedureExecutingBatchSize(), schedulerConcurrency)
 
 	nodeInspector := inspector.NewNodeInspector(logger, metadata)
 
 	return &Cluster{
 		logger:           logger,
 		metadata:         metadata,
+		dispatch:         dispatch,
+		dispatchAudit:    dispatchAudit,
 		procedureFactory: procedureFactory,
 		procedureManager: procedureManager,
+		procedureStorage: procedureStorage,
 		schedulerManager: schedulerManager,
 		nodeInspector:    nodeInspector,
 	}, nil
@@ -80,6 +98,12 @@ func (c *Cluster) Start(ctx context.Context) error {
 	if err := c.procedureManager.Start(ctx); err != nil {
 		return errors.WithMessage(err, "start procedure manager")
 	}
+	if c.metadata.IsProcedureCheckpointEnabled() {
+		// The failure of resuming procedures shouldn't block the cluster from serving, and the procedures left are handled next time.
+		if err := c.resumeProcedures(ctx); err != nil {
+			c.logger.Error("resume procedures", zap.Error(err))
+		}
+	}
 	if err := c.schedulerManager.Start(ctx); err != nil {
 		return errors.WithMessage(err, "start scheduler manager")
 	}
@@ -89,6 +113,51 @@ func (c *Cluster) Start(ctx context.Context) error {
 	return nil
 }
 
+// resumeProcedures handles the unfinished procedures checkpointed by the last leader according to the resume policies of their kinds.
+func (c *Cluster) resumeProcedures(ctx context.Context) error {
+	metas, err := procedure.ListUnfinishedMetas(ctx, c.procedureStorage)
+	if err != nil {
+		return errors.WithMessage(err, "list unfinished procedures")
+	}
+
+	for _, meta := range metas {
+		p, needed, err := c.procedureFactory.ResumeProcedure(ctx, c.metadata, meta)
+		if err != nil {
+			c.logger.Warn("abandon unfinished procedure", zap.Uint64("procedureID", meta.ID), zap.Int("kind", int(meta.Kind)), zap.Error(err))
+			if err := c.updateProcedureState(ctx, *meta, procedure.StateFailed); err != nil {
+				return err
+			}
+			continue
+		}
+		if !needed {
+			c.logger.Info("unfinished procedure has nothing left to do", zap.Uint64("procedureID", meta.ID), zap.Int("kind", int(meta.Kind)))
+			if err := c.updateProcedureState(ctx, *meta, procedure.StateFinished); err != nil {
+				return err
+			}
+			continue
+		}
+
+		c.logger.Info("resume unfinished procedure", zap.Uint64("procedureID", meta.ID), zap.Int("kind", int(meta.Kind)))
+		if err := c.procedureManager.Submit(procedure.WithInitiator(ctx, meta.Initiator), p); err != nil {
+			c.logger.Warn("submit resumed procedure", zap.Uint64("procedureID", meta.ID), zap.Error(err))
+			if err := c.updateProcedureState(ctx, *meta, procedure.StateFailed); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Cluster) updateProcedureState(ctx context.Context, meta procedure.Meta, state procedure.State) error {
+	meta.State = state
+	meta.UpdatedAt = uint64(time.Now().UnixMilli())
+	if err := c.procedureStorage.CreateOrUpdate(ctx, meta); err != nil {
+		return errors.WithMessagef(err, "update procedure state, procedureID:%d", meta.ID)
+	}
+	return nil
+}
+
 func (c *Cluster) Stop(ctx context.Context) error {
 	if err := c.procedureManager.Stop(ctx); err != nil {
 		return errors.WithMessage(err, "stop procedure manager")
@@ -114,6 +183,168 @@ func (c *Cluster) GetProcedureFactory() *coordinator.Factory {
 	return c.procedureFactory
 }
 
+// GetProcedureDispatches returns the events dispatched to the data nodes by the procedure, and nothing is returned if the procedure dispatches
+// nothing or it is evicted from the audit.
+func (c *Cluster) GetProcedureDispatches(procedureID uint64) eventdispatch.ProcedureAudit {
+	audit, ok := c.dispatchAudit.Get(procedureID)
+	if !ok {
+		return eventdispatch.ProcedureAudit{ProcedureID: procedureID, Records: []eventdispatch.AuditRecord{}, Dropped: 0}
+	}
+	return audit
+}
+
+// PurgeFinishedProcedures counts and purges the persisted finished procedures older than the retention, nothing will be deleted if dryRun is set.
+func (c *Cluster) PurgeFinishedProcedures(ctx context.Context, retention time.Duration, dryRun bool) (procedure.PurgeResult, error) {
+	return procedure.PurgeFinishedProcedures(ctx, c.procedureStorage, c.procedureManager, retention, dryRun)
+}
+
+// ProcedureStats counts the running procedures and the procedures finished in the window by their states and kinds.
+func (c *Cluster) ProcedureStats(ctx context.Context, window time.Duration) (procedure.Stats, error) {
+	return procedure.CollectStats(ctx, c.procedureStorage, c.procedureManager, window)
+}
+
+// ProcedureKindCounts counts the running procedures by the names of their kinds.
+func (c *Cluster) ProcedureKindCounts(ctx context.Context) (map[string]int, error) {
+	return procedure.CountRunningKinds(ctx, c.procedureManager)
+}
+
+// ExportProcedure exports the replayable definition of the persisted procedure, which may be running or finished recently.
+func (c *Cluster) ExportProcedure(ctx context.Context, procedureID uint64) (coordinator.ProcedureDefinition, error) {
+	meta, err := procedure.FindMeta(ctx, c.procedureStorage, procedureID)
+	if err != nil {
+		var emptyDef coordinator.ProcedureDefinition
+		return emptyDef, err
+	}
+	return coordinator.ExportProcedureDefinition(meta)
+}
+
+// ReplayProcedure reconstructs the procedure from the definition and submits it, the id of the new procedure is returned.
+func (c *Cluster) ReplayProcedure(ctx context.Context, def coordinator.ProcedureDefinition) (uint64, error) {
+	p, err := c.procedureFactory.CreateProcedureFromDefinition(ctx, c.metadata, def)
+	if err != nil {
+		return 0, errors.WithMessage(err, "create procedure from definition")
+	}
+	if err := c.procedureManager.Submit(ctx, p); err != nil {
+		return 0, errors.WithMessage(err, "submit procedure")
+	}
+	return p.ID(), nil
+}
+
+// CloseTableOnShard closes the table on the leader of the shard directly without any procedure, and then removes the table from the shard view.
+// It is an expert operation to clear the table wedged open on a node, and the table is left unassigned after closing.
+// The shard is locked during the operation, so it fails instead of racing with the procedures running on the shard.
+func (c *Cluster) CloseTableOnShard(ctx context.Context, schemaName, tableName string, shardID storage.ShardID) error {
+	unlock, err := c.procedureManager.TryLockShards([]storage.ShardID{shardID})
+	if err != nil {
+		return errors.WithMessage(err, "lock shard")
+	}
+	defer unlock()
+
+	table, exists, err := c.metadata.GetTable(schemaName, tableName)
+	if err != nil {
+		return errors.WithMessage(err, "get table")
+	}
+	if !exists {
+		return metadata.ErrTableNotFound.WithCausef("schemaName:%s, tableName:%s", schemaName, tableName)
+	}
+
+	tableShardID, exists := c.metadata.GetTableShard(ctx, table)
+	if !exists || tableShardID != shardID {
+		return metadata.ErrTableNotOnShard.WithCausef("tableName:%s, shardID:%d", tableName, shardID)
+	}
+
+	shardView, exists := c.metadata.GetClusterSnapshot().Topology.ShardViewsMapping[shardID]
+	if !exists {
+		return metadata.ErrShardNotFound.WithCausef("shardID:%d", shardID)
+	}
+
+	leader, exists := c.metadata.GetShardLeader(shardID)
+	if !exists {
+		return errors.WithMessagef(procedure.ErrShardLeaderNotFound, "shardID:%d", shardID)
+	}
+
+	c.logger.Info("close table on shard", zap.String("tableName", tableName), zap.Uint32("shardID", uint32(shardID)), zap.String("node", leader.NodeName), zap.Uint64("shardVersion", shardView.Version))
+	if err := c.dispatch.CloseTableOnShard(ctx, leader.NodeName, eventdispatch.CloseTableOnShardRequest{
+		UpdateShardInfo: eventdispatch.UpdateShardInfo{
+			CurrShardInfo: metadata.ShardInfo{
+				ID:      shardID,
+				Role:    storage.ShardRoleLeader,
+				Version: shardView.Version,
+				// FIXME: We have no need to update the status, but it must be set. Maybe we should provide another struct without status field.
+				Status: storage.ShardStatusUnknown,
+			},
+		},
+		TableInfo: metadata.TableInfo{
+			ID:            table.ID,
+			Name:          table.Name,
+			SchemaID:      table.SchemaID,
+			SchemaName:    schemaName,
+			PartitionInfo: table.PartitionInfo,
+			CreatedAt:     table.CreatedAt,
+		},
+	}); err != nil {
+		return errors.WithMessage(err, "dispatch close table on shard")
+	}
+
+	// The node takes the shard info in the request as its current one after the table is closed, so the version is unchanged.
+	if err := c.metadata.RemoveTableTopology(ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: shardView.Version,
+	}, table); err != nil {
+		return errors.WithMessage(err, "remove table topology")
+	}
+
+	return nil
+}
+
+// CloseGhostShard closes the shard on the node which reports it but isn't assigned with it in the cluster view.
+// The shard is checked against the latest snapshot, so the shard assigned to the node in the meantime is never closed.
+func (c *Cluster) CloseGhostShard(ctx context.Context, nodeName string, shardID storage.ShardID) error {
+	isGhost := false
+	for _, ghostShard := range c.metadata.GetClusterSnapshot().FindGhostShards(time.Now()) {
+		if ghostShard.NodeName == nodeName && ghostShard.ShardID == shardID {
+			isGhost = true
+			break
+		}
+	}
+	if !isGhost {
+		return metadata.ErrShardNotGhost.WithCausef("nodeName:%s, shardID:%d", nodeName, shardID)
+	}
+
+	c.logger.Info("close ghost shard", zap.String("node", nodeName), zap.Uint32("shardID", uint32(shardID)))
+	if err := c.dispatch.CloseShard(ctx, nodeName, eventdispatch.CloseShardRequest{ShardID: uint32(shardID)}); err != nil {
+		return errors.WithMessage(err, "dispatch close shard")
+	}
+
+	return nil
+}
+
+// PreWarmShard hints the leader of the online shard to pre-allocate the resources for the tables about to be created on it, e.g. before a bulk load.
+// The error is returned only if the shard isn't online, and the hint is best-effort, whose failure is only logged and reported by the returned dispatched.
+func (c *Cluster) PreWarmShard(ctx context.Context, shardID storage.ShardID, expectedTableCount uint32) (string, bool, error) {
+	snapshot := c.metadata.GetClusterSnapshot()
+	leader, err := snapshot.GetOnlineShardLeader(shardID, time.Now())
+	if err != nil {
+		return "", false, err
+	}
+
+	c.logger.Info("pre-warm shard", zap.Uint32("shardID", uint32(shardID)), zap.String("node", leader.NodeName), zap.Uint32("expectedTableCount", expectedTableCount))
+	if err := c.dispatch.PreWarmShard(ctx, leader.NodeName, eventdispatch.PreWarmShardRequest{
+		Shard: metadata.ShardInfo{
+			ID:      shardID,
+			Role:    storage.ShardRoleLeader,
+			Version: snapshot.Topology.ShardViewsMapping[shardID].Version,
+			Status:  storage.ShardStatusReady,
+		},
+		ExpectedTableCount: expectedTableCount,
+	}); err != nil {
+		c.logger.Warn("dispatch pre-warm shard failed", zap.Uint32("shardID", uint32(shardID)), zap.String("node", leader.NodeName), zap.Error(err))
+		return leader.NodeName, false, nil
+	}
+
+	return leader.NodeName, true, nil
+}
+
 func (c *Cluster) GetSchedulerManager() manager.SchedulerManager {
 	return c.schedulerManager
 }
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cluster_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseTableOnShard(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	c := test.InitStableCluster(ctx, t)
+	shardView := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping
+	shardID := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[0].ID
+	otherShardID := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[1].ID
+	result, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       shardID,
+		LatestVersion: shardView[shardID].Version + 1,
+		SchemaName:    test.TestSchemaName,
+		TableName:     "closeTable",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	err = c.CloseTableOnShard(ctx, test.TestSchemaName, "unknownTable", shardID)
+	re.ErrorIs(err, metadata.ErrTableNotFound)
+	err = c.CloseTableOnShard(ctx, test.TestSchemaName, "closeTable", otherShardID)
+	re.ErrorIs(err, metadata.ErrTableNotOnShard)
+
+	// The table can't be closed while a procedure is running on the shard.
+	unlock, err := c.GetProcedureManager().TryLockShards([]storage.ShardID{shardID})
+	re.NoError(err)
+	err = c.CloseTableOnShard(ctx, test.TestSchemaName, "closeTable", shardID)
+	re.ErrorIs(err, procedure.ErrShardLocked)
+	unlock()
+
+	// The table is kept on the shard if it fails to be closed on the node, and the shard lock is released.
+	dispatchCtx, dispatchCancel := context.WithTimeout(ctx, time.Second)
+	defer dispatchCancel()
+	err = c.CloseTableOnShard(dispatchCtx, test.TestSchemaName, "closeTable", shardID)
+	re.Error(err)
+	tableShardID, exists := c.GetMetadata().GetTableShard(ctx, result.Table)
+	re.True(exists)
+	re.Equal(shardID, tableShardID)
+	unlock, err = c.GetProcedureManager().TryLockShards([]storage.ShardID{shardID})
+	re.NoError(err)
+	unlock()
+}
@@ -26,8 +26,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/id"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/pkg/errors"
@@ -47,6 +49,8 @@ type Manager interface {
 
 	ListClusters(ctx context.Context) ([]*Cluster, error)
 	CreateCluster(ctx context.Context, clusterName string, opts metadata.CreateClusterOpts) (*Cluster, error)
+	// CloneCluster creates a new empty cluster whose shard total, node count and configs are same as the source cluster.
+	CloneCluster(ctx context.Context, sourceClusterName, clusterName string) (*Cluster, error)
 	UpdateCluster(ctx context.Context, clusterName string, opt metadata.UpdateClusterOpts) error
 	GetCluster(ctx context.Context, clusterName string) (*Cluster, error)
 	// AllocSchemaID means get or create schema.
@@ -62,6 +66,57 @@ type Manager interface {
 	RegisterNode(ctx context.Context, clusterName string, registeredNode metadata.RegisteredNode) error
 	GetRegisteredNode(ctx context.Context, clusterName string, node string) (metadata.RegisteredNode, error)
 	ListRegisteredNodes(ctx context.Context, clusterName string) ([]metadata.RegisteredNode, error)
+
+	// GetScanLimit returns the limits of the number of keys in a metadata scan.
+	GetScanLimit() storage.ScanLimit
+	// UpdateMaxScanLimit updates the max limit of the number of keys in the subsequent metadata scans, which is persisted and
+	// restored by the manager started on the new leader.
+	UpdateMaxScanLimit(ctx context.Context, maxScanLimit int) error
+}
+
+// ManagerOptions contains the options of the cluster manager, and most of them are applied to every cluster opened by the manager.
+type ManagerOptions struct {
+	RootPath        string
+	IDAllocatorStep uint
+	// ClusterKeyPrefixes isolates the keys of the clusters by their names, and the keys of the clusters not included are placed under the root path.
+	ClusterKeyPrefixes map[string]string
+	// TODO: TopologyType is used to be compatible with cluster data changes and needs to be deleted later.
+	TopologyType storage.TopologyType
+	// EnableSchemaAutoCreation determines whether the unknown schema is created implicitly when its id is allocated.
+	EnableSchemaAutoCreation bool
+
+	// SchedulerConcurrency is the max number of schedulers running concurrently in every cluster.
+	SchedulerConcurrency int
+	// MaxShardVersionDelta is the max delta of the shard version in a single table operation of every cluster.
+	MaxShardVersionDelta uint64
+	// ReadyShardStatuses is the shard statuses considered as ready of every cluster.
+	ReadyShardStatuses []storage.ShardStatus
+	// NodePickerHash is the hash function used by the node picker of every cluster.
+	NodePickerHash metadata.NodePickerHash
+	// EnableProcedureCheckpoint determines whether the procedures of every cluster are checkpointed and resumed after restarting.
+	EnableProcedureCheckpoint bool
+	// CreateTableOfflineShardPolicy determines how the create table procedures of every cluster behave if the leader node of the picked shard is offline.
+	CreateTableOfflineShardPolicy metadata.OfflineShardPolicy
+	// ShardPickers is the name of the shard picker used when creating tables of the clusters by their names, and the clusters not included use the default picker.
+	ShardPickers map[string]string
+	// ProcedureTimeouts bounds the execution of the procedures of every cluster by their kinds.
+	ProcedureTimeouts procedure.Timeouts
+	// ShardOscillationThreshold determines how frequently the leader of a shard of every cluster is allowed to move before its further moves are suppressed.
+	ShardOscillationThreshold metadata.ShardOscillationThreshold
+	// PreferredLeaderStabilizationDelay determines how long the node of a preferred leader of every cluster must stay online before the leadership is moved back to it.
+	PreferredLeaderStabilizationDelay time.Duration
+	// NodeStatsHistoryOptions bounds the utilization history of the nodes of every cluster.
+	NodeStatsHistoryOptions metadata.NodeStatsHistoryOptions
+	// MaxInflightCreatesPerShard bounds the table creations in flight on every shard of every cluster, zero means unlimited.
+	MaxInflightCreatesPerShard uint32
+	// MaxPartitionSubTables bounds the sub tables of the partition tables created in every cluster, zero means unlimited.
+	MaxPartitionSubTables uint32
+	// SubTableDispatchConcurrency bounds the shards creating the sub tables of a partition table concurrently in every cluster, zero means unlimited.
+	SubTableDispatchConcurrency uint32
+	// ProcedureStorageOptions determines where the procedures of every cluster are persisted in etcd.
+	ProcedureStorageOptions procedure.StorageOptions
+	// RecentErrors records the failures of the procedures of every cluster.
+	RecentErrors *coderr.RecentErrors
 }
 
 type managerImpl struct {
@@ -70,37 +125,54 @@ type managerImpl struct {
 	running  bool
 	clusters map[string]*Cluster
 
-	storage         storage.Storage
-	kv              clientv3.KV
-	client          *clientv3.Client
-	alloc           id.Allocator
-	rootPath        string
-	idAllocatorStep uint
-
-	// TODO: topologyType is used to be compatible with cluster data changes and needs to be deleted later.
-	topologyType storage.TopologyType
+	storage storage.Storage
+	kv      clientv3.KV
+	client  *clientv3.Client
+	alloc   id.Allocator
+	opts    ManagerOptions
 }
 
-func NewManagerImpl(storage storage.Storage, kv clientv3.KV, client *clientv3.Client, rootPath string, idAllocatorStep uint, topologyType storage.TopologyType) (Manager, error) {
-	alloc := id.NewAllocatorImpl(log.GetLogger(), kv, path.Join(rootPath, AllocClusterIDPrefix), idAllocatorStep)
+func NewManagerImpl(storage storage.Storage, kv clientv3.KV, client *clientv3.Client, opts ManagerOptions) (Manager, error) {
+	alloc := id.NewAllocatorImpl(log.GetLogger(), kv, path.Join(opts.RootPath, AllocClusterIDPrefix), opts.IDAllocatorStep)
 
 	manager := &managerImpl{
 		lock:     sync.RWMutex{},
 		running:  false,
 		clusters: map[string]*Cluster{},
 
-		kv:              kv,
-		storage:         storage,
-		client:          client,
-		alloc:           alloc,
-		rootPath:        rootPath,
-		idAllocatorStep: idAllocatorStep,
-		topologyType:    topologyType,
+		kv:      kv,
+		storage: storage,
+		client:  client,
+		alloc:   alloc,
+		opts:    opts,
 	}
 
 	return manager, nil
 }
 
+// newClusterMetadata builds the metadata of the cluster with the options of the manager, and it must be loaded before the cluster is opened.
+func (m *managerImpl) newClusterMetadata(logger *zap.Logger, clusterMetadataStorage storage.Cluster, clusterRootPath string) *metadata.ClusterMetadata {
+	clusterMetadata := metadata.NewClusterMetadata(logger, clusterMetadataStorage, m.storage, m.kv, clusterRootPath, m.opts.IDAllocatorStep)
+	clusterMetadata.UpdateMaxShardVersionDelta(m.opts.MaxShardVersionDelta)
+	clusterMetadata.UpdateReadyShardStatuses(m.opts.ReadyShardStatuses)
+	clusterMetadata.UpdateNodePickerHash(m.opts.NodePickerHash)
+	clusterMetadata.UpdateEnableProcedureCheckpoint(m.opts.EnableProcedureCheckpoint)
+	clusterMetadata.UpdateCreateTableOfflineShardPolicy(m.opts.CreateTableOfflineShardPolicy)
+	clusterMetadata.UpdateShardPicker(m.opts.ShardPickers[clusterMetadataStorage.Name])
+	clusterMetadata.UpdateShardOscillationThreshold(m.opts.ShardOscillationThreshold)
+	clusterMetadata.UpdatePreferredLeaderStabilizationDelay(m.opts.PreferredLeaderStabilizationDelay)
+	clusterMetadata.UpdateNodeStatsHistoryOptions(m.opts.NodeStatsHistoryOptions)
+	clusterMetadata.UpdateMaxInflightCreatesPerShard(m.opts.MaxInflightCreatesPerShard)
+	clusterMetadata.UpdateMaxPartitionSubTables(m.opts.MaxPartitionSubTables)
+	clusterMetadata.UpdateSubTableDispatchConcurrency(m.opts.SubTableDispatchConcurrency)
+	return clusterMetadata
+}
+
+// openCluster creates the cluster of the loaded metadata with the options of the manager.
+func (m *managerImpl) openCluster(ctx context.Context, logger *zap.Logger, clusterMetadata *metadata.ClusterMetadata, clusterRootPath string) (*Cluster, error) {
+	return NewCluster(ctx, logger, clusterMetadata, m.client, m.opts.RootPath, clusterRootPath, m.opts.SchedulerConcurrency, m.opts.ProcedureTimeouts, m.opts.ProcedureStorageOptions, m.opts.RecentErrors)
+}
+
 func (m *managerImpl) ListClusters(_ context.Context) ([]*Cluster, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -117,6 +189,10 @@ func (m *managerImpl) CreateCluster(ctx context.Context, clusterName string, opt
 		log.Error("cluster's nodeCount must > 0", zap.String("clusterName", clusterName))
 		return nil, metadata.ErrCreateCluster.WithCausef("nodeCount must > 0")
 	}
+	if err := metadata.ValidateShardIDs(opts.ShardIDs, opts.ShardTotal); err != nil {
+		log.Error("invalid shard ids", zap.String("clusterName", clusterName), zap.Error(err))
+		return nil, err
+	}
 
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -151,11 +227,18 @@ func (m *managerImpl) CreateCluster(ctx context.Context, clusterName string, opt
 		return nil, errors.WithMessage(err, "cluster create cluster")
 	}
 
+	// The key prefix is registered only after the cluster is created, so a failed creation leaves no prefix behind.
+	clusterRootPath, err := m.isolateClusterKeys(clusterID, clusterName)
+	if err != nil {
+		log.Error("fail to isolate cluster keys", zap.Error(err), zap.String("clusterName", clusterName))
+		return nil, err
+	}
+
 	logger := log.With(zap.String("clusterName", clusterName))
 
-	clusterMetadata := metadata.NewClusterMetadata(logger, clusterMetadataStorage, m.storage, m.kv, m.rootPath, m.idAllocatorStep)
+	clusterMetadata := m.newClusterMetadata(logger, clusterMetadataStorage, clusterRootPath)
 
-	if err = clusterMetadata.Init(ctx); err != nil {
+	if err = clusterMetadata.InitWithShardIDs(ctx, opts.ShardIDs); err != nil {
 		log.Error("fail to init cluster", zap.Error(err), zap.String("clusterName", clusterName))
 		return nil, errors.WithMessage(err, "cluster init")
 	}
@@ -165,7 +248,7 @@ func (m *managerImpl) CreateCluster(ctx context.Context, clusterName string, opt
 		return nil, errors.WithMessage(err, "cluster load")
 	}
 
-	c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath)
+	c, err := m.openCluster(ctx, logger, clusterMetadata, clusterRootPath)
 	if err != nil {
 		return nil, errors.WithMessage(err, "new cluster")
 	}
@@ -178,6 +261,37 @@ func (m *managerImpl) CreateCluster(ctx context.Context, clusterName string, opt
 	return c, nil
 }
 
+func (m *managerImpl) CloneCluster(ctx context.Context, sourceClusterName, clusterName string) (*Cluster, error) {
+	source, err := m.GetCluster(ctx, sourceClusterName)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "get source cluster, clusterName:%s", sourceClusterName)
+	}
+	sourceMetadata := source.GetMetadata()
+
+	// The enableSchedule is only available in dynamic topology.
+	enableSchedule, err := source.GetSchedulerManager().GetEnableSchedule(ctx)
+	if err != nil {
+		enableSchedule = false
+	}
+
+	c, err := m.CreateCluster(ctx, clusterName, metadata.CreateClusterOpts{
+		NodeCount:                   sourceMetadata.GetClusterMinNodeCount(),
+		ShardTotal:                  sourceMetadata.GetTotalShardNum(),
+		EnableSchedule:              enableSchedule,
+		TopologyType:                sourceMetadata.GetTopologyType(),
+		ProcedureExecutingBatchSize: sourceMetadata.GetProcedureExecutingBatchSize(),
+		ShardIDs:                    nil,
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "clone cluster, sourceClusterName:%s, clusterName:%s", sourceClusterName, clusterName)
+	}
+	if err := c.GetMetadata().UpdateMaxTables(ctx, sourceMetadata.GetMaxTables()); err != nil {
+		return nil, errors.WithMessagef(err, "clone cluster quota, sourceClusterName:%s, clusterName:%s", sourceClusterName, clusterName)
+	}
+
+	return c, nil
+}
+
 func (m *managerImpl) UpdateCluster(ctx context.Context, clusterName string, opt metadata.UpdateClusterOpts) error {
 	c, err := m.getCluster(clusterName)
 	if err != nil {
@@ -185,6 +299,20 @@ func (m *managerImpl) UpdateCluster(ctx context.Context, clusterName string, opt
 		return err
 	}
 
+	oldTopologyType := c.GetMetadata().GetTopologyType()
+	topologyTypeChanged := oldTopologyType != opt.TopologyType
+	if topologyTypeChanged {
+		if err := checkTopologyTypeChangeSafe(ctx, c); err != nil {
+			log.Error("reject topology type change", zap.Error(err), zap.String("clusterName", clusterName))
+			return err
+		}
+		// Switch the scheduler manager before persisting, so a failed switch never leaves a persisted topology type that the schedulers don't follow.
+		if err := c.GetSchedulerManager().UpdateTopologyType(ctx, opt.TopologyType); err != nil {
+			log.Error("fail to switch topology type of scheduler manager", zap.Error(err), zap.String("clusterName", clusterName))
+			return err
+		}
+	}
+
 	err = m.storage.UpdateCluster(ctx, storage.UpdateClusterRequest{Cluster: storage.Cluster{
 		ID:                          c.GetMetadata().GetClusterID(),
 		Name:                        c.GetMetadata().Name(),
@@ -197,6 +325,11 @@ func (m *managerImpl) UpdateCluster(ctx context.Context, clusterName string, opt
 	}})
 	if err != nil {
 		log.Error("update cluster", zap.Error(err))
+		if topologyTypeChanged {
+			if rollbackErr := c.GetSchedulerManager().UpdateTopologyType(ctx, oldTopologyType); rollbackErr != nil {
+				log.Error("fail to roll back topology type of scheduler manager", zap.Error(rollbackErr), zap.String("clusterName", clusterName))
+			}
+		}
 		return err
 	}
 
@@ -208,6 +341,27 @@ func (m *managerImpl) UpdateCluster(ctx context.Context, clusterName string, opt
 	return nil
 }
 
+// checkTopologyTypeChangeSafe makes sure the cluster is empty or quiescent, switching the topology type while shards are moving may strand them.
+func checkTopologyTypeChangeSafe(ctx context.Context, c *Cluster) error {
+	state := c.GetMetadata().GetClusterState()
+	if state == storage.ClusterStateEmpty {
+		return nil
+	}
+	if state != storage.ClusterStateStable {
+		return metadata.ErrUnsafeTopologyTypeChange.WithCausef("cluster state is not stable, state:%d", state)
+	}
+
+	runningProcedures, err := c.GetProcedureManager().ListRunningProcedure(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "list running procedures")
+	}
+	if len(runningProcedures) > 0 {
+		return metadata.ErrUnsafeTopologyTypeChange.WithCausef("there are %d running procedures", len(runningProcedures))
+	}
+
+	return nil
+}
+
 func (m *managerImpl) GetCluster(_ context.Context, clusterName string) (*Cluster, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -225,6 +379,14 @@ func (m *managerImpl) AllocSchemaID(ctx context.Context, clusterName, schemaName
 		return 0, false, errors.WithMessage(err, "get cluster")
 	}
 
+	if !m.opts.EnableSchemaAutoCreation {
+		schema, exists := cluster.metadata.GetSchema(schemaName)
+		if !exists {
+			return 0, false, metadata.ErrSchemaNotProvisioned.WithCausef("cluster:%s, schema:%s", clusterName, schemaName)
+		}
+		return schema.ID, true, nil
+	}
+
 	// create new schema
 	schema, exists, err := cluster.metadata.GetOrCreateSchema(ctx, schemaName)
 	if err != nil {
@@ -363,12 +525,29 @@ func (m *managerImpl) RegisterNode(ctx context.Context, clusterName string, regi
 		return errors.WithMessage(err, "get cluster")
 	}
 
+	// The shards are pinned to the nodes in the static topology, so they can't be drained and the node is kept available.
+	drainRejected := registeredNode.IsShuttingDown() && cluster.metadata.GetTopologyType() == storage.TopologyTypeStatic
+	if drainRejected {
+		registeredNode.Node.NodeStats.ShuttingDown = false
+	}
+
+	oldNode, exists := cluster.metadata.GetRegisteredNodeByName(registeredNode.Node.Name)
 	err = cluster.metadata.RegisterNode(ctx, registeredNode)
 
 	if err != nil {
 		return errors.WithMessage(err, "cluster register node")
 	}
 
+	if drainRejected {
+		return metadata.ErrDrainNotSupported.WithCausef("the shards are pinned to the nodes in the static topology, clusterName:%s, node:%s", clusterName, registeredNode.Node.Name)
+	}
+
+	// Transfer the shards away as soon as possible when the node claims it is going to shut down.
+	if registeredNode.IsShuttingDown() && !(exists && oldNode.IsShuttingDown()) {
+		log.Info("node is shutting down, trigger schedule", zap.String("clusterName", clusterName), zap.String("node", registeredNode.Node.Name))
+		cluster.schedulerManager.TriggerSchedule()
+	}
+
 	return nil
 }
 
@@ -398,6 +577,19 @@ func (m *managerImpl) ListRegisteredNodes(_ context.Context, clusterName string)
 	return nodes, nil
 }
 
+func (m *managerImpl) GetScanLimit() storage.ScanLimit {
+	return m.storage.GetScanLimit()
+}
+
+func (m *managerImpl) UpdateMaxScanLimit(ctx context.Context, maxScanLimit int) error {
+	if err := m.storage.UpdateMaxScanLimit(ctx, maxScanLimit); err != nil {
+		return errors.WithMessage(err, "update max scan limit")
+	}
+
+	log.Info("max scan limit updated", zap.Int("max", maxScanLimit))
+	return nil
+}
+
 func (m *managerImpl) getCluster(clusterName string) (*Cluster, error) {
 	m.lock.RLock()
 	cluster, ok := m.clusters[clusterName]
@@ -425,6 +617,11 @@ func (m *managerImpl) Start(ctx context.Context) error {
 		return nil
 	}
 
+	if err := m.storage.LoadScanLimit(ctx); err != nil {
+		log.Error("cluster manager fail to start, fail to load scan limit", zap.Error(err))
+		return errors.WithMessage(err, "cluster manager start")
+	}
+
 	clusters, err := m.storage.ListClusters(ctx)
 	if err != nil {
 		log.Error("cluster manager fail to start, fail to list clusters", zap.Error(err))
@@ -434,7 +631,12 @@ func (m *managerImpl) Start(ctx context.Context) error {
 	m.clusters = make(map[string]*Cluster, len(clusters.Clusters))
 	for _, metadataStorage := range clusters.Clusters {
 		logger := log.With(zap.String("clusterName", metadataStorage.Name))
-		clusterMetadata := metadata.NewClusterMetadata(logger, metadataStorage, m.storage, m.kv, m.rootPath, m.idAllocatorStep)
+		clusterRootPath, err := m.isolateClusterKeys(metadataStorage.ID, metadataStorage.Name)
+		if err != nil {
+			log.Error("fail to isolate cluster keys", zap.String("cluster", metadataStorage.Name), zap.Error(err))
+			return err
+		}
+		clusterMetadata := m.newClusterMetadata(logger, metadataStorage, clusterRootPath)
 		if err = clusterMetadata.Load(ctx); err != nil {
 			log.Error("fail to load cluster", zap.String("cluster", clusterMetadata.Name()), zap.Error(err))
 			return errors.WithMessage(err, "fail to load cluster")
@@ -448,7 +650,7 @@ func (m *managerImpl) Start(ctx context.Context) error {
 					Name:                        metadataStorage.Name,
 					MinNodeCount:                metadataStorage.MinNodeCount,
 					ShardTotal:                  metadataStorage.ShardTotal,
-					TopologyType:                m.topologyType,
+					TopologyType:                m.opts.TopologyType,
 					ProcedureExecutingBatchSize: metadataStorage.ProcedureExecutingBatchSize,
 					CreatedAt:                   metadataStorage.CreatedAt,
 					ModifiedAt:                  uint64(time.Now().UnixMilli()),
@@ -465,7 +667,7 @@ func (m *managerImpl) Start(ctx context.Context) error {
 		}
 
 		log.Info("open cluster successfully", zap.String("cluster", clusterMetadata.Name()))
-		c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath)
+		c, err := m.openCluster(ctx, logger, clusterMetadata, clusterRootPath)
 		if err != nil {
 			return errors.WithMessage(err, "new cluster")
 		}
@@ -526,3 +728,12 @@ func (m *managerImpl) GetNodeShards(ctx context.Context, clusterName string) (me
 
 	return ret, nil
 }
+
+// isolateClusterKeys registers the key prefix of the cluster to the storage, and returns the root path of the keys scoped to the cluster.
+func (m *managerImpl) isolateClusterKeys(clusterID storage.ClusterID, clusterName string) (string, error) {
+	keyPrefix := m.opts.ClusterKeyPrefixes[clusterName]
+	if err := m.storage.SetClusterKeyPrefix(clusterID, keyPrefix); err != nil {
+		return "", errors.WithMessagef(err, "set cluster key prefix, clusterName:%s", clusterName)
+	}
+	return storage.ClusterRootPath(m.opts.RootPath, keyPrefix), nil
+}
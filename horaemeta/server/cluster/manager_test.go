@@ -27,8 +27,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
@@ -48,18 +51,47 @@ const (
 	defaultSchemaID                    = 0
 	testRootPath                       = "/rootPath"
 	defaultIDAllocatorStep             = 20
+	defaultSchedulerConcurrency        = 2
 )
 
+var defaultReadyShardStatuses = []storage.ShardStatus{storage.ShardStatusReady}
+
 func newTestStorage(t *testing.T) (storage.Storage, clientv3.KV, *clientv3.Client, etcdutil.CloseFn) {
 	_, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
 	storage := storage.NewStorageWithEtcdBackend(client, testRootPath, storage.Options{
-		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32,
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
 	})
 	return storage, client, client, closeSrv
 }
 
+func newTestManagerOptions(enableSchemaAutoCreation bool) cluster.ManagerOptions {
+	return cluster.ManagerOptions{
+		RootPath:                          testRootPath,
+		IDAllocatorStep:                   defaultIDAllocatorStep,
+		ClusterKeyPrefixes:                nil,
+		TopologyType:                      defaultTopologyType,
+		EnableSchemaAutoCreation:          enableSchemaAutoCreation,
+		SchedulerConcurrency:              defaultSchedulerConcurrency,
+		MaxShardVersionDelta:              metadata.DefaultMaxShardVersionDelta,
+		ReadyShardStatuses:                defaultReadyShardStatuses,
+		NodePickerHash:                    metadata.NodePickerHash{Function: "", Seed: 0},
+		EnableProcedureCheckpoint:         false,
+		CreateTableOfflineShardPolicy:     metadata.OfflineShardPolicyFail,
+		ShardPickers:                      nil,
+		ProcedureTimeouts:                 procedure.Timeouts{},
+		ShardOscillationThreshold:         metadata.ShardOscillationThreshold{MaxMoves: 0, Window: 0},
+		PreferredLeaderStabilizationDelay: 0,
+		NodeStatsHistoryOptions:           metadata.NodeStatsHistoryOptions{Capacity: 0, Interval: 0},
+		MaxInflightCreatesPerShard:        0,
+		MaxPartitionSubTables:             0,
+		SubTableDispatchConcurrency:       0,
+		ProcedureStorageOptions:           procedure.StorageOptions{EtcdPrefix: ""},
+		RecentErrors:                      coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity),
+	}
+}
+
 func newClusterManagerWithStorage(storage storage.Storage, kv clientv3.KV, client *clientv3.Client) (cluster.Manager, error) {
-	return cluster.NewManagerImpl(storage, kv, client, testRootPath, defaultIDAllocatorStep, defaultTopologyType)
+	return cluster.NewManagerImpl(storage, kv, client, newTestManagerOptions(true))
 }
 
 func TestClusterManager(t *testing.T) {
@@ -81,6 +113,8 @@ func TestClusterManager(t *testing.T) {
 
 	testInitShardView(ctx, re, manager, cluster1)
 
+	testUpdateTopologyType(ctx, re, manager, cluster1)
+
 	testGetNodeAndShard(ctx, re, manager, cluster1)
 
 	testGetTables(re, manager, node1, cluster1, 0)
@@ -97,6 +131,8 @@ func TestClusterManager(t *testing.T) {
 
 	testRouteTables(ctx, re, manager, cluster1, defaultSchema, testTableNames)
 
+	testCloneCluster(ctx, re, manager, cluster1, testTableNames)
+
 	for _, tableName := range testTableNames {
 		testDropTable(ctx, re, manager, cluster1, defaultSchema, tableName)
 	}
@@ -104,6 +140,205 @@ func TestClusterManager(t *testing.T) {
 	re.NoError(manager.Stop(ctx))
 }
 
+func TestSchemaAutoCreationDisabled(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	manager, err := cluster.NewManagerImpl(s, kv, client, newTestManagerOptions(false))
+	re.NoError(err)
+
+	re.NoError(manager.Start(ctx))
+	testCreateCluster(ctx, re, manager, cluster1)
+
+	// The unknown schema is rejected rather than created implicitly.
+	_, _, err = manager.AllocSchemaID(ctx, cluster1, defaultSchema)
+	re.Error(err)
+	re.True(coderr.Is(err, coderr.SchemaNotProvisioned))
+
+	// The schema provisioned explicitly can be used.
+	c, err := manager.GetCluster(ctx, cluster1)
+	re.NoError(err)
+	schema, _, err := c.GetMetadata().GetOrCreateSchema(ctx, defaultSchema)
+	re.NoError(err)
+	schemaID, exists, err := manager.AllocSchemaID(ctx, cluster1, defaultSchema)
+	re.NoError(err)
+	re.True(exists)
+	re.Equal(schema.ID, schemaID)
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestClusterSettingsPersisted(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	manager, err := newClusterManagerWithStorage(s, kv, client)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+	testCreateCluster(ctx, re, manager, cluster1)
+
+	c, err := manager.GetCluster(ctx, cluster1)
+	re.NoError(err)
+	re.NoError(c.GetMetadata().UpdateMaxTables(ctx, 10))
+	re.NoError(c.GetMetadata().SetShardsMaintenance(ctx, []storage.ShardID{0, 1}, "maintenance"))
+	re.NoError(c.GetMetadata().ClearShardsMaintenance(ctx, []storage.ShardID{1}))
+	re.NoError(c.GetMetadata().SetShardsMinNodeVersion(ctx, []storage.ShardID{0, 1}, "1.2.0"))
+	re.NoError(c.GetMetadata().ClearShardsMinNodeVersion(ctx, []storage.ShardID{0}))
+	re.NoError(c.GetMetadata().SetPreferredLeader(ctx, []storage.ShardID{0, 1}, node1))
+	re.NoError(c.GetMetadata().ClearPreferredLeader(ctx, []storage.ShardID{1}))
+	re.NoError(c.GetSchedulerManager().UpdateNodePickerStrategy(ctx, nodepicker.StrategyConsistentUniformHash))
+	re.NoError(c.GetMetadata().SetShardPermutation(ctx, true))
+	re.NoError(c.GetMetadata().UpdateDefaultPartitionCount(ctx, 2))
+	re.NoError(manager.Stop(ctx))
+
+	// The settings are restored by the manager started on the new leader.
+	newManager, err := newClusterManagerWithStorage(s, kv, client)
+	re.NoError(err)
+	re.NoError(newManager.Start(ctx))
+	c, err = newManager.GetCluster(ctx, cluster1)
+	re.NoError(err)
+	re.Equal(uint64(10), c.GetMetadata().GetMaxTables())
+	re.Equal(map[storage.ShardID]string{0: "maintenance"}, c.GetMetadata().GetMaintenanceShards())
+	re.Equal(map[storage.ShardID]string{1: "1.2.0"}, c.GetMetadata().GetShardMinNodeVersions())
+	re.Equal(map[storage.ShardID]string{0: node1}, c.GetMetadata().GetPreferredLeaders())
+	re.Equal(nodepicker.StrategyConsistentUniformHash, c.GetMetadata().GetNodePickerStrategy())
+	re.True(c.GetMetadata().IsShardPermutationEnabled())
+	re.Equal(uint32(2), c.GetMetadata().GetDefaultPartitionCount())
+	re.NoError(newManager.Stop(ctx))
+}
+
+func TestPrefixedClusterManager(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	opts := newTestManagerOptions(true)
+	opts.ClusterKeyPrefixes = map[string]string{cluster1: "tenantA"}
+	manager, err := cluster.NewManagerImpl(s, kv, client, opts)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+	testCreateCluster(ctx, re, manager, cluster1)
+	testRegisterNode(ctx, re, manager, cluster1, node1)
+	testRegisterNode(ctx, re, manager, cluster1, node2)
+	testInitShardView(ctx, re, manager, cluster1)
+
+	// Updating the cluster reloads its metadata, which reads the cluster key.
+	testUpdateTopologyType(ctx, re, manager, cluster1)
+	re.NoError(manager.Stop(ctx))
+
+	// The prefixed cluster is loaded by the manager started on the new leader.
+	newManager, err := cluster.NewManagerImpl(s, kv, client, opts)
+	re.NoError(err)
+	re.NoError(newManager.Start(ctx))
+	c, err := newManager.GetCluster(ctx, cluster1)
+	re.NoError(err)
+	re.Equal(storage.TopologyType(defaultTopologyType), c.GetMetadata().GetTopologyType())
+	re.NoError(newManager.Stop(ctx))
+}
+
+func TestDrainRejectedInStaticTopology(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	manager, err := newClusterManagerWithStorage(s, kv, client)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	testCreateCluster(ctx, re, manager, cluster1)
+
+	nodeStats := storage.NewEmptyNodeStats()
+	nodeStats.ShuttingDown = true
+	err = manager.RegisterNode(ctx, cluster1, metadata.RegisteredNode{
+		Node: storage.Node{
+			Name:          node1,
+			LastTouchTime: uint64(time.Now().UnixMilli()),
+			State:         storage.NodeStateOnline,
+			NodeStats:     nodeStats,
+		}, ShardInfos: []metadata.ShardInfo{},
+	})
+	re.ErrorIs(err, metadata.ErrDrainNotSupported)
+
+	// The node is still registered and kept available for its pinned shards.
+	registeredNode, err := manager.GetRegisteredNode(ctx, cluster1, node1)
+	re.NoError(err)
+	re.False(registeredNode.IsShuttingDown())
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestCreateClusterWithShardIDs(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	manager, err := newClusterManagerWithStorage(s, kv, client)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	newOpts := func(shardIDs []storage.ShardID) metadata.CreateClusterOpts {
+		return metadata.CreateClusterOpts{
+			NodeCount:                   defaultNodeCount,
+			EnableSchedule:              false,
+			ShardTotal:                  4,
+			TopologyType:                defaultTopologyType,
+			ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+			ShardIDs:                    shardIDs,
+		}
+	}
+
+	// The shard ids must be as many as the shards, must not be duplicate and must be less than the shard total.
+	_, err = manager.CreateCluster(ctx, cluster1, newOpts([]storage.ShardID{0, 1, 2}))
+	re.True(coderr.Is(err, metadata.ErrInvalidShardIDs.Code()))
+	_, err = manager.CreateCluster(ctx, cluster1, newOpts([]storage.ShardID{0, 1, 1, 2}))
+	re.True(coderr.Is(err, metadata.ErrInvalidShardIDs.Code()))
+	_, err = manager.CreateCluster(ctx, cluster1, newOpts([]storage.ShardID{10, 11, 12, 13}))
+	re.True(coderr.Is(err, metadata.ErrInvalidShardIDs.Code()))
+
+	shardIDs := []storage.ShardID{3, 1, 0, 2}
+	c, err := manager.CreateCluster(ctx, cluster1, newOpts(shardIDs))
+	re.NoError(err)
+	shardViews := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping
+	re.Len(shardViews, len(shardIDs))
+	for _, shardID := range shardIDs {
+		re.Contains(shardViews, shardID)
+	}
+
+	// The shard ids allocated later never collide with the specified ones.
+	for i := 0; i < 16; i++ {
+		shardID, err := c.GetMetadata().AllocShardID(ctx)
+		re.NoError(err)
+		re.NotContains(shardIDs, storage.ShardID(shardID))
+	}
+
+	// All the shards are assigned by a scheduling pass.
+	testRegisterNode(ctx, re, manager, cluster1, node1)
+	testRegisterNode(ctx, re, manager, cluster1, node2)
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStatePrepare, []storage.ShardNode{}))
+	numProcedures := 0
+	for _, result := range c.GetSchedulerManager().Scheduler(ctx, c.GetMetadata().GetClusterSnapshot()) {
+		if result.Procedure != nil {
+			numProcedures++
+			re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, len(shardIDs))
+		}
+	}
+	re.Equal(1, numProcedures)
+
+	re.NoError(manager.Stop(ctx))
+}
+
 func testGetNodeAndShard(ctx context.Context, re *require.Assertions, manager cluster.Manager, clusterName string) {
 	c, err := manager.GetCluster(ctx, clusterName)
 	re.NoError(err)
@@ -139,6 +374,93 @@ func testInitShardView(ctx context.Context, re *require.Assertions, manager clus
 	re.NoError(err)
 }
 
+func testUpdateTopologyType(ctx context.Context, re *require.Assertions, manager cluster.Manager, clusterName string) {
+	c, err := manager.GetCluster(ctx, clusterName)
+	re.NoError(err)
+
+	err = manager.UpdateCluster(ctx, clusterName, metadata.UpdateClusterOpts{
+		TopologyType:                storage.TopologyTypeDynamic,
+		ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+	})
+	re.NoError(err)
+	re.Equal(storage.TopologyType(storage.TopologyTypeDynamic), c.GetMetadata().GetTopologyType())
+	_, err = c.GetSchedulerManager().GetEnableSchedule(ctx)
+	re.NoError(err)
+
+	// Switching topology type is rejected when the cluster is not quiescent.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStatePrepare, snapshot.Topology.ClusterView.ShardNodes))
+	err = manager.UpdateCluster(ctx, clusterName, metadata.UpdateClusterOpts{
+		TopologyType:                defaultTopologyType,
+		ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+	})
+	re.Error(err)
+	re.ErrorIs(err, metadata.ErrUnsafeTopologyTypeChange)
+	re.Equal(storage.TopologyType(storage.TopologyTypeDynamic), c.GetMetadata().GetTopologyType())
+
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, snapshot.Topology.ClusterView.ShardNodes))
+	err = manager.UpdateCluster(ctx, clusterName, metadata.UpdateClusterOpts{
+		TopologyType:                defaultTopologyType,
+		ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+	})
+	re.NoError(err)
+	re.Equal(storage.TopologyType(defaultTopologyType), c.GetMetadata().GetTopologyType())
+	_, err = c.GetSchedulerManager().GetEnableSchedule(ctx)
+	re.Error(err)
+}
+
+func testCloneCluster(ctx context.Context, re *require.Assertions, manager cluster.Manager, sourceClusterName string, tableNames []string) {
+	cloneClusterName := sourceClusterName + "Clone"
+	source, err := manager.GetCluster(ctx, sourceClusterName)
+	re.NoError(err)
+
+	c, err := manager.CloneCluster(ctx, sourceClusterName, cloneClusterName)
+	re.NoError(err)
+	re.NotEqual(source.GetMetadata().GetClusterID(), c.GetMetadata().GetClusterID())
+	re.Equal(source.GetMetadata().GetTotalShardNum(), c.GetMetadata().GetTotalShardNum())
+	re.Equal(source.GetMetadata().GetClusterMinNodeCount(), c.GetMetadata().GetClusterMinNodeCount())
+	re.Equal(source.GetMetadata().GetTopologyType(), c.GetMetadata().GetTopologyType())
+	re.Equal(storage.ClusterStateEmpty, c.GetMetadata().GetClusterState())
+
+	// The table metadata is copied without assigning to any shard.
+	numCopied, err := c.GetMetadata().CopyTableMetadataFrom(ctx, source.GetMetadata())
+	re.NoError(err)
+	re.Equal(len(tableNames), numCopied)
+	for _, tableName := range tableNames {
+		_, exists, err := c.GetMetadata().GetTable(defaultSchema, tableName)
+		re.NoError(err)
+		re.True(exists)
+	}
+	topology := c.GetMetadata().GetClusterSnapshot().Topology
+	re.Equal(0, topology.TableCount())
+
+	// The copied tables exist in both clusters, but they are not placed on the same shards.
+	diff := metadata.CompareTopology(source.GetMetadata(), c.GetMetadata())
+	re.False(diff.Equivalent)
+	re.Equal(diff.SourceShardTotal, diff.TargetShardTotal)
+	re.Empty(diff.ShardsOnlyInSource)
+	re.Empty(diff.ShardsOnlyInTarget)
+	re.Empty(diff.TablesOnlyInSource)
+	re.Empty(diff.TablesOnlyInTarget)
+	re.Len(diff.PlacementMismatches, len(tableNames))
+	for _, mismatch := range diff.PlacementMismatches {
+		re.NotNil(mismatch.SourceShardID)
+		re.Nil(mismatch.TargetShardID)
+	}
+	re.True(metadata.CompareTopology(source.GetMetadata(), source.GetMetadata()).Equivalent)
+
+	// Copying again is a no-op.
+	numCopied, err = c.GetMetadata().CopyTableMetadataFrom(ctx, source.GetMetadata())
+	re.NoError(err)
+	re.Equal(0, numCopied)
+
+	// The name of the new cluster must be free.
+	_, err = manager.CloneCluster(ctx, sourceClusterName, cloneClusterName)
+	re.Error(err)
+	_, err = manager.CloneCluster(ctx, "notExistCluster", cloneClusterName+"1")
+	re.Error(err)
+}
+
 func testCreateCluster(ctx context.Context, re *require.Assertions, manager cluster.Manager, clusterName string) {
 	_, err := manager.CreateCluster(ctx, clusterName, metadata.CreateClusterOpts{
 		NodeCount:                   defaultNodeCount,
@@ -146,6 +468,7 @@ func testCreateCluster(ctx context.Context, re *require.Assertions, manager clus
 		ShardTotal:                  defaultShardTotal,
 		TopologyType:                defaultTopologyType,
 		ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+		ShardIDs:                    nil,
 	})
 	re.NoError(err)
 }
@@ -206,12 +529,20 @@ func testGetTables(re *require.Assertions, manager cluster.Manager, node, cluste
 }
 
 func testRouteTables(ctx context.Context, re *require.Assertions, manager cluster.Manager, cluster, schema string, tableNames []string) {
+	c, err := manager.GetCluster(ctx, cluster)
+	re.NoError(err)
+	shardViews := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping
+
 	ret, err := manager.RouteTables(ctx, cluster, schema, tableNames)
 	re.NoError(err)
 	re.Equal(len(tableNames), len(ret.RouteEntries))
+	re.Empty(ret.FailedTables)
 	for _, entry := range ret.RouteEntries {
 		re.Equal(1, len(entry.NodeShards))
 		re.Equal(storage.ShardRoleLeader, entry.NodeShards[0].ShardNode.ShardRole)
+		// The shard version is returned for clients to detect stale routes.
+		shardID := entry.NodeShards[0].ShardInfo.ID
+		re.Equal(shardViews[shardID].Version, entry.NodeShards[0].ShardInfo.Version)
 	}
 }
 
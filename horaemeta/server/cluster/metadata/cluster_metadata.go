@@ -23,10 +23,13 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"maps"
 	"math/big"
 	"path"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/id"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -38,6 +41,18 @@ import (
 const (
 	AllocSchemaIDPrefix = "SchemaID"
 	AllocTableIDPrefix  = "TableID"
+	// The offsets in the table id ranges of the schemas are allocated under the prefix.
+	AllocRangeTableIDPrefix = "RangeTableID"
+
+	// DefaultMaxShardVersionDelta is the default max delta of the shard version in a single table operation.
+	DefaultMaxShardVersionDelta = 1000
+	// MaxShardLeaderHistoryLen is the max number of the leader changes kept for every shard.
+	MaxShardLeaderHistoryLen = 32
+	// ShardLeaderHistoryRetention is how long the leader changes of every shard are kept.
+	ShardLeaderHistoryRetention = 7 * 24 * time.Hour
+	// MinOrphanTableAge is the min age of the orphan tables to be dropped, because the metadata of the table in creation is written before
+	// it is added to the shard.
+	MinOrphanTableAge = 10 * time.Minute
 )
 
 type ClusterMetadata struct {
@@ -54,15 +69,68 @@ type ClusterMetadata struct {
 
 	// Manage the registered nodes from heartbeat.
 	registeredNodesCache map[string]RegisteredNode // nodeName -> NodeName
+	// The max number of tables the cluster can hold, zero means unlimited.
+	maxTables uint64
+	// The number of the partitions of the partition tables created without partitions, zero means no default.
+	defaultPartitionCount uint32
+	// The max delta of the shard version in a single table operation, zero means unlimited.
+	maxShardVersionDelta uint64
+	// The shards under maintenance will be skipped by the schedulers, shardID -> reason.
+	maintenanceShards map[storage.ShardID]string
+	// The shard statuses considered as ready, the shards in other statuses may be reopened by the schedulers.
+	readyShardStatuses []storage.ShardStatus
+	// The min node version required by the shards, the older nodes are skipped when picking node for them, shardID -> version.
+	shardMinNodeVersions map[storage.ShardID]string
+	// The node preferred to be the leader of the shards if it is online, shardID -> nodeName.
+	preferredLeaders map[storage.ShardID]string
+	// The strategy of the node picker used by the schedulers, empty means the default strategy.
+	nodePickerStrategy string
+	// The hash function used by the node picker.
+	nodePickerHash NodePickerHash
+	// Whether the shard ids are permuted before placement, so that the adjacent shards are spread over the nodes.
+	shardPermutation bool
+	// Whether the fsm state transitions of the procedures are checkpointed to the procedure storage.
+	enableProcedureCheckpoint bool
+	// How the create table procedure behaves if the leader node of the picked shard is offline.
+	createTableOfflineShardPolicy OfflineShardPolicy
+	// The name of the shard picker used when creating tables, empty means the default picker.
+	shardPicker string
+	// How frequently the leader of a shard is allowed to move before its further moves are suppressed by the scheduler manager.
+	shardOscillationThreshold ShardOscillationThreshold
+	// How long the node of a preferred leader must stay online before the leadership is moved back to it.
+	preferredLeaderStabilizationDelay time.Duration
+	// The bounded utilization history of the registered nodes sampled from their heartbeats, which is kept in memory only.
+	nodeStatsHistory *nodeStatsHistory
+	// The key of the end id persisted by the table id allocator of the schemas without range.
+	tableIDAllocKey string
+	// rangeTableIDAllocKey returns the key of the end id persisted by the allocator of the table id range of the schema.
+	rangeTableIDAllocKey func(schemaName string) string
+	// The table creations which have picked their shards but are not finished yet, which is kept in memory only.
+	inflightCreates *inflightCreates
+	// The max number of the table creations in flight on a shard, zero means unlimited.
+	maxInflightCreatesPerShard uint32
+	// The max number of the sub tables of a partition table, zero means unlimited.
+	maxPartitionSubTables uint32
+	// The max number of the shards creating the sub tables of a partition table concurrently, zero means unlimited.
+	subTableDispatchConcurrency uint32
+	// When the metadata is created or loaded by the current leader, and the nodes are registered only after they heartbeat to it.
+	loadedAt time.Time
 
 	storage      storage.Storage
 	kv           clientv3.KV
 	shardIDAlloc id.Allocator
 }
 
-func NewClusterMetadata(logger *zap.Logger, meta storage.Cluster, storage storage.Storage, kv clientv3.KV, rootPath string, idAllocatorStep uint) *ClusterMetadata {
+func NewClusterMetadata(logger *zap.Logger, meta storage.Cluster, metaStorage storage.Storage, kv clientv3.KV, rootPath string, idAllocatorStep uint) *ClusterMetadata {
 	schemaIDAlloc := id.NewAllocatorImpl(logger, kv, path.Join(rootPath, meta.Name, AllocSchemaIDPrefix), idAllocatorStep)
-	tableIDAlloc := id.NewAllocatorImpl(logger, kv, path.Join(rootPath, meta.Name, AllocTableIDPrefix), idAllocatorStep)
+	tableIDAllocKey := path.Join(rootPath, meta.Name, AllocTableIDPrefix)
+	tableIDAlloc := id.NewAllocatorImpl(logger, kv, tableIDAllocKey, idAllocatorStep)
+	rangeTableIDAllocKey := func(schemaName string) string {
+		return path.Join(rootPath, meta.Name, AllocRangeTableIDPrefix, schemaName)
+	}
+	newRangeTableIDAlloc := func(schemaName string) id.Allocator {
+		return id.NewAllocatorImpl(logger, kv, rangeTableIDAllocKey(schemaName), idAllocatorStep)
+	}
 	// FIXME: Load ShardTopology when cluster create, pass exist ShardID to allocator.
 	shardIDAlloc := id.NewReusableAllocatorImpl([]uint64{}, MinShardID)
 
@@ -71,12 +139,37 @@ func NewClusterMetadata(logger *zap.Logger, meta storage.Cluster, storage storag
 		clusterID:            meta.ID,
 		lock:                 sync.RWMutex{},
 		metaData:             meta,
-		tableManager:         NewTableManagerImpl(logger, storage, meta.ID, schemaIDAlloc, tableIDAlloc),
-		topologyManager:      NewTopologyManagerImpl(logger, storage, meta.ID, shardIDAlloc),
+		tableManager:         NewTableManagerImpl(logger, metaStorage, meta.ID, schemaIDAlloc, tableIDAlloc, newRangeTableIDAlloc),
+		topologyManager:      NewTopologyManagerImpl(logger, metaStorage, meta.ID, shardIDAlloc),
 		registeredNodesCache: map[string]RegisteredNode{},
-		storage:              storage,
-		kv:                   kv,
-		shardIDAlloc:         shardIDAlloc,
+		maxTables:            0,
+		maxShardVersionDelta: DefaultMaxShardVersionDelta,
+		maintenanceShards:    map[storage.ShardID]string{},
+		readyShardStatuses:   []storage.ShardStatus{storage.ShardStatusReady},
+		shardMinNodeVersions: map[storage.ShardID]string{},
+		preferredLeaders:     map[storage.ShardID]string{},
+		nodePickerStrategy:   "",
+		nodePickerHash:       NodePickerHash{Function: "", Seed: 0},
+		shardPermutation:     false,
+
+		enableProcedureCheckpoint:         false,
+		createTableOfflineShardPolicy:     OfflineShardPolicyFail,
+		shardPicker:                       "",
+		shardOscillationThreshold:         ShardOscillationThreshold{MaxMoves: 0, Window: 0},
+		preferredLeaderStabilizationDelay: 0,
+		defaultPartitionCount:             0,
+		nodeStatsHistory:                  newNodeStatsHistory(NodeStatsHistoryOptions{Capacity: 0, Interval: 0}),
+		tableIDAllocKey:                   tableIDAllocKey,
+		rangeTableIDAllocKey:              rangeTableIDAllocKey,
+		inflightCreates:                   newInflightCreates(),
+		maxInflightCreatesPerShard:        0,
+		maxPartitionSubTables:             0,
+		subTableDispatchConcurrency:       0,
+		loadedAt:                          time.Now(),
+
+		storage:      metaStorage,
+		kv:           kv,
+		shardIDAlloc: shardIDAlloc,
 	}
 
 	return cluster
@@ -85,19 +178,41 @@ func NewClusterMetadata(logger *zap.Logger, meta storage.Cluster, storage storag
 // Initialize the cluster view and shard view of the cluster.
 // It will be used when we create the cluster.
 func (c *ClusterMetadata) Init(ctx context.Context) error {
+	return c.InitWithShardIDs(ctx, nil)
+}
+
+// InitWithShardIDs initializes the cluster like Init, but the shards take the given ids if shardIDs is not empty, which are reserved
+// in the shard id allocator so that the shards created later never collide with them.
+func (c *ClusterMetadata) InitWithShardIDs(ctx context.Context, shardIDs []storage.ShardID) error {
+	if err := ValidateShardIDs(shardIDs, c.metaData.ShardTotal); err != nil {
+		return err
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	createShardViews := make([]CreateShardView, 0, c.metaData.ShardTotal)
-	for i := uint32(0); i < c.metaData.ShardTotal; i++ {
-		shardID, err := c.AllocShardID(ctx)
-		if err != nil {
-			return errors.WithMessage(err, "alloc shard id failed")
+	if len(shardIDs) > 0 {
+		reservedIDs := make([]uint64, 0, len(shardIDs))
+		for _, shardID := range shardIDs {
+			reservedIDs = append(reservedIDs, uint64(shardID))
+			createShardViews = append(createShardViews, CreateShardView{
+				ShardID: shardID,
+				Tables:  []storage.TableID{},
+			})
+		}
+		c.shardIDAlloc = id.NewReusableAllocatorImpl(reservedIDs, MinShardID)
+	} else {
+		for i := uint32(0); i < c.metaData.ShardTotal; i++ {
+			shardID, err := c.AllocShardID(ctx)
+			if err != nil {
+				return errors.WithMessage(err, "alloc shard id failed")
+			}
+			createShardViews = append(createShardViews, CreateShardView{
+				ShardID: storage.ShardID(shardID),
+				Tables:  []storage.TableID{},
+			})
 		}
-		createShardViews = append(createShardViews, CreateShardView{
-			ShardID: storage.ShardID(shardID),
-			Tables:  []storage.TableID{},
-		})
 	}
 	if err := c.topologyManager.CreateShardViews(ctx, createShardViews); err != nil {
 		return errors.WithMessage(err, "create shard view")
@@ -120,6 +235,59 @@ func (c *ClusterMetadata) Load(ctx context.Context) error {
 		return errors.WithMessage(err, "load topology manager")
 	}
 
+	settingsResult, err := c.storage.GetClusterSettings(ctx, storage.GetClusterSettingsRequest{ClusterID: c.clusterID})
+	if err != nil {
+		return errors.WithMessage(err, "load cluster settings")
+	}
+	c.applySettingsLocked(settingsResult.Settings)
+	c.loadedAt = time.Now()
+
+	return nil
+}
+
+// settingsLocked returns the runtime settings of the cluster to be persisted.
+func (c *ClusterMetadata) settingsLocked() storage.ClusterSettings {
+	return storage.ClusterSettings{
+		MaxTables:            c.maxTables,
+		MaintenanceShards:    maps.Clone(c.maintenanceShards),
+		ShardMinNodeVersions: maps.Clone(c.shardMinNodeVersions),
+		PreferredLeaders:     maps.Clone(c.preferredLeaders),
+		NodePickerStrategy:   c.nodePickerStrategy,
+		ShardPermutation:     c.shardPermutation,
+
+		DefaultPartitionCount: c.defaultPartitionCount,
+	}
+}
+
+// applySettingsLocked applies the runtime settings loaded from the storage.
+func (c *ClusterMetadata) applySettingsLocked(settings storage.ClusterSettings) {
+	c.maxTables = settings.MaxTables
+	c.maintenanceShards = settings.MaintenanceShards
+	if c.maintenanceShards == nil {
+		c.maintenanceShards = map[storage.ShardID]string{}
+	}
+	c.shardMinNodeVersions = settings.ShardMinNodeVersions
+	if c.shardMinNodeVersions == nil {
+		c.shardMinNodeVersions = map[storage.ShardID]string{}
+	}
+	c.preferredLeaders = settings.PreferredLeaders
+	if c.preferredLeaders == nil {
+		c.preferredLeaders = map[storage.ShardID]string{}
+	}
+	c.nodePickerStrategy = settings.NodePickerStrategy
+	c.shardPermutation = settings.ShardPermutation
+	c.defaultPartitionCount = settings.DefaultPartitionCount
+}
+
+// updateSettingsLocked persists the runtime settings modified by the update, and applies them only if they are persisted, so that
+// the settings in memory never diverge from the storage.
+func (c *ClusterMetadata) updateSettingsLocked(ctx context.Context, update func(settings *storage.ClusterSettings)) error {
+	settings := c.settingsLocked()
+	update(&settings)
+	if err := c.storage.PutClusterSettings(ctx, storage.PutClusterSettingsRequest{ClusterID: c.clusterID, Settings: settings}); err != nil {
+		return errors.WithMessage(err, "persist cluster settings")
+	}
+	c.applySettingsLocked(settings)
 	return nil
 }
 
@@ -211,6 +379,10 @@ func (c *ClusterMetadata) DropTable(ctx context.Context, request DropTableReques
 		return ErrTableNotFound
 	}
 
+	if err := c.checkShardVersionDelta(request.ShardID, request.LatestVersion); err != nil {
+		return err
+	}
+
 	// Drop table.
 	err = c.tableManager.DropTable(ctx, request.SchemaName, request.TableName)
 	if err != nil {
@@ -275,11 +447,217 @@ func (c *ClusterMetadata) GetOrCreateSchema(ctx context.Context, schemaName stri
 	return c.tableManager.GetOrCreateSchema(ctx, schemaName)
 }
 
+// GetSchema the second output parameter bool: returns true if the schema exists.
+func (c *ClusterMetadata) GetSchema(schemaName string) (storage.Schema, bool) {
+	return c.tableManager.GetSchema(schemaName)
+}
+
+// GetSchemas returns all the schemas of the cluster.
+func (c *ClusterMetadata) GetSchemas() []storage.Schema {
+	return c.tableManager.GetSchemas()
+}
+
+// GetTablesOfSchema returns all the tables of the schema.
+func (c *ClusterMetadata) GetTablesOfSchema(schemaName string) []storage.Table {
+	return c.tableManager.GetTablesOfSchema(schemaName)
+}
+
+// GetTableCountOfSchema returns the number of tables of the schema, and ErrSchemaNotFound is returned if the schema doesn't exist.
+func (c *ClusterMetadata) GetTableCountOfSchema(schemaName string) (int, error) {
+	count, exists := c.tableManager.GetTableCountOfSchema(schemaName)
+	if !exists {
+		return 0, ErrSchemaNotFound.WithCausef("schemaName:%s", schemaName)
+	}
+	return count, nil
+}
+
+// GetShardSpreadOfSchema returns how the tables of the schema are spread over the shards, and ErrSchemaNotFound is returned if
+// the schema doesn't exist.
+func (c *ClusterMetadata) GetShardSpreadOfSchema(ctx context.Context, schemaName string) (SchemaShardSpread, error) {
+	var emptySpread SchemaShardSpread
+	if _, exists := c.tableManager.GetSchema(schemaName); !exists {
+		return emptySpread, ErrSchemaNotFound.WithCausef("schemaName:%s", schemaName)
+	}
+
+	tables := c.tableManager.GetTablesOfSchema(schemaName)
+	spread := SchemaShardSpread{
+		TableCount:           len(tables),
+		ShardTableCounts:     make(map[storage.ShardID]int),
+		PartitionTableCount:  0,
+		UnassignedTableCount: 0,
+	}
+	for _, table := range tables {
+		if table.IsPartitioned() {
+			spread.PartitionTableCount++
+			continue
+		}
+		shardID, ok := c.topologyManager.GetTableShardID(ctx, table)
+		if !ok {
+			spread.UnassignedTableCount++
+			continue
+		}
+		spread.ShardTableCounts[shardID]++
+	}
+	return spread, nil
+}
+
+// FindOrphanTables returns the tables not contained by any shard view in the snapshot, ordered by the table id. The partition tables
+// are never on any shard, so they are not considered as orphans.
+func (c *ClusterMetadata) FindOrphanTables() []OrphanTable {
+	snapshot := c.GetClusterSnapshot()
+	tableIDsOnShards := make(map[storage.TableID]struct{})
+	for _, shardView := range snapshot.Topology.ShardViewsMapping {
+		for _, tableID := range shardView.TableIDs {
+			tableIDsOnShards[tableID] = struct{}{}
+		}
+	}
+
+	orphans := make([]OrphanTable, 0)
+	for _, schema := range c.tableManager.GetSchemas() {
+		for _, table := range c.tableManager.GetTablesOfSchema(schema.Name) {
+			if table.IsPartitioned() {
+				continue
+			}
+			if _, ok := tableIDsOnShards[table.ID]; ok {
+				continue
+			}
+			orphans = append(orphans, OrphanTable{SchemaName: schema.Name, Table: table})
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Table.ID < orphans[j].Table.ID })
+	return orphans
+}
+
+// DropOrphanTables drops the metadata of the orphan tables created before now minus minAge, and the younger ones are kept because
+// they may be still in creation. The minAge must not be less than MinOrphanTableAge. The orphans to be dropped are returned, and nothing
+// will be dropped if dryRun is set.
+func (c *ClusterMetadata) DropOrphanTables(ctx context.Context, minAge time.Duration, now time.Time, dryRun bool) ([]OrphanTable, error) {
+	if minAge < MinOrphanTableAge {
+		return []OrphanTable{}, ErrInvalidOrphanTableAge.WithCausef("minAge:%s, min:%s", minAge, MinOrphanTableAge)
+	}
+
+	deadline := uint64(now.Add(-minAge).UnixMilli())
+	targets := make([]OrphanTable, 0)
+	for _, orphan := range c.FindOrphanTables() {
+		if orphan.Table.CreatedAt > deadline {
+			continue
+		}
+		targets = append(targets, orphan)
+	}
+	if dryRun {
+		return targets, nil
+	}
+
+	dropped := make([]OrphanTable, 0, len(targets))
+	for _, orphan := range targets {
+		ok, err := c.dropOrphanTable(ctx, orphan)
+		if err != nil {
+			return dropped, errors.WithMessagef(err, "drop orphan table, schemaName:%s, tableName:%s", orphan.SchemaName, orphan.Table.Name)
+		}
+		if !ok {
+			c.logger.Info("skip orphan table added to shard or recreated", zap.String("cluster", c.Name()), zap.String("schemaName", orphan.SchemaName), zap.String("table", fmt.Sprintf("%+v", orphan.Table)))
+			continue
+		}
+		c.logger.Info("drop orphan table", zap.String("cluster", c.Name()), zap.String("schemaName", orphan.SchemaName), zap.String("table", fmt.Sprintf("%+v", orphan.Table)))
+		dropped = append(dropped, orphan)
+	}
+	return dropped, nil
+}
+
+// dropOrphanTable drops the metadata of the orphan table under the topology lock, so that the table can't be added to any shard by a
+// running creation meanwhile. False is returned if the table is not an orphan any more or it is recreated since the snapshot.
+func (c *ClusterMetadata) dropOrphanTable(ctx context.Context, orphan OrphanTable) (bool, error) {
+	dropped := false
+	onShards, err := c.topologyManager.RunIfTableOnNoShard(orphan.Table.ID, func() error {
+		table, exists, err := c.tableManager.GetTable(orphan.SchemaName, orphan.Table.Name)
+		if err != nil {
+			return errors.WithMessage(err, "get table")
+		}
+		if !exists || table.ID != orphan.Table.ID {
+			return nil
+		}
+		if err := c.tableManager.DropTable(ctx, orphan.SchemaName, orphan.Table.Name); err != nil {
+			return err
+		}
+		dropped = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return !onShards && dropped, nil
+}
+
 // GetTable the second output parameter bool: returns true if the table exists.
 func (c *ClusterMetadata) GetTable(schemaName, tableName string) (storage.Table, bool, error) {
 	return c.tableManager.GetTable(schemaName, tableName)
 }
 
+// GetPartitionTableLayout returns the shard and nodes of every sub table of the partition table.
+func (c *ClusterMetadata) GetPartitionTableLayout(ctx context.Context, schemaName, tableName string) (PartitionTableLayout, error) {
+	var layout PartitionTableLayout
+
+	table, exists, err := c.tableManager.GetTable(schemaName, tableName)
+	if err != nil {
+		return layout, errors.WithMessage(err, "get table")
+	}
+	if !exists {
+		return layout, ErrTableNotFound.WithCausef("schema:%s, table:%s", schemaName, tableName)
+	}
+	if !table.IsPartitioned() {
+		return layout, ErrTableNotPartitioned.WithCausef("schema:%s, table:%s", schemaName, tableName)
+	}
+
+	definitions := getPartitionDefinitions(table.PartitionInfo.Info)
+	subTables := make([]SubTableLayout, 0, len(definitions))
+	for _, definition := range definitions {
+		subTableName := FormatSubTableName(tableName, definition.GetName())
+		subTableLayout := SubTableLayout{
+			TableName: subTableName,
+			Exists:    false,
+			TableID:   0,
+			ShardID:   0,
+			NodeNames: []string{},
+		}
+
+		subTable, exists, err := c.tableManager.GetTable(schemaName, subTableName)
+		if err != nil {
+			return layout, errors.WithMessagef(err, "get sub table, table:%s", subTableName)
+		}
+		if !exists {
+			subTables = append(subTables, subTableLayout)
+			continue
+		}
+		subTableLayout.Exists = true
+		subTableLayout.TableID = subTable.ID
+
+		shardID, ok := c.topologyManager.GetTableShardID(ctx, subTable)
+		if !ok {
+			subTables = append(subTables, subTableLayout)
+			continue
+		}
+		subTableLayout.ShardID = shardID
+		// The shard may not be assigned to any node, e.g. when the cluster is not stable.
+		if shardNodes, err := c.topologyManager.GetShardNodesByID(shardID); err == nil {
+			for _, shardNode := range shardNodes {
+				subTableLayout.NodeNames = append(subTableLayout.NodeNames, shardNode.NodeName)
+			}
+		}
+		subTables = append(subTables, subTableLayout)
+	}
+
+	layout.Table = TableInfo{
+		ID:            table.ID,
+		Name:          table.Name,
+		SchemaID:      table.SchemaID,
+		SchemaName:    schemaName,
+		PartitionInfo: table.PartitionInfo,
+		CreatedAt:     table.CreatedAt,
+	}
+	layout.SubTables = subTables
+	return layout, nil
+}
+
 // GetTableShard get the shard where the table actually exists.
 func (c *ClusterMetadata) GetTableShard(ctx context.Context, table storage.Table) (storage.ShardID, bool) {
 	return c.topologyManager.GetTableShardID(ctx, table)
@@ -315,6 +693,35 @@ func (c *ClusterMetadata) CreateTableMetadata(ctx context.Context, request Creat
 	return res, nil
 }
 
+// CopyTableMetadataFrom creates the schemas and the table metadata of the source cluster which don't exist in this cluster, and the number of the created tables is returned.
+// Only the metadata is copied, and the created tables are not assigned to any shard.
+func (c *ClusterMetadata) CopyTableMetadataFrom(ctx context.Context, source *ClusterMetadata) (int, error) {
+	numCopied := 0
+	for _, schema := range source.tableManager.GetSchemas() {
+		if _, _, err := c.tableManager.GetOrCreateSchema(ctx, schema.Name); err != nil {
+			return numCopied, errors.WithMessagef(err, "create schema, schemaName:%s", schema.Name)
+		}
+
+		for _, table := range source.tableManager.GetTablesOfSchema(schema.Name) {
+			_, exists, err := c.tableManager.GetTable(schema.Name, table.Name)
+			if err != nil {
+				return numCopied, errors.WithMessagef(err, "get table, schemaName:%s, tableName:%s", schema.Name, table.Name)
+			}
+			if exists {
+				continue
+			}
+
+			if _, err := c.tableManager.CreateTable(ctx, schema.Name, table.Name, table.PartitionInfo); err != nil {
+				return numCopied, errors.WithMessagef(err, "create table, schemaName:%s, tableName:%s", schema.Name, table.Name)
+			}
+			numCopied++
+		}
+	}
+
+	c.logger.Info("copy table metadata finish", zap.String("cluster", c.Name()), zap.String("sourceCluster", source.Name()), zap.Int("numCopied", numCopied))
+	return numCopied, nil
+}
+
 func (c *ClusterMetadata) AddTableTopology(ctx context.Context, shardVersionUpdate ShardVersionUpdate, table storage.Table) error {
 	c.logger.Info("add table topology start", zap.String("cluster", c.Name()), zap.String("tableName", table.Name))
 
@@ -322,6 +729,10 @@ func (c *ClusterMetadata) AddTableTopology(ctx context.Context, shardVersionUpda
 		return errors.WithMessage(ErrClusterStateInvalid, "invalid cluster state, cluster state must be stable")
 	}
 
+	if err := c.checkShardVersionDelta(shardVersionUpdate.ShardID, shardVersionUpdate.LatestVersion); err != nil {
+		return err
+	}
+
 	// Add table to topology manager.
 	err := c.topologyManager.AddTable(ctx, shardVersionUpdate.ShardID, shardVersionUpdate.LatestVersion, []storage.Table{table})
 	if err != nil {
@@ -332,6 +743,27 @@ func (c *ClusterMetadata) AddTableTopology(ctx context.Context, shardVersionUpda
 	return nil
 }
 
+// RemoveTableTopology removes the table from the shard view while keeping the table metadata, the table is left unassigned.
+func (c *ClusterMetadata) RemoveTableTopology(ctx context.Context, shardVersionUpdate ShardVersionUpdate, table storage.Table) error {
+	c.logger.Info("remove table topology start", zap.String("cluster", c.Name()), zap.String("tableName", table.Name))
+
+	if !c.ensureClusterStable() {
+		return errors.WithMessage(ErrClusterStateInvalid, "invalid cluster state, cluster state must be stable")
+	}
+
+	if err := c.checkShardVersionDelta(shardVersionUpdate.ShardID, shardVersionUpdate.LatestVersion); err != nil {
+		return err
+	}
+
+	err := c.topologyManager.RemoveTable(ctx, shardVersionUpdate.ShardID, shardVersionUpdate.LatestVersion, []storage.TableID{table.ID})
+	if err != nil {
+		return errors.WithMessage(err, "topology manager remove table")
+	}
+
+	c.logger.Info("remove table topology succeed", zap.String("cluster", c.Name()), zap.String("table", fmt.Sprintf("%+v", table)), zap.String("shardVersionUpdate", fmt.Sprintf("%+v", shardVersionUpdate)))
+	return nil
+}
+
 func (c *ClusterMetadata) DropTableMetadata(ctx context.Context, schemaName, tableName string) (DropTableMetadataResult, error) {
 	c.logger.Info("drop table start", zap.String("cluster", c.Name()), zap.String("schemaName", schemaName), zap.String("tableName", tableName))
 
@@ -423,6 +855,67 @@ func (c *ClusterMetadata) DeleteTableAssignedShard(ctx context.Context, schemaNa
 	return c.topologyManager.DeleteTableAssignedShard(ctx, schema.ID, tableName)
 }
 
+// ListTableAssignments lists the shards persisted by the shard picker for the tables being created, sorted by the schema and table names.
+func (c *ClusterMetadata) ListTableAssignments() []TableAssignment {
+	schemaNames := make(map[storage.SchemaID]string)
+	for _, schema := range c.tableManager.GetSchemas() {
+		schemaNames[schema.ID] = schema.Name
+	}
+	shards := make(map[storage.ShardID]struct{})
+	for _, shardID := range c.topologyManager.GetShards() {
+		shards[shardID] = struct{}{}
+	}
+
+	assignments := make([]TableAssignment, 0)
+	for schemaID, assigns := range c.topologyManager.ListTableAssignedShards() {
+		schemaName, ok := schemaNames[schemaID]
+		if !ok {
+			c.logger.Warn("schema of the table assignments not found", zap.Uint32("schemaID", uint32(schemaID)))
+			continue
+		}
+		for tableName, shardID := range assigns {
+			_, tableExists, _ := c.tableManager.GetTable(schemaName, tableName)
+			_, shardExists := shards[shardID]
+			assignments = append(assignments, TableAssignment{
+				SchemaName:  schemaName,
+				TableName:   tableName,
+				ShardID:     shardID,
+				TableExists: tableExists,
+				ShardExists: shardExists,
+			})
+		}
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].SchemaName != assignments[j].SchemaName {
+			return assignments[i].SchemaName < assignments[j].SchemaName
+		}
+		return assignments[i].TableName < assignments[j].TableName
+	})
+	return assignments
+}
+
+// ClearTableAssignment removes the shard persisted by the shard picker for the table, and the expected shard must match the persisted one
+// so that the assignment made by a concurrent table creation is never removed by mistake.
+func (c *ClusterMetadata) ClearTableAssignment(ctx context.Context, schemaName, tableName string, expectShardID storage.ShardID) error {
+	shardID, exists, err := c.GetTableAssignedShard(ctx, schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTableAssignmentNotFound.WithCausef("schemaName:%s, tableName:%s", schemaName, tableName)
+	}
+	if shardID != expectShardID {
+		return ErrTableAssignmentMismatch.WithCausef("schemaName:%s, tableName:%s, assigned shardID:%d, expected shardID:%d", schemaName, tableName, shardID, expectShardID)
+	}
+
+	if err := c.DeleteTableAssignedShard(ctx, schemaName, tableName); err != nil {
+		return errors.WithMessage(err, "delete table assigned shard")
+	}
+	c.logger.Warn("clear table assignment", zap.String("schemaName", schemaName), zap.String("tableName", tableName), zap.Uint32("shardID", uint32(shardID)))
+	return nil
+}
+
 func (c *ClusterMetadata) GetShards() []storage.ShardID {
 	return c.topologyManager.GetShards()
 }
@@ -431,6 +924,22 @@ func (c *ClusterMetadata) GetShardNodesByShardID(id storage.ShardID) ([]storage.
 	return c.topologyManager.GetShardNodesByID(id)
 }
 
+// GetShardLeader returns the leader of the shard in the current cluster view, false is returned if the shard has no leader.
+func (c *ClusterMetadata) GetShardLeader(id storage.ShardID) (storage.ShardNode, bool) {
+	shardNodes, err := c.topologyManager.GetShardNodesByID(id)
+	if err != nil {
+		var emptyShardNode storage.ShardNode
+		return emptyShardNode, false
+	}
+	for _, shardNode := range shardNodes {
+		if shardNode.ShardRole == storage.ShardRoleLeader {
+			return shardNode, true
+		}
+	}
+	var emptyShardNode storage.ShardNode
+	return emptyShardNode, false
+}
+
 func (c *ClusterMetadata) GetShardNodeByTableIDs(tableIDs []storage.TableID) (GetShardNodesByTableIDsResult, error) {
 	return c.topologyManager.GetShardNodesByTableIDs(tableIDs)
 }
@@ -460,6 +969,7 @@ func (c *ClusterMetadata) RegisterNode(ctx context.Context, registeredNode Regis
 	// Check whether to update persistence data.
 	oldCache, exists := c.registeredNodesCache[registeredNode.Node.Name]
 	c.registeredNodesCache[registeredNode.Node.Name] = registeredNode
+	c.recordNodeStats(registeredNode)
 	enableUpdateWhenStable := c.metaData.TopologyType == storage.TopologyTypeDynamic
 	if !enableUpdateWhenStable && c.topologyManager.GetClusterState() == storage.ClusterStateStable {
 		return nil
@@ -487,6 +997,44 @@ func (c *ClusterMetadata) RegisterNode(ctx context.Context, registeredNode Regis
 	return nil
 }
 
+// recordNodeStats samples the utilization of the node into the node stats history, and the caller should hold the lock.
+// Nothing is computed for the heartbeat within the sampling interval.
+func (c *ClusterMetadata) recordNodeStats(registeredNode RegisteredNode) {
+	if !c.nodeStatsHistory.due(registeredNode.Node.Name, int64(registeredNode.Node.LastTouchTime)) {
+		return
+	}
+	shardIDs := make([]storage.ShardID, 0, len(registeredNode.ShardInfos))
+	for _, shardInfo := range registeredNode.ShardInfos {
+		shardIDs = append(shardIDs, shardInfo.ID)
+	}
+	tableIDs := c.topologyManager.GetTableIDs(shardIDs)
+	c.nodeStatsHistory.record(registeredNode.Node.Name, newNodeStatsSample(registeredNode, c.readyShardStatuses, tableIDs))
+}
+
+// GetNodeStatsHistory returns the utilization samples of the node from the oldest to the latest.
+func (c *ClusterMetadata) GetNodeStatsHistory(nodeName string) []NodeStatsSample {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.nodeStatsHistory.get(nodeName)
+}
+
+// ListNodeStatsHistory returns the utilization samples of all the nodes in the history, nodeName -> samples.
+func (c *ClusterMetadata) ListNodeStatsHistory() map[string][]NodeStatsSample {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.nodeStatsHistory.getAll()
+}
+
+// UpdateNodeStatsHistoryOptions updates the bounds of the node stats history, and the samples taken before are dropped.
+func (c *ClusterMetadata) UpdateNodeStatsHistoryOptions(options NodeStatsHistoryOptions) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.nodeStatsHistory = newNodeStatsHistory(options)
+}
+
 func (c *ClusterMetadata) GetRegisteredNodes() []RegisteredNode {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -506,6 +1054,116 @@ func (c *ClusterMetadata) GetRegisteredNodeByName(nodeName string) (RegisteredNo
 	return registeredNode, ok
 }
 
+// ExpireNode resets the last touch time of the registered node, so the schedulers treat it as expired immediately.
+// The node becomes alive again once its next heartbeat arrives.
+func (c *ClusterMetadata) ExpireNode(ctx context.Context, nodeName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	registeredNode, ok := c.registeredNodesCache[nodeName]
+	if !ok {
+		return ErrNodeNotFound.WithCausef("node name:%s", nodeName)
+	}
+
+	registeredNode.Node.LastTouchTime = 0
+	err := c.storage.CreateOrUpdateNode(ctx, storage.CreateOrUpdateNodeRequest{
+		ClusterID: c.clusterID,
+		Node:      registeredNode.Node,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "update expired node")
+	}
+	c.registeredNodesCache[nodeName] = registeredNode
+
+	c.logger.Warn("node is expired manually", zap.String("node", nodeName))
+	return nil
+}
+
+// CleanupExpiredNodes removes the registered nodes which have been expired longer than the retention, and returns the
+// names of the removed nodes. The nodes still holding shards in the cluster view are kept until their shards are reassigned.
+func (c *ClusterMetadata) CleanupExpiredNodes(ctx context.Context, retention time.Duration, now time.Time) ([]string, error) {
+	if retention <= 0 {
+		return nil, ErrInvalidNodeRetention.WithCausef("retention must be positive, retention:%s", retention)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// The cluster view is read under the lock, so that the node registered with shards concurrently is not removed.
+	shardNodes := c.topologyManager.GetTopology().ClusterView.ShardNodes
+	nodesWithShards := make(map[string]struct{}, len(shardNodes))
+	for _, shardNode := range shardNodes {
+		nodesWithShards[shardNode.NodeName] = struct{}{}
+	}
+
+	removedNodes := []string{}
+	for nodeName, registeredNode := range c.registeredNodesCache {
+		if !registeredNode.IsExpired(now.Add(-retention)) {
+			continue
+		}
+		if _, ok := nodesWithShards[nodeName]; ok {
+			c.logger.Warn("skip cleaning up the expired node holding shards", zap.String("node", nodeName))
+			continue
+		}
+
+		err := c.storage.DeleteNode(ctx, storage.DeleteNodeRequest{
+			ClusterID: c.clusterID,
+			NodeName:  nodeName,
+		})
+		if err != nil {
+			return removedNodes, errors.WithMessagef(err, "delete expired node, node:%s", nodeName)
+		}
+		delete(c.registeredNodesCache, nodeName)
+		c.nodeStatsHistory.remove(nodeName)
+		removedNodes = append(removedNodes, nodeName)
+
+		c.logger.Info("expired node is cleaned up", zap.String("node", nodeName), zap.Uint64("lastTouchTime", registeredNode.Node.LastTouchTime))
+	}
+
+	return removedNodes, nil
+}
+
+// RecordShardLeaderChange persists the change of the shard leader, and the oldest changes of the shard are trimmed if the history is full
+// or they are older than the ShardLeaderHistoryRetention.
+func (c *ClusterMetadata) RecordShardLeaderChange(ctx context.Context, shardID storage.ShardID, oldNode, newNode, reason string) error {
+	err := c.storage.AppendShardLeaderChange(ctx, storage.AppendShardLeaderChangeRequest{
+		ClusterID: c.clusterID,
+		Change: storage.ShardLeaderChange{
+			ShardID:   shardID,
+			OldNode:   oldNode,
+			NewNode:   newNode,
+			Reason:    reason,
+			Timestamp: uint64(time.Now().UnixMilli()),
+		},
+		MaxHistoryLen: MaxShardLeaderHistoryLen,
+		Retention:     ShardLeaderHistoryRetention,
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "record shard leader change, shardID:%d", shardID)
+	}
+	return nil
+}
+
+// GetShardLeaderHistory returns the leader changes of the shard within the ShardLeaderHistoryRetention from the oldest to the newest.
+// The expired changes of the shard are only removed from the storage when a new change is recorded, so they are filtered here.
+func (c *ClusterMetadata) GetShardLeaderHistory(ctx context.Context, shardID storage.ShardID) ([]storage.ShardLeaderChange, error) {
+	result, err := c.storage.ListShardLeaderChanges(ctx, storage.ListShardLeaderChangesRequest{
+		ClusterID: c.clusterID,
+		ShardID:   shardID,
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "list shard leader changes, shardID:%d", shardID)
+	}
+	expiredBefore := uint64(time.Now().Add(-ShardLeaderHistoryRetention).UnixMilli())
+	changes := make([]storage.ShardLeaderChange, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		if change.Timestamp >= expiredBefore {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
 func (c *ClusterMetadata) AllocShardID(ctx context.Context) (uint32, error) {
 	id, err := c.shardIDAlloc.Alloc(ctx)
 	if err != nil {
@@ -514,8 +1172,11 @@ func (c *ClusterMetadata) AllocShardID(ctx context.Context) (uint32, error) {
 	return uint32(id), nil
 }
 
-func (c *ClusterMetadata) RouteTables(_ context.Context, schemaName string, tableNames []string) (RouteTablesResult, error) {
+// RouteTables routes the tables to their shard nodes, and the tables failed to be routed are returned with the reasons in the FailedTables
+// of the result rather than failing the whole batch.
+func (c *ClusterMetadata) RouteTables(ctx context.Context, schemaName string, tableNames []string) (RouteTablesResult, error) {
 	routeEntries := make(map[string]RouteEntry, len(tableNames))
+	failedTables := make(map[string]RouteFailureReason)
 	tables := make(map[storage.TableID]storage.Table, len(tableNames))
 	tableIDs := make([]storage.TableID, 0, len(tableNames))
 	for _, tableName := range tableNames {
@@ -524,6 +1185,7 @@ func (c *ClusterMetadata) RouteTables(_ context.Context, schemaName string, tabl
 			return RouteTablesResult{}, errors.WithMessage(err, "table manager get table")
 		}
 		if !exists {
+			failedTables[tableName] = RouteFailureTableNotFound
 			continue
 		}
 
@@ -573,6 +1235,9 @@ func (c *ClusterMetadata) RouteTables(_ context.Context, schemaName string, tabl
 			nodeShardsResult = []ShardNodeWithVersion{nodeShards[selectIndex.Uint64()]}
 		}
 		table := tables[tableID]
+		if reason, failed := c.routeFailureReason(ctx, table, nodeShards); failed {
+			failedTables[table.Name] = reason
+		}
 		routeEntries[table.Name] = RouteEntry{
 			Table: TableInfo{
 				ID:            table.ID,
@@ -588,9 +1253,24 @@ func (c *ClusterMetadata) RouteTables(_ context.Context, schemaName string, tabl
 	return RouteTablesResult{
 		ClusterViewVersion: c.topologyManager.GetVersion(),
 		RouteEntries:       routeEntries,
+		FailedTables:       failedTables,
 	}, nil
 }
 
+// routeFailureReason returns the reason why the table can't be routed to the given shard nodes, and false if it can be routed.
+func (c *ClusterMetadata) routeFailureReason(ctx context.Context, table storage.Table, nodeShards []ShardNodeWithVersion) (RouteFailureReason, bool) {
+	for _, nodeShard := range nodeShards {
+		if nodeShard.ShardNode.ShardRole == storage.ShardRoleLeader {
+			return "", false
+		}
+	}
+
+	if _, assigned := c.topologyManager.GetTableShardID(ctx, table); !assigned {
+		return RouteFailureNoShardAssigned, true
+	}
+	return RouteFailureShardLeaderless, true
+}
+
 func (c *ClusterMetadata) GetNodeShards(_ context.Context) (GetNodeShardsResult, error) {
 	getNodeShardsResult := c.topologyManager.GetShardNodes()
 
@@ -649,18 +1329,173 @@ func (c *ClusterMetadata) GetProcedureExecutingBatchSize() uint32 {
 	return c.metaData.ProcedureExecutingBatchSize
 }
 
-func (c *ClusterMetadata) GetCreateTime() uint64 {
+func (c *ClusterMetadata) GetMaxTables() uint64 {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	return c.metaData.CreatedAt
+	return c.maxTables
 }
 
-func (c *ClusterMetadata) GetClusterState() storage.ClusterState {
+// GetDefaultPartitionCount returns the number of the partitions of the partition tables created without partitions, zero means no default.
+func (c *ClusterMetadata) GetDefaultPartitionCount() uint32 {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	return c.topologyManager.GetClusterState()
+	return c.defaultPartitionCount
+}
+
+// UpdateDefaultPartitionCount updates the default partition count of the cluster, zero means no default. The count must not exceed the
+// number of the shards, so that the partitions can be spread over different shards. The count is persisted with the cluster.
+// The partitions specified by the client always take precedence over the default.
+func (c *ClusterMetadata) UpdateDefaultPartitionCount(ctx context.Context, count uint32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if count > c.metaData.ShardTotal {
+		return ErrInvalidPartitionCount.WithCausef("partition count exceeds the shard total, count:%d, shardTotal:%d", count, c.metaData.ShardTotal)
+	}
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		settings.DefaultPartitionCount = count
+	})
+}
+
+// ListTableIDRanges lists the table id ranges of the schemas ordered by the start of the ranges.
+func (c *ClusterMetadata) ListTableIDRanges() []storage.TableIDRange {
+	return c.tableManager.ListTableIDRanges()
+}
+
+// CreateTableIDRange creates the table id range of the schema, and the ids of the tables created in the schema afterwards are
+// allocated in the range. The schema may not exist yet, and the range can't be changed once created.
+// The ranges must not overlap each other, and the ids of the schemas without range are allocated outside all the ranges, so the
+// range must start above the ids which may have been allocated to them.
+func (c *ClusterMetadata) CreateTableIDRange(ctx context.Context, idRange storage.TableIDRange) error {
+	minStart, err := id.LoadEndID(ctx, c.logger, c.kv, c.tableIDAllocKey)
+	if err != nil {
+		return errors.WithMessage(err, "load end id of table id allocator")
+	}
+
+	if err := c.tableManager.CreateTableIDRange(ctx, idRange, minStart); err != nil {
+		return errors.WithMessagef(err, "create table id range, schema:%s", idRange.SchemaName)
+	}
+	c.logger.Info("create table id range", zap.String("schema", idRange.SchemaName), zap.Uint64("start", idRange.Start), zap.Uint64("end", idRange.End))
+	return nil
+}
+
+// DeleteTableIDRange deletes the table id range of the schema, which is only allowed if no id in the range has been allocated,
+// otherwise the ids may be allocated again to the schemas without range.
+func (c *ClusterMetadata) DeleteTableIDRange(ctx context.Context, schemaName string) error {
+	allocatedEnd, err := id.LoadEndID(ctx, c.logger, c.kv, c.rangeTableIDAllocKey(schemaName))
+	if err != nil {
+		return errors.WithMessage(err, "load end id of range table id allocator")
+	}
+	if allocatedEnd > 0 {
+		return ErrTableIDRangeInUse.WithCausef("ids have been allocated in the range of schema:%s", schemaName)
+	}
+
+	if err := c.tableManager.DeleteTableIDRange(ctx, schemaName); err != nil {
+		return errors.WithMessagef(err, "delete table id range, schema:%s", schemaName)
+	}
+	c.logger.Info("delete table id range", zap.String("schema", schemaName))
+	return nil
+}
+
+// UpdateMaxTables updates the table quota of the cluster, zero means unlimited. The quota is persisted with the cluster.
+func (c *ClusterMetadata) UpdateMaxTables(ctx context.Context, maxTables uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		settings.MaxTables = maxTables
+	})
+}
+
+func (c *ClusterMetadata) GetMaxShardVersionDelta() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.maxShardVersionDelta
+}
+
+// UpdateMaxShardVersionDelta updates the max delta of the shard version in a single table operation, zero means unlimited.
+func (c *ClusterMetadata) UpdateMaxShardVersionDelta(maxShardVersionDelta uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.maxShardVersionDelta = maxShardVersionDelta
+}
+
+func (c *ClusterMetadata) GetReadyShardStatuses() []storage.ShardStatus {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return slices.Clone(c.readyShardStatuses)
+}
+
+// UpdateReadyShardStatuses updates the shard statuses considered as ready.
+// Accepting ShardStatusPartialOpen stops the schedulers from reopening the partially opened shards, whose failed tables stay unavailable until the shards are reopened in other ways.
+func (c *ClusterMetadata) UpdateReadyShardStatuses(statuses []storage.ShardStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.readyShardStatuses = slices.Clone(statuses)
+}
+
+// checkShardVersionDelta returns ErrShardVersionJump if the latest version of the shard is too far ahead of the current one.
+// Such a jump is never expected in a single table operation and indicates a bug in the version handling, so it is rejected rather than persisted.
+func (c *ClusterMetadata) checkShardVersionDelta(shardID storage.ShardID, latestVersion uint64) error {
+	maxDelta := c.GetMaxShardVersionDelta()
+	if maxDelta == 0 {
+		return nil
+	}
+
+	currentVersion, ok := c.topologyManager.GetShardVersion(shardID)
+	if !ok {
+		// The missing shard will be reported by the following topology update.
+		return nil
+	}
+	if latestVersion < currentVersion {
+		c.logger.Warn("shard version goes backwards", zap.String("cluster", c.Name()), zap.Uint32("shardID", uint32(shardID)), zap.Uint64("currentVersion", currentVersion), zap.Uint64("latestVersion", latestVersion))
+		return nil
+	}
+	if latestVersion-currentVersion <= maxDelta {
+		return nil
+	}
+
+	c.logger.Error("shard version jumps unexpectedly", zap.String("cluster", c.Name()), zap.Uint32("shardID", uint32(shardID)), zap.Uint64("currentVersion", currentVersion), zap.Uint64("latestVersion", latestVersion), zap.Uint64("maxDelta", maxDelta))
+	return ErrShardVersionJump.WithCausef("cluster:%s, shardID:%d, currentVersion:%d, latestVersion:%d, maxDelta:%d", c.Name(), shardID, currentVersion, latestVersion, maxDelta)
+}
+
+// CheckTableQuota returns ErrTableQuotaExceeded if creating numTables tables makes the number of tables in the snapshot, including the
+// ones created in flight, exceed the quota. It only rejects the creations early, and the quota is enforced by AcquireInflightCreates.
+func (c *ClusterMetadata) CheckTableQuota(snapshot Snapshot, numTables int) error {
+	maxTables := c.GetMaxTables()
+	if maxTables == 0 {
+		return nil
+	}
+
+	tableCount := snapshot.Topology.TableCount()
+	inflightTables := 0
+	for _, count := range snapshot.InflightCreates {
+		inflightTables += count
+	}
+	if uint64(tableCount+inflightTables+numTables) > maxTables {
+		return ErrTableQuotaExceeded.WithCausef("cluster:%s, tableCount:%d, inflightTables:%d, numTablesToCreate:%d, maxTables:%d", c.Name(), tableCount, inflightTables, numTables, maxTables)
+	}
+	return nil
+}
+
+func (c *ClusterMetadata) GetCreateTime() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.metaData.CreatedAt
+}
+
+func (c *ClusterMetadata) GetClusterState() storage.ClusterState {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.topologyManager.GetClusterState()
 }
 
 func (c *ClusterMetadata) ensureClusterStable() bool {
@@ -702,9 +1537,363 @@ func (c *ClusterMetadata) CreateShardViews(ctx context.Context, views []CreateSh
 
 func (c *ClusterMetadata) GetClusterSnapshot() Snapshot {
 	return Snapshot{
-		Topology:        c.topologyManager.GetTopology(),
-		RegisteredNodes: c.GetRegisteredNodes(),
+		Topology:             c.topologyManager.GetTopology(),
+		RegisteredNodes:      c.GetRegisteredNodes(),
+		MaintenanceShards:    c.GetMaintenanceShards(),
+		OscillatingShards:    nil,
+		ReadyShardStatuses:   c.GetReadyShardStatuses(),
+		ShardMinNodeVersions: c.GetShardMinNodeVersions(),
+		PreferredLeaders:     c.GetPreferredLeaders(),
+		ShardPermutation:     c.IsShardPermutationEnabled(),
+
+		InflightCreates:            c.GetInflightCreates(),
+		MaxInflightCreatesPerShard: c.GetMaxInflightCreatesPerShard(),
+		LoadedAt:                   c.getLoadedAt(),
+	}
+}
+
+func (c *ClusterMetadata) getLoadedAt() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.loadedAt
+}
+
+// GetInflightCreates returns the number of the table creations in flight on the shards, shardID -> count.
+func (c *ClusterMetadata) GetInflightCreates() map[storage.ShardID]int {
+	return c.inflightCreates.counts(time.Now())
+}
+
+// AcquireInflightCreates counts a table creation in flight on every given shard until it is released by the returned ticket or
+// expires, and a shard is given multiple times if multiple tables are created on it.
+// ErrTableQuotaExceeded is returned if the tables created and in flight exceed the table quota with the creations, which is checked
+// atomically with the acquisition, so that the concurrent creations never push the cluster over the quota.
+func (c *ClusterMetadata) AcquireInflightCreates(shardIDs []storage.ShardID) (InflightCreatesTicket, error) {
+	ticket, err := c.inflightCreates.acquireWithinQuota(shardIDs, time.Now(), c.GetMaxTables(), func() int {
+		topology := c.topologyManager.GetTopology()
+		return topology.TableCount()
+	})
+	if err != nil {
+		return ticket, errors.WithMessagef(err, "cluster:%s", c.Name())
+	}
+	return ticket, nil
+}
+
+// ReleaseInflightCreates finishes the table creations acquired with the ticket, and it is a no-op if they have expired.
+func (c *ClusterMetadata) ReleaseInflightCreates(ticket InflightCreatesTicket) {
+	c.inflightCreates.release(ticket)
+}
+
+func (c *ClusterMetadata) GetMaxInflightCreatesPerShard() uint32 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.maxInflightCreatesPerShard
+}
+
+// UpdateMaxInflightCreatesPerShard updates the max number of the table creations in flight on a shard, zero means unlimited.
+func (c *ClusterMetadata) UpdateMaxInflightCreatesPerShard(maxInflightCreates uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.maxInflightCreatesPerShard = maxInflightCreates
+}
+
+func (c *ClusterMetadata) GetMaxPartitionSubTables() uint32 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.maxPartitionSubTables
+}
+
+// UpdateMaxPartitionSubTables updates the max number of the sub tables of a partition table, zero means unlimited.
+func (c *ClusterMetadata) UpdateMaxPartitionSubTables(maxSubTables uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.maxPartitionSubTables = maxSubTables
+}
+
+// CheckPartitionSubTables returns ErrTooManySubTables if the partition table with numSubTables sub tables exceeds the limit.
+func (c *ClusterMetadata) CheckPartitionSubTables(numSubTables int) error {
+	maxSubTables := c.GetMaxPartitionSubTables()
+	if maxSubTables == 0 || numSubTables <= int(maxSubTables) {
+		return nil
+	}
+	return ErrTooManySubTables.WithCausef("cluster:%s, numSubTables:%d, maxSubTables:%d", c.Name(), numSubTables, maxSubTables)
+}
+
+func (c *ClusterMetadata) GetSubTableDispatchConcurrency() uint32 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.subTableDispatchConcurrency
+}
+
+// UpdateSubTableDispatchConcurrency updates the max number of the shards creating the sub tables of a partition table concurrently,
+// zero means unlimited.
+func (c *ClusterMetadata) UpdateSubTableDispatchConcurrency(concurrency uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.subTableDispatchConcurrency = concurrency
+}
+
+// GetMaintenanceShards returns the shards under maintenance, shardID -> reason.
+func (c *ClusterMetadata) GetMaintenanceShards() map[storage.ShardID]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return maps.Clone(c.maintenanceShards)
+}
+
+// SetShardsMaintenance marks the shards under maintenance, and they will be skipped by the schedulers until the flag is cleared.
+// The flags are persisted with the cluster.
+func (c *ClusterMetadata) SetShardsMaintenance(ctx context.Context, shardIDs []storage.ShardID, reason string) error {
+	shards := c.topologyManager.GetShards()
+	for _, shardID := range shardIDs {
+		if !slices.Contains(shards, shardID) {
+			return ErrShardNotFound.WithCausef("shard id:%d", shardID)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			settings.MaintenanceShards[shardID] = reason
+		}
+	})
+}
+
+// ClearShardsMaintenance clears the maintenance flag of the shards.
+func (c *ClusterMetadata) ClearShardsMaintenance(ctx context.Context, shardIDs []storage.ShardID) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			delete(settings.MaintenanceShards, shardID)
+		}
+	})
+}
+
+// GetShardMinNodeVersions returns the min node version required by the shards, shardID -> version.
+func (c *ClusterMetadata) GetShardMinNodeVersions() map[storage.ShardID]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return maps.Clone(c.shardMinNodeVersions)
+}
+
+// SetShardsMinNodeVersion requires the shards to be placed on the nodes whose version is not older than the minVersion.
+// It only takes effect on the subsequent placements, and the shards already opened on the older nodes are kept. The constraints
+// are persisted with the cluster.
+func (c *ClusterMetadata) SetShardsMinNodeVersion(ctx context.Context, shardIDs []storage.ShardID, minVersion string) error {
+	if err := ValidateNodeVersion(minVersion); err != nil {
+		return err
+	}
+
+	shards := c.topologyManager.GetShards()
+	for _, shardID := range shardIDs {
+		if !slices.Contains(shards, shardID) {
+			return ErrShardNotFound.WithCausef("shard id:%d", shardID)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			settings.ShardMinNodeVersions[shardID] = minVersion
+		}
+	})
+}
+
+// ClearShardsMinNodeVersion removes the min node version constraint of the shards.
+func (c *ClusterMetadata) ClearShardsMinNodeVersion(ctx context.Context, shardIDs []storage.ShardID) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			delete(settings.ShardMinNodeVersions, shardID)
+		}
+	})
+}
+
+// GetPreferredLeaders returns the node preferred to be the leader of the shards, shardID -> nodeName.
+func (c *ClusterMetadata) GetPreferredLeaders() map[storage.ShardID]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return maps.Clone(c.preferredLeaders)
+}
+
+// SetPreferredLeader makes the schedulers place the leader of the shards on the node when it is online, and the shards
+// fall back to other nodes when it is offline. The preferred leaders are persisted with the cluster.
+func (c *ClusterMetadata) SetPreferredLeader(ctx context.Context, shardIDs []storage.ShardID, nodeName string) error {
+	shards := c.topologyManager.GetShards()
+	for _, shardID := range shardIDs {
+		if !slices.Contains(shards, shardID) {
+			return ErrShardNotFound.WithCausef("shard id:%d", shardID)
+		}
 	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			settings.PreferredLeaders[shardID] = nodeName
+		}
+	})
+}
+
+// ClearPreferredLeader removes the preferred leader of the shards.
+func (c *ClusterMetadata) ClearPreferredLeader(ctx context.Context, shardIDs []storage.ShardID) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		for _, shardID := range shardIDs {
+			delete(settings.PreferredLeaders, shardID)
+		}
+	})
+}
+
+// GetNodePickerStrategy returns the strategy of the node picker used by the schedulers, empty means the default strategy.
+func (c *ClusterMetadata) GetNodePickerStrategy() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.nodePickerStrategy
+}
+
+// SetNodePickerStrategy sets the strategy of the node picker, and it takes effect when the schedulers are initialized next time.
+// The strategy is persisted with the cluster, and the caller should ensure it is registered.
+func (c *ClusterMetadata) SetNodePickerStrategy(ctx context.Context, strategy string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		settings.NodePickerStrategy = strategy
+	})
+}
+
+func (c *ClusterMetadata) IsShardPermutationEnabled() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.shardPermutation
+}
+
+// SetShardPermutation sets whether the shard ids are permuted before placement, and changing it reshuffles the placement
+// of the shards in the dynamic topology. The setting is persisted with the cluster.
+func (c *ClusterMetadata) SetShardPermutation(ctx context.Context, enable bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.updateSettingsLocked(ctx, func(settings *storage.ClusterSettings) {
+		settings.ShardPermutation = enable
+	})
+}
+
+func (c *ClusterMetadata) GetNodePickerHash() NodePickerHash {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.nodePickerHash
+}
+
+// UpdateNodePickerHash updates the hash function used by the node picker, and it takes effect when the schedulers are initialized next time.
+// The caller should ensure the hash function is registered.
+func (c *ClusterMetadata) UpdateNodePickerHash(nodePickerHash NodePickerHash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.nodePickerHash = nodePickerHash
+}
+
+func (c *ClusterMetadata) IsProcedureCheckpointEnabled() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.enableProcedureCheckpoint
+}
+
+// UpdateEnableProcedureCheckpoint updates whether the procedures are checkpointed, and it takes effect on the procedure factory created next time.
+func (c *ClusterMetadata) UpdateEnableProcedureCheckpoint(enable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.enableProcedureCheckpoint = enable
+}
+
+func (c *ClusterMetadata) GetCreateTableOfflineShardPolicy() OfflineShardPolicy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.createTableOfflineShardPolicy
+}
+
+// UpdateCreateTableOfflineShardPolicy updates how the create table procedure behaves if the leader node of the picked shard is offline,
+// and it takes effect on the create table procedures dispatching to the shard next time.
+func (c *ClusterMetadata) UpdateCreateTableOfflineShardPolicy(policy OfflineShardPolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.createTableOfflineShardPolicy = policy
+}
+
+// GetShardPicker returns the name of the shard picker used when creating tables, empty means the default picker.
+func (c *ClusterMetadata) GetShardPicker() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.shardPicker
+}
+
+// UpdateShardPicker updates the name of the shard picker, and it takes effect on the procedure factory created next time.
+// The caller should ensure the shard picker is registered.
+func (c *ClusterMetadata) UpdateShardPicker(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.shardPicker = name
+}
+
+func (c *ClusterMetadata) GetShardOscillationThreshold() ShardOscillationThreshold {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.shardOscillationThreshold
+}
+
+// UpdateShardOscillationThreshold updates the threshold of the shard oscillation detection, and it takes effect when the schedulers are initialized next time.
+func (c *ClusterMetadata) UpdateShardOscillationThreshold(threshold ShardOscillationThreshold) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.shardOscillationThreshold = threshold
+}
+
+func (c *ClusterMetadata) GetPreferredLeaderStabilizationDelay() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.preferredLeaderStabilizationDelay
+}
+
+// UpdatePreferredLeaderStabilizationDelay updates how long the node of a preferred leader must stay online before the leadership
+// is moved back to it, and it takes effect when the scheduler manager is created next time.
+func (c *ClusterMetadata) UpdatePreferredLeaderStabilizationDelay(delay time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.preferredLeaderStabilizationDelay = delay
 }
 
 func (c *ClusterMetadata) GetStorageMetadata() storage.Cluster {
@@ -791,6 +1980,29 @@ L1:
 	return true
 }
 
+// AdvanceShardVersion sets the version of the shard to the given one, and returns the previous version.
+// It is an escape hatch to resynchronize with the data nodes reporting an ahead version, so the version is never decreased.
+func (c *ClusterMetadata) AdvanceShardVersion(ctx context.Context, shardID storage.ShardID, version uint64) (uint64, error) {
+	prevVersion, ok := c.topologyManager.GetShardVersion(shardID)
+	if !ok {
+		return 0, ErrShardNotFound.WithCausef("shard id:%d", shardID)
+	}
+	if version < prevVersion {
+		return prevVersion, ErrShardVersionDecrease.WithCausef("shard id:%d, current version:%d, target version:%d", shardID, prevVersion, version)
+	}
+	if version == prevVersion {
+		return prevVersion, nil
+	}
+
+	c.logger.Warn("advance shard version manually", zap.Uint32("shardID", uint32(shardID)), zap.Uint64("prevVersion", prevVersion), zap.Uint64("newVersion", version))
+	if err := c.topologyManager.UpdateShardVersionWithExpect(ctx, shardID, version, prevVersion); err != nil {
+		return prevVersion, errors.WithMessage(err, "update shard version with expect")
+	}
+	c.logger.Warn("shard version is advanced manually", zap.Uint32("shardID", uint32(shardID)), zap.Uint64("prevVersion", prevVersion), zap.Uint64("newVersion", version))
+
+	return prevVersion, nil
+}
+
 func (c *ClusterMetadata) maybeCorrectShardVersion(ctx context.Context, node RegisteredNode) {
 	topology := c.topologyManager.GetTopology()
 	for _, shardInfo := range node.ShardInfos {
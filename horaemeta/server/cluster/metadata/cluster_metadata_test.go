@@ -22,12 +22,15 @@ package metadata_test
 import (
 	"context"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,8 +43,67 @@ func TestClusterMetadata(t *testing.T) {
 	testUpdateClusterView(ctx, re, metadata)
 	testRegisterNode(ctx, re, metadata)
 	testTableOperation(ctx, re, metadata)
+	testPartitionTableLayout(ctx, re, metadata)
+	testSchemaShardSpread(ctx, re, metadata)
+	testOrphanTables(ctx, re, metadata)
+	testShardVersionDelta(ctx, re, metadata)
+	testRemoveTableTopology(ctx, re, metadata)
+	testUnderReplicatedShards(re, metadata)
+	testGhostShards(re, metadata)
+	testUnreadyAndUnregisteredShards(re, metadata)
+	testShardsWithoutEligibleNode(re, metadata)
+	testAliveShardLeader(re, metadata)
+	testOnlineShardLeader(re, metadata)
+	testAdvanceShardVersion(ctx, re, metadata)
+	testTableAssignment(ctx, re, metadata)
 	testShardOperation(ctx, re, metadata)
 	testMetadataOperation(ctx, re, metadata)
+	testExpireNode(ctx, re, metadata)
+	testCleanupExpiredNodes(ctx, re, metadata)
+	testNodeStatsHistory(ctx, re, metadata)
+}
+
+func TestRouteTablesFailureReasons(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	m := test.InitStableCluster(ctx, t).GetMetadata()
+	testSchema := "testRouteFailureSchema"
+	_, _, err := m.GetOrCreateSchema(ctx, testSchema)
+	re.NoError(err)
+
+	// The table created without assignment has no shard.
+	unassignedTableName := "testUnassignedTable"
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     unassignedTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	// The shard of the table has no leader after its shard node is dropped.
+	leaderlessTableName := "testLeaderlessTable"
+	_, err = m.CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       0,
+		LatestVersion: 0,
+		SchemaName:    testSchema,
+		TableName:     leaderlessTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	shardNodes, err := m.GetShardNodesByShardID(0)
+	re.NoError(err)
+	re.NoError(m.DropShardNodes(ctx, shardNodes))
+
+	routeResult, err := m.RouteTables(ctx, testSchema, []string{unassignedTableName, leaderlessTableName, "notExistTable"})
+	re.NoError(err)
+	re.Equal(map[string]metadata.RouteFailureReason{
+		unassignedTableName: metadata.RouteFailureNoShardAssigned,
+		leaderlessTableName: metadata.RouteFailureShardLeaderless,
+		"notExistTable":     metadata.RouteFailureTableNotFound,
+	}, routeResult.FailedTables)
+	// The tables existing are still returned in the route entries.
+	re.Len(routeResult.RouteEntries, 2)
 }
 
 func testUpdateClusterView(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
@@ -117,6 +179,11 @@ func testTableOperation(ctx context.Context, re *require.Assertions, m *metadata
 	schema, _, err := m.GetOrCreateSchema(ctx, testSchema)
 	re.NoError(err)
 	re.Equal(testSchema, schema.Name)
+	tableCount, err := m.GetTableCountOfSchema(testSchema)
+	re.NoError(err)
+	re.Equal(0, tableCount)
+	_, err = m.GetTableCountOfSchema("unknownSchema")
+	re.True(coderr.Is(err, metadata.ErrSchemaNotFound.Code()))
 
 	// Test create table metadata.
 	createMetadataResult, err := m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
@@ -126,6 +193,9 @@ func testTableOperation(ctx context.Context, re *require.Assertions, m *metadata
 	})
 	re.NoError(err)
 	re.Equal(createMetadataResult.Table.Name, testTableName)
+	tableCount, err = m.GetTableCountOfSchema(testSchema)
+	re.NoError(err)
+	re.Equal(1, tableCount)
 
 	// Table metadata is exists.
 	t, exists, err := m.GetTable(testSchema, testTableName)
@@ -142,6 +212,9 @@ func testTableOperation(ctx context.Context, re *require.Assertions, m *metadata
 	dropMetadataResult, err := m.DropTableMetadata(ctx, testSchema, testTableName)
 	re.NoError(err)
 	re.Equal(testTableName, dropMetadataResult.Table.Name)
+	tableCount, err = m.GetTableCountOfSchema(testSchema)
+	re.NoError(err)
+	re.Equal(0, tableCount)
 
 	// Table metadata is not exists.
 	t, exists, err = m.GetTable(testSchema, testTableName)
@@ -164,6 +237,13 @@ func testTableOperation(ctx context.Context, re *require.Assertions, m *metadata
 	routeResult, err := m.RouteTables(ctx, testSchema, []string{testTableName})
 	re.NoError(err)
 	re.Equal(1, len(routeResult.RouteEntries))
+	re.Empty(routeResult.FailedTables)
+
+	// The table not found is returned with the reason together with the routed ones.
+	routeResult, err = m.RouteTables(ctx, testSchema, []string{testTableName, "notExistTable"})
+	re.NoError(err)
+	re.Equal(1, len(routeResult.RouteEntries))
+	re.Equal(map[string]metadata.RouteFailureReason{"notExistTable": metadata.RouteFailureTableNotFound}, routeResult.FailedTables)
 
 	// Migrate this table to another shard.
 	err = m.MigrateTable(ctx, metadata.MigrateTableRequest{
@@ -190,6 +270,534 @@ func testTableOperation(ctx context.Context, re *require.Assertions, m *metadata
 	re.NoError(err)
 }
 
+func testPartitionTableLayout(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	testSchema := "testSchemaName"
+	partitionTableName := "testPartitionTable"
+	normalTableName := "testNormalTable"
+
+	_, _, err := m.GetOrCreateSchema(ctx, testSchema)
+	re.NoError(err)
+
+	partitionInfo := &clusterpb.PartitionInfo{Info: &clusterpb.PartitionInfo_Key{Key: &clusterpb.KeyPartitionInfo{
+		Version:      0,
+		Definitions:  []*clusterpb.PartitionDefinition{{Name: "p0", OriginName: nil}, {Name: "p1", OriginName: nil}},
+		PartitionKey: []string{"id"},
+		Linear:       false,
+	}}}
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     partitionTableName,
+		PartitionInfo: storage.PartitionInfo{Info: partitionInfo},
+	})
+	re.NoError(err)
+
+	// Only the first sub table is created.
+	subTableName := metadata.FormatSubTableName(partitionTableName, "p0")
+	_, err = m.CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       1,
+		LatestVersion: 0,
+		SchemaName:    testSchema,
+		TableName:     subTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	layout, err := m.GetPartitionTableLayout(ctx, testSchema, partitionTableName)
+	re.NoError(err)
+	re.Equal(partitionTableName, layout.Table.Name)
+	re.Equal(2, len(layout.SubTables))
+	re.Equal(subTableName, layout.SubTables[0].TableName)
+	re.True(layout.SubTables[0].Exists)
+	re.Equal(storage.ShardID(1), layout.SubTables[0].ShardID)
+	shardNodes, err := m.GetShardNodesByShardID(1)
+	re.NoError(err)
+	re.Equal(len(shardNodes), len(layout.SubTables[0].NodeNames))
+	re.False(layout.SubTables[1].Exists)
+
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     normalTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	_, err = m.GetPartitionTableLayout(ctx, testSchema, normalTableName)
+	re.True(coderr.Is(err, metadata.ErrTableNotPartitioned.Code()))
+
+	_, err = m.GetPartitionTableLayout(ctx, testSchema, "notExistTable")
+	re.True(coderr.Is(err, metadata.ErrTableNotFound.Code()))
+}
+
+func testShardVersionDelta(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	testSchema := "testSchemaName"
+	testTableName := "testVersionDeltaTable"
+	shardID := storage.ShardID(0)
+
+	createMetadataResult, err := m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     testTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	currentVersion := m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version
+	maxDelta := m.GetMaxShardVersionDelta()
+	re.Equal(uint64(metadata.DefaultMaxShardVersionDelta), maxDelta)
+
+	// The jump exceeding the max delta should be rejected without touching the topology.
+	err = m.AddTableTopology(ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: currentVersion + maxDelta + 1,
+	}, createMetadataResult.Table)
+	re.Error(err)
+	re.True(coderr.Is(err, metadata.ErrShardVersionJump.Code()))
+	re.Equal(currentVersion, m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version)
+
+	err = m.AddTableTopology(ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: currentVersion + 1,
+	}, createMetadataResult.Table)
+	re.NoError(err)
+
+	// The check is disabled when the max delta is zero.
+	m.UpdateMaxShardVersionDelta(0)
+	err = m.DropTable(ctx, metadata.DropTableRequest{
+		SchemaName:    testSchema,
+		TableName:     testTableName,
+		ShardID:       shardID,
+		LatestVersion: currentVersion + maxDelta + 2,
+	})
+	re.NoError(err)
+	m.UpdateMaxShardVersionDelta(metadata.DefaultMaxShardVersionDelta)
+}
+
+func testRemoveTableTopology(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	testSchema := "testSchemaName"
+	testTableName := "testRemoveTopologyTable"
+	shardID := storage.ShardID(0)
+
+	createMetadataResult, err := m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     testTableName,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	table := createMetadataResult.Table
+
+	currentVersion := m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version
+	err = m.AddTableTopology(ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: currentVersion + 1,
+	}, table)
+	re.NoError(err)
+	tableShardID, exists := m.GetTableShard(ctx, table)
+	re.True(exists)
+	re.Equal(shardID, tableShardID)
+
+	// The table metadata should be kept after the table is removed from the shard view.
+	err = m.RemoveTableTopology(ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: currentVersion + 2,
+	}, table)
+	re.NoError(err)
+	_, exists = m.GetTableShard(ctx, table)
+	re.False(exists)
+	re.Equal(currentVersion+2, m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version)
+	_, exists, err = m.GetTable(testSchema, testTableName)
+	re.NoError(err)
+	re.True(exists)
+}
+
+func testSchemaShardSpread(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	testSchema := "testShardSpreadSchema"
+	_, err := m.GetShardSpreadOfSchema(ctx, testSchema)
+	re.True(coderr.Is(err, metadata.ErrSchemaNotFound.Code()))
+
+	_, _, err = m.GetOrCreateSchema(ctx, testSchema)
+	re.NoError(err)
+	spread, err := m.GetShardSpreadOfSchema(ctx, testSchema)
+	re.NoError(err)
+	re.Equal(0, spread.TableCount)
+	re.Empty(spread.ShardTableCounts)
+
+	// Two tables on shard 0, one table on shard 1, and a table not on any shard.
+	for i, shardID := range []storage.ShardID{0, 0, 1} {
+		_, err = m.CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       shardID,
+			LatestVersion: 0,
+			SchemaName:    testSchema,
+			TableName:     fmt.Sprintf("spreadTable%d", i),
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     "unassignedTable",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     "partitionTable",
+		PartitionInfo: storage.PartitionInfo{Info: &clusterpb.PartitionInfo{Info: nil}},
+	})
+	re.NoError(err)
+
+	spread, err = m.GetShardSpreadOfSchema(ctx, testSchema)
+	re.NoError(err)
+	re.Equal(5, spread.TableCount)
+	re.Equal(map[storage.ShardID]int{0: 2, 1: 1}, spread.ShardTableCounts)
+	re.Equal(1, spread.PartitionTableCount)
+	re.Equal(1, spread.UnassignedTableCount)
+}
+
+func testOrphanTables(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	testSchema := "testOrphanSchema"
+	_, _, err := m.GetOrCreateSchema(ctx, testSchema)
+	re.NoError(err)
+	_, err = m.CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       0,
+		LatestVersion: 0,
+		SchemaName:    testSchema,
+		TableName:     "assignedTable",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	orphan, err := m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     "orphanTable",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	_, err = m.CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    testSchema,
+		TableName:     "partitionTable",
+		PartitionInfo: storage.PartitionInfo{Info: &clusterpb.PartitionInfo{Info: nil}},
+	})
+	re.NoError(err)
+
+	findOrphansOfSchema := func() []storage.Table {
+		tables := make([]storage.Table, 0)
+		for _, orphan := range m.FindOrphanTables() {
+			if orphan.SchemaName == testSchema {
+				tables = append(tables, orphan.Table)
+			}
+		}
+		return tables
+	}
+	re.Equal([]storage.Table{orphan.Table}, findOrphansOfSchema())
+
+	// The min age can't be less than the floor.
+	_, err = m.DropOrphanTables(ctx, 0, time.Now().Add(time.Hour), false)
+	re.True(coderr.Is(err, metadata.ErrInvalidOrphanTableAge.Code()))
+	re.Equal([]storage.Table{orphan.Table}, findOrphansOfSchema())
+
+	// The young orphan is kept, and nothing is dropped in the dry run.
+	dropped, err := m.DropOrphanTables(ctx, metadata.MinOrphanTableAge, time.Now(), false)
+	re.NoError(err)
+	re.Empty(dropped)
+	dropped, err = m.DropOrphanTables(ctx, metadata.MinOrphanTableAge, time.Now().Add(time.Hour), true)
+	re.NoError(err)
+	re.Contains(dropped, metadata.OrphanTable{SchemaName: testSchema, Table: orphan.Table})
+	re.Equal([]storage.Table{orphan.Table}, findOrphansOfSchema())
+
+	dropped, err = m.DropOrphanTables(ctx, metadata.MinOrphanTableAge, time.Now().Add(time.Hour), false)
+	re.NoError(err)
+	re.Contains(dropped, metadata.OrphanTable{SchemaName: testSchema, Table: orphan.Table})
+	re.Empty(m.FindOrphanTables())
+	_, exists, err := m.GetTable(testSchema, "orphanTable")
+	re.NoError(err)
+	re.False(exists)
+	_, exists, err = m.GetTable(testSchema, "assignedTable")
+	re.NoError(err)
+	re.True(exists)
+}
+
+func testUnderReplicatedShards(re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	snapshot := m.GetClusterSnapshot()
+	numShards := len(snapshot.Topology.ShardViewsMapping)
+
+	// Every shard has only one leader replica.
+	re.Empty(snapshot.FindUnderReplicatedShards(1, now))
+	underReplicatedShards := snapshot.FindUnderReplicatedShards(2, now)
+	re.Len(underReplicatedShards, numShards)
+	for i, shard := range underReplicatedShards {
+		re.Equal(storage.ShardID(i), shard.ShardID)
+		re.Equal(1, shard.HealthyReplicas)
+		re.Equal(1, shard.MissingReplicas)
+	}
+
+	// The replicas on the expired node are not healthy.
+	expiredNode := snapshot.RegisteredNodes[0]
+	expectShards := make(map[storage.ShardID]struct{})
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.NodeName == expiredNode.Node.Name {
+			expectShards[shardNode.ID] = struct{}{}
+		}
+	}
+	underReplicatedShards = snapshot.FindUnderReplicatedShards(1, now.Add(time.Hour))
+	re.Len(underReplicatedShards, numShards)
+	snapshot.RegisteredNodes = snapshot.RegisteredNodes[1:]
+	underReplicatedShards = snapshot.FindUnderReplicatedShards(1, now)
+	re.Len(underReplicatedShards, len(expectShards))
+	for _, shard := range underReplicatedShards {
+		re.Contains(expectShards, shard.ShardID)
+		re.Equal(0, shard.HealthyReplicas)
+		re.Equal(1, shard.MissingReplicas)
+	}
+}
+
+func testGhostShards(re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	snapshot := m.GetClusterSnapshot()
+	re.GreaterOrEqual(len(snapshot.RegisteredNodes), 2)
+
+	// Every node reports exactly the shards assigned to it.
+	assignedShards := make(map[string][]metadata.ShardInfo)
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		assignedShards[shardNode.NodeName] = append(assignedShards[shardNode.NodeName], metadata.ShardInfo{
+			ID:      shardNode.ID,
+			Role:    shardNode.ShardRole,
+			Version: 0,
+			Status:  storage.ShardStatusReady,
+		})
+	}
+	registeredNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes))
+	for _, node := range snapshot.RegisteredNodes {
+		registeredNodes = append(registeredNodes, metadata.RegisteredNode{
+			Node:       node.Node,
+			ShardInfos: assignedShards[node.Node.Name],
+		})
+	}
+	snapshot.RegisteredNodes = registeredNodes
+	re.Empty(snapshot.FindGhostShards(now))
+
+	// A node reports a shard of the other nodes and an unknown shard. The node without shards is preferred, otherwise the
+	// other nodes may have no shard at all, because the order of the registered nodes is random.
+	ghostIdx := 0
+	for i, node := range registeredNodes {
+		if len(node.ShardInfos) == 0 {
+			ghostIdx = i
+			break
+		}
+	}
+	ghostNode := registeredNodes[ghostIdx].Node.Name
+	var otherShards []metadata.ShardInfo
+	for i, node := range registeredNodes {
+		if i != ghostIdx {
+			otherShards = append(otherShards, node.ShardInfos...)
+		}
+	}
+	re.NotEmpty(otherShards)
+	otherShard := otherShards[0]
+	unknownShard := metadata.ShardInfo{ID: 9999, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusReady}
+	registeredNodes[ghostIdx].ShardInfos = append([]metadata.ShardInfo{unknownShard, otherShard}, registeredNodes[ghostIdx].ShardInfos...)
+	re.Equal([]metadata.GhostShard{
+		{ShardID: otherShard.ID, NodeName: ghostNode, Unknown: false},
+		{ShardID: unknownShard.ID, NodeName: ghostNode, Unknown: true},
+	}, snapshot.FindGhostShards(now))
+
+	// The reports of the expired nodes are ignored.
+	re.Empty(snapshot.FindGhostShards(now.Add(time.Hour)))
+}
+
+func testUnreadyAndUnregisteredShards(re *require.Assertions, m *metadata.ClusterMetadata) {
+	snapshot := m.GetClusterSnapshot()
+	re.GreaterOrEqual(len(snapshot.RegisteredNodes), 2)
+	re.GreaterOrEqual(len(snapshot.Topology.ShardViewsMapping), 3)
+
+	// No shard is reported by the nodes.
+	registeredNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes))
+	for _, node := range snapshot.RegisteredNodes {
+		registeredNodes = append(registeredNodes, metadata.RegisteredNode{Node: node.Node, ShardInfos: []metadata.ShardInfo{}})
+	}
+	sort.Slice(registeredNodes, func(i, j int) bool { return registeredNodes[i].Node.Name < registeredNodes[j].Node.Name })
+	snapshot.RegisteredNodes = registeredNodes
+	re.Empty(snapshot.FindUnreadyShards())
+	re.Len(snapshot.FindUnregisteredShards(), len(snapshot.Topology.ShardViewsMapping))
+
+	// The shards reported by the nodes are registered whatever their statuses are.
+	registeredNodes[0].ShardInfos = []metadata.ShardInfo{
+		{ID: 0, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusReady},
+		{ID: 1, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusPartialOpen},
+	}
+	registeredNodes[1].ShardInfos = []metadata.ShardInfo{
+		{ID: 2, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusUnknown},
+	}
+	re.Equal([]metadata.UnreadyShard{
+		{ShardID: 1, NodeName: registeredNodes[0].Node.Name, Status: storage.ShardStatusPartialOpen},
+		{ShardID: 2, NodeName: registeredNodes[1].Node.Name, Status: storage.ShardStatusUnknown},
+	}, snapshot.FindUnreadyShards())
+	unregisteredShards := snapshot.FindUnregisteredShards()
+	re.Len(unregisteredShards, len(snapshot.Topology.ShardViewsMapping)-3)
+	for _, shardID := range unregisteredShards {
+		re.Greater(shardID, storage.ShardID(2))
+	}
+}
+
+func testShardsWithoutEligibleNode(re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	snapshot := m.GetClusterSnapshot()
+	re.NotEmpty(snapshot.Topology.ClusterView.ShardNodes)
+	snapshot.Topology.ClusterView.State = storage.ClusterStateStable
+
+	// All the nodes are online.
+	re.Empty(snapshot.FindShardsWithoutEligibleNode(now))
+
+	// All the nodes are expired, so no shard can be scheduled except the ones under maintenance.
+	maintenanceShard := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	snapshot.MaintenanceShards = map[storage.ShardID]string{maintenanceShard: "test"}
+	shardIDs := snapshot.FindShardsWithoutEligibleNode(now.Add(time.Hour))
+	re.Len(shardIDs, len(snapshot.Topology.ShardViewsMapping)-1)
+	re.NotContains(shardIDs, maintenanceShard)
+	re.True(sort.SliceIsSorted(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] }))
+
+	// The unassigned shards are eligible as long as any node is alive.
+	snapshot.Topology.ClusterView.ShardNodes = []storage.ShardNode{}
+	re.Empty(snapshot.FindShardsWithoutEligibleNode(now))
+
+	// The empty cluster is never scheduled.
+	snapshot.Topology.ClusterView.State = storage.ClusterStateEmpty
+	re.Empty(snapshot.FindShardsWithoutEligibleNode(now.Add(time.Hour)))
+}
+
+func testAliveShardLeader(re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	snapshot := m.GetClusterSnapshot()
+	re.NotEmpty(snapshot.Topology.ClusterView.ShardNodes)
+	leader := snapshot.Topology.ClusterView.ShardNodes[0]
+
+	shardNode, node, err := snapshot.GetAliveShardLeader(leader.ID, now)
+	re.NoError(err)
+	re.Equal(leader, shardNode)
+	re.Equal(leader.NodeName, node.Node.Name)
+	re.True(snapshot.NodesReported(now))
+
+	_, _, err = snapshot.GetAliveShardLeader(9999, now)
+	re.True(coderr.Is(err, metadata.ErrShardNotFound.Code()))
+
+	// The shard isn't online if its leader is expired.
+	_, _, err = snapshot.GetAliveShardLeader(leader.ID, now.Add(time.Hour))
+	re.True(coderr.Is(err, metadata.ErrShardNotOnline.Code()))
+
+	// The leader not registered yet is regarded as alive until it misses the heartbeats since the metadata is loaded.
+	snapshot.RegisteredNodes = nil
+	snapshot.LoadedAt = now
+	shardNode, node, err = snapshot.GetAliveShardLeader(leader.ID, now)
+	re.NoError(err)
+	re.Equal(leader, shardNode)
+	re.Empty(node.Node.Name)
+	_, _, err = snapshot.GetAliveShardLeader(leader.ID, now.Add(time.Hour))
+	re.True(coderr.Is(err, metadata.ErrShardNotOnline.Code()))
+
+	// The nodes are not fully reported until they are all registered or have missed the heartbeats.
+	re.False(snapshot.NodesReported(now))
+	re.True(snapshot.NodesReported(now.Add(time.Hour)))
+}
+
+func testOnlineShardLeader(re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	snapshot := m.GetClusterSnapshot()
+	re.NotEmpty(snapshot.Topology.ClusterView.ShardNodes)
+	leader := snapshot.Topology.ClusterView.ShardNodes[0]
+
+	// Every node reports the shards assigned to it with the given status.
+	reportShards := func(status storage.ShardStatus) {
+		registeredNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes))
+		for _, node := range snapshot.RegisteredNodes {
+			var shardInfos []metadata.ShardInfo
+			for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+				if shardNode.NodeName == node.Node.Name {
+					shardInfos = append(shardInfos, metadata.ShardInfo{ID: shardNode.ID, Role: shardNode.ShardRole, Version: 0, Status: status})
+				}
+			}
+			registeredNodes = append(registeredNodes, metadata.RegisteredNode{Node: node.Node, ShardInfos: shardInfos})
+		}
+		snapshot.RegisteredNodes = registeredNodes
+	}
+
+	reportShards(storage.ShardStatusReady)
+	shardNode, err := snapshot.GetOnlineShardLeader(leader.ID, now)
+	re.NoError(err)
+	re.Equal(leader, shardNode)
+
+	_, err = snapshot.GetOnlineShardLeader(9999, now)
+	re.True(coderr.Is(err, metadata.ErrShardNotFound.Code()))
+
+	// The shard isn't online if its leader is expired or hasn't opened it.
+	_, err = snapshot.GetOnlineShardLeader(leader.ID, now.Add(time.Hour))
+	re.True(coderr.Is(err, metadata.ErrShardNotOnline.Code()))
+	reportShards(storage.ShardStatusPartialOpen)
+	_, err = snapshot.GetOnlineShardLeader(leader.ID, now)
+	re.True(coderr.Is(err, metadata.ErrShardNotOnline.Code()))
+}
+
+func testAdvanceShardVersion(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	shardID := storage.ShardID(0)
+	shardView, ok := m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID]
+	re.True(ok)
+	prevVersion := shardView.Version
+
+	// Advance the version.
+	ret, err := m.AdvanceShardVersion(ctx, shardID, prevVersion+10)
+	re.NoError(err)
+	re.Equal(prevVersion, ret)
+	re.Equal(prevVersion+10, m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version)
+
+	// Setting the same version is a no-op.
+	ret, err = m.AdvanceShardVersion(ctx, shardID, prevVersion+10)
+	re.NoError(err)
+	re.Equal(prevVersion+10, ret)
+
+	// The version can't be decreased.
+	_, err = m.AdvanceShardVersion(ctx, shardID, prevVersion)
+	re.True(coderr.Is(err, metadata.ErrShardVersionDecrease.Code()))
+	re.Equal(prevVersion+10, m.GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version)
+
+	// The shard must exist.
+	_, err = m.AdvanceShardVersion(ctx, storage.ShardID(m.GetTotalShardNum()+100), 1)
+	re.True(coderr.Is(err, metadata.ErrShardNotFound.Code()))
+}
+
+func testTableAssignment(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	schemaName := "assignmentSchema"
+	_, _, err := m.GetOrCreateSchema(ctx, schemaName)
+	re.NoError(err)
+	re.NoError(m.AssignTableToShard(ctx, schemaName, "table1", storage.ShardID(1)))
+	re.NoError(m.AssignTableToShard(ctx, schemaName, "table0", storage.ShardID(m.GetTotalShardNum()+100)))
+
+	var assignments []metadata.TableAssignment
+	for _, assignment := range m.ListTableAssignments() {
+		if assignment.SchemaName == schemaName {
+			assignments = append(assignments, assignment)
+		}
+	}
+	re.Equal([]metadata.TableAssignment{
+		{SchemaName: schemaName, TableName: "table0", ShardID: storage.ShardID(m.GetTotalShardNum() + 100), TableExists: false, ShardExists: false},
+		{SchemaName: schemaName, TableName: "table1", ShardID: storage.ShardID(1), TableExists: false, ShardExists: true},
+	}, assignments)
+
+	// The assignment is kept if the shard mismatches.
+	err = m.ClearTableAssignment(ctx, schemaName, "table1", storage.ShardID(2))
+	re.True(coderr.Is(err, metadata.ErrTableAssignmentMismatch.Code()))
+	_, exists, err := m.GetTableAssignedShard(ctx, schemaName, "table1")
+	re.NoError(err)
+	re.True(exists)
+
+	re.NoError(m.ClearTableAssignment(ctx, schemaName, "table1", storage.ShardID(1)))
+	_, exists, err = m.GetTableAssignedShard(ctx, schemaName, "table1")
+	re.NoError(err)
+	re.False(exists)
+
+	err = m.ClearTableAssignment(ctx, schemaName, "table1", storage.ShardID(1))
+	re.True(coderr.Is(err, metadata.ErrTableAssignmentNotFound.Code()))
+	err = m.ClearTableAssignment(ctx, "unknownSchema", "table1", storage.ShardID(1))
+	re.True(coderr.Is(err, metadata.ErrSchemaNotFound.Code()))
+
+	re.NoError(m.ClearTableAssignment(ctx, schemaName, "table0", storage.ShardID(m.GetTotalShardNum()+100)))
+}
+
 func testShardOperation(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
 	newID, err := m.AllocShardID(ctx)
 	re.NoError(err)
@@ -213,6 +821,12 @@ func testShardOperation(ctx context.Context, re *require.Assertions, m *metadata
 	_, err = m.GetShardNodeByTableIDs([]storage.TableID{})
 	re.NoError(err)
 
+	leader, ok := m.GetShardLeader(shardNodeResult.NodeShards[0].ShardInfo.ID)
+	re.True(ok)
+	re.Equal(shardNodes[0].NodeName, leader.NodeName)
+	_, ok = m.GetShardLeader(storage.ShardID(m.GetTotalShardNum()))
+	re.False(ok)
+
 	err = m.DropShardNodes(ctx, []storage.ShardNode{{
 		ID:        shardNodeResult.NodeShards[0].ShardNode.ID,
 		ShardRole: shardNodeResult.NodeShards[0].ShardNode.ShardRole,
@@ -233,3 +847,109 @@ func testMetadataOperation(ctx context.Context, re *require.Assertions, m *metad
 	err = m.LoadMetadata(ctx)
 	re.Error(err)
 }
+
+func testExpireNode(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	registeredNodes := m.GetRegisteredNodes()
+	re.NotEmpty(registeredNodes)
+	nodeName := registeredNodes[0].Node.Name
+
+	err := m.ExpireNode(ctx, nodeName)
+	re.NoError(err)
+	expiredNode, ok := m.GetRegisteredNodeByName(nodeName)
+	re.True(ok)
+	re.True(expiredNode.IsExpired(now))
+	re.Greater(expiredNode.HeartbeatLag(now), time.Duration(0))
+
+	err = m.ExpireNode(ctx, "unknownNode")
+	re.Error(err)
+	re.True(coderr.Is(err, metadata.ErrNodeNotFound.Code()))
+}
+
+func testCleanupExpiredNodes(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	now := time.Now()
+	nodeName := "testCleanupExpiredNode"
+	err := m.RegisterNode(ctx, metadata.RegisteredNode{
+		Node: storage.Node{
+			Name:          nodeName,
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: uint64(now.Add(-time.Hour).UnixMilli()),
+			State:         0,
+		},
+		ShardInfos: nil,
+	})
+	re.NoError(err)
+
+	// The non-positive retention is rejected rather than removing all the expired nodes.
+	for _, retention := range []time.Duration{0, -time.Minute} {
+		_, err := m.CleanupExpiredNodes(ctx, retention, now)
+		re.ErrorIs(err, metadata.ErrInvalidNodeRetention)
+	}
+	_, ok := m.GetRegisteredNodeByName(nodeName)
+	re.True(ok)
+
+	// The node is kept if it is expired shorter than the retention.
+	removedNodes, err := m.CleanupExpiredNodes(ctx, 2*time.Hour, now)
+	re.NoError(err)
+	re.NotContains(removedNodes, nodeName)
+	_, ok = m.GetRegisteredNodeByName(nodeName)
+	re.True(ok)
+
+	removedNodes, err = m.CleanupExpiredNodes(ctx, time.Minute, now)
+	re.NoError(err)
+	re.Contains(removedNodes, nodeName)
+	_, ok = m.GetRegisteredNodeByName(nodeName)
+	re.False(ok)
+
+	// The nodes holding shards are never removed.
+	for _, shardNode := range m.GetClusterSnapshot().Topology.ClusterView.ShardNodes {
+		re.NotContains(removedNodes, shardNode.NodeName)
+	}
+}
+
+func testNodeStatsHistory(ctx context.Context, re *require.Assertions, m *metadata.ClusterMetadata) {
+	m.UpdateNodeStatsHistoryOptions(metadata.NodeStatsHistoryOptions{Capacity: 2, Interval: time.Second})
+
+	now := time.Now()
+	nodeName := "testNodeStatsHistory"
+	shardInfos := []metadata.ShardInfo{
+		{ID: 0, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusReady},
+		{ID: 1, Role: storage.ShardRoleFollower, Version: 0, Status: storage.ShardStatusPartialOpen},
+	}
+	// The node is expired, so that it can be cleaned up at last.
+	base := now.Add(-time.Hour)
+	for _, offset := range []time.Duration{0, 500 * time.Millisecond, time.Second, 2 * time.Second} {
+		err := m.RegisterNode(ctx, metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          nodeName,
+				NodeStats:     storage.NewEmptyNodeStats(),
+				LastTouchTime: uint64(base.Add(offset).UnixMilli()),
+				State:         0,
+			},
+			ShardInfos: shardInfos,
+		})
+		re.NoError(err)
+	}
+
+	// The heartbeat within the interval is not sampled, and the oldest sample is overwritten.
+	samples := m.GetNodeStatsHistory(nodeName)
+	re.Len(samples, 2)
+	re.Equal(base.Add(time.Second).UnixMilli(), samples[0].Timestamp)
+	re.Equal(base.Add(2*time.Second).UnixMilli(), samples[1].Timestamp)
+	shardViews := m.GetClusterSnapshot().Topology.ShardViewsMapping
+	re.Equal(metadata.NodeStatsSample{
+		Timestamp:         base.Add(2 * time.Second).UnixMilli(),
+		ShardCount:        2,
+		LeaderShardCount:  1,
+		UnreadyShardCount: 1,
+		TableCount:        len(shardViews[0].TableIDs) + len(shardViews[1].TableIDs),
+		ShuttingDown:      false,
+	}, samples[1])
+	re.Equal(samples, m.ListNodeStatsHistory()[nodeName])
+	re.Empty(m.GetNodeStatsHistory("unknownNode"))
+
+	// The history of the node is dropped once it is cleaned up.
+	_, err := m.CleanupExpiredNodes(ctx, time.Minute, now)
+	re.NoError(err)
+	re.Empty(m.GetNodeStatsHistory(nodeName))
+}
@@ -36,4 +36,30 @@ var (
 	ErrTableAlreadyExists   = coderr.NewCodeError(coderr.Internal, "table already exists")
 	ErrOpenTable            = coderr.NewCodeError(coderr.Internal, "open table")
 	ErrParseTopologyType    = coderr.NewCodeError(coderr.Internal, "parse topology type")
+	ErrParseShardStatus     = coderr.NewCodeError(coderr.InvalidParams, "parse shard status")
+	ErrParseNodeVersion     = coderr.NewCodeError(coderr.InvalidParams, "parse node version")
+	ErrTableNotPartitioned  = coderr.NewCodeError(coderr.BadRequest, "table is not partitioned")
+	ErrTableQuotaExceeded   = coderr.NewCodeError(coderr.TableQuotaExceeded, "table quota exceeded")
+	ErrShardVersionJump     = coderr.NewCodeError(coderr.Internal, "shard version jumps unexpectedly")
+	ErrTableNotOnShard      = coderr.NewCodeError(coderr.BadRequest, "table is not on the shard")
+	ErrShardNotGhost        = coderr.NewCodeError(coderr.BadRequest, "shard is not a ghost shard of the node")
+	ErrShardNotOnline       = coderr.NewCodeError(coderr.BadRequest, "shard is not online")
+	ErrShardVersionDecrease = coderr.NewCodeError(coderr.BadRequest, "shard version must not decrease")
+	ErrSchemaNotProvisioned = coderr.NewCodeError(coderr.SchemaNotProvisioned, "schema is not provisioned and auto creation is disabled")
+
+	ErrUnsafeTopologyTypeChange = coderr.NewCodeError(coderr.BadRequest, "unsafe topology type change")
+	ErrParseOfflineShardPolicy  = coderr.NewCodeError(coderr.InvalidParams, "parse offline shard policy")
+	ErrTableAssignmentNotFound  = coderr.NewCodeError(coderr.NotFound, "table assignment not found")
+	ErrTableAssignmentMismatch  = coderr.NewCodeError(coderr.BadRequest, "table assignment mismatches")
+	ErrInvalidPartitionCount    = coderr.NewCodeError(coderr.InvalidParams, "invalid partition count")
+	ErrInvalidTableIDRange      = coderr.NewCodeError(coderr.InvalidParams, "invalid table id range")
+	ErrTableIDRangeOverlap      = coderr.NewCodeError(coderr.InvalidParams, "table id range overlaps")
+	ErrTableIDRangeExhausted    = coderr.NewCodeError(coderr.BadRequest, "table id range exhausted")
+	ErrTableIDRangeNotFound     = coderr.NewCodeError(coderr.NotFound, "table id range not found")
+	ErrTableIDRangeInUse        = coderr.NewCodeError(coderr.BadRequest, "table id range is in use")
+	ErrTooManySubTables         = coderr.NewCodeError(coderr.InvalidParams, "too many sub tables of partition table")
+	ErrInvalidShardIDs          = coderr.NewCodeError(coderr.InvalidParams, "invalid shard ids")
+	ErrDrainNotSupported        = coderr.NewCodeError(coderr.BadRequest, "drain is not supported")
+	ErrInvalidNodeRetention     = coderr.NewCodeError(coderr.InvalidParams, "invalid expired node retention")
+	ErrInvalidOrphanTableAge    = coderr.NewCodeError(coderr.InvalidParams, "invalid min age of orphan tables")
 )
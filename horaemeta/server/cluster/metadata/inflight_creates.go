@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package metadata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// inflightCreateTTL bounds how long a table creation is counted as in flight, so that the creation whose procedure never finishes,
+// e.g. it fails to be submitted, doesn't block its shards for long. The creation lasting longer is just not counted anymore.
+const inflightCreateTTL = time.Minute
+
+// InflightCreatesTicket identifies the table creations in flight acquired together, which are released together by it.
+type InflightCreatesTicket struct {
+	id uint64
+}
+
+type inflightCreate struct {
+	shardIDs []storage.ShardID
+	start    time.Time
+}
+
+// inflightCreates counts the table creations which have picked their shards but are not finished yet.
+type inflightCreates struct {
+	lock   sync.Mutex
+	nextID uint64
+	// The creations in flight, ticket id -> creation.
+	creates map[uint64]inflightCreate
+}
+
+func newInflightCreates() *inflightCreates {
+	return &inflightCreates{
+		lock:    sync.Mutex{},
+		nextID:  0,
+		creates: map[uint64]inflightCreate{},
+	}
+}
+
+// acquireWithinQuota counts the creations in flight on the shards only if they don't make the tables exceed the maxTables, zero means unlimited.
+// The tables are the ones counted by the tableCount and the ones in flight, and the tableCount is called under the lock, so that the
+// concurrent acquisitions never pass the quota together and the table created before its creation is released is never missed.
+func (c *inflightCreates) acquireWithinQuota(shardIDs []storage.ShardID, now time.Time, maxTables uint64, tableCount func() int) (InflightCreatesTicket, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if maxTables > 0 {
+		numTables := tableCount()
+		numInflight := c.inflightTablesLocked(now)
+		if uint64(numTables+numInflight+len(shardIDs)) > maxTables {
+			return InflightCreatesTicket{id: 0}, ErrTableQuotaExceeded.WithCausef("tableCount:%d, inflightTables:%d, numTablesToCreate:%d, maxTables:%d", numTables, numInflight, len(shardIDs), maxTables)
+		}
+	}
+
+	c.nextID++
+	c.creates[c.nextID] = inflightCreate{shardIDs: shardIDs, start: now}
+	return InflightCreatesTicket{id: c.nextID}, nil
+}
+
+// inflightTablesLocked returns the number of the tables created in flight, and the expired ones are dropped.
+func (c *inflightCreates) inflightTablesLocked(now time.Time) int {
+	numTables := 0
+	for id, create := range c.creates {
+		if now.Sub(create.start) > inflightCreateTTL {
+			delete(c.creates, id)
+			continue
+		}
+		numTables += len(create.shardIDs)
+	}
+	return numTables
+}
+
+// release finishes the creations acquired with the ticket, and nothing is done if they have been released or expired.
+func (c *inflightCreates) release(ticket InflightCreatesTicket) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.creates, ticket.id)
+}
+
+// counts returns the number of the creations in flight on the shards, and the expired ones are dropped.
+func (c *inflightCreates) counts(now time.Time) map[storage.ShardID]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := map[storage.ShardID]int{}
+	for id, create := range c.creates {
+		if now.Sub(create.start) > inflightCreateTTL {
+			delete(c.creates, id)
+			continue
+		}
+		for _, shardID := range create.shardIDs {
+			counts[shardID]++
+		}
+	}
+	return counts
+}
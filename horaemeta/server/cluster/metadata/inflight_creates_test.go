@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInflightCreates(t *testing.T) {
+	re := require.New(t)
+	c := newInflightCreates()
+	now := time.Now()
+
+	expired, err := c.acquireWithinQuota([]storage.ShardID{0}, now, 0, nil)
+	re.NoError(err)
+	ticket, err := c.acquireWithinQuota([]storage.ShardID{0, 1, 1}, now.Add(inflightCreateTTL), 0, nil)
+	re.NoError(err)
+	re.Equal(map[storage.ShardID]int{0: 2, 1: 2}, c.counts(now.Add(inflightCreateTTL)))
+
+	// Releasing the expired creation doesn't release the others on the same shard.
+	re.Equal(map[storage.ShardID]int{0: 1, 1: 2}, c.counts(now.Add(inflightCreateTTL+time.Second)))
+	c.release(expired)
+	re.Equal(map[storage.ShardID]int{0: 1, 1: 2}, c.counts(now.Add(inflightCreateTTL+time.Second)))
+
+	// The creations are released only once.
+	c.release(ticket)
+	c.release(ticket)
+	re.Empty(c.counts(now.Add(inflightCreateTTL + time.Second)))
+}
+
+func TestInflightCreatesWithinQuota(t *testing.T) {
+	re := require.New(t)
+	c := newInflightCreates()
+	now := time.Now()
+	tableCount := func() int { return 2 }
+
+	// The tables in flight are counted against the quota with the created ones.
+	ticket, err := c.acquireWithinQuota([]storage.ShardID{0, 1}, now, 5, tableCount)
+	re.NoError(err)
+	_, err = c.acquireWithinQuota([]storage.ShardID{0, 1}, now, 5, tableCount)
+	re.ErrorIs(err, ErrTableQuotaExceeded)
+	_, err = c.acquireWithinQuota([]storage.ShardID{0}, now, 5, tableCount)
+	re.NoError(err)
+	_, err = c.acquireWithinQuota([]storage.ShardID{0}, now, 5, tableCount)
+	re.ErrorIs(err, ErrTableQuotaExceeded)
+
+	// The released and expired creations are not counted anymore.
+	c.release(ticket)
+	_, err = c.acquireWithinQuota([]storage.ShardID{0, 1}, now, 5, tableCount)
+	re.NoError(err)
+	_, err = c.acquireWithinQuota([]storage.ShardID{0, 1, 2}, now.Add(inflightCreateTTL+time.Second), 5, tableCount)
+	re.NoError(err)
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package metadata
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// NodeStatsHistoryOptions bounds the utilization history kept in memory for every node.
+// The history is not persisted, and it starts empty after a restart or a leader change.
+type NodeStatsHistoryOptions struct {
+	// Capacity is the max number of the samples kept for every node, zero disables the history.
+	Capacity int
+	// Interval is the min interval between two samples of a node, and the heartbeats in between are not sampled.
+	Interval time.Duration
+}
+
+// NodeStatsSample is the utilization of a node sampled from its heartbeat.
+type NodeStatsSample struct {
+	// Timestamp is the unix milliseconds when the sample is taken.
+	Timestamp         int64
+	ShardCount        int
+	LeaderShardCount  int
+	UnreadyShardCount int
+	// TableCount is the number of the tables on the shards reported by the node.
+	TableCount   int
+	ShuttingDown bool
+}
+
+// nodeStatsRing is a ring buffer keeping the latest samples of a node.
+type nodeStatsRing struct {
+	samples []NodeStatsSample
+	// next is the position of the next sample.
+	next int
+	full bool
+}
+
+func (r *nodeStatsRing) add(sample NodeStatsSample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *nodeStatsRing) last() (NodeStatsSample, bool) {
+	if !r.full && r.next == 0 {
+		return NodeStatsSample{}, false
+	}
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)], true
+}
+
+// list returns the samples from the oldest to the latest.
+func (r *nodeStatsRing) list() []NodeStatsSample {
+	if !r.full {
+		return append([]NodeStatsSample{}, r.samples[:r.next]...)
+	}
+	ret := make([]NodeStatsSample, 0, len(r.samples))
+	ret = append(ret, r.samples[r.next:]...)
+	return append(ret, r.samples[:r.next]...)
+}
+
+// nodeStatsHistory keeps a bounded time series of the utilization of every node, which turns the point-in-time stats in the
+// heartbeats into trends.
+type nodeStatsHistory struct {
+	lock    sync.Mutex
+	options NodeStatsHistoryOptions
+	rings   map[string]*nodeStatsRing
+}
+
+func newNodeStatsHistory(options NodeStatsHistoryOptions) *nodeStatsHistory {
+	return &nodeStatsHistory{
+		lock:    sync.Mutex{},
+		options: options,
+		rings:   map[string]*nodeStatsRing{},
+	}
+}
+
+// due returns true if the sample of the node taken at the timestamp is going to be recorded, so that the caller can skip building
+// the samples which are dropped anyway.
+func (h *nodeStatsHistory) due(nodeName string, timestamp int64) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.dueLocked(nodeName, timestamp)
+}
+
+func (h *nodeStatsHistory) dueLocked(nodeName string, timestamp int64) bool {
+	if h.options.Capacity <= 0 {
+		return false
+	}
+	ring, ok := h.rings[nodeName]
+	if !ok {
+		return true
+	}
+	last, ok := ring.last()
+	return !ok || timestamp-last.Timestamp >= h.options.Interval.Milliseconds()
+}
+
+// record adds the sample of the node, and returns false if it is skipped because the last sample is taken within the interval.
+func (h *nodeStatsHistory) record(nodeName string, sample NodeStatsSample) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !h.dueLocked(nodeName, sample.Timestamp) {
+		return false
+	}
+
+	ring, ok := h.rings[nodeName]
+	if !ok {
+		ring = &nodeStatsRing{samples: make([]NodeStatsSample, h.options.Capacity), next: 0, full: false}
+		h.rings[nodeName] = ring
+	}
+	ring.add(sample)
+	return true
+}
+
+func (h *nodeStatsHistory) get(nodeName string) []NodeStatsSample {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	ring, ok := h.rings[nodeName]
+	if !ok {
+		return []NodeStatsSample{}
+	}
+	return ring.list()
+}
+
+func (h *nodeStatsHistory) getAll() map[string][]NodeStatsSample {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	ret := make(map[string][]NodeStatsSample, len(h.rings))
+	for nodeName, ring := range h.rings {
+		ret[nodeName] = ring.list()
+	}
+	return ret
+}
+
+func (h *nodeStatsHistory) remove(nodeName string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.rings, nodeName)
+}
+
+// newNodeStatsSample samples the utilization of the node from its heartbeat, and tableIDs is the tables of the shards reported by the node.
+func newNodeStatsSample(registeredNode RegisteredNode, readyShardStatuses []storage.ShardStatus, tableIDs map[storage.ShardID]ShardTableIDs) NodeStatsSample {
+	sample := NodeStatsSample{
+		Timestamp:         int64(registeredNode.Node.LastTouchTime),
+		ShardCount:        len(registeredNode.ShardInfos),
+		LeaderShardCount:  0,
+		UnreadyShardCount: 0,
+		TableCount:        0,
+		ShuttingDown:      registeredNode.IsShuttingDown(),
+	}
+	for _, shardInfo := range registeredNode.ShardInfos {
+		if shardInfo.Role == storage.ShardRoleLeader {
+			sample.LeaderShardCount++
+		}
+		if !slices.Contains(readyShardStatuses, shardInfo.Status) {
+			sample.UnreadyShardCount++
+		}
+		sample.TableCount += len(tableIDs[shardInfo.ID].TableIDs)
+	}
+	return sample
+}
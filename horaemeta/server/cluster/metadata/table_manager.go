@@ -22,6 +22,7 @@ package metadata
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -51,8 +52,19 @@ type TableManager interface {
 	GetSchemaByID(schemaID storage.SchemaID) (storage.Schema, bool)
 	// GetSchemas get all schemas in cluster.
 	GetSchemas() []storage.Schema
+	// GetTablesOfSchema get all the tables of the schema.
+	GetTablesOfSchema(schemaName string) []storage.Table
+	// GetTableCountOfSchema get the number of tables of the schema, the second output parameter bool: returns true if the schema exists.
+	GetTableCountOfSchema(schemaName string) (int, bool)
 	// GetOrCreateSchema get or create schema with schemaName.
 	GetOrCreateSchema(ctx context.Context, schemaName string) (storage.Schema, bool, error)
+	// ListTableIDRanges list the table id ranges of the schemas ordered by the start of the ranges.
+	ListTableIDRanges() []storage.TableIDRange
+	// CreateTableIDRange create the table id range of the schema, and the ids of the tables created in the schema afterwards are
+	// allocated in the range. The range must not overlap the others, and must start at or above the minStart.
+	CreateTableIDRange(ctx context.Context, idRange storage.TableIDRange, minStart uint64) error
+	// DeleteTableIDRange delete the table id range of the schema, which is refused if any id in the range has been allocated.
+	DeleteTableIDRange(ctx context.Context, schemaName string) error
 }
 
 type Tables struct {
@@ -66,14 +78,20 @@ type TableManagerImpl struct {
 	clusterID     storage.ClusterID
 	schemaIDAlloc id.Allocator
 	tableIDAlloc  id.Allocator
+	// newRangeTableIDAlloc creates the allocator of the offsets in the table id range of the schema.
+	newRangeTableIDAlloc func(schemaName string) id.Allocator
 
 	// RWMutex is used to protect following fields.
 	lock         sync.RWMutex
 	schemas      map[string]storage.Schema    // schemaName -> schema
 	schemaTables map[storage.SchemaID]*Tables // schemaName -> tables
+	// The table ids of the schemas with a range are allocated in the range, and those of the other schemas are allocated outside
+	// all the ranges.
+	tableIDRanges      map[string]storage.TableIDRange // schemaName -> range
+	rangeTableIDAllocs map[string]id.Allocator         // schemaName -> allocator
 }
 
-func NewTableManagerImpl(logger *zap.Logger, storage storage.Storage, clusterID storage.ClusterID, schemaIDAlloc id.Allocator, tableIDAlloc id.Allocator) TableManager {
+func NewTableManagerImpl(logger *zap.Logger, storage storage.Storage, clusterID storage.ClusterID, schemaIDAlloc id.Allocator, tableIDAlloc id.Allocator, newRangeTableIDAlloc func(schemaName string) id.Allocator) TableManager {
 	return &TableManagerImpl{
 		logger:        logger,
 		storage:       storage,
@@ -85,6 +103,10 @@ func NewTableManagerImpl(logger *zap.Logger, storage storage.Storage, clusterID
 		schemas: nil,
 		// It will be initialized in loadTables.
 		schemaTables: nil,
+		// It will be initialized in loadTableIDRanges.
+		tableIDRanges:        nil,
+		rangeTableIDAllocs:   map[string]id.Allocator{},
+		newRangeTableIDAlloc: newRangeTableIDAlloc,
 	}
 }
 
@@ -100,6 +122,10 @@ func (m *TableManagerImpl) Load(ctx context.Context) error {
 		return errors.WithMessage(err, "load tables")
 	}
 
+	if err := m.loadTableIDRanges(ctx); err != nil {
+		return errors.WithMessage(err, "load table id ranges")
+	}
+
 	return nil
 }
 
@@ -156,13 +182,13 @@ func (m *TableManagerImpl) CreateTable(ctx context.Context, schemaName string, t
 		return emptyTable, ErrSchemaNotFound.WithCausef("schema name:%s", schemaName)
 	}
 
-	id, err := m.tableIDAlloc.Alloc(ctx)
+	id, err := m.allocTableID(ctx, schemaName)
 	if err != nil {
 		return emptyTable, errors.WithMessagef(err, "alloc table id, table name:%s", tableName)
 	}
 
 	table := storage.Table{
-		ID:            storage.TableID(id),
+		ID:            id,
 		Name:          tableName,
 		SchemaID:      schema.ID,
 		CreatedAt:     uint64(time.Now().UnixMilli()),
@@ -249,7 +275,7 @@ func (m *TableManagerImpl) GetSchemas() []storage.Schema {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	schemas := make([]storage.Schema, len(m.schemas))
+	schemas := make([]storage.Schema, 0, len(m.schemas))
 
 	for _, schema := range m.schemas {
 		schemas = append(schemas, schema)
@@ -258,6 +284,41 @@ func (m *TableManagerImpl) GetSchemas() []storage.Schema {
 	return schemas
 }
 
+func (m *TableManagerImpl) GetTablesOfSchema(schemaName string) []storage.Table {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	schema, ok := m.schemas[schemaName]
+	if !ok {
+		return []storage.Table{}
+	}
+	tables, ok := m.schemaTables[schema.ID]
+	if !ok {
+		return []storage.Table{}
+	}
+
+	result := make([]storage.Table, 0, len(tables.tables))
+	for _, table := range tables.tables {
+		result = append(result, table)
+	}
+	return result
+}
+
+func (m *TableManagerImpl) GetTableCountOfSchema(schemaName string) (int, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	schema, ok := m.schemas[schemaName]
+	if !ok {
+		return 0, false
+	}
+	tables, ok := m.schemaTables[schema.ID]
+	if !ok {
+		return 0, true
+	}
+	return len(tables.tables), true
+}
+
 func (m *TableManagerImpl) GetOrCreateSchema(ctx context.Context, schemaName string) (storage.Schema, bool, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -291,6 +352,125 @@ func (m *TableManagerImpl) GetOrCreateSchema(ctx context.Context, schemaName str
 	return schema, false, nil
 }
 
+func (m *TableManagerImpl) ListTableIDRanges() []storage.TableIDRange {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ranges := make([]storage.TableIDRange, 0, len(m.tableIDRanges))
+	for _, idRange := range m.tableIDRanges {
+		ranges = append(ranges, idRange)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges
+}
+
+func (m *TableManagerImpl) CreateTableIDRange(ctx context.Context, idRange storage.TableIDRange, minStart uint64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(idRange.SchemaName) == 0 {
+		return ErrInvalidTableIDRange.WithCausef("schema name could not be empty")
+	}
+	if idRange.Start >= idRange.End {
+		return ErrInvalidTableIDRange.WithCausef("start must be less than end, start:%d, end:%d", idRange.Start, idRange.End)
+	}
+	if idRange.Start < minStart {
+		return ErrInvalidTableIDRange.WithCausef("start must not be less than %d, which has been allocated to the schemas without range, start:%d", minStart, idRange.Start)
+	}
+	if _, ok := m.tableIDRanges[idRange.SchemaName]; ok {
+		return ErrInvalidTableIDRange.WithCausef("range of the schema already exists, schema:%s", idRange.SchemaName)
+	}
+	for _, other := range m.tableIDRanges {
+		if idRange.Start < other.End && other.Start < idRange.End {
+			return ErrTableIDRangeOverlap.WithCausef("range:[%d, %d) of schema:%s overlaps range:[%d, %d) of schema:%s", idRange.Start, idRange.End, idRange.SchemaName, other.Start, other.End, other.SchemaName)
+		}
+	}
+
+	if err := m.storage.CreateTableIDRange(ctx, storage.CreateTableIDRangeRequest{
+		ClusterID: m.clusterID,
+		Range:     idRange,
+	}); err != nil {
+		return errors.WithMessage(err, "storage create table id range")
+	}
+	m.tableIDRanges[idRange.SchemaName] = idRange
+
+	return nil
+}
+
+func (m *TableManagerImpl) DeleteTableIDRange(ctx context.Context, schemaName string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	idRange, ok := m.tableIDRanges[schemaName]
+	if !ok {
+		return ErrTableIDRangeNotFound.WithCausef("schema:%s", schemaName)
+	}
+	// The allocator of the range is only created when an id is allocated in it.
+	if _, ok := m.rangeTableIDAllocs[schemaName]; ok {
+		return ErrTableIDRangeInUse.WithCausef("ids have been allocated in range:[%d, %d) of schema:%s", idRange.Start, idRange.End, schemaName)
+	}
+
+	if err := m.storage.DeleteTableIDRange(ctx, storage.DeleteTableIDRangeRequest{
+		ClusterID:  m.clusterID,
+		SchemaName: schemaName,
+	}); err != nil {
+		return errors.WithMessage(err, "storage delete table id range")
+	}
+	delete(m.tableIDRanges, schemaName)
+
+	return nil
+}
+
+// allocTableID allocates the table id in the range of the schema if it has one, otherwise outside all the ranges.
+func (m *TableManagerImpl) allocTableID(ctx context.Context, schemaName string) (storage.TableID, error) {
+	idRange, ok := m.tableIDRanges[schemaName]
+	if !ok {
+		return m.allocTableIDOutsideRanges(ctx)
+	}
+
+	alloc, ok := m.rangeTableIDAllocs[schemaName]
+	if !ok {
+		alloc = m.newRangeTableIDAlloc(schemaName)
+		m.rangeTableIDAllocs[schemaName] = alloc
+	}
+	offset, err := alloc.Alloc(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if offset >= idRange.End-idRange.Start {
+		return 0, ErrTableIDRangeExhausted.WithCausef("schema:%s, range:[%d, %d)", schemaName, idRange.Start, idRange.End)
+	}
+	return storage.TableID(idRange.Start + offset), nil
+}
+
+// allocTableIDOutsideRanges allocates the table id for the schemas without range, and the ranges reserved for the other schemas
+// are skipped.
+func (m *TableManagerImpl) allocTableIDOutsideRanges(ctx context.Context) (storage.TableID, error) {
+	for {
+		id, err := m.tableIDAlloc.Alloc(ctx)
+		if err != nil {
+			return 0, err
+		}
+		reserved, ok := m.findTableIDRange(id)
+		if !ok {
+			return storage.TableID(id), nil
+		}
+		if err := m.tableIDAlloc.SkipTo(ctx, reserved.End); err != nil {
+			return 0, errors.WithMessagef(err, "skip range:[%d, %d) of schema:%s", reserved.Start, reserved.End, reserved.SchemaName)
+		}
+	}
+}
+
+// findTableIDRange returns the table id range containing the id.
+func (m *TableManagerImpl) findTableIDRange(id uint64) (storage.TableIDRange, bool) {
+	for _, idRange := range m.tableIDRanges {
+		if id >= idRange.Start && id < idRange.End {
+			return idRange, true
+		}
+	}
+	return storage.TableIDRange{}, false
+}
+
 func (m *TableManagerImpl) loadSchemas(ctx context.Context) error {
 	schemasResult, err := m.storage.ListSchemas(ctx, storage.ListSchemasRequest{ClusterID: m.clusterID})
 	if err != nil {
@@ -337,6 +517,20 @@ func (m *TableManagerImpl) loadTables(ctx context.Context) error {
 	return nil
 }
 
+func (m *TableManagerImpl) loadTableIDRanges(ctx context.Context) error {
+	rangesResult, err := m.storage.ListTableIDRanges(ctx, storage.ListTableIDRangesRequest{ClusterID: m.clusterID})
+	if err != nil {
+		return errors.WithMessage(err, "list table id ranges")
+	}
+
+	// Reset data in memory.
+	m.tableIDRanges = make(map[string]storage.TableIDRange, len(rangesResult.Ranges))
+	for _, idRange := range rangesResult.Ranges {
+		m.tableIDRanges[idRange.SchemaName] = idRange
+	}
+	return nil
+}
+
 func (m *TableManagerImpl) getTable(schemaName, tableName string) (storage.Table, bool, error) {
 	schema, ok := m.schemas[schemaName]
 	var emptyTable storage.Table
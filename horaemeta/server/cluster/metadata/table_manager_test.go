@@ -21,9 +21,11 @@ package metadata_test
 
 import (
 	"context"
+	"fmt"
 	"path"
 	"testing"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/id"
@@ -49,17 +51,21 @@ func TestTableManager(t *testing.T) {
 
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 	clusterStorage := storage.NewStorageWithEtcdBackend(client, TestRootPath, storage.Options{
-		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 10,
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 10, ReadTimeout: 0, WriteTimeout: 0,
 	})
 
 	schemaIDAlloc := id.NewAllocatorImpl(zap.NewNop(), client, path.Join(TestRootPath, TestClusterName, TestSchemaIDPrefix), TestIDAllocatorStep)
 	tableIDAlloc := id.NewAllocatorImpl(zap.NewNop(), client, path.Join(TestRootPath, TestClusterName, TestTableIDPrefix), TestIDAllocatorStep)
-	tableManager := metadata.NewTableManagerImpl(zap.NewNop(), clusterStorage, storage.ClusterID(TestClusterID), schemaIDAlloc, tableIDAlloc)
+	newRangeTableIDAlloc := func(schemaName string) id.Allocator {
+		return id.NewAllocatorImpl(zap.NewNop(), client, path.Join(TestRootPath, TestClusterName, metadata.AllocRangeTableIDPrefix, schemaName), TestIDAllocatorStep)
+	}
+	tableManager := metadata.NewTableManagerImpl(zap.NewNop(), clusterStorage, storage.ClusterID(TestClusterID), schemaIDAlloc, tableIDAlloc, newRangeTableIDAlloc)
 	err := tableManager.Load(ctx)
 	re.NoError(err)
 
 	testSchema(ctx, re, tableManager)
 	testCreateAndDropTable(ctx, re, tableManager)
+	testTableIDRange(ctx, re, tableManager)
 }
 
 func testSchema(ctx context.Context, re *require.Assertions, manager metadata.TableManager) {
@@ -92,3 +98,59 @@ func testCreateAndDropTable(ctx context.Context, re *require.Assertions, manager
 	re.NoError(err)
 	re.False(exists)
 }
+
+func testTableIDRange(ctx context.Context, re *require.Assertions, manager metadata.TableManager) {
+	rangeSchemaName := "RangeSchemaName"
+	idRange := storage.TableIDRange{SchemaName: rangeSchemaName, Start: 1000, End: 1002}
+	minStart := uint64(TestIDAllocatorStep)
+
+	// Invalid ranges are rejected.
+	err := manager.CreateTableIDRange(ctx, storage.TableIDRange{SchemaName: rangeSchemaName, Start: 1000, End: 1000}, minStart)
+	re.True(coderr.Is(err, metadata.ErrInvalidTableIDRange.Code()))
+	err = manager.CreateTableIDRange(ctx, storage.TableIDRange{SchemaName: rangeSchemaName, Start: 0, End: 1000}, minStart)
+	re.True(coderr.Is(err, metadata.ErrInvalidTableIDRange.Code()))
+
+	err = manager.CreateTableIDRange(ctx, idRange, minStart)
+	re.NoError(err)
+	re.Equal([]storage.TableIDRange{idRange}, manager.ListTableIDRanges())
+
+	// The range can't be changed once created, and the ranges must not overlap each other.
+	err = manager.CreateTableIDRange(ctx, storage.TableIDRange{SchemaName: rangeSchemaName, Start: 2000, End: 3000}, minStart)
+	re.Error(err)
+	err = manager.CreateTableIDRange(ctx, storage.TableIDRange{SchemaName: TestSchemaName, Start: 1001, End: 3000}, minStart)
+	re.True(coderr.Is(err, metadata.ErrTableIDRangeOverlap.Code()))
+	re.ErrorContains(err, "table id range overlaps")
+
+	// The ids of the tables in the schema are allocated in the range.
+	_, _, err = manager.GetOrCreateSchema(ctx, rangeSchemaName)
+	re.NoError(err)
+	for i := uint64(0); i < idRange.End-idRange.Start; i++ {
+		table, err := manager.CreateTable(ctx, rangeSchemaName, fmt.Sprintf("%s_%d", TestTableName, i), storage.PartitionInfo{Info: nil})
+		re.NoError(err)
+		re.Equal(storage.TableID(idRange.Start+i), table.ID)
+	}
+	_, err = manager.CreateTable(ctx, rangeSchemaName, TestTableName, storage.PartitionInfo{Info: nil})
+	re.True(coderr.Is(err, metadata.ErrTableIDRangeExhausted.Code()))
+	re.ErrorContains(err, "table id range exhausted")
+
+	// The ids of the tables in the schemas without range skip the reserved ranges.
+	reservedRange := storage.TableIDRange{SchemaName: "ReservedSchemaName", Start: minStart, End: minStart + 3}
+	err = manager.CreateTableIDRange(ctx, reservedRange, minStart)
+	re.NoError(err)
+	ids := make([]storage.TableID, 0, minStart)
+	for i := uint64(0); i < minStart; i++ {
+		table, err := manager.CreateTable(ctx, TestSchemaName, fmt.Sprintf("%s_%d", TestTableName, i), storage.PartitionInfo{Info: nil})
+		re.NoError(err)
+		ids = append(ids, table.ID)
+	}
+	re.Equal([]storage.TableID{1, 2, 3, 4, 8}, ids)
+
+	// Only the range without any allocated id can be deleted.
+	err = manager.DeleteTableIDRange(ctx, rangeSchemaName)
+	re.True(coderr.Is(err, metadata.ErrTableIDRangeInUse.Code()))
+	err = manager.DeleteTableIDRange(ctx, reservedRange.SchemaName)
+	re.NoError(err)
+	re.Equal([]storage.TableIDRange{idRange}, manager.ListTableIDRanges())
+	err = manager.DeleteTableIDRange(ctx, reservedRange.SchemaName)
+	re.True(coderr.Is(err, metadata.ErrTableIDRangeNotFound.Code()))
+}
@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package metadata
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// TopologyDiff describes the structural differences between the shard and table layouts of two clusters.
+// The shards are compared by their ids, and the tables are compared by their names since the ids are allocated by each cluster.
+type TopologyDiff struct {
+	SourceCluster string `json:"sourceCluster"`
+	TargetCluster string `json:"targetCluster"`
+	// Equivalent is true if no structural difference is found.
+	Equivalent       bool   `json:"equivalent"`
+	SourceShardTotal uint32 `json:"sourceShardTotal"`
+	TargetShardTotal uint32 `json:"targetShardTotal"`
+
+	ShardsOnlyInSource []storage.ShardID `json:"shardsOnlyInSource"`
+	ShardsOnlyInTarget []storage.ShardID `json:"shardsOnlyInTarget"`
+	TablesOnlyInSource []TableRef        `json:"tablesOnlyInSource"`
+	TablesOnlyInTarget []TableRef        `json:"tablesOnlyInTarget"`
+	// PlacementMismatches contains the tables existing in both clusters but assigned to different shards.
+	PlacementMismatches []TablePlacementMismatch `json:"placementMismatches"`
+}
+
+// TableRef identifies a table by its schema name and table name.
+type TableRef struct {
+	SchemaName string `json:"schemaName"`
+	TableName  string `json:"tableName"`
+}
+
+// TablePlacementMismatch describes a table assigned to different shards in the two clusters, and the shard is nil if the table
+// is not assigned to any shard.
+type TablePlacementMismatch struct {
+	TableRef
+	SourceShardID *storage.ShardID `json:"sourceShardID"`
+	TargetShardID *storage.ShardID `json:"targetShardID"`
+}
+
+// CompareTopology compares the shard and table layouts of the source cluster with the target cluster. The clusters of different
+// sizes are compared as well, and the mismatches are reported in the diff.
+func CompareTopology(source, target *ClusterMetadata) TopologyDiff {
+	sourceSnapshot := source.GetClusterSnapshot()
+	targetSnapshot := target.GetClusterSnapshot()
+	sourceLayout := source.tableLayout(sourceSnapshot)
+	targetLayout := target.tableLayout(targetSnapshot)
+
+	diff := TopologyDiff{
+		SourceCluster:       source.Name(),
+		TargetCluster:       target.Name(),
+		Equivalent:          false,
+		SourceShardTotal:    source.GetTotalShardNum(),
+		TargetShardTotal:    target.GetTotalShardNum(),
+		ShardsOnlyInSource:  []storage.ShardID{},
+		ShardsOnlyInTarget:  []storage.ShardID{},
+		TablesOnlyInSource:  []TableRef{},
+		TablesOnlyInTarget:  []TableRef{},
+		PlacementMismatches: []TablePlacementMismatch{},
+	}
+
+	for shardID := range sourceSnapshot.Topology.ShardViewsMapping {
+		if _, ok := targetSnapshot.Topology.ShardViewsMapping[shardID]; !ok {
+			diff.ShardsOnlyInSource = append(diff.ShardsOnlyInSource, shardID)
+		}
+	}
+	for shardID := range targetSnapshot.Topology.ShardViewsMapping {
+		if _, ok := sourceSnapshot.Topology.ShardViewsMapping[shardID]; !ok {
+			diff.ShardsOnlyInTarget = append(diff.ShardsOnlyInTarget, shardID)
+		}
+	}
+
+	for table, sourceShardID := range sourceLayout {
+		targetShardID, ok := targetLayout[table]
+		if !ok {
+			diff.TablesOnlyInSource = append(diff.TablesOnlyInSource, table)
+			continue
+		}
+		if !sameShard(sourceShardID, targetShardID) {
+			diff.PlacementMismatches = append(diff.PlacementMismatches, TablePlacementMismatch{
+				TableRef:      table,
+				SourceShardID: sourceShardID,
+				TargetShardID: targetShardID,
+			})
+		}
+	}
+	for table := range targetLayout {
+		if _, ok := sourceLayout[table]; !ok {
+			diff.TablesOnlyInTarget = append(diff.TablesOnlyInTarget, table)
+		}
+	}
+
+	slices.Sort(diff.ShardsOnlyInSource)
+	slices.Sort(diff.ShardsOnlyInTarget)
+	sortTableRefs(diff.TablesOnlyInSource)
+	sortTableRefs(diff.TablesOnlyInTarget)
+	sort.Slice(diff.PlacementMismatches, func(i, j int) bool {
+		return lessTableRef(diff.PlacementMismatches[i].TableRef, diff.PlacementMismatches[j].TableRef)
+	})
+
+	diff.Equivalent = diff.SourceShardTotal == diff.TargetShardTotal &&
+		len(diff.ShardsOnlyInSource) == 0 && len(diff.ShardsOnlyInTarget) == 0 &&
+		len(diff.TablesOnlyInSource) == 0 && len(diff.TablesOnlyInTarget) == 0 &&
+		len(diff.PlacementMismatches) == 0
+	return diff
+}
+
+// tableLayout returns the shard of all the tables in the cluster, and the shard is nil if the table is not assigned to any shard.
+func (c *ClusterMetadata) tableLayout(snapshot Snapshot) map[TableRef]*storage.ShardID {
+	schemaNames := make(map[storage.SchemaID]string)
+	layout := make(map[TableRef]*storage.ShardID)
+	for _, schema := range c.GetSchemas() {
+		schemaNames[schema.ID] = schema.Name
+		for _, table := range c.GetTablesOfSchema(schema.Name) {
+			layout[TableRef{SchemaName: schema.Name, TableName: table.Name}] = nil
+		}
+	}
+
+	for shardID, shardView := range snapshot.Topology.ShardViewsMapping {
+		for _, table := range c.GetTablesByIDs(shardView.TableIDs) {
+			assignedShardID := shardID
+			layout[TableRef{SchemaName: schemaNames[table.SchemaID], TableName: table.Name}] = &assignedShardID
+		}
+	}
+	return layout
+}
+
+func sameShard(a, b *storage.ShardID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sortTableRefs(tables []TableRef) {
+	sort.Slice(tables, func(i, j int) bool { return lessTableRef(tables[i], tables[j]) })
+}
+
+func lessTableRef(a, b TableRef) bool {
+	if a.SchemaName != b.SchemaName {
+		return a.SchemaName < b.SchemaName
+	}
+	return a.TableName < b.TableName
+}
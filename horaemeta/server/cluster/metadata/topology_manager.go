@@ -40,16 +40,23 @@ type TopologyManager interface {
 	GetClusterState() storage.ClusterState
 	// GetTableIDs get shardNode and tablesIDs with shardID and nodeName.
 	GetTableIDs(shardIDs []storage.ShardID) map[storage.ShardID]ShardTableIDs
+	// GetShardVersion get the version of the shard, false is returned if the shard doesn't exist.
+	GetShardVersion(shardID storage.ShardID) (uint64, bool)
 	// AddTable add table to cluster topology.
 	AddTable(ctx context.Context, shardID storage.ShardID, latestVersion uint64, tables []storage.Table) error
 	// RemoveTable remove table on target shards from cluster topology.
 	RemoveTable(ctx context.Context, shardID storage.ShardID, latestVersion uint64, tableIDs []storage.TableID) error
+	// RunIfTableOnNoShard runs fn under the lock of the topology if the table is not contained by any shard view, so that the table can't
+	// be added to any shard meanwhile, and true is returned without running fn if the table is on some shard.
+	RunIfTableOnNoShard(tableID storage.TableID, fn func() error) (bool, error)
 	// GetTableShardID get the shardID of the shard where the table is located.
 	GetTableShardID(ctx context.Context, table storage.Table) (storage.ShardID, bool)
 	// AssignTableToShard persistent table shard mapping, it is used to store assign results and make the table creation idempotent.
 	AssignTableToShard(ctx context.Context, schemaID storage.SchemaID, tableName string, shardID storage.ShardID) error
 	// GetTableAssignedShard get table assign result.
 	GetTableAssignedShard(ctx context.Context, schemaID storage.SchemaID, tableName string) (storage.ShardID, bool)
+	// ListTableAssignedShards lists all the table assign results, schemaID -> tableName -> shardID.
+	ListTableAssignedShards() map[storage.SchemaID]map[string]storage.ShardID
 	// DeleteTableAssignedShard delete table assign result.
 	DeleteTableAssignedShard(ctx context.Context, schemaID storage.SchemaID, tableName string) error
 	// GetShards get all shards in cluster topology.
@@ -117,6 +124,17 @@ func (t *Topology) IsStable() bool {
 	return true
 }
 
+// TableCount returns the number of distinct tables in all shard views.
+func (t *Topology) TableCount() int {
+	tableIDs := make(map[storage.TableID]struct{})
+	for _, shardView := range t.ShardViewsMapping {
+		for _, tableID := range shardView.TableIDs {
+			tableIDs[tableID] = struct{}{}
+		}
+	}
+	return len(tableIDs)
+}
+
 func (t *Topology) IsPrepareFinished() bool {
 	if t.ClusterView.State != storage.ClusterStatePrepare {
 		return false
@@ -218,6 +236,17 @@ func (m *TopologyManagerImpl) GetTableIDs(shardIDs []storage.ShardID) map[storag
 	return shardTableIDs
 }
 
+func (m *TopologyManagerImpl) GetShardVersion(shardID storage.ShardID) (uint64, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	shardView, ok := m.shardTablesMapping[shardID]
+	if !ok {
+		return 0, false
+	}
+	return shardView.Version, true
+}
+
 func (m *TopologyManagerImpl) AddTable(ctx context.Context, shardID storage.ShardID, latestVersion uint64, tables []storage.Table) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -346,10 +375,28 @@ func (m *TopologyManagerImpl) AssignTableToShard(ctx context.Context, schemaID s
 }
 
 func (m *TopologyManagerImpl) GetTableAssignedShard(_ context.Context, schemaID storage.SchemaID, tableName string) (storage.ShardID, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	assignResult, exists := m.tableAssignMapping[schemaID][tableName]
 	return assignResult, exists
 }
 
+func (m *TopologyManagerImpl) ListTableAssignedShards() map[storage.SchemaID]map[string]storage.ShardID {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	result := make(map[storage.SchemaID]map[string]storage.ShardID, len(m.tableAssignMapping))
+	for schemaID, assigns := range m.tableAssignMapping {
+		copied := make(map[string]storage.ShardID, len(assigns))
+		for tableName, shardID := range assigns {
+			copied[tableName] = shardID
+		}
+		result[schemaID] = copied
+	}
+	return result
+}
+
 func (m *TopologyManagerImpl) DeleteTableAssignedShard(ctx context.Context, schemaID storage.SchemaID, tableName string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -591,6 +638,16 @@ func (m *TopologyManagerImpl) UpdateShardVersionWithExpect(ctx context.Context,
 	return nil
 }
 
+func (m *TopologyManagerImpl) RunIfTableOnNoShard(tableID storage.TableID, fn func() error) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if shardIDs, ok := m.tableShardMapping[tableID]; ok && len(shardIDs) > 0 {
+		return true, nil
+	}
+	return false, fn()
+}
+
 func (m *TopologyManagerImpl) GetTopology() Topology {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -48,7 +48,7 @@ func TestTopologyManager(t *testing.T) {
 
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 	clusterStorage := storage.NewStorageWithEtcdBackend(client, TestRootPath, storage.Options{
-		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32,
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
 	})
 	shardIDAlloc := id.NewReusableAllocatorImpl([]uint64{}, TestMinShardID)
 
@@ -82,6 +82,16 @@ func testTableTopology(ctx context.Context, re *require.Assertions, manager meta
 	found := foundTable(TestTableID, shardTables, TestTableID)
 	re.Equal(true, found)
 
+	// The function is not run for the table on the shard.
+	ran := false
+	onShards, err := manager.RunIfTableOnNoShard(TestTableID, func() error {
+		ran = true
+		return nil
+	})
+	re.NoError(err)
+	re.True(onShards)
+	re.False(ran)
+
 	err = manager.RemoveTable(ctx, TestShardID, 0, []storage.TableID{TestTableID})
 	re.NoError(err)
 
@@ -89,6 +99,14 @@ func testTableTopology(ctx context.Context, re *require.Assertions, manager meta
 	found = foundTable(TestTableID, shardTables, TestTableID)
 	re.Equal(false, found)
 
+	onShards, err = manager.RunIfTableOnNoShard(TestTableID, func() error {
+		ran = true
+		return nil
+	})
+	re.NoError(err)
+	re.False(onShards)
+	re.True(ran)
+
 	err = manager.AddTable(ctx, TestShardID, 0, []storage.Table{{
 		ID:            TestTableID,
 		Name:          TestTableName,
@@ -20,9 +20,15 @@
 package metadata
 
 import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
 	"github.com/pkg/errors"
 )
@@ -30,11 +36,313 @@ import (
 const (
 	expiredThreshold = time.Second * 10
 	MinShardID       = 0
+	// subTablePrefix is the prefix of the sub table names of partition tables.
+	subTablePrefix = "__"
 )
 
 type Snapshot struct {
 	Topology        Topology
 	RegisteredNodes []RegisteredNode
+	// MaintenanceShards contains the shards excluded from scheduling, shardID -> reason.
+	MaintenanceShards map[storage.ShardID]string
+	// OscillatingShards contains the shards whose leader moves too frequently, and they are not moved between the alive nodes.
+	OscillatingShards map[storage.ShardID]struct{}
+	// ReadyShardStatuses contains the shard statuses considered as ready, only ShardStatusReady is considered if it is empty.
+	ReadyShardStatuses []storage.ShardStatus
+	// ShardMinNodeVersions contains the min node version required by the shards, shardID -> version.
+	ShardMinNodeVersions map[storage.ShardID]string
+	// PreferredLeaders contains the node preferred to be the leader of the shards, shardID -> nodeName.
+	PreferredLeaders map[storage.ShardID]string
+	// ShardPermutation tells whether the shard ids are permuted before placement to spread the adjacent shards.
+	ShardPermutation bool
+	// InflightCreates contains the number of the table creations in flight on the shards, shardID -> count.
+	InflightCreates map[storage.ShardID]int
+	// MaxInflightCreatesPerShard is the max number of the table creations in flight on a shard, zero means unlimited.
+	MaxInflightCreatesPerShard uint32
+	// LoadedAt is when the metadata is loaded by the current leader, before which the nodes heartbeating to the former leader are not registered.
+	LoadedAt time.Time
+}
+
+// IsShardUnderMaintenance returns true if the shard should be skipped by the schedulers.
+func (s Snapshot) IsShardUnderMaintenance(shardID storage.ShardID) bool {
+	_, ok := s.MaintenanceShards[shardID]
+	return ok
+}
+
+// IsShardMoveSuppressed returns true if the shard is oscillating, and the schedulers shouldn't move its leader off an alive node.
+// The shard is still assigned if it is unassigned, reopened if it is not opened, and moved off the dead node.
+func (s Snapshot) IsShardMoveSuppressed(shardID storage.ShardID) bool {
+	_, ok := s.OscillatingShards[shardID]
+	return ok
+}
+
+// IsShardStatusReady returns true if the shard status is acceptable and the shard needs no more scheduling.
+func (s Snapshot) IsShardStatusReady(status storage.ShardStatus) bool {
+	if len(s.ReadyShardStatuses) == 0 {
+		return status == storage.ShardStatusReady
+	}
+	return slices.Contains(s.ReadyShardStatuses, status)
+}
+
+// UnderReplicatedShard describes the shard with fewer healthy replicas than expected.
+type UnderReplicatedShard struct {
+	ShardID         storage.ShardID
+	HealthyReplicas int
+	MissingReplicas int
+}
+
+// FindUnderReplicatedShards returns the shards whose healthy replicas in the cluster view are fewer than the replication factor, ordered by the shard id.
+// A replica is healthy only if its node is registered, not expired and not shutting down.
+func (s Snapshot) FindUnderReplicatedShards(replicationFactor int, now time.Time) []UnderReplicatedShard {
+	healthyNodes := make(map[string]struct{}, len(s.RegisteredNodes))
+	for _, node := range s.RegisteredNodes {
+		if !node.IsExpired(now) && !node.IsShuttingDown() {
+			healthyNodes[node.Node.Name] = struct{}{}
+		}
+	}
+
+	healthyReplicas := make(map[storage.ShardID]int, len(s.Topology.ShardViewsMapping))
+	for _, shardNode := range s.Topology.ClusterView.ShardNodes {
+		if _, ok := healthyNodes[shardNode.NodeName]; ok {
+			healthyReplicas[shardNode.ID]++
+		}
+	}
+
+	shardIDs := make([]storage.ShardID, 0, len(s.Topology.ShardViewsMapping))
+	for shardID := range s.Topology.ShardViewsMapping {
+		shardIDs = append(shardIDs, shardID)
+	}
+	slices.Sort(shardIDs)
+
+	underReplicatedShards := make([]UnderReplicatedShard, 0)
+	for _, shardID := range shardIDs {
+		numHealthy := healthyReplicas[shardID]
+		if numHealthy >= replicationFactor {
+			continue
+		}
+		underReplicatedShards = append(underReplicatedShards, UnderReplicatedShard{
+			ShardID:         shardID,
+			HealthyReplicas: numHealthy,
+			MissingReplicas: replicationFactor - numHealthy,
+		})
+	}
+	return underReplicatedShards
+}
+
+// GhostShard describes the shard reported by a node in the heartbeat but not assigned to the node in the cluster view.
+type GhostShard struct {
+	ShardID  storage.ShardID
+	NodeName string
+	// Unknown is true if the shard doesn't exist in the cluster at all, otherwise it is assigned to other nodes.
+	Unknown bool
+}
+
+// FindGhostShards returns the shards reported by the alive nodes but not assigned to them in the cluster view, ordered by
+// the node name and the shard id. These shards are usually left over by the failed migrations, and the expired nodes are
+// skipped because their reports are stale.
+func (s Snapshot) FindGhostShards(now time.Time) []GhostShard {
+	assigned := make(map[string]map[storage.ShardID]struct{}, len(s.RegisteredNodes))
+	for _, shardNode := range s.Topology.ClusterView.ShardNodes {
+		if _, ok := assigned[shardNode.NodeName]; !ok {
+			assigned[shardNode.NodeName] = make(map[storage.ShardID]struct{})
+		}
+		assigned[shardNode.NodeName][shardNode.ID] = struct{}{}
+	}
+
+	ghostShards := make([]GhostShard, 0)
+	for _, node := range s.RegisteredNodes {
+		if node.IsExpired(now) {
+			continue
+		}
+		for _, shardInfo := range node.ShardInfos {
+			if _, ok := assigned[node.Node.Name][shardInfo.ID]; ok {
+				continue
+			}
+			_, exists := s.Topology.ShardViewsMapping[shardInfo.ID]
+			ghostShards = append(ghostShards, GhostShard{
+				ShardID:  shardInfo.ID,
+				NodeName: node.Node.Name,
+				Unknown:  !exists,
+			})
+		}
+	}
+
+	sort.Slice(ghostShards, func(i, j int) bool {
+		if ghostShards[i].NodeName != ghostShards[j].NodeName {
+			return ghostShards[i].NodeName < ghostShards[j].NodeName
+		}
+		return ghostShards[i].ShardID < ghostShards[j].ShardID
+	})
+	return ghostShards
+}
+
+// UnreadyShard describes the shard reported by a node with a status not considered as ready.
+type UnreadyShard struct {
+	ShardID  storage.ShardID
+	NodeName string
+	Status   storage.ShardStatus
+}
+
+// FindUnreadyShards returns the shards reported by the registered nodes with the statuses not considered as ready, ordered by
+// the node name and the shard id.
+func (s Snapshot) FindUnreadyShards() []UnreadyShard {
+	unreadyShards := make([]UnreadyShard, 0)
+	for _, node := range s.RegisteredNodes {
+		for _, shardInfo := range node.ShardInfos {
+			if s.IsShardStatusReady(shardInfo.Status) {
+				continue
+			}
+			unreadyShards = append(unreadyShards, UnreadyShard{
+				ShardID:  shardInfo.ID,
+				NodeName: node.Node.Name,
+				Status:   shardInfo.Status,
+			})
+		}
+	}
+
+	sort.Slice(unreadyShards, func(i, j int) bool {
+		if unreadyShards[i].NodeName != unreadyShards[j].NodeName {
+			return unreadyShards[i].NodeName < unreadyShards[j].NodeName
+		}
+		return unreadyShards[i].ShardID < unreadyShards[j].ShardID
+	})
+	return unreadyShards
+}
+
+// FindUnregisteredShards returns the shards of the cluster not reported by any registered node, ordered by the shard id.
+func (s Snapshot) FindUnregisteredShards() []storage.ShardID {
+	registeredShards := make(map[storage.ShardID]struct{}, len(s.Topology.ShardViewsMapping))
+	for _, node := range s.RegisteredNodes {
+		for _, shardInfo := range node.ShardInfos {
+			registeredShards[shardInfo.ID] = struct{}{}
+		}
+	}
+
+	unregisteredShards := make([]storage.ShardID, 0)
+	for shardID := range s.Topology.ShardViewsMapping {
+		if _, ok := registeredShards[shardID]; !ok {
+			unregisteredShards = append(unregisteredShards, shardID)
+		}
+	}
+	slices.Sort(unregisteredShards)
+	return unregisteredShards
+}
+
+// FindShardsWithoutEligibleNode returns the shards which can't be scheduled because no eligible node is online, ordered by
+// the shard id. The shard assigned in the cluster view can only be opened on its assigned nodes, which must be registered and
+// not expired, while the unassigned shard can be opened on any alive node not shutting down. The shards under maintenance are
+// skipped, and so is the empty cluster which is never scheduled.
+func (s Snapshot) FindShardsWithoutEligibleNode(now time.Time) []storage.ShardID {
+	shardIDs := make([]storage.ShardID, 0)
+	if s.Topology.ClusterView.State == storage.ClusterStateEmpty {
+		return shardIDs
+	}
+
+	onlineNodes := make(map[string]struct{}, len(s.RegisteredNodes))
+	hasAliveNode := false
+	for _, node := range s.RegisteredNodes {
+		if node.IsExpired(now) {
+			continue
+		}
+		onlineNodes[node.Node.Name] = struct{}{}
+		if !node.IsShuttingDown() {
+			hasAliveNode = true
+		}
+	}
+
+	// shardID -> whether any assigned node of the shard is online.
+	assigned := make(map[storage.ShardID]bool, len(s.Topology.ShardViewsMapping))
+	for _, shardNode := range s.Topology.ClusterView.ShardNodes {
+		_, online := onlineNodes[shardNode.NodeName]
+		assigned[shardNode.ID] = assigned[shardNode.ID] || online
+	}
+
+	for shardID := range s.Topology.ShardViewsMapping {
+		if s.IsShardUnderMaintenance(shardID) {
+			continue
+		}
+		eligible, ok := assigned[shardID]
+		if !ok {
+			eligible = hasAliveNode
+		}
+		if !eligible {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	slices.Sort(shardIDs)
+	return shardIDs
+}
+
+// NodesReported returns whether the registered nodes are fully reported, that is, all the nodes in the cluster view are registered, or
+// the nodes heartbeating to the former leader of HoraeMeta have had enough time to register since the metadata is loaded.
+func (s Snapshot) NodesReported(now time.Time) bool {
+	if now.After(s.LoadedAt.Add(expiredThreshold)) {
+		return true
+	}
+	registered := make(map[string]struct{}, len(s.RegisteredNodes))
+	for _, node := range s.RegisteredNodes {
+		registered[node.Node.Name] = struct{}{}
+	}
+	for _, shardNode := range s.Topology.ClusterView.ShardNodes {
+		if _, ok := registered[shardNode.NodeName]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// GetAliveShardLeader returns the leader of the shard and the registered node it is on, and ErrShardNotOnline is returned
+// if the shard has no leader, or the leader node is not registered, expired or shutting down.
+// The leader node not registered yet is regarded as alive until it has missed the heartbeats since the metadata is loaded, e.g. right
+// after the leader of HoraeMeta changes, and the returned registered node is empty in that case.
+func (s Snapshot) GetAliveShardLeader(shardID storage.ShardID, now time.Time) (storage.ShardNode, RegisteredNode, error) {
+	var emptyShardNode storage.ShardNode
+	var emptyNode RegisteredNode
+	if _, ok := s.Topology.ShardViewsMapping[shardID]; !ok {
+		return emptyShardNode, emptyNode, ErrShardNotFound.WithCausef("shardID:%d", shardID)
+	}
+
+	var leader storage.ShardNode
+	found := false
+	for _, shardNode := range s.Topology.ClusterView.ShardNodes {
+		if shardNode.ID == shardID && shardNode.ShardRole == storage.ShardRoleLeader {
+			leader, found = shardNode, true
+			break
+		}
+	}
+	if !found {
+		return emptyShardNode, emptyNode, ErrShardNotOnline.WithCausef("shard has no leader, shardID:%d", shardID)
+	}
+
+	for _, node := range s.RegisteredNodes {
+		if node.Node.Name != leader.NodeName {
+			continue
+		}
+		if node.IsExpired(now) || node.IsShuttingDown() {
+			return emptyShardNode, emptyNode, ErrShardNotOnline.WithCausef("leader is not alive, shardID:%d, node:%s", shardID, leader.NodeName)
+		}
+		return leader, node, nil
+	}
+	if !now.After(s.LoadedAt.Add(expiredThreshold)) {
+		return leader, emptyNode, nil
+	}
+	return emptyShardNode, emptyNode, ErrShardNotOnline.WithCausef("leader is not registered, shardID:%d, node:%s", shardID, leader.NodeName)
+}
+
+// GetOnlineShardLeader returns the leader of the shard if the shard is online, that is, the leader node is alive and reports the shard as ready.
+func (s Snapshot) GetOnlineShardLeader(shardID storage.ShardID, now time.Time) (storage.ShardNode, error) {
+	var emptyShardNode storage.ShardNode
+	leader, node, err := s.GetAliveShardLeader(shardID, now)
+	if err != nil {
+		return emptyShardNode, err
+	}
+
+	for _, shardInfo := range node.ShardInfos {
+		if shardInfo.ID == shardID && s.IsShardStatusReady(shardInfo.Status) {
+			return leader, nil
+		}
+	}
+	return emptyShardNode, ErrShardNotOnline.WithCausef("shard is not opened by the leader, shardID:%d, node:%s", shardID, leader.NodeName)
 }
 
 type TableInfo struct {
@@ -46,6 +354,38 @@ type TableInfo struct {
 	CreatedAt     uint64
 }
 
+// SubTableLayout describes where a sub table of a partition table is placed.
+type SubTableLayout struct {
+	TableName string
+	// Exists is false if the sub table is declared in the partition info but not found in the metadata.
+	Exists    bool
+	TableID   storage.TableID
+	ShardID   storage.ShardID
+	NodeNames []string
+}
+
+type PartitionTableLayout struct {
+	Table     TableInfo
+	SubTables []SubTableLayout
+}
+
+// SchemaShardSpread describes how the tables of a schema are spread over the shards.
+type SchemaShardSpread struct {
+	TableCount int
+	// ShardTableCounts is the number of the tables of the schema on every shard hosting them, shardID -> count.
+	ShardTableCounts map[storage.ShardID]int
+	// PartitionTableCount is the number of the partition tables, which are not on any shard while their sub tables are.
+	PartitionTableCount int
+	// UnassignedTableCount is the number of the non-partition tables not on any shard.
+	UnassignedTableCount int
+}
+
+// OrphanTable is the table whose metadata exists but which is contained by no shard view, e.g. the one left by a failed creation.
+type OrphanTable struct {
+	SchemaName string
+	Table      storage.Table
+}
+
 type ShardTables struct {
 	Shard  ShardInfo
 	Tables []TableInfo
@@ -71,6 +411,33 @@ type CreateClusterOpts struct {
 	EnableSchedule              bool
 	TopologyType                storage.TopologyType
 	ProcedureExecutingBatchSize uint32
+	// ShardIDs is the ids of the shards of the new cluster, and the ids are allocated from MinShardID if it is empty. It helps the
+	// cluster recreated after deletion to keep the shard ids referenced externally, and the ids must be in [0, ShardTotal) because the
+	// schedulers place the shards by their ids. Note that the nodes and the external systems
+	// may still hold the stale state of the deleted cluster under the same shard ids, which must be cleaned up before reusing them.
+	ShardIDs []storage.ShardID
+}
+
+// ValidateShardIDs checks the shard ids specified for the new cluster, which must be either empty or as many as the shards without
+// duplicates, and every id must be less than the shard total.
+func ValidateShardIDs(shardIDs []storage.ShardID, shardTotal uint32) error {
+	if len(shardIDs) == 0 {
+		return nil
+	}
+	if len(shardIDs) != int(shardTotal) {
+		return ErrInvalidShardIDs.WithCausef("the number of shard ids must equal to the shard total, shardIDs:%d, shardTotal:%d", len(shardIDs), shardTotal)
+	}
+	seen := make(map[storage.ShardID]struct{}, len(shardIDs))
+	for _, shardID := range shardIDs {
+		if uint32(shardID) >= shardTotal {
+			return ErrInvalidShardIDs.WithCausef("shard id must be less than the shard total, shardID:%d, shardTotal:%d", shardID, shardTotal)
+		}
+		if _, ok := seen[shardID]; ok {
+			return ErrInvalidShardIDs.WithCausef("duplicate shard id:%d", shardID)
+		}
+		seen[shardID] = struct{}{}
+	}
+	return nil
 }
 
 type UpdateClusterOpts struct {
@@ -149,13 +516,44 @@ type RouteEntry struct {
 type RouteTablesResult struct {
 	ClusterViewVersion uint64
 	RouteEntries       map[string]RouteEntry
+	// FailedTables is the reasons of the tables failed to be routed, table name -> reason. The tables routed to no shard node are still
+	// returned in the RouteEntries with empty NodeShards for compatibility. The grpc clients receive the reasons in the route failures header.
+	FailedTables map[string]RouteFailureReason
 }
 
+// RouteFailureReason describes why a table couldn't be routed.
+type RouteFailureReason string
+
+const (
+	// RouteFailureTableNotFound means the table doesn't exist in the schema.
+	RouteFailureTableNotFound RouteFailureReason = "tableNotFound"
+	// RouteFailureNoShardAssigned means the table isn't assigned to any shard.
+	RouteFailureNoShardAssigned RouteFailureReason = "noShardAssigned"
+	// RouteFailureShardLeaderless means the shard of the table has no leader node.
+	RouteFailureShardLeaderless RouteFailureReason = "shardLeaderless"
+)
+
 type GetNodeShardsResult struct {
 	ClusterTopologyVersion uint64
 	NodeShards             []ShardNodeWithVersion
 }
 
+// NodePickerHash describes the hash function used by the node picker to place the shards on the nodes.
+// Changing it reshuffles the placement of the shards.
+type NodePickerHash struct {
+	// Function is the name of the hash function, empty means the default one.
+	Function string
+	Seed     uint32
+}
+
+// ShardOscillationThreshold describes how frequently the leader of a shard is allowed to move before the shard is considered
+// oscillating, and the further moves of the oscillating shards are suppressed.
+type ShardOscillationThreshold struct {
+	// MaxMoves is the max number of the leader moves of a shard in the window, zero disables the detection.
+	MaxMoves uint32
+	Window   time.Duration
+}
+
 type RegisteredNode struct {
 	Node       storage.Node
 	ShardInfos []ShardInfo
@@ -168,12 +566,36 @@ func NewRegisteredNode(meta storage.Node, shardInfos []ShardInfo) RegisteredNode
 	}
 }
 
+// IsShuttingDown returns true if the node has claimed it is going to shut down in the heartbeat.
+func (n RegisteredNode) IsShuttingDown() bool {
+	return n.Node.NodeStats.ShuttingDown
+}
+
 func (n RegisteredNode) IsExpired(now time.Time) bool {
 	expiredTime := time.UnixMilli(int64(n.Node.LastTouchTime)).Add(expiredThreshold)
 
 	return now.After(expiredTime)
 }
 
+// HeartbeatLag returns the duration since the last heartbeat of the node.
+func (n RegisteredNode) HeartbeatLag(now time.Time) time.Duration {
+	return now.Sub(time.UnixMilli(int64(n.Node.LastTouchTime)))
+}
+
+// SatisfiesMinVersion returns true if the version of the node is not older than the minVersion.
+// The node whose version can't be parsed never satisfies any min version.
+func (n RegisteredNode) SatisfiesMinVersion(minVersion string) bool {
+	if len(minVersion) == 0 {
+		return true
+	}
+
+	cmp, err := CompareNodeVersion(n.Node.NodeStats.NodeVersion, minVersion)
+	if err != nil {
+		return false
+	}
+	return cmp >= 0
+}
+
 func ConvertShardsInfoToPB(shard ShardInfo) *metaservicepb.ShardInfo {
 	status := storage.ConvertShardStatusToPB(shard.Status)
 	return &metaservicepb.ShardInfo{
@@ -203,6 +625,24 @@ func ConvertTableInfoToPB(table TableInfo) *metaservicepb.TableInfo {
 	}
 }
 
+// FormatSubTableName formats the name of the sub table of a partition table, and it must be consistent with the one in HoraeDB.
+func FormatSubTableName(tableName, partitionName string) string {
+	return fmt.Sprintf("%s%s_%s", subTablePrefix, tableName, partitionName)
+}
+
+func getPartitionDefinitions(partitionInfo *clusterpb.PartitionInfo) []*clusterpb.PartitionDefinition {
+	switch info := partitionInfo.GetInfo().(type) {
+	case *clusterpb.PartitionInfo_Hash:
+		return info.Hash.GetDefinitions()
+	case *clusterpb.PartitionInfo_Key:
+		return info.Key.GetDefinitions()
+	case *clusterpb.PartitionInfo_Random:
+		return info.Random.GetDefinitions()
+	default:
+		return nil
+	}
+}
+
 func ParseTopologyType(rawString string) (storage.TopologyType, error) {
 	switch rawString {
 	case storage.TopologyTypeStatic:
@@ -213,3 +653,103 @@ func ParseTopologyType(rawString string) (storage.TopologyType, error) {
 
 	return "", errors.WithMessagef(ErrParseTopologyType, "could not be parsed to topologyType, rawString:%s", rawString)
 }
+
+// OfflineShardPolicy determines how the table creation behaves if the leader node of the picked shard is offline before the procedure is submitted.
+type OfflineShardPolicy string
+
+const (
+	// OfflineShardPolicyFail rejects the creation without dispatching to the offline node.
+	OfflineShardPolicyFail OfflineShardPolicy = "fail"
+	// OfflineShardPolicyRepick re-picks another shard whose leader node is alive, and creates the table on it.
+	OfflineShardPolicyRepick OfflineShardPolicy = "repick"
+)
+
+func ParseOfflineShardPolicy(rawString string) (OfflineShardPolicy, error) {
+	switch policy := OfflineShardPolicy(rawString); policy {
+	case OfflineShardPolicyFail, OfflineShardPolicyRepick:
+		return policy, nil
+	}
+
+	return "", errors.WithMessagef(ErrParseOfflineShardPolicy, "could not be parsed to offline shard policy, rawString:%s", rawString)
+}
+
+func ParseShardStatus(rawString string) (storage.ShardStatus, error) {
+	switch rawString {
+	case storage.ConvertShardStatusToString(storage.ShardStatusReady):
+		return storage.ShardStatusReady, nil
+	case storage.ConvertShardStatusToString(storage.ShardStatusPartialOpen):
+		return storage.ShardStatusPartialOpen, nil
+	}
+
+	return storage.ShardStatusUnknown, errors.WithMessagef(ErrParseShardStatus, "could not be parsed to shardStatus, rawString:%s", rawString)
+}
+
+// parseNodeVersion parses the version like `v1.2.3-xxx` into numeric components, and the pre-release or build suffix is ignored.
+func parseNodeVersion(rawString string) ([]uint64, error) {
+	version := strings.TrimPrefix(strings.TrimSpace(rawString), "v")
+	if idx := strings.IndexAny(version, "-+"); idx >= 0 {
+		version = version[:idx]
+	}
+	if len(version) == 0 {
+		return nil, errors.WithMessagef(ErrParseNodeVersion, "empty version, rawString:%s", rawString)
+	}
+
+	parts := strings.Split(version, ".")
+	components := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		component, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrParseNodeVersion, "invalid version component, rawString:%s, err:%v", rawString, err)
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// ValidateNodeVersion returns error if the version can't be compared with other versions.
+func ValidateNodeVersion(rawString string) error {
+	_, err := parseNodeVersion(rawString)
+	return err
+}
+
+// CompareNodeVersion returns -1, 0 or 1 if the version a is older than, equal to or newer than the version b.
+// The missing components are considered as zero, that is to say, `1.2` equals to `1.2.0`.
+func CompareNodeVersion(a, b string) (int, error) {
+	versionA, err := parseNodeVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	versionB, err := parseNodeVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < max(len(versionA), len(versionB)); i++ {
+		var componentA, componentB uint64
+		if i < len(versionA) {
+			componentA = versionA[i]
+		}
+		if i < len(versionB) {
+			componentB = versionB[i]
+		}
+		if componentA != componentB {
+			if componentA < componentB {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// TableAssignment is the shard persisted by the shard picker for the table being created, which makes the table creation idempotent.
+// It is removed once the table creation finishes, so the assignment left may mis-route the table created again.
+type TableAssignment struct {
+	SchemaName string
+	TableName  string
+	ShardID    storage.ShardID
+	// TableExists is true if the table has been created.
+	TableExists bool
+	// ShardExists is false if the assigned shard has been removed from the cluster.
+	ShardExists bool
+}
@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var (
+	unregisteredShardsDesc = prometheus.NewDesc(prometheus.BuildFQName("horaemeta", "cluster", "unregistered_shards"),
+		"Number of the shards not reported by any registered node.", []string{"cluster"}, nil)
+	unreadyShardsDesc = prometheus.NewDesc(prometheus.BuildFQName("horaemeta", "cluster", "unready_shards"),
+		"Number of the shards reported by the nodes with the statuses not considered as ready.", []string{"cluster"}, nil)
+	noEligibleNodeShardsDesc = prometheus.NewDesc(prometheus.BuildFQName("horaemeta", "cluster", "no_eligible_node_shards"),
+		"Number of the shards which can't be scheduled because no eligible node is online.", []string{"cluster"}, nil)
+)
+
+// diagnoseCollector exports the counts of the shard diagnosis of every cluster as gauges, which are computed from the cluster snapshots on scrape.
+type diagnoseCollector struct {
+	manager Manager
+}
+
+func NewDiagnoseCollector(manager Manager) prometheus.Collector {
+	return diagnoseCollector{manager: manager}
+}
+
+func (c diagnoseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- unregisteredShardsDesc
+	ch <- unreadyShardsDesc
+	ch <- noEligibleNodeShardsDesc
+}
+
+func (c diagnoseCollector) Collect(ch chan<- prometheus.Metric) {
+	clusters, err := c.manager.ListClusters(context.Background())
+	if err != nil {
+		log.Warn("list clusters for diagnose metrics", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for _, cluster := range clusters {
+		snapshot := cluster.GetMetadata().GetClusterSnapshot()
+		clusterName := cluster.GetMetadata().Name()
+		ch <- prometheus.MustNewConstMetric(unregisteredShardsDesc, prometheus.GaugeValue, float64(len(snapshot.FindUnregisteredShards())), clusterName)
+		ch <- prometheus.MustNewConstMetric(unreadyShardsDesc, prometheus.GaugeValue, float64(len(snapshot.FindUnreadyShards())), clusterName)
+		ch <- prometheus.MustNewConstMetric(noEligibleNodeShardsDesc, prometheus.GaugeValue, float64(len(snapshot.FindShardsWithoutEligibleNode(now))), clusterName)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cluster_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnoseCollector(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	s, kv, client, closeSrv := newTestStorage(t)
+	defer closeSrv()
+	manager, err := newClusterManagerWithStorage(s, kv, client)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	testCreateCluster(ctx, re, manager, cluster1)
+	testRegisterNode(ctx, re, manager, cluster1, node1)
+	testRegisterNode(ctx, re, manager, cluster1, node2)
+	testInitShardView(ctx, re, manager, cluster1)
+
+	// The nodes report no shard, so all the shards are unregistered.
+	expected := fmt.Sprintf(`
+# HELP horaemeta_cluster_no_eligible_node_shards Number of the shards which can't be scheduled because no eligible node is online.
+# TYPE horaemeta_cluster_no_eligible_node_shards gauge
+horaemeta_cluster_no_eligible_node_shards{cluster="%s"} 0
+# HELP horaemeta_cluster_unready_shards Number of the shards reported by the nodes with the statuses not considered as ready.
+# TYPE horaemeta_cluster_unready_shards gauge
+horaemeta_cluster_unready_shards{cluster="%s"} 0
+# HELP horaemeta_cluster_unregistered_shards Number of the shards not reported by any registered node.
+# TYPE horaemeta_cluster_unregistered_shards gauge
+horaemeta_cluster_unregistered_shards{cluster="%s"} %d
+`, cluster1, cluster1, cluster1, defaultShardTotal)
+	re.NoError(testutil.CollectAndCompare(cluster.NewDiagnoseCollector(manager), strings.NewReader(expected)))
+
+	re.NoError(manager.Stop(ctx))
+}
@@ -20,6 +20,7 @@
 package config
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"math"
@@ -27,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/caarlos0/env/v6"
 	"github.com/pelletier/go-toml/v2"
@@ -41,6 +43,10 @@ const (
 	defaultEtcdKeyPath          = ""
 	defaultEtcdCertPath         = ""
 
+	defaultLimiterCost int = 1
+	// The partition table creation spawns many sub tables, so it costs more tokens.
+	defaultPartitionTableLimiterCost int = 10
+
 	defaultEnableLimiter          bool  = true
 	defaultInitialLimiterCapacity int   = 100 * 1000
 	defaultInitialLimiterRate     int   = 10 * 1000
@@ -48,14 +54,23 @@ const (
 	defaultCallTimeoutMs                = 5 * 1000
 	defaultEtcdMaxTxnOps                = 128
 	defaultEtcdLeaseTTLSec              = 10
+	// The metadata reads and writes are not bounded by default, which keeps the behavior of the existing deployments.
+	defaultEtcdReadTimeoutMs  int64 = 0
+	defaultEtcdWriteTimeoutMs int64 = 0
 
 	defaultGrpcHandleTimeoutMs int = 60 * 1000
+	// defaultHeartbeatErrorBackoffMs is the suggested backoff of the heartbeat when the server is degraded.
+	defaultHeartbeatErrorBackoffMs int64 = 3 * 1000
+	// defaultSlowRequestThresholdMs is the latency beyond which the http/grpc request is logged as a slow one.
+	defaultSlowRequestThresholdMs int64 = 1000
 	// GrpcServiceMaxSendMsgSize controls the max size of the sent message(200MB by default).
 	defaultGrpcServiceMaxSendMsgSize int = 200 * 1024 * 1024
 	// GrpcServiceMaxRecvMsgSize controls the max size of the received message(100MB by default).
 	defaultGrpcServiceMaxRecvMsgSize int = 100 * 1024 * 1024
 	// GrpcServiceKeepAlivePingMinIntervalSec controls the min interval for one keepalive ping.
 	defaultGrpcServiceKeepAlivePingMinIntervalSec int = 20
+	// GrpcServiceMaxConcurrentStreams controls the max number of concurrent streams of a client connection.
+	defaultGrpcServiceMaxConcurrentStreams int = 1024
 
 	defaultNodeNamePrefix          = "horaemeta"
 	defaultEndpoint                = "127.0.0.1"
@@ -83,8 +98,37 @@ const (
 	defaultClusterShardTotal = 8
 	enableSchedule           = true
 	// topologyType is used to determine the scheduling cluster strategy of HoraeMeta. It should be determined according to the storage method of HoraeDB. The default is static to support local storage.
-	defaultTopologyType                = "static"
-	defaultProcedureExecutingBatchSize = math.MaxUint32
+	defaultTopologyType                         = "static"
+	defaultProcedureExecutingBatchSize          = math.MaxUint32
+	defaultSchedulerConcurrency                 = 4
+	defaultMaxShardVersionDelta                 = 1000
+	defaultReadyShardStatus                     = "ready"
+	defaultNodePickerHashFunction               = "murmur3"
+	defaultNodePickerHashSeed                   = 0
+	defaultEnableSchemaAutoCreation             = true
+	defaultEnableProcedureCheckpoint            = false
+	defaultCreateTableOfflineShard              = "fail"
+	defaultProcedureRetentionSec                = 7 * 24 * 3600
+	defaultProcedurePurgeIntervalSec            = 3600
+	defaultEnableNodeCleanup                    = true
+	defaultExpiredNodeRetentionSec              = 24 * 3600
+	defaultUnknownClusterAutoCreation           = false
+	defaultUnknownClusterErrorWindow            = 60
+	defaultShardOscillationMaxMoves             = 0
+	defaultShardOscillationWindowSec            = 600
+	defaultPreferredLeaderStabilizationDelaySec = 60
+	defaultNodeStatsHistoryCapacity             = 360
+	defaultNodeStatsHistoryIntervalSec          = 60
+	defaultMaxInflightCreatesPerShard           = 0
+	defaultMaxPartitionSubTables                = 0
+	defaultSubTableDispatchConcurrency          = 0
+	defaultEnableStaleRouteFallback             = false
+	defaultStaleRouteMaxAgeSec                  = 3600
+	defaultEnableSafeMode                       = false
+	defaultEnableAdaptiveFlowLimiter            = false
+	defaultAdaptiveLatencyThresholdMs           = 500
+	defaultAdaptiveMinLimitPercent              = 10
+	defaultAdaptiveProbeIntervalMs              = 1000
 
 	defaultHTTPPort = 8080
 	defaultGrpcPort = 2379
@@ -104,6 +148,14 @@ type LimiterConfig struct {
 	Limit int `toml:"limit" env:"FLOW_LIMITER_LIMIT"`
 	// Burst is the maximum number of tokens.
 	Burst int `toml:"burst" env:"FLOW_LIMITER_BURST"`
+	// CreateTableCost is the number of tokens consumed by creating a normal table.
+	CreateTableCost int `toml:"create-table-cost" env:"FLOW_LIMITER_CREATE_TABLE_COST"`
+	// CreatePartitionTableCost is the number of tokens consumed by creating a partition table.
+	CreatePartitionTableCost int `toml:"create-partition-table-cost" env:"FLOW_LIMITER_CREATE_PARTITION_TABLE_COST"`
+	// DropTableCost is the number of tokens consumed by dropping a table.
+	DropTableCost int `toml:"drop-table-cost" env:"FLOW_LIMITER_DROP_TABLE_COST"`
+	// RouteTablesCost is the number of tokens consumed by routing tables.
+	RouteTablesCost int `toml:"route-tables-cost" env:"FLOW_LIMITER_ROUTE_TABLES_COST"`
 }
 
 // Config is server start config, it has three input modes:
@@ -123,12 +175,22 @@ type Config struct {
 
 	EtcdStartTimeoutMs int64 `toml:"etcd-start-timeout-ms" env:"ETCD_START_TIMEOUT_MS"`
 	EtcdCallTimeoutMs  int64 `toml:"etcd-call-timeout-ms" env:"ETCD_CALL_TIMEOUT_MS"`
+	// EtcdReadTimeoutMs bounds a single get or scan issued by the metadata storage, zero means no timeout.
+	EtcdReadTimeoutMs int64 `toml:"etcd-read-timeout-ms" env:"ETCD_READ_TIMEOUT_MS"`
+	// EtcdWriteTimeoutMs bounds a single put, delete or txn issued by the metadata storage, zero means no timeout.
+	EtcdWriteTimeoutMs int64 `toml:"etcd-write-timeout-ms" env:"ETCD_WRITE_TIMEOUT_MS"`
 	EtcdMaxTxnOps      int64 `toml:"etcd-max-txn-ops" env:"ETCD_MAX_TXN_OPS"`
 
 	GrpcHandleTimeoutMs                    int `toml:"grpc-handle-timeout-ms" env:"GRPC_HANDLER_TIMEOUT_MS"`
 	GrpcServiceMaxSendMsgSize              int `toml:"grpc-service-max-send-msg-size" env:"GRPC_SERVICE_MAX_SEND_MSG_SIZE"`
 	GrpcServiceMaxRecvMsgSize              int `toml:"grpc-service-max-recv-msg-size" env:"GRPC_SERVICE_MAX_RECV_MSG_SIZE"`
 	GrpcServiceKeepAlivePingMinIntervalSec int `toml:"grpc-service-keep-alive-ping-min-interval-sec" env:"GRPC_SERVICE_KEEP_ALIVE_PING_MIN_INTERVAL_SEC"`
+	// GrpcServiceMaxConcurrentStreams limits the concurrent streams of a client connection to protect the server from being exhausted by a single client.
+	GrpcServiceMaxConcurrentStreams int `toml:"grpc-service-max-concurrent-streams" env:"GRPC_SERVICE_MAX_CONCURRENT_STREAMS"`
+	// HeartbeatErrorBackoffMs is the backoff suggested to the nodes when the heartbeat fails or the server is overloaded, zero disables the suggestion.
+	HeartbeatErrorBackoffMs int64 `toml:"heartbeat-error-backoff-ms" env:"HEARTBEAT_ERROR_BACKOFF_MS"`
+	// SlowRequestThresholdMs is the latency beyond which the http/grpc request is logged as a warning, zero disables the logging.
+	SlowRequestThresholdMs int64 `toml:"slow-request-threshold-ms" env:"SLOW_REQUEST_THRESHOLD_MS"`
 
 	LeaseTTLSec int64 `toml:"lease-sec" env:"LEASE_SEC"`
 
@@ -139,6 +201,10 @@ type Config struct {
 	InitialCluster      string `toml:"initial-cluster" env:"INITIAL_CLUSTER"`
 	InitialClusterState string `toml:"initial-cluster-state" env:"INITIAL_CLUSTER_STATE"`
 	InitialClusterToken string `toml:"initial-cluster-token" env:"INITIAL_CLUSTER_TOKEN"`
+	// ClusterKeyPrefixes isolates the metadata keys of the clusters in the shared etcd, formatted as `clusterName=keyPrefix`, e.g. `defaultCluster=tenantA`.
+	// The keys of a cluster listed are placed under `{storage-root-path}/tenant/{keyPrefix}`, and the key prefixes must be unique.
+	// The key prefix of an existing cluster must not be changed, otherwise its metadata can't be found.
+	ClusterKeyPrefixes []string `toml:"cluster-key-prefixes" env:"CLUSTER_KEY_PREFIXES"`
 	// TickInterval is the interval for etcd Raft tick.
 	TickIntervalMs    int64 `toml:"tick-interval-ms" env:"TICK_INTERVAL_MS"`
 	ElectionTimeoutMs int64 `toml:"election-timeout-ms" env:"ELECTION_TIMEOUT_MS"`
@@ -171,6 +237,95 @@ type Config struct {
 	TopologyType string `toml:"topology-type" env:"TOPOLOGY_TYPE"`
 	// ProcedureExecutingBatchSize determines the maximum number of shards in a single batch when opening shards concurrently.
 	ProcedureExecutingBatchSize uint32 `toml:"procedure-executing-batch-size" env:"PROCEDURE_EXECUTING_BATCH_SIZE"`
+	// SchedulerConcurrency determines the max number of schedulers running concurrently in a cluster.
+	SchedulerConcurrency int `toml:"scheduler-concurrency" env:"SCHEDULER_CONCURRENCY"`
+	// MaxShardVersionDelta determines the max delta of the shard version in a single table operation, the larger jump is rejected as a bug and zero disables the check.
+	MaxShardVersionDelta uint64 `toml:"max-shard-version-delta" env:"MAX_SHARD_VERSION_DELTA"`
+	// ReadyShardStatuses determines the shard statuses considered as ready by the schedulers and the diagnosis, the valid statuses are "ready" and "partialOpen".
+	// Accepting "partialOpen" stops reopening the partially opened shards, so the tables failed to open stay unavailable until the shards are reopened manually.
+	ReadyShardStatuses []string `toml:"ready-shard-statuses" env:"READY_SHARD_STATUSES"`
+	// NodePickerHashFunction determines the hash function used to place the shards on the nodes, the valid functions are "murmur3" and "fnv1a".
+	// Changing the hash function or the seed reshuffles the placement of the shards, which leads to a lot of shard migrations in the dynamic topology.
+	NodePickerHashFunction string `toml:"node-picker-hash-function" env:"NODE_PICKER_HASH_FUNCTION"`
+	// NodePickerHashSeed is the seed of the node picker hash function, the same function and seed always reproduce the same placement.
+	NodePickerHashSeed uint32 `toml:"node-picker-hash-seed" env:"NODE_PICKER_HASH_SEED"`
+	// EnableSchemaAutoCreation determines whether the unknown schema is created implicitly when allocating its id, otherwise the schema must be created explicitly in advance.
+	EnableSchemaAutoCreation bool `toml:"enable-schema-auto-creation" env:"ENABLE_SCHEMA_AUTO_CREATION"`
+	// ProcedureRetentionSec determines how long the finished procedures are kept in the storage before being purged.
+	ProcedureRetentionSec int64 `toml:"procedure-retention-sec" env:"PROCEDURE_RETENTION_SEC"`
+	// ProcedurePurgeIntervalSec determines the interval of purging the finished procedures, the purging is disabled if it is not positive.
+	ProcedurePurgeIntervalSec int64 `toml:"procedure-purge-interval-sec" env:"PROCEDURE_PURGE_INTERVAL_SEC"`
+	// EnableProcedureCheckpoint determines whether every fsm state transition of the procedures is persisted, so that the new leader resumes the unfinished procedures
+	// instead of abandoning them. The create table and drop table procedures are resumed from the last committed state, and the others are marked as failed.
+	EnableProcedureCheckpoint bool `toml:"enable-procedure-checkpoint" env:"ENABLE_PROCEDURE_CHECKPOINT"`
+	// CreateTableOfflineShardPolicy determines how the table creation behaves if the leader node of the picked shard is offline before
+	// the create table procedure is submitted. The valid policies are "fail", rejecting the creation, and "repick", creating the table on
+	// another shard whose leader node is alive instead. The submitted procedure always fails if the leader goes offline afterwards.
+	CreateTableOfflineShardPolicy string `toml:"create-table-offline-shard-policy" env:"CREATE_TABLE_OFFLINE_SHARD_POLICY"`
+	// ShardPickers selects the shard picker used when creating tables of the clusters, formatted as `clusterName=shardPicker`, e.g. `defaultCluster=least_table`.
+	// The clusters not listed use the default picker "least_table", picking the shards with the least tables.
+	ShardPickers []string `toml:"shard-pickers" env:"SHARD_PICKERS"`
+	// ProcedureTimeouts bounds the execution of the procedures by their kinds, formatted as `kind=duration`, e.g. `split=30m`. The procedure is cancelled
+	// if it is not finished in time, and the procedures of the kinds not listed are unbounded. No procedure is bounded by default.
+	// The createPartitionTable procedure can't be bounded, because it is not rolled back when cancelled halfway.
+	ProcedureTimeouts []string `toml:"procedure-timeouts" env:"PROCEDURE_TIMEOUTS"`
+	// RecentErrorsCapacity determines how many recent errors of the http handlers, the grpc handlers and the procedures are kept in memory for triage.
+	RecentErrorsCapacity int `toml:"recent-errors-capacity" env:"RECENT_ERRORS_CAPACITY"`
+	// EnableNodeCleanup determines whether the nodes expired longer than the ExpiredNodeRetentionSec are removed from the registered nodes automatically.
+	// It can be disabled to keep the expired nodes for debugging.
+	EnableNodeCleanup bool `toml:"enable-node-cleanup" env:"ENABLE_NODE_CLEANUP"`
+	// ExpiredNodeRetentionSec determines how long the expired nodes are kept before being removed, and the nodes still holding shards are always kept.
+	ExpiredNodeRetentionSec int64 `toml:"expired-node-retention-sec" env:"EXPIRED_NODE_RETENTION_SEC"`
+	// EnableUnknownClusterAutoCreation determines whether the cluster unknown to the heartbeat of a node is created with the settings of the default cluster,
+	// otherwise the heartbeat is rejected.
+	EnableUnknownClusterAutoCreation bool `toml:"enable-unknown-cluster-auto-creation" env:"ENABLE_UNKNOWN_CLUSTER_AUTO_CREATION"`
+	// UnknownClusterErrorWindowSec determines the window in which the repeated rejections of the heartbeats from the same node to the same unknown cluster
+	// are only logged and recorded once, zero disables the suppression.
+	UnknownClusterErrorWindowSec int64 `toml:"unknown-cluster-error-window-sec" env:"UNKNOWN_CLUSTER_ERROR_WINDOW_SEC"`
+	// ShardOscillationMaxMoves determines the max number of the leader moves of a shard in the ShardOscillationWindowSec, and the shard moving more
+	// frequently is considered oscillating, and its leader is not moved between the alive nodes until it calms down, but it is still recovered if
+	// it is unassigned, unopened or its node is dead. Zero disables the detection, which is the default.
+	ShardOscillationMaxMoves uint32 `toml:"shard-oscillation-max-moves" env:"SHARD_OSCILLATION_MAX_MOVES"`
+	// ShardOscillationWindowSec determines the window in which the leader moves of a shard are counted for the oscillation detection.
+	ShardOscillationWindowSec int64 `toml:"shard-oscillation-window-sec" env:"SHARD_OSCILLATION_WINDOW_SEC"`
+	// PreferredLeaderStabilizationDelaySec determines how long the node of a preferred leader must stay online before the leadership is moved back
+	// to it, so that a flapping node won't cause the leadership to ping-pong. Zero moves the leadership back immediately.
+	PreferredLeaderStabilizationDelaySec int64 `toml:"preferred-leader-stabilization-delay-sec" env:"PREFERRED_LEADER_STABILIZATION_DELAY_SEC"`
+	// NodeStatsHistoryCapacity determines the max number of the utilization samples kept in memory for every node, and the oldest samples are
+	// overwritten once it is full. Zero disables the history. The history is not persisted, so it starts empty on the new leader.
+	NodeStatsHistoryCapacity int `toml:"node-stats-history-capacity" env:"NODE_STATS_HISTORY_CAPACITY"`
+	// NodeStatsHistoryIntervalSec determines the min interval of sampling the utilization of a node from its heartbeats.
+	NodeStatsHistoryIntervalSec int64 `toml:"node-stats-history-interval-sec" env:"NODE_STATS_HISTORY_INTERVAL_SEC"`
+	// MaxInflightCreatesPerShard determines the max number of the table creations in flight on a shard, and the shards reaching it are skipped
+	// when picking the shards for the new tables. Zero means unlimited.
+	MaxInflightCreatesPerShard uint32 `toml:"max-inflight-creates-per-shard" env:"MAX_INFLIGHT_CREATES_PER_SHARD"`
+	// MaxPartitionSubTables determines the max number of the sub tables of a partition table, and the creation of the partition table with
+	// more sub tables is rejected before any shard is picked. Zero means unlimited.
+	MaxPartitionSubTables uint32 `toml:"max-partition-sub-tables" env:"MAX_PARTITION_SUB_TABLES"`
+	// SubTableDispatchConcurrency determines the max number of the shards creating the sub tables of a partition table concurrently, zero
+	// means unlimited. The sub tables on the same shard are always created one by one.
+	SubTableDispatchConcurrency uint32 `toml:"sub-table-dispatch-concurrency" env:"SUB_TABLE_DISPATCH_CONCURRENCY"`
+	// EnableStaleRouteFallback determines whether the last known routes of the tables are served with a staleness marker when the leader is
+	// unavailable. The errors returned by the leader, e.g. the table is not found, are never covered. The routing fails as before if it is disabled.
+	EnableStaleRouteFallback bool `toml:"enable-stale-route-fallback" env:"ENABLE_STALE_ROUTE_FALLBACK"`
+	// StaleRouteMaxAgeSec determines the max age of the last known routes served by the fallback, zero means unlimited.
+	StaleRouteMaxAgeSec int64 `toml:"stale-route-max-age-sec" env:"STALE_ROUTE_MAX_AGE_SEC"`
+	// EnableSafeMode disables the destructive http apis, e.g. dropping tables, dropping node shards, dropping the tables of a schema, removing
+	// etcd members, purging procedures, clearing table assignments, injecting faults and the debug apis closing tables or shards, advancing shard
+	// versions and expiring nodes, and they are rejected with the "disabled in safe mode" error. The read-only and non-destructive apis are not affected.
+	EnableSafeMode bool `toml:"enable-safe-mode" env:"ENABLE_SAFE_MODE"`
+	// ProcedureStorageEtcdPrefix replaces the cluster root path of the procedure keys in etcd if it is not empty, so that the procedure churn is
+	// kept apart from the metadata keyspace. The server refuses to start if it is changed while any procedure is left under the previous path.
+	ProcedureStorageEtcdPrefix string `toml:"procedure-storage-etcd-prefix" env:"PROCEDURE_STORAGE_ETCD_PREFIX"`
+	// EnableAdaptiveFlowLimiter determines whether the rate of the flow limiter is reduced when the latency of etcd probed every
+	// AdaptiveProbeIntervalMs exceeds AdaptiveLatencyThresholdMs, and restored gradually when the latency is back to normal.
+	EnableAdaptiveFlowLimiter bool `toml:"enable-adaptive-flow-limiter" env:"ENABLE_ADAPTIVE_FLOW_LIMITER"`
+	// AdaptiveLatencyThresholdMs is the etcd latency beyond which the rate of the flow limiter is reduced.
+	AdaptiveLatencyThresholdMs int64 `toml:"adaptive-latency-threshold-ms" env:"ADAPTIVE_LATENCY_THRESHOLD_MS"`
+	// AdaptiveMinLimitPercent is the lower bound of the reduced rate in percent of the configured one, in [1, 100].
+	AdaptiveMinLimitPercent int `toml:"adaptive-min-limit-percent" env:"ADAPTIVE_MIN_LIMIT_PERCENT"`
+	// AdaptiveProbeIntervalMs is the interval of probing the etcd latency.
+	AdaptiveProbeIntervalMs int64 `toml:"adaptive-probe-interval-ms" env:"ADAPTIVE_PROBE_INTERVAL_MS"`
 
 	ClientUrls          string `toml:"client-urls" env:"CLIENT_URLS"`
 	PeerUrls            string `toml:"peer-urls" env:"PEER_URLS"`
@@ -179,12 +334,26 @@ type Config struct {
 
 	HTTPPort int `toml:"http-port" env:"HTTP_PORT"`
 	GrpcPort int `toml:"grpc-port" env:"GRPC_PORT"`
+
+	// EnableFaultInjection allows to fail procedure steps through the debug api, it must only be enabled for testing.
+	EnableFaultInjection bool `toml:"enable-fault-injection" env:"ENABLE_FAULT_INJECTION"`
+
+	// sources records where the config items not using the default values come from, tomlKey -> source.
+	sources map[string]Source
 }
 
 func (c *Config) GrpcHandleTimeout() time.Duration {
 	return time.Duration(c.GrpcHandleTimeoutMs) * time.Millisecond
 }
 
+func (c *Config) HeartbeatErrorBackoff() time.Duration {
+	return time.Duration(c.HeartbeatErrorBackoffMs) * time.Millisecond
+}
+
+func (c *Config) SlowRequestThreshold() time.Duration {
+	return time.Duration(c.SlowRequestThresholdMs) * time.Millisecond
+}
+
 func (c *Config) EtcdStartTimeout() time.Duration {
 	return time.Duration(c.EtcdStartTimeoutMs) * time.Millisecond
 }
@@ -193,9 +362,96 @@ func (c *Config) EtcdCallTimeout() time.Duration {
 	return time.Duration(c.EtcdCallTimeoutMs) * time.Millisecond
 }
 
+func (c *Config) EtcdReadTimeout() time.Duration {
+	return time.Duration(c.EtcdReadTimeoutMs) * time.Millisecond
+}
+
+func (c *Config) EtcdWriteTimeout() time.Duration {
+	return time.Duration(c.EtcdWriteTimeoutMs) * time.Millisecond
+}
+
+func (c *Config) ProcedureRetention() time.Duration {
+	return time.Duration(c.ProcedureRetentionSec) * time.Second
+}
+
+func (c *Config) ProcedurePurgeInterval() time.Duration {
+	return time.Duration(c.ProcedurePurgeIntervalSec) * time.Second
+}
+
+func (c *Config) ExpiredNodeRetention() time.Duration {
+	return time.Duration(c.ExpiredNodeRetentionSec) * time.Second
+}
+
+func (c *Config) UnknownClusterErrorWindow() time.Duration {
+	return time.Duration(c.UnknownClusterErrorWindowSec) * time.Second
+}
+
+func (c *Config) StaleRouteMaxAge() time.Duration {
+	return time.Duration(c.StaleRouteMaxAgeSec) * time.Second
+}
+
+func (c *Config) ShardOscillationWindow() time.Duration {
+	return time.Duration(c.ShardOscillationWindowSec) * time.Second
+}
+
+func (c *Config) PreferredLeaderStabilizationDelay() time.Duration {
+	return time.Duration(c.PreferredLeaderStabilizationDelaySec) * time.Second
+}
+
+func (c *Config) NodeStatsHistoryInterval() time.Duration {
+	return time.Duration(c.NodeStatsHistoryIntervalSec) * time.Second
+}
+
+func (c *Config) AdaptiveLatencyThreshold() time.Duration {
+	return time.Duration(c.AdaptiveLatencyThresholdMs) * time.Millisecond
+}
+
+func (c *Config) AdaptiveProbeInterval() time.Duration {
+	return time.Duration(c.AdaptiveProbeIntervalMs) * time.Millisecond
+}
+
 // ValidateAndAdjust validates the config fields and adjusts some fields which should be adjusted.
 // Return error if any field is invalid.
 func (c *Config) ValidateAndAdjust() error {
+	if c.GrpcServiceMaxConcurrentStreams <= 0 || c.GrpcServiceMaxConcurrentStreams > math.MaxUint32 {
+		return ErrInvalidConfig.WithCausef("grpc-service-max-concurrent-streams must be positive and fit in uint32, value:%d", c.GrpcServiceMaxConcurrentStreams)
+	}
+	if c.EtcdReadTimeoutMs < 0 {
+		return ErrInvalidConfig.WithCausef("etcd-read-timeout-ms must not be negative, value:%d", c.EtcdReadTimeoutMs)
+	}
+	if c.EtcdWriteTimeoutMs < 0 {
+		return ErrInvalidConfig.WithCausef("etcd-write-timeout-ms must not be negative, value:%d", c.EtcdWriteTimeoutMs)
+	}
+	if c.MinScanLimit < 1 {
+		return ErrInvalidConfig.WithCausef("min-scan-limit must be positive, value:%d", c.MinScanLimit)
+	}
+	if c.MaxScanLimit <= 1 {
+		return ErrInvalidConfig.WithCausef("max-scan-limit must be greater than 1, value:%d", c.MaxScanLimit)
+	}
+	if c.MinScanLimit > c.MaxScanLimit {
+		return ErrInvalidConfig.WithCausef("min-scan-limit must not be greater than max-scan-limit, min:%d, max:%d", c.MinScanLimit, c.MaxScanLimit)
+	}
+	if c.EnableNodeCleanup && c.ExpiredNodeRetentionSec <= 0 {
+		return ErrInvalidConfig.WithCausef("expired-node-retention-sec must be positive if the node cleanup is enabled, value:%d", c.ExpiredNodeRetentionSec)
+	}
+	if c.PreferredLeaderStabilizationDelaySec < 0 {
+		return ErrInvalidConfig.WithCausef("preferred-leader-stabilization-delay-sec must not be negative, value:%d", c.PreferredLeaderStabilizationDelaySec)
+	}
+	if c.RecentErrorsCapacity <= 0 {
+		return ErrInvalidConfig.WithCausef("recent-errors-capacity must be positive, value:%d", c.RecentErrorsCapacity)
+	}
+	if c.EnableAdaptiveFlowLimiter {
+		if c.AdaptiveLatencyThresholdMs <= 0 {
+			return ErrInvalidConfig.WithCausef("adaptive-latency-threshold-ms must be positive, value:%d", c.AdaptiveLatencyThresholdMs)
+		}
+		if c.AdaptiveProbeIntervalMs <= 0 {
+			return ErrInvalidConfig.WithCausef("adaptive-probe-interval-ms must be positive, value:%d", c.AdaptiveProbeIntervalMs)
+		}
+		if c.AdaptiveMinLimitPercent < 1 || c.AdaptiveMinLimitPercent > 100 {
+			return ErrInvalidConfig.WithCausef("adaptive-min-limit-percent must be in [1, 100], value:%d", c.AdaptiveMinLimitPercent)
+		}
+	}
+
 	return nil
 }
 
@@ -251,6 +507,8 @@ type Parser struct {
 	cfg            *Config
 	configFilePath string
 	version        *bool
+	// strictConfig makes the parsing of the toml config file fail if there are any unknown keys.
+	strictConfig *bool
 }
 
 func (p *Parser) Parse(arguments []string) (*Config, error) {
@@ -300,6 +558,11 @@ func MakeConfigParser() (*Parser, error) {
 			Enable: defaultEnableLimiter,
 			Limit:  defaultInitialLimiterRate,
 			Burst:  defaultInitialLimiterCapacity,
+
+			CreateTableCost:          defaultLimiterCost,
+			CreatePartitionTableCost: defaultPartitionTableLimiterCost,
+			DropTableCost:            defaultLimiterCost,
+			RouteTablesCost:          defaultLimiterCost,
 		},
 
 		EnableEmbedEtcd: defaultEnableEmbedEtcd,
@@ -309,12 +572,17 @@ func MakeConfigParser() (*Parser, error) {
 
 		EtcdStartTimeoutMs: defaultEtcdStartTimeoutMs,
 		EtcdCallTimeoutMs:  defaultCallTimeoutMs,
+		EtcdReadTimeoutMs:  defaultEtcdReadTimeoutMs,
+		EtcdWriteTimeoutMs: defaultEtcdWriteTimeoutMs,
 		EtcdMaxTxnOps:      defaultEtcdMaxTxnOps,
 
 		GrpcHandleTimeoutMs:                    defaultGrpcHandleTimeoutMs,
 		GrpcServiceMaxSendMsgSize:              defaultGrpcServiceMaxSendMsgSize,
 		GrpcServiceMaxRecvMsgSize:              defaultGrpcServiceMaxRecvMsgSize,
 		GrpcServiceKeepAlivePingMinIntervalSec: defaultGrpcServiceKeepAlivePingMinIntervalSec,
+		GrpcServiceMaxConcurrentStreams:        defaultGrpcServiceMaxConcurrentStreams,
+		HeartbeatErrorBackoffMs:                defaultHeartbeatErrorBackoffMs,
+		SlowRequestThresholdMs:                 defaultSlowRequestThresholdMs,
 
 		LeaseTTLSec: defaultEtcdLeaseTTLSec,
 
@@ -326,6 +594,7 @@ func MakeConfigParser() (*Parser, error) {
 		InitialCluster:      defaultInitialCluster,
 		InitialClusterState: defaultInitialClusterState,
 		InitialClusterToken: defaultInitialClusterToken,
+		ClusterKeyPrefixes:  []string{},
 
 		ClientUrls:          defaultClientUrls,
 		AdvertiseClientUrls: defaultClientUrls,
@@ -350,18 +619,66 @@ func MakeConfigParser() (*Parser, error) {
 		EnableSchedule:              enableSchedule,
 		TopologyType:                defaultTopologyType,
 		ProcedureExecutingBatchSize: defaultProcedureExecutingBatchSize,
+		SchedulerConcurrency:        defaultSchedulerConcurrency,
+		MaxShardVersionDelta:        defaultMaxShardVersionDelta,
+		ReadyShardStatuses:          []string{defaultReadyShardStatus},
+		NodePickerHashFunction:      defaultNodePickerHashFunction,
+		NodePickerHashSeed:          defaultNodePickerHashSeed,
+		EnableSchemaAutoCreation:    defaultEnableSchemaAutoCreation,
+		ProcedureRetentionSec:       defaultProcedureRetentionSec,
+		ProcedurePurgeIntervalSec:   defaultProcedurePurgeIntervalSec,
+		EnableProcedureCheckpoint:   defaultEnableProcedureCheckpoint,
+		ProcedureTimeouts:           []string{},
+		RecentErrorsCapacity:        coderr.DefaultRecentErrorsCapacity,
+		EnableNodeCleanup:           defaultEnableNodeCleanup,
+		ExpiredNodeRetentionSec:     defaultExpiredNodeRetentionSec,
+
+		EnableUnknownClusterAutoCreation: defaultUnknownClusterAutoCreation,
+		UnknownClusterErrorWindowSec:     defaultUnknownClusterErrorWindow,
+
+		ShardOscillationMaxMoves:             defaultShardOscillationMaxMoves,
+		ShardOscillationWindowSec:            defaultShardOscillationWindowSec,
+		PreferredLeaderStabilizationDelaySec: defaultPreferredLeaderStabilizationDelaySec,
+
+		NodeStatsHistoryCapacity:    defaultNodeStatsHistoryCapacity,
+		NodeStatsHistoryIntervalSec: defaultNodeStatsHistoryIntervalSec,
+
+		MaxInflightCreatesPerShard:  defaultMaxInflightCreatesPerShard,
+		MaxPartitionSubTables:       defaultMaxPartitionSubTables,
+		SubTableDispatchConcurrency: defaultSubTableDispatchConcurrency,
+
+		EnableStaleRouteFallback: defaultEnableStaleRouteFallback,
+		StaleRouteMaxAgeSec:      defaultStaleRouteMaxAgeSec,
+
+		EnableSafeMode: defaultEnableSafeMode,
+
+		ProcedureStorageEtcdPrefix: "",
+
+		EnableAdaptiveFlowLimiter:  defaultEnableAdaptiveFlowLimiter,
+		AdaptiveLatencyThresholdMs: defaultAdaptiveLatencyThresholdMs,
+		AdaptiveMinLimitPercent:    defaultAdaptiveMinLimitPercent,
+		AdaptiveProbeIntervalMs:    defaultAdaptiveProbeIntervalMs,
+
+		CreateTableOfflineShardPolicy: defaultCreateTableOfflineShard,
+		ShardPickers:                  []string{},
 
 		HTTPPort: defaultHTTPPort,
 		GrpcPort: defaultGrpcPort,
+
+		EnableFaultInjection: false,
+
+		sources: map[string]Source{},
 	}
 
 	version := fs.Bool("version", false, "print version information")
+	strictConfig := fs.Bool("strict-config", false, "fail to start if there are unknown keys in the config file, otherwise only log a warning")
 
 	builder := &Parser{
 		flagSet:        fs,
 		cfg:            cfg,
 		version:        version,
 		configFilePath: "",
+		strictConfig:   strictConfig,
 	}
 
 	fs.StringVar(&builder.configFilePath, "config", "", "config file path")
@@ -384,19 +701,56 @@ func (p *Parser) ParseConfigFromToml() error {
 	}
 	log.Info("toml config value", zap.String("config", string(file)))
 
-	err = toml.Unmarshal(file, p.cfg)
+	// Unknown keys are always detected, and whether they are treated as an error depends on the strict-config flag.
+	err = toml.NewDecoder(bytes.NewReader(file)).DisallowUnknownFields().Decode(p.cfg)
 	if err != nil {
-		log.Error("err", zap.Error(err))
-		return errors.WithMessagef(err, "unmarshal toml config, configFile:%s", p.configFilePath)
+		var strictMissingErr *toml.StrictMissingError
+		if !errors.As(err, &strictMissingErr) {
+			log.Error("err", zap.Error(err))
+			return errors.WithMessagef(err, "unmarshal toml config, configFile:%s", p.configFilePath)
+		}
+
+		unknownKeys := make([]string, 0, len(strictMissingErr.Errors))
+		for _, decodeErr := range strictMissingErr.Errors {
+			unknownKeys = append(unknownKeys, strings.Join(decodeErr.Key(), "."))
+		}
+		if *p.strictConfig {
+			return ErrUnknownConfigKeys.WithCausef("configFile:%s, unknownKeys:%v", p.configFilePath, unknownKeys)
+		}
+		log.Warn("unknown keys in config file are ignored", zap.String("configFile", p.configFilePath), zap.Strings("unknownKeys", unknownKeys))
 	}
 
+	var document map[string]interface{}
+	if err := toml.Unmarshal(file, &document); err != nil {
+		return errors.WithMessagef(err, "unmarshal toml document, configFile:%s", p.configFilePath)
+	}
+	tomlKeys := make(map[string]struct{}, len(document))
+	collectTomlKeys(document, "", tomlKeys)
+	p.cfg.markSource(SourceToml, func(field configField) bool {
+		_, ok := tomlKeys[field.key]
+		return ok
+	})
+
 	return nil
 }
 
 func (p *Parser) ParseConfigFromEnv() error {
-	err := env.Parse(p.cfg)
+	envKeys := make(map[string]struct{})
+	err := env.Parse(p.cfg, env.Options{
+		// OnSet is called for every field, and only the non-empty env variables are applied.
+		OnSet: func(tag string, value interface{}, isDefault bool) {
+			if rawValue, ok := value.(string); ok && len(rawValue) > 0 && !isDefault {
+				envKeys[tag] = struct{}{}
+			}
+		},
+	})
 	if err != nil {
 		return errors.WithMessagef(err, "parse config from env variables")
 	}
+
+	p.cfg.markSource(SourceEnv, func(field configField) bool {
+		_, ok := envKeys[field.envKey]
+		return ok && len(field.envKey) > 0
+	})
 	return nil
 }
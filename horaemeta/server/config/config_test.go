@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfigFile(t *testing.T, content string) string {
+	configFile := path.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+	return configFile
+}
+
+func TestParseConfigWithUnknownKeys(t *testing.T) {
+	re := require.New(t)
+	configFile := writeTestConfigFile(t, `
+http-port = 5000
+http-prot = 5001
+
+[flow-limiter]
+enabel = false
+`)
+
+	// Unknown keys are ignored by default, and the known keys are still applied.
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{"-config", configFile})
+	re.NoError(err)
+	re.NoError(parser.ParseConfigFromToml())
+	re.Equal(5000, cfg.HTTPPort)
+
+	// Unknown keys are rejected in strict mode.
+	parser, err = MakeConfigParser()
+	re.NoError(err)
+	_, err = parser.Parse([]string{"-config", configFile, "-strict-config"})
+	re.NoError(err)
+	err = parser.ParseConfigFromToml()
+	re.Error(err)
+	re.True(coderr.Is(err, coderr.InvalidParams))
+	re.Contains(err.Error(), "http-prot")
+	re.Contains(err.Error(), "flow-limiter.enabel")
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	re := require.New(t)
+	configFile := writeTestConfigFile(t, `
+http-port = 5000
+grpc-port = 5001
+
+[flow-limiter]
+limit = 10
+`)
+	t.Setenv("GRPC_PORT", "6001")
+	t.Setenv("FLOW_LIMITER_BURST", "20")
+
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{"-config", configFile})
+	re.NoError(err)
+	re.NoError(parser.ParseConfigFromToml())
+	re.NoError(parser.ParseConfigFromEnv())
+
+	items := make(map[string]EffectiveItem)
+	for _, item := range cfg.EffectiveConfig() {
+		items[item.Key] = item
+	}
+
+	expects := []EffectiveItem{
+		{Key: "http-port", EnvKey: "HTTP_PORT", Value: 5000, Source: SourceToml},
+		{Key: "grpc-port", EnvKey: "GRPC_PORT", Value: 6001, Source: SourceEnv},
+		{Key: "flow-limiter.limit", EnvKey: "FLOW_LIMITER_LIMIT", Value: 10, Source: SourceToml},
+		{Key: "flow-limiter.burst", EnvKey: "FLOW_LIMITER_BURST", Value: 20, Source: SourceEnv},
+		{Key: "max-scan-limit", EnvKey: "MAX_SCAN_LIMIT", Value: defaultMaxScanLimit, Source: SourceDefault},
+	}
+	for _, expect := range expects {
+		re.Equal(expect, items[expect.Key])
+	}
+}
+
+func TestValidateGrpcServiceMaxConcurrentStreams(t *testing.T) {
+	re := require.New(t)
+
+	t.Setenv("GRPC_SERVICE_MAX_CONCURRENT_STREAMS", "0")
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{})
+	re.NoError(err)
+	re.Equal(defaultGrpcServiceMaxConcurrentStreams, cfg.GrpcServiceMaxConcurrentStreams)
+	re.NoError(cfg.ValidateAndAdjust())
+
+	re.NoError(parser.ParseConfigFromEnv())
+	re.Equal(0, cfg.GrpcServiceMaxConcurrentStreams)
+	err = cfg.ValidateAndAdjust()
+	re.Error(err)
+	re.True(coderr.Is(err, ErrInvalidConfig.Code()))
+
+	cfg.GrpcServiceMaxConcurrentStreams = 128
+	re.NoError(cfg.ValidateAndAdjust())
+
+	cfg.RecentErrorsCapacity = 0
+	re.True(coderr.Is(cfg.ValidateAndAdjust(), ErrInvalidConfig.Code()))
+}
+
+func TestValidateEtcdTimeouts(t *testing.T) {
+	re := require.New(t)
+
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{})
+	re.NoError(err)
+	// The timeouts are disabled by default.
+	re.Equal(time.Duration(0), cfg.EtcdReadTimeout())
+	re.Equal(time.Duration(0), cfg.EtcdWriteTimeout())
+	re.NoError(cfg.ValidateAndAdjust())
+
+	cfg.EtcdReadTimeoutMs = -1
+	re.True(coderr.Is(cfg.ValidateAndAdjust(), ErrInvalidConfig.Code()))
+
+	cfg.EtcdReadTimeoutMs = 1000
+	cfg.EtcdWriteTimeoutMs = 5000
+	re.NoError(cfg.ValidateAndAdjust())
+
+	cfg.EtcdWriteTimeoutMs = -1
+	re.True(coderr.Is(cfg.ValidateAndAdjust(), ErrInvalidConfig.Code()))
+}
+
+func TestValidateExpiredNodeRetention(t *testing.T) {
+	re := require.New(t)
+
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{})
+	re.NoError(err)
+	re.NoError(cfg.ValidateAndAdjust())
+
+	cfg.ExpiredNodeRetentionSec = 0
+	re.ErrorIs(cfg.ValidateAndAdjust(), ErrInvalidConfig)
+
+	// The retention is not used if the node cleanup is disabled.
+	cfg.EnableNodeCleanup = false
+	re.NoError(cfg.ValidateAndAdjust())
+}
+
+func TestValidateProcedureTimeouts(t *testing.T) {
+	re := require.New(t)
+
+	parser, err := MakeConfigParser()
+	re.NoError(err)
+	cfg, err := parser.Parse([]string{})
+	re.NoError(err)
+	// No procedure is bounded by default.
+	re.Empty(cfg.ProcedureTimeouts)
+	re.NoError(cfg.ValidateAndAdjust())
+
+	cfg.ProcedureTimeouts = []string{"createTable=1m", "split=30m"}
+	re.NoError(cfg.ValidateAndAdjust())
+
+	// The timeouts are parsed by the procedure package when the server starts.
+	cfg.ProcedureTimeouts = []string{"createPartitionTable=5m"}
+	re.NoError(cfg.ValidateAndAdjust())
+}
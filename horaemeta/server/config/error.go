@@ -28,4 +28,6 @@ var (
 	ErrInvalidPeerURL     = coderr.NewCodeError(coderr.InvalidParams, "invalid peers url")
 	ErrInvalidCommandArgs = coderr.NewCodeError(coderr.InvalidParams, "invalid command arguments")
 	ErrRetrieveHostname   = coderr.NewCodeError(coderr.Internal, "retrieve local hostname")
+	ErrUnknownConfigKeys  = coderr.NewCodeError(coderr.InvalidParams, "unknown keys in config file")
+	ErrInvalidConfig      = coderr.NewCodeError(coderr.InvalidParams, "invalid config")
 )
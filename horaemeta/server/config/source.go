@@ -0,0 +1,124 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Source is where the effective value of a config item comes from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceToml    Source = "toml"
+	SourceEnv     Source = "env"
+)
+
+// EffectiveItem is a config item with its effective value and the source setting it.
+type EffectiveItem struct {
+	// Key is the dotted toml key of the item, e.g. `flow-limiter.limit`.
+	Key    string      `json:"key"`
+	EnvKey string      `json:"envKey"`
+	Value  interface{} `json:"value"`
+	Source Source      `json:"source"`
+}
+
+type configField struct {
+	key    string
+	envKey string
+	value  reflect.Value
+}
+
+// listConfigFields lists the leaf fields of the config struct with their toml keys and env keys, and the nested
+// structs are flattened.
+func listConfigFields(value reflect.Value, keyPrefix string) []configField {
+	fields := make([]configField, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if len(key) == 0 {
+			key = field.Name
+		}
+		key = keyPrefix + key
+
+		// The env keys of the nested structs are not prefixed, see the usage of `env.Parse`.
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, listConfigFields(value.Field(i), key+".")...)
+			continue
+		}
+		fields = append(fields, configField{
+			key:    key,
+			envKey: field.Tag.Get("env"),
+			value:  value.Field(i),
+		})
+	}
+	return fields
+}
+
+// collectTomlKeys collects the dotted keys of all the leaf values in the decoded toml document.
+func collectTomlKeys(document map[string]interface{}, keyPrefix string, keys map[string]struct{}) {
+	for key, value := range document {
+		if table, ok := value.(map[string]interface{}); ok {
+			collectTomlKeys(table, keyPrefix+key+".", keys)
+			continue
+		}
+		keys[keyPrefix+key] = struct{}{}
+	}
+}
+
+// markSource records the source of the config items whose key is accepted by the matcher.
+func (c *Config) markSource(source Source, matcher func(field configField) bool) {
+	for _, field := range listConfigFields(reflect.ValueOf(c).Elem(), "") {
+		if matcher(field) {
+			c.sources[field.key] = source
+		}
+	}
+}
+
+// EffectiveConfig returns all the config items with their effective values and sources, sorted by the keys.
+func (c *Config) EffectiveConfig() []EffectiveItem {
+	fields := listConfigFields(reflect.ValueOf(c).Elem(), "")
+	items := make([]EffectiveItem, 0, len(fields))
+	for _, field := range fields {
+		source, ok := c.sources[field.key]
+		if !ok {
+			source = SourceDefault
+		}
+		items = append(items, EffectiveItem{
+			Key:    field.key,
+			EnvKey: field.envKey,
+			Value:  field.value.Interface(),
+			Source: source,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items
+}
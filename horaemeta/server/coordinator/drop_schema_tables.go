@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type DropSchemaTablesResult struct {
+	NumDropped int                      `json:"numDropped"`
+	NumFailed  int                      `json:"numFailed"`
+	Tables     []DropSchemaTableOutcome `json:"tables"`
+}
+
+type DropSchemaTableOutcome struct {
+	TableName   string `json:"tableName"`
+	Partitioned bool   `json:"partitioned"`
+	Dropped     bool   `json:"dropped"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DropSchemaTables drops all the tables of the schema one by one, and the failure of a table doesn't stop dropping the
+// others. The partition tables are dropped through the partition table procedure together with their sub tables, so the
+// sub tables are not dropped separately. ErrSchemaNotFound is returned if the schema doesn't exist.
+// The tables are dropped even if the ctx is cancelled, e.g. the client of the http request goes away, so that the schema
+// is not left dropped halfway.
+func (f *Factory) DropSchemaTables(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, procedureManager procedure.Manager, schemaName string) (DropSchemaTablesResult, error) {
+	var result DropSchemaTablesResult
+	if _, err := clusterMetadata.GetTableCountOfSchema(schemaName); err != nil {
+		return result, err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	tables := clusterMetadata.GetTablesOfSchema(schemaName)
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+
+	subTableNames := make(map[string]struct{})
+	partitionTableInfos := make(map[string]*metaservicepb.PartitionTableInfo)
+	for _, table := range tables {
+		if !table.IsPartitioned() {
+			continue
+		}
+		layout, err := clusterMetadata.GetPartitionTableLayout(ctx, schemaName, table.Name)
+		if err != nil {
+			return result, errors.WithMessagef(err, "get partition table layout, table:%s", table.Name)
+		}
+		names := make([]string, 0, len(layout.SubTables))
+		for _, subTable := range layout.SubTables {
+			subTableNames[subTable.TableName] = struct{}{}
+			names = append(names, subTable.TableName)
+		}
+		partitionTableInfos[table.Name] = &metaservicepb.PartitionTableInfo{
+			PartitionInfo: table.PartitionInfo.Info,
+			SubTableNames: names,
+		}
+	}
+
+	outcomes := make([]DropSchemaTableOutcome, 0, len(tables))
+	for _, table := range tables {
+		if _, ok := subTableNames[table.Name]; ok {
+			continue
+		}
+
+		partitionTableInfo := partitionTableInfos[table.Name]
+		outcome := DropSchemaTableOutcome{
+			TableName:   table.Name,
+			Partitioned: partitionTableInfo != nil,
+			Dropped:     false,
+			Error:       "",
+		}
+		if err := f.dropSchemaTable(ctx, clusterMetadata, procedureManager, schemaName, table.Name, partitionTableInfo); err != nil {
+			f.logger.Error("drop table of schema failed", zap.String("schema", schemaName), zap.String("table", table.Name), zap.Error(err))
+			outcome.Error = err.Error()
+			result.NumFailed++
+		} else {
+			outcome.Dropped = true
+			result.NumDropped++
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	result.Tables = outcomes
+
+	return result, nil
+}
+
+// dropSchemaTable submits the drop table procedure of the table and waits for it to finish.
+func (f *Factory) dropSchemaTable(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, procedureManager procedure.Manager, schemaName, tableName string, partitionTableInfo *metaservicepb.PartitionTableInfo) error {
+	errorCh := make(chan error, 1)
+	resultCh := make(chan metadata.TableInfo, 1)
+
+	p, ok, err := f.CreateDropTableProcedure(ctx, DropTableRequest{
+		ClusterMetadata: clusterMetadata,
+		ClusterSnapshot: clusterMetadata.GetClusterSnapshot(),
+		SourceReq: &metaservicepb.DropTableRequest{
+			Header:             &metaservicepb.RequestHeader{ClusterName: clusterMetadata.Name()},
+			SchemaName:         schemaName,
+			Name:               tableName,
+			PartitionTableInfo: partitionTableInfo,
+		},
+		OnSucceeded: func(ret metadata.TableInfo) error {
+			resultCh <- ret
+			return nil
+		},
+		OnFailed: func(err error) error {
+			errorCh <- err
+			return nil
+		},
+	})
+	if err != nil {
+		return errors.WithMessage(err, "create drop table procedure")
+	}
+	// The table has been dropped already.
+	if !ok {
+		return nil
+	}
+
+	if err := procedureManager.Submit(ctx, p); err != nil {
+		return errors.WithMessage(err, "submit drop table procedure")
+	}
+
+	select {
+	case <-resultCh:
+		return nil
+	case err := <-errorCh:
+		return err
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDropSchemaTables(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), m, procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(procedureManager.Start(ctx))
+	defer func() {
+		re.NoError(procedureManager.Stop(ctx))
+	}()
+
+	var shardID storage.ShardID
+	for id := range m.GetClusterSnapshot().Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		_, err := m.CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       shardID,
+			LatestVersion: 0,
+			SchemaName:    test.TestSchemaName,
+			TableName:     tableName,
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	// The unknown schema is rejected.
+	_, err = f.DropSchemaTables(ctx, m, procedureManager, "unknown")
+	re.True(coderr.Is(err, metadata.ErrSchemaNotFound.Code()))
+
+	// The tables are dropped even if the caller has gone away.
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	result, err := f.DropSchemaTables(cancelledCtx, m, procedureManager, test.TestSchemaName)
+	re.NoError(err)
+	re.Equal(2, result.NumDropped)
+	re.Equal(0, result.NumFailed)
+	re.Len(result.Tables, 2)
+	re.Equal(test.TestTableName0, result.Tables[0].TableName)
+	re.True(result.Tables[0].Dropped)
+	re.Empty(m.GetTablesOfSchema(test.TestSchemaName))
+
+	// Nothing is dropped from the empty schema.
+	result, err = f.DropSchemaTables(ctx, m, procedureManager, test.TestSchemaName)
+	re.NoError(err)
+	re.Equal(0, result.NumDropped)
+	re.Empty(result.Tables)
+}
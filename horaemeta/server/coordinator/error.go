@@ -22,6 +22,12 @@ package coordinator
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
 var (
-	ErrNodeNumberNotEnough = coderr.NewCodeError(coderr.Internal, "node number not enough")
-	ErrPickNode            = coderr.NewCodeError(coderr.Internal, "no node is picked")
+	ErrNodeNumberNotEnough    = coderr.NewCodeError(coderr.Internal, "node number not enough")
+	ErrPickNode               = coderr.NewCodeError(coderr.Internal, "no node is picked")
+	ErrProcedureNotReplayable = coderr.NewCodeError(coderr.BadRequest, "procedure is not replayable")
+	ErrProcedureNotResumable  = coderr.NewCodeError(coderr.Internal, "procedure is not resumable")
+	ErrUnknownShardPicker     = coderr.NewCodeError(coderr.BadRequest, "unknown shard picker")
+	ErrParseShardPicker       = coderr.NewCodeError(coderr.BadRequest, "parse shard picker")
+	ErrTooManyInflightCreates = coderr.NewCodeError(coderr.TooManyRequests, "too many table creations in flight on the shards")
+	ErrInvalidPartitions      = coderr.NewCodeError(coderr.InvalidParams, "invalid partitions")
 )
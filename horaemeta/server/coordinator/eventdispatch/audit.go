@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package eventdispatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuditMaxProcedures is the max number of the procedures whose dispatches are audited, and the oldest procedure is evicted once it is full.
+	DefaultAuditMaxProcedures = 1024
+	// DefaultAuditMaxRecordsPerProcedure is the max number of the dispatches audited for a procedure, and the oldest ones are dropped once it is full.
+	DefaultAuditMaxRecordsPerProcedure = 128
+)
+
+type procedureIDKey struct{}
+
+// WithProcedureID returns the context carrying the id of the procedure, and the dispatches made with it are audited for the procedure.
+func WithProcedureID(ctx context.Context, procedureID uint64) context.Context {
+	return context.WithValue(ctx, procedureIDKey{}, procedureID)
+}
+
+// ProcedureIDFromContext returns the id of the procedure carried by the context, and false is returned if it is not set.
+func ProcedureIDFromContext(ctx context.Context) (uint64, bool) {
+	procedureID, ok := ctx.Value(procedureIDKey{}).(uint64)
+	return procedureID, ok
+}
+
+// AuditRecord describes an event dispatched to a data node.
+type AuditRecord struct {
+	Method string `json:"method"`
+	// Target is the address of the data node which the event is dispatched to.
+	Target string `json:"target"`
+	// Detail describes the shard or the table the event is about.
+	Detail    string    `json:"detail"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	LatencyMs int64     `json:"latencyMs"`
+}
+
+// ProcedureAudit is the events dispatched by a procedure ordered from the oldest.
+type ProcedureAudit struct {
+	ProcedureID uint64        `json:"procedureID"`
+	Records     []AuditRecord `json:"records"`
+	// Dropped is the number of the oldest records dropped because of the bound of the records per procedure.
+	Dropped int `json:"dropped"`
+}
+
+// Audit keeps the events dispatched by the recent procedures in memory.
+type Audit struct {
+	maxProcedures          int
+	maxRecordsPerProcedure int
+
+	lock   sync.Mutex
+	audits map[uint64]*ProcedureAudit
+	// The procedures ordered by their first dispatches, used to evict the oldest procedure.
+	procedureIDs []uint64
+}
+
+func NewAudit(maxProcedures, maxRecordsPerProcedure int) *Audit {
+	return &Audit{
+		maxProcedures:          maxProcedures,
+		maxRecordsPerProcedure: maxRecordsPerProcedure,
+
+		lock:         sync.Mutex{},
+		audits:       map[uint64]*ProcedureAudit{},
+		procedureIDs: []uint64{},
+	}
+}
+
+// Get returns the events dispatched by the procedure, and false is returned if nothing is audited for it.
+func (a *Audit) Get(procedureID uint64) (ProcedureAudit, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	audit, ok := a.audits[procedureID]
+	if !ok {
+		return ProcedureAudit{}, false
+	}
+	records := make([]AuditRecord, len(audit.Records))
+	copy(records, audit.Records)
+	return ProcedureAudit{ProcedureID: procedureID, Records: records, Dropped: audit.Dropped}, true
+}
+
+// record audits the dispatch for the procedure carried by the context, and nothing is audited for the dispatches out of the procedures.
+func (a *Audit) record(ctx context.Context, method, target, detail string, start time.Time, err error) {
+	procedureID, ok := ProcedureIDFromContext(ctx)
+	if !ok || a.maxProcedures <= 0 || a.maxRecordsPerProcedure <= 0 {
+		return
+	}
+
+	record := AuditRecord{
+		Method:    method,
+		Target:    target,
+		Detail:    detail,
+		Success:   err == nil,
+		Error:     "",
+		StartTime: start,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	audit, ok := a.audits[procedureID]
+	if !ok {
+		if len(a.procedureIDs) >= a.maxProcedures {
+			delete(a.audits, a.procedureIDs[0])
+			a.procedureIDs = a.procedureIDs[1:]
+		}
+		audit = &ProcedureAudit{ProcedureID: procedureID, Records: []AuditRecord{}, Dropped: 0}
+		a.audits[procedureID] = audit
+		a.procedureIDs = append(a.procedureIDs, procedureID)
+	}
+	if len(audit.Records) >= a.maxRecordsPerProcedure {
+		audit.Records = audit.Records[1:]
+		audit.Dropped++
+	}
+	audit.Records = append(audit.Records, record)
+}
+
+// AuditedDispatch audits the events dispatched by the procedures, and forwards them to the internal dispatch.
+type AuditedDispatch struct {
+	internal Dispatch
+	audit    *Audit
+}
+
+func NewAuditedDispatch(internal Dispatch, audit *Audit) *AuditedDispatch {
+	return &AuditedDispatch{internal: internal, audit: audit}
+}
+
+func (d *AuditedDispatch) OpenShard(ctx context.Context, addr string, request OpenShardRequest) error {
+	start := time.Now()
+	err := d.internal.OpenShard(ctx, addr, request)
+	d.audit.record(ctx, methodOpenShard, addr, describeShard(uint32(request.Shard.ID)), start, err)
+	return err
+}
+
+func (d *AuditedDispatch) CloseShard(ctx context.Context, addr string, request CloseShardRequest) error {
+	start := time.Now()
+	err := d.internal.CloseShard(ctx, addr, request)
+	d.audit.record(ctx, methodCloseShard, addr, describeShard(request.ShardID), start, err)
+	return err
+}
+
+func (d *AuditedDispatch) CreateTableOnShard(ctx context.Context, addr string, request CreateTableOnShardRequest) (uint64, error) {
+	start := time.Now()
+	version, err := d.internal.CreateTableOnShard(ctx, addr, request)
+	d.audit.record(ctx, methodCreateTableOnShard, addr, describeTableOnShard(request.UpdateShardInfo, request.TableInfo.SchemaName, request.TableInfo.Name), start, err)
+	return version, err
+}
+
+func (d *AuditedDispatch) DropTableOnShard(ctx context.Context, addr string, request DropTableOnShardRequest) (uint64, error) {
+	start := time.Now()
+	version, err := d.internal.DropTableOnShard(ctx, addr, request)
+	d.audit.record(ctx, methodDropTableOnShard, addr, describeTableOnShard(request.UpdateShardInfo, request.TableInfo.SchemaName, request.TableInfo.Name), start, err)
+	return version, err
+}
+
+func (d *AuditedDispatch) OpenTableOnShard(ctx context.Context, addr string, request OpenTableOnShardRequest) error {
+	start := time.Now()
+	err := d.internal.OpenTableOnShard(ctx, addr, request)
+	d.audit.record(ctx, methodOpenTableOnShard, addr, describeTableOnShard(request.UpdateShardInfo, request.TableInfo.SchemaName, request.TableInfo.Name), start, err)
+	return err
+}
+
+func (d *AuditedDispatch) CloseTableOnShard(ctx context.Context, addr string, request CloseTableOnShardRequest) error {
+	start := time.Now()
+	err := d.internal.CloseTableOnShard(ctx, addr, request)
+	d.audit.record(ctx, methodCloseTableOnShard, addr, describeTableOnShard(request.UpdateShardInfo, request.TableInfo.SchemaName, request.TableInfo.Name), start, err)
+	return err
+}
+
+func (d *AuditedDispatch) PreWarmShard(ctx context.Context, addr string, request PreWarmShardRequest) error {
+	start := time.Now()
+	err := d.internal.PreWarmShard(ctx, addr, request)
+	d.audit.record(ctx, methodPreWarmShard, addr, describeShard(uint32(request.Shard.ID)), start, err)
+	return err
+}
+
+func describeShard(shardID uint32) string {
+	return fmt.Sprintf("shardID:%d", shardID)
+}
+
+func describeTableOnShard(updateShardInfo UpdateShardInfo, schemaName, tableName string) string {
+	return fmt.Sprintf("shardID:%d, version:%d, table:%s.%s", updateShardInfo.CurrShardInfo.ID, updateShardInfo.CurrShardInfo.Version, schemaName, tableName)
+}
@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package eventdispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDispatch fails the events dispatched to the address failedAddr.
+type mockDispatch struct {
+	failedAddr string
+}
+
+func (m mockDispatch) result(addr string) error {
+	if addr == m.failedAddr {
+		return errors.New("node unavailable")
+	}
+	return nil
+}
+
+func (m mockDispatch) OpenShard(_ context.Context, addr string, _ OpenShardRequest) error {
+	return m.result(addr)
+}
+
+func (m mockDispatch) CloseShard(_ context.Context, addr string, _ CloseShardRequest) error {
+	return m.result(addr)
+}
+
+func (m mockDispatch) CreateTableOnShard(_ context.Context, addr string, _ CreateTableOnShardRequest) (uint64, error) {
+	return 0, m.result(addr)
+}
+
+func (m mockDispatch) DropTableOnShard(_ context.Context, addr string, _ DropTableOnShardRequest) (uint64, error) {
+	return 0, m.result(addr)
+}
+
+func (m mockDispatch) OpenTableOnShard(_ context.Context, addr string, _ OpenTableOnShardRequest) error {
+	return m.result(addr)
+}
+
+func (m mockDispatch) CloseTableOnShard(_ context.Context, addr string, _ CloseTableOnShardRequest) error {
+	return m.result(addr)
+}
+
+func (m mockDispatch) PreWarmShard(_ context.Context, addr string, _ PreWarmShardRequest) error {
+	return m.result(addr)
+}
+
+func TestAuditedDispatch(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	audit := NewAudit(2, 2)
+	dispatch := NewAuditedDispatch(mockDispatch{failedAddr: "node1"}, audit)
+	openShardRequest := OpenShardRequest{Shard: metadata.ShardInfo{ID: 1, Role: storage.ShardRoleLeader, Version: 0, Status: storage.ShardStatusUnknown}}
+
+	// The dispatches out of the procedures are not audited.
+	re.NoError(dispatch.OpenShard(ctx, "node0", openShardRequest))
+	_, ok := audit.Get(0)
+	re.False(ok)
+
+	procedureCtx := WithProcedureID(ctx, 1)
+	re.NoError(dispatch.CloseShard(procedureCtx, "node0", CloseShardRequest{ShardID: 1}))
+	re.Error(dispatch.OpenShard(procedureCtx, "node1", openShardRequest))
+	procedureAudit, ok := audit.Get(1)
+	re.True(ok)
+	re.Equal(uint64(1), procedureAudit.ProcedureID)
+	re.Equal(0, procedureAudit.Dropped)
+	re.Len(procedureAudit.Records, 2)
+	re.Equal(methodCloseShard, procedureAudit.Records[0].Method)
+	re.Equal("node0", procedureAudit.Records[0].Target)
+	re.Equal("shardID:1", procedureAudit.Records[0].Detail)
+	re.True(procedureAudit.Records[0].Success)
+	re.Equal(methodOpenShard, procedureAudit.Records[1].Method)
+	re.Equal("node1", procedureAudit.Records[1].Target)
+	re.False(procedureAudit.Records[1].Success)
+	re.Equal("node unavailable", procedureAudit.Records[1].Error)
+
+	// The oldest records of the procedure are dropped once it is full.
+	re.NoError(dispatch.PreWarmShard(procedureCtx, "node0", PreWarmShardRequest{Shard: openShardRequest.Shard, ExpectedTableCount: 0}))
+	procedureAudit, ok = audit.Get(1)
+	re.True(ok)
+	re.Equal(1, procedureAudit.Dropped)
+	re.Len(procedureAudit.Records, 2)
+	re.Equal(methodOpenShard, procedureAudit.Records[0].Method)
+	re.Equal(methodPreWarmShard, procedureAudit.Records[1].Method)
+	re.Equal("shardID:1", procedureAudit.Records[1].Detail)
+
+	// The oldest procedure is evicted once the audit is full.
+	re.NoError(dispatch.CloseShard(WithProcedureID(ctx, 2), "node0", CloseShardRequest{ShardID: 2}))
+	re.NoError(dispatch.CloseShard(WithProcedureID(ctx, 3), "node0", CloseShardRequest{ShardID: 3}))
+	_, ok = audit.Get(1)
+	re.False(ok)
+	for _, procedureID := range []uint64{2, 3} {
+		procedureAudit, ok = audit.Get(procedureID)
+		re.True(ok)
+		re.Len(procedureAudit.Records, 1)
+	}
+}
@@ -32,6 +32,7 @@ type Dispatch interface {
 	DropTableOnShard(context context.Context, address string, request DropTableOnShardRequest) (uint64, error)
 	OpenTableOnShard(ctx context.Context, address string, request OpenTableOnShardRequest) error
 	CloseTableOnShard(context context.Context, address string, request CloseTableOnShardRequest) error
+	PreWarmShard(ctx context.Context, address string, request PreWarmShardRequest) error
 }
 
 type OpenShardRequest struct {
@@ -69,3 +70,10 @@ type CloseTableOnShardRequest struct {
 	UpdateShardInfo UpdateShardInfo
 	TableInfo       metadata.TableInfo
 }
+
+// PreWarmShardRequest hints the node to pre-allocate the resources for the shard which is about to receive many tables, e.g. by a bulk load.
+type PreWarmShardRequest struct {
+	Shard metadata.ShardInfo
+	// ExpectedTableCount is the number of the tables expected to be created on the shard, zero if unknown.
+	ExpectedTableCount uint32
+}
@@ -21,7 +21,9 @@ package eventdispatch
 
 import (
 	"context"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -29,10 +31,14 @@ import (
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaeventpb"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	grpcmetadata "google.golang.org/grpc/metadata"
 )
 
 var ErrDispatch = coderr.NewCodeError(coderr.Internal, "event dispatch failed")
 
+// PreWarmShardExpectedTablesKey is the key of the grpc metadata carried by the pre-warm hint, whose value is the number of the tables expected to be created on the shard.
+const PreWarmShardExpectedTablesKey = "x-horaedb-pre-warm-expected-tables"
+
 type DispatchImpl struct {
 	conns sync.Map
 }
@@ -44,6 +50,8 @@ func NewDispatchImpl() *DispatchImpl {
 }
 
 func (d *DispatchImpl) OpenShard(ctx context.Context, addr string, request OpenShardRequest) error {
+	defer observeDispatchLatency(methodOpenShard, addr, time.Now())
+
 	client, err := d.getMetaEventClient(ctx, addr)
 	if err != nil {
 		return err
@@ -61,6 +69,8 @@ func (d *DispatchImpl) OpenShard(ctx context.Context, addr string, request OpenS
 }
 
 func (d *DispatchImpl) CloseShard(ctx context.Context, addr string, request CloseShardRequest) error {
+	defer observeDispatchLatency(methodCloseShard, addr, time.Now())
+
 	client, err := d.getMetaEventClient(ctx, addr)
 	if err != nil {
 		return err
@@ -78,6 +88,8 @@ func (d *DispatchImpl) CloseShard(ctx context.Context, addr string, request Clos
 }
 
 func (d *DispatchImpl) CreateTableOnShard(ctx context.Context, addr string, request CreateTableOnShardRequest) (uint64, error) {
+	defer observeDispatchLatency(methodCreateTableOnShard, addr, time.Now())
+
 	client, err := d.getMetaEventClient(ctx, addr)
 	if err != nil {
 		return 0, err
@@ -93,6 +105,8 @@ func (d *DispatchImpl) CreateTableOnShard(ctx context.Context, addr string, requ
 }
 
 func (d *DispatchImpl) DropTableOnShard(ctx context.Context, addr string, request DropTableOnShardRequest) (uint64, error) {
+	defer observeDispatchLatency(methodDropTableOnShard, addr, time.Now())
+
 	client, err := d.getMetaEventClient(ctx, addr)
 	if err != nil {
 		return 0, err
@@ -139,6 +153,29 @@ func (d *DispatchImpl) CloseTableOnShard(ctx context.Context, addr string, reque
 	return nil
 }
 
+// PreWarmShard sends the pre-warm hint of the shard to its leader through OpenShard with the current shard info.
+// Reopening the shard of the same version is a no-op on the node which has opened it, and the node serving the hint reads the expected table count
+// from the grpc metadata keyed by PreWarmShardExpectedTablesKey.
+func (d *DispatchImpl) PreWarmShard(ctx context.Context, addr string, request PreWarmShardRequest) error {
+	defer observeDispatchLatency(methodPreWarmShard, addr, time.Now())
+
+	client, err := d.getMetaEventClient(ctx, addr)
+	if err != nil {
+		return err
+	}
+	ctx = grpcmetadata.AppendToOutgoingContext(ctx, PreWarmShardExpectedTablesKey, strconv.FormatUint(uint64(request.ExpectedTableCount), 10))
+	resp, err := client.OpenShard(ctx, &metaeventpb.OpenShardRequest{
+		Shard: metadata.ConvertShardsInfoToPB(request.Shard),
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "pre-warm shard, addr:%s, request:%v", addr, request)
+	}
+	if resp.GetHeader().Code != 0 {
+		return ErrDispatch.WithCausef("pre-warm shard, addr:%s, request:%v, err:%s", addr, request, resp.GetHeader().GetError())
+	}
+	return nil
+}
+
 func (d *DispatchImpl) getGrpcClient(ctx context.Context, addr string) (*grpc.ClientConn, error) {
 	client, ok := d.conns.Load(addr)
 	if !ok {
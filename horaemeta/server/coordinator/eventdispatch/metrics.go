@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package eventdispatch
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	methodOpenShard          = "open_shard"
+	methodCloseShard         = "close_shard"
+	methodCreateTableOnShard = "create_table_on_shard"
+	methodDropTableOnShard   = "drop_table_on_shard"
+	methodOpenTableOnShard   = "open_table_on_shard"
+	methodCloseTableOnShard  = "close_table_on_shard"
+	methodPreWarmShard       = "pre_warm_shard"
+)
+
+// dispatchLatency records the latency of the events dispatched to the data nodes, labeled by the method and the address of the target node.
+var dispatchLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "horaemeta",
+	Subsystem: "dispatch",
+	Name:      "latency_seconds",
+	Help:      "Latency of the events dispatched to the data nodes.",
+	// From 1ms to about 32s.
+	Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+}, []string{"method", "addr"})
+
+func init() {
+	prometheus.MustRegister(dispatchLatency)
+}
+
+// observeDispatchLatency is expected to be deferred with the start time evaluated when the dispatch begins.
+func observeDispatchLatency(method, addr string, start time.Time) {
+	dispatchLatency.WithLabelValues(method, addr).Observe(time.Since(start).Seconds())
+}
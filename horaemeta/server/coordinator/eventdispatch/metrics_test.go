@@ -0,0 +1,39 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package eventdispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveDispatchLatency(t *testing.T) {
+	re := require.New(t)
+
+	observeDispatchLatency(methodOpenShard, "127.0.0.1:8831", time.Now().Add(-time.Millisecond))
+	observeDispatchLatency(methodOpenShard, "127.0.0.2:8831", time.Now())
+	observeDispatchLatency(methodCloseShard, "127.0.0.1:8831", time.Now())
+
+	// Every pair of method and address is exported as a separate series.
+	re.Equal(3, testutil.CollectAndCount(dispatchLatency, "horaemeta_dispatch_latency_seconds"))
+}
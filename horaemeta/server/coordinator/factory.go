@@ -21,6 +21,8 @@ package coordinator
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
@@ -44,6 +46,10 @@ type Factory struct {
 	dispatch    eventdispatch.Dispatch
 	storage     procedure.Storage
 	shardPicker *PersistShardPicker
+	// clusterMetadata records the leader changes of the shards transferred by the procedures.
+	clusterMetadata *metadata.ClusterMetadata
+	// checkpointer persists the fsm state transitions of the create table and drop table procedures.
+	checkpointer procedure.Checkpointer
 }
 
 type CreateTableRequest struct {
@@ -71,11 +77,21 @@ func (d DropTableRequest) IsPartitionTable() bool {
 	return d.SourceReq.PartitionTableInfo != nil
 }
 
+// The reasons of transferring the shard leader, which are recorded in the leader history of the shard.
+const (
+	TransferLeaderReasonManual    = "manual"
+	TransferLeaderReasonFailover  = "failover"
+	TransferLeaderReasonRebalance = "rebalance"
+	TransferLeaderReasonReopen    = "reopen"
+)
+
 type TransferLeaderRequest struct {
 	Snapshot          metadata.Snapshot
 	ShardID           storage.ShardID
 	OldLeaderNodeName string
 	NewLeaderNodeName string
+	// Reason is why the leader is transferred, e.g. TransferLeaderReasonFailover.
+	Reason string
 }
 
 type SplitRequest struct {
@@ -99,15 +115,22 @@ type CreatePartitionTableRequest struct {
 type BatchRequest struct {
 	Batch     []procedure.Procedure
 	BatchType procedure.Kind
+	// Concurrency is the max number of procedures in the batch started concurrently, zero means no limit.
+	Concurrency uint32
 }
 
-func NewFactory(logger *zap.Logger, allocator id.Allocator, dispatch eventdispatch.Dispatch, storage procedure.Storage, clusterMetadata *metadata.ClusterMetadata) *Factory {
+// NewFactory creates the factory whose shard picker persists the tables assigned by the given shardPicker.
+func NewFactory(logger *zap.Logger, allocator id.Allocator, dispatch eventdispatch.Dispatch, storage procedure.Storage, clusterMetadata *metadata.ClusterMetadata, shardPicker ShardPicker) *Factory {
 	return &Factory{
 		idAllocator: allocator,
 		dispatch:    dispatch,
 		storage:     storage,
 		logger:      logger,
-		shardPicker: NewPersistShardPicker(clusterMetadata, NewLeastTableShardPicker()),
+		shardPicker: NewPersistShardPicker(clusterMetadata, shardPicker),
+
+		clusterMetadata: clusterMetadata,
+
+		checkpointer: procedure.NewCheckpointer(storage, clusterMetadata.IsProcedureCheckpointEnabled()),
 	}
 }
 
@@ -115,6 +138,8 @@ func (f *Factory) MakeCreateTableProcedure(ctx context.Context, request CreateTa
 	isPartitionTable := request.isPartitionTable()
 
 	if isPartitionTable {
+		// The default partition count of the cluster applies only if the client doesn't specify the partitions.
+		request.SourceReq = withDefaultPartitions(request.SourceReq, request.ClusterMetadata.GetDefaultPartitionCount())
 		req := CreatePartitionTableRequest(request)
 		return f.makeCreatePartitionTableProcedure(ctx, req)
 	}
@@ -128,53 +153,97 @@ func (f *Factory) makeCreateTableProcedure(ctx context.Context, request CreateTa
 		return nil, err
 	}
 	snapshot := request.ClusterMetadata.GetClusterSnapshot()
+	if err := request.ClusterMetadata.CheckTableQuota(snapshot, 1); err != nil {
+		return nil, err
+	}
 
 	var targetShardID storage.ShardID
+	var ticket metadata.InflightCreatesTicket
 	shardID, exists, err := request.ClusterMetadata.GetTableAssignedShard(ctx, request.SourceReq.SchemaName, request.SourceReq.Name)
 	if err != nil {
 		return nil, err
 	}
 	if exists {
 		targetShardID = shardID
+		ticket, err = request.ClusterMetadata.AcquireInflightCreates([]storage.ShardID{targetShardID})
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		shards, err := f.shardPicker.PickShards(ctx, snapshot, request.SourceReq.GetSchemaName(), []string{request.SourceReq.GetName()})
+		shards, pickTicket, err := f.shardPicker.PickShardsInflight(ctx, snapshot, request.SourceReq.GetSchemaName(), []string{request.SourceReq.GetName()})
 		if err != nil {
 			f.logger.Error("pick table shard", zap.Error(err))
 			return nil, errors.WithMessage(err, "pick table shard")
 		}
 		if len(shards) != 1 {
 			f.logger.Error("pick table shards length not equal 1", zap.Int("shards", len(shards)))
+			request.ClusterMetadata.ReleaseInflightCreates(pickTicket)
 			return nil, errors.WithMessagef(procedure.ErrPickShard, "pick table shard, shards length:%d", len(shards))
 		}
 		targetShardID = shards[request.SourceReq.GetName()].ID
+		ticket = pickTicket
 	}
 
-	return createtable.NewProcedure(createtable.ProcedureParams{
+	// The offline shard is re-picked before the procedure is submitted, because the shard locked by the procedure must not change.
+	resolvedShardID, err := f.resolveOnlineShard(ctx, request.ClusterMetadata, snapshot, request.SourceReq, targetShardID, time.Now())
+	if err != nil {
+		request.ClusterMetadata.ReleaseInflightCreates(ticket)
+		return nil, err
+	}
+	if resolvedShardID != targetShardID {
+		request.ClusterMetadata.ReleaseInflightCreates(ticket)
+		ticket, err = request.ClusterMetadata.AcquireInflightCreates([]storage.ShardID{resolvedShardID})
+		if err != nil {
+			return nil, err
+		}
+		targetShardID = resolvedShardID
+	}
+
+	onSucceeded, onFailed := releaseInflightCreatesOnDone(request.ClusterMetadata, ticket, request.OnSucceeded, request.OnFailed)
+	p, err := createtable.NewProcedure(createtable.ProcedureParams{
 		Dispatch:        f.dispatch,
 		ClusterMetadata: request.ClusterMetadata,
 		ClusterSnapshot: snapshot,
 		ID:              id,
 		ShardID:         targetShardID,
 		SourceReq:       request.SourceReq,
-		OnSucceeded:     request.OnSucceeded,
-		OnFailed:        request.OnFailed,
+		OnSucceeded:     onSucceeded,
+		OnFailed:        onFailed,
+		Checkpointer:    f.checkpointer,
+		ResumeState:     "",
 	})
+	if err != nil {
+		request.ClusterMetadata.ReleaseInflightCreates(ticket)
+		return nil, err
+	}
+	return p, nil
 }
 
 func (f *Factory) makeCreatePartitionTableProcedure(ctx context.Context, request CreatePartitionTableRequest) (procedure.Procedure, error) {
+	if err := validatePartitions(request.SourceReq); err != nil {
+		return nil, err
+	}
+	// The huge partition table is rejected before anything is allocated for it.
+	if err := request.ClusterMetadata.CheckPartitionSubTables(len(request.SourceReq.PartitionTableInfo.SubTableNames)); err != nil {
+		return nil, err
+	}
+
 	id, err := f.allocProcedureID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	snapshot := request.ClusterMetadata.GetClusterSnapshot()
+	if err := request.ClusterMetadata.CheckTableQuota(snapshot, len(request.SourceReq.PartitionTableInfo.SubTableNames)); err != nil {
+		return nil, err
+	}
 
 	nodeNames := make(map[string]int, len(snapshot.Topology.ClusterView.ShardNodes))
 	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
 		nodeNames[shardNode.NodeName] = 1
 	}
 
-	subTableShards, err := f.shardPicker.PickShards(ctx, snapshot, request.SourceReq.GetSchemaName(), request.SourceReq.PartitionTableInfo.SubTableNames)
+	subTableShards, ticket, err := f.shardPicker.PickShardsInflight(ctx, snapshot, request.SourceReq.GetSchemaName(), request.SourceReq.PartitionTableInfo.SubTableNames)
 	if err != nil {
 		return nil, errors.WithMessage(err, "pick sub table shards")
 	}
@@ -183,6 +252,7 @@ func (f *Factory) makeCreatePartitionTableProcedure(ctx context.Context, request
 	for _, subTableShard := range subTableShards {
 		shardView, exists := snapshot.Topology.ShardViewsMapping[subTableShard.ID]
 		if !exists {
+			request.ClusterMetadata.ReleaseInflightCreates(ticket)
 			return nil, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found, shardID:%d", subTableShard.ID)
 		}
 		shardNodesWithVersion = append(shardNodesWithVersion, metadata.ShardNodeWithVersion{
@@ -196,7 +266,8 @@ func (f *Factory) makeCreatePartitionTableProcedure(ctx context.Context, request
 		})
 	}
 
-	return createpartitiontable.NewProcedure(createpartitiontable.ProcedureParams{
+	onSucceeded, onFailed := releaseInflightCreatesOnDone(request.ClusterMetadata, ticket, request.OnSucceeded, request.OnFailed)
+	p, err := createpartitiontable.NewProcedure(createpartitiontable.ProcedureParams{
 		ID:              id,
 		ClusterMetadata: request.ClusterMetadata,
 		ClusterSnapshot: snapshot,
@@ -204,9 +275,43 @@ func (f *Factory) makeCreatePartitionTableProcedure(ctx context.Context, request
 		Storage:         f.storage,
 		SourceReq:       request.SourceReq,
 		SubTablesShards: shardNodesWithVersion,
-		OnSucceeded:     request.OnSucceeded,
-		OnFailed:        request.OnFailed,
+		OnSucceeded:     onSucceeded,
+		OnFailed:        onFailed,
+
+		DispatchConcurrency: int(request.ClusterMetadata.GetSubTableDispatchConcurrency()),
 	})
+	if err != nil {
+		request.ClusterMetadata.ReleaseInflightCreates(ticket)
+		return nil, err
+	}
+	return p, nil
+}
+
+// releaseInflightCreatesOnDone wraps the callbacks of the table creation, so that the creations in flight on the picked
+// shards are released once when the creation finishes.
+func releaseInflightCreatesOnDone(clusterMetadata *metadata.ClusterMetadata, ticket metadata.InflightCreatesTicket, onSucceeded func(metadata.CreateTableResult) error, onFailed func(error) error) (func(metadata.CreateTableResult) error, func(error) error) {
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			clusterMetadata.ReleaseInflightCreates(ticket)
+		})
+	}
+
+	wrappedOnSucceeded := func(result metadata.CreateTableResult) error {
+		release()
+		if onSucceeded == nil {
+			return nil
+		}
+		return onSucceeded(result)
+	}
+	wrappedOnFailed := func(err error) error {
+		release()
+		if onFailed == nil {
+			return nil
+		}
+		return onFailed(err)
+	}
+	return wrappedOnSucceeded, wrappedOnFailed
 }
 
 // CreateDropTableProcedure creates a procedure to do drop table.
@@ -242,6 +347,7 @@ func (f *Factory) CreateDropTableProcedure(ctx context.Context, request DropTabl
 		SourceReq:       request.SourceReq,
 		OnSucceeded:     request.OnSucceeded,
 		OnFailed:        request.OnFailed,
+		Checkpointer:    f.checkpointer,
 	})
 }
 
@@ -255,10 +361,12 @@ func (f *Factory) CreateTransferLeaderProcedure(ctx context.Context, request Tra
 		ID:                id,
 		Dispatch:          f.dispatch,
 		Storage:           f.storage,
+		ClusterMetadata:   f.clusterMetadata,
 		ClusterSnapshot:   request.Snapshot,
 		ShardID:           request.ShardID,
 		OldLeaderNodeName: request.OldLeaderNodeName,
 		NewLeaderNodeName: request.NewLeaderNodeName,
+		Reason:            request.Reason,
 	})
 }
 
@@ -290,7 +398,7 @@ func (f *Factory) CreateBatchTransferLeaderProcedure(ctx context.Context, reques
 		return nil, err
 	}
 
-	return transferleader.NewBatchTransferLeaderProcedure(id, request.Batch)
+	return transferleader.NewBatchTransferLeaderProcedure(id, request.Batch, request.Concurrency)
 }
 
 func (f *Factory) allocProcedureID(ctx context.Context) (uint64, error) {
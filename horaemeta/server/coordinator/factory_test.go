@@ -21,25 +21,35 @@ package coordinator_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
 func setupFactory(t *testing.T) (*coordinator.Factory, *metadata.ClusterMetadata) {
+	return setupFactoryWithShardPicker(t, coordinator.NewLeastTableShardPicker())
+}
+
+func setupFactoryWithShardPicker(t *testing.T, shardPicker coordinator.ShardPicker) (*coordinator.Factory, *metadata.ClusterMetadata) {
 	ctx := context.Background()
 	c := test.InitStableCluster(ctx, t)
 
 	dispatch := test.MockDispatch{}
 	allocator := test.MockIDAllocator{}
 	storage := test.NewTestStorage(t)
-	f := coordinator.NewFactory(zap.NewNop(), allocator, dispatch, storage, c.GetMetadata())
+	f := coordinator.NewFactory(zap.NewNop(), allocator, dispatch, storage, c.GetMetadata(), shardPicker)
 
 	return f, c.GetMetadata()
 }
@@ -92,6 +102,136 @@ func TestCreateTable(t *testing.T) {
 	re.Equal(procedure.StateInit, string(p.State()))
 }
 
+func TestCreatePartitionTableWithDefaultPartitionCount(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	// The default partition count must not exceed the shard total.
+	err := m.UpdateDefaultPartitionCount(ctx, test.DefaultShardTotal+1)
+	re.Error(err)
+	re.True(coderr.Is(err, metadata.ErrInvalidPartitionCount.Code()))
+	re.NoError(m.UpdateDefaultPartitionCount(ctx, 3))
+
+	newRequest := func(name string, definitions []*clusterpb.PartitionDefinition, subTableNames []string) *metaservicepb.CreateTableRequest {
+		return &metaservicepb.CreateTableRequest{
+			Header:           nil,
+			SchemaName:       test.TestSchemaName,
+			Name:             name,
+			EncodedSchema:    nil,
+			Engine:           "",
+			CreateIfNotExist: false,
+			Options:          nil,
+			PartitionTableInfo: &metaservicepb.PartitionTableInfo{
+				PartitionInfo: &clusterpb.PartitionInfo{Info: &clusterpb.PartitionInfo_Key{Key: &clusterpb.KeyPartitionInfo{
+					Version:      0,
+					Definitions:  definitions,
+					PartitionKey: []string{"id"},
+					Linear:       false,
+				}}},
+				SubTableNames: subTableNames,
+			},
+		}
+	}
+	makeProcedure := func(req *metaservicepb.CreateTableRequest) (procedure.Procedure, error) {
+		return f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq:       req,
+			OnSucceeded:     nil,
+			OnFailed:        nil,
+		})
+	}
+
+	subTableCount := func(req *metaservicepb.CreateTableRequest) uint32 {
+		p, err := makeProcedure(req)
+		re.NoError(err)
+		re.Equal(procedure.CreatePartitionTable, p.Kind())
+		return p.(procedure.ProgressReporter).Progress().Total
+	}
+
+	// The default partition count applies if the partitions are not specified, and the request of the client is not modified.
+	req := newRequest("test3", nil, nil)
+	re.Equal(uint32(3), subTableCount(req))
+	re.Empty(req.GetPartitionTableInfo().GetSubTableNames())
+	re.Empty(req.GetPartitionTableInfo().GetPartitionInfo().GetKey().GetDefinitions())
+
+	// The partitions specified by the client win.
+	definitions := []*clusterpb.PartitionDefinition{{Name: "0", OriginName: nil}, {Name: "1", OriginName: nil}}
+	re.Equal(uint32(2), subTableCount(newRequest("test4", definitions, []string{"__test4_0", "__test4_1"})))
+
+	// The partition definitions must match the sub tables.
+	_, err = makeProcedure(newRequest("test5", definitions, []string{"__test5_0"}))
+	re.ErrorIs(err, coordinator.ErrInvalidPartitions)
+
+	// The partitions must be specified by the client if there is no default partition count.
+	re.NoError(m.UpdateDefaultPartitionCount(ctx, 0))
+	_, err = makeProcedure(newRequest("test6", nil, nil))
+	re.ErrorIs(err, coordinator.ErrInvalidPartitions)
+}
+
+// countingShardPicker counts the picks before delegating them to the internal picker.
+type countingShardPicker struct {
+	internal coordinator.ShardPicker
+	picks    int
+}
+
+func (p *countingShardPicker) PickShards(ctx context.Context, snapshot metadata.Snapshot, expectShardNum int) ([]storage.ShardNode, error) {
+	p.picks++
+	return p.internal.PickShards(ctx, snapshot, expectShardNum)
+}
+
+func TestCreatePartitionTableWithMaxSubTables(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	shardPicker := &countingShardPicker{internal: coordinator.NewLeastTableShardPicker(), picks: 0}
+	f, m := setupFactoryWithShardPicker(t, shardPicker)
+
+	makeCreatePartitionTableProcedure := func(name string, numSubTables int) (procedure.Procedure, error) {
+		subTableNames := make([]string, 0, numSubTables)
+		for i := 0; i < numSubTables; i++ {
+			subTableNames = append(subTableNames, fmt.Sprintf("__%s_%d", name, i))
+		}
+		return f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:           nil,
+				SchemaName:       test.TestSchemaName,
+				Name:             name,
+				EncodedSchema:    nil,
+				Engine:           "",
+				CreateIfNotExist: false,
+				Options:          nil,
+				PartitionTableInfo: &metaservicepb.PartitionTableInfo{
+					PartitionInfo: nil,
+					SubTableNames: subTableNames,
+				},
+			},
+			OnSucceeded: nil,
+			OnFailed:    nil,
+		})
+	}
+
+	m.UpdateMaxPartitionSubTables(2)
+	_, err := makeCreatePartitionTableProcedure("huge_partition_table", 3)
+	re.Error(err)
+	re.ErrorIs(err, metadata.ErrTooManySubTables)
+	// No shard is picked for the rejected partition table.
+	re.Zero(shardPicker.picks)
+	re.Empty(m.GetInflightCreates())
+
+	p, err := makeCreatePartitionTableProcedure("small_partition_table", 2)
+	re.NoError(err)
+	re.Equal(procedure.CreatePartitionTable, p.Kind())
+	re.Positive(shardPicker.picks)
+
+	// Zero means unlimited.
+	m.UpdateMaxPartitionSubTables(0)
+	p, err = makeCreatePartitionTableProcedure("unlimited_partition_table", test.DefaultShardTotal+1)
+	re.NoError(err)
+	re.Equal(procedure.CreatePartitionTable, p.Kind())
+	re.Equal(uint32(test.DefaultShardTotal+1), p.(procedure.ProgressReporter).Progress().Total)
+}
+
 func TestDropTable(t *testing.T) {
 	re := require.New(t)
 	ctx := context.Background()
@@ -145,6 +285,7 @@ func TestTransferLeader(t *testing.T) {
 		ShardID:           0,
 		OldLeaderNodeName: "",
 		NewLeaderNodeName: snapshot.RegisteredNodes[0].Node.Name,
+		Reason:            coordinator.TransferLeaderReasonManual,
 	})
 	re.NoError(err)
 	re.Equal(procedure.TransferLeader, p.Kind())
@@ -169,3 +310,308 @@ func TestSplit(t *testing.T) {
 	re.Equal(procedure.Split, p.Kind())
 	re.Equal(procedure.StateInit, string(p.State()))
 }
+
+func TestCreateTableExceedQuota(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	topology := m.GetClusterSnapshot().Topology
+	tableCount := topology.TableCount()
+	re.NoError(m.UpdateMaxTables(ctx, uint64(tableCount+1)))
+
+	// Create partition table whose sub tables exceed the quota.
+	_, err := f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+		ClusterMetadata: m,
+		SourceReq: &metaservicepb.CreateTableRequest{
+			Header:           nil,
+			SchemaName:       test.TestSchemaName,
+			Name:             "test2",
+			EncodedSchema:    nil,
+			Engine:           "",
+			CreateIfNotExist: false,
+			Options:          nil,
+			PartitionTableInfo: &metaservicepb.PartitionTableInfo{
+				PartitionInfo: nil,
+				SubTableNames: []string{"test2-0", "test2-1"},
+			},
+		},
+		OnSucceeded: nil,
+		OnFailed:    nil,
+	})
+	re.Error(err)
+	re.True(coderr.Is(err, coderr.TableQuotaExceeded))
+
+	// Create normal table within the quota.
+	_, err = f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+		ClusterMetadata: m,
+		SourceReq: &metaservicepb.CreateTableRequest{
+			Header:             nil,
+			SchemaName:         test.TestSchemaName,
+			Name:               "test1",
+			EncodedSchema:      nil,
+			Engine:             "",
+			CreateIfNotExist:   false,
+			Options:            nil,
+			PartitionTableInfo: nil,
+		},
+		OnSucceeded: nil,
+		OnFailed:    nil,
+	})
+	re.NoError(err)
+}
+
+func TestCreateTableConcurrentlyWithinQuota(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	const quota = 3
+	topology := m.GetClusterSnapshot().Topology
+	tableCount := topology.TableCount()
+	re.NoError(m.UpdateMaxTables(ctx, uint64(tableCount+quota)))
+
+	makeCreateTableProcedure := func(name string) (procedure.Procedure, error) {
+		return f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:             nil,
+				SchemaName:         test.TestSchemaName,
+				Name:               name,
+				EncodedSchema:      nil,
+				Engine:             "",
+				CreateIfNotExist:   false,
+				Options:            nil,
+				PartitionTableInfo: nil,
+			},
+			OnSucceeded: nil,
+			OnFailed:    nil,
+		})
+	}
+
+	// The creations in flight are counted against the quota, so the concurrent creations never pass it together.
+	var wg sync.WaitGroup
+	procedures := make(chan procedure.Procedure, 4*quota)
+	errs := make(chan error, 4*quota)
+	for i := 0; i < 4*quota; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := makeCreateTableProcedure(fmt.Sprintf("quota_table_%d", i))
+			if err != nil {
+				errs <- err
+				return
+			}
+			procedures <- p
+		}(i)
+	}
+	wg.Wait()
+	close(procedures)
+	close(errs)
+
+	re.Len(procedures, quota)
+	re.Len(errs, 3*quota)
+	for err := range errs {
+		re.True(coderr.Is(err, coderr.TableQuotaExceeded), "err:%v", err)
+	}
+
+	// The finished creation is counted by the created table instead.
+	p := <-procedures
+	re.NoError(p.Start(ctx))
+	topology = m.GetClusterSnapshot().Topology
+	re.Equal(tableCount+1, topology.TableCount())
+	_, err := makeCreateTableProcedure("quota_table_exceeded")
+	re.True(coderr.Is(err, coderr.TableQuotaExceeded), "err:%v", err)
+}
+
+// largestIDShardPicker always picks the shard with the largest id.
+type largestIDShardPicker struct{}
+
+func (largestIDShardPicker) PickShards(_ context.Context, snapshot metadata.Snapshot, expectShardNum int) ([]storage.ShardNode, error) {
+	shardNodes := snapshot.Topology.ClusterView.ShardNodes
+	picked := shardNodes[0]
+	for _, shardNode := range shardNodes {
+		if shardNode.ID > picked.ID {
+			picked = shardNode
+		}
+	}
+
+	result := make([]storage.ShardNode, 0, expectShardNum)
+	for i := 0; i < expectShardNum; i++ {
+		result = append(result, picked)
+	}
+	return result, nil
+}
+
+func TestFactoryWithShardPicker(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	pickShard := func(shardPicker coordinator.ShardPicker) storage.ShardID {
+		f, m := setupFactoryWithShardPicker(t, shardPicker)
+		p, err := f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:             nil,
+				SchemaName:         test.TestSchemaName,
+				Name:               "test1",
+				EncodedSchema:      nil,
+				Engine:             "",
+				CreateIfNotExist:   false,
+				Options:            nil,
+				PartitionTableInfo: nil,
+			},
+			OnSucceeded: nil,
+			OnFailed:    nil,
+		})
+		re.NoError(err)
+
+		shardWithVersion := p.RelatedVersionInfo().ShardWithVersion
+		re.Len(shardWithVersion, 1)
+		for shardID := range shardWithVersion {
+			return shardID
+		}
+		return 0
+	}
+
+	// The least table shard picker picks the shard with the smallest id among the empty shards.
+	re.Equal(storage.ShardID(0), pickShard(coordinator.NewLeastTableShardPicker()))
+	re.Equal(storage.ShardID(test.DefaultShardTotal-1), pickShard(largestIDShardPicker{}))
+}
+
+func TestCreateTablesConcurrently(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	makeCreateTableProcedure := func(name string) (procedure.Procedure, error) {
+		return f.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:             nil,
+				SchemaName:         test.TestSchemaName,
+				Name:               name,
+				EncodedSchema:      nil,
+				Engine:             "",
+				CreateIfNotExist:   false,
+				Options:            nil,
+				PartitionTableInfo: nil,
+			},
+			OnSucceeded: nil,
+			OnFailed:    nil,
+		})
+	}
+
+	// The creations in flight are counted when picking the shards, so the burst is spread over all the shards.
+	var wg sync.WaitGroup
+	procedures := make(chan procedure.Procedure, test.DefaultShardTotal)
+	for i := 0; i < test.DefaultShardTotal; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := makeCreateTableProcedure(fmt.Sprintf("concurrent_table_%d", i))
+			re.NoError(err)
+			procedures <- p
+		}(i)
+	}
+	wg.Wait()
+	close(procedures)
+
+	shardIDs := map[storage.ShardID]procedure.Procedure{}
+	for p := range procedures {
+		for shardID := range p.RelatedVersionInfo().ShardWithVersion {
+			shardIDs[shardID] = p
+		}
+	}
+	re.Len(shardIDs, test.DefaultShardTotal)
+	inflightCreates := m.GetInflightCreates()
+	for shardID := range shardIDs {
+		re.Equal(1, inflightCreates[shardID])
+	}
+
+	// No shard can be picked once all of them reach the limit.
+	m.UpdateMaxInflightCreatesPerShard(1)
+	_, err := makeCreateTableProcedure("concurrent_table_exceeded")
+	re.Error(err)
+	re.True(coderr.Is(err, coordinator.ErrTooManyInflightCreates.Code()))
+
+	// The shard is available again once its creation is finished.
+	re.NoError(shardIDs[test.DefaultShardTotal-1].Start(ctx))
+	re.Zero(m.GetInflightCreates()[test.DefaultShardTotal-1])
+	p, err := makeCreateTableProcedure("concurrent_table_released")
+	re.NoError(err)
+	re.Contains(p.RelatedVersionInfo().ShardWithVersion, storage.ShardID(test.DefaultShardTotal-1))
+}
+
+func TestCreateTableOnOfflineShard(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	// Spread the shards over all the nodes, so that another shard is still alive after a node goes offline.
+	snapshot := m.GetClusterSnapshot()
+	shardNodes := make([]storage.ShardNode, 0, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  snapshot.RegisteredNodes[int(shardID)%len(snapshot.RegisteredNodes)].Node.Name,
+		})
+	}
+	re.NoError(m.UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	// Take the leader node of a shard offline, and assign the tables to the shard.
+	offlineShardNode := m.GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
+	re.NoError(m.RegisterNode(ctx, metadata.RegisteredNode{
+		Node: storage.Node{
+			Name:          offlineShardNode.NodeName,
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: uint64(time.Now().Add(-time.Hour).UnixMilli()),
+			State:         storage.NodeStateOnline,
+		},
+		ShardInfos: nil,
+	}))
+	re.NoError(m.AssignTableToShard(ctx, test.TestSchemaName, test.TestTableName0, offlineShardNode.ID))
+	newRequest := func(tableName string) coordinator.CreateTableRequest {
+		return coordinator.CreateTableRequest{
+			ClusterMetadata: m,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:             nil,
+				SchemaName:         test.TestSchemaName,
+				Name:               tableName,
+				EncodedSchema:      nil,
+				Engine:             "",
+				CreateIfNotExist:   false,
+				Options:            nil,
+				PartitionTableInfo: nil,
+			},
+			OnSucceeded: nil,
+			OnFailed:    nil,
+		}
+	}
+
+	// The creation is rejected before submitting by default.
+	re.Equal(metadata.OfflineShardPolicyFail, m.GetCreateTableOfflineShardPolicy())
+	_, err := f.MakeCreateTableProcedure(ctx, newRequest(test.TestTableName0))
+	re.True(coderr.Is(err, metadata.ErrShardNotOnline.Code()))
+	re.Zero(m.GetInflightCreates()[offlineShardNode.ID])
+
+	// Another shard whose leader is alive is picked before submitting with the repick policy.
+	m.UpdateCreateTableOfflineShardPolicy(metadata.OfflineShardPolicyRepick)
+	p, err := f.MakeCreateTableProcedure(ctx, newRequest(test.TestTableName0))
+	re.NoError(err)
+	shardWithVersion := p.RelatedVersionInfo().ShardWithVersion
+	re.Len(shardWithVersion, 1)
+	re.NotContains(shardWithVersion, offlineShardNode.ID)
+	for newShardID := range shardWithVersion {
+		_, _, err := m.GetClusterSnapshot().GetAliveShardLeader(newShardID, time.Now())
+		re.NoError(err)
+		// The assignment and the inflight creation are moved to the new shard too.
+		assignedShardID, exists, err := m.GetTableAssignedShard(ctx, test.TestSchemaName, test.TestTableName0)
+		re.NoError(err)
+		re.True(exists)
+		re.Equal(newShardID, assignedShardID)
+		re.Equal(1, m.GetInflightCreates()[newShardID])
+	}
+	re.Zero(m.GetInflightCreates()[offlineShardNode.ID])
+}
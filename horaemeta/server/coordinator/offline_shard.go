@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// resolveOnlineShard returns the shard to create the table on. If the leader node of the given shard is offline, the creation is rejected
+// or another shard whose leader node is alive is re-picked according to the offline shard policy of the cluster.
+func (f *Factory) resolveOnlineShard(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, snapshot metadata.Snapshot, sourceReq *metaservicepb.CreateTableRequest, shardID storage.ShardID, now time.Time) (storage.ShardID, error) {
+	_, _, err := snapshot.GetAliveShardLeader(shardID, now)
+	if err == nil {
+		return shardID, nil
+	}
+	if !coderr.Is(err, metadata.ErrShardNotOnline.Code()) || clusterMetadata.GetCreateTableOfflineShardPolicy() != metadata.OfflineShardPolicyRepick {
+		return 0, errors.WithMessagef(err, "target shard is offline, shardID:%d", shardID)
+	}
+
+	newShardID, ok := pickAliveShard(snapshot, shardID, now)
+	if !ok {
+		return 0, errors.WithMessagef(err, "no other shard is alive to re-pick, shardID:%d", shardID)
+	}
+
+	// Re-assign the table to the new shard, so that the retried requests of the table are placed on it too.
+	schemaName, tableName := sourceReq.GetSchemaName(), sourceReq.GetName()
+	_, assigned, err := clusterMetadata.GetTableAssignedShard(ctx, schemaName, tableName)
+	if err != nil {
+		return 0, errors.WithMessage(err, "get table assigned shard")
+	}
+	if assigned {
+		if err := clusterMetadata.DeleteTableAssignedShard(ctx, schemaName, tableName); err != nil {
+			return 0, errors.WithMessage(err, "delete table assigned shard")
+		}
+	}
+	if err := clusterMetadata.AssignTableToShard(ctx, schemaName, tableName, newShardID); err != nil {
+		return 0, errors.WithMessage(err, "assign table to shard")
+	}
+
+	f.logger.Warn("re-pick shard to create table as the leader of the picked shard is offline", zap.String("tableName", tableName), zap.Uint32("shardID", uint32(shardID)), zap.Uint32("newShardID", uint32(newShardID)))
+	return newShardID, nil
+}
+
+// pickAliveShard picks the shard with the least tables among the shards whose leader node is alive, except the excluded one.
+func pickAliveShard(snapshot metadata.Snapshot, excluded storage.ShardID, now time.Time) (storage.ShardID, bool) {
+	var picked storage.ShardID
+	found := false
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ShardRole != storage.ShardRoleLeader || shardNode.ID == excluded {
+			continue
+		}
+		if _, _, err := snapshot.GetAliveShardLeader(shardNode.ID, now); err != nil {
+			continue
+		}
+		if !found {
+			picked, found = shardNode.ID, true
+			continue
+		}
+		numTables := len(snapshot.Topology.ShardViewsMapping[shardNode.ID].TableIDs)
+		numPickedTables := len(snapshot.Topology.ShardViewsMapping[picked].TableIDs)
+		if numTables < numPickedTables || (numTables == numPickedTables && shardNode.ID < picked) {
+			picked = shardNode.ID
+		}
+	}
+	return picked, found
+}
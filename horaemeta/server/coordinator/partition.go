@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// subTablePrefix is the prefix of the names of the sub tables, which is the same as the one used by HoraeDB.
+const subTablePrefix = "__"
+
+// formatSubTableName returns the name of the sub table of the partition, e.g. `__table_0`.
+func formatSubTableName(tableName, partitionName string) string {
+	return fmt.Sprintf("%s%s_%s", subTablePrefix, tableName, partitionName)
+}
+
+// withDefaultPartitions returns the create partition table request whose partitions are filled with the default partition count
+// if the client doesn't specify any, and the request is returned as it is otherwise. The partitions specified by the client always
+// take precedence over the default, and the request is never modified in place.
+// The filled request is the one dispatched to the data nodes, so the partition info kept by the meta is the same as the one on the data node.
+func withDefaultPartitions(req *metaservicepb.CreateTableRequest, defaultPartitionCount uint32) *metaservicepb.CreateTableRequest {
+	partitionTableInfo := req.GetPartitionTableInfo()
+	if defaultPartitionCount == 0 || partitionTableInfo == nil || len(partitionTableInfo.GetSubTableNames()) > 0 {
+		return req
+	}
+	// The partition method is unknown without the partition info.
+	if partitionTableInfo.GetPartitionInfo() == nil || len(partitionDefinitions(partitionTableInfo.GetPartitionInfo())) > 0 {
+		return req
+	}
+
+	definitions := make([]*clusterpb.PartitionDefinition, 0, defaultPartitionCount)
+	subTableNames := make([]string, 0, defaultPartitionCount)
+	for i := uint32(0); i < defaultPartitionCount; i++ {
+		partitionName := strconv.FormatUint(uint64(i), 10)
+		definitions = append(definitions, &clusterpb.PartitionDefinition{Name: partitionName, OriginName: nil})
+		subTableNames = append(subTableNames, formatSubTableName(req.GetName(), partitionName))
+	}
+
+	filledReq := proto.Clone(req).(*metaservicepb.CreateTableRequest)
+	switch info := filledReq.PartitionTableInfo.PartitionInfo.Info.(type) {
+	case *clusterpb.PartitionInfo_Hash:
+		info.Hash.Definitions = definitions
+	case *clusterpb.PartitionInfo_Key:
+		info.Key.Definitions = definitions
+	case *clusterpb.PartitionInfo_Random:
+		info.Random.Definitions = definitions
+	default:
+		return req
+	}
+	filledReq.PartitionTableInfo.SubTableNames = subTableNames
+	return filledReq
+}
+
+// validatePartitions checks the partitions of the create partition table request, which must be specified by the client or filled with
+// the default partition count, so that the partition info kept by the meta is the same as the one received by the data node.
+func validatePartitions(req *metaservicepb.CreateTableRequest) error {
+	subTableNames := req.GetPartitionTableInfo().GetSubTableNames()
+	if len(subTableNames) == 0 {
+		return ErrInvalidPartitions.WithCausef("no sub table is specified, table:%s", req.GetName())
+	}
+	partitionInfo := req.GetPartitionTableInfo().GetPartitionInfo()
+	if partitionInfo == nil {
+		return nil
+	}
+	if definitions := partitionDefinitions(partitionInfo); len(definitions) != len(subTableNames) {
+		return ErrInvalidPartitions.WithCausef("the partition definitions mismatch the sub tables, table:%s, definitions:%d, subTables:%d", req.GetName(), len(definitions), len(subTableNames))
+	}
+	return nil
+}
+
+func partitionDefinitions(partitionInfo *clusterpb.PartitionInfo) []*clusterpb.PartitionDefinition {
+	switch {
+	case partitionInfo.GetHash() != nil:
+		return partitionInfo.GetHash().GetDefinitions()
+	case partitionInfo.GetKey() != nil:
+		return partitionInfo.GetKey().GetDefinitions()
+	case partitionInfo.GetRandom() != nil:
+		return partitionInfo.GetRandom().GetDefinitions()
+	}
+	return nil
+}
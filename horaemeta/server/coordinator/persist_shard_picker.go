@@ -21,6 +21,7 @@ package coordinator
 
 import (
 	"context"
+	"sync"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -29,10 +30,43 @@ import (
 type PersistShardPicker struct {
 	cluster  *metadata.ClusterMetadata
 	internal ShardPicker
+
+	// lock makes picking the shards and counting the creations in flight on them atomic, so that the concurrent creations
+	// are spread over the shards instead of piling on the same one.
+	lock sync.Mutex
 }
 
 func NewPersistShardPicker(cluster *metadata.ClusterMetadata, internal ShardPicker) *PersistShardPicker {
-	return &PersistShardPicker{cluster: cluster, internal: internal}
+	return &PersistShardPicker{cluster: cluster, internal: internal, lock: sync.Mutex{}}
+}
+
+// PickShardsInflight picks the shards like PickShards, and the picked shards are counted as the table creations in flight
+// until they are released by ClusterMetadata.ReleaseInflightCreates with the returned ticket.
+// ErrTableQuotaExceeded is returned if the tables exceed the table quota with the creations in flight.
+func (p *PersistShardPicker) PickShardsInflight(ctx context.Context, snapshot metadata.Snapshot, schemaName string, tableNames []string) (map[string]storage.ShardNode, metadata.InflightCreatesTicket, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// The snapshot may be taken before the creations picked concurrently are counted.
+	snapshot.InflightCreates = p.cluster.GetInflightCreates()
+	// The creations over the quota are rejected before their tables are assigned to the shards.
+	if err := p.cluster.CheckTableQuota(snapshot, len(tableNames)); err != nil {
+		return map[string]storage.ShardNode{}, metadata.InflightCreatesTicket{}, err
+	}
+	result, err := p.PickShards(ctx, snapshot, schemaName, tableNames)
+	if err != nil {
+		return result, metadata.InflightCreatesTicket{}, err
+	}
+
+	shardIDs := make([]storage.ShardID, 0, len(result))
+	for _, shardNode := range result {
+		shardIDs = append(shardIDs, shardNode.ID)
+	}
+	ticket, err := p.cluster.AcquireInflightCreates(shardIDs)
+	if err != nil {
+		return map[string]storage.ShardNode{}, metadata.InflightCreatesTicket{}, err
+	}
+	return result, ticket, nil
 }
 
 func (p *PersistShardPicker) PickShards(ctx context.Context, snapshot metadata.Snapshot, schemaName string, tableNames []string) (map[string]storage.ShardNode, error) {
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Checkpointer persists the meta of the procedure after every fsm transition, which makes it possible to resume the procedure from its last committed
+// fsm state after the meta server restarts. Nothing is persisted if the checkpoint is disabled.
+type Checkpointer struct {
+	storage Storage
+	enabled bool
+}
+
+func NewCheckpointer(storage Storage, enabled bool) Checkpointer {
+	return Checkpointer{
+		storage: storage,
+		enabled: enabled,
+	}
+}
+
+func (c Checkpointer) Enabled() bool {
+	return c.enabled
+}
+
+// Commit persists the meta built by convertToMeta, and convertToMeta is not called at all if the checkpoint is disabled.
+func (c Checkpointer) Commit(ctx context.Context, convertToMeta func() (Meta, error)) error {
+	if !c.enabled {
+		return nil
+	}
+
+	meta, err := convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	if err := c.storage.CreateOrUpdate(ctx, meta); err != nil {
+		return errors.WithMessage(err, "checkpoint procedure")
+	}
+	return nil
+}
+
+// ListUnfinishedMetas returns the persisted procedures which are neither finished, failed nor cancelled, that is, the procedures left by the last
+// leader of the meta cluster.
+func ListUnfinishedMetas(ctx context.Context, storage Storage) ([]*Meta, error) {
+	var unfinished []*Meta
+	for _, kind := range allKinds {
+		metas, err := storage.List(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "list procedures, kind:%d", kind)
+		}
+		for _, meta := range metas {
+			if !isFinishedState(meta.State) {
+				unfinished = append(unfinished, meta)
+			}
+		}
+	}
+
+	return unfinished, nil
+}
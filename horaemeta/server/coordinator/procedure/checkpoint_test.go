@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointer(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	storage := NewTestStorage(t)
+	convertToMeta := func(id uint64, state State) func() (Meta, error) {
+		return func() (Meta, error) {
+			return Meta{ID: id, Kind: CreateTable, State: state, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""}, nil
+		}
+	}
+
+	// Nothing is persisted if the checkpoint is disabled.
+	re.NoError(NewCheckpointer(storage, false).Commit(ctx, convertToMeta(1, StateRunning)))
+	metas, err := ListUnfinishedMetas(ctx, storage)
+	re.NoError(err)
+	re.Empty(metas)
+
+	checkpointer := NewCheckpointer(storage, true)
+	re.NoError(checkpointer.Commit(ctx, convertToMeta(1, StateRunning)))
+	re.NoError(checkpointer.Commit(ctx, convertToMeta(2, StateFinished)))
+	re.NoError(checkpointer.Commit(ctx, convertToMeta(3, StateInit)))
+	metas, err = ListUnfinishedMetas(ctx, storage)
+	re.NoError(err)
+	re.Len(metas, 2)
+	re.Equal(uint64(1), metas[0].ID)
+	re.Equal(uint64(3), metas[1].ID)
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -71,6 +72,8 @@ type Procedure struct {
 	params                     ProcedureParams
 	relatedVersionInfo         procedure.RelatedVersionInfo
 	createPartitionTableResult *metadata.CreateTableMetadataResult
+	// progress tracks the sub tables created.
+	progress *procedure.ProgressTracker
 
 	lock  sync.RWMutex
 	state procedure.State
@@ -84,8 +87,11 @@ type ProcedureParams struct {
 	Storage         procedure.Storage
 	SourceReq       *metaservicepb.CreateTableRequest
 	SubTablesShards []metadata.ShardNodeWithVersion
-	OnSucceeded     func(metadata.CreateTableResult) error
-	OnFailed        func(error) error
+	// DispatchConcurrency is the max number of the shards creating the sub tables concurrently, zero means unlimited. The sub tables
+	// on the same shard are always created one by one, because every creation bumps the version of the shard.
+	DispatchConcurrency int
+	OnSucceeded         func(metadata.CreateTableResult) error
+	OnFailed            func(error) error
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
@@ -97,7 +103,7 @@ func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
 	fsm := fsm.NewFSM(
 		stateBegin,
 		createPartitionTableEvents,
-		createPartitionTableCallbacks,
+		procedure.WithFaultInjection(procedure.CreatePartitionTable, createPartitionTableCallbacks),
 	)
 
 	return &Procedure{
@@ -105,6 +111,7 @@ func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
 		params:                     params,
 		relatedVersionInfo:         relatedVersionInfo,
 		createPartitionTableResult: nil,
+		progress:                   procedure.NewProgressTracker(uint32(len(params.SourceReq.GetPartitionTableInfo().GetSubTableNames()))),
 		lock:                       sync.RWMutex{},
 		state:                      procedure.StateInit,
 	}, nil
@@ -139,6 +146,11 @@ func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
 	return p.relatedVersionInfo
 }
 
+// Progress returns the progress of creating the sub tables.
+func (p *Procedure) Progress() procedure.Progress {
+	return p.progress.Progress()
+}
+
 func (p *Procedure) Priority() procedure.Priority {
 	return procedure.PriorityLow
 }
@@ -252,60 +264,94 @@ func createDataTablesCallback(event *fsm.Event) {
 		}
 		shardTableMetaDatas[subTableShard.ShardInfo.ID] = append(shardTableMetaDatas[subTableShard.ShardInfo.ID], tableMetaData)
 	}
-	succeedCh := make(chan bool)
-	errCh := make(chan error)
+	concurrency := params.DispatchConcurrency
+	if concurrency <= 0 || concurrency > len(shardTableMetaDatas) {
+		concurrency = len(shardTableMetaDatas)
+	}
+	// Every sub table is required by the partition table, so all the results are collected and the procedure fails if any of them fails.
+	results := make(chan subTableResult, len(params.SubTablesShards))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
 	for shardID, tableMetaDatas := range shardTableMetaDatas {
-		shardVersion := shardVersions[shardID]
-		go createDataTables(req, shardID, tableMetaDatas, shardVersion, succeedCh, errCh)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(shardID storage.ShardID, tableMetaDatas []metadata.CreateTableMetadataRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			createDataTables(req, shardID, tableMetaDatas, shardVersions[shardID], results)
+		}(shardID, tableMetaDatas)
+	}
+	wg.Wait()
+	close(results)
+
+	var firstErr error
+	failedSubTables := make([]string, 0)
+	for result := range results {
+		if result.err == nil {
+			continue
+		}
+		log.Error("create sub table failed", zap.String("tableName", params.SourceReq.GetName()), zap.String("subTableName", result.tableName), zap.Error(result.err))
+		if firstErr == nil {
+			firstErr = result.err
+		}
+		failedSubTables = append(failedSubTables, result.tableName)
+	}
+	if firstErr != nil {
+		procedure.CancelEventWithLog(event, errors.WithMessagef(firstErr, "failed sub tables:%v", failedSubTables), "create data tables")
+		return
 	}
+}
 
-	goRoutineNumber := len(shardTableMetaDatas)
-	for {
-		select {
-		case err := <-errCh:
-			procedure.CancelEventWithLog(event, err, "create data tables")
-			return
-		case <-succeedCh:
-			goRoutineNumber--
-			if goRoutineNumber == 0 {
-				return
+// subTableResult is the result of creating a sub table, and err is nil if it succeeds.
+type subTableResult struct {
+	tableName string
+	err       error
+}
+
+// createDataTables creates the sub tables on the shard one by one, and the ones following a failed sub table are skipped.
+func createDataTables(req *callbackRequest, shardID storage.ShardID, tableMetaDatas []metadata.CreateTableMetadataRequest, shardVersion uint64, results chan<- subTableResult) {
+	for i, tableMetaData := range tableMetaDatas {
+		if err := createDataTable(req, shardID, tableMetaData, shardVersion); err != nil {
+			results <- subTableResult{tableName: tableMetaData.TableName, err: err}
+			for _, skipped := range tableMetaDatas[i+1:] {
+				results <- subTableResult{tableName: skipped.TableName, err: errors.WithMessagef(err, "skipped after the failure of sub table %s on shard %d", tableMetaData.TableName, shardID)}
 			}
+			return
 		}
+		results <- subTableResult{tableName: tableMetaData.TableName, err: nil}
+		req.p.progress.Advance()
+		shardVersion++
 	}
 }
 
-func createDataTables(req *callbackRequest, shardID storage.ShardID, tableMetaDatas []metadata.CreateTableMetadataRequest, shardVersion uint64, succeedCh chan bool, errCh chan error) {
+func createDataTable(req *callbackRequest, shardID storage.ShardID, tableMetaData metadata.CreateTableMetadataRequest, shardVersion uint64) error {
 	params := req.p.params
 
-	for _, tableMetaData := range tableMetaDatas {
-		result, err := params.ClusterMetadata.CreateTableMetadata(req.ctx, tableMetaData)
-		if err != nil {
-			errCh <- errors.WithMessage(err, "create table metadata")
-			return
-		}
+	result, err := params.ClusterMetadata.CreateTableMetadata(req.ctx, tableMetaData)
+	if err != nil {
+		return errors.WithMessage(err, "create table metadata")
+	}
 
-		shardVersionUpdate := metadata.ShardVersionUpdate{
-			ShardID:       shardID,
-			LatestVersion: shardVersion,
-		}
+	shardVersionUpdate := metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: shardVersion,
+	}
 
-		latestShardVersion, err := ddl.CreateTableOnShard(req.ctx, params.ClusterMetadata, params.Dispatch, shardID, ddl.BuildCreateTableRequest(result.Table, shardVersionUpdate, params.SourceReq))
-		if err != nil {
-			errCh <- errors.WithMessage(err, "dispatch create table on shard")
-			return
-		}
+	latestShardVersion, err := ddl.CreateTableOnShard(req.ctx, params.ClusterMetadata, params.Dispatch, shardID, ddl.BuildCreateTableRequest(result.Table, shardVersionUpdate, params.SourceReq))
+	if err != nil {
+		return errors.WithMessage(err, "dispatch create table on shard")
+	}
 
-		err = params.ClusterMetadata.AddTableTopology(req.ctx, metadata.ShardVersionUpdate{
-			ShardID:       shardID,
-			LatestVersion: latestShardVersion,
-		}, result.Table)
-		if err != nil {
-			errCh <- errors.WithMessage(err, "create table metadata")
-			return
-		}
-		shardVersion++
+	err = params.ClusterMetadata.AddTableTopology(req.ctx, metadata.ShardVersionUpdate{
+		ShardID:       shardID,
+		LatestVersion: latestShardVersion,
+	}, result.Table)
+	if err != nil {
+		return errors.WithMessage(err, "create table metadata")
 	}
-	succeedCh <- true
+	return nil
 }
 
 func finishCallback(event *fsm.Event) {
@@ -335,7 +381,7 @@ func (p *Procedure) updateStateWithLock(state procedure.State) {
 }
 
 func (p *Procedure) persist(ctx context.Context) error {
-	meta, err := p.convertToMeta()
+	meta, err := p.convertToMeta(procedure.InitiatorFromContext(ctx))
 	if err != nil {
 		return errors.WithMessage(err, "convert to meta")
 	}
@@ -355,9 +401,10 @@ type rawData struct {
 	CreateTableResult    *metadata.CreateTableResult
 	PartitionTableShards []metadata.ShardNodeWithVersion
 	SubTablesShards      []metadata.ShardNodeWithVersion
+	SourceReq            *metaservicepb.CreateTableRequest
 }
 
-func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+func (p *Procedure) convertToMeta(initiator string) (procedure.Meta, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
@@ -368,6 +415,7 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		CreateTableResult:    nil,
 		PartitionTableShards: []metadata.ShardNodeWithVersion{},
 		SubTablesShards:      p.params.SubTablesShards,
+		SourceReq:            p.params.SourceReq,
 	}
 	rawDataBytes, err := json.Marshal(rawData)
 	if err != nil {
@@ -380,7 +428,9 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		Kind:  procedure.CreatePartitionTable,
 		State: p.state,
 
-		RawData: rawDataBytes,
+		RawData:   rawDataBytes,
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: initiator,
 	}
 
 	return meta, nil
@@ -21,10 +21,14 @@ package createpartitiontable_test
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/createpartitiontable"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -86,9 +90,111 @@ func TestCreatePartitionTable(t *testing.T) {
 		OnFailed: func(err error) error {
 			return nil
 		},
+		DispatchConcurrency: 0,
 	})
 	re.NoError(err)
+	p, ok := procedure.(*createpartitiontable.Procedure)
+	re.True(ok)
+	re.Equal(uint32(0), p.Progress().Done)
+	re.Equal(uint32(2), p.Progress().Total)
 
 	err = procedure.Start(ctx)
 	re.NoError(err)
+	re.Equal(uint32(2), p.Progress().Done)
+	re.Equal(1.0, p.Progress().Fraction)
+}
+
+// countingDispatch records the max number of the sub tables created on the shards concurrently, and fails the creation of the sub
+// table named failedTable.
+type countingDispatch struct {
+	test.MockDispatch
+	failedTable string
+	inflight    atomic.Int32
+	maxInflight atomic.Int32
+}
+
+func (d *countingDispatch) CreateTableOnShard(_ context.Context, _ string, req eventdispatch.CreateTableOnShardRequest) (uint64, error) {
+	inflight := d.inflight.Add(1)
+	defer d.inflight.Add(-1)
+	for {
+		maxInflight := d.maxInflight.Load()
+		if inflight <= maxInflight || d.maxInflight.CompareAndSwap(maxInflight, inflight) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if req.TableInfo.Name == d.failedTable {
+		return 0, fmt.Errorf("injected failure, table:%s", req.TableInfo.Name)
+	}
+	return req.UpdateShardInfo.CurrShardInfo.Version + 1, nil
+}
+
+func TestCreatePartitionTableDispatchConcurrency(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	const numSubTables = test.DefaultShardTotal * 3
+	newProcedure := func(c *metadata.ClusterMetadata, dispatch *countingDispatch, tableName string, concurrency int) *createpartitiontable.Procedure {
+		snapshot := c.GetClusterSnapshot()
+		subTableNames := make([]string, 0, numSubTables)
+		shardNodesWithVersion := make([]metadata.ShardNodeWithVersion, 0, numSubTables)
+		for i := 0; i < numSubTables; i++ {
+			subTableNames = append(subTableNames, fmt.Sprintf("__%s_%d", tableName, i))
+			shardNode := snapshot.Topology.ClusterView.ShardNodes[i%test.DefaultShardTotal]
+			shardView := snapshot.Topology.ShardViewsMapping[shardNode.ID]
+			shardNodesWithVersion = append(shardNodesWithVersion, metadata.ShardNodeWithVersion{
+				ShardInfo: metadata.ShardInfo{
+					ID:      shardView.ShardID,
+					Role:    shardNode.ShardRole,
+					Version: shardView.Version,
+					Status:  storage.ShardStatusUnknown,
+				},
+				ShardNode: shardNode,
+			})
+		}
+
+		p, err := createpartitiontable.NewProcedure(createpartitiontable.ProcedureParams{
+			ID:              0,
+			ClusterMetadata: c,
+			ClusterSnapshot: snapshot,
+			Dispatch:        dispatch,
+			Storage:         test.NewTestStorage(t),
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header:             &metaservicepb.RequestHeader{ClusterName: test.ClusterName},
+				PartitionTableInfo: &metaservicepb.PartitionTableInfo{SubTableNames: subTableNames},
+				SchemaName:         test.TestSchemaName,
+				Name:               tableName,
+			},
+			SubTablesShards: shardNodesWithVersion,
+			OnSucceeded: func(_ metadata.CreateTableResult) error {
+				return nil
+			},
+			OnFailed: func(_ error) error {
+				return nil
+			},
+			DispatchConcurrency: concurrency,
+		})
+		re.NoError(err)
+		return p.(*createpartitiontable.Procedure)
+	}
+
+	// The shards creating the sub tables concurrently are bounded by the concurrency.
+	c := test.InitStableCluster(ctx, t).GetMetadata()
+	dispatch := &countingDispatch{failedTable: ""}
+	p := newProcedure(c, dispatch, "wide_table", 2)
+	re.NoError(p.Start(ctx))
+	re.Equal(uint32(numSubTables), p.Progress().Done)
+	re.Equal(int32(2), dispatch.maxInflight.Load())
+
+	// All the sub tables on the other shards are still created if one of them fails, but the procedure fails.
+	dispatch = &countingDispatch{failedTable: "__failed_table_0"}
+	p = newProcedure(c, dispatch, "failed_table", 0)
+	re.Error(p.Start(ctx))
+	re.LessOrEqual(dispatch.maxInflight.Load(), int32(test.DefaultShardTotal))
+	// The sub tables following the failed one on the same shard are skipped.
+	re.Equal(uint32(numSubTables-3), p.Progress().Done)
+	_, exists, err := c.GetTable(test.TestSchemaName, "__failed_table_1")
+	re.NoError(err)
+	re.True(exists)
 }
@@ -21,9 +21,10 @@ package createtable
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
+	"time"
 
-	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
@@ -140,13 +141,22 @@ func createOnShard(event *fsm.Event) {
 		return
 	}
 
+	// The shard whose leader is offline is re-picked by the factory before the procedure is submitted, and the procedure holding the
+	// lock of the shard never moves to another shard, so it fails if the leader goes offline afterwards.
+	shardID := params.ShardID
+	if _, _, err := params.ClusterMetadata.GetClusterSnapshot().GetAliveShardLeader(shardID, time.Now()); err != nil {
+		procedure.CancelEventWithLog(event, err, "target shard is offline", zap.String("tableName", table.Name), zap.Uint32("shardID", uint32(shardID)))
+		return
+	}
+	shardVersion := req.p.relatedVersionInfo.ShardWithVersion[shardID]
+
 	shardVersionUpdate := metadata.ShardVersionUpdate{
-		ShardID:       params.ShardID,
-		LatestVersion: req.p.relatedVersionInfo.ShardWithVersion[params.ShardID],
+		ShardID:       shardID,
+		LatestVersion: shardVersion,
 	}
 
 	createTableRequest := ddl.BuildCreateTableRequest(table, shardVersionUpdate, params.SourceReq)
-	latestShardVersion, err := ddl.CreateTableOnShard(req.ctx, params.ClusterMetadata, params.Dispatch, params.ShardID, createTableRequest)
+	latestShardVersion, err := ddl.CreateTableOnShard(req.ctx, params.ClusterMetadata, params.Dispatch, shardID, createTableRequest)
 	if err != nil {
 		procedure.CancelEventWithLog(event, err, "dispatch create table on shard")
 		return
@@ -155,7 +165,7 @@ func createOnShard(event *fsm.Event) {
 	log.Debug("dispatch createTableOnShard finish", zap.String("tableName", table.Name))
 
 	shardVersionUpdate = metadata.ShardVersionUpdate{
-		ShardID:       params.ShardID,
+		ShardID:       shardID,
 		LatestVersion: latestShardVersion,
 	}
 
@@ -185,7 +195,11 @@ func createFinish(event *fsm.Event) {
 		log.Warn("delete assign table failed", zap.String("schemaName", params.SourceReq.GetSchemaName()), zap.String("tableName", params.SourceReq.GetName()))
 	}
 
-	assert.Assert(req.createTableResult != nil)
+	// The result is lost if the procedure is resumed after the table is created on the shard, and the caller waiting for it has gone anyway.
+	if req.createTableResult == nil {
+		log.Info("create table procedure resumed after creating on shard", zap.String("tableName", params.SourceReq.GetName()), zap.Uint64("procedureID", params.ID))
+		return
+	}
 	if err := req.p.params.OnSucceeded(*req.createTableResult); err != nil {
 		log.Error("exec success callback failed")
 	}
@@ -208,13 +222,27 @@ type ProcedureParams struct {
 	SourceReq       *metaservicepb.CreateTableRequest
 	OnSucceeded     func(metadata.CreateTableResult) error
 	OnFailed        func(error) error
+
+	Checkpointer procedure.Checkpointer
+	// ResumeState is the last committed fsm state to resume the procedure from, and the procedure starts from the beginning if it is empty.
+	ResumeState string
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	initialState := stateBegin
+	if len(params.ResumeState) != 0 {
+		switch params.ResumeState {
+		case stateBegin, stateCheckTableExists, stateCreateMetadata, stateCreateOnShard, stateFinish:
+			initialState = params.ResumeState
+		default:
+			return nil, procedure.ErrInvalidResumeState.WithCausef("procedureID:%d, fsmState:%s", params.ID, params.ResumeState)
+		}
+	}
+
 	fsm := fsm.NewFSM(
-		stateBegin,
+		initialState,
 		createTableEvents,
-		createTableCallbacks,
+		procedure.WithFaultInjection(procedure.CreateTable, createTableCallbacks),
 	)
 
 	relatedVersionInfo, err := buildRelatedVersionInfo(params)
@@ -280,32 +308,88 @@ func (p *Procedure) Start(ctx context.Context) error {
 		createTableResult: nil,
 	}
 
+	if err := p.checkpoint(ctx); err != nil {
+		return p.fail(ctx, err)
+	}
+
 	for {
+		var event string
 		switch p.fsm.Current() {
 		case stateBegin:
-			if err := p.fsm.Event(eventCheckTableExists, req); err != nil {
-				_ = p.params.OnFailed(err)
-				return err
-			}
+			event = eventCheckTableExists
 		case stateCheckTableExists:
-			if err := p.fsm.Event(eventCreateMetadata, req); err != nil {
-				_ = p.params.OnFailed(err)
-				return err
-			}
+			event = eventCreateMetadata
 		case stateCreateMetadata:
-			if err := p.fsm.Event(eventCreateOnShard, req); err != nil {
-				_ = p.params.OnFailed(err)
-				return err
-			}
+			event = eventCreateOnShard
 		case stateCreateOnShard:
-			if err := p.fsm.Event(eventFinish, req); err != nil {
-				_ = p.params.OnFailed(err)
-				return err
-			}
+			event = eventFinish
 		case stateFinish:
 			p.updateState(procedure.StateFinished)
+			if err := p.checkpoint(ctx); err != nil {
+				log.Warn("checkpoint finished procedure", zap.Uint64("procedureID", p.params.ID), zap.Error(err))
+			}
 			return nil
 		}
+
+		if err := p.fsm.Event(event, req); err != nil {
+			return p.fail(ctx, err)
+		}
+		if err := p.checkpoint(ctx); err != nil {
+			return p.fail(ctx, err)
+		}
+	}
+}
+
+func (p *Procedure) fail(ctx context.Context, err error) error {
+	p.updateState(procedure.StateFailed)
+	if err := p.checkpoint(ctx); err != nil {
+		log.Warn("checkpoint failed procedure", zap.Uint64("procedureID", p.params.ID), zap.Error(err))
+	}
+	_ = p.params.OnFailed(err)
+	return err
+}
+
+// checkpoint commits the current fsm state, so the procedure can be resumed from it after the meta server restarts.
+func (p *Procedure) checkpoint(ctx context.Context) error {
+	return p.params.Checkpointer.Commit(ctx, p.convertToMeta(procedure.InitiatorFromContext(ctx)))
+}
+
+// RawData is the persisted raw data of the procedure.
+type RawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	ShardID   storage.ShardID
+	SourceReq *metaservicepb.CreateTableRequest
+}
+
+func (p *Procedure) convertToMeta(initiator string) func() (procedure.Meta, error) {
+	return func() (procedure.Meta, error) {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+
+		rawData := RawData{
+			ID:        p.params.ID,
+			FsmState:  p.fsm.Current(),
+			State:     p.state,
+			ShardID:   p.params.ShardID,
+			SourceReq: p.params.SourceReq,
+		}
+		rawDataBytes, err := json.Marshal(rawData)
+		if err != nil {
+			var emptyMeta procedure.Meta
+			return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%v, err:%v", p.params.ID, err)
+		}
+
+		return procedure.Meta{
+			ID:        p.params.ID,
+			Kind:      procedure.CreateTable,
+			State:     p.state,
+			RawData:   rawDataBytes,
+			UpdatedAt: uint64(time.Now().UnixMilli()),
+			Initiator: initiator,
+		}, nil
 	}
 }
 
@@ -21,12 +21,18 @@ package createtable_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/createtable"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
 	"github.com/stretchr/testify/require"
 )
@@ -67,3 +73,142 @@ func TestCreateTable(t *testing.T) {
 	err = p.Start(context.Background())
 	re.NoError(err)
 }
+
+func TestCreateTableCheckpoint(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitStableCluster(ctx, t)
+	_, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+	procedureStorage := procedure.NewEtcdStorageImpl(client, "/horaemeta", 1)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	shardNode := snapshot.Topology.ClusterView.ShardNodes[0]
+	newParams := func(id uint64, tableName, resumeState string) createtable.ProcedureParams {
+		return createtable.ProcedureParams{
+			Dispatch:        dispatch,
+			ClusterMetadata: c.GetMetadata(),
+			ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+			ID:              id,
+			ShardID:         shardNode.ID,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header: &metaservicepb.RequestHeader{
+					Node:        shardNode.NodeName,
+					ClusterName: test.ClusterName,
+				},
+				SchemaName: test.TestSchemaName,
+				Name:       tableName,
+			},
+			OnSucceeded: func(_ metadata.CreateTableResult) error {
+				return nil
+			},
+			OnFailed: func(err error) error {
+				panic(fmt.Sprintf("create table failed, err:%v", err))
+			},
+			Checkpointer: procedure.NewCheckpointer(procedureStorage, true),
+			ResumeState:  resumeState,
+		}
+	}
+
+	// The finished procedure is checkpointed with the final fsm state.
+	p, err := createtable.NewProcedure(newParams(1, test.TestTableName0, ""))
+	re.NoError(err)
+	re.NoError(p.Start(ctx))
+	metas, err := procedureStorage.List(ctx, procedure.CreateTable, 10)
+	re.NoError(err)
+	re.Len(metas, 1)
+	re.Equal(procedure.StateFinished, string(metas[0].State))
+	var rawData createtable.RawData
+	re.NoError(json.Unmarshal(metas[0].RawData, &rawData))
+	re.Equal("StateFinish", rawData.FsmState)
+	re.Equal(shardNode.ID, rawData.ShardID)
+	re.Equal(test.TestTableName0, rawData.SourceReq.GetName())
+
+	// Resume the procedure crashed after the table metadata is created.
+	_, err = c.GetMetadata().CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName1,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	p, err = createtable.NewProcedure(newParams(2, test.TestTableName1, "StateCreateMetadata"))
+	re.NoError(err)
+	re.NoError(p.Start(ctx))
+	table, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, test.TestTableName1)
+	re.NoError(err)
+	re.True(exists)
+	_, exists = c.GetMetadata().GetTableShard(ctx, table)
+	re.True(exists)
+
+	_, err = createtable.NewProcedure(newParams(3, test.TestTableName1, "StateUnknown"))
+	re.True(coderr.Is(err, procedure.ErrInvalidResumeState.Code()))
+}
+
+func TestCreateTableOnOfflineShard(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitPrepareCluster(ctx, t)
+
+	// Place the shards on the nodes in turn, and take the node of the picked shard offline.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	shardNodes := make([]storage.ShardNode, 0, test.DefaultShardTotal)
+	for i := 0; i < test.DefaultShardTotal; i++ {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        storage.ShardID(i),
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  snapshot.RegisteredNodes[i%len(snapshot.RegisteredNodes)].Node.Name,
+		})
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+	offlineShardNode := shardNodes[0]
+	re.NoError(c.GetMetadata().RegisterNode(ctx, metadata.RegisteredNode{
+		Node: storage.Node{
+			Name:          offlineShardNode.NodeName,
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: uint64(time.Now().Add(-time.Hour).UnixMilli()),
+			State:         storage.NodeStateOnline,
+		},
+		ShardInfos: nil,
+	}))
+
+	newProcedure := func(id uint64, tableName string, onSucceeded func(metadata.CreateTableResult) error, onFailed func(error) error) procedure.Procedure {
+		p, err := createtable.NewProcedure(createtable.ProcedureParams{
+			Dispatch:        dispatch,
+			ClusterMetadata: c.GetMetadata(),
+			ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+			ID:              id,
+			ShardID:         offlineShardNode.ID,
+			SourceReq: &metaservicepb.CreateTableRequest{
+				Header: &metaservicepb.RequestHeader{
+					Node:        offlineShardNode.NodeName,
+					ClusterName: test.ClusterName,
+				},
+				SchemaName: test.TestSchemaName,
+				Name:       tableName,
+			},
+			OnSucceeded:  onSucceeded,
+			OnFailed:     onFailed,
+			Checkpointer: procedure.NewCheckpointer(test.NewTestStorage(t), false),
+			ResumeState:  "",
+		})
+		re.NoError(err)
+		return p
+	}
+
+	// The procedure never moves to another shard, so it fails whatever the offline shard policy is.
+	for i, policy := range []metadata.OfflineShardPolicy{metadata.OfflineShardPolicyFail, metadata.OfflineShardPolicyRepick} {
+		c.GetMetadata().UpdateCreateTableOfflineShardPolicy(policy)
+		var failedErr error
+		p := newProcedure(uint64(i+1), test.TestTableName0, func(_ metadata.CreateTableResult) error {
+			panic("create table on the offline shard should fail")
+		}, func(err error) error {
+			failedErr = err
+			return nil
+		})
+		re.Error(p.Start(ctx))
+		re.True(coderr.Is(failedErr, metadata.ErrShardNotOnline.Code()))
+		re.Equal(map[storage.ShardID]uint64{offlineShardNode.ID: 0}, p.RelatedVersionInfo().ShardWithVersion)
+	}
+}
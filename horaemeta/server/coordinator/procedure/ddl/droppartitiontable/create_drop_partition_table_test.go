@@ -139,6 +139,7 @@ func testCreatePartitionTable(ctx context.Context, t *testing.T, dispatch eventd
 		OnFailed: func(err error) error {
 			return nil
 		},
+		DispatchConcurrency: 0,
 	})
 	re.NoError(err)
 
@@ -172,8 +173,13 @@ func testDropPartitionTable(t *testing.T, dispatch eventdispatch.Dispatch, c *cl
 	procedure, ok, err := droppartitiontable.NewProcedure(req)
 	re.NoError(err)
 	re.True(ok)
+	total := uint32(len(subTableNames))
+	re.Equal(uint32(0), procedure.Progress().Done)
+	re.Equal(total, procedure.Progress().Total)
 	err = procedure.Start(context.Background())
 	re.NoError(err)
+	re.Equal(total, procedure.Progress().Done)
+	re.Equal(1.0, procedure.Progress().Fraction)
 }
 
 func genSubTables(tableName string, tableNum int) []string {
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -70,6 +71,8 @@ type Procedure struct {
 	fsm                *fsm.FSM
 	params             ProcedureParams
 	relatedVersionInfo procedure.RelatedVersionInfo
+	// progress tracks the sub tables dropped.
+	progress *procedure.ProgressTracker
 
 	// Protect the state.
 	lock  sync.RWMutex
@@ -91,7 +94,7 @@ func NewProcedure(params ProcedureParams) (*Procedure, bool, error) {
 	fsm := fsm.NewFSM(
 		stateBegin,
 		createDropPartitionTableEvents,
-		createDropPartitionTableCallbacks,
+		procedure.WithFaultInjection(procedure.DropPartitionTable, createDropPartitionTableCallbacks),
 	)
 	relatedVersionInfo, err := buildRelatedVersionInfo(params)
 	if err != nil {
@@ -102,6 +105,7 @@ func NewProcedure(params ProcedureParams) (*Procedure, bool, error) {
 		fsm:                fsm,
 		params:             params,
 		relatedVersionInfo: relatedVersionInfo,
+		progress:           procedure.NewProgressTracker(uint32(len(params.SourceReq.GetPartitionTableInfo().GetSubTableNames()))),
 		lock:               sync.RWMutex{},
 		state:              stateBegin,
 	}, true, nil
@@ -154,6 +158,11 @@ func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
 	return p.relatedVersionInfo
 }
 
+// Progress returns the progress of dropping the sub tables.
+func (p *Procedure) Progress() procedure.Progress {
+	return p.progress.Progress()
+}
+
 func (p *Procedure) Priority() procedure.Priority {
 	return procedure.PriorityMed
 }
@@ -228,7 +237,7 @@ func (p *Procedure) updateStateWithLock(state procedure.State) {
 }
 
 func (p *Procedure) persist(ctx context.Context) error {
-	meta, err := p.convertToMeta()
+	meta, err := p.convertToMeta(procedure.InitiatorFromContext(ctx))
 	if err != nil {
 		return errors.WithMessage(err, "convert to meta")
 	}
@@ -239,7 +248,7 @@ func (p *Procedure) persist(ctx context.Context) error {
 	return nil
 }
 
-func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+func (p *Procedure) convertToMeta(initiator string) (procedure.Meta, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
@@ -260,7 +269,9 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		Kind:  procedure.DropPartitionTable,
 		State: p.state,
 
-		RawData: rawDataBytes,
+		RawData:   rawDataBytes,
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: initiator,
 	}
 
 	return meta, nil
@@ -312,6 +323,7 @@ func dropDataTablesCallback(event *fsm.Event) {
 		table, err := ddl.GetTableMetadata(params.ClusterMetadata, req.schemaName(), tableName)
 		if err != nil {
 			log.Warn("get table metadata failed", zap.String("tableName", tableName))
+			req.p.progress.Advance()
 			continue
 		}
 
@@ -329,6 +341,7 @@ func dropDataTablesCallback(event *fsm.Event) {
 				procedure.CancelEventWithLog(event, err, "drop table metadata", zap.String("tableName", tableName))
 				return
 			}
+			req.p.progress.Advance()
 			continue
 		}
 
@@ -417,6 +430,7 @@ func dispatchDropDataTable(req *callbackRequest, dispatch eventdispatch.Dispatch
 		if err != nil {
 			return errors.WithMessagef(err, "drop table, table:%s", tableName)
 		}
+		req.p.progress.Advance()
 
 		shardVersion++
 	}
@@ -21,7 +21,9 @@ package droptable
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -160,6 +162,8 @@ type ProcedureParams struct {
 	SourceReq   *metaservicepb.DropTableRequest
 	OnSucceeded func(metadata.TableInfo) error
 	OnFailed    func(error) error
+
+	Checkpointer procedure.Checkpointer
 }
 
 func NewDropTableProcedure(params ProcedureParams) (procedure.Procedure, bool, error) {
@@ -186,7 +190,7 @@ func NewDropTableProcedure(params ProcedureParams) (procedure.Procedure, bool, e
 	fsm := fsm.NewFSM(
 		stateBegin,
 		dropTableEvents,
-		dropTableCallbacks,
+		procedure.WithFaultInjection(procedure.DropTable, dropTableCallbacks),
 	)
 
 	return &Procedure{
@@ -260,23 +264,81 @@ func (p *Procedure) Start(ctx context.Context) error {
 		droppedTable: nil,
 	}
 
+	if err := p.checkpoint(ctx); err != nil {
+		p.updateState(procedure.StateFailed)
+		_ = p.params.OnFailed(err)
+		return errors.WithMessage(err, "checkpoint procedure")
+	}
+
 	if err := p.fsm.Event(eventPrepare, req); err != nil {
 		err1 := p.fsm.Event(eventFailed, req)
 		p.updateState(procedure.StateFailed)
+		p.checkpointWithLog(ctx)
 		if err1 != nil {
 			err = errors.WithMessagef(err, "send eventFailed, err:%v", err1)
 		}
 		return errors.WithMessage(err, "send eventPrepare")
 	}
+	// The table has been dropped, so the procedure goes on even if the checkpoint fails.
+	p.checkpointWithLog(ctx)
 
 	if err := p.fsm.Event(eventSuccess, req); err != nil {
 		return errors.WithMessage(err, "send eventSuccess")
 	}
 
 	p.updateState(procedure.StateFinished)
+	p.checkpointWithLog(ctx)
 	return nil
 }
 
+// checkpoint commits the current fsm state, and the procedure persisted before the table is dropped is started over after the meta server restarts.
+func (p *Procedure) checkpoint(ctx context.Context) error {
+	return p.params.Checkpointer.Commit(ctx, p.convertToMeta(procedure.InitiatorFromContext(ctx)))
+}
+
+func (p *Procedure) checkpointWithLog(ctx context.Context) {
+	if err := p.checkpoint(ctx); err != nil {
+		log.Warn("checkpoint procedure", zap.Uint64("procedureID", p.params.ID), zap.Error(err))
+	}
+}
+
+// RawData is the persisted raw data of the procedure.
+type RawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	SourceReq *metaservicepb.DropTableRequest
+}
+
+func (p *Procedure) convertToMeta(initiator string) func() (procedure.Meta, error) {
+	return func() (procedure.Meta, error) {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+
+		rawData := RawData{
+			ID:        p.params.ID,
+			FsmState:  p.fsm.Current(),
+			State:     p.state,
+			SourceReq: p.params.SourceReq,
+		}
+		rawDataBytes, err := json.Marshal(rawData)
+		if err != nil {
+			var emptyMeta procedure.Meta
+			return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%v, err:%v", p.params.ID, err)
+		}
+
+		return procedure.Meta{
+			ID:        p.params.ID,
+			Kind:      procedure.DropTable,
+			State:     p.state,
+			RawData:   rawDataBytes,
+			UpdatedAt: uint64(time.Now().UnixMilli()),
+			Initiator: initiator,
+		}, nil
+	}
+}
+
 func (p *Procedure) Cancel(_ context.Context) error {
 	p.updateState(procedure.StateCancelled)
 	return nil
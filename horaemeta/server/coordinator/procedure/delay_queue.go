@@ -33,12 +33,19 @@ type procedureScheduleEntry struct {
 	runAfter  time.Time
 }
 
+// DelayQueue is a priority queue of the procedures which can be popped after their delays.
+// The procedures are moved from the delayQueue to the readyQueue once their delays expire, and the ready procedure with
+// the highest priority is popped first, so that the urgent procedures, e.g. transferring leaders, are not blocked behind
+// the routine ones.
 type DelayQueue struct {
 	maxLen int
 
 	// This lock is used to protect the following fields.
-	lock      sync.RWMutex
-	heapQueue *heapPriorityQueue
+	lock sync.RWMutex
+	// delayQueue holds the procedures whose delays haven't expired, ordered by the runAfter.
+	delayQueue *heapPriorityQueue
+	// readyQueue holds the procedures whose delays have expired, ordered by the priority and then the runAfter.
+	readyQueue *heapPriorityQueue
 	// existingProcs is used to record procedures has been pushed into the queue,
 	// and they will be used to verify the addition of duplicate elements.
 	existingProcs map[uint64]struct{}
@@ -48,16 +55,16 @@ type DelayQueue struct {
 // and its thread safety is guaranteed by the external caller.
 type heapPriorityQueue struct {
 	procedures []*procedureScheduleEntry
+	// less determines the dequeue order of elements, and the smallest element will be popped first.
+	less func(a, b *procedureScheduleEntry) bool
 }
 
 func (q *heapPriorityQueue) Len() int {
 	return len(q.procedures)
 }
 
-// The dequeue order of elements is determined by the less method.
-// When return procedures[i].runAfter < procedures[j].runAfter, the element with smallest will be pop first.
 func (q *heapPriorityQueue) Less(i, j int) bool {
-	return q.procedures[i].runAfter.Before(q.procedures[j].runAfter)
+	return q.less(q.procedures[i], q.procedures[j])
 }
 
 func (q *heapPriorityQueue) Swap(i, j int) {
@@ -88,12 +95,29 @@ func (q *heapPriorityQueue) Peek() any {
 	return item
 }
 
+// lessByRunAfter makes the procedure to run earlier be popped first.
+func lessByRunAfter(a, b *procedureScheduleEntry) bool {
+	return a.runAfter.Before(b.runAfter)
+}
+
+// lessByPriority makes the procedure with higher priority be popped first, and the procedures with the same priority
+// are popped in the order of runAfter.
+// Note: the smaller value of Priority means the higher priority.
+func lessByPriority(a, b *procedureScheduleEntry) bool {
+	priorityA, priorityB := a.procedure.Priority(), b.procedure.Priority()
+	if priorityA != priorityB {
+		return priorityA < priorityB
+	}
+	return lessByRunAfter(a, b)
+}
+
 func NewProcedureDelayQueue(maxLen int) *DelayQueue {
 	return &DelayQueue{
 		maxLen: maxLen,
 
 		lock:          sync.RWMutex{},
-		heapQueue:     &heapPriorityQueue{procedures: []*procedureScheduleEntry{}},
+		delayQueue:    &heapPriorityQueue{procedures: []*procedureScheduleEntry{}, less: lessByRunAfter},
+		readyQueue:    &heapPriorityQueue{procedures: []*procedureScheduleEntry{}, less: lessByPriority},
 		existingProcs: map[uint64]struct{}{},
 	}
 }
@@ -102,14 +126,14 @@ func (q *DelayQueue) Len() int {
 	q.lock.RLock()
 	defer q.lock.RUnlock()
 
-	return q.heapQueue.Len()
+	return q.delayQueue.Len() + q.readyQueue.Len()
 }
 
 func (q *DelayQueue) Push(p Procedure, delay time.Duration) error {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if q.heapQueue.Len() >= q.maxLen {
+	if q.delayQueue.Len()+q.readyQueue.Len() >= q.maxLen {
 		return errors.WithMessage(ErrQueueFull, fmt.Sprintf("queue max length is %d", q.maxLen))
 	}
 
@@ -117,7 +141,7 @@ func (q *DelayQueue) Push(p Procedure, delay time.Duration) error {
 		return errors.WithMessage(ErrPushDuplicatedProcedure, fmt.Sprintf("procedure has been pushed, %v", p))
 	}
 
-	heap.Push(q.heapQueue, &procedureScheduleEntry{
+	heap.Push(q.delayQueue, &procedureScheduleEntry{
 		procedure: p,
 		runAfter:  time.Now().Add(delay),
 	})
@@ -126,20 +150,26 @@ func (q *DelayQueue) Push(p Procedure, delay time.Duration) error {
 	return nil
 }
 
+// Pop pops the ready procedure with the highest priority, and nil is returned if no procedure is ready.
 func (q *DelayQueue) Pop() Procedure {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if q.heapQueue.Len() == 0 {
-		return nil
+	now := time.Now()
+	for q.delayQueue.Len() > 0 {
+		entry := q.delayQueue.Peek().(*procedureScheduleEntry)
+		if now.Before(entry.runAfter) {
+			break
+		}
+		heap.Pop(q.delayQueue)
+		heap.Push(q.readyQueue, entry)
 	}
 
-	entry := q.heapQueue.Peek().(*procedureScheduleEntry)
-	if time.Now().Before(entry.runAfter) {
+	if q.readyQueue.Len() == 0 {
 		return nil
 	}
 
-	heap.Pop(q.heapQueue)
+	entry := heap.Pop(q.readyQueue).(*procedureScheduleEntry)
 	delete(q.existingProcs, entry.procedure.ID())
 
 	return entry.procedure
@@ -62,6 +62,15 @@ func (t TestProcedure) State() State {
 	return StateInit
 }
 
+type TestPriorityProcedure struct {
+	TestProcedure
+	priority Priority
+}
+
+func (t TestPriorityProcedure) Priority() Priority {
+	return t.priority
+}
+
 func TestDelayQueue(t *testing.T) {
 	re := require.New(t)
 
@@ -108,3 +117,33 @@ func TestDelayQueue(t *testing.T) {
 	p0 = queue.Pop()
 	re.Equal(uint64(0), p0.ID())
 }
+
+func TestDelayQueuePriority(t *testing.T) {
+	re := require.New(t)
+
+	queue := NewProcedureDelayQueue(10)
+	lowProcedure0 := TestPriorityProcedure{TestProcedure: TestProcedure{ProcedureID: 0}, priority: PriorityLow}
+	lowProcedure1 := TestPriorityProcedure{TestProcedure: TestProcedure{ProcedureID: 1}, priority: PriorityLow}
+	medProcedure := TestPriorityProcedure{TestProcedure: TestProcedure{ProcedureID: 2}, priority: PriorityMed}
+	highProcedure := TestPriorityProcedure{TestProcedure: TestProcedure{ProcedureID: 3}, priority: PriorityHigh}
+	re.NoError(queue.Push(lowProcedure0, 0))
+	re.NoError(queue.Push(lowProcedure1, time.Millisecond*10))
+	re.NoError(queue.Push(medProcedure, time.Millisecond*10))
+	// The high priority procedure is delayed, and it isn't popped until the delay expires.
+	re.NoError(queue.Push(highProcedure, time.Millisecond*20))
+	re.Equal(4, queue.Len())
+
+	p := queue.Pop()
+	re.Equal(uint64(0), p.ID())
+
+	// The high priority procedure preempts the queued lower ones once it is ready.
+	time.Sleep(time.Millisecond * 50)
+	p = queue.Pop()
+	re.Equal(uint64(3), p.ID())
+	p = queue.Pop()
+	re.Equal(uint64(2), p.ID())
+	p = queue.Pop()
+	re.Equal(uint64(1), p.ID())
+	re.Nil(queue.Pop())
+	re.Equal(0, queue.Len())
+}
@@ -22,25 +22,35 @@ package procedure
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
 var (
-	ErrShardLeaderNotFound     = coderr.NewCodeError(coderr.Internal, "shard leader not found")
-	ErrShardNotMatch           = coderr.NewCodeError(coderr.Internal, "target shard not match to persis data")
-	ErrProcedureNotFound       = coderr.NewCodeError(coderr.Internal, "procedure not found")
-	ErrClusterConfigChanged    = coderr.NewCodeError(coderr.Internal, "cluster config changed")
-	ErrTableNotExists          = coderr.NewCodeError(coderr.Internal, "table not exists")
-	ErrTableAlreadyExists      = coderr.NewCodeError(coderr.Internal, "table already exists")
-	ErrListRunningProcedure    = coderr.NewCodeError(coderr.Internal, "procedure type not match")
-	ErrListProcedure           = coderr.NewCodeError(coderr.Internal, "list running procedure")
-	ErrDecodeRawData           = coderr.NewCodeError(coderr.Internal, "decode raw data")
-	ErrEncodeRawData           = coderr.NewCodeError(coderr.Internal, "encode raw data")
-	ErrGetRequest              = coderr.NewCodeError(coderr.Internal, "get request from event")
-	ErrNodeNumberNotEnough     = coderr.NewCodeError(coderr.Internal, "node number not enough")
-	ErrEmptyPartitionNames     = coderr.NewCodeError(coderr.Internal, "partition names is empty")
-	ErrDropTableResult         = coderr.NewCodeError(coderr.Internal, "length of shard not correct")
-	ErrPickShard               = coderr.NewCodeError(coderr.Internal, "pick shard failed")
-	ErrSubmitProcedure         = coderr.NewCodeError(coderr.Internal, "submit new procedure")
-	ErrQueueFull               = coderr.NewCodeError(coderr.Internal, "queue is full, unable to offer more data")
-	ErrPushDuplicatedProcedure = coderr.NewCodeError(coderr.Internal, "try to push duplicated procedure")
-	ErrShardNumberNotEnough    = coderr.NewCodeError(coderr.Internal, "shard number not enough")
-	ErrEmptyBatchProcedure     = coderr.NewCodeError(coderr.Internal, "procedure batch is empty")
-	ErrMergeBatchProcedure     = coderr.NewCodeError(coderr.Internal, "failed to merge procedures batch")
+	ErrShardLeaderNotFound         = coderr.NewCodeError(coderr.Internal, "shard leader not found")
+	ErrShardNotMatch               = coderr.NewCodeError(coderr.Internal, "target shard not match to persis data")
+	ErrProcedureNotFound           = coderr.NewCodeError(coderr.Internal, "procedure not found")
+	ErrClusterConfigChanged        = coderr.NewCodeError(coderr.Internal, "cluster config changed")
+	ErrTableNotExists              = coderr.NewCodeError(coderr.Internal, "table not exists")
+	ErrTableAlreadyExists          = coderr.NewCodeError(coderr.Internal, "table already exists")
+	ErrListRunningProcedure        = coderr.NewCodeError(coderr.Internal, "procedure type not match")
+	ErrListProcedure               = coderr.NewCodeError(coderr.Internal, "list running procedure")
+	ErrDecodeRawData               = coderr.NewCodeError(coderr.Internal, "decode raw data")
+	ErrEncodeRawData               = coderr.NewCodeError(coderr.Internal, "encode raw data")
+	ErrGetRequest                  = coderr.NewCodeError(coderr.Internal, "get request from event")
+	ErrNodeNumberNotEnough         = coderr.NewCodeError(coderr.Internal, "node number not enough")
+	ErrEmptyPartitionNames         = coderr.NewCodeError(coderr.Internal, "partition names is empty")
+	ErrDropTableResult             = coderr.NewCodeError(coderr.Internal, "length of shard not correct")
+	ErrPickShard                   = coderr.NewCodeError(coderr.Internal, "pick shard failed")
+	ErrSubmitProcedure             = coderr.NewCodeError(coderr.Internal, "submit new procedure")
+	ErrQueueFull                   = coderr.NewCodeError(coderr.Internal, "queue is full, unable to offer more data")
+	ErrPushDuplicatedProcedure     = coderr.NewCodeError(coderr.Internal, "try to push duplicated procedure")
+	ErrShardNumberNotEnough        = coderr.NewCodeError(coderr.Internal, "shard number not enough")
+	ErrEmptyBatchProcedure         = coderr.NewCodeError(coderr.Internal, "procedure batch is empty")
+	ErrMergeBatchProcedure         = coderr.NewCodeError(coderr.Internal, "failed to merge procedures batch")
+	ErrBatchProcedurePartialFailed = coderr.NewCodeError(coderr.Internal, "some procedures in the batch failed")
+	ErrFaultInjectionDisabled      = coderr.NewCodeError(coderr.BadRequest, "fault injection is disabled")
+	ErrInjectedFault               = coderr.NewCodeError(coderr.Internal, "injected fault")
+	ErrInvalidResumeState          = coderr.NewCodeError(coderr.Internal, "invalid fsm state to resume procedure")
+	ErrParseProcedureTimeout       = coderr.NewCodeError(coderr.Internal, "parse procedure timeout")
+	ErrProcedureTimeout            = coderr.NewCodeError(coderr.Internal, "procedure timeout")
+	ErrCancelFinishedProcedure     = coderr.NewCodeError(coderr.BadRequest, "procedure is already finished")
+	ErrProcedureNotStopped         = coderr.NewCodeError(coderr.Internal, "procedure is not stopped after being cancelled")
+	ErrStoragePrefixChanged        = coderr.NewCodeError(coderr.BadRequest, "procedure storage prefix is changed with procedures left")
+	ErrShardLocked                 = coderr.NewCodeError(coderr.BadRequest, "shard is locked by running procedure")
 )
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"sync"
+
+	"github.com/looplab/fsm"
+	"go.uber.org/zap"
+)
+
+// Fault describes the procedure step to fail, the step is identified by the kind of the procedure and the fsm state to enter.
+type Fault struct {
+	Kind  Kind   `json:"kind"`
+	State string `json:"state"`
+}
+
+// faultInjector is used to fail the procedure steps deterministically for resilience testing.
+// It is completely inert unless EnableFaultInjection is called.
+type faultInjector struct {
+	lock    sync.RWMutex
+	enabled bool
+	faults  map[Fault]struct{}
+}
+
+var defaultFaultInjector = &faultInjector{
+	lock:    sync.RWMutex{},
+	enabled: false,
+	faults:  map[Fault]struct{}{},
+}
+
+// EnableFaultInjection enables the fault injection, it should only be called at startup, and only the procedures created afterward are affected.
+func EnableFaultInjection() {
+	defaultFaultInjector.lock.Lock()
+	defer defaultFaultInjector.lock.Unlock()
+
+	defaultFaultInjector.enabled = true
+}
+
+func IsFaultInjectionEnabled() bool {
+	defaultFaultInjector.lock.RLock()
+	defer defaultFaultInjector.lock.RUnlock()
+
+	return defaultFaultInjector.enabled
+}
+
+// SetFaults replaces all the faults to inject, and empty faults means no fault will be injected.
+func SetFaults(faults []Fault) error {
+	defaultFaultInjector.lock.Lock()
+	defer defaultFaultInjector.lock.Unlock()
+
+	if !defaultFaultInjector.enabled {
+		return ErrFaultInjectionDisabled
+	}
+
+	newFaults := make(map[Fault]struct{}, len(faults))
+	for _, fault := range faults {
+		newFaults[fault] = struct{}{}
+	}
+	defaultFaultInjector.faults = newFaults
+
+	return nil
+}
+
+func ListFaults() []Fault {
+	defaultFaultInjector.lock.RLock()
+	defer defaultFaultInjector.lock.RUnlock()
+
+	faults := make([]Fault, 0, len(defaultFaultInjector.faults))
+	for fault := range defaultFaultInjector.faults {
+		faults = append(faults, fault)
+	}
+	return faults
+}
+
+func shouldInjectFault(kind Kind, state string) bool {
+	defaultFaultInjector.lock.RLock()
+	defer defaultFaultInjector.lock.RUnlock()
+
+	_, ok := defaultFaultInjector.faults[Fault{Kind: kind, State: state}]
+	return ok
+}
+
+// WithFaultInjection wraps the fsm callbacks of the procedure to fail the event when its destination state is configured to fail.
+// The callbacks are returned as is if the fault injection is disabled.
+func WithFaultInjection(kind Kind, callbacks fsm.Callbacks) fsm.Callbacks {
+	if !IsFaultInjectionEnabled() {
+		return callbacks
+	}
+
+	wrappedCallbacks := make(fsm.Callbacks, len(callbacks))
+	for name, callback := range callbacks {
+		callback := callback
+		wrappedCallbacks[name] = func(event *fsm.Event) {
+			if shouldInjectFault(kind, event.Dst) {
+				CancelEventWithLog(event, ErrInjectedFault.WithCausef("kind:%d, state:%s", kind, event.Dst), "inject fault", zap.String("event", event.Event))
+				return
+			}
+			callback(event)
+		}
+	}
+	return wrappedCallbacks
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/looplab/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjection(t *testing.T) {
+	re := require.New(t)
+
+	events := fsm.Events{
+		{Name: "EventPrepare", Src: []string{"StateBegin"}, Dst: "StatePrepare"},
+		{Name: "EventFinish", Src: []string{"StatePrepare"}, Dst: "StateFinish"},
+	}
+	executed := 0
+	callbacks := fsm.Callbacks{
+		"EventPrepare": func(_ *fsm.Event) { executed++ },
+		"EventFinish":  func(_ *fsm.Event) { executed++ },
+	}
+	fault := Fault{Kind: TransferLeader, State: "StateFinish"}
+
+	// Fault injection is inert when it is disabled.
+	re.False(IsFaultInjectionEnabled())
+	re.Error(SetFaults([]Fault{fault}))
+	f := fsm.NewFSM("StateBegin", events, WithFaultInjection(TransferLeader, callbacks))
+	re.NoError(f.Event("EventPrepare"))
+	re.NoError(f.Event("EventFinish"))
+	re.Equal(2, executed)
+
+	EnableFaultInjection()
+	t.Cleanup(resetFaultInjector)
+	re.NoError(SetFaults([]Fault{fault}))
+	re.Equal([]Fault{fault}, ListFaults())
+
+	// The fault is only injected into the procedure with the same kind.
+	executed = 0
+	f = fsm.NewFSM("StateBegin", events, WithFaultInjection(Split, callbacks))
+	re.NoError(f.Event("EventPrepare"))
+	re.NoError(f.Event("EventFinish"))
+	re.Equal(2, executed)
+
+	executed = 0
+	f = fsm.NewFSM("StateBegin", events, WithFaultInjection(TransferLeader, callbacks))
+	re.NoError(f.Event("EventPrepare"))
+	err := f.Event("EventFinish")
+	re.Error(err)
+	re.True(coderr.Is(err, ErrInjectedFault.Code()))
+	re.Equal(1, executed)
+}
+
+// resetFaultInjector restores the global fault injector to be disabled, so that the other tests are not affected.
+func resetFaultInjector() {
+	defaultFaultInjector.lock.Lock()
+	defer defaultFaultInjector.lock.Unlock()
+
+	defaultFaultInjector.enabled = false
+	defaultFaultInjector.faults = map[Fault]struct{}{}
+}
@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import "context"
+
+// InitiatorSystem is the initiator of the procedures generated by the meta server itself, e.g. the procedures generated by the schedulers.
+const InitiatorSystem = "system"
+
+type initiatorKey struct{}
+
+// WithInitiator returns a context carrying the initiator, and the procedures submitted with the context are tagged with it.
+func WithInitiator(ctx context.Context, initiator string) context.Context {
+	return context.WithValue(ctx, initiatorKey{}, initiator)
+}
+
+// InitiatorFromContext returns the initiator carried by the context, and InitiatorSystem is returned if it is not set.
+func InitiatorFromContext(ctx context.Context) string {
+	initiator, ok := ctx.Value(initiatorKey{}).(string)
+	if !ok || len(initiator) == 0 {
+		return InitiatorSystem
+	}
+	return initiator
+}
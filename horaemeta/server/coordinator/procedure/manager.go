@@ -21,6 +21,8 @@ package procedure
 
 import (
 	"context"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 )
 
 type Manager interface {
@@ -34,4 +36,23 @@ type Manager interface {
 	Submit(ctx context.Context, procedure Procedure) error
 	// ListRunningProcedure return immutable procedures info.
 	ListRunningProcedure(ctx context.Context) ([]*Info, error)
+	// CancelProceduresOfKind cancels all the running procedures of the kind and waits for them to stop, and the procedures not stopped by the
+	// cancellation are reported as failed in the result.
+	CancelProceduresOfKind(ctx context.Context, kind Kind) (CancelResult, error)
+	// TryLockShards acquires the locks of the shards shared with the running procedures, so that no procedure runs on
+	// the shards until the returned unlock is called. ErrShardLocked is returned if any shard is locked.
+	TryLockShards(shardIDs []storage.ShardID) (func(), error)
+}
+
+// CancelResult describes the procedures cancelled in bulk.
+type CancelResult struct {
+	// Cancelled is the ids of the cancelled procedures in ascending order.
+	Cancelled []uint64 `json:"cancelled"`
+	// Failed is the procedures which couldn't be cancelled in ascending order of the ids.
+	Failed []CancelFailure `json:"failed"`
+}
+
+type CancelFailure struct {
+	ProcedureID uint64 `json:"procedureID"`
+	Reason      string `json:"reason"`
 }
@@ -21,12 +21,17 @@ package procedure
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/lock"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -36,11 +41,22 @@ const (
 	defaultWaitingQueueDelay         = time.Millisecond * 500
 	defaultPromoteDelay              = time.Millisecond * 100
 	defaultProcedureWorkerChanBufSiz = 10
+	defaultCancelWaitTimeout         = time.Second * 5
 )
 
+// procedureCancellation is used to cancel the running procedure and wait for it to stop.
+type procedureCancellation struct {
+	cancel context.CancelFunc
+	// done is closed once the procedure stops, and err is the error returned by the procedure.
+	done chan struct{}
+	err  error
+}
+
 type ManagerImpl struct {
 	logger   *zap.Logger
 	metadata *metadata.ClusterMetadata
+	// The running procedures are cancelled if they are not finished within the timeouts of their kinds.
+	timeouts Timeouts
 
 	// ProcedureShardLock is used to ensure the consistency of procedures' concurrent running on shard, that is to say, only one procedure is allowed to run on a specific shard.
 	procedureShardLock *lock.EntryLock
@@ -55,6 +71,15 @@ type ManagerImpl struct {
 	// There is only one procedure running for every shard.
 	// It will be removed when the procedure is finished or failed.
 	runningProcedures map[storage.ShardID]Procedure
+	// The initiators of the submitted procedures, and it will be removed when the procedure is finished or dropped.
+	initiators map[uint64]string
+	// The deadlines of the running procedures bounded by the timeouts, and it will be removed when the procedure is finished.
+	deadlines map[uint64]time.Time
+	// The cancellations of the running procedures, and it will be removed when the procedure is finished.
+	cancellations map[uint64]*procedureCancellation
+
+	// recentErrors records the failures of the procedures for triage.
+	recentErrors *coderr.RecentErrors
 }
 
 func (m *ManagerImpl) Start(ctx context.Context) error {
@@ -93,8 +118,13 @@ func (m *ManagerImpl) Stop(ctx context.Context) error {
 }
 
 // TODO: Filter duplicate submitted Procedure.
-func (m *ManagerImpl) Submit(_ context.Context, procedure Procedure) error {
+func (m *ManagerImpl) Submit(ctx context.Context, procedure Procedure) error {
+	m.lock.Lock()
+	m.initiators[procedure.ID()] = InitiatorFromContext(ctx)
+	m.lock.Unlock()
+
 	if err := m.waitingProcedures.Push(procedure, 0); err != nil {
+		m.removeInitiator(procedure.ID())
 		return err
 	}
 
@@ -110,34 +140,127 @@ func (m *ManagerImpl) ListRunningProcedure(_ context.Context) ([]*Info, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
+	now := time.Now()
 	procedureInfos := make([]*Info, 0, len(m.runningProcedures))
 	for _, procedure := range m.runningProcedures {
 		if procedure.State() == StateRunning {
 			procedureInfos = append(procedureInfos, &Info{
-				ID:    procedure.ID(),
-				Kind:  procedure.Kind(),
-				State: procedure.State(),
+				ID:          procedure.ID(),
+				Kind:        procedure.Kind(),
+				State:       procedure.State(),
+				Priority:    procedure.Priority(),
+				Initiator:   m.initiatorLocked(procedure.ID()),
+				RemainingMs: m.remainingMsLocked(procedure.ID(), now),
+				Progress:    progressOf(procedure),
 			})
 		}
 	}
 	return procedureInfos, nil
 }
 
-func NewManagerImpl(logger *zap.Logger, metadata *metadata.ClusterMetadata) (Manager, error) {
+// CancelProceduresOfKind cancels the contexts of the running procedures of the kind and waits for them to stop, and only the procedures
+// stopped with errors are reported as cancelled.
+func (m *ManagerImpl) CancelProceduresOfKind(ctx context.Context, kind Kind) (CancelResult, error) {
+	// A procedure running on multiple shards is cancelled once.
+	m.lock.RLock()
+	procedures := make(map[uint64]Procedure)
+	cancellations := make(map[uint64]*procedureCancellation)
+	for _, procedure := range m.runningProcedures {
+		if procedure.Kind() == kind {
+			procedures[procedure.ID()] = procedure
+			cancellations[procedure.ID()] = m.cancellations[procedure.ID()]
+		}
+	}
+	m.lock.RUnlock()
+
+	result := CancelResult{Cancelled: []uint64{}, Failed: []CancelFailure{}}
+	cancelling := make(map[uint64]*procedureCancellation, len(procedures))
+	for id, procedure := range procedures {
+		if err := m.cancelProcedure(ctx, procedure, cancellations[id]); err != nil {
+			result.Failed = append(result.Failed, CancelFailure{ProcedureID: id, Reason: err.Error()})
+			continue
+		}
+		cancelling[id] = cancellations[id]
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, defaultCancelWaitTimeout)
+	defer cancel()
+	for id, cancellation := range cancelling {
+		select {
+		case <-cancellation.done:
+			if cancellation.err == nil {
+				result.Failed = append(result.Failed, CancelFailure{ProcedureID: id, Reason: ErrCancelFinishedProcedure.WithCausef("procedure finished before being cancelled, procedureID:%d", id).Error()})
+				continue
+			}
+			result.Cancelled = append(result.Cancelled, id)
+		case <-waitCtx.Done():
+			result.Failed = append(result.Failed, CancelFailure{ProcedureID: id, Reason: ErrProcedureNotStopped.WithCausef("procedureID:%d, err:%v", id, waitCtx.Err()).Error()})
+		}
+	}
+	sort.Slice(result.Cancelled, func(i, j int) bool { return result.Cancelled[i] < result.Cancelled[j] })
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].ProcedureID < result.Failed[j].ProcedureID })
+
+	m.logger.Warn("cancel procedures of kind", zap.String("kind", kindName(kind)), zap.Uint64s("cancelled", result.Cancelled), zap.Int("failed", len(result.Failed)))
+	return result, nil
+}
+
+// cancelProcedure cancels the procedure and its context unless it is already finished.
+func (m *ManagerImpl) cancelProcedure(ctx context.Context, procedure Procedure, cancellation *procedureCancellation) error {
+	if state := procedure.State(); isFinishedState(state) || cancellation == nil {
+		return ErrCancelFinishedProcedure.WithCausef("procedureID:%d, state:%s", procedure.ID(), state)
+	}
+	if err := procedure.Cancel(ctx); err != nil {
+		m.logger.Error("cancel procedure failed", zap.Uint64("procedureID", procedure.ID()), zap.Error(err))
+		return errors.WithMessagef(err, "cancel procedure, procedureID:%d", procedure.ID())
+	}
+	// The state set by Cancel may be overwritten by the running procedure, which is only stopped by its context.
+	cancellation.cancel()
+	return nil
+}
+
+func progressOf(procedure Procedure) *Progress {
+	reporter, ok := procedure.(ProgressReporter)
+	if !ok {
+		return nil
+	}
+	progress := reporter.Progress()
+	return &progress
+}
+
+func NewManagerImpl(logger *zap.Logger, metadata *metadata.ClusterMetadata, timeouts Timeouts, recentErrors *coderr.RecentErrors) (Manager, error) {
 	entryLock := lock.NewEntryLock(10)
 	manager := &ManagerImpl{
 		logger:              logger,
 		metadata:            metadata,
+		timeouts:            timeouts,
 		procedureShardLock:  &entryLock,
 		waitingProcedures:   NewProcedureDelayQueue(defaultWaitingQueueLen),
 		procedureWorkerChan: make(chan struct{}),
 		lock:                sync.RWMutex{},
 		running:             false,
 		runningProcedures:   map[storage.ShardID]Procedure{},
+		initiators:          map[uint64]string{},
+		deadlines:           map[uint64]time.Time{},
+		cancellations:       map[uint64]*procedureCancellation{},
+		recentErrors:        recentErrors,
 	}
 	return manager, nil
 }
 
+func (m *ManagerImpl) TryLockShards(shardIDs []storage.ShardID) (func(), error) {
+	locks := make([]uint64, 0, len(shardIDs))
+	for _, shardID := range shardIDs {
+		locks = append(locks, uint64(shardID))
+	}
+	if !m.procedureShardLock.TryLock(locks) {
+		return nil, ErrShardLocked.WithCausef("shardIDs:%v", shardIDs)
+	}
+
+	return func() {
+		m.procedureShardLock.UnLock(locks)
+	}, nil
+}
+
 func (m *ManagerImpl) startProcedurePromote(ctx context.Context, procedureWorkerChan chan struct{}) {
 	ticker := time.NewTicker(defaultPromoteDelay)
 	defer ticker.Stop()
@@ -160,27 +283,43 @@ func (m *ManagerImpl) startProcedurePromoteInternal(ctx context.Context, procedu
 		return
 	}
 
+	// The procedures are cancellable as soon as they are running.
+	procedureCtxs := make([]context.Context, 0, len(newProcedures))
 	m.lock.Lock()
 	for _, newProcedure := range newProcedures {
 		for shardID := range newProcedure.RelatedVersionInfo().ShardWithVersion {
 			m.runningProcedures[shardID] = newProcedure
 		}
+		procedureCtx, cancel := context.WithCancel(ctx)
+		m.cancellations[newProcedure.ID()] = &procedureCancellation{cancel: cancel, done: make(chan struct{}), err: nil}
+		procedureCtxs = append(procedureCtxs, procedureCtx)
 	}
 	m.lock.Unlock()
 
-	for _, newProcedure := range newProcedures {
+	for i, newProcedure := range newProcedures {
 		m.logger.Info("promote procedure", zap.Uint64("procedureID", newProcedure.ID()))
-		m.startProcedureWorker(ctx, newProcedure, procedureWorkerChan)
+		m.startProcedureWorker(procedureCtxs[i], newProcedure, procedureWorkerChan)
 	}
 }
 
 func (m *ManagerImpl) startProcedureWorker(ctx context.Context, newProcedure Procedure, procedureWorkerChan chan struct{}) {
+	m.lock.RLock()
+	initiator := m.initiatorLocked(newProcedure.ID())
+	m.lock.RUnlock()
+
 	go func() {
 		start := time.Now()
-		m.logger.Info("procedure start", zap.Uint64("procedureID", newProcedure.ID()))
-		err := newProcedure.Start(ctx)
+		m.logger.Info("procedure start", zap.Uint64("procedureID", newProcedure.ID()), zap.String("initiator", initiator))
+		// The events dispatched by the procedure are audited with its id.
+		procedureCtx, cancel := m.withTimeout(eventdispatch.WithProcedureID(WithInitiator(ctx, initiator), newProcedure.ID()), newProcedure, start)
+		err := newProcedure.Start(procedureCtx)
+		if err != nil && errors.Is(procedureCtx.Err(), context.DeadlineExceeded) {
+			err = ErrProcedureTimeout.WithCausef("timeout:%s, err:%v", m.timeouts.Of(newProcedure.Kind()), err)
+		}
+		cancel()
 		if err != nil {
 			m.logger.Error("procedure start failed", zap.Error(err), zap.Int64("costTime", time.Since(start).Milliseconds()))
+			m.recentErrors.Record(fmt.Sprintf("procedure, id:%d, kind:%d", newProcedure.ID(), newProcedure.Kind()), err)
 		} else {
 			m.logger.Info("procedure start finish", zap.Uint64("procedureID", newProcedure.ID()), zap.Int64("costTime", time.Since(start).Milliseconds()))
 		}
@@ -191,6 +330,9 @@ func (m *ManagerImpl) startProcedureWorker(ctx context.Context, newProcedure Pro
 
 			m.procedureShardLock.UnLock([]uint64{uint64(shardID)})
 		}
+		m.removeInitiator(newProcedure.ID())
+		m.removeDeadline(newProcedure.ID())
+		m.finishCancellation(newProcedure.ID(), err)
 		select {
 		case procedureWorkerChan <- struct{}{}:
 		default:
@@ -198,6 +340,83 @@ func (m *ManagerImpl) startProcedureWorker(ctx context.Context, newProcedure Pro
 	}()
 }
 
+// withTimeout bounds the execution of the procedure by the timeout of its kind, and the procedure is cancelled on expiry.
+func (m *ManagerImpl) withTimeout(ctx context.Context, p Procedure, start time.Time) (context.Context, context.CancelFunc) {
+	timeout := m.timeouts.Of(p.Kind())
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	m.lock.Lock()
+	m.deadlines[p.ID()] = start.Add(timeout)
+	m.lock.Unlock()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	stop := context.AfterFunc(timeoutCtx, func() {
+		// The context is also done when the manager is stopped, and the procedure is cancelled by the manager then.
+		if !errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		m.logger.Warn("procedure timeout, cancel it", zap.Uint64("procedureID", p.ID()), zap.Uint("kind", uint(p.Kind())), zap.Duration("timeout", timeout))
+		if err := p.Cancel(context.Background()); err != nil {
+			m.logger.Error("cancel timeout procedure failed", zap.Uint64("procedureID", p.ID()), zap.Error(err))
+		}
+	})
+	return timeoutCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// remainingMsLocked returns the time left before the procedure times out, -1 if it is unbounded, and the caller should hold the lock.
+func (m *ManagerImpl) remainingMsLocked(procedureID uint64, now time.Time) int64 {
+	deadline, ok := m.deadlines[procedureID]
+	if !ok {
+		return -1
+	}
+	if now.After(deadline) {
+		return 0
+	}
+	return deadline.Sub(now).Milliseconds()
+}
+
+func (m *ManagerImpl) removeDeadline(procedureID uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.deadlines, procedureID)
+}
+
+// finishCancellation records the error of the stopped procedure and notifies the waiters of its cancellation.
+func (m *ManagerImpl) finishCancellation(procedureID uint64, err error) {
+	m.lock.Lock()
+	cancellation, ok := m.cancellations[procedureID]
+	delete(m.cancellations, procedureID)
+	m.lock.Unlock()
+
+	if ok {
+		cancellation.err = err
+		cancellation.cancel()
+		close(cancellation.done)
+	}
+}
+
+// initiatorLocked returns the initiator of the submitted procedure, and the caller should hold the lock.
+func (m *ManagerImpl) initiatorLocked(procedureID uint64) string {
+	initiator, ok := m.initiators[procedureID]
+	if !ok {
+		return InitiatorSystem
+	}
+	return initiator
+}
+
+func (m *ManagerImpl) removeInitiator(procedureID uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.initiators, procedureID)
+}
+
 // Whether a waiting procedure could be running procedure.
 func checkValid(p Procedure, clusterMetadata *metadata.ClusterMetadata) bool {
 	// ClusterVersion and ShardVersion in this procedure must be same with current cluster topology.
@@ -223,6 +442,8 @@ func checkValid(p Procedure, clusterMetadata *metadata.ClusterMetadata) bool {
 
 // Promote a waiting procedure to be a running procedure.
 // One procedure may be related with multiple shards.
+// The waiting procedures are popped in the order of priority, so the procedures with higher priority take the shard locks
+// ahead of the lower ones.
 func (m *ManagerImpl) promoteProcedure(_ context.Context) ([]Procedure, error) {
 	// Get waiting procedures, it has been sorted in queue.
 	queue := m.waitingProcedures
@@ -235,25 +456,30 @@ func (m *ManagerImpl) promoteProcedure(_ context.Context) ([]Procedure, error) {
 			return readyProcs, nil
 		}
 
-		if !checkValid(p, m.metadata) {
-			// This procedure is invalid, just remove it.
-			continue
-		}
-
-		// Try to get shard locks.
+		// Try to get shard locks, so only one procedure is running on a shard at any time.
 		shardIDs := make([]uint64, 0, len(p.RelatedVersionInfo().ShardWithVersion))
 		for shardID := range p.RelatedVersionInfo().ShardWithVersion {
 			shardIDs = append(shardIDs, uint64(shardID))
 		}
 		lockResult := m.procedureShardLock.TryLock(shardIDs)
-		if lockResult {
-			// Get lock success, procedure will be executed.
-			readyProcs = append(readyProcs, p)
-		} else {
+		if !lockResult {
 			// Get lock failed, procedure will be put back into the queue.
 			if err := queue.Push(p, defaultWaitingQueueDelay); err != nil {
 				return nil, err
 			}
+			continue
+		}
+
+		// The versions must be checked with the shard locks held, otherwise the procedure running on the same shard may
+		// update the versions after the check.
+		if !checkValid(p, m.metadata) {
+			// This procedure is invalid, just remove it.
+			m.procedureShardLock.UnLock(shardIDs)
+			m.removeInitiator(p.ID())
+			continue
 		}
+
+		// Get lock success, procedure will be executed.
+		readyProcs = append(readyProcs, p)
 	}
 }
@@ -21,9 +21,11 @@ package procedure_test
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -70,12 +72,84 @@ func (m *MockProcedure) Priority() procedure.Priority {
 	return procedure.PriorityMed
 }
 
+// shardRecordingProcedure records the number of the procedures running on the same shard concurrently.
+type shardRecordingProcedure struct {
+	*MockProcedure
+	running    *atomic.Int32
+	maxRunning *atomic.Int32
+	finished   *atomic.Int32
+}
+
+func (p *shardRecordingProcedure) Start(ctx context.Context) error {
+	running := p.running.Add(1)
+	for {
+		maxRunning := p.maxRunning.Load()
+		if running <= maxRunning || p.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	err := p.MockProcedure.Start(ctx)
+	p.running.Add(-1)
+	p.finished.Add(1)
+	return err
+}
+
+// initiatorRecordingProcedure records the initiator carried by the context passed to Start.
+type initiatorRecordingProcedure struct {
+	*MockProcedure
+	initiator chan string
+}
+
+func (p *initiatorRecordingProcedure) Start(ctx context.Context) error {
+	p.initiator <- procedure.InitiatorFromContext(ctx)
+	return p.MockProcedure.Start(ctx)
+}
+
+// blockingProcedure runs until its context is done, and records whether it is cancelled.
+type blockingProcedure struct {
+	*MockProcedure
+	running   *atomic.Bool
+	cancelled *atomic.Bool
+}
+
+func (p *blockingProcedure) Start(ctx context.Context) error {
+	p.running.Store(true)
+	<-ctx.Done()
+	p.running.Store(false)
+	return ctx.Err()
+}
+
+func (p *blockingProcedure) State() procedure.State {
+	if p.running.Load() {
+		return procedure.StateRunning
+	}
+	return procedure.StateInit
+}
+
+func (p *blockingProcedure) Cancel(_ context.Context) error {
+	p.cancelled.Store(true)
+	return nil
+}
+
+// stubbornProcedure ignores the cancellation of its context until it is released.
+type stubbornProcedure struct {
+	*blockingProcedure
+	release chan struct{}
+}
+
+func (p *stubbornProcedure) Start(_ context.Context) error {
+	p.running.Store(true)
+	<-p.release
+	p.running.Store(false)
+	return nil
+}
+
 func TestManager(t *testing.T) {
 	ctx := context.Background()
 	re := require.New(t)
 
 	c := test.InitStableCluster(ctx, t)
-	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata())
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
 	re.NoError(err)
 
 	err = manager.Start(ctx)
@@ -175,3 +249,319 @@ func TestManager(t *testing.T) {
 		re.NoError(err)
 	}
 }
+
+func TestManagerSerializeShardProcedures(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	// Submit two procedures mutating the same shard, e.g. a split and a transferLeader.
+	var shardID storage.ShardID
+	var shardVersion uint64
+	for id, shardView := range c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping {
+		shardID, shardVersion = id, shardView.Version
+		break
+	}
+	running, maxRunning, finished := &atomic.Int32{}, &atomic.Int32{}, &atomic.Int32{}
+	for procedureID := uint64(0); procedureID < 2; procedureID++ {
+		err = manager.Submit(ctx, &shardRecordingProcedure{
+			MockProcedure: &MockProcedure{
+				id:                 procedureID,
+				state:              procedure.StateInit,
+				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: shardVersion}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+				execTime:           time.Millisecond * 50,
+			},
+			running:    running,
+			maxRunning: maxRunning,
+			finished:   finished,
+		})
+		re.NoError(err)
+	}
+
+	// The procedures are executed one by one.
+	re.Eventually(func() bool {
+		return finished.Load() == 2
+	}, time.Second*5, time.Millisecond*10)
+	re.Equal(int32(1), maxRunning.Load())
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestManagerInitiator(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	shardIDs := make([]storage.ShardID, 0, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardIDs = append(shardIDs, shardID)
+	}
+	re.GreaterOrEqual(len(shardIDs), 2)
+
+	newProcedure := func(id uint64, shardID storage.ShardID) *initiatorRecordingProcedure {
+		return &initiatorRecordingProcedure{
+			MockProcedure: &MockProcedure{
+				id:                 id,
+				state:              procedure.StateInit,
+				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+				execTime:           time.Millisecond * 100,
+			},
+			initiator: make(chan string, 1),
+		}
+	}
+
+	operatorProcedure := newProcedure(0, shardIDs[0])
+	re.NoError(manager.Submit(procedure.WithInitiator(ctx, "operator"), operatorProcedure))
+	systemProcedure := newProcedure(1, shardIDs[1])
+	re.NoError(manager.Submit(ctx, systemProcedure))
+
+	// The initiator is passed to the procedure when it is started.
+	re.Equal("operator", <-operatorProcedure.initiator)
+	re.Equal(procedure.InitiatorSystem, <-systemProcedure.initiator)
+
+	time.Sleep(time.Millisecond * 10)
+	infos, err := manager.ListRunningProcedure(ctx)
+	re.NoError(err)
+	re.Equal(2, len(infos))
+	for _, info := range infos {
+		if info.ID == operatorProcedure.ID() {
+			re.Equal("operator", info.Initiator)
+		} else {
+			re.Equal(procedure.InitiatorSystem, info.Initiator)
+		}
+	}
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestManagerTimeout(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	timeout := time.Millisecond * 500
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{procedure.CreateTable: timeout}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	p := &blockingProcedure{
+		MockProcedure: &MockProcedure{
+			id:                 0,
+			state:              procedure.StateInit,
+			relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+			execTime:           0,
+		},
+		running:   &atomic.Bool{},
+		cancelled: &atomic.Bool{},
+	}
+	re.NoError(manager.Submit(ctx, p))
+
+	// The remaining time is surfaced when the procedure is running.
+	var infos []*procedure.Info
+	re.Eventually(func() bool {
+		infos, err = manager.ListRunningProcedure(ctx)
+		re.NoError(err)
+		return len(infos) == 1
+	}, time.Second*5, time.Millisecond*10)
+	re.Greater(infos[0].RemainingMs, int64(0))
+	re.LessOrEqual(infos[0].RemainingMs, timeout.Milliseconds())
+
+	// The procedure is cancelled on expiry.
+	re.Eventually(func() bool {
+		return p.cancelled.Load()
+	}, time.Second*5, time.Millisecond*10)
+	re.Eventually(func() bool {
+		infos, err = manager.ListRunningProcedure(ctx)
+		re.NoError(err)
+		return len(infos) == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestManagerCancelProceduresOfKind(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	procedures := make([]*blockingProcedure, 0, 2)
+	for shardID := storage.ShardID(0); shardID < 2; shardID++ {
+		p := &blockingProcedure{
+			MockProcedure: &MockProcedure{
+				id:                 uint64(shardID),
+				state:              procedure.StateInit,
+				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+				execTime:           0,
+			},
+			running:   &atomic.Bool{},
+			cancelled: &atomic.Bool{},
+		}
+		re.NoError(manager.Submit(ctx, p))
+		procedures = append(procedures, p)
+	}
+	re.Eventually(func() bool {
+		infos, err := manager.ListRunningProcedure(ctx)
+		re.NoError(err)
+		return len(infos) == len(procedures)
+	}, time.Second*5, time.Millisecond*10)
+
+	// The procedures of the other kinds are not affected.
+	result, err := manager.CancelProceduresOfKind(ctx, procedure.Split)
+	re.NoError(err)
+	re.Empty(result.Cancelled)
+	re.Empty(result.Failed)
+	for _, p := range procedures {
+		re.False(p.cancelled.Load())
+	}
+
+	result, err = manager.CancelProceduresOfKind(ctx, procedure.CreateTable)
+	re.NoError(err)
+	re.Equal([]uint64{0, 1}, result.Cancelled)
+	re.Empty(result.Failed)
+	for _, p := range procedures {
+		re.True(p.cancelled.Load())
+		re.False(p.running.Load())
+	}
+
+	// The cancelled procedures are stopped without cancelling the context of the manager.
+	re.Eventually(func() bool {
+		infos, err := manager.ListRunningProcedure(ctx)
+		re.NoError(err)
+		return len(infos) == 0
+	}, time.Second*5, time.Millisecond*10)
+	re.NoError(manager.Stop(context.Background()))
+}
+
+func TestManagerCancelProceduresOfKindNotStopped(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	p := &stubbornProcedure{
+		blockingProcedure: &blockingProcedure{
+			MockProcedure: &MockProcedure{
+				id:                 0,
+				state:              procedure.StateInit,
+				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{0: snapshot.Topology.ShardViewsMapping[0].Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+				execTime:           0,
+			},
+			running:   &atomic.Bool{},
+			cancelled: &atomic.Bool{},
+		},
+		release: make(chan struct{}),
+	}
+	re.NoError(manager.Submit(ctx, p))
+	re.Eventually(p.running.Load, time.Second*5, time.Millisecond*10)
+
+	// The procedure ignoring the cancellation is not reported as cancelled.
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Millisecond*100)
+	defer waitCancel()
+	result, err := manager.CancelProceduresOfKind(waitCtx, procedure.CreateTable)
+	re.NoError(err)
+	re.Empty(result.Cancelled)
+	re.Len(result.Failed, 1)
+	re.Equal(uint64(0), result.Failed[0].ProcedureID)
+	re.True(p.cancelled.Load())
+
+	close(p.release)
+	re.Eventually(func() bool {
+		infos, err := manager.ListRunningProcedure(ctx)
+		re.NoError(err)
+		return len(infos) == 0
+	}, time.Second*5, time.Millisecond*10)
+	re.NoError(manager.Stop(context.Background()))
+}
+
+// orderRecordingProcedure records the order of the procedures being started.
+type orderRecordingProcedure struct {
+	*MockProcedure
+	priority procedure.Priority
+	started  chan<- uint64
+}
+
+func (p *orderRecordingProcedure) Start(ctx context.Context) error {
+	p.started <- p.ID()
+	return p.MockProcedure.Start(ctx)
+}
+
+func (p *orderRecordingProcedure) Priority() procedure.Priority {
+	return p.priority
+}
+
+func TestManagerPriority(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+
+	var shardID storage.ShardID
+	var shardVersion uint64
+	for id, shardView := range c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping {
+		shardID, shardVersion = id, shardView.Version
+		break
+	}
+	newProcedure := func(id uint64, priority procedure.Priority, started chan<- uint64) *orderRecordingProcedure {
+		return &orderRecordingProcedure{
+			MockProcedure: &MockProcedure{
+				id:                 id,
+				state:              procedure.StateInit,
+				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: shardVersion}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
+				execTime:           time.Millisecond * 10,
+			},
+			priority: priority,
+			started:  started,
+		}
+	}
+
+	// The low priority procedures are queued ahead of the high priority one on the same shard, before the manager starts.
+	numLowProcedures := 3
+	started := make(chan uint64, numLowProcedures+1)
+	for procedureID := uint64(0); procedureID < uint64(numLowProcedures); procedureID++ {
+		re.NoError(manager.Submit(ctx, newProcedure(procedureID, procedure.PriorityLow, started)))
+	}
+	highProcedureID := uint64(numLowProcedures)
+	re.NoError(manager.Submit(ctx, newProcedure(highProcedureID, procedure.PriorityHigh, started)))
+
+	// The high priority procedure takes the shard lock first, and the queued low priority ones run after it.
+	re.NoError(manager.Start(ctx))
+	re.Equal(highProcedureID, <-started)
+	for i := 0; i < numLowProcedures; i++ {
+		select {
+		case procedureID := <-started:
+			re.NotEqual(highProcedureID, procedureID)
+		case <-time.After(time.Second * 10):
+			re.FailNow("low priority procedures are not executed")
+		}
+	}
+
+	re.NoError(manager.Stop(ctx))
+}
@@ -22,7 +22,9 @@ package split
 import (
 	"context"
 	"encoding/json"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -106,7 +108,7 @@ func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
 	splitFsm := fsm.NewFSM(
 		stateBegin,
 		splitEvents,
-		splitCallbacks,
+		procedure.WithFaultInjection(procedure.Split, splitCallbacks),
 	)
 
 	return &Procedure{
@@ -238,6 +240,13 @@ func (p *Procedure) Start(ctx context.Context) error {
 	}
 }
 
+// Progress returns the progress of the split by the fsm states passed.
+func (p *Procedure) Progress() procedure.Progress {
+	steps := []string{stateBegin, stateCreateNewShardView, stateUpdateShardTables, stateOpenNewShard, stateFinish}
+	done := slices.Index(steps, p.fsm.Current())
+	return procedure.NewProgress(uint32(max(done, 0)), uint32(len(steps)-1))
+}
+
 func (p *Procedure) Cancel(_ context.Context) error {
 	p.updateStateWithLock(procedure.StateCancelled)
 	return nil
@@ -322,7 +331,7 @@ func finishCallback(event *fsm.Event) {
 }
 
 func (p *Procedure) persist(ctx context.Context) error {
-	meta, err := p.convertToMeta()
+	meta, err := p.convertToMeta(procedure.InitiatorFromContext(ctx))
 	if err != nil {
 		return errors.WithMessage(err, "convert to meta")
 	}
@@ -341,7 +350,7 @@ type rawData struct {
 	TargetNodeName string
 }
 
-func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+func (p *Procedure) convertToMeta(initiator string) (procedure.Meta, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
@@ -363,7 +372,9 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		Kind:  procedure.Split,
 		State: p.state,
 
-		RawData: rawDataBytes,
+		RawData:   rawDataBytes,
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: initiator,
 	}
 
 	return meta, nil
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/split"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -77,8 +78,10 @@ func TestSplit(t *testing.T) {
 		TargetNodeName:  createTableNodeShard.NodeName,
 	})
 	re.NoError(err)
+	re.Equal(procedure.Progress{Done: 0, Total: 4, Fraction: 0}, p.(procedure.ProgressReporter).Progress())
 	err = p.Start(ctx)
 	re.NoError(err)
+	re.Equal(procedure.Progress{Done: 4, Total: 4, Fraction: 1}, p.(procedure.ProgressReporter).Progress())
 
 	// Validate split result:
 	// 1. Shards on node, split shard and new shard must be all exists.
@@ -22,6 +22,8 @@ package transferleader
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -32,19 +34,34 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// SubProcedureResult is the result of a procedure in the batch.
+type SubProcedureResult struct {
+	ProcedureID uint64
+	ShardIDs    []storage.ShardID
+	// Err is nil if the procedure succeeds.
+	Err error
+}
+
 // BatchTransferLeaderProcedure is a proxy procedure contains a batch of TransferLeaderProcedure.
 // It is used to support concurrent execution of a batch of TransferLeaderProcedure with same version.
 type BatchTransferLeaderProcedure struct {
 	id                 uint64
 	batch              []procedure.Procedure
 	relatedVersionInfo procedure.RelatedVersionInfo
-
-	// Protect the state.
-	lock  sync.RWMutex
-	state procedure.State
+	// concurrency is the max number of procedures in the batch started concurrently.
+	concurrency int
+	// progress tracks the procedures in the batch done.
+	progress *procedure.ProgressTracker
+
+	// Protect the state and the results.
+	lock    sync.RWMutex
+	state   procedure.State
+	results []SubProcedureResult
 }
 
-func NewBatchTransferLeaderProcedure(id uint64, batch []procedure.Procedure) (procedure.Procedure, error) {
+// NewBatchTransferLeaderProcedure creates a procedure starting the procedures in the batch concurrently, at most concurrency procedures are running at the
+// same time and zero means no limit.
+func NewBatchTransferLeaderProcedure(id uint64, batch []procedure.Procedure, concurrency uint32) (procedure.Procedure, error) {
 	if len(batch) == 0 {
 		return nil, procedure.ErrEmptyBatchProcedure
 	}
@@ -54,12 +71,20 @@ func NewBatchTransferLeaderProcedure(id uint64, batch []procedure.Procedure) (pr
 		return nil, err
 	}
 
+	limit := len(batch)
+	if concurrency > 0 && uint64(concurrency) < uint64(limit) {
+		limit = int(concurrency)
+	}
+
 	return &BatchTransferLeaderProcedure{
 		id:                 id,
 		batch:              batch,
 		relatedVersionInfo: relateVersionInfo,
+		concurrency:        limit,
+		progress:           procedure.NewProgressTracker(uint32(len(batch))),
 		lock:               sync.RWMutex{},
 		state:              procedure.StateInit,
+		results:            nil,
 	}, nil
 }
 
@@ -107,28 +132,61 @@ func (p *BatchTransferLeaderProcedure) Kind() procedure.Kind {
 }
 
 func (p *BatchTransferLeaderProcedure) Start(ctx context.Context) error {
-	// Start procedures with multiple goroutine.
-	g, _ := errgroup.WithContext(ctx)
-	for _, p := range p.batch {
-		p := p
+	p.updateStateWithLock(procedure.StateRunning)
+
+	// Start procedures with multiple goroutine, and the failure of a procedure never aborts the others.
+	results := make([]SubProcedureResult, len(p.batch))
+	g := errgroup.Group{}
+	g.SetLimit(p.concurrency)
+	for i, subProcedure := range p.batch {
+		i, subProcedure := i, subProcedure
 		g.Go(func() error {
-			err := p.Start(ctx)
+			shardIDs := make([]storage.ShardID, 0, len(subProcedure.RelatedVersionInfo().ShardWithVersion))
+			for shardID := range subProcedure.RelatedVersionInfo().ShardWithVersion {
+				shardIDs = append(shardIDs, shardID)
+			}
+			sort.Slice(shardIDs, func(i, j int) bool {
+				return shardIDs[i] < shardIDs[j]
+			})
+
+			err := subProcedure.Start(ctx)
 			if err != nil {
-				log.Error("procedure start failed", zap.Error(err), zap.Uint64("procedureID", p.ID()), zap.Error(err))
+				log.Error("procedure start failed", zap.Uint64("procedureID", subProcedure.ID()), zap.Any("shardIDs", shardIDs), zap.Error(err))
 			}
-			return err
+			results[i] = SubProcedureResult{ProcedureID: subProcedure.ID(), ShardIDs: shardIDs, Err: err}
+			p.progress.Advance()
+			return nil
 		})
 	}
+	_ = g.Wait()
+
+	p.lock.Lock()
+	p.results = results
+	p.lock.Unlock()
 
-	if err := g.Wait(); err != nil {
+	var failures []string
+	for _, result := range results {
+		if result.Err != nil {
+			failures = append(failures, fmt.Sprintf("procedureID:%d, shardIDs:%v, err:%v", result.ProcedureID, result.ShardIDs, result.Err))
+		}
+	}
+	if len(failures) > 0 {
 		p.updateStateWithLock(procedure.StateFailed)
-		return err
+		return procedure.ErrBatchProcedurePartialFailed.WithCausef("failed:%d, total:%d, failures:[%s]", len(failures), len(results), strings.Join(failures, "; "))
 	}
 
 	p.updateStateWithLock(procedure.StateFinished)
 	return nil
 }
 
+// Results returns the results of the procedures in the batch in the order of the batch, and it is empty until the batch is done.
+func (p *BatchTransferLeaderProcedure) Results() []SubProcedureResult {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.results
+}
+
 func (p *BatchTransferLeaderProcedure) Cancel(_ context.Context) error {
 	p.updateStateWithLock(procedure.StateCancelled)
 	return nil
@@ -142,6 +200,11 @@ func (p *BatchTransferLeaderProcedure) RelatedVersionInfo() procedure.RelatedVer
 	return p.relatedVersionInfo
 }
 
+// Progress returns the progress of the procedures in the batch, and the failed ones are counted as done.
+func (p *BatchTransferLeaderProcedure) Progress() procedure.Progress {
+	return p.progress.Progress()
+}
+
 func (p *BatchTransferLeaderProcedure) Priority() procedure.Priority {
 	return p.batch[0].Priority()
 }
@@ -21,8 +21,11 @@ package transferleader_test
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/transferleader"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -79,7 +82,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(storage.ClusterID(0), 0, 0, shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err := transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err := transferleader.NewBatchTransferLeaderProcedure(0, procedures, 0)
 	re.NoError(err)
 
 	// Procedure with different clusterID.
@@ -89,7 +92,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(storage.ClusterID(i), 0, procedure.TransferLeader, shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures, 0)
 	re.Error(err)
 
 	// Procedures with different type.
@@ -99,7 +102,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(0, 0, procedure.Kind(i), shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures, 0)
 	re.Error(err)
 
 	// Procedures with different version.
@@ -109,10 +112,86 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(0, 0, procedure.Kind(i), shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures, 0)
 	re.Error(err)
 }
 
+// concurrentProcedure records the max number of the procedures running concurrently.
+type concurrentProcedure struct {
+	mockProcedure
+	id      uint64
+	fail    bool
+	running *atomic.Int32
+	maxSeen *atomic.Int32
+}
+
+func (p concurrentProcedure) ID() uint64 {
+	return p.id
+}
+
+func (p concurrentProcedure) Start(_ context.Context) error {
+	running := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		maxSeen := p.maxSeen.Load()
+		if running <= maxSeen || p.maxSeen.CompareAndSwap(maxSeen, running) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if p.fail {
+		return procedure.ErrInjectedFault
+	}
+	return nil
+}
+
+func TestBatchProcedureConcurrency(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	running := &atomic.Int32{}
+	maxSeen := &atomic.Int32{}
+	var procedures []procedure.Procedure
+	for i := 0; i < 10; i++ {
+		procedures = append(procedures, concurrentProcedure{
+			mockProcedure: mockProcedure{
+				ClusterID:        0,
+				clusterVersion:   0,
+				kind:             procedure.TransferLeader,
+				ShardWithVersion: map[storage.ShardID]uint64{storage.ShardID(i): 0},
+			},
+			id:      uint64(i),
+			fail:    i%4 == 0,
+			running: running,
+			maxSeen: maxSeen,
+		})
+	}
+
+	p, err := transferleader.NewBatchTransferLeaderProcedure(100, procedures, 3)
+	re.NoError(err)
+	err = p.Start(ctx)
+	re.True(coderr.Is(err, procedure.ErrBatchProcedurePartialFailed.Code()))
+	re.Equal(procedure.StateFailed, string(p.State()))
+	re.LessOrEqual(maxSeen.Load(), int32(3))
+	re.Greater(maxSeen.Load(), int32(1))
+	// The failed procedures are counted as done.
+	re.Equal(procedure.Progress{Done: 10, Total: 10, Fraction: 1}, p.(procedure.ProgressReporter).Progress())
+
+	// The failed procedures are reported individually, and the others are not aborted.
+	results := p.(*transferleader.BatchTransferLeaderProcedure).Results()
+	re.Len(results, 10)
+	for i, result := range results {
+		re.Equal(uint64(i), result.ProcedureID)
+		re.Equal([]storage.ShardID{storage.ShardID(i)}, result.ShardIDs)
+		if i%4 == 0 {
+			re.Error(result.Err)
+		} else {
+			re.NoError(result.Err)
+		}
+	}
+}
+
 func CreateMockProcedure(clusterID storage.ClusterID, clusterVersion uint64, typ procedure.Kind, shardWithVersion map[storage.ShardID]uint64) procedure.Procedure {
 	return mockProcedure{
 		ClusterID:        clusterID,
@@ -85,11 +85,14 @@ type ProcedureParams struct {
 	Dispatch eventdispatch.Dispatch
 	Storage  procedure.Storage
 
+	ClusterMetadata *metadata.ClusterMetadata
 	ClusterSnapshot metadata.Snapshot
 
 	ShardID           storage.ShardID
 	OldLeaderNodeName string
 	NewLeaderNodeName string
+	// Reason is why the leader is transferred, and it is recorded in the leader history of the shard.
+	Reason string
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
@@ -105,7 +108,7 @@ func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
 	transferLeaderOperationFsm := fsm.NewFSM(
 		stateBegin,
 		transferLeaderEvents,
-		transferLeaderCallbacks,
+		procedure.WithFaultInjection(procedure.TransferLeader, transferLeaderCallbacks),
 	)
 
 	return &Procedure{
@@ -148,7 +151,8 @@ func validateClusterTopology(topology metadata.Topology, shardID storage.ShardID
 		return metadata.ErrShardNotFound
 	}
 	for _, shardNode := range shardNodes {
-		if shardNode.ID == shardID {
+		// The followers of the shard are kept, and only the leader is transferred.
+		if shardNode.ID == shardID && shardNode.ShardRole != storage.ShardRoleFollower {
 			leaderNodeName := shardNode.NodeName
 			if leaderNodeName != oldLeaderNodeName {
 				log.Error("shard leader node not match", zap.String("requestOldLeaderNodeName", oldLeaderNodeName), zap.String("actualOldLeaderNodeName", leaderNodeName))
@@ -284,7 +288,13 @@ func finishCallback(event *fsm.Event) {
 		return
 	}
 
-	log.Info("transfer leader finish", zap.Uint64("procedureID", req.p.ID()), zap.Uint32("shardID", uint32(req.p.params.ShardID)), zap.String("oldLeaderNode", req.p.params.OldLeaderNodeName), zap.String("newLeaderNode", req.p.params.NewLeaderNodeName))
+	params := req.p.params
+	// The leader history is only used for diagnosis, so the failure of recording it doesn't fail the procedure.
+	if err := params.ClusterMetadata.RecordShardLeaderChange(req.ctx, params.ShardID, params.OldLeaderNodeName, params.NewLeaderNodeName, params.Reason); err != nil {
+		log.Warn("record shard leader change failed", zap.Uint64("procedureID", req.p.ID()), zap.Uint32("shardID", uint32(params.ShardID)), zap.Error(err))
+	}
+
+	log.Info("transfer leader finish", zap.Uint64("procedureID", req.p.ID()), zap.Uint32("shardID", uint32(params.ShardID)), zap.String("oldLeaderNode", params.OldLeaderNodeName), zap.String("newLeaderNode", params.NewLeaderNodeName), zap.String("reason", params.Reason))
 }
 
 func (p *Procedure) updateStateWithLock(state procedure.State) {
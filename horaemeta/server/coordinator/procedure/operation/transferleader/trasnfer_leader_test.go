@@ -49,13 +49,24 @@ func TestTransferLeader(t *testing.T) {
 		ID:                0,
 		Dispatch:          dispatch,
 		Storage:           s,
+		ClusterMetadata:   c.GetMetadata(),
 		ClusterSnapshot:   snapshot,
 		ShardID:           targetShardID,
 		OldLeaderNodeName: "",
 		NewLeaderNodeName: newLeaderNodeName,
+		Reason:            "test",
 	})
 	re.NoError(err)
 
 	err = p.Start(ctx)
 	re.NoError(err)
+
+	// The leader change is recorded in the leader history of the shard.
+	history, err := c.GetMetadata().GetShardLeaderHistory(ctx, targetShardID)
+	re.NoError(err)
+	re.Len(history, 1)
+	re.Equal(targetShardID, history[0].ShardID)
+	re.Equal("", history[0].OldNode)
+	re.Equal(newLeaderNodeName, history[0].NewNode)
+	re.Equal("test", history[0].Reason)
 }
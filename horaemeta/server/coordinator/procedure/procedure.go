@@ -57,6 +57,9 @@ const (
 type Priority uint32
 
 // Lower value means higher priority.
+// The priority only decides the order in which the waiting procedures are promoted, so it takes effect when the procedures
+// contend for the same shard: the one with higher priority takes the shard lock first once the shard is released. A running
+// procedure is never interrupted, and the procedures on different shards run concurrently regardless of their priorities.
 const (
 	PriorityHigh Priority = 3
 	PriorityMed  Priority = 5
@@ -83,7 +86,8 @@ type Procedure interface {
 	// RelatedVersionInfo return the related shard and version information corresponding to this procedure for verifying whether the procedure can be executed.
 	RelatedVersionInfo() RelatedVersionInfo
 
-	// Priority present the priority of this procedure, the procedure with high level priority will be executed first.
+	// Priority present the priority of this procedure, the procedure with high level priority will be promoted first among
+	// the waiting procedures contending for the same shard.
 	Priority() Priority
 }
 
@@ -92,6 +96,14 @@ type Info struct {
 	ID    uint64
 	Kind  Kind
 	State State
+	// Priority is the effective priority used when promoting the procedure.
+	Priority Priority
+	// Initiator is who submits the procedure, and it is InitiatorSystem for the procedures generated by the meta server.
+	Initiator string
+	// RemainingMs is the time left before the procedure is cancelled for the timeout of its kind, and it is -1 if the procedure is unbounded.
+	RemainingMs int64
+	// Progress is the progress of the procedure made of multiple steps, and it is nil if the procedure doesn't report it.
+	Progress *Progress
 }
 
 type RelatedVersionInfo struct {
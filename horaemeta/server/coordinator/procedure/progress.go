@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import "sync/atomic"
+
+// Progress is the progress of a procedure made of multiple steps, e.g. the sub tables of a partition table.
+type Progress struct {
+	Done  uint32
+	Total uint32
+	// Fraction is the fraction of the done steps in [0, 1].
+	Fraction float64
+}
+
+// NewProgress creates the progress of the done steps out of the total steps, and the procedure without any step is regarded as done.
+func NewProgress(done, total uint32) Progress {
+	done = min(done, total)
+	fraction := 1.0
+	if total > 0 {
+		fraction = float64(done) / float64(total)
+	}
+	return Progress{Done: done, Total: total, Fraction: fraction}
+}
+
+// ProgressReporter is implemented by the procedures reporting their progress, which is exposed with the procedure info.
+type ProgressReporter interface {
+	Progress() Progress
+}
+
+// ProgressTracker tracks the done steps of a procedure, and it is safe to be advanced by the steps running concurrently.
+type ProgressTracker struct {
+	done  atomic.Uint32
+	total uint32
+}
+
+func NewProgressTracker(total uint32) *ProgressTracker {
+	return &ProgressTracker{done: atomic.Uint32{}, total: total}
+}
+
+// Advance marks a step as done.
+func (t *ProgressTracker) Advance() {
+	t.done.Add(1)
+}
+
+func (t *ProgressTracker) Progress() Progress {
+	return NewProgress(t.done.Load(), t.total)
+}
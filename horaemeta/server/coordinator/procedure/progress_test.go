@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProgress(t *testing.T) {
+	re := require.New(t)
+
+	re.Equal(Progress{Done: 0, Total: 0, Fraction: 1}, NewProgress(0, 0))
+	re.Equal(Progress{Done: 1, Total: 4, Fraction: 0.25}, NewProgress(1, 4))
+	// The done steps should never exceed the total steps.
+	re.Equal(Progress{Done: 4, Total: 4, Fraction: 1}, NewProgress(5, 4))
+}
+
+func TestProgressTracker(t *testing.T) {
+	re := require.New(t)
+
+	tracker := NewProgressTracker(2)
+	re.Equal(Progress{Done: 0, Total: 2, Fraction: 0}, tracker.Progress())
+	tracker.Advance()
+	re.Equal(Progress{Done: 1, Total: 2, Fraction: 0.5}, tracker.Progress())
+	tracker.Advance()
+	tracker.Advance()
+	re.Equal(Progress{Done: 2, Total: 2, Fraction: 1}, tracker.Progress())
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// allKinds contains all the kinds of procedures which may be persisted in the storage.
+var allKinds = []Kind{
+	Create, Delete, TransferLeader, Migrate, Split, Merge, Scatter,
+	CreateTable, DropTable, CreatePartitionTable, DropPartitionTable,
+}
+
+type PurgeResult struct {
+	// Count is the number of persisted finished procedures older than the retention.
+	Count int
+	// Purged is the number of procedures deleted from the storage, it is always zero in dry run mode.
+	Purged int
+}
+
+func isFinishedState(state State) bool {
+	switch state {
+	case StateFinished, StateFailed, StateCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// PurgeFinishedProcedures counts the persisted finished procedures whose last update is older than the retention, and deletes them from the storage unless dryRun is set.
+// The procedures in the running set of the manager are never touched.
+func PurgeFinishedProcedures(ctx context.Context, storage Storage, manager Manager, retention time.Duration, dryRun bool) (PurgeResult, error) {
+	result := PurgeResult{Count: 0, Purged: 0}
+
+	runningProcedures, err := manager.ListRunningProcedure(ctx)
+	if err != nil {
+		return result, errors.WithMessage(err, "list running procedures")
+	}
+	runningIDs := make(map[uint64]struct{}, len(runningProcedures))
+	for _, info := range runningProcedures {
+		runningIDs[info.ID] = struct{}{}
+	}
+
+	expiredAt := uint64(time.Now().Add(-retention).UnixMilli())
+	for _, kind := range allKinds {
+		metas, err := storage.List(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return result, errors.WithMessagef(err, "list procedures, kind:%d", kind)
+		}
+
+		for _, meta := range metas {
+			if !isFinishedState(meta.State) || meta.UpdatedAt > expiredAt {
+				continue
+			}
+			if _, ok := runningIDs[meta.ID]; ok {
+				continue
+			}
+
+			result.Count++
+			if dryRun {
+				continue
+			}
+			if err := storage.Delete(ctx, meta.Kind, meta.ID); err != nil {
+				return result, errors.WithMessagef(err, "delete procedure, id:%d", meta.ID)
+			}
+			result.Purged++
+		}
+	}
+
+	return result, nil
+}
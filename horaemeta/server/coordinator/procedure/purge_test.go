@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type mockManager struct {
+	runningProcedures []*Info
+}
+
+func (m mockManager) Start(_ context.Context) error {
+	return nil
+}
+
+func (m mockManager) Stop(_ context.Context) error {
+	return nil
+}
+
+func (m mockManager) Submit(_ context.Context, _ Procedure) error {
+	return nil
+}
+
+func (m mockManager) ListRunningProcedure(_ context.Context) ([]*Info, error) {
+	return m.runningProcedures, nil
+}
+
+func (m mockManager) CancelProceduresOfKind(_ context.Context, _ Kind) (CancelResult, error) {
+	return CancelResult{Cancelled: []uint64{}, Failed: []CancelFailure{}}, nil
+}
+
+func (m mockManager) TryLockShards(_ []storage.ShardID) (func(), error) {
+	return func() {}, nil
+}
+
+func TestPurgeFinishedProcedures(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	storage := NewTestStorage(t)
+	now := uint64(time.Now().UnixMilli())
+	metas := []Meta{
+		// Finished and expired.
+		{ID: 1, Kind: TransferLeader, State: StateFinished, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""},
+		// Failed but not expired.
+		{ID: 2, Kind: TransferLeader, State: StateFailed, RawData: []byte("test"), UpdatedAt: now, Initiator: ""},
+		// Not finished.
+		{ID: 3, Kind: TransferLeader, State: StateRunning, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""},
+		// Cancelled and expired, but still in the running set.
+		{ID: 4, Kind: TransferLeader, State: StateCancelled, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""},
+		// Finished and expired.
+		{ID: 5, Kind: CreateTable, State: StateFinished, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""},
+	}
+	for _, meta := range metas {
+		re.NoError(storage.CreateOrUpdate(ctx, meta))
+	}
+	manager := mockManager{runningProcedures: []*Info{{ID: 4, Kind: TransferLeader, State: StateRunning}}}
+
+	result, err := PurgeFinishedProcedures(ctx, storage, manager, time.Hour, true)
+	re.NoError(err)
+	re.Equal(2, result.Count)
+	re.Equal(0, result.Purged)
+
+	result, err = PurgeFinishedProcedures(ctx, storage, manager, time.Hour, false)
+	re.NoError(err)
+	re.Equal(2, result.Count)
+	re.Equal(2, result.Purged)
+
+	remaining, err := storage.List(ctx, TransferLeader, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Equal(3, len(remaining))
+	remaining, err = storage.List(ctx, CreateTable, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Equal(0, len(remaining))
+}
@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultStatsWindow is the default window in which the finished procedures are counted in the stats.
+const DefaultStatsWindow = time.Hour
+
+// Stats summarizes the running procedures and the procedures finished in the window by their states and kinds.
+type Stats struct {
+	WindowSec int64
+	// States is the number of the procedures of every state.
+	States map[State]int
+	// Kinds is the number of the procedures of every state, grouped by the names of the kinds, e.g. `createTable`.
+	Kinds map[string]map[State]int
+}
+
+func (s *Stats) add(kind Kind, state State) {
+	s.States[state]++
+
+	name := kindName(kind)
+	kindStates, ok := s.Kinds[name]
+	if !ok {
+		kindStates = map[State]int{}
+		s.Kinds[name] = kindStates
+	}
+	kindStates[state]++
+}
+
+// CollectStats counts the procedures in the running set of the manager, and the persisted procedures finished in the window.
+// The persisted procedures without the update time are skipped because it is unknown when they are finished.
+func CollectStats(ctx context.Context, storage Storage, manager Manager, window time.Duration) (Stats, error) {
+	stats := Stats{
+		WindowSec: int64(window / time.Second),
+		States:    map[State]int{},
+		Kinds:     map[string]map[State]int{},
+	}
+
+	runningProcedures, err := manager.ListRunningProcedure(ctx)
+	if err != nil {
+		return stats, errors.WithMessage(err, "list running procedures")
+	}
+	runningIDs := make(map[uint64]struct{}, len(runningProcedures))
+	for _, info := range runningProcedures {
+		runningIDs[info.ID] = struct{}{}
+		stats.add(info.Kind, info.State)
+	}
+
+	finishedAfter := uint64(time.Now().Add(-window).UnixMilli())
+	for _, kind := range allKinds {
+		metas, err := storage.List(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return stats, errors.WithMessagef(err, "list procedures, kind:%d", kind)
+		}
+
+		for _, meta := range metas {
+			if !isFinishedState(meta.State) || meta.UpdatedAt < finishedAfter {
+				continue
+			}
+			if _, ok := runningIDs[meta.ID]; ok {
+				continue
+			}
+			stats.add(meta.Kind, meta.State)
+		}
+	}
+
+	return stats, nil
+}
+
+// CountRunningKinds counts the running procedures of the manager by the names of their kinds, e.g. `createTable`, which is much
+// cheaper than CollectStats because the persisted procedures are not scanned.
+func CountRunningKinds(ctx context.Context, manager Manager) (map[string]int, error) {
+	runningProcedures, err := manager.ListRunningProcedure(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "list running procedures")
+	}
+
+	counts := make(map[string]int)
+	for _, info := range runningProcedures {
+		counts[kindName(info.Kind)]++
+	}
+	return counts, nil
+}
+
+// kindName returns the name of the kind used in the configuration, or the number of the kind if it is unnamed.
+func kindName(kind Kind) string {
+	for name, k := range kindNames {
+		if k == kind {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(kind), 10)
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectStats(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	storage := NewTestStorage(t)
+	now := uint64(time.Now().UnixMilli())
+	expired := uint64(time.Now().Add(-2 * time.Hour).UnixMilli())
+	metas := []Meta{
+		// Finished in the window.
+		{ID: 1, Kind: CreateTable, State: StateFinished, RawData: []byte("test"), UpdatedAt: now, Initiator: ""},
+		{ID: 2, Kind: CreateTable, State: StateFailed, RawData: []byte("test"), UpdatedAt: now, Initiator: ""},
+		{ID: 3, Kind: TransferLeader, State: StateFinished, RawData: []byte("test"), UpdatedAt: now, Initiator: ""},
+		// Finished out of the window.
+		{ID: 4, Kind: CreateTable, State: StateFinished, RawData: []byte("test"), UpdatedAt: expired, Initiator: ""},
+		// Finished by the old version without the update time.
+		{ID: 5, Kind: DropTable, State: StateFailed, RawData: []byte("test"), UpdatedAt: 0, Initiator: ""},
+		// Persisted as finished, but still in the running set.
+		{ID: 6, Kind: Split, State: StateFinished, RawData: []byte("test"), UpdatedAt: now, Initiator: ""},
+	}
+	for _, meta := range metas {
+		re.NoError(storage.CreateOrUpdate(ctx, meta))
+	}
+	manager := mockManager{runningProcedures: []*Info{{ID: 6, Kind: Split, State: StateRunning}}}
+
+	stats, err := CollectStats(ctx, storage, manager, time.Hour)
+	re.NoError(err)
+	re.Equal(int64(3600), stats.WindowSec)
+	re.Equal(map[State]int{StateRunning: 1, StateFinished: 2, StateFailed: 1}, stats.States)
+	re.Equal(map[string]map[State]int{
+		"createTable":    {StateFinished: 1, StateFailed: 1},
+		"transferLeader": {StateFinished: 1},
+		"split":          {StateRunning: 1},
+	}, stats.Kinds)
+
+	// The procedures finished out of the window are counted in a wider window.
+	stats, err = CollectStats(ctx, storage, manager, 3*time.Hour)
+	re.NoError(err)
+	re.Equal(2, stats.Kinds["createTable"][StateFinished])
+}
+
+func TestCountRunningKinds(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	counts, err := CountRunningKinds(ctx, mockManager{runningProcedures: []*Info{}})
+	re.NoError(err)
+	re.Empty(counts)
+
+	manager := mockManager{runningProcedures: []*Info{
+		{ID: 1, Kind: CreateTable, State: StateRunning},
+		{ID: 2, Kind: CreateTable, State: StateRunning},
+		{ID: 3, Kind: Split, State: StateRunning},
+	}}
+	counts, err = CountRunningKinds(ctx, manager)
+	re.NoError(err)
+	re.Equal(map[string]int{"createTable": 2, "split": 1}, counts)
+}
@@ -21,6 +21,8 @@ package procedure
 
 import (
 	"context"
+
+	"github.com/pkg/errors"
 )
 
 type Write interface {
@@ -33,11 +35,33 @@ type Meta struct {
 	Kind    Kind
 	State   State
 	RawData []byte
+	// UpdatedAt is the unix timestamp in milliseconds when the meta is persisted, and it is zero for the meta persisted by the old version.
+	UpdatedAt uint64
+	// Initiator is who submits the procedure, and it is empty for the meta persisted by the old version.
+	Initiator string
 }
 
+// Storage persists the procedures of a cluster, and the implementations must be safe for concurrent use.
 type Storage interface {
 	Write
 	List(ctx context.Context, procedureType Kind, batchSize int) ([]*Meta, error)
 	Delete(ctx context.Context, procedureType Kind, id uint64) error
 	MarkDeleted(ctx context.Context, procedureType Kind, id uint64) error
 }
+
+// FindMeta searches the persisted procedures of all kinds for the one with the given id.
+func FindMeta(ctx context.Context, storage Storage, id uint64) (*Meta, error) {
+	for _, kind := range allKinds {
+		metas, err := storage.List(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "list procedures, kind:%d", kind)
+		}
+		for _, meta := range metas {
+			if meta.ID == id {
+				return meta, nil
+			}
+		}
+	}
+
+	return nil, ErrProcedureNotFound.WithCausef("procedure is not persisted, id:%d", id)
+}
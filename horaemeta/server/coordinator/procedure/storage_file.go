@@ -0,0 +1,191 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const tmpFileSuffix = ".tmp"
+
+// fileEntry is the content of the file of a procedure.
+type fileEntry struct {
+	Meta *Meta
+	// ExpireAt is the unix timestamp in milliseconds after which the procedure is expired, and zero means never.
+	ExpireAt int64
+}
+
+// FileStorageImpl persists the procedures in the local files, and it is only used for testing because the procedures are lost once
+// the leader changes. The file layout is:
+// {dir}/{clusterID}/{procedure|deletedProcedure}/{procedureType}/{procedureID}
+type FileStorageImpl struct {
+	lock    sync.Mutex
+	rootDir string
+}
+
+func NewFileStorageImpl(dir string, clusterID uint32) Storage {
+	return &FileStorageImpl{
+		lock:    sync.Mutex{},
+		rootDir: filepath.Join(dir, fmtID(uint64(clusterID))),
+	}
+}
+
+func (f *FileStorageImpl) CreateOrUpdate(_ context.Context, meta Meta) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.write(f.normalPath(meta.Kind, meta.ID), fileEntry{Meta: &meta, ExpireAt: 0})
+}
+
+// CreateOrUpdateWithTTL
+// ttl is only valid when greater than 0, if it is less than or equal to 0, it will be ignored.
+func (f *FileStorageImpl) CreateOrUpdateWithTTL(_ context.Context, meta Meta, ttlSec int64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var expireAt int64
+	if ttlSec > 0 {
+		expireAt = time.Now().Add(time.Duration(ttlSec) * time.Second).UnixMilli()
+	}
+	return f.write(f.normalPath(meta.Kind, meta.ID), fileEntry{Meta: &meta, ExpireAt: expireAt})
+}
+
+// Delete will delete the specified procedure, and its corresponding history procedure if it exists.
+func (f *FileStorageImpl) Delete(_ context.Context, procedureType Kind, id uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, p := range []string{f.normalPath(procedureType, id), f.deletedPath(procedureType, id)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return errors.WithMessagef(err, "remove procedure file, path:%s", p)
+		}
+	}
+	return nil
+}
+
+// MarkDeleted moves the procedure to the deleted directory.
+func (f *FileStorageImpl) MarkDeleted(_ context.Context, procedureType Kind, id uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	normalPath := f.normalPath(procedureType, id)
+	entry, err := f.read(normalPath)
+	if err != nil {
+		return err
+	}
+	if err := f.write(f.deletedPath(procedureType, id), entry); err != nil {
+		return err
+	}
+	if err := os.Remove(normalPath); err != nil {
+		return errors.WithMessagef(err, "remove procedure file, path:%s", normalPath)
+	}
+	return nil
+}
+
+// List returns the procedures of the type ordered by the id, and the expired ones are removed.
+func (f *FileStorageImpl) List(_ context.Context, procedureType Kind, _ int) ([]*Meta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	dir := filepath.Join(f.rootDir, PathProcedure, strconv.Itoa(int(procedureType)))
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithMessagef(err, "read procedure dir, dir:%s", dir)
+	}
+	// The file names are the zero padded ids, so the procedures are ordered by the id.
+	sort.Slice(dirEntries, func(i, j int) bool { return dirEntries[i].Name() < dirEntries[j].Name() })
+
+	now := time.Now().UnixMilli()
+	var metas []*Meta
+	for _, dirEntry := range dirEntries {
+		// The temporary file is left by an interrupted write.
+		if strings.HasSuffix(dirEntry.Name(), tmpFileSuffix) {
+			continue
+		}
+		p := filepath.Join(dir, dirEntry.Name())
+		entry, err := f.read(p)
+		if err != nil {
+			return nil, err
+		}
+		if entry.ExpireAt > 0 && entry.ExpireAt <= now {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return nil, errors.WithMessagef(err, "remove expired procedure file, path:%s", p)
+			}
+			continue
+		}
+		metas = append(metas, entry.Meta)
+	}
+	return metas, nil
+}
+
+func (f *FileStorageImpl) normalPath(procedureType Kind, id uint64) string {
+	return filepath.Join(f.rootDir, PathProcedure, strconv.Itoa(int(procedureType)), fmtID(id))
+}
+
+func (f *FileStorageImpl) deletedPath(procedureType Kind, id uint64) string {
+	return filepath.Join(f.rootDir, PathDeletedProcedure, strconv.Itoa(int(procedureType)), fmtID(id))
+}
+
+func (f *FileStorageImpl) read(p string) (fileEntry, error) {
+	var entry fileEntry
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entry, ErrProcedureNotFound.WithCausef("procedure file not found, path:%s", p)
+		}
+		return entry, errors.WithMessagef(err, "read procedure file, path:%s", p)
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, errors.WithMessagef(err, "decode procedure file, path:%s", p)
+	}
+	return entry, nil
+}
+
+// write replaces the file atomically by renaming a temporary file to it.
+func (f *FileStorageImpl) write(p string, entry fileEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithMessage(err, "encode meta failed")
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.WithMessagef(err, "create procedure dir, path:%s", p)
+	}
+	tmpPath := p + tmpFileSuffix
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return errors.WithMessagef(err, "write procedure file, path:%s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, p); err != nil {
+		return errors.WithMessagef(err, "rename procedure file, path:%s", p)
+	}
+	return nil
+}
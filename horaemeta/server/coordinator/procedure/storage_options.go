@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PathStoragePrefix is where the root path of the procedures of every cluster is recorded, so that changing it is detected.
+const PathStoragePrefix = "procedureStoragePrefix"
+
+// StorageOptions determines where the procedures are persisted in etcd.
+type StorageOptions struct {
+	// EtcdPrefix replaces the cluster root path of the procedure keys if it is not empty, so that the procedure churn is kept apart
+	// from the metadata keyspace. It is refused to be changed while any procedure is left under the previous path.
+	EtcdPrefix string
+}
+
+// NewStorage creates the procedure storage of the cluster in etcd. The root path in use is recorded under the cluster root path, and
+// ErrStoragePrefixChanged is returned if it differs from the recorded one while the procedures are left under the recorded one,
+// because these procedures would be orphaned silently.
+func NewStorage(ctx context.Context, client *clientv3.Client, clusterRootPath string, clusterID uint32, opts StorageOptions) (Storage, error) {
+	rootPath := clusterRootPath
+	if len(opts.EtcdPrefix) > 0 {
+		rootPath = opts.EtcdPrefix
+	}
+
+	recordKey := path.Join(clusterRootPath, Version, PathStoragePrefix, fmtID(uint64(clusterID)))
+	resp, err := client.Get(ctx, recordKey)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "get procedure storage prefix, key:%s", recordKey)
+	}
+	// The procedures are kept under the cluster root path before the prefix is recorded.
+	prevRootPath := clusterRootPath
+	if len(resp.Kvs) > 0 {
+		prevRootPath = string(resp.Kvs[0].Value)
+	}
+
+	if prevRootPath != rootPath {
+		procedurePrefix := path.Join(prevRootPath, Version, PathProcedure, fmtID(uint64(clusterID))) + "/"
+		countResp, err := client.Get(ctx, procedurePrefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+		if err != nil {
+			return nil, errors.WithMessagef(err, "count procedures under the previous prefix, prefix:%s", procedurePrefix)
+		}
+		if countResp.Count > 0 {
+			return nil, ErrStoragePrefixChanged.WithCausef("procedures are left under the previous prefix, prevPrefix:%s, prefix:%s, procedures:%d", prevRootPath, rootPath, countResp.Count)
+		}
+	}
+	if len(resp.Kvs) == 0 || prevRootPath != rootPath {
+		if _, err := client.Put(ctx, recordKey, rootPath); err != nil {
+			return nil, errors.WithMessagef(err, "put procedure storage prefix, key:%s", recordKey)
+		}
+	}
+
+	return NewEtcdStorageImpl(client, rootPath, clusterID), nil
+}
@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/stretchr/testify/require"
 )
@@ -41,10 +42,12 @@ func testWrite(t *testing.T, storage Storage) {
 	defer cancel()
 
 	testMeta1 := Meta{
-		ID:      uint64(1),
-		Kind:    TransferLeader,
-		State:   StateInit,
-		RawData: []byte("test"),
+		ID:        uint64(1),
+		Kind:      TransferLeader,
+		State:     StateInit,
+		RawData:   []byte("test"),
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: InitiatorSystem,
 	}
 
 	// Test create new procedure
@@ -52,10 +55,12 @@ func testWrite(t *testing.T, storage Storage) {
 	re.NoError(err)
 
 	testMeta2 := Meta{
-		ID:      uint64(2),
-		Kind:    TransferLeader,
-		State:   StateInit,
-		RawData: []byte("test"),
+		ID:        uint64(2),
+		Kind:      TransferLeader,
+		State:     StateInit,
+		RawData:   []byte("test"),
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: "operator",
 	}
 	err = storage.CreateOrUpdate(ctx, testMeta2)
 	re.NoError(err)
@@ -76,6 +81,8 @@ func testScan(t *testing.T, storage Storage) {
 	re.Equal(2, len(metas))
 	re.Equal("test", string(metas[0].RawData))
 	re.Equal("test update", string(metas[1].RawData))
+	re.Equal(InitiatorSystem, metas[0].Initiator)
+	re.Equal("operator", metas[1].Initiator)
 }
 
 func testDelete(t *testing.T, storage Storage) {
@@ -84,10 +91,12 @@ func testDelete(t *testing.T, storage Storage) {
 	defer cancel()
 
 	testMeta1 := &Meta{
-		ID:      uint64(1),
-		Kind:    TransferLeader,
-		State:   StateInit,
-		RawData: []byte("test"),
+		ID:        uint64(1),
+		Kind:      TransferLeader,
+		State:     StateInit,
+		RawData:   []byte("test"),
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: InitiatorSystem,
 	}
 	err := storage.MarkDeleted(ctx, TransferLeader, testMeta1.ID)
 	re.NoError(err)
@@ -97,10 +106,12 @@ func testDelete(t *testing.T, storage Storage) {
 	re.Equal(1, len(metas))
 
 	testMeta2 := Meta{
-		ID:      uint64(2),
-		Kind:    TransferLeader,
-		State:   StateInit,
-		RawData: []byte("test"),
+		ID:        uint64(2),
+		Kind:      TransferLeader,
+		State:     StateInit,
+		RawData:   []byte("test"),
+		UpdatedAt: uint64(time.Now().UnixMilli()),
+		Initiator: InitiatorSystem,
 	}
 	err = storage.Delete(ctx, TransferLeader, testMeta2.ID)
 	re.NoError(err)
@@ -122,3 +133,70 @@ func TestStorage(t *testing.T) {
 	testScan(t, storage)
 	testDelete(t, storage)
 }
+
+func TestFileStorage(t *testing.T) {
+	storage := NewFileStorageImpl(t.TempDir(), TestClusterID)
+	testWrite(t, storage)
+	testScan(t, storage)
+	testDelete(t, storage)
+}
+
+func TestFileStorageTTL(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	dir := t.TempDir()
+	storage := NewFileStorageImpl(dir, TestClusterID)
+	meta := Meta{ID: 1, Kind: Split, State: StateFinished, RawData: []byte("test"), UpdatedAt: 0, Initiator: InitiatorSystem}
+	re.NoError(storage.CreateOrUpdateWithTTL(ctx, meta, 1))
+	metas, err := storage.List(ctx, Split, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Len(metas, 1)
+
+	// The procedures are kept apart by the clusters.
+	metas, err = NewFileStorageImpl(dir, TestClusterID+1).List(ctx, Split, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Empty(metas)
+
+	// The expired procedure is not listed.
+	re.Eventually(func() bool {
+		metas, err := storage.List(ctx, Split, DefaultScanBatchSie)
+		return err == nil && len(metas) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The procedure not persisted can't be marked as deleted.
+	err = storage.MarkDeleted(ctx, Split, meta.ID)
+	re.True(coderr.Is(err, ErrProcedureNotFound.Code()))
+}
+
+func TestNewStorage(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
+
+	// The cluster root path is used by default.
+	storage, err := NewStorage(ctx, client, TestRootPath, TestClusterID, StorageOptions{EtcdPrefix: ""})
+	re.NoError(err)
+	re.Equal(TestRootPath, storage.(*EtcdStorageImpl).rootPath)
+	meta := Meta{ID: 1, Kind: TransferLeader, State: StateInit, RawData: []byte("test"), UpdatedAt: uint64(time.Now().UnixMilli()), Initiator: InitiatorSystem}
+	re.NoError(storage.CreateOrUpdate(ctx, meta))
+
+	// The prefix can't be changed while any procedure is left under the previous one.
+	_, err = NewStorage(ctx, client, TestRootPath, TestClusterID, StorageOptions{EtcdPrefix: "/procedures"})
+	re.True(coderr.Is(err, ErrStoragePrefixChanged.Code()))
+
+	// The prefix is changed once the procedures under the previous one are finished.
+	re.NoError(storage.Delete(ctx, meta.Kind, meta.ID))
+	storage, err = NewStorage(ctx, client, TestRootPath, TestClusterID, StorageOptions{EtcdPrefix: "/procedures"})
+	re.NoError(err)
+	re.Equal("/procedures", storage.(*EtcdStorageImpl).rootPath)
+	re.NoError(storage.CreateOrUpdate(ctx, meta))
+
+	// Changing it back is refused too.
+	_, err = NewStorage(ctx, client, TestRootPath, TestClusterID, StorageOptions{EtcdPrefix: ""})
+	re.True(coderr.Is(err, ErrStoragePrefixChanged.Code()))
+	_, err = NewStorage(ctx, client, TestRootPath, TestClusterID, StorageOptions{EtcdPrefix: "/procedures"})
+	re.NoError(err)
+}
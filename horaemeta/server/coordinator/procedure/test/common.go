@@ -28,6 +28,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
@@ -46,6 +47,7 @@ const (
 	TestSchemaName                     = "TestSchemaName"
 	TestRootPath                       = "/rootPath"
 	DefaultIDAllocatorStep             = 20
+	DefaultSchedulerConcurrency        = 2
 	ClusterName                        = "testCluster1"
 	DefaultNodeCount                   = 2
 	DefaultShardTotal                  = 4
@@ -80,6 +82,10 @@ func (m MockDispatch) CloseTableOnShard(_ context.Context, _ string, _ eventdisp
 	return nil
 }
 
+func (m MockDispatch) PreWarmShard(_ context.Context, _ string, _ eventdispatch.PreWarmShardRequest) error {
+	return nil
+}
+
 type MockStorage struct{}
 
 func (m MockStorage) CreateOrUpdate(_ context.Context, _ procedure.Meta) error {
@@ -116,13 +122,17 @@ func (m MockIDAllocator) Collect(_ context.Context, _ uint64) error {
 	return nil
 }
 
+func (m MockIDAllocator) SkipTo(_ context.Context, _ uint64) error {
+	return nil
+}
+
 // InitEmptyCluster will return a cluster that has created shards and nodes, but it does not have any shard node mapping.
 func InitEmptyCluster(ctx context.Context, t *testing.T) *cluster.Cluster {
 	re := require.New(t)
 
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 	clusterStorage := storage.NewStorageWithEtcdBackend(client, TestRootPath, storage.Options{
-		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 10,
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 10, ReadTimeout: 0, WriteTimeout: 0,
 	})
 
 	logger := zap.NewNop()
@@ -144,7 +154,7 @@ func InitEmptyCluster(ctx context.Context, t *testing.T) *cluster.Cluster {
 	err = clusterMetadata.Load(ctx)
 	re.NoError(err)
 
-	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath)
+	c, err := cluster.NewCluster(ctx, logger, clusterMetadata, client, TestRootPath, TestRootPath, DefaultSchedulerConcurrency, procedure.Timeouts{}, procedure.StorageOptions{EtcdPrefix: ""}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
 	re.NoError(err)
 
 	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, TestSchemaName)
@@ -173,7 +183,7 @@ func InitEmptyClusterWithConfig(ctx context.Context, t *testing.T, shardNumber i
 
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 	clusterStorage := storage.NewStorageWithEtcdBackend(client, TestRootPath, storage.Options{
-		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32,
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
 	})
 
 	logger := zap.NewNop()
@@ -195,7 +205,7 @@ func InitEmptyClusterWithConfig(ctx context.Context, t *testing.T, shardNumber i
 	err = clusterMetadata.Load(ctx)
 	re.NoError(err)
 
-	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath)
+	c, err := cluster.NewCluster(ctx, logger, clusterMetadata, client, TestRootPath, TestRootPath, DefaultSchedulerConcurrency, procedure.Timeouts{}, procedure.StorageOptions{EtcdPrefix: ""}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
 	re.NoError(err)
 
 	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, TestSchemaName)
@@ -263,8 +273,11 @@ func InitStableClusterWithConfig(ctx context.Context, t *testing.T, nodeNumber i
 		unAssignedShardIDs = append(unAssignedShardIDs, storage.ShardID(i))
 	}
 	shardNodeMapping, err := nodePicker.PickNode(ctx, nodepicker.Config{
-		NumTotalShards:    uint32(shardNumber),
-		ShardAffinityRule: map[storage.ShardID]scheduler.ShardAffinity{},
+		NumTotalShards:       uint32(shardNumber),
+		ShardAffinityRule:    map[storage.ShardID]scheduler.ShardAffinity{},
+		ShardMinNodeVersions: map[storage.ShardID]string{},
+		PreferredNodes:       map[storage.ShardID]string{},
+		ShardPermutation:     false,
 	}, unAssignedShardIDs, snapshot.RegisteredNodes)
 	re.NoError(err)
 
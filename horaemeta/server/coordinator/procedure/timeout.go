@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"strings"
+	"time"
+)
+
+// kindNames are the names of the kinds used in the configuration.
+var kindNames = map[string]Kind{
+	"create":               Create,
+	"delete":               Delete,
+	"transferLeader":       TransferLeader,
+	"migrate":              Migrate,
+	"split":                Split,
+	"merge":                Merge,
+	"scatter":              Scatter,
+	"createTable":          CreateTable,
+	"dropTable":            DropTable,
+	"createPartitionTable": CreatePartitionTable,
+	"dropPartitionTable":   DropPartitionTable,
+}
+
+// ParseKind parses the name of the kind, e.g. `createTable`.
+func ParseKind(name string) (Kind, error) {
+	kind, ok := kindNames[name]
+	if !ok {
+		return 0, ErrParseProcedureTimeout.WithCausef("unknown procedure kind, name:%s", name)
+	}
+	return kind, nil
+}
+
+// Timeouts bounds the execution of the procedures by their kinds, and the procedures of the kinds not included are unbounded.
+type Timeouts map[Kind]time.Duration
+
+// ParseTimeouts parses the timeouts formatted as `kind=duration`, e.g. `createTable=1m` and `split=30m`.
+// The CreatePartitionTable kind is rejected, because the sub tables created are left behind if the procedure is cancelled halfway.
+func ParseTimeouts(rawTimeouts []string) (Timeouts, error) {
+	timeouts := make(Timeouts, len(rawTimeouts))
+	for _, rawTimeout := range rawTimeouts {
+		name, rawDuration, ok := strings.Cut(rawTimeout, "=")
+		if !ok {
+			return nil, ErrParseProcedureTimeout.WithCausef("timeout must be formatted as kind=duration, timeout:%s", rawTimeout)
+		}
+		kind, err := ParseKind(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if kind == CreatePartitionTable {
+			return nil, ErrParseProcedureTimeout.WithCausef("createPartitionTable can't be bounded since it is not rolled back, timeout:%s", rawTimeout)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(rawDuration))
+		if err != nil {
+			return nil, ErrParseProcedureTimeout.WithCausef("parse duration, timeout:%s, err:%v", rawTimeout, err)
+		}
+		if duration <= 0 {
+			return nil, ErrParseProcedureTimeout.WithCausef("duration must be positive, timeout:%s", rawTimeout)
+		}
+		timeouts[kind] = duration
+	}
+	return timeouts, nil
+}
+
+// Of returns the timeout of the kind, and zero means the procedure is unbounded.
+func (t Timeouts) Of(kind Kind) time.Duration {
+	return t[kind]
+}
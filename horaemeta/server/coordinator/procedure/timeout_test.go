@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	re := require.New(t)
+
+	timeouts, err := procedure.ParseTimeouts([]string{"createTable=1m", " split = 30m "})
+	re.NoError(err)
+	re.Equal(time.Minute, timeouts.Of(procedure.CreateTable))
+	re.Equal(30*time.Minute, timeouts.Of(procedure.Split))
+	// The kinds not configured are unbounded.
+	re.Equal(time.Duration(0), timeouts.Of(procedure.DropTable))
+
+	timeouts, err = procedure.ParseTimeouts(nil)
+	re.NoError(err)
+	re.Empty(timeouts)
+
+	for _, rawTimeout := range []string{"createTable", "unknown=1m", "createTable=1", "createTable=-1s", "createTable=0s", "createPartitionTable=5m"} {
+		_, err = procedure.ParseTimeouts([]string{rawTimeout})
+		re.Error(err, rawTimeout)
+		re.True(coderr.Is(err, procedure.ErrParseProcedureTimeout.Code()), rawTimeout)
+	}
+}
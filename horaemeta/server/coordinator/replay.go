@@ -0,0 +1,310 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// RedactedValue replaces the sensitive values in the exported procedure definitions.
+const RedactedValue = "<redacted>"
+
+// sensitiveOptionKeys contains the substrings of the table option keys whose values should never be exported.
+var sensitiveOptionKeys = []string{"password", "secret", "token", "credential", "access_key"}
+
+// ProcedureDefinition describes the inputs of a procedure, which can be used to replay the procedure in another cluster.
+// Only the DDL procedures and the split procedure are replayable. The other operation procedures, e.g. TransferLeader, are
+// generated by the schedulers from the cluster state and their inputs are not persisted, so they are rejected explicitly.
+type ProcedureDefinition struct {
+	Kind procedure.Kind `json:"kind"`
+	// SourceID is the id of the exported procedure, and it is ignored when the definition is replayed.
+	SourceID uint64 `json:"sourceId"`
+	// Initiator is who submits the exported procedure, and it is ignored when the definition is replayed.
+	Initiator string `json:"initiator,omitempty"`
+
+	Split                *SplitDefinition                `json:"split,omitempty"`
+	CreateTable          *CreateTableDefinition          `json:"createTable,omitempty"`
+	DropTable            *DropTableDefinition            `json:"dropTable,omitempty"`
+	CreatePartitionTable *CreatePartitionTableDefinition `json:"createPartitionTable,omitempty"`
+	DropPartitionTable   *DropPartitionTableDefinition   `json:"dropPartitionTable,omitempty"`
+}
+
+type SplitDefinition struct {
+	SchemaName     string   `json:"schemaName"`
+	TableNames     []string `json:"tableNames"`
+	ShardID        uint32   `json:"shardId"`
+	TargetNodeName string   `json:"targetNodeName"`
+}
+
+type CreateTableDefinition struct {
+	Request *metaservicepb.CreateTableRequest `json:"request"`
+}
+
+type DropTableDefinition struct {
+	Request *metaservicepb.DropTableRequest `json:"request"`
+}
+
+type CreatePartitionTableDefinition struct {
+	Request *metaservicepb.CreateTableRequest `json:"request"`
+}
+
+type DropPartitionTableDefinition struct {
+	Request *metaservicepb.DropTableRequest `json:"request"`
+}
+
+// The layouts below mirror the persisted raw data of the procedures, only the inputs are decoded.
+type splitRawData struct {
+	SchemaName     string
+	TableNames     []string
+	ShardID        uint32
+	TargetNodeName string
+}
+
+type createTableRawData struct {
+	SourceReq *metaservicepb.CreateTableRequest
+}
+
+type dropTableRawData struct {
+	SourceReq *metaservicepb.DropTableRequest
+}
+
+type createPartitionTableRawData struct {
+	SourceReq *metaservicepb.CreateTableRequest
+}
+
+type dropPartitionTableRawData struct {
+	DropTableRequest *metaservicepb.DropTableRequest
+}
+
+// ExportProcedureDefinition extracts the replayable definition from the persisted procedure meta, and the sensitive fields are redacted.
+func ExportProcedureDefinition(meta *procedure.Meta) (ProcedureDefinition, error) {
+	def := ProcedureDefinition{
+		Kind:                 meta.Kind,
+		SourceID:             meta.ID,
+		Initiator:            meta.Initiator,
+		Split:                nil,
+		CreateTable:          nil,
+		DropTable:            nil,
+		CreatePartitionTable: nil,
+		DropPartitionTable:   nil,
+	}
+
+	switch meta.Kind {
+	case procedure.Split:
+		var rawData splitRawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return def, procedure.ErrDecodeRawData.WithCausef("decode split raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		def.Split = &SplitDefinition{
+			SchemaName:     rawData.SchemaName,
+			TableNames:     rawData.TableNames,
+			ShardID:        rawData.ShardID,
+			TargetNodeName: rawData.TargetNodeName,
+		}
+	case procedure.CreateTable:
+		var rawData createTableRawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return def, procedure.ErrDecodeRawData.WithCausef("decode create table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		if rawData.SourceReq == nil {
+			return def, ErrProcedureNotReplayable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		rawData.SourceReq.Header = nil
+		rawData.SourceReq.Options = redactOptions(rawData.SourceReq.Options)
+		def.CreateTable = &CreateTableDefinition{Request: rawData.SourceReq}
+	case procedure.DropTable:
+		var rawData dropTableRawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return def, procedure.ErrDecodeRawData.WithCausef("decode drop table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		if rawData.SourceReq == nil {
+			return def, ErrProcedureNotReplayable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		rawData.SourceReq.Header = nil
+		def.DropTable = &DropTableDefinition{Request: rawData.SourceReq}
+	case procedure.CreatePartitionTable:
+		var rawData createPartitionTableRawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return def, procedure.ErrDecodeRawData.WithCausef("decode create partition table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		// The source request is only persisted since the procedure definition is supported.
+		if rawData.SourceReq == nil {
+			return def, ErrProcedureNotReplayable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		rawData.SourceReq.Header = nil
+		rawData.SourceReq.Options = redactOptions(rawData.SourceReq.Options)
+		def.CreatePartitionTable = &CreatePartitionTableDefinition{Request: rawData.SourceReq}
+	case procedure.DropPartitionTable:
+		var rawData dropPartitionTableRawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return def, procedure.ErrDecodeRawData.WithCausef("decode drop partition table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		if rawData.DropTableRequest == nil {
+			return def, ErrProcedureNotReplayable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		rawData.DropTableRequest.Header = nil
+		def.DropPartitionTable = &DropPartitionTableDefinition{Request: rawData.DropTableRequest}
+	case procedure.Create, procedure.Delete, procedure.TransferLeader, procedure.Migrate, procedure.Merge, procedure.Scatter:
+		return def, ErrProcedureNotReplayable.WithCausef("inputs of the procedure are derived from the cluster state and not persisted, procedureID:%d, kind:%d", meta.ID, meta.Kind)
+	default:
+		return def, ErrProcedureNotReplayable.WithCausef("procedure kind is not supported, procedureID:%d, kind:%d", meta.ID, meta.Kind)
+	}
+
+	return def, nil
+}
+
+func isSensitiveOption(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveOptionKeys {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactOptions(options map[string]string) map[string]string {
+	for key := range options {
+		if isSensitiveOption(key) {
+			options[key] = RedactedValue
+		}
+	}
+	return options
+}
+
+// dropRedactedOptions removes the redacted options so that the defaults are used when the definition is replayed.
+func dropRedactedOptions(options map[string]string) {
+	for key, value := range options {
+		if value == RedactedValue {
+			delete(options, key)
+		}
+	}
+}
+
+// CreateProcedureFromDefinition reconstructs a new procedure from the definition in the cluster described by clusterMetadata.
+func (f *Factory) CreateProcedureFromDefinition(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, def ProcedureDefinition) (procedure.Procedure, error) {
+	header := &metaservicepb.RequestHeader{Node: "", ClusterName: clusterMetadata.Name()}
+
+	switch def.Kind {
+	case procedure.Split:
+		if def.Split == nil {
+			return nil, ErrProcedureNotReplayable.WithCausef("split definition is missing")
+		}
+		newShardID, err := clusterMetadata.AllocShardID(ctx)
+		if err != nil {
+			return nil, errors.WithMessage(err, "alloc shard id")
+		}
+		return f.CreateSplitProcedure(ctx, SplitRequest{
+			ClusterMetadata: clusterMetadata,
+			SchemaName:      def.Split.SchemaName,
+			TableNames:      def.Split.TableNames,
+			Snapshot:        clusterMetadata.GetClusterSnapshot(),
+			ShardID:         storage.ShardID(def.Split.ShardID),
+			NewShardID:      storage.ShardID(newShardID),
+			TargetNodeName:  def.Split.TargetNodeName,
+		})
+	case procedure.CreateTable:
+		if def.CreateTable == nil || def.CreateTable.Request == nil || def.CreateTable.Request.PartitionTableInfo != nil {
+			return nil, ErrProcedureNotReplayable.WithCausef("create table definition is missing")
+		}
+		req := def.CreateTable.Request
+		req.Header = header
+		dropRedactedOptions(req.Options)
+		return f.makeCreateTableProcedure(ctx, CreateTableRequest{
+			ClusterMetadata: clusterMetadata,
+			SourceReq:       req,
+			OnSucceeded: func(ret metadata.CreateTableResult) error {
+				f.logger.Info("replayed create table procedure succeeded", zap.String("tableName", ret.Table.Name))
+				return nil
+			},
+			OnFailed: func(err error) error {
+				f.logger.Warn("replayed create table procedure failed", zap.String("tableName", req.GetName()), zap.Error(err))
+				return nil
+			},
+		})
+	case procedure.DropTable:
+		if def.DropTable == nil || def.DropTable.Request == nil || def.DropTable.Request.PartitionTableInfo != nil {
+			return nil, ErrProcedureNotReplayable.WithCausef("drop table definition is missing")
+		}
+		return f.createDropTableProcedureFromRequest(ctx, clusterMetadata, def.DropTable.Request, header)
+	case procedure.CreatePartitionTable:
+		if def.CreatePartitionTable == nil || def.CreatePartitionTable.Request == nil || def.CreatePartitionTable.Request.PartitionTableInfo == nil {
+			return nil, ErrProcedureNotReplayable.WithCausef("create partition table definition is missing")
+		}
+		req := def.CreatePartitionTable.Request
+		req.Header = header
+		dropRedactedOptions(req.Options)
+		return f.makeCreatePartitionTableProcedure(ctx, CreatePartitionTableRequest{
+			ClusterMetadata: clusterMetadata,
+			SourceReq:       req,
+			OnSucceeded: func(ret metadata.CreateTableResult) error {
+				f.logger.Info("replayed create partition table procedure succeeded", zap.String("tableName", ret.Table.Name))
+				return nil
+			},
+			OnFailed: func(err error) error {
+				f.logger.Warn("replayed create partition table procedure failed", zap.String("tableName", req.GetName()), zap.Error(err))
+				return nil
+			},
+		})
+	case procedure.DropPartitionTable:
+		if def.DropPartitionTable == nil || def.DropPartitionTable.Request == nil || def.DropPartitionTable.Request.PartitionTableInfo == nil {
+			return nil, ErrProcedureNotReplayable.WithCausef("drop partition table definition is missing")
+		}
+		return f.createDropTableProcedureFromRequest(ctx, clusterMetadata, def.DropPartitionTable.Request, header)
+	case procedure.Create, procedure.Delete, procedure.TransferLeader, procedure.Migrate, procedure.Merge, procedure.Scatter:
+		return nil, ErrProcedureNotReplayable.WithCausef("inputs of the procedure are derived from the cluster state and not persisted, kind:%d", def.Kind)
+	default:
+		return nil, ErrProcedureNotReplayable.WithCausef("procedure kind is not supported, kind:%d", def.Kind)
+	}
+}
+
+// createDropTableProcedureFromRequest creates the procedure to drop the normal or partition table described by the replayed request.
+func (f *Factory) createDropTableProcedureFromRequest(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, req *metaservicepb.DropTableRequest, header *metaservicepb.RequestHeader) (procedure.Procedure, error) {
+	req.Header = header
+	p, ok, err := f.CreateDropTableProcedure(ctx, DropTableRequest{
+		ClusterMetadata: clusterMetadata,
+		ClusterSnapshot: clusterMetadata.GetClusterSnapshot(),
+		SourceReq:       req,
+		OnSucceeded: func(ret metadata.TableInfo) error {
+			f.logger.Info("replayed drop table procedure succeeded", zap.String("tableName", ret.Name))
+			return nil
+		},
+		OnFailed: func(err error) error {
+			f.logger.Warn("replayed drop table procedure failed", zap.String("tableName", req.GetName()), zap.Error(err))
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, procedure.ErrTableNotExists.WithCausef("table to drop is not found, tableName:%s", req.GetName())
+	}
+	return p, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayCreatePartitionTable(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	rawData, err := json.Marshal(map[string]any{
+		"ID":       1,
+		"FsmState": "begin",
+		"SourceReq": &metaservicepb.CreateTableRequest{
+			Header:           &metaservicepb.RequestHeader{Node: "127.0.0.1:8831", ClusterName: "prod"},
+			SchemaName:       test.TestSchemaName,
+			Name:             "test3",
+			EncodedSchema:    nil,
+			Engine:           "",
+			CreateIfNotExist: false,
+			Options:          map[string]string{"ttl": "7d", "s3_secret_key": "foo"},
+			PartitionTableInfo: &metaservicepb.PartitionTableInfo{
+				PartitionInfo: nil,
+				SubTableNames: []string{"test3-0", "test3-1"},
+			},
+		},
+	})
+	re.NoError(err)
+
+	def, err := coordinator.ExportProcedureDefinition(&procedure.Meta{
+		ID:        1,
+		Kind:      procedure.CreatePartitionTable,
+		State:     procedure.StateRunning,
+		RawData:   rawData,
+		UpdatedAt: 0,
+		Initiator: "operator",
+	})
+	re.NoError(err)
+	re.Equal(uint64(1), def.SourceID)
+	re.Equal("operator", def.Initiator)
+	re.NotNil(def.CreatePartitionTable)
+	req := def.CreatePartitionTable.Request
+	re.Nil(req.Header)
+	re.Equal("7d", req.Options["ttl"])
+	re.Equal(coordinator.RedactedValue, req.Options["s3_secret_key"])
+
+	// The definition should survive the json round trip.
+	encoded, err := json.Marshal(def)
+	re.NoError(err)
+	var decoded coordinator.ProcedureDefinition
+	re.NoError(json.Unmarshal(encoded, &decoded))
+
+	p, err := f.CreateProcedureFromDefinition(ctx, m, decoded)
+	re.NoError(err)
+	re.Equal(procedure.CreatePartitionTable, p.Kind())
+	re.Equal(procedure.StateInit, string(p.State()))
+	re.NotContains(decoded.CreatePartitionTable.Request.Options, "s3_secret_key")
+	re.Equal(m.Name(), decoded.CreatePartitionTable.Request.Header.ClusterName)
+}
+
+func TestReplayCreateTable(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+
+	rawData, err := json.Marshal(map[string]any{
+		"ID":       5,
+		"FsmState": "begin",
+		"ShardID":  0,
+		"SourceReq": &metaservicepb.CreateTableRequest{
+			Header:             &metaservicepb.RequestHeader{Node: "127.0.0.1:8831", ClusterName: "prod"},
+			SchemaName:         test.TestSchemaName,
+			Name:               "test4",
+			EncodedSchema:      nil,
+			Engine:             "",
+			CreateIfNotExist:   false,
+			Options:            map[string]string{"access_key_id": "foo"},
+			PartitionTableInfo: nil,
+		},
+	})
+	re.NoError(err)
+
+	def, err := coordinator.ExportProcedureDefinition(&procedure.Meta{
+		ID:        5,
+		Kind:      procedure.CreateTable,
+		State:     procedure.StateFailed,
+		RawData:   rawData,
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.NoError(err)
+	re.NotNil(def.CreateTable)
+	re.Nil(def.CreateTable.Request.Header)
+	re.Equal(coordinator.RedactedValue, def.CreateTable.Request.Options["access_key_id"])
+
+	p, err := f.CreateProcedureFromDefinition(ctx, m, def)
+	re.NoError(err)
+	re.Equal(procedure.CreateTable, p.Kind())
+	re.Equal(m.Name(), def.CreateTable.Request.Header.ClusterName)
+}
+
+func TestReplaySplit(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+	snapshot := m.GetClusterSnapshot()
+	shardNode := snapshot.Topology.ClusterView.ShardNodes[0]
+
+	rawData, err := json.Marshal(map[string]any{
+		"SchemaName":     test.TestSchemaName,
+		"TableNames":     []string{"test1"},
+		"ShardID":        shardNode.ID,
+		"NewShardID":     100,
+		"TargetNodeName": shardNode.NodeName,
+	})
+	re.NoError(err)
+
+	def, err := coordinator.ExportProcedureDefinition(&procedure.Meta{
+		ID:        2,
+		Kind:      procedure.Split,
+		State:     procedure.StateFinished,
+		RawData:   rawData,
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.NoError(err)
+	re.Equal(uint32(shardNode.ID), def.Split.ShardID)
+	re.Equal([]string{"test1"}, def.Split.TableNames)
+	re.Equal(shardNode.NodeName, def.Split.TargetNodeName)
+
+	p, err := f.CreateProcedureFromDefinition(ctx, m, def)
+	re.NoError(err)
+	re.Equal(procedure.Split, p.Kind())
+}
+
+func TestExportUnsupportedProcedure(t *testing.T) {
+	re := require.New(t)
+
+	_, err := coordinator.ExportProcedureDefinition(&procedure.Meta{
+		ID:        3,
+		Kind:      procedure.TransferLeader,
+		State:     procedure.StateRunning,
+		RawData:   nil,
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.True(coderr.Is(err, coordinator.ErrProcedureNotReplayable.Code()))
+
+	// The create partition table procedure persisted by the old version has no source request.
+	_, err = coordinator.ExportProcedureDefinition(&procedure.Meta{
+		ID:        4,
+		Kind:      procedure.CreatePartitionTable,
+		State:     procedure.StateRunning,
+		RawData:   []byte(`{"ID":4}`),
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.True(coderr.Is(err, coordinator.ErrProcedureNotReplayable.Code()))
+}
@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/createtable"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/droptable"
+	"go.uber.org/zap"
+)
+
+// ResumePolicy describes how the unfinished procedure checkpointed by the last leader is handled after the meta server restarts.
+type ResumePolicy int
+
+const (
+	// ResumeFromCheckpoint resumes the procedure from its last committed fsm state.
+	ResumeFromCheckpoint ResumePolicy = iota
+	// ResumeAbandon marks the procedure as failed, and the replayable ones can be replayed manually by the exported definitions.
+	ResumeAbandon
+)
+
+// ResumePolicyOf returns the resume policy of the procedure kind.
+// The create table procedure resumes from the last committed state, and every step of it is idempotent. The drop table procedure is started over if the
+// table has not been dropped, otherwise it is considered finished. The other procedures touch multiple shards, and resuming them without the callers is
+// unsafe, so they are abandoned.
+func ResumePolicyOf(kind procedure.Kind) ResumePolicy {
+	switch kind {
+	case procedure.CreateTable, procedure.DropTable:
+		return ResumeFromCheckpoint
+	default:
+		return ResumeAbandon
+	}
+}
+
+// ResumeProcedure reconstructs the unfinished procedure from its checkpointed meta, and ErrProcedureNotResumable is returned if it should be abandoned.
+// The returned boolean value is false if there is nothing left to do, e.g. the table to drop has been dropped already.
+// The results of the resumed procedures are only logged since the callers have gone.
+func (f *Factory) ResumeProcedure(ctx context.Context, clusterMetadata *metadata.ClusterMetadata, meta *procedure.Meta) (procedure.Procedure, bool, error) {
+	if ResumePolicyOf(meta.Kind) == ResumeAbandon {
+		return nil, false, ErrProcedureNotResumable.WithCausef("procedure kind is not resumable, procedureID:%d, kind:%d", meta.ID, meta.Kind)
+	}
+
+	onFailed := func(err error) error {
+		f.logger.Warn("resumed procedure failed", zap.Uint64("procedureID", meta.ID), zap.Error(err))
+		return nil
+	}
+
+	switch meta.Kind {
+	case procedure.CreateTable:
+		var rawData createtable.RawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return nil, false, procedure.ErrDecodeRawData.WithCausef("decode create table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		if rawData.SourceReq == nil {
+			return nil, false, ErrProcedureNotResumable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		p, err := createtable.NewProcedure(createtable.ProcedureParams{
+			Dispatch:        f.dispatch,
+			ClusterMetadata: clusterMetadata,
+			ClusterSnapshot: clusterMetadata.GetClusterSnapshot(),
+			ID:              meta.ID,
+			ShardID:         rawData.ShardID,
+			SourceReq:       rawData.SourceReq,
+			OnSucceeded: func(ret metadata.CreateTableResult) error {
+				f.logger.Info("resumed create table procedure succeeded", zap.Uint64("procedureID", meta.ID), zap.String("tableName", ret.Table.Name))
+				return nil
+			},
+			OnFailed:     onFailed,
+			Checkpointer: f.checkpointer,
+			ResumeState:  rawData.FsmState,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		return p, true, nil
+	case procedure.DropTable:
+		var rawData droptable.RawData
+		if err := json.Unmarshal(meta.RawData, &rawData); err != nil {
+			return nil, false, procedure.ErrDecodeRawData.WithCausef("decode drop table raw data, procedureID:%d, err:%v", meta.ID, err)
+		}
+		if rawData.SourceReq == nil {
+			return nil, false, ErrProcedureNotResumable.WithCausef("source request is not persisted, procedureID:%d", meta.ID)
+		}
+		return droptable.NewDropTableProcedure(droptable.ProcedureParams{
+			ID:              meta.ID,
+			Dispatch:        f.dispatch,
+			ClusterMetadata: clusterMetadata,
+			ClusterSnapshot: clusterMetadata.GetClusterSnapshot(),
+			SourceReq:       rawData.SourceReq,
+			OnSucceeded: func(table metadata.TableInfo) error {
+				f.logger.Info("resumed drop table procedure succeeded", zap.Uint64("procedureID", meta.ID), zap.String("tableName", table.Name))
+				return nil
+			},
+			OnFailed:     onFailed,
+			Checkpointer: f.checkpointer,
+		})
+	default:
+		return nil, false, ErrProcedureNotResumable.WithCausef("procedure kind is not supported, procedureID:%d, kind:%d", meta.ID, meta.Kind)
+	}
+}
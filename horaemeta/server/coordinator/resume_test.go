@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package coordinator_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeProcedure(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+	shardNode := m.GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
+
+	re.Equal(coordinator.ResumeFromCheckpoint, coordinator.ResumePolicyOf(procedure.CreateTable))
+	re.Equal(coordinator.ResumeFromCheckpoint, coordinator.ResumePolicyOf(procedure.DropTable))
+	re.Equal(coordinator.ResumeAbandon, coordinator.ResumePolicyOf(procedure.Split))
+
+	// The split procedure is abandoned.
+	_, _, err := f.ResumeProcedure(ctx, m, &procedure.Meta{
+		ID:        1,
+		Kind:      procedure.Split,
+		State:     procedure.StateRunning,
+		RawData:   []byte(`{}`),
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.True(coderr.Is(err, coordinator.ErrProcedureNotResumable.Code()))
+
+	// The create table procedure is resumed from the checkpointed fsm state.
+	rawData, err := json.Marshal(map[string]any{
+		"ID":       2,
+		"FsmState": "StateCheckTableExists",
+		"State":    procedure.StateRunning,
+		"ShardID":  shardNode.ID,
+		"SourceReq": &metaservicepb.CreateTableRequest{
+			Header:             &metaservicepb.RequestHeader{Node: shardNode.NodeName, ClusterName: test.ClusterName},
+			SchemaName:         test.TestSchemaName,
+			Name:               "test1",
+			EncodedSchema:      nil,
+			Engine:             "",
+			CreateIfNotExist:   false,
+			Options:            nil,
+			PartitionTableInfo: nil,
+		},
+	})
+	re.NoError(err)
+	p, needed, err := f.ResumeProcedure(ctx, m, &procedure.Meta{
+		ID:        2,
+		Kind:      procedure.CreateTable,
+		State:     procedure.StateRunning,
+		RawData:   rawData,
+		UpdatedAt: 0,
+		Initiator: "operator",
+	})
+	re.NoError(err)
+	re.True(needed)
+	re.Equal(uint64(2), p.ID())
+	re.Equal(procedure.CreateTable, p.Kind())
+	re.NoError(p.Start(ctx))
+	_, exists, err := m.GetTable(test.TestSchemaName, "test1")
+	re.NoError(err)
+	re.True(exists)
+
+	// The drop table procedure has nothing to do if the table has been dropped.
+	rawData, err = json.Marshal(map[string]any{
+		"ID":       3,
+		"FsmState": "StateWaiting",
+		"State":    procedure.StateRunning,
+		"SourceReq": &metaservicepb.DropTableRequest{
+			Header:             &metaservicepb.RequestHeader{Node: shardNode.NodeName, ClusterName: test.ClusterName},
+			SchemaName:         test.TestSchemaName,
+			Name:               "notExists",
+			PartitionTableInfo: nil,
+		},
+	})
+	re.NoError(err)
+	_, needed, err = f.ResumeProcedure(ctx, m, &procedure.Meta{
+		ID:        3,
+		Kind:      procedure.DropTable,
+		State:     procedure.StateRunning,
+		RawData:   rawData,
+		UpdatedAt: 0,
+		Initiator: "",
+	})
+	re.NoError(err)
+	re.False(needed)
+}
@@ -0,0 +1,147 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+type AffinityProblemKind string
+
+const (
+	// AffinityProblemShardNotFound means the shard of the affinity doesn't exist in the cluster.
+	AffinityProblemShardNotFound AffinityProblemKind = "shardNotFound"
+	// AffinityProblemConflictingRules means the same shard is given different affinities.
+	AffinityProblemConflictingRules AffinityProblemKind = "conflictingRules"
+	// AffinityProblemNoAliveNodes means there is no node to host the shards.
+	AffinityProblemNoAliveNodes AffinityProblemKind = "noAliveNodes"
+	// AffinityProblemInsufficientCapacity means the nodes can't host all the shards while keeping the affinities.
+	AffinityProblemInsufficientCapacity AffinityProblemKind = "insufficientCapacity"
+)
+
+// AffinityProblem describes why a set of shard affinities can't be satisfied by the cluster.
+type AffinityProblem struct {
+	Kind     AffinityProblemKind `json:"kind"`
+	ShardIDs []storage.ShardID   `json:"shardIDs"`
+	Reason   string              `json:"reason"`
+}
+
+// ValidateShardAffinities checks whether the proposed affinities together with the existing ones can be satisfied by the
+// cluster described by the snapshot, and the proposed affinity overrides the existing one of the same shard just like
+// what happens when it is applied.
+//
+// Every node hosting a shard with affinity can host NumAllowedOtherShards+1 shards at most, so the rules are unsatisfiable
+// if all the alive nodes are occupied by such shards and the sum of their capacities is less than the number of shards.
+func ValidateShardAffinities(snapshot metadata.Snapshot, existing, proposed []ShardAffinity, now time.Time) []AffinityProblem {
+	problems := make([]AffinityProblem, 0)
+
+	proposedAffinities := make(map[storage.ShardID]ShardAffinity, len(proposed))
+	conflictingShards := make(map[storage.ShardID]struct{})
+	for _, affinity := range proposed {
+		if _, exists := snapshot.Topology.ShardViewsMapping[affinity.ShardID]; !exists {
+			problems = append(problems, AffinityProblem{
+				Kind:     AffinityProblemShardNotFound,
+				ShardIDs: []storage.ShardID{affinity.ShardID},
+				Reason:   fmt.Sprintf("shard %d doesn't exist in the cluster", affinity.ShardID),
+			})
+			continue
+		}
+		if prev, exists := proposedAffinities[affinity.ShardID]; exists && prev.NumAllowedOtherShards != affinity.NumAllowedOtherShards {
+			if _, reported := conflictingShards[affinity.ShardID]; !reported {
+				conflictingShards[affinity.ShardID] = struct{}{}
+				problems = append(problems, AffinityProblem{
+					Kind:     AffinityProblemConflictingRules,
+					ShardIDs: []storage.ShardID{affinity.ShardID},
+					Reason:   fmt.Sprintf("shard %d is given different numAllowedOtherShards, %d and %d", affinity.ShardID, prev.NumAllowedOtherShards, affinity.NumAllowedOtherShards),
+				})
+			}
+			continue
+		}
+		proposedAffinities[affinity.ShardID] = affinity
+	}
+
+	affinities := make(map[storage.ShardID]ShardAffinity, len(existing)+len(proposedAffinities))
+	for _, affinity := range existing {
+		affinities[affinity.ShardID] = affinity
+	}
+	for shardID, affinity := range proposedAffinities {
+		affinities[shardID] = affinity
+	}
+	if len(affinities) == 0 {
+		return problems
+	}
+
+	shardIDs := make([]storage.ShardID, 0, len(affinities))
+	for shardID := range affinities {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Slice(shardIDs, func(i, j int) bool {
+		return shardIDs[i] < shardIDs[j]
+	})
+
+	numAliveNodes := 0
+	for _, node := range snapshot.RegisteredNodes {
+		if !node.IsExpired(now) && !node.IsShuttingDown() {
+			numAliveNodes++
+		}
+	}
+	if numAliveNodes == 0 {
+		problems = append(problems, AffinityProblem{
+			Kind:     AffinityProblemNoAliveNodes,
+			ShardIDs: shardIDs,
+			Reason:   "no alive node to host the shards",
+		})
+		return problems
+	}
+
+	// Some nodes are free of the affinities, and they can host all the other shards.
+	if len(affinities) < numAliveNodes {
+		return problems
+	}
+
+	// All the nodes are occupied by the shards with affinity, and the capacity is maximized when the shards with the
+	// largest capacities are spread over the nodes.
+	capacities := make([]uint64, 0, len(affinities))
+	for _, affinity := range affinities {
+		capacities = append(capacities, uint64(affinity.NumAllowedOtherShards)+1)
+	}
+	sort.Slice(capacities, func(i, j int) bool {
+		return capacities[i] > capacities[j]
+	})
+	var maxCapacity uint64
+	for _, capacity := range capacities[:numAliveNodes] {
+		maxCapacity += capacity
+	}
+	numShards := uint64(len(snapshot.Topology.ShardViewsMapping))
+	if maxCapacity < numShards {
+		problems = append(problems, AffinityProblem{
+			Kind:     AffinityProblemInsufficientCapacity,
+			ShardIDs: shardIDs,
+			Reason:   fmt.Sprintf("%d alive nodes can host %d shards at most under the affinities, but there are %d shards", numAliveNodes, maxCapacity, numShards),
+		})
+	}
+
+	return problems
+}
@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateShardAffinities(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	// The cluster contains 2 nodes and 4 shards.
+	c := test.InitStableCluster(ctx, t)
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	now := time.Now()
+
+	kinds := func(problems []scheduler.AffinityProblem) []scheduler.AffinityProblemKind {
+		res := make([]scheduler.AffinityProblemKind, 0, len(problems))
+		for _, problem := range problems {
+			res = append(res, problem.Kind)
+		}
+		return res
+	}
+
+	// A free node is left to host the other shards.
+	problems := scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 0}}, now)
+	re.Empty(problems)
+
+	problems = scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{{ShardID: 99, NumAllowedOtherShards: 0}}, now)
+	re.Equal([]scheduler.AffinityProblemKind{scheduler.AffinityProblemShardNotFound}, kinds(problems))
+	re.Equal([]storage.ShardID{99}, problems[0].ShardIDs)
+
+	problems = scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{
+		{ShardID: 0, NumAllowedOtherShards: 1},
+		{ShardID: 0, NumAllowedOtherShards: 2},
+		{ShardID: 0, NumAllowedOtherShards: 3},
+	}, now)
+	re.Equal([]scheduler.AffinityProblemKind{scheduler.AffinityProblemConflictingRules}, kinds(problems))
+
+	// Both nodes are occupied, and each of them can host only one shard.
+	problems = scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{
+		{ShardID: 0, NumAllowedOtherShards: 0},
+		{ShardID: 1, NumAllowedOtherShards: 0},
+	}, now)
+	re.Equal([]scheduler.AffinityProblemKind{scheduler.AffinityProblemInsufficientCapacity}, kinds(problems))
+	re.Equal([]storage.ShardID{0, 1}, problems[0].ShardIDs)
+
+	problems = scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{
+		{ShardID: 0, NumAllowedOtherShards: 0},
+		{ShardID: 1, NumAllowedOtherShards: 2},
+	}, now)
+	re.Empty(problems)
+
+	// The existing affinities are taken into account.
+	existing := []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 0}}
+	problems = scheduler.ValidateShardAffinities(snapshot, existing, []scheduler.ShardAffinity{{ShardID: 1, NumAllowedOtherShards: 0}}, now)
+	re.Equal([]scheduler.AffinityProblemKind{scheduler.AffinityProblemInsufficientCapacity}, kinds(problems))
+
+	// The proposed affinity overrides the existing one.
+	problems = scheduler.ValidateShardAffinities(snapshot, existing, []scheduler.ShardAffinity{
+		{ShardID: 0, NumAllowedOtherShards: 3},
+		{ShardID: 1, NumAllowedOtherShards: 0},
+	}, now)
+	re.Empty(problems)
+
+	// All the nodes are expired.
+	problems = scheduler.ValidateShardAffinities(snapshot, nil, []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 0}}, now.Add(time.Hour))
+	re.Equal([]scheduler.AffinityProblemKind{scheduler.AffinityProblemNoAliveNodes}, kinds(problems))
+}
@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// ShardAffinityFilter selects the shard affinity rules to remove, and exactly one of the criteria must be set.
+type ShardAffinityFilter struct {
+	// All selects all the rules.
+	All bool `json:"all"`
+	// NodeName selects the rules of the shards assigned to the node in the cluster view.
+	NodeName string `json:"nodeName"`
+	// UnknownShards selects the rules of the shards which don't exist in the cluster any more.
+	UnknownShards bool `json:"unknownShards"`
+	// OfflineNodes selects the rules of the shards whose assigned nodes are all unregistered or expired, including the
+	// shards not assigned to any node. It is refused until the registered nodes are fully reported, e.g. right after the
+	// leader of HoraeMeta changes.
+	OfflineNodes bool `json:"offlineNodes"`
+}
+
+// Validate checks that exactly one criterion of the filter is set.
+func (f ShardAffinityFilter) Validate() error {
+	numCriteria := 0
+	for _, set := range []bool{f.All, len(f.NodeName) > 0, f.UnknownShards, f.OfflineNodes} {
+		if set {
+			numCriteria++
+		}
+	}
+	if numCriteria != 1 {
+		return ErrInvalidAffinityFilter.WithCausef("exactly one of all, nodeName, unknownShards and offlineNodes must be set, filter:%+v", f)
+	}
+	return nil
+}
+
+// newShardMatcher builds the matcher of the shards selected by the filter against the snapshot.
+func (f ShardAffinityFilter) newShardMatcher(snapshot metadata.Snapshot, now time.Time) func(storage.ShardID) bool {
+	switch {
+	case f.All:
+		return func(_ storage.ShardID) bool { return true }
+	case len(f.NodeName) > 0:
+		onNode := make(map[storage.ShardID]struct{})
+		for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+			if shardNode.NodeName == f.NodeName {
+				onNode[shardNode.ID] = struct{}{}
+			}
+		}
+		return func(shardID storage.ShardID) bool {
+			_, ok := onNode[shardID]
+			return ok
+		}
+	case f.UnknownShards:
+		return func(shardID storage.ShardID) bool {
+			_, ok := snapshot.Topology.ShardViewsMapping[shardID]
+			return !ok
+		}
+	default:
+		onlineNodes := make(map[string]struct{}, len(snapshot.RegisteredNodes))
+		for _, node := range snapshot.RegisteredNodes {
+			if !node.IsExpired(now) {
+				onlineNodes[node.Node.Name] = struct{}{}
+			}
+		}
+		online := make(map[storage.ShardID]struct{})
+		for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+			if _, ok := onlineNodes[shardNode.NodeName]; ok {
+				online[shardNode.ID] = struct{}{}
+			}
+		}
+		return func(shardID storage.ShardID) bool {
+			_, ok := online[shardID]
+			return !ok
+		}
+	}
+}
+
+// RemoveShardAffinitiesResult describes the shard affinity rules removed by a filter.
+type RemoveShardAffinitiesResult struct {
+	// Removed is the shards whose rules are removed from all the schedulers, ordered by the shard id.
+	Removed []storage.ShardID `json:"removed"`
+	// Failed is the rules failing to be removed from some schedulers.
+	Failed []ShardAffinityRemoveFailure `json:"failed"`
+}
+
+type ShardAffinityRemoveFailure struct {
+	ShardID   storage.ShardID `json:"shardID"`
+	Scheduler string          `json:"scheduler"`
+	Reason    string          `json:"reason"`
+}
+
+func (m *schedulerManagerImpl) RemoveShardAffinityRulesByFilter(ctx context.Context, filter ShardAffinityFilter) (RemoveShardAffinitiesResult, error) {
+	result := RemoveShardAffinitiesResult{Removed: []storage.ShardID{}, Failed: []ShardAffinityRemoveFailure{}}
+	if err := filter.Validate(); err != nil {
+		return result, err
+	}
+
+	m.affinityLock.Lock()
+	defer m.affinityLock.Unlock()
+
+	m.lock.RLock()
+	schedulers := m.registerSchedulers
+	m.lock.RUnlock()
+
+	snapshot, now := m.clusterMetadata.GetClusterSnapshot(), time.Now()
+	// The nodes not registered yet would be regarded as offline, and the rules of their shards would be removed by mistake.
+	if filter.OfflineNodes && !snapshot.NodesReported(now) {
+		return result, ErrNodesNotReported.WithCausef("loadedAt:%s, registeredNodes:%d", snapshot.LoadedAt, len(snapshot.RegisteredNodes))
+	}
+	match := filter.newShardMatcher(snapshot, now)
+	removed := make(map[storage.ShardID]struct{})
+	failed := make(map[storage.ShardID]struct{})
+	for _, s := range schedulers {
+		rule, err := s.ListShardAffinityRule(ctx)
+		if err != nil {
+			// The scheduler doesn't support the shard affinity and holds no rule at all.
+			if coderr.Is(err, coderr.ErrNotImplemented) {
+				continue
+			}
+			return result, err
+		}
+
+		for _, affinity := range rule.Affinities {
+			if !match(affinity.ShardID) {
+				continue
+			}
+			log.Info("try to remove shard affinity rule by filter", zap.String("scheduler", s.Name()), zap.Uint32("shardID", uint32(affinity.ShardID)))
+			if err := s.RemoveShardAffinityRule(ctx, affinity.ShardID); err != nil {
+				log.Error("failed to remove shard affinity rule of a scheduler", zap.String("scheduler", s.Name()), zap.Uint32("shardID", uint32(affinity.ShardID)), zap.Error(err))
+				failed[affinity.ShardID] = struct{}{}
+				result.Failed = append(result.Failed, ShardAffinityRemoveFailure{
+					ShardID:   affinity.ShardID,
+					Scheduler: s.Name(),
+					Reason:    err.Error(),
+				})
+				continue
+			}
+			removed[affinity.ShardID] = struct{}{}
+		}
+	}
+
+	for shardID := range removed {
+		if _, ok := failed[shardID]; !ok {
+			result.Removed = append(result.Removed, shardID)
+		}
+	}
+	slices.Sort(result.Removed)
+	return result, nil
+}
@@ -21,4 +21,10 @@ package manager
 
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
-var ErrInvalidTopologyType = coderr.NewCodeError(coderr.InvalidParams, "invalid topology type")
+var (
+	ErrInvalidTopologyType    = coderr.NewCodeError(coderr.InvalidParams, "invalid topology type")
+	ErrEnableScheduleConflict = coderr.NewCodeError(coderr.Conflict, "current enableSchedule mismatches the expected one")
+	ErrInvalidRebalanceShards = coderr.NewCodeError(coderr.InvalidParams, "invalid shards to rebalance")
+	ErrInvalidAffinityFilter  = coderr.NewCodeError(coderr.InvalidParams, "invalid shard affinity filter")
+	ErrNodesNotReported       = coderr.NewCodeError(coderr.BadRequest, "registered nodes are not fully reported")
+)
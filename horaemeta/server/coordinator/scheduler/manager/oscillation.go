@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// OscillatingShard describes a shard whose leader moves too frequently, and its further moves are suppressed.
+type OscillatingShard struct {
+	ShardID storage.ShardID `json:"shardID"`
+	// Moves is the number of the leader moves of the shard in the window.
+	Moves int `json:"moves"`
+	// LeaderNodes is the recent leader nodes of the shard in the order of the moves.
+	LeaderNodes []string `json:"leaderNodes"`
+	// SuppressedAt is the unix milliseconds when the further moves of the shard start to be suppressed.
+	SuppressedAt int64 `json:"suppressedAt"`
+}
+
+type shardMove struct {
+	at       time.Time
+	nodeName string
+}
+
+// shardOscillationDetector tracks the recent leader moves of the shards observed in the cluster snapshots, and detects the shards
+// moving more frequently than the threshold, e.g. the schedulers disagree with each other or the affinity rules conflict.
+type shardOscillationDetector struct {
+	lock      sync.Mutex
+	threshold metadata.ShardOscillationThreshold
+	// lastLeaders is the last observed leader node of the shards, shardID -> nodeName.
+	lastLeaders map[storage.ShardID]string
+	// moves is the leader moves of the shards in the window.
+	moves map[storage.ShardID][]shardMove
+	// oscillatingShards is the shards whose further moves are suppressed, shardID -> the time when the suppression starts.
+	oscillatingShards map[storage.ShardID]time.Time
+}
+
+func newShardOscillationDetector(threshold metadata.ShardOscillationThreshold) *shardOscillationDetector {
+	return &shardOscillationDetector{
+		lock:              sync.Mutex{},
+		threshold:         threshold,
+		lastLeaders:       map[storage.ShardID]string{},
+		moves:             map[storage.ShardID][]shardMove{},
+		oscillatingShards: map[storage.ShardID]time.Time{},
+	}
+}
+
+func (d *shardOscillationDetector) updateThreshold(threshold metadata.ShardOscillationThreshold) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.threshold = threshold
+}
+
+// observe records the leader moves of the shards since the last observation, and returns the shards starting and stopping
+// oscillating respectively.
+// The shard becoming unassigned is not considered as a move, and it moves when it is assigned to another node later.
+func (d *shardOscillationDetector) observe(shardNodes []storage.ShardNode, now time.Time) ([]storage.ShardID, []storage.ShardID) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, shardNode := range shardNodes {
+		if shardNode.ShardRole != storage.ShardRoleLeader || len(shardNode.NodeName) == 0 {
+			continue
+		}
+		lastLeader, ok := d.lastLeaders[shardNode.ID]
+		d.lastLeaders[shardNode.ID] = shardNode.NodeName
+		if ok && lastLeader != shardNode.NodeName {
+			d.moves[shardNode.ID] = append(d.moves[shardNode.ID], shardMove{at: now, nodeName: shardNode.NodeName})
+		}
+	}
+
+	var started, stopped []storage.ShardID
+	for shardID, moves := range d.moves {
+		moves = d.expireMoves(moves, now)
+		if len(moves) == 0 {
+			delete(d.moves, shardID)
+		} else {
+			d.moves[shardID] = moves
+		}
+
+		_, oscillating := d.oscillatingShards[shardID]
+		exceeded := d.threshold.MaxMoves > 0 && len(moves) > int(d.threshold.MaxMoves)
+		if exceeded && !oscillating {
+			d.oscillatingShards[shardID] = now
+			started = append(started, shardID)
+		}
+		if !exceeded && oscillating {
+			delete(d.oscillatingShards, shardID)
+			stopped = append(stopped, shardID)
+		}
+	}
+	// The shards without moves in the window are not oscillating any more.
+	for shardID := range d.oscillatingShards {
+		if _, ok := d.moves[shardID]; !ok {
+			delete(d.oscillatingShards, shardID)
+			stopped = append(stopped, shardID)
+		}
+	}
+
+	sort.Slice(started, func(i, j int) bool { return started[i] < started[j] })
+	sort.Slice(stopped, func(i, j int) bool { return stopped[i] < stopped[j] })
+	return started, stopped
+}
+
+func (d *shardOscillationDetector) expireMoves(moves []shardMove, now time.Time) []shardMove {
+	deadline := now.Add(-d.threshold.Window)
+	idx := 0
+	for idx < len(moves) && moves[idx].at.Before(deadline) {
+		idx++
+	}
+	return moves[idx:]
+}
+
+// list returns the oscillating shards sorted by the shard id.
+func (d *shardOscillationDetector) list() []OscillatingShard {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ret := make([]OscillatingShard, 0, len(d.oscillatingShards))
+	for shardID, suppressedAt := range d.oscillatingShards {
+		moves := d.moves[shardID]
+		leaderNodes := make([]string, 0, len(moves))
+		for _, move := range moves {
+			leaderNodes = append(leaderNodes, move.nodeName)
+		}
+		ret = append(ret, OscillatingShard{
+			ShardID:      shardID,
+			Moves:        len(moves),
+			LeaderNodes:  leaderNodes,
+			SuppressedAt: suppressedAt.UnixMilli(),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ShardID < ret[j].ShardID })
+	return ret
+}
+
+// suppress returns a copy of the snapshot, in which the oscillating shards are marked, so that the schedulers stop moving their leaders
+// between the alive nodes. Unlike the shards under maintenance, they are still recovered by the schedulers.
+func (d *shardOscillationDetector) suppress(snapshot metadata.Snapshot) metadata.Snapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(d.oscillatingShards) == 0 {
+		return snapshot
+	}
+
+	oscillatingShards := make(map[storage.ShardID]struct{}, len(d.oscillatingShards))
+	for shardID := range d.oscillatingShards {
+		oscillatingShards[shardID] = struct{}{}
+	}
+	snapshot.OscillatingShards = oscillatingShards
+	return snapshot
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func leaderShardNodes(nodeNames ...string) []storage.ShardNode {
+	shardNodes := make([]storage.ShardNode, 0, len(nodeNames))
+	for i, nodeName := range nodeNames {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        storage.ShardID(i),
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  nodeName,
+		})
+	}
+	return shardNodes
+}
+
+func TestShardOscillationDetector(t *testing.T) {
+	re := require.New(t)
+
+	d := newShardOscillationDetector(metadata.ShardOscillationThreshold{MaxMoves: 2, Window: time.Minute})
+	now := time.Now()
+
+	// Shard 0 bounces between node0 and node1, and shard 1 stays on node1.
+	started, stopped := d.observe(leaderShardNodes("node0", "node1"), now)
+	re.Empty(started)
+	re.Empty(stopped)
+	started, _ = d.observe(leaderShardNodes("node1", "node1"), now.Add(time.Second))
+	re.Empty(started)
+	// The shard becoming unassigned is not a move.
+	started, _ = d.observe(leaderShardNodes("", "node1"), now.Add(2*time.Second))
+	re.Empty(started)
+	started, _ = d.observe(leaderShardNodes("node0", "node1"), now.Add(3*time.Second))
+	re.Empty(started)
+	started, stopped = d.observe(leaderShardNodes("node1", "node1"), now.Add(4*time.Second))
+	re.Equal([]storage.ShardID{0}, started)
+	re.Empty(stopped)
+
+	oscillatingShards := d.list()
+	re.Len(oscillatingShards, 1)
+	re.Equal(storage.ShardID(0), oscillatingShards[0].ShardID)
+	re.Equal(3, oscillatingShards[0].Moves)
+	re.Equal([]string{"node1", "node0", "node1"}, oscillatingShards[0].LeaderNodes)
+	re.Equal(now.Add(4*time.Second).UnixMilli(), oscillatingShards[0].SuppressedAt)
+
+	// The moves of the oscillating shard are suppressed, but it is not put under maintenance.
+	snapshot := metadata.Snapshot{MaintenanceShards: map[storage.ShardID]string{1: "manual"}}
+	suppressed := d.suppress(snapshot)
+	re.True(suppressed.IsShardMoveSuppressed(0))
+	re.False(suppressed.IsShardUnderMaintenance(0))
+	re.False(suppressed.IsShardMoveSuppressed(1))
+	re.Equal(snapshot.MaintenanceShards, suppressed.MaintenanceShards)
+	re.False(snapshot.IsShardMoveSuppressed(0))
+
+	// The shard stops oscillating once the moves expire from the window.
+	started, stopped = d.observe(leaderShardNodes("node1", "node1"), now.Add(2*time.Minute))
+	re.Empty(started)
+	re.Equal([]storage.ShardID{0}, stopped)
+	re.Empty(d.list())
+	re.Equal(snapshot, d.suppress(snapshot))
+}
+
+func TestShardOscillationDetectorDisabled(t *testing.T) {
+	re := require.New(t)
+
+	d := newShardOscillationDetector(metadata.ShardOscillationThreshold{MaxMoves: 0, Window: time.Minute})
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		nodeName := "node0"
+		if i%2 == 1 {
+			nodeName = "node1"
+		}
+		started, _ := d.observe(leaderShardNodes(nodeName), now.Add(time.Duration(i)*time.Second))
+		re.Empty(started)
+	}
+	re.Empty(d.list())
+}
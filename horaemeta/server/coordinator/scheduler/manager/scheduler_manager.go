@@ -23,6 +23,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -45,6 +47,8 @@ import (
 
 const (
 	schedulerInterval = time.Second * 5
+	// DefaultSchedulerConcurrency is used when the configured scheduler concurrency is not positive.
+	DefaultSchedulerConcurrency = 4
 )
 
 // SchedulerManager used to manage schedulers, it will register all schedulers when it starts.
@@ -53,6 +57,9 @@ const (
 type SchedulerManager interface {
 	ListScheduler() []scheduler.Scheduler
 
+	// DescribeSchedulers describes the registered schedulers and the configuration they are created with.
+	DescribeSchedulers() SchedulerRegistry
+
 	Start(ctx context.Context) error
 
 	Stop(ctx context.Context) error
@@ -61,6 +68,10 @@ type SchedulerManager interface {
 	// when enableSchedule is true, shard topology will not be updated, it is usually used in scenarios such as cluster deploy.
 	UpdateEnableSchedule(ctx context.Context, enable bool) error
 
+	// CompareAndSwapEnableSchedule updates enableSchedule only if the current value equals to the expected one, otherwise
+	// ErrEnableScheduleConflict is returned. Like UpdateEnableSchedule, it can only be used in dynamic mode.
+	CompareAndSwapEnableSchedule(ctx context.Context, expected, enable bool) error
+
 	// GetEnableSchedule can only be used in dynamic mode, it will throw error when topology type is static.
 	GetEnableSchedule(ctx context.Context) (bool, error)
 
@@ -73,11 +84,73 @@ type SchedulerManager interface {
 	// ListShardAffinityRules lists all the rules about shard affinity of all the registered schedulers.
 	ListShardAffinityRules(ctx context.Context) (map[string]scheduler.ShardAffinityRule, error)
 
+	// RemoveShardAffinityRulesByFilter removes the shard affinity rules selected by the filter from all the registered schedulers
+	// atomically with respect to the other shard affinity operations, and reports the rules failing to be removed.
+	RemoveShardAffinityRulesByFilter(ctx context.Context, filter ShardAffinityFilter) (RemoveShardAffinitiesResult, error)
+
+	// UpdateTopologyType tears down the shard watch and registered schedulers of the current topology type, and re-initializes them for the new one.
+	// The caller must ensure the cluster is quiescent before switching.
+	UpdateTopologyType(ctx context.Context, topologyType storage.TopologyType) error
+
+	// UpdateNodePickerStrategy validates the node picker strategy against the registered strategies and persists it in the cluster metadata.
+	// The new strategy takes effect when the schedulers are initialized next time.
+	UpdateNodePickerStrategy(ctx context.Context, strategy string) error
+
+	// SimulateNodeLoss previews the reassignment of the shard leaders made by the schedulers as if the node were expired,
+	// and nothing is applied. It can only be used in dynamic mode.
+	SimulateNodeLoss(ctx context.Context, nodeName string) ([]ShardReassignment, error)
+
+	// RebalanceShards places only the given shards in the way of the rebalanced scheduler and transfers their leaders accordingly,
+	// and the planned moves are returned. Nothing is applied if dryRun is true. It can only be used in dynamic mode.
+	RebalanceShards(ctx context.Context, shardIDs []storage.ShardID, dryRun bool) ([]ShardReassignment, error)
+
+	// TriggerSchedule wakes up the scheduling loop to schedule immediately instead of waiting for the next interval.
+	TriggerSchedule()
+
+	// ResyncShardWatch forces the shard watch to resync the shard locks from etcd, and re-applies the callbacks to the shards whose
+	// leader in the cluster view mismatches the lock holder. It can only be used in dynamic mode.
+	ResyncShardWatch(ctx context.Context) (watch.ResyncResult, error)
+
+	// ListOscillatingShards lists the shards whose leader moves more frequently than the threshold, and their further moves are suppressed.
+	ListOscillatingShards() []OscillatingShard
+
 	// Scheduler will be called when received new heartbeat, every scheduler registered in schedulerManager will be called to generate procedures.
 	// Scheduler cloud be schedule with fix time interval or heartbeat.
 	Scheduler(ctx context.Context, clusterSnapshot metadata.Snapshot) []scheduler.ScheduleResult
 }
 
+// SchedulerInfo describes a registered scheduler.
+type SchedulerInfo struct {
+	// Type is the type name of the scheduler implementation.
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Enabled is the enableSchedule applied to the scheduler, and it is always false if the scheduler doesn't need enableSchedule.
+	Enabled bool `json:"enabled"`
+}
+
+// SchedulerRegistry describes the registered schedulers of a cluster and the configuration shared by them.
+type SchedulerRegistry struct {
+	TopologyType storage.TopologyType `json:"topologyType"`
+	// Running tells whether the registered schedulers are invoked by the scheduling loop.
+	Running                     bool   `json:"running"`
+	EnableSchedule              bool   `json:"enableSchedule"`
+	ProcedureExecutingBatchSize uint32 `json:"procedureExecutingBatchSize"`
+	SchedulerConcurrency        int    `json:"schedulerConcurrency"`
+	// NodePicker is the type name of the node picker used by the schedulers assigning shards to nodes.
+	NodePicker string `json:"nodePicker"`
+	// NodePickerStrategy is the strategy stored in the cluster metadata, which may not be applied until the schedulers are initialized next time.
+	NodePickerStrategy string          `json:"nodePickerStrategy"`
+	Schedulers         []SchedulerInfo `json:"schedulers"`
+}
+
+// ShardReassignment describes the leader of a shard moving from a node to another one.
+type ShardReassignment struct {
+	ShardID     storage.ShardID `json:"shardID"`
+	OldNodeName string          `json:"oldNodeName"`
+	// NewNodeName is empty if no compatible node is found for the shard.
+	NewNodeName string `json:"newNodeName"`
+}
+
 type schedulerManagerImpl struct {
 	logger           *zap.Logger
 	procedureManager procedure.Manager
@@ -94,25 +167,31 @@ type schedulerManagerImpl struct {
 	isRunning                   atomic.Bool
 	topologyType                storage.TopologyType
 	procedureExecutingBatchSize uint32
-	enableSchedule              bool
-	shardAffinities             map[storage.ShardID]scheduler.ShardAffinityRule
+	// triggerCh is used to wake up the scheduling loop.
+	triggerCh chan struct{}
+	// schedulerConcurrency is the max number of registered schedulers running concurrently.
+	schedulerConcurrency int
+	enableSchedule       bool
+	shardAffinities      map[storage.ShardID]scheduler.ShardAffinityRule
+	// affinityLock serializes the operations on the shard affinity rules of the schedulers.
+	affinityLock sync.Mutex
+	// oscillationDetector detects the shards moving too frequently, and their further moves are suppressed.
+	oscillationDetector *shardOscillationDetector
+	// preferredLeaderTracker is shared by the schedulers, so that the preferred leaders are honored after the same delay.
+	preferredLeaderTracker *scheduler.PreferredLeaderTracker
 }
 
-func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, topologyType storage.TopologyType, procedureExecutingBatchSize uint32) SchedulerManager {
-	var shardWatch watch.ShardWatch
-	switch topologyType {
-	case storage.TopologyTypeDynamic:
-		shardWatch = watch.NewEtcdShardWatch(logger, clusterMetadata.Name(), rootPath, client)
-		shardWatch.RegisteringEventCallback(&schedulerWatchCallback{c: clusterMetadata})
-	case storage.TopologyTypeStatic:
-		shardWatch = watch.NewNoopShardWatch()
+func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, topologyType storage.TopologyType, procedureExecutingBatchSize uint32, schedulerConcurrency int) SchedulerManager {
+	shardWatch := newShardWatch(logger, clusterMetadata, client, rootPath, topologyType)
+	if schedulerConcurrency <= 0 {
+		schedulerConcurrency = DefaultSchedulerConcurrency
 	}
 
 	return &schedulerManagerImpl{
 		logger:                      logger,
 		procedureManager:            procedureManager,
 		factory:                     factory,
-		nodePicker:                  nodepicker.NewConsistentUniformHashNodePicker(logger),
+		nodePicker:                  newNodePicker(logger, clusterMetadata.GetNodePickerStrategy(), clusterMetadata.GetNodePickerHash()),
 		client:                      client,
 		clusterMetadata:             clusterMetadata,
 		rootPath:                    rootPath,
@@ -122,17 +201,44 @@ func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory
 		isRunning:                   atomic.Bool{},
 		topologyType:                topologyType,
 		procedureExecutingBatchSize: procedureExecutingBatchSize,
+		triggerCh:                   make(chan struct{}, 1),
+		schedulerConcurrency:        schedulerConcurrency,
 		enableSchedule:              false,
 		shardAffinities:             make(map[storage.ShardID]scheduler.ShardAffinityRule),
+		affinityLock:                sync.Mutex{},
+		oscillationDetector:         newShardOscillationDetector(clusterMetadata.GetShardOscillationThreshold()),
+		preferredLeaderTracker:      scheduler.NewPreferredLeaderTracker(clusterMetadata.GetPreferredLeaderStabilizationDelay()),
 	}
 }
 
+// newNodePicker creates the node picker of the strategy, and falls back to the default strategy and hash function if they are unknown.
+func newNodePicker(logger *zap.Logger, strategy string, nodePickerHash metadata.NodePickerHash) nodepicker.NodePicker {
+	nodePicker, err := nodepicker.NewNodePicker(logger, strategy, nodePickerHash)
+	if err != nil {
+		logger.Warn("create node picker failed, fall back to the default strategy", zap.String("strategy", strategy), zap.String("hashFunction", nodePickerHash.Function), zap.Error(err))
+		nodePicker, _ = nodepicker.NewNodePicker(logger, nodepicker.DefaultStrategy, metadata.NodePickerHash{Function: nodepicker.DefaultHashFunction, Seed: 0})
+	}
+	return nodePicker
+}
+
+func newShardWatch(logger *zap.Logger, clusterMetadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, topologyType storage.TopologyType) watch.ShardWatch {
+	var shardWatch watch.ShardWatch
+	switch topologyType {
+	case storage.TopologyTypeDynamic:
+		shardWatch = watch.NewEtcdShardWatch(logger, clusterMetadata.Name(), rootPath, client)
+		shardWatch.RegisteringEventCallback(&schedulerWatchCallback{c: clusterMetadata})
+	case storage.TopologyTypeStatic:
+		shardWatch = watch.NewNoopShardWatch()
+	}
+	return shardWatch
+}
+
 func (m *schedulerManagerImpl) Stop(ctx context.Context) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	if m.isRunning.Load() {
-		m.registerSchedulers = m.registerSchedulers[:0]
+		m.registerSchedulers = []scheduler.Scheduler{}
 		m.isRunning.Store(false)
 		if err := m.shardWatch.Stop(ctx); err != nil {
 			return errors.WithMessage(err, "stop shard watch failed")
@@ -156,15 +262,18 @@ func (m *schedulerManagerImpl) Start(ctx context.Context) error {
 		return errors.WithMessage(err, "start shard watch failed")
 	}
 
+	m.isRunning.Store(true)
 	go func() {
-		m.isRunning.Store(true)
 		for {
 			if !m.isRunning.Load() {
 				m.logger.Info("scheduler manager is canceled")
 				return
 			}
 
-			time.Sleep(schedulerInterval)
+			select {
+			case <-time.After(schedulerInterval):
+			case <-m.triggerCh:
+			}
 			// Get latest cluster snapshot.
 			clusterSnapshot := m.clusterMetadata.GetClusterSnapshot()
 			m.logger.Debug("scheduler manager invoke", zap.String("clusterSnapshot", fmt.Sprintf("%v", clusterSnapshot)))
@@ -196,6 +305,7 @@ type schedulerWatchCallback struct {
 	c *metadata.ClusterMetadata
 }
 
+// OnShardRegistered does nothing, the new leader of the shard is updated when the node reports its shards in the heartbeat.
 func (callback *schedulerWatchCallback) OnShardRegistered(_ context.Context, _ watch.ShardRegisterEvent) error {
 	return nil
 }
@@ -214,6 +324,9 @@ func (callback *schedulerWatchCallback) OnShardExpired(ctx context.Context, even
 
 // Schedulers should to be initialized and registered here.
 func (m *schedulerManagerImpl) initRegister() {
+	m.nodePicker = newNodePicker(m.logger, m.clusterMetadata.GetNodePickerStrategy(), m.clusterMetadata.GetNodePickerHash())
+	m.oscillationDetector.updateThreshold(m.clusterMetadata.GetShardOscillationThreshold())
+
 	var schedulers []scheduler.Scheduler
 	switch m.topologyType {
 	case storage.TopologyTypeDynamic:
@@ -227,13 +340,13 @@ func (m *schedulerManagerImpl) initRegister() {
 }
 
 func (m *schedulerManagerImpl) createStaticTopologySchedulers() []scheduler.Scheduler {
-	staticTopologyShardScheduler := static.NewShardScheduler(m.factory, m.nodePicker, m.procedureExecutingBatchSize)
+	staticTopologyShardScheduler := static.NewShardScheduler(m.factory, m.nodePicker, m.procedureExecutingBatchSize, m.preferredLeaderTracker)
 	reopenShardScheduler := reopen.NewShardScheduler(m.factory, m.procedureExecutingBatchSize)
 	return []scheduler.Scheduler{staticTopologyShardScheduler, reopenShardScheduler}
 }
 
 func (m *schedulerManagerImpl) createDynamicTopologySchedulers() []scheduler.Scheduler {
-	rebalancedShardScheduler := rebalanced.NewShardScheduler(m.logger, m.factory, m.nodePicker, m.procedureExecutingBatchSize)
+	rebalancedShardScheduler := rebalanced.NewShardScheduler(m.logger, m.factory, m.nodePicker, m.procedureExecutingBatchSize, m.preferredLeaderTracker)
 	reopenShardScheduler := reopen.NewShardScheduler(m.factory, m.procedureExecutingBatchSize)
 	return []scheduler.Scheduler{rebalancedShardScheduler, reopenShardScheduler}
 }
@@ -250,16 +363,330 @@ func (m *schedulerManagerImpl) ListScheduler() []scheduler.Scheduler {
 	return m.registerSchedulers
 }
 
-func (m *schedulerManagerImpl) Scheduler(ctx context.Context, clusterSnapshot metadata.Snapshot) []scheduler.ScheduleResult {
-	// TODO: Every scheduler should run in an independent goroutine.
-	results := make([]scheduler.ScheduleResult, 0, len(m.registerSchedulers))
-	for _, scheduler := range m.registerSchedulers {
-		result, err := scheduler.Schedule(ctx, clusterSnapshot)
-		if err != nil {
-			m.logger.Error("scheduler failed", zap.Error(err))
+func (m *schedulerManagerImpl) DescribeSchedulers() SchedulerRegistry {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	running := m.isRunning.Load()
+	schedulers := make([]SchedulerInfo, 0, len(m.registerSchedulers))
+	for _, s := range m.registerSchedulers {
+		schedulers = append(schedulers, SchedulerInfo{
+			Type:    reflect.TypeOf(s).String(),
+			Name:    s.Name(),
+			Enabled: s.IsScheduleEnabled(),
+		})
+	}
+
+	return SchedulerRegistry{
+		TopologyType:                m.topologyType,
+		Running:                     running,
+		EnableSchedule:              m.enableSchedule,
+		ProcedureExecutingBatchSize: m.procedureExecutingBatchSize,
+		SchedulerConcurrency:        m.schedulerConcurrency,
+		NodePicker:                  reflect.TypeOf(m.nodePicker).String(),
+		NodePickerStrategy:          m.clusterMetadata.GetNodePickerStrategy(),
+		Schedulers:                  schedulers,
+	}
+}
+
+func (m *schedulerManagerImpl) UpdateNodePickerStrategy(ctx context.Context, strategy string) error {
+	if err := nodepicker.ValidateStrategy(strategy); err != nil {
+		return err
+	}
+
+	m.logger.Info("update node picker strategy", zap.String("strategy", strategy))
+	return m.clusterMetadata.SetNodePickerStrategy(ctx, strategy)
+}
+
+func (m *schedulerManagerImpl) SimulateNodeLoss(ctx context.Context, nodeName string) ([]ShardReassignment, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return nil, ErrInvalidTopologyType.WithCausef("node loss could only be simulated when topology type is dynamic")
+	}
+
+	snapshot := m.clusterMetadata.GetClusterSnapshot()
+	found := false
+	for i := range snapshot.RegisteredNodes {
+		if snapshot.RegisteredNodes[i].Node.Name == nodeName {
+			snapshot.RegisteredNodes[i].Node.LastTouchTime = 0
+			found = true
+		}
+	}
+	if !found {
+		return nil, metadata.ErrNodeNotFound.WithCausef("node name:%s", nodeName)
+	}
+
+	shardNodeMapping, err := m.pickShardNodeMapping(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	oldLeaders := shardLeaders(snapshot)
+
+	reassignments := []ShardReassignment{}
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		if snapshot.IsShardUnderMaintenance(shardID) {
 			continue
 		}
-		results = append(results, result)
+		oldNodeName := oldLeaders[shardID]
+		newNode, ok := shardNodeMapping[shardID]
+		switch {
+		case ok && newNode.Node.Name != oldNodeName:
+			reassignments = append(reassignments, ShardReassignment{ShardID: shardID, OldNodeName: oldNodeName, NewNodeName: newNode.Node.Name})
+		case !ok && oldNodeName == nodeName:
+			// The schedulers keep the shard on the lost node if no compatible node is found.
+			reassignments = append(reassignments, ShardReassignment{ShardID: shardID, OldNodeName: oldNodeName, NewNodeName: ""})
+		}
+	}
+	sort.Slice(reassignments, func(i, j int) bool { return reassignments[i].ShardID < reassignments[j].ShardID })
+
+	return reassignments, nil
+}
+
+func (m *schedulerManagerImpl) RebalanceShards(ctx context.Context, shardIDs []storage.ShardID, dryRun bool) ([]ShardReassignment, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return nil, ErrInvalidTopologyType.WithCausef("shards could only be rebalanced when topology type is dynamic")
+	}
+	if len(shardIDs) == 0 {
+		return nil, ErrInvalidRebalanceShards.WithCausef("shardIDs could not be empty")
+	}
+
+	snapshot := m.clusterMetadata.GetClusterSnapshot()
+	for _, shardID := range shardIDs {
+		if _, ok := snapshot.Topology.ShardViewsMapping[shardID]; !ok {
+			return nil, ErrInvalidRebalanceShards.WithCausef("shard not found, shardID:%d", shardID)
+		}
+		if snapshot.IsShardUnderMaintenance(shardID) {
+			return nil, ErrInvalidRebalanceShards.WithCausef("shard is under maintenance, shardID:%d", shardID)
+		}
+	}
+
+	// The whole cluster is placed so that the given shards land where the rebalanced scheduler expects them.
+	shardNodeMapping, err := m.pickShardNodeMapping(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	oldLeaders := shardLeaders(snapshot)
+
+	sortedShardIDs := slices.Clone(shardIDs)
+	slices.Sort(sortedShardIDs)
+	sortedShardIDs = slices.Compact(sortedShardIDs)
+
+	reassignments := []ShardReassignment{}
+	procedures := make([]procedure.Procedure, 0, len(sortedShardIDs))
+	for _, shardID := range sortedShardIDs {
+		newNode, ok := shardNodeMapping[shardID]
+		if !ok {
+			m.logger.Warn("keep shard on the current node, no compatible node is found", zap.Uint32("shardID", uint32(shardID)))
+			continue
+		}
+		oldNodeName := oldLeaders[shardID]
+		if newNode.Node.Name == oldNodeName {
+			continue
+		}
+		reassignments = append(reassignments, ShardReassignment{ShardID: shardID, OldNodeName: oldNodeName, NewNodeName: newNode.Node.Name})
+		if dryRun {
+			continue
+		}
+
+		p, err := m.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
+			Snapshot:          snapshot,
+			ShardID:           shardID,
+			OldLeaderNodeName: oldNodeName,
+			NewLeaderNodeName: newNode.Node.Name,
+			Reason:            coordinator.TransferLeaderReasonRebalance,
+		})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "create transfer leader procedure, shardID:%d", shardID)
+		}
+		procedures = append(procedures, p)
+	}
+
+	if len(procedures) == 0 {
+		return reassignments, nil
+	}
+
+	batchProcedure, err := m.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
+		Batch:       procedures,
+		BatchType:   procedure.TransferLeader,
+		Concurrency: m.procedureExecutingBatchSize,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "create batch transfer leader procedure")
+	}
+	m.logger.Info("rebalance shards", zap.String("reassignments", fmt.Sprintf("%+v", reassignments)))
+	if err := m.procedureManager.Submit(ctx, batchProcedure); err != nil {
+		return nil, errors.WithMessage(err, "submit batch transfer leader procedure")
+	}
+
+	return reassignments, nil
+}
+
+// pickShardNodeMapping picks the leader node for all the shards of the snapshot in the way of the rebalanced scheduler, honoring
+// the shard affinity rules of all the registered schedulers. The caller must hold the lock.
+// The preferred leaders are peeked without refreshing the tracker, since the snapshot may be modified for the previews.
+func (m *schedulerManagerImpl) pickShardNodeMapping(ctx context.Context, snapshot metadata.Snapshot) (map[storage.ShardID]metadata.RegisteredNode, error) {
+	shardAffinityRule := make(map[storage.ShardID]scheduler.ShardAffinity)
+	for _, s := range m.registerSchedulers {
+		rule, err := s.ListShardAffinityRule(ctx)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "list shard affinity rule, scheduler:%s", s.Name())
+		}
+		for _, affinity := range rule.Affinities {
+			shardAffinityRule[affinity.ShardID] = affinity
+		}
+	}
+
+	shardNodeMapping, err := rebalanced.PickShardNodeMapping(ctx, m.nodePicker, snapshot, shardAffinityRule, m.preferredLeaderTracker.PeekStablePreferredNodes(snapshot, time.Now()))
+	if err != nil {
+		return nil, errors.WithMessage(err, "pick shard node mapping")
+	}
+	return shardNodeMapping, nil
+}
+
+// shardLeaders returns the current leader of the shards, shardID -> nodeName.
+func shardLeaders(snapshot metadata.Snapshot) map[storage.ShardID]string {
+	leaders := make(map[storage.ShardID]string, len(snapshot.Topology.ClusterView.ShardNodes))
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ShardRole == storage.ShardRoleLeader {
+			leaders[shardNode.ID] = shardNode.NodeName
+		}
+	}
+	return leaders
+}
+
+func (m *schedulerManagerImpl) TriggerSchedule() {
+	select {
+	case m.triggerCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *schedulerManagerImpl) UpdateTopologyType(ctx context.Context, topologyType storage.TopologyType) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.topologyType == topologyType {
+		return nil
+	}
+
+	switch topologyType {
+	case storage.TopologyTypeStatic, storage.TopologyTypeDynamic:
+	default:
+		return ErrInvalidTopologyType.WithCausef("unknown topology type:%s", topologyType)
+	}
+
+	m.logger.Info("switch topology type of scheduler manager", zap.String("oldTopologyType", string(m.topologyType)), zap.String("newTopologyType", string(topologyType)))
+
+	isRunning := m.isRunning.Load()
+	if isRunning {
+		if err := m.shardWatch.Stop(ctx); err != nil {
+			return errors.WithMessage(err, "stop shard watch failed")
+		}
+	}
+
+	m.topologyType = topologyType
+	m.shardWatch = newShardWatch(m.logger, m.clusterMetadata, m.client, m.rootPath, topologyType)
+	m.registerSchedulers = []scheduler.Scheduler{}
+	if topologyType != storage.TopologyTypeDynamic {
+		m.enableSchedule = false
+	}
+
+	if !isRunning {
+		return nil
+	}
+
+	m.initRegister()
+	for _, scheduler := range m.registerSchedulers {
+		scheduler.UpdateEnableSchedule(ctx, m.enableSchedule)
+	}
+	if err := m.shardWatch.Start(ctx); err != nil {
+		return errors.WithMessage(err, "start shard watch failed")
+	}
+
+	return nil
+}
+
+func (m *schedulerManagerImpl) ResyncShardWatch(ctx context.Context) (watch.ResyncResult, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return watch.ResyncResult{}, ErrInvalidTopologyType.WithCausef("shard watch could only be resynced when topology type is dynamic")
+	}
+
+	start := time.Now()
+	result, err := m.shardWatch.Resync(ctx, shardLeaders(m.clusterMetadata.GetClusterSnapshot()))
+	if err != nil {
+		m.logger.Error("resync shard watch failed", zap.Int("corrections", result.Corrections()), zap.Duration("cost", time.Since(start)), zap.Error(err))
+		return result, err
+	}
+	m.logger.Info("resync shard watch finished", zap.Int("locks", result.Locks), zap.Int("corrections", result.Corrections()),
+		zap.Int("registered", len(result.Registered)), zap.Int("expired", len(result.Expired)), zap.Duration("cost", time.Since(start)))
+	return result, nil
+}
+
+func (m *schedulerManagerImpl) ListOscillatingShards() []OscillatingShard {
+	return m.oscillationDetector.list()
+}
+
+// detectOscillation records the leader moves of the shards in the snapshot, and returns the snapshot in which the oscillating shards
+// are marked.
+func (m *schedulerManagerImpl) detectOscillation(clusterSnapshot metadata.Snapshot) metadata.Snapshot {
+	started, stopped := m.oscillationDetector.observe(clusterSnapshot.Topology.ClusterView.ShardNodes, time.Now())
+	for _, shardID := range started {
+		m.logger.Warn("shard oscillation detected, suppress the further moves of the shard", zap.Uint32("shardID", uint32(shardID)))
+	}
+	for _, shardID := range stopped {
+		m.logger.Info("shard stops oscillating, resume the moves of the shard", zap.Uint32("shardID", uint32(shardID)))
+	}
+	return m.oscillationDetector.suppress(clusterSnapshot)
+}
+
+// Scheduler runs the registered schedulers concurrently, and the number of running schedulers is bounded by schedulerConcurrency.
+// The failure of a scheduler is only logged and won't affect the others.
+// The leaders of the shards moving more frequently than the oscillation threshold are not moved between the alive nodes.
+func (m *schedulerManagerImpl) Scheduler(ctx context.Context, clusterSnapshot metadata.Snapshot) []scheduler.ScheduleResult {
+	m.lock.RLock()
+	schedulers := make([]scheduler.Scheduler, len(m.registerSchedulers))
+	copy(schedulers, m.registerSchedulers)
+	concurrency := m.schedulerConcurrency
+	m.lock.RUnlock()
+
+	clusterSnapshot = m.detectOscillation(clusterSnapshot)
+
+	// The result of each scheduler is put into its own slot, and it is nil if the scheduler fails.
+	scheduleResults := make([]*scheduler.ScheduleResult, len(schedulers))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, s := range schedulers {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(idx int, s scheduler.Scheduler) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result, err := s.Schedule(ctx, clusterSnapshot)
+			if err != nil {
+				m.logger.Error("scheduler failed", zap.String("scheduler", s.Name()), zap.Error(err))
+				return
+			}
+			scheduleResults[idx] = &result
+		}(i, s)
+	}
+	wg.Wait()
+
+	results := make([]scheduler.ScheduleResult, 0, len(schedulers))
+	for _, result := range scheduleResults {
+		if result != nil {
+			results = append(results, *result)
+		}
 	}
 	return results
 }
@@ -272,12 +699,32 @@ func (m *schedulerManagerImpl) UpdateEnableSchedule(ctx context.Context, enable
 		return ErrInvalidTopologyType.WithCausef("deploy mode could only update when topology type is dynamic")
 	}
 
+	m.updateEnableScheduleLocked(ctx, enable)
+	return nil
+}
+
+func (m *schedulerManagerImpl) CompareAndSwapEnableSchedule(ctx context.Context, expected, enable bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return ErrInvalidTopologyType.WithCausef("deploy mode could only update when topology type is dynamic")
+	}
+
+	if m.enableSchedule != expected {
+		return ErrEnableScheduleConflict.WithCausef("current:%t, expected:%t", m.enableSchedule, expected)
+	}
+
+	m.updateEnableScheduleLocked(ctx, enable)
+	return nil
+}
+
+// updateEnableScheduleLocked applies the enableSchedule to the registered schedulers, and the caller should hold the lock.
+func (m *schedulerManagerImpl) updateEnableScheduleLocked(ctx context.Context, enable bool) {
 	m.enableSchedule = enable
 	for _, scheduler := range m.registerSchedulers {
 		scheduler.UpdateEnableSchedule(ctx, enable)
 	}
-
-	return nil
 }
 
 func (m *schedulerManagerImpl) GetEnableSchedule(_ context.Context) (bool, error) {
@@ -292,6 +739,9 @@ func (m *schedulerManagerImpl) GetEnableSchedule(_ context.Context) (bool, error
 }
 
 func (m *schedulerManagerImpl) AddShardAffinityRule(ctx context.Context, rule scheduler.ShardAffinityRule) error {
+	m.affinityLock.Lock()
+	defer m.affinityLock.Unlock()
+
 	var lastErr error
 	for _, scheduler := range m.registerSchedulers {
 		if err := scheduler.AddShardAffinityRule(ctx, rule); err != nil {
@@ -304,6 +754,9 @@ func (m *schedulerManagerImpl) AddShardAffinityRule(ctx context.Context, rule sc
 }
 
 func (m *schedulerManagerImpl) RemoveShardAffinityRule(ctx context.Context, shardID storage.ShardID) error {
+	m.affinityLock.Lock()
+	defer m.affinityLock.Unlock()
+
 	var lastErr error
 	for _, scheduler := range m.registerSchedulers {
 		if err := scheduler.RemoveShardAffinityRule(ctx, shardID); err != nil {
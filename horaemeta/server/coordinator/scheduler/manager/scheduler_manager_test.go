@@ -21,12 +21,16 @@ package manager_test
 
 import (
 	"context"
+	"slices"
 	"testing"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
@@ -39,23 +43,23 @@ func TestSchedulerManager(t *testing.T) {
 
 	// Init dependencies for scheduler manager.
 	c := test.InitStableCluster(ctx, t)
-	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata())
+	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
 	re.NoError(err)
 	dispatch := test.MockDispatch{}
 	allocator := test.MockIDAllocator{}
 	s := test.NewTestStorage(t)
-	f := coordinator.NewFactory(zap.NewNop(), allocator, dispatch, s, c.GetMetadata())
+	f := coordinator.NewFactory(zap.NewNop(), allocator, dispatch, s, c.GetMetadata(), coordinator.NewLeastTableShardPicker())
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 
 	// Create scheduler manager with enableScheduler equal to false.
-	schedulerManager := manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1)
+	schedulerManager := manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1, manager.DefaultSchedulerConcurrency)
 	err = schedulerManager.Start(ctx)
 	re.NoError(err)
 	err = schedulerManager.Stop(ctx)
 	re.NoError(err)
 
 	// Create scheduler manager with static topology.
-	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1)
+	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1, manager.DefaultSchedulerConcurrency)
 	err = schedulerManager.Start(ctx)
 	re.NoError(err)
 	schedulers := schedulerManager.ListScheduler()
@@ -64,11 +68,169 @@ func TestSchedulerManager(t *testing.T) {
 	re.NoError(err)
 
 	// Create scheduler manager with dynamic topology.
-	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeDynamic, 1)
+	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeDynamic, 1, manager.DefaultSchedulerConcurrency)
 	err = schedulerManager.Start(ctx)
 	re.NoError(err)
 	schedulers = schedulerManager.ListScheduler()
 	re.Equal(2, len(schedulers))
+	registry := schedulerManager.DescribeSchedulers()
+	re.Equal(storage.TopologyType(storage.TopologyTypeDynamic), registry.TopologyType)
+	re.Equal(uint32(1), registry.ProcedureExecutingBatchSize)
+	re.Equal(manager.DefaultSchedulerConcurrency, registry.SchedulerConcurrency)
+	re.NotEmpty(registry.NodePicker)
+	re.True(registry.Running)
+	re.Equal(2, len(registry.Schedulers))
+	for i, s := range schedulers {
+		re.Equal(s.Name(), registry.Schedulers[i].Name)
+		re.False(registry.Schedulers[i].Enabled)
+	}
+
+	// Compare and swap the enableSchedule.
+	err = schedulerManager.CompareAndSwapEnableSchedule(ctx, true, true)
+	re.Error(err)
+	re.True(coderr.Is(err, manager.ErrEnableScheduleConflict.Code()))
+	re.NoError(schedulerManager.CompareAndSwapEnableSchedule(ctx, false, true))
+	enableSchedule, err := schedulerManager.GetEnableSchedule(ctx)
+	re.NoError(err)
+	re.True(enableSchedule)
+	// Only the schedulers which need enableSchedule report it as enabled.
+	for _, info := range schedulerManager.DescribeSchedulers().Schedulers {
+		re.Equal(info.Name == "rebalanced_scheduler", info.Enabled)
+	}
+	re.NoError(schedulerManager.UpdateEnableSchedule(ctx, false))
+
+	// Simulate the loss of a node, and its shards are moved to the other nodes.
+	_, err = schedulerManager.SimulateNodeLoss(ctx, "unknownNode")
+	re.Error(err)
+	shardNodes := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes
+	lostNodeName := shardNodes[0].NodeName
+	reassignments, err := schedulerManager.SimulateNodeLoss(ctx, lostNodeName)
+	re.NoError(err)
+	re.NotEmpty(reassignments)
+	reassignedShards := make(map[storage.ShardID]struct{}, len(reassignments))
+	for _, reassignment := range reassignments {
+		re.NotEqual(lostNodeName, reassignment.NewNodeName)
+		reassignedShards[reassignment.ShardID] = struct{}{}
+	}
+	for _, shardNode := range shardNodes {
+		if shardNode.NodeName == lostNodeName {
+			re.Contains(reassignedShards, shardNode.ID)
+		}
+	}
+	// Nothing is applied.
+	re.Equal(shardNodes, c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes)
+
+	// Rebalance a subset of the shards after piling all the shards on a single node.
+	_, err = schedulerManager.RebalanceShards(ctx, nil, true)
+	re.True(coderr.Is(err, manager.ErrInvalidRebalanceShards.Code()))
+	_, err = schedulerManager.RebalanceShards(ctx, []storage.ShardID{9999}, true)
+	re.True(coderr.Is(err, manager.ErrInvalidRebalanceShards.Code()))
+	skewedShardNodes := make([]storage.ShardNode, 0, len(shardNodes))
+	for _, shardNode := range shardNodes {
+		skewedShardNodes = append(skewedShardNodes, storage.ShardNode{ID: shardNode.ID, ShardRole: storage.ShardRoleLeader, NodeName: lostNodeName})
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, skewedShardNodes))
+	allShardIDs := make([]storage.ShardID, 0, len(skewedShardNodes))
+	for _, shardNode := range skewedShardNodes {
+		allShardIDs = append(allShardIDs, shardNode.ID)
+	}
+	reassignments, err = schedulerManager.RebalanceShards(ctx, allShardIDs, true)
+	re.NoError(err)
+	re.NotEmpty(reassignments)
+	movedShardID := reassignments[0].ShardID
+	planned, err := schedulerManager.RebalanceShards(ctx, []storage.ShardID{movedShardID, movedShardID}, true)
+	re.NoError(err)
+	re.Equal(reassignments[:1], planned)
+	// The moves of the given shards are submitted.
+	planned, err = schedulerManager.RebalanceShards(ctx, []storage.ShardID{movedShardID}, false)
+	re.NoError(err)
+	re.Equal(reassignments[:1], planned)
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
 	err = schedulerManager.Stop(ctx)
 	re.NoError(err)
+
+	// Update the node picker strategy, and it takes effect on the next initialization.
+	re.Error(schedulerManager.UpdateNodePickerStrategy(ctx, "unknown"))
+	re.Empty(c.GetMetadata().GetNodePickerStrategy())
+	re.NoError(schedulerManager.UpdateNodePickerStrategy(ctx, nodepicker.StrategyConsistentUniformHash))
+	re.Equal(nodepicker.StrategyConsistentUniformHash, c.GetMetadata().GetNodePickerStrategy())
+	err = schedulerManager.Start(ctx)
+	re.NoError(err)
+	registry = schedulerManager.DescribeSchedulers()
+	re.Equal(nodepicker.StrategyConsistentUniformHash, registry.NodePickerStrategy)
+	re.Equal("*nodepicker.ConsistentUniformHashNodePicker", registry.NodePicker)
+	err = schedulerManager.Stop(ctx)
+	re.NoError(err)
+}
+
+func TestRemoveShardAffinityRulesByFilter(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedure.Timeouts{}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	re.NoError(err)
+	f := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
+	schedulerManager := manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeDynamic, 1, manager.DefaultSchedulerConcurrency)
+	re.NoError(schedulerManager.Start(ctx))
+	defer func() {
+		re.NoError(schedulerManager.Stop(ctx))
+	}()
+
+	shardNodes := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes
+	unknownShardID := storage.ShardID(9999)
+	affinities := []scheduler.ShardAffinity{{ShardID: unknownShardID, NumAllowedOtherShards: 0}}
+	for _, shardNode := range shardNodes {
+		affinities = append(affinities, scheduler.ShardAffinity{ShardID: shardNode.ID, NumAllowedOtherShards: 0})
+	}
+	re.NoError(schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: affinities}))
+
+	// Exactly one criterion must be set.
+	_, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: false, NodeName: "", UnknownShards: false, OfflineNodes: false})
+	re.True(coderr.Is(err, manager.ErrInvalidAffinityFilter.Code()))
+	_, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: true, NodeName: "", UnknownShards: true, OfflineNodes: false})
+	re.True(coderr.Is(err, manager.ErrInvalidAffinityFilter.Code()))
+
+	// The rules of the shards which don't exist are removed.
+	result, err := schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: false, NodeName: "", UnknownShards: true, OfflineNodes: false})
+	re.NoError(err)
+	re.Equal([]storage.ShardID{unknownShardID}, result.Removed)
+	re.Empty(result.Failed)
+
+	// All the nodes are online, so nothing is removed.
+	result, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: false, NodeName: "", UnknownShards: false, OfflineNodes: true})
+	re.NoError(err)
+	re.Empty(result.Removed)
+
+	// The offline nodes are not selected until the nodes in the cluster view are all registered right after the metadata is loaded.
+	unreportedShardNodes := slices.Clone(shardNodes)
+	unreportedShardNodes[0].NodeName = "unreportedNode"
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, unreportedShardNodes))
+	_, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: false, NodeName: "", UnknownShards: false, OfflineNodes: true})
+	re.True(coderr.Is(err, manager.ErrNodesNotReported.Code()))
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	// The rules of the shards on the node are removed.
+	nodeName := shardNodes[0].NodeName
+	var expectRemoved []storage.ShardID
+	for _, shardNode := range shardNodes {
+		if shardNode.NodeName == nodeName {
+			expectRemoved = append(expectRemoved, shardNode.ID)
+		}
+	}
+	result, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: false, NodeName: nodeName, UnknownShards: false, OfflineNodes: false})
+	re.NoError(err)
+	re.ElementsMatch(expectRemoved, result.Removed)
+
+	// The remaining rules are removed.
+	result, err = schedulerManager.RemoveShardAffinityRulesByFilter(ctx, manager.ShardAffinityFilter{All: true, NodeName: "", UnknownShards: false, OfflineNodes: false})
+	re.NoError(err)
+	re.Len(result.Removed, len(shardNodes)-len(expectRemoved))
+	rules, err := schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	for _, rule := range rules {
+		re.Empty(rule.Affinities)
+	}
 }
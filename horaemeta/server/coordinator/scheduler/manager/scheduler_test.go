@@ -0,0 +1,137 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockScheduler struct {
+	name     string
+	delay    time.Duration
+	fail     bool
+	running  *atomic.Int32
+	maxTrack *atomic.Int32
+}
+
+func (s mockScheduler) Name() string {
+	return s.name
+}
+
+func (s mockScheduler) Schedule(_ context.Context, _ metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	running := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		maxRunning := s.maxTrack.Load()
+		if running <= maxRunning || s.maxTrack.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+
+	time.Sleep(s.delay)
+	if s.fail {
+		return scheduler.ScheduleResult{Procedure: nil, Reason: ""}, errors.New("mock scheduler failed")
+	}
+	return scheduler.ScheduleResult{Procedure: nil, Reason: s.name}, nil
+}
+
+func (s mockScheduler) UpdateEnableSchedule(_ context.Context, _ bool) {}
+
+func (s mockScheduler) IsScheduleEnabled() bool {
+	return false
+}
+
+func (s mockScheduler) AddShardAffinityRule(_ context.Context, _ scheduler.ShardAffinityRule) error {
+	return nil
+}
+
+func (s mockScheduler) RemoveShardAffinityRule(_ context.Context, _ storage.ShardID) error {
+	return nil
+}
+
+func (s mockScheduler) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	return scheduler.ShardAffinityRule{Affinities: nil}, nil
+}
+
+func TestConcurrentScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	const concurrency = 2
+	var running, maxRunning atomic.Int32
+	schedulers := make([]scheduler.Scheduler, 0, 6)
+	expectReasons := make([]string, 0, 6)
+	for i := 0; i < 6; i++ {
+		s := mockScheduler{
+			name: fmt.Sprintf("scheduler%d", i),
+			// Schedulers registered later finish earlier.
+			delay:    time.Duration(6-i) * 10 * time.Millisecond,
+			fail:     i == 3,
+			running:  &running,
+			maxTrack: &maxRunning,
+		}
+		schedulers = append(schedulers, s)
+		if !s.fail {
+			expectReasons = append(expectReasons, s.name)
+		}
+	}
+
+	m := &schedulerManagerImpl{
+		logger:                      zap.NewNop(),
+		procedureManager:            nil,
+		factory:                     nil,
+		nodePicker:                  nil,
+		client:                      nil,
+		clusterMetadata:             nil,
+		rootPath:                    "",
+		lock:                        sync.RWMutex{},
+		registerSchedulers:          schedulers,
+		shardWatch:                  nil,
+		isRunning:                   atomic.Bool{},
+		topologyType:                storage.TopologyTypeStatic,
+		procedureExecutingBatchSize: 1,
+		triggerCh:                   make(chan struct{}, 1),
+		schedulerConcurrency:        concurrency,
+		enableSchedule:              false,
+		shardAffinities:             map[storage.ShardID]scheduler.ShardAffinityRule{},
+		affinityLock:                sync.Mutex{},
+		oscillationDetector:         newShardOscillationDetector(metadata.ShardOscillationThreshold{MaxMoves: 0, Window: 0}),
+	}
+
+	results := m.Scheduler(ctx, metadata.Snapshot{})
+	reasons := make([]string, 0, len(results))
+	for _, result := range results {
+		reasons = append(reasons, result.Reason)
+	}
+	re.ElementsMatch(expectReasons, reasons)
+	re.LessOrEqual(maxRunning.Load(), int32(concurrency))
+}
@@ -21,4 +21,8 @@ package nodepicker
 
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
-var ErrNoAliveNodes = coderr.NewCodeError(coderr.InvalidParams, "no alive nodes is found")
+var (
+	ErrNoAliveNodes        = coderr.NewCodeError(coderr.InvalidParams, "no alive nodes is found")
+	ErrUnknownStrategy     = coderr.NewCodeError(coderr.InvalidParams, "unknown node picker strategy")
+	ErrUnknownHashFunction = coderr.NewCodeError(coderr.InvalidParams, "unknown node picker hash function")
+)
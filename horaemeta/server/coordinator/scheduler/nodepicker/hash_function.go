@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nodepicker
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker/hash"
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// HashFunctionMurmur3 is the 64-bit murmur3 hash, and the seed is used as the murmur3 seed.
+	HashFunctionMurmur3 = "murmur3"
+	// HashFunctionFNV1a is the 64-bit FNV-1a hash, and the non-zero seed is hashed ahead of the data.
+	HashFunctionFNV1a = "fnv1a"
+	// DefaultHashFunction is used when no hash function is specified.
+	DefaultHashFunction = HashFunctionMurmur3
+)
+
+// hashFunctions contains the registered hash functions, name -> constructor.
+var hashFunctions = map[string]func(seed uint32) hash.Hasher{
+	HashFunctionMurmur3: func(seed uint32) hash.Hasher { return murmur3Hasher{seed: seed} },
+	HashFunctionFNV1a:   func(seed uint32) hash.Hasher { return fnv1aHasher{seed: seed} },
+}
+
+type murmur3Hasher struct {
+	seed uint32
+}
+
+func (h murmur3Hasher) Sum64(data []byte) uint64 {
+	return murmur3.Sum64WithSeed(data, h.seed)
+}
+
+type fnv1aHasher struct {
+	seed uint32
+}
+
+func (h fnv1aHasher) Sum64(data []byte) uint64 {
+	hasher := fnv.New64a()
+	if h.seed != 0 {
+		var seed [4]byte
+		binary.LittleEndian.PutUint32(seed[:], h.seed)
+		_, _ = hasher.Write(seed[:])
+	}
+	_, _ = hasher.Write(data)
+	return hasher.Sum64()
+}
+
+// RegisteredHashFunctions returns the names of the registered hash functions in order.
+func RegisteredHashFunctions() []string {
+	names := make([]string, 0, len(hashFunctions))
+	for name := range hashFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateHashFunction checks whether the hash function is registered, and the empty one stands for the DefaultHashFunction.
+func ValidateHashFunction(function string) error {
+	if len(function) == 0 {
+		return nil
+	}
+	if _, ok := hashFunctions[function]; !ok {
+		return ErrUnknownHashFunction.WithCausef("hash function:%s, registered hash functions:%v", function, RegisteredHashFunctions())
+	}
+	return nil
+}
+
+func newHasher(nodePickerHash metadata.NodePickerHash) (hash.Hasher, error) {
+	function := nodePickerHash.Function
+	if len(function) == 0 {
+		function = DefaultHashFunction
+	}
+	newHasher, ok := hashFunctions[function]
+	if !ok {
+		return nil, ErrUnknownHashFunction.WithCausef("hash function:%s, registered hash functions:%v", function, RegisteredHashFunctions())
+	}
+	return newHasher(nodePickerHash.Seed), nil
+}
@@ -21,6 +21,7 @@ package nodepicker
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
@@ -28,19 +29,27 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker/hash"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
-	"github.com/spaolacci/murmur3"
 	"go.uber.org/zap"
 )
 
 type Config struct {
 	NumTotalShards    uint32
 	ShardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+	// ShardMinNodeVersions contains the min node version required by the shards, shardID -> version.
+	// The shard is left out of the picking result if no alive node satisfies its min node version.
+	ShardMinNodeVersions map[storage.ShardID]string
+	// PreferredNodes contains the node preferred by the shards, shardID -> nodeName.
+	// The preferred node is picked if it is alive and satisfies the min node version of the shard.
+	PreferredNodes map[storage.ShardID]string
+	// ShardPermutation permutes the shard ids before placement, so that the adjacent shards are unlikely to land on the
+	// same node, which spreads the load of the range scans over the range partitioned data.
+	ShardPermutation bool
 }
 
 func (c Config) genPartitionAffinities() []hash.PartitionAffinity {
 	affinities := make([]hash.PartitionAffinity, 0, len(c.ShardAffinityRule))
 	for shardID, affinity := range c.ShardAffinityRule {
-		partitionID := int(shardID)
+		partitionID := c.partitionID(shardID)
 		affinities = append(affinities, hash.PartitionAffinity{
 			PartitionID:               partitionID,
 			NumAllowedOtherPartitions: affinity.NumAllowedOtherShards,
@@ -56,10 +65,22 @@ type NodePicker interface {
 
 type ConsistentUniformHashNodePicker struct {
 	logger *zap.Logger
+	hasher hash.Hasher
 }
 
+// NewConsistentUniformHashNodePicker creates the node picker with the default hash function.
 func NewConsistentUniformHashNodePicker(logger *zap.Logger) NodePicker {
-	return &ConsistentUniformHashNodePicker{logger: logger}
+	return &ConsistentUniformHashNodePicker{logger: logger, hasher: murmur3Hasher{seed: 0}}
+}
+
+// NewConsistentUniformHashNodePickerWithHash creates the node picker with the given hash function, and the same hash
+// function always leads to the same placement for the same nodes and shards.
+func NewConsistentUniformHashNodePickerWithHash(logger *zap.Logger, nodePickerHash metadata.NodePickerHash) (NodePicker, error) {
+	hasher, err := newHasher(nodePickerHash)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsistentUniformHashNodePicker{logger: logger, hasher: hasher}, nil
 }
 
 type nodeMember string
@@ -72,19 +93,13 @@ func (m nodeMember) String() string {
 
 const uniformHashReplicationFactor int = 127
 
-type hasher struct{}
-
-func (h hasher) Sum64(data []byte) uint64 {
-	return murmur3.Sum64(data)
-}
-
-// filterExpiredNodes will retain the alive nodes only.
-func filterExpiredNodes(nodes []metadata.RegisteredNode) map[string]metadata.RegisteredNode {
+// filterUnavailableNodes will retain the alive nodes which are not shutting down only.
+func filterUnavailableNodes(nodes []metadata.RegisteredNode) map[string]metadata.RegisteredNode {
 	now := time.Now()
 
 	aliveNodes := make(map[string]metadata.RegisteredNode, len(nodes))
 	for _, node := range nodes {
-		if !node.IsExpired(now) {
+		if !node.IsExpired(now) && !node.IsShuttingDown() {
 			aliveNodes[node.Node.Name] = node
 		}
 	}
@@ -93,7 +108,7 @@ func filterExpiredNodes(nodes []metadata.RegisteredNode) map[string]metadata.Reg
 }
 
 func (p *ConsistentUniformHashNodePicker) PickNode(_ context.Context, config Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode) (map[storage.ShardID]metadata.RegisteredNode, error) {
-	aliveNodes := filterExpiredNodes(registerNodes)
+	aliveNodes := filterUnavailableNodes(registerNodes)
 	if len(aliveNodes) == 0 {
 		return nil, ErrNoAliveNodes.WithCausef("registerNodes:%+v", registerNodes)
 	}
@@ -107,7 +122,7 @@ func (p *ConsistentUniformHashNodePicker) PickNode(_ context.Context, config Con
 
 	hashConf := hash.Config{
 		ReplicationFactor:   uniformHashReplicationFactor,
-		Hasher:              hasher{},
+		Hasher:              p.hasher,
 		PartitionAffinities: config.genPartitionAffinities(),
 	}
 	h, err := hash.BuildConsistentUniformHash(int(config.NumTotalShards), mems, hashConf)
@@ -116,16 +131,66 @@ func (p *ConsistentUniformHashNodePicker) PickNode(_ context.Context, config Con
 	}
 
 	shardNodes := make(map[storage.ShardID]metadata.RegisteredNode, len(registerNodes))
+	// The shards whose picked node is too old are re-picked after all the other shards are allocated.
+	constrainedShardIDs := make([]storage.ShardID, 0, len(config.ShardMinNodeVersions))
 	for _, shardID := range shardIDs {
 		assert.Assert(shardID < storage.ShardID(config.NumTotalShards))
-		partID := int(shardID)
-		nodeName := h.GetPartitionOwner(partID).String()
+		if preferredNode, ok := aliveNodes[config.PreferredNodes[shardID]]; ok && preferredNode.SatisfiesMinVersion(config.ShardMinNodeVersions[shardID]) {
+			shardNodes[shardID] = preferredNode
+			p.logger.Debug("shard is allocated to the preferred node", zap.Uint32("shardID", uint32(shardID)), zap.String("node", preferredNode.Node.Name))
+			continue
+		}
+
+		nodeName := h.GetPartitionOwner(config.partitionID(shardID)).String()
 		node, ok := aliveNodes[nodeName]
 		assert.Assertf(ok, "node:%s must be in the aliveNodes:%v", nodeName, aliveNodes)
-		shardNodes[storage.ShardID(partID)] = node
+		if !node.SatisfiesMinVersion(config.ShardMinNodeVersions[shardID]) {
+			constrainedShardIDs = append(constrainedShardIDs, shardID)
+			continue
+		}
+		shardNodes[shardID] = node
 
 		p.logger.Debug("shard is allocated to the node", zap.Uint32("shardID", uint32(shardID)), zap.String("node", nodeName))
 	}
 
+	p.pickCompatibleNodes(config, constrainedShardIDs, registerNodes, aliveNodes, shardNodes)
+
 	return shardNodes, nil
 }
+
+// pickCompatibleNodes allocates the shards to the least loaded alive node satisfying their min node version.
+func (p *ConsistentUniformHashNodePicker) pickCompatibleNodes(config Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode, aliveNodes map[string]metadata.RegisteredNode, shardNodes map[storage.ShardID]metadata.RegisteredNode) {
+	if len(shardIDs) == 0 {
+		return
+	}
+
+	numShards := make(map[string]int, len(aliveNodes))
+	for _, node := range shardNodes {
+		numShards[node.Node.Name]++
+	}
+
+	slices.Sort(shardIDs)
+	for _, shardID := range shardIDs {
+		minVersion := config.ShardMinNodeVersions[shardID]
+
+		var picked *metadata.RegisteredNode
+		for i := range registerNodes {
+			node := registerNodes[i]
+			if _, alive := aliveNodes[node.Node.Name]; !alive || !node.SatisfiesMinVersion(minVersion) {
+				continue
+			}
+			if picked == nil || numShards[node.Node.Name] < numShards[picked.Node.Name] {
+				picked = &registerNodes[i]
+			}
+		}
+
+		if picked == nil {
+			p.logger.Warn("shard is left unassigned, no alive node satisfies the min node version", zap.Uint32("shardID", uint32(shardID)), zap.String("minNodeVersion", minVersion))
+			continue
+		}
+
+		shardNodes[shardID] = *picked
+		numShards[picked.Node.Name]++
+		p.logger.Debug("shard is allocated to the compatible node", zap.Uint32("shardID", uint32(shardID)), zap.String("node", picked.Node.Name), zap.String("minNodeVersion", minVersion))
+	}
+}
@@ -47,8 +47,11 @@ func TestNodePicker(t *testing.T) {
 
 	var nodes []metadata.RegisteredNode
 	config := nodepicker.Config{
-		NumTotalShards:    defaultTotalShardNum,
-		ShardAffinityRule: nil,
+		NumTotalShards:       defaultTotalShardNum,
+		ShardAffinityRule:    nil,
+		ShardMinNodeVersions: nil,
+		PreferredNodes:       nil,
+		ShardPermutation:     false,
 	}
 	_, err := nodePicker.PickNode(ctx, config, []storage.ShardID{0}, nodes)
 	re.Error(err)
@@ -101,6 +104,27 @@ func TestNodePicker(t *testing.T) {
 	shardNodeMapping, err := nodePicker.PickNode(ctx, config, []storage.ShardID{0}, nodes)
 	re.NoError(err)
 	re.Equal(strconv.Itoa(selectOnlineNodeIndex), shardNodeMapping[0].Node.Name)
+
+	// The node which is shutting down should not be picked.
+	nodes = nodes[:0]
+	for i := 0; i < nodeLength; i++ {
+		node := storage.Node{
+			Name:          strconv.Itoa(i),
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: generateLastTouchTime(0),
+			State:         storage.NodeStateUnknown,
+		}
+		node.NodeStats.ShuttingDown = i != selectOnlineNodeIndex
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node:       node,
+			ShardInfos: nil,
+		})
+	}
+	shardNodeMapping, err = nodePicker.PickNode(ctx, config, []storage.ShardID{0, 1, 2}, nodes)
+	re.NoError(err)
+	for _, node := range shardNodeMapping {
+		re.Equal(strconv.Itoa(selectOnlineNodeIndex), node.Node.Name)
+	}
 }
 
 func TestUniformity(t *testing.T) {
@@ -178,8 +202,11 @@ func allocShards(ctx context.Context, nodePicker nodepicker.NodePicker, nodeNum
 		shardIDs = append(shardIDs, storage.ShardID(i))
 	}
 	config := nodepicker.Config{
-		NumTotalShards:    uint32(shardNum),
-		ShardAffinityRule: nil,
+		NumTotalShards:       uint32(shardNum),
+		ShardAffinityRule:    nil,
+		ShardMinNodeVersions: nil,
+		PreferredNodes:       nil,
+		ShardPermutation:     false,
 	}
 	shardNodeMapping, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
 	re.NoError(err)
@@ -213,3 +240,246 @@ func diffShardIds(oldShardIDs, newShardIDs []int) []int {
 	}
 	return result
 }
+
+func TestNodePickerMinNodeVersion(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	nodePicker := nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop())
+
+	// Only the last node is upgraded.
+	nodes := make([]metadata.RegisteredNode, 0, nodeLength)
+	for i := 0; i < nodeLength; i++ {
+		node := storage.Node{
+			Name:          strconv.Itoa(i),
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: generateLastTouchTime(0),
+			State:         storage.NodeStateUnknown,
+		}
+		node.NodeStats.NodeVersion = "1.2.0"
+		if i == nodeLength-1 {
+			node.NodeStats.NodeVersion = "v1.3.0-nightly"
+		}
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node:       node,
+			ShardInfos: nil,
+		})
+	}
+
+	shardIDs := make([]storage.ShardID, 0, defaultTotalShardNum)
+	for i := 0; i < defaultTotalShardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+	config := nodepicker.Config{
+		NumTotalShards:       defaultTotalShardNum,
+		ShardAffinityRule:    nil,
+		ShardMinNodeVersions: map[storage.ShardID]string{0: "1.3", 1: "1.3.0", 2: "2.0.0"},
+		PreferredNodes:       nil,
+		ShardPermutation:     false,
+	}
+	shardNodeMapping, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
+	re.NoError(err)
+
+	// The constrained shards are placed on the upgraded node.
+	re.Equal(strconv.Itoa(nodeLength-1), shardNodeMapping[0].Node.Name)
+	re.Equal(strconv.Itoa(nodeLength-1), shardNodeMapping[1].Node.Name)
+	// The shard is left unassigned if no node is compatible.
+	_, ok := shardNodeMapping[2]
+	re.False(ok)
+	// The other shards are not affected.
+	re.Len(shardNodeMapping, defaultTotalShardNum-1)
+}
+
+func TestNodePickerPreferredNode(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	nodePicker := nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop())
+
+	nodes := make([]metadata.RegisteredNode, 0, nodeLength)
+	for i := 0; i < nodeLength; i++ {
+		node := storage.Node{
+			Name:          strconv.Itoa(i),
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: generateLastTouchTime(0),
+			State:         storage.NodeStateUnknown,
+		}
+		// The last node is offline.
+		if i == nodeLength-1 {
+			node.LastTouchTime = generateLastTouchTime(time.Minute)
+		}
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node:       node,
+			ShardInfos: nil,
+		})
+	}
+
+	shardIDs := make([]storage.ShardID, 0, defaultTotalShardNum)
+	for i := 0; i < defaultTotalShardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+	config := nodepicker.Config{
+		NumTotalShards:       defaultTotalShardNum,
+		ShardAffinityRule:    nil,
+		ShardMinNodeVersions: nil,
+		PreferredNodes:       map[storage.ShardID]string{0: "0", 1: "1", 2: strconv.Itoa(nodeLength - 1)},
+		ShardPermutation:     false,
+	}
+	shardNodeMapping, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
+	re.NoError(err)
+	re.Len(shardNodeMapping, defaultTotalShardNum)
+
+	// The alive preferred nodes are picked.
+	re.Equal("0", shardNodeMapping[0].Node.Name)
+	re.Equal("1", shardNodeMapping[1].Node.Name)
+	// The shard falls back to other nodes if its preferred node is offline.
+	re.NotEqual(strconv.Itoa(nodeLength-1), shardNodeMapping[2].Node.Name)
+}
+
+func TestNodePickerStrategy(t *testing.T) {
+	re := require.New(t)
+
+	re.Contains(nodepicker.RegisteredStrategies(), nodepicker.DefaultStrategy)
+	re.NoError(nodepicker.ValidateStrategy(""))
+	re.NoError(nodepicker.ValidateStrategy(nodepicker.StrategyConsistentUniformHash))
+	re.Error(nodepicker.ValidateStrategy("unknown"))
+
+	defaultHash := metadata.NodePickerHash{Function: "", Seed: 0}
+	nodePicker, err := nodepicker.NewNodePicker(zap.NewNop(), "", defaultHash)
+	re.NoError(err)
+	re.IsType(&nodepicker.ConsistentUniformHashNodePicker{}, nodePicker)
+
+	_, err = nodepicker.NewNodePicker(zap.NewNop(), "unknown", defaultHash)
+	re.Error(err)
+	_, err = nodepicker.NewNodePicker(zap.NewNop(), "", metadata.NodePickerHash{Function: "unknown", Seed: 0})
+	re.Error(err)
+}
+
+func TestNodePickerHash(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	re.Equal([]string{nodepicker.HashFunctionFNV1a, nodepicker.HashFunctionMurmur3}, nodepicker.RegisteredHashFunctions())
+	re.NoError(nodepicker.ValidateHashFunction(""))
+	re.Error(nodepicker.ValidateHashFunction("unknown"))
+
+	const numShards = 64
+	var nodes []metadata.RegisteredNode
+	for i := 0; i < 5; i++ {
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          fmt.Sprintf("node-%d", i),
+				NodeStats:     storage.NewEmptyNodeStats(),
+				LastTouchTime: generateLastTouchTime(0),
+				State:         storage.NodeStateUnknown,
+			},
+			ShardInfos: nil,
+		})
+	}
+	shardIDs := make([]storage.ShardID, 0, numShards)
+	for i := 0; i < numShards; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+	config := nodepicker.Config{
+		NumTotalShards:       numShards,
+		ShardAffinityRule:    nil,
+		ShardMinNodeVersions: nil,
+		PreferredNodes:       nil,
+		ShardPermutation:     false,
+	}
+	pick := func(nodePicker nodepicker.NodePicker) map[storage.ShardID]string {
+		shardNodeMapping, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
+		re.NoError(err)
+		placement := make(map[storage.ShardID]string, len(shardNodeMapping))
+		for shardID, node := range shardNodeMapping {
+			placement[shardID] = node.Node.Name
+		}
+		return placement
+	}
+	pickWithHash := func(nodePickerHash metadata.NodePickerHash) map[storage.ShardID]string {
+		nodePicker, err := nodepicker.NewConsistentUniformHashNodePickerWithHash(zap.NewNop(), nodePickerHash)
+		re.NoError(err)
+		return pick(nodePicker)
+	}
+
+	// The default hash function with zero seed keeps the placement of the default node picker.
+	defaultPlacement := pick(nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()))
+	re.Equal(defaultPlacement, pickWithHash(metadata.NodePickerHash{Function: "", Seed: 0}))
+	re.Equal(defaultPlacement, pickWithHash(metadata.NodePickerHash{Function: nodepicker.HashFunctionMurmur3, Seed: 0}))
+
+	// The placement is determined by the hash function and the seed.
+	for _, function := range nodepicker.RegisteredHashFunctions() {
+		nodePickerHash := metadata.NodePickerHash{Function: function, Seed: 42}
+		placement := pickWithHash(nodePickerHash)
+		re.Len(placement, numShards)
+		re.Equal(placement, pickWithHash(nodePickerHash))
+		re.NotEqual(placement, pickWithHash(metadata.NodePickerHash{Function: function, Seed: 7}))
+	}
+	re.NotEqual(defaultPlacement, pickWithHash(metadata.NodePickerHash{Function: nodepicker.HashFunctionFNV1a, Seed: 0}))
+
+	_, err := nodepicker.NewConsistentUniformHashNodePickerWithHash(zap.NewNop(), metadata.NodePickerHash{Function: "unknown", Seed: 0})
+	re.Error(err)
+}
+
+func TestNodePickerShardPermutation(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	nodePicker := nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop())
+
+	shardNum := 256
+	shardIDs := make([]storage.ShardID, 0, shardNum)
+	for i := 0; i < shardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+
+	// countAdjacentShards counts the adjacent shards placed on the same node.
+	countAdjacentShards := func(nodeNum int, shardPermutation bool) int {
+		var nodes []metadata.RegisteredNode
+		for i := 0; i < nodeNum; i++ {
+			nodes = append(nodes, metadata.RegisteredNode{
+				Node: storage.Node{
+					Name:          strconv.Itoa(i),
+					NodeStats:     storage.NewEmptyNodeStats(),
+					LastTouchTime: generateLastTouchTime(0),
+					State:         storage.NodeStateUnknown,
+				},
+				ShardInfos: nil,
+			})
+		}
+		config := nodepicker.Config{
+			NumTotalShards:       uint32(shardNum),
+			ShardAffinityRule:    nil,
+			ShardMinNodeVersions: nil,
+			PreferredNodes:       nil,
+			ShardPermutation:     shardPermutation,
+		}
+		shardNodes, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
+		re.NoError(err)
+		re.Len(shardNodes, shardNum)
+
+		// The permutation must keep the load uniform.
+		loads := make(map[string]int, nodeNum)
+		for _, node := range shardNodes {
+			loads[node.Node.Name]++
+		}
+		for _, load := range loads {
+			re.LessOrEqual(load, shardNum/nodeNum+1)
+			re.GreaterOrEqual(load, shardNum/nodeNum)
+		}
+
+		count := 0
+		for i := 1; i < shardNum; i++ {
+			if shardNodes[storage.ShardID(i)].Node.Name == shardNodes[storage.ShardID(i-1)].Node.Name {
+				count++
+			}
+		}
+		return count
+	}
+
+	numAdjacent, numAdjacentPermuted := 0, 0
+	for nodeNum := 2; nodeNum <= 6; nodeNum++ {
+		numAdjacent += countAdjacentShards(nodeNum, false)
+		numAdjacentPermuted += countAdjacentShards(nodeNum, true)
+	}
+	re.Less(numAdjacentPermuted, numAdjacent)
+}
@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nodepicker
+
+import (
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// goldenRatioConjugate makes the stride of the permutation far from both zero and the number of shards, which keeps the
+// adjacent shards apart.
+const goldenRatioConjugate = 0.6180339887498949
+
+// permutationStride returns the smallest number not less than the golden section of numTotalShards and coprime to it,
+// so that the multiplication by it modulo numTotalShards is a bijection.
+func permutationStride(numTotalShards uint32) uint64 {
+	if numTotalShards <= 2 {
+		return 1
+	}
+
+	stride := uint64(float64(numTotalShards) * goldenRatioConjugate)
+	for gcd(stride, uint64(numTotalShards)) != 1 {
+		stride++
+	}
+	return stride
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// permuteShardID maps the shard to a partition of the consistent hash by a bijection on [0, numTotalShards), and the
+// adjacent shards are mapped to the partitions which are a stride apart.
+func permuteShardID(shardID storage.ShardID, numTotalShards uint32) int {
+	return int(uint64(shardID) * permutationStride(numTotalShards) % uint64(numTotalShards))
+}
+
+// partitionID returns the partition of the consistent hash which the shard is placed as.
+func (c Config) partitionID(shardID storage.ShardID) int {
+	if !c.ShardPermutation {
+		return int(shardID)
+	}
+	return permuteShardID(shardID, c.NumTotalShards)
+}
@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nodepicker
+
+import (
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"go.uber.org/zap"
+)
+
+const (
+	// StrategyConsistentUniformHash picks nodes by the consistent uniform hash of the shard id.
+	StrategyConsistentUniformHash = "consistent_uniform_hash"
+	// DefaultStrategy is used when no strategy is specified for the cluster.
+	DefaultStrategy = StrategyConsistentUniformHash
+)
+
+// strategies contains the registered node picker strategies, strategy name -> constructor.
+var strategies = map[string]func(logger *zap.Logger, nodePickerHash metadata.NodePickerHash) (NodePicker, error){
+	StrategyConsistentUniformHash: NewConsistentUniformHashNodePickerWithHash,
+}
+
+// RegisteredStrategies returns the names of the registered node picker strategies in order.
+func RegisteredStrategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateStrategy checks whether the strategy is registered, and the empty strategy stands for the DefaultStrategy.
+func ValidateStrategy(strategy string) error {
+	if len(strategy) == 0 {
+		return nil
+	}
+	if _, ok := strategies[strategy]; !ok {
+		return ErrUnknownStrategy.WithCausef("strategy:%s, registered strategies:%v", strategy, RegisteredStrategies())
+	}
+	return nil
+}
+
+// NewNodePicker creates the node picker of the strategy with the hash function, and the empty strategy stands for the DefaultStrategy.
+func NewNodePicker(logger *zap.Logger, strategy string, nodePickerHash metadata.NodePickerHash) (NodePicker, error) {
+	if len(strategy) == 0 {
+		strategy = DefaultStrategy
+	}
+	newPicker, ok := strategies[strategy]
+	if !ok {
+		return nil, ErrUnknownStrategy.WithCausef("strategy:%s, registered strategies:%v", strategy, RegisteredStrategies())
+	}
+	return newPicker(logger, nodePickerHash)
+}
@@ -0,0 +1,93 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// PreferredLeaderTracker honors the preferred leader of a shard only after its node has been online for the stabilization
+// delay, so that a flapping node won't cause the leadership to ping-pong. It is shared by the schedulers of a cluster.
+type PreferredLeaderTracker struct {
+	stabilizationDelay time.Duration
+
+	// The lock is used to protect following fields.
+	lock sync.Mutex
+	// nodeOnlineSince records when the node is observed online continuously, nodeName -> time.
+	nodeOnlineSince map[string]time.Time
+}
+
+func NewPreferredLeaderTracker(stabilizationDelay time.Duration) *PreferredLeaderTracker {
+	return &PreferredLeaderTracker{
+		stabilizationDelay: stabilizationDelay,
+		lock:               sync.Mutex{},
+		nodeOnlineSince:    map[string]time.Time{},
+	}
+}
+
+// StablePreferredNodes returns the preferred leaders whose nodes have been online for the stabilization delay, and the online
+// time of the nodes is refreshed by the way.
+func (t *PreferredLeaderTracker) StablePreferredNodes(snapshot metadata.Snapshot, now time.Time) map[storage.ShardID]string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.nodeOnlineSince = t.onlineSinceLocked(snapshot, now)
+	return t.stablePreferredNodesLocked(snapshot, t.nodeOnlineSince, now)
+}
+
+// PeekStablePreferredNodes returns the same preferred leaders as StablePreferredNodes without refreshing the online time of the nodes,
+// so that the previews on the modified snapshots, e.g. with a node simulated as lost, never affect the real scheduling.
+func (t *PreferredLeaderTracker) PeekStablePreferredNodes(snapshot metadata.Snapshot, now time.Time) map[storage.ShardID]string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.stablePreferredNodesLocked(snapshot, t.onlineSinceLocked(snapshot, now), now)
+}
+
+// onlineSinceLocked returns the online time of the nodes online in the snapshot, and the nodes not tracked yet are online since now.
+func (t *PreferredLeaderTracker) onlineSinceLocked(snapshot metadata.Snapshot, now time.Time) map[string]time.Time {
+	nodeOnlineSince := make(map[string]time.Time, len(snapshot.RegisteredNodes))
+	for _, node := range snapshot.RegisteredNodes {
+		if node.IsExpired(now) || node.IsShuttingDown() {
+			continue
+		}
+		onlineSince, ok := t.nodeOnlineSince[node.Node.Name]
+		if !ok {
+			onlineSince = now
+		}
+		nodeOnlineSince[node.Node.Name] = onlineSince
+	}
+	return nodeOnlineSince
+}
+
+func (t *PreferredLeaderTracker) stablePreferredNodesLocked(snapshot metadata.Snapshot, nodeOnlineSince map[string]time.Time, now time.Time) map[storage.ShardID]string {
+	preferredNodes := make(map[storage.ShardID]string, len(snapshot.PreferredLeaders))
+	for shardID, nodeName := range snapshot.PreferredLeaders {
+		onlineSince, ok := nodeOnlineSince[nodeName]
+		if ok && now.Sub(onlineSince) >= t.stabilizationDelay {
+			preferredNodes[shardID] = nodeName
+		}
+	}
+	return preferredNodes
+}
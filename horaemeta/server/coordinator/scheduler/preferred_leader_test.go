@@ -0,0 +1,109 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStablePreferredNodes(t *testing.T) {
+	re := require.New(t)
+
+	delay := time.Minute
+	tracker := scheduler.NewPreferredLeaderTracker(delay)
+	now := time.Now()
+	newSnapshot := func(touchTime time.Time, online bool) metadata.Snapshot {
+		node := metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          "node0",
+				NodeStats:     storage.NewEmptyNodeStats(),
+				LastTouchTime: uint64(touchTime.UnixMilli()),
+				State:         storage.NodeStateOnline,
+			},
+			ShardInfos: nil,
+		}
+		node.Node.NodeStats.ShuttingDown = !online
+		return metadata.Snapshot{
+			Topology:             metadata.Topology{},
+			RegisteredNodes:      []metadata.RegisteredNode{node},
+			MaintenanceShards:    map[storage.ShardID]string{},
+			ReadyShardStatuses:   nil,
+			ShardMinNodeVersions: map[storage.ShardID]string{},
+			PreferredLeaders:     map[storage.ShardID]string{0: "node0", 1: "node1"},
+			ShardPermutation:     false,
+		}
+	}
+
+	// The preferred node is not honored until it has been online for the stabilization delay.
+	re.Empty(tracker.StablePreferredNodes(newSnapshot(now, true), now))
+	re.Empty(tracker.StablePreferredNodes(newSnapshot(now.Add(delay/2), true), now.Add(delay/2)))
+	re.Equal(map[storage.ShardID]string{0: "node0"}, tracker.StablePreferredNodes(newSnapshot(now.Add(delay), true), now.Add(delay)))
+
+	// The preferred node is dropped immediately when it is offline, and the delay is restarted after it returns.
+	re.Empty(tracker.StablePreferredNodes(newSnapshot(now.Add(delay), false), now.Add(delay)))
+	re.Empty(tracker.StablePreferredNodes(newSnapshot(now.Add(delay), true), now.Add(delay)))
+	re.Equal(map[storage.ShardID]string{0: "node0"}, tracker.StablePreferredNodes(newSnapshot(now.Add(2*delay), true), now.Add(2*delay)))
+
+	// The preferred node is honored immediately if there is no delay.
+	re.Equal(map[storage.ShardID]string{0: "node0"}, scheduler.NewPreferredLeaderTracker(0).StablePreferredNodes(newSnapshot(now, true), now))
+}
+
+func TestPeekStablePreferredNodes(t *testing.T) {
+	re := require.New(t)
+
+	delay := time.Minute
+	tracker := scheduler.NewPreferredLeaderTracker(delay)
+	now := time.Now()
+	newSnapshot := func(touchTime time.Time) metadata.Snapshot {
+		return metadata.Snapshot{
+			Topology: metadata.Topology{},
+			RegisteredNodes: []metadata.RegisteredNode{{
+				Node: storage.Node{
+					Name:          "node0",
+					NodeStats:     storage.NewEmptyNodeStats(),
+					LastTouchTime: uint64(touchTime.UnixMilli()),
+					State:         storage.NodeStateOnline,
+				},
+				ShardInfos: nil,
+			}},
+			MaintenanceShards:    map[storage.ShardID]string{},
+			ReadyShardStatuses:   nil,
+			ShardMinNodeVersions: map[storage.ShardID]string{},
+			PreferredLeaders:     map[storage.ShardID]string{0: "node0"},
+			ShardPermutation:     false,
+		}
+	}
+
+	// The peek regards the untracked nodes as online since now, but never starts tracking them.
+	re.Equal(map[storage.ShardID]string{0: "node0"}, scheduler.NewPreferredLeaderTracker(0).PeekStablePreferredNodes(newSnapshot(now), now))
+	re.Empty(tracker.PeekStablePreferredNodes(newSnapshot(now), now))
+	re.Empty(tracker.StablePreferredNodes(newSnapshot(now.Add(delay)), now.Add(delay)))
+
+	// The preview with the node simulated as lost leaves the tracker unchanged, so the node is still honored after the delay.
+	re.Empty(tracker.PeekStablePreferredNodes(newSnapshot(time.UnixMilli(0)), now.Add(delay)))
+	re.Equal(map[storage.ShardID]string{0: "node0"}, tracker.PeekStablePreferredNodes(newSnapshot(now.Add(2*delay)), now.Add(2*delay)))
+	re.Equal(map[storage.ShardID]string{0: "node0"}, tracker.StablePreferredNodes(newSnapshot(now.Add(2*delay)), now.Add(2*delay)))
+}
@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package rebalanced
+
+import (
+	"sort"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// balanceLeaders evens out the number of leader shards assigned to every alive node in the shardNodeMapping by moving
+// the leadership of a shard to a node which already holds a follower of it, so that no shard is moved to a node without
+// its replica and the placement of the node picker is kept otherwise. The pinned shards (e.g. by the affinity rules or
+// the preferred leaders) are never moved, and shards are never moved to the nodes older than their min node version.
+//
+// The result is deterministic for the same inputs, so that the balanced mapping is stable across the schedule rounds.
+func balanceLeaders(snapshot metadata.Snapshot, shardNodeMapping map[storage.ShardID]metadata.RegisteredNode, pinnedShards map[storage.ShardID]struct{}) map[storage.ShardID]metadata.RegisteredNode {
+	// The nodes which hold a follower of the shard in current topology.
+	followerNodes := make(map[storage.ShardID]map[string]struct{}, len(snapshot.Topology.ShardViewsMapping))
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ShardRole != storage.ShardRoleFollower {
+			continue
+		}
+		if _, ok := followerNodes[shardNode.ID]; !ok {
+			followerNodes[shardNode.ID] = map[string]struct{}{}
+		}
+		followerNodes[shardNode.ID][shardNode.NodeName] = struct{}{}
+	}
+	if len(followerNodes) == 0 {
+		return shardNodeMapping
+	}
+
+	now := time.Now()
+	aliveNodes := make(map[string]metadata.RegisteredNode, len(snapshot.RegisteredNodes))
+	for _, node := range snapshot.RegisteredNodes {
+		if !node.IsExpired(now) && !node.IsShuttingDown() {
+			aliveNodes[node.Node.Name] = node
+		}
+	}
+	if len(aliveNodes) <= 1 {
+		return shardNodeMapping
+	}
+
+	nodeNames := make([]string, 0, len(aliveNodes))
+	for name := range aliveNodes {
+		nodeNames = append(nodeNames, name)
+	}
+
+	leaderShards := make(map[string][]storage.ShardID, len(aliveNodes))
+	for shardID, node := range shardNodeMapping {
+		if _, alive := aliveNodes[node.Node.Name]; !alive {
+			continue
+		}
+		leaderShards[node.Node.Name] = append(leaderShards[node.Node.Name], shardID)
+	}
+	for _, shardIDs := range leaderShards {
+		sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+	}
+
+	balanced := make(map[storage.ShardID]metadata.RegisteredNode, len(shardNodeMapping))
+	for shardID, node := range shardNodeMapping {
+		balanced[shardID] = node
+	}
+
+	for {
+		// Sort the nodes by their leader counts, and the ties are broken by the names.
+		sort.Slice(nodeNames, func(i, j int) bool {
+			if len(leaderShards[nodeNames[i]]) != len(leaderShards[nodeNames[j]]) {
+				return len(leaderShards[nodeNames[i]]) < len(leaderShards[nodeNames[j]])
+			}
+			return nodeNames[i] < nodeNames[j]
+		})
+
+		from, to, idx := pickLeaderToMove(nodeNames, leaderShards, aliveNodes, followerNodes, pinnedShards, snapshot.ShardMinNodeVersions)
+		if idx < 0 {
+			break
+		}
+
+		shardID := leaderShards[from][idx]
+		leaderShards[from] = append(leaderShards[from][:idx], leaderShards[from][idx+1:]...)
+		leaderShards[to] = append(leaderShards[to], shardID)
+		balanced[shardID] = aliveNodes[to]
+	}
+
+	return balanced
+}
+
+// pickLeaderToMove picks a shard whose leadership can be moved from a more loaded node to a less loaded one, the nodeNames
+// must be sorted by their leader counts in ascending order. The returned index is -1 if no leadership can be moved.
+func pickLeaderToMove(nodeNames []string, leaderShards map[string][]storage.ShardID, aliveNodes map[string]metadata.RegisteredNode, followerNodes map[storage.ShardID]map[string]struct{}, pinnedShards map[storage.ShardID]struct{}, minNodeVersions map[storage.ShardID]string) (string, string, int) {
+	for i := len(nodeNames) - 1; i >= 0; i-- {
+		from := nodeNames[i]
+		for _, to := range nodeNames[:i] {
+			// Moving a leader between the nodes whose leader counts differ by at most one doesn't make them more even.
+			if len(leaderShards[from])-len(leaderShards[to]) <= 1 {
+				break
+			}
+			for idx, shardID := range leaderShards[from] {
+				if _, pinned := pinnedShards[shardID]; pinned {
+					continue
+				}
+				if _, ok := followerNodes[shardID][to]; !ok {
+					continue
+				}
+				if !aliveNodes[to].SatisfiesMinVersion(minNodeVersions[shardID]) {
+					continue
+				}
+				return from, to, idx
+			}
+		}
+	}
+	return "", "", -1
+}
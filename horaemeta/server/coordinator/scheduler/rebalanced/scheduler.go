@@ -25,8 +25,8 @@ import (
 	"maps"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
@@ -51,9 +51,11 @@ type schedulerImpl struct {
 	enableSchedule bool
 	// shardAffinityRule is used to control the shard distribution.
 	shardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+	// preferredLeaderTracker filters out the preferred leaders whose nodes are not stable yet.
+	preferredLeaderTracker *scheduler.PreferredLeaderTracker
 }
 
-func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32) scheduler.Scheduler {
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32, preferredLeaderTracker *scheduler.PreferredLeaderTracker) scheduler.Scheduler {
 	return &schedulerImpl{
 		logger:                      logger,
 		factory:                     factory,
@@ -63,6 +65,7 @@ func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePic
 		latestShardNodeMapping:      map[storage.ShardID]metadata.RegisteredNode{},
 		enableSchedule:              false,
 		shardAffinityRule:           map[storage.ShardID]scheduler.ShardAffinity{},
+		preferredLeaderTracker:      preferredLeaderTracker,
 	}
 }
 
@@ -74,6 +77,13 @@ func (r *schedulerImpl) UpdateEnableSchedule(_ context.Context, enable bool) {
 	r.updateEnableSchedule(enable)
 }
 
+func (r *schedulerImpl) IsScheduleEnabled() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.enableSchedule
+}
+
 func (r *schedulerImpl) AddShardAffinityRule(_ context.Context, rule scheduler.ShardAffinityRule) error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -123,6 +133,13 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 	}
 
 	numShards := uint32(len(clusterSnapshot.Topology.ShardViewsMapping))
+	now := time.Now()
+	aliveNodes := make(map[string]struct{}, len(clusterSnapshot.RegisteredNodes))
+	for _, node := range clusterSnapshot.RegisteredNodes {
+		if !node.IsExpired(now) && !node.IsShuttingDown() {
+			aliveNodes[node.Node.Name] = struct{}{}
+		}
+	}
 	// Generate assigned shards mapping and transfer leader if node is changed.
 	assignedShardIDs := make(map[storage.ShardID]struct{}, numShards)
 	for _, shardNode := range clusterSnapshot.Topology.ClusterView.ShardNodes {
@@ -133,15 +150,32 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 
 		// Mark the shard assigned.
 		assignedShardIDs[shardNode.ID] = struct{}{}
+		// The leadership is transferred from the leader, and the followers are never transferred themselves.
+		if shardNode.ShardRole == storage.ShardRoleFollower {
+			continue
+		}
+		if clusterSnapshot.IsShardUnderMaintenance(shardNode.ID) {
+			continue
+		}
 		newLeaderNode, ok := shardNodeMapping[shardNode.ID]
-		assert.Assert(ok)
+		if !ok {
+			// No node satisfies the placement constraints of the shard, keep it on the current node.
+			r.logger.Warn("rebalanced shard scheduler keeps shard on the current node, no compatible node is found", zap.Uint64("shardID", uint64(shardNode.ID)), zap.String("node", shardNode.NodeName), zap.String("minNodeVersion", clusterSnapshot.ShardMinNodeVersions[shardNode.ID]))
+			continue
+		}
 		if newLeaderNode.Node.Name != shardNode.NodeName {
+			// The oscillating shard is kept on the alive node, and it is only moved off the dead node.
+			if _, alive := aliveNodes[shardNode.NodeName]; alive && clusterSnapshot.IsShardMoveSuppressed(shardNode.ID) {
+				r.logger.Warn("rebalanced shard scheduler keeps oscillating shard on the current node", zap.Uint64("shardID", uint64(shardNode.ID)), zap.String("node", shardNode.NodeName), zap.String("newNode", newLeaderNode.Node.Name))
+				continue
+			}
 			r.logger.Info("rebalanced shard scheduler try to assign shard to another node", zap.Uint64("shardID", uint64(shardNode.ID)), zap.String("originNode", shardNode.NodeName), zap.String("newNode", newLeaderNode.Node.Name))
 			p, err := r.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
 				Snapshot:          clusterSnapshot,
 				ShardID:           shardNode.ID,
 				OldLeaderNodeName: shardNode.NodeName,
 				NewLeaderNodeName: newLeaderNode.Node.Name,
+				Reason:            coordinator.TransferLeaderReasonRebalance,
 			})
 			if err != nil {
 				return emptySchedulerRes, err
@@ -160,9 +194,15 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 		}
 
 		shardID := storage.ShardID(id)
+		if clusterSnapshot.IsShardUnderMaintenance(shardID) {
+			continue
+		}
 		if _, assigned := assignedShardIDs[shardID]; !assigned {
-			node, ok := r.latestShardNodeMapping[shardID]
-			assert.Assert(ok)
+			node, ok := shardNodeMapping[shardID]
+			if !ok {
+				r.logger.Warn("rebalanced shard scheduler leaves shard unassigned, no compatible node is found", zap.Uint32("shardID", id), zap.String("minNodeVersion", clusterSnapshot.ShardMinNodeVersions[shardID]))
+				continue
+			}
 
 			r.logger.Info("rebalanced shard scheduler try to assign unassigned shard to node", zap.Uint32("shardID", id), zap.String("node", node.Node.Name))
 			p, err := r.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
@@ -170,6 +210,7 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 				ShardID:           shardID,
 				OldLeaderNodeName: "",
 				NewLeaderNodeName: node.Node.Name,
+				Reason:            coordinator.TransferLeaderReasonFailover,
 			})
 			if err != nil {
 				return emptySchedulerRes, err
@@ -185,8 +226,9 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 	}
 
 	batchProcedure, err := r.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
-		Batch:     procedures,
-		BatchType: procedure.TransferLeader,
+		Batch:       procedures,
+		BatchType:   procedure.TransferLeader,
+		Concurrency: r.procedureExecutingBatchSize,
 	})
 	if err != nil {
 		return emptySchedulerRes, err
@@ -196,23 +238,14 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 }
 
 func (r *schedulerImpl) generateLatestShardNodeMapping(ctx context.Context, snapshot metadata.Snapshot) (map[storage.ShardID]metadata.RegisteredNode, error) {
-	numShards := uint32(len(snapshot.Topology.ShardViewsMapping))
 	// TODO: Improve scheduling efficiency and verify whether the topology changes.
-	shardIDs := make([]storage.ShardID, 0, numShards)
-	for shardID := range snapshot.Topology.ShardViewsMapping {
-		shardIDs = append(shardIDs, shardID)
-	}
-
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	var err error
 	shardNodeMapping := r.latestShardNodeMapping
+	preferredNodes := r.preferredLeaderTracker.StablePreferredNodes(snapshot, time.Now())
 	if !r.enableSchedule {
-		pickConfig := nodepicker.Config{
-			NumTotalShards:    numShards,
-			ShardAffinityRule: maps.Clone(r.shardAffinityRule),
-		}
-		shardNodeMapping, err = r.nodePicker.PickNode(ctx, pickConfig, shardIDs, snapshot.RegisteredNodes)
+		shardNodeMapping, err = PickShardNodeMapping(ctx, r.nodePicker, snapshot, maps.Clone(r.shardAffinityRule), preferredNodes)
 		if err != nil {
 			return nil, err
 		}
@@ -222,6 +255,38 @@ func (r *schedulerImpl) generateLatestShardNodeMapping(ctx context.Context, snap
 	return shardNodeMapping, nil
 }
 
+// PickShardNodeMapping picks the leader node for all the shards of the snapshot in the way of the rebalanced scheduler.
+// The shards without any compatible node are left out of the result.
+func PickShardNodeMapping(ctx context.Context, nodePicker nodepicker.NodePicker, snapshot metadata.Snapshot, shardAffinityRule map[storage.ShardID]scheduler.ShardAffinity, preferredNodes map[storage.ShardID]string) (map[storage.ShardID]metadata.RegisteredNode, error) {
+	numShards := uint32(len(snapshot.Topology.ShardViewsMapping))
+	shardIDs := make([]storage.ShardID, 0, numShards)
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardIDs = append(shardIDs, shardID)
+	}
+
+	pickConfig := nodepicker.Config{
+		NumTotalShards:       numShards,
+		ShardAffinityRule:    shardAffinityRule,
+		ShardMinNodeVersions: snapshot.ShardMinNodeVersions,
+		PreferredNodes:       preferredNodes,
+		ShardPermutation:     snapshot.ShardPermutation,
+	}
+	shardNodeMapping, err := nodePicker.PickNode(ctx, pickConfig, shardIDs, snapshot.RegisteredNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The node picker only balances the shards roughly, so the leaders are evened out among the replicas before being adopted.
+	pinnedShards := make(map[storage.ShardID]struct{}, len(shardAffinityRule)+len(preferredNodes))
+	for shardID := range shardAffinityRule {
+		pinnedShards[shardID] = struct{}{}
+	}
+	for shardID := range preferredNodes {
+		pinnedShards[shardID] = struct{}{}
+	}
+	return balanceLeaders(snapshot, shardNodeMapping, pinnedShards), nil
+}
+
 func (r *schedulerImpl) updateEnableSchedule(enableSchedule bool) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -21,12 +21,17 @@ package rebalanced_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/rebalanced"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -37,23 +42,175 @@ func TestRebalancedScheduler(t *testing.T) {
 
 	// EmptyCluster would be scheduled an empty procedure.
 	emptyCluster := test.InitEmptyCluster(ctx, t)
-	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata())
-	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	result, err := s.Schedule(ctx, emptyCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 	re.Empty(result)
 
 	// PrepareCluster would be scheduled an empty procedure.
 	prepareCluster := test.InitPrepareCluster(ctx, t)
-	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata())
-	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	_, err = s.Schedule(ctx, prepareCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 
 	// StableCluster with all shards assigned would be scheduled a load balance procedure.
 	stableCluster := test.InitStableCluster(ctx, t)
-	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), stableCluster.GetMetadata())
-	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), stableCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	_, err = s.Schedule(ctx, stableCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 }
+
+func TestRebalancedSchedulerBalanceLeaders(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	nodeNumber, shardNumber := 4, 8
+	c := test.InitEmptyClusterWithConfig(ctx, t, shardNumber, nodeNumber)
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	leaderNode := snapshot.RegisteredNodes[0].Node.Name
+
+	// All the leaders are held by a single node, and no other node holds a replica of the shards.
+	shardNodes := make([]storage.ShardNode, 0, shardNumber*nodeNumber)
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  leaderNode,
+		})
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, fixedNodePicker{nodeName: leaderNode}, test.DefaultProcedureExecutingBatchSize, scheduler.NewPreferredLeaderTracker(0))
+	result, err := s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	// The placement of the node picker is kept, because the leadership can't be moved without a follower.
+	re.Nil(result.Procedure)
+
+	// Every other node holds a follower of all the shards.
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		for _, node := range snapshot.RegisteredNodes[1:] {
+			shardNodes = append(shardNodes, storage.ShardNode{
+				ID:        shardID,
+				ShardRole: storage.ShardRoleFollower,
+				NodeName:  node.Node.Name,
+			})
+		}
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+
+	// The leaders should be spread out evenly, and the node keeps its share of the leaders.
+	expectMoved := shardNumber - shardNumber/nodeNumber
+	re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, expectMoved)
+	re.Equal(expectMoved, strings.Count(result.Reason, fmt.Sprintf("oldNode:%s,", leaderNode)))
+	re.Equal(0, strings.Count(result.Reason, fmt.Sprintf("newNode:%s\n", leaderNode)))
+	for _, node := range snapshot.RegisteredNodes[1:] {
+		re.Equal(shardNumber/nodeNumber, strings.Count(result.Reason, fmt.Sprintf("newNode:%s\n", node.Node.Name)))
+	}
+}
+
+// fixedNodePicker assigns all the shards to the registered node with the name.
+type fixedNodePicker struct {
+	nodeName string
+}
+
+func (p fixedNodePicker) PickNode(_ context.Context, _ nodepicker.Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode) (map[storage.ShardID]metadata.RegisteredNode, error) {
+	shardNodes := make(map[storage.ShardID]metadata.RegisteredNode, len(shardIDs))
+	for _, node := range registerNodes {
+		if node.Node.Name != p.nodeName {
+			continue
+		}
+		for _, shardID := range shardIDs {
+			shardNodes[shardID] = node
+		}
+	}
+	return shardNodes, nil
+}
+
+func TestRebalancedSchedulerSkipMaintenanceShards(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	oldNode, newNode := snapshot.RegisteredNodes[0].Node.Name, snapshot.RegisteredNodes[1].Node.Name
+
+	// All the leaders are held by the old node, and the picker moves them to the new node.
+	shardNodes := make([]storage.ShardNode, 0, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  oldNode,
+		})
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, fixedNodePicker{nodeName: newNode}, test.DefaultProcedureExecutingBatchSize, scheduler.NewPreferredLeaderTracker(0))
+
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+
+	// The shards under maintenance are skipped.
+	snapshot.MaintenanceShards = make(map[storage.ShardID]string, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		snapshot.MaintenanceShards[shardID] = "test"
+	}
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
+
+func TestRebalancedSchedulerOscillatingShards(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	oldNode, newNode := snapshot.RegisteredNodes[0].Node.Name, snapshot.RegisteredNodes[1].Node.Name
+
+	// All the leaders are held by the old node, and the picker moves them to the new node.
+	shardNodes := make([]storage.ShardNode, 0, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  oldNode,
+		})
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, fixedNodePicker{nodeName: newNode}, test.DefaultProcedureExecutingBatchSize, scheduler.NewPreferredLeaderTracker(0))
+
+	// The oscillating shards are kept on the alive node.
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	snapshot.OscillatingShards = make(map[storage.ShardID]struct{}, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		snapshot.OscillatingShards[shardID] = struct{}{}
+	}
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// The oscillating shards are still moved off the dead node.
+	for i := range snapshot.RegisteredNodes {
+		if snapshot.RegisteredNodes[i].Node.Name == oldNode {
+			snapshot.RegisteredNodes[i].Node.LastTouchTime = 0
+		}
+	}
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, len(snapshot.Topology.ShardViewsMapping))
+}
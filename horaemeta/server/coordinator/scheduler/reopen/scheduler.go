@@ -53,6 +53,10 @@ func (r schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
 	// ReopenShardScheduler do not need enableSchedule.
 }
 
+func (r schedulerImpl) IsScheduleEnabled() bool {
+	return false
+}
+
 func (r schedulerImpl) AddShardAffinityRule(_ context.Context, _ scheduler.ShardAffinityRule) error {
 	return nil
 }
@@ -82,7 +86,7 @@ func (r schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 		}
 
 		for _, shardInfo := range registeredNode.ShardInfos {
-			if !needReopen(shardInfo) {
+			if !needReopen(clusterSnapshot, shardInfo) || clusterSnapshot.IsShardUnderMaintenance(shardInfo.ID) {
 				continue
 			}
 			p, err := r.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
@@ -90,6 +94,7 @@ func (r schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 				ShardID:           shardInfo.ID,
 				OldLeaderNodeName: "",
 				NewLeaderNodeName: registeredNode.Node.Name,
+				Reason:            coordinator.TransferLeaderReasonReopen,
 			})
 			if err != nil {
 				return scheduleRes, err
@@ -108,8 +113,9 @@ func (r schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	}
 
 	batchProcedure, err := r.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
-		Batch:     procedures,
-		BatchType: procedure.TransferLeader,
+		Batch:       procedures,
+		BatchType:   procedure.TransferLeader,
+		Concurrency: r.procedureExecutingBatchSize,
 	})
 	if err != nil {
 		return scheduleRes, err
@@ -122,6 +128,7 @@ func (r schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	return scheduleRes, nil
 }
 
-func needReopen(shardInfo metadata.ShardInfo) bool {
-	return shardInfo.Status == storage.ShardStatusPartialOpen
+// needReopen returns true if the shard is partially opened and such status is not accepted as ready.
+func needReopen(clusterSnapshot metadata.Snapshot, shardInfo metadata.ShardInfo) bool {
+	return shardInfo.Status == storage.ShardStatusPartialOpen && !clusterSnapshot.IsShardStatusReady(shardInfo.Status)
 }
@@ -37,7 +37,7 @@ func TestReopenShardScheduler(t *testing.T) {
 	ctx := context.Background()
 	emptyCluster := test.InitEmptyCluster(ctx, t)
 
-	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata())
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
 
 	s := reopen.NewShardScheduler(procedureFactory, 1)
 
@@ -69,4 +69,17 @@ func TestReopenShardScheduler(t *testing.T) {
 	result, err = s.Schedule(ctx, snapshot)
 	re.NoError(err)
 	re.NotNil(result.Procedure)
+
+	// Shard under maintenance should be skipped.
+	snapshot.MaintenanceShards = map[storage.ShardID]string{1: "test"}
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// Shard with partitionOpen status should be skipped if the status is accepted as ready.
+	snapshot.MaintenanceShards = map[storage.ShardID]string{}
+	snapshot.ReadyShardStatuses = []storage.ShardStatus{storage.ShardStatusReady, storage.ShardStatusPartialOpen}
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
 }
@@ -49,6 +49,8 @@ type Scheduler interface {
 	// UpdateEnableSchedule is used to update enableSchedule for scheduler,
 	// EnableSchedule means that the cluster topology is locked and the mapping between shards and nodes cannot be changed.
 	UpdateEnableSchedule(ctx context.Context, enable bool)
+	// IsScheduleEnabled returns the enableSchedule applied to the scheduler, and it is always false if the scheduler doesn't need enableSchedule.
+	IsScheduleEnabled() bool
 	AddShardAffinityRule(ctx context.Context, rule ShardAffinityRule) error
 	RemoveShardAffinityRule(ctx context.Context, shardID storage.ShardID) error
 	ListShardAffinityRule(ctx context.Context) (ShardAffinityRule, error)
@@ -27,6 +27,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
@@ -34,16 +35,19 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 type schedulerImpl struct {
 	factory                     *coordinator.Factory
 	nodePicker                  nodepicker.NodePicker
 	procedureExecutingBatchSize uint32
+	// preferredLeaderTracker filters out the preferred leaders whose nodes are not stable yet.
+	preferredLeaderTracker *scheduler.PreferredLeaderTracker
 }
 
-func NewShardScheduler(factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32) scheduler.Scheduler {
-	return schedulerImpl{factory: factory, nodePicker: nodePicker, procedureExecutingBatchSize: procedureExecutingBatchSize}
+func NewShardScheduler(factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32, preferredLeaderTracker *scheduler.PreferredLeaderTracker) scheduler.Scheduler {
+	return schedulerImpl{factory: factory, nodePicker: nodePicker, procedureExecutingBatchSize: procedureExecutingBatchSize, preferredLeaderTracker: preferredLeaderTracker}
 }
 
 func (s schedulerImpl) Name() string {
@@ -54,6 +58,10 @@ func (s schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
 	// StaticTopologyShardScheduler do not need EnableSchedule.
 }
 
+func (s schedulerImpl) IsScheduleEnabled() bool {
+	return false
+}
+
 func (s schedulerImpl) AddShardAffinityRule(_ context.Context, _ scheduler.ShardAffinityRule) error {
 	return ErrNotImplemented.WithCausef("static topology scheduler doesn't support shard affinity")
 }
@@ -79,14 +87,17 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 		unassignedShardIds := make([]storage.ShardID, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
 		for _, shardView := range clusterSnapshot.Topology.ShardViewsMapping {
 			_, exists := findNodeByShard(shardView.ShardID, clusterSnapshot.Topology.ClusterView.ShardNodes)
-			if exists {
+			if exists || clusterSnapshot.IsShardUnderMaintenance(shardView.ShardID) {
 				continue
 			}
 			unassignedShardIds = append(unassignedShardIds, shardView.ShardID)
 		}
 		pickConfig := nodepicker.Config{
-			NumTotalShards:    uint32(len(clusterSnapshot.Topology.ShardViewsMapping)),
-			ShardAffinityRule: map[storage.ShardID]scheduler.ShardAffinity{},
+			NumTotalShards:       uint32(len(clusterSnapshot.Topology.ShardViewsMapping)),
+			ShardAffinityRule:    map[storage.ShardID]scheduler.ShardAffinity{},
+			ShardMinNodeVersions: clusterSnapshot.ShardMinNodeVersions,
+			PreferredNodes:       s.preferredLeaderTracker.StablePreferredNodes(clusterSnapshot, time.Now()),
+			ShardPermutation:     clusterSnapshot.ShardPermutation,
 		}
 		// Assign shards
 		shardNodeMapping, err := s.nodePicker.PickNode(ctx, pickConfig, unassignedShardIds, clusterSnapshot.RegisteredNodes)
@@ -100,6 +111,7 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 				ShardID:           shardID,
 				OldLeaderNodeName: "",
 				NewLeaderNodeName: node.Node.Name,
+				Reason:            coordinator.TransferLeaderReasonFailover,
 			})
 			if err != nil {
 				return emptyScheduleRes, err
@@ -111,10 +123,16 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 			}
 		}
 	case storage.ClusterStateStable:
+		// The shards skipped because their assigned nodes are offline, they are reported to tell a stuck cluster from an idle one.
+		var noEligibleNodeShards []storage.ShardID
 		for i := 0; i < len(clusterSnapshot.Topology.ClusterView.ShardNodes); i++ {
 			shardNode := clusterSnapshot.Topology.ClusterView.ShardNodes[i]
+			if clusterSnapshot.IsShardUnderMaintenance(shardNode.ID) {
+				continue
+			}
 			node, err := findOnlineNodeByName(shardNode.NodeName, clusterSnapshot.RegisteredNodes)
 			if err != nil {
+				noEligibleNodeShards = append(noEligibleNodeShards, shardNode.ID)
 				continue
 			}
 			if !containsShard(node.ShardInfos, shardNode.ID) {
@@ -124,6 +142,7 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 					ShardID:           shardNode.ID,
 					OldLeaderNodeName: "",
 					NewLeaderNodeName: node.Node.Name,
+					Reason:            coordinator.TransferLeaderReasonReopen,
 				})
 				if err != nil {
 					return emptyScheduleRes, err
@@ -135,6 +154,9 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 				}
 			}
 		}
+		if len(noEligibleNodeShards) > 0 {
+			log.Warn("no eligible node for shards, skip scheduling them", zap.String("scheduler", s.Name()), zap.String("shardIDs", fmt.Sprintf("%v", noEligibleNodeShards)))
+		}
 	}
 
 	if len(procedures) == 0 {
@@ -142,8 +164,9 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	}
 
 	batchProcedure, err := s.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
-		Batch:     procedures,
-		BatchType: procedure.TransferLeader,
+		Batch:       procedures,
+		BatchType:   procedure.TransferLeader,
+		Concurrency: s.procedureExecutingBatchSize,
 	})
 	if err != nil {
 		return emptyScheduleRes, err
@@ -21,12 +21,18 @@ package static_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/static"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -37,25 +43,88 @@ func TestStaticTopologyScheduler(t *testing.T) {
 
 	// EmptyCluster would be scheduled an empty procedure.
 	emptyCluster := test.InitEmptyCluster(ctx, t)
-	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata())
-	s := static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s := static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	result, err := s.Schedule(ctx, emptyCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 	re.Empty(result)
 
 	// PrepareCluster would be scheduled a transfer leader procedure.
 	prepareCluster := test.InitPrepareCluster(ctx, t)
-	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata())
-	s = static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s = static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	result, err = s.Schedule(ctx, prepareCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 	re.NotEmpty(result)
 
 	// StableCluster with all shards assigned would be scheduled a transfer leader procedure by hash rule.
 	stableCluster := test.InitStableCluster(ctx, t)
-	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), stableCluster.GetMetadata())
-	s = static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), stableCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	s = static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1, scheduler.NewPreferredLeaderTracker(0))
 	result, err = s.Schedule(ctx, stableCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 	re.NotEmpty(result)
+
+	// The shards under maintenance are skipped.
+	snapshot := stableCluster.GetMetadata().GetClusterSnapshot()
+	snapshot.MaintenanceShards = make(map[storage.ShardID]string, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		snapshot.MaintenanceShards[shardID] = "test"
+	}
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Empty(result)
+
+	// StableCluster whose nodes are all offline would be scheduled nothing, and its shards are reported to have no eligible node.
+	snapshot = stableCluster.GetMetadata().GetClusterSnapshot()
+	offlineNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes))
+	for _, node := range snapshot.RegisteredNodes {
+		node.Node.LastTouchTime = 0
+		offlineNodes = append(offlineNodes, node)
+	}
+	snapshot.RegisteredNodes = offlineNodes
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Empty(result)
+	re.Len(snapshot.FindShardsWithoutEligibleNode(time.Now()), len(snapshot.Topology.ShardViewsMapping))
+}
+
+func TestStaticTopologySchedulerPreferredLeaderDelay(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	prepareCluster := test.InitPrepareCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata(), coordinator.NewLeastTableShardPicker())
+	newScheduler := func(delay time.Duration) scheduler.Scheduler {
+		return static.NewShardScheduler(procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), test.DefaultProcedureExecutingBatchSize, scheduler.NewPreferredLeaderTracker(delay))
+	}
+	assignedNode := func(result scheduler.ScheduleResult, nodes []metadata.RegisteredNode) string {
+		for _, node := range nodes {
+			if strings.Contains(result.Reason, fmt.Sprintf("shardID:0, nodeName:%s.", node.Node.Name)) {
+				return node.Node.Name
+			}
+		}
+		return ""
+	}
+
+	snapshot := prepareCluster.GetMetadata().GetClusterSnapshot()
+	result, err := newScheduler(time.Hour).Schedule(ctx, snapshot)
+	re.NoError(err)
+	hashNode := assignedNode(result, snapshot.RegisteredNodes)
+	re.NotEmpty(hashNode)
+
+	preferredNode := snapshot.RegisteredNodes[0].Node.Name
+	if preferredNode == hashNode {
+		preferredNode = snapshot.RegisteredNodes[1].Node.Name
+	}
+	snapshot.PreferredLeaders = map[storage.ShardID]string{0: preferredNode}
+
+	// The preferred leader is not honored until its node has been online for the stabilization delay.
+	result, err = newScheduler(time.Hour).Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Equal(hashNode, assignedNode(result, snapshot.RegisteredNodes))
+
+	result, err = newScheduler(0).Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Equal(preferredNode, assignedNode(result, snapshot.RegisteredNodes))
 }
@@ -22,6 +22,7 @@ package coordinator
 import (
 	"context"
 	"sort"
+	"strings"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -36,8 +37,67 @@ type ShardPicker interface {
 	PickShards(ctx context.Context, snapshot metadata.Snapshot, expectShardNum int) ([]storage.ShardNode, error)
 }
 
+const (
+	// ShardPickerLeastTable picks the shards with the least tables.
+	ShardPickerLeastTable = "least_table"
+	// DefaultShardPicker is used when no shard picker is specified for the cluster.
+	DefaultShardPicker = ShardPickerLeastTable
+)
+
+// shardPickers contains the registered shard pickers, shard picker name -> constructor.
+var shardPickers = map[string]func() ShardPicker{
+	ShardPickerLeastTable: NewLeastTableShardPicker,
+}
+
+// RegisteredShardPickers returns the names of the registered shard pickers in order.
+func RegisteredShardPickers() []string {
+	names := make([]string, 0, len(shardPickers))
+	for name := range shardPickers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewShardPicker creates the registered shard picker by its name, and the empty name stands for the DefaultShardPicker.
+func NewShardPicker(name string) (ShardPicker, error) {
+	if len(name) == 0 {
+		name = DefaultShardPicker
+	}
+	newPicker, ok := shardPickers[name]
+	if !ok {
+		return nil, ErrUnknownShardPicker.WithCausef("shard picker:%s, registered shard pickers:%v", name, RegisteredShardPickers())
+	}
+	return newPicker(), nil
+}
+
+// ParseShardPickers parses the shard pickers of the clusters formatted as `clusterName=shardPicker`, e.g. `defaultCluster=least_table`.
+func ParseShardPickers(rawPickers []string) (map[string]string, error) {
+	pickers := make(map[string]string, len(rawPickers))
+	for _, rawPicker := range rawPickers {
+		rawName, rawPickerName, ok := strings.Cut(rawPicker, "=")
+		if !ok {
+			return nil, ErrParseShardPicker.WithCausef("shard picker must be formatted as clusterName=shardPicker, shardPicker:%s", rawPicker)
+		}
+		clusterName, pickerName := strings.TrimSpace(rawName), strings.TrimSpace(rawPickerName)
+		if len(clusterName) == 0 {
+			return nil, ErrParseShardPicker.WithCausef("cluster name is empty, shardPicker:%s", rawPicker)
+		}
+		if _, ok := shardPickers[pickerName]; !ok {
+			return nil, ErrUnknownShardPicker.WithCausef("shard picker:%s, registered shard pickers:%v", rawPicker, RegisteredShardPickers())
+		}
+		if _, ok := pickers[clusterName]; ok {
+			return nil, ErrParseShardPicker.WithCausef("duplicate cluster name, clusterName:%s", clusterName)
+		}
+		pickers[clusterName] = pickerName
+	}
+	return pickers, nil
+}
+
 // LeastTableShardPicker selects shards based on the number of tables on the current shard,
-// and always selects the shard with the smallest number of current tables.
+// and selects the shards round-robin starting from the shard with the smallest number of current tables.
+// The table creations in flight are counted as the tables of the shards, and the shards whose creations in flight reach
+// the limit are skipped.
 type leastTableShardPicker struct{}
 
 func NewLeastTableShardPicker() ShardPicker {
@@ -50,32 +110,59 @@ func (l leastTableShardPicker) PickShards(_ context.Context, snapshot metadata.S
 	}
 
 	shardNodeMapping := make(map[storage.ShardID]storage.ShardNode, len(snapshot.Topology.ShardViewsMapping))
-	sortedShardsByTableCount := make([]storage.ShardID, 0, len(snapshot.Topology.ShardViewsMapping))
+	// Only collect the shards witch has been allocated to a node.
 	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
 		shardNodeMapping[shardNode.ID] = shardNode
-		// Only collect the shards witch has been allocated to a node.
-		sortedShardsByTableCount = append(sortedShardsByTableCount, shardNode.ID)
 	}
 
-	// Sort shard by table number,
-	// the shard with the smallest number of tables is at the front of the array.
-	sort.SliceStable(sortedShardsByTableCount, func(i, j int) bool {
-		shardView1 := snapshot.Topology.ShardViewsMapping[sortedShardsByTableCount[i]]
-		shardView2 := snapshot.Topology.ShardViewsMapping[sortedShardsByTableCount[j]]
-		// When the number of tables is the same, sort according to the size of ShardID.
-		if len(shardView1.TableIDs) == len(shardView2.TableIDs) {
-			return shardView1.ShardID < shardView2.ShardID
+	// The load of a shard is the number of its tables and the creations in flight on it.
+	inflightCreates := make(map[storage.ShardID]int, len(shardNodeMapping))
+	sortedShardsByLoad := make([]storage.ShardID, 0, len(shardNodeMapping))
+	loads := make(map[storage.ShardID]int, len(shardNodeMapping))
+	for shardID := range shardNodeMapping {
+		inflightCreates[shardID] = snapshot.InflightCreates[shardID]
+		loads[shardID] = len(snapshot.Topology.ShardViewsMapping[shardID].TableIDs) + inflightCreates[shardID]
+		sortedShardsByLoad = append(sortedShardsByLoad, shardID)
+	}
+	maxInflightCreates := int(snapshot.MaxInflightCreatesPerShard)
+
+	// Sort shard by load,
+	// the shard with the smallest load is at the front of the array.
+	sort.Slice(sortedShardsByLoad, func(i, j int) bool {
+		shardID1, shardID2 := sortedShardsByLoad[i], sortedShardsByLoad[j]
+		// When the load is the same, sort according to the size of ShardID.
+		if loads[shardID1] == loads[shardID2] {
+			return shardID1 < shardID2
 		}
-		return len(shardView1.TableIDs) < len(shardView2.TableIDs)
+		return loads[shardID1] < loads[shardID2]
 	})
 
 	result := make([]storage.ShardNode, 0, expectShardNum)
 
+	// The shards are picked round-robin from the least loaded one, and the shards whose creations in flight reach the limit,
+	// including the ones picked here, are skipped.
+	next := 0
 	for i := 0; i < expectShardNum; i++ {
-		selectShardID := sortedShardsByTableCount[i%len(sortedShardsByTableCount)]
+		var selectShardID storage.ShardID
+		found := false
+		for j := 0; j < len(sortedShardsByLoad); j++ {
+			shardID := sortedShardsByLoad[next%len(sortedShardsByLoad)]
+			next++
+			if maxInflightCreates > 0 && inflightCreates[shardID] >= maxInflightCreates {
+				continue
+			}
+			selectShardID = shardID
+			found = true
+			break
+		}
+		if !found {
+			return nil, ErrTooManyInflightCreates.WithCausef("max inflight creates per shard:%d", maxInflightCreates)
+		}
+
 		shardNode, ok := shardNodeMapping[selectShardID]
 		assert.Assert(ok)
 		result = append(result, shardNode)
+		inflightCreates[selectShardID]++
 	}
 
 	return result, nil
@@ -21,9 +21,11 @@ package coordinator_test
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"testing"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
@@ -99,6 +101,37 @@ func TestLeastTableShardPicker(t *testing.T) {
 	checkPartitionTable(ctx, shardPicker, t, 50, 256, 50, 2)
 }
 
+func TestLeastTableShardPickerSpreadsUnevenLoads(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	// Shard 0 has no table, and the other shards have some tables.
+	for shardID := storage.ShardID(1); shardID < test.DefaultShardTotal; shardID++ {
+		for i := 0; i < 3; i++ {
+			_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+				ShardID:       shardID,
+				LatestVersion: 0,
+				SchemaName:    test.TestSchemaName,
+				TableName:     fmt.Sprintf("table_%d_%d", shardID, i),
+				PartitionInfo: storage.PartitionInfo{Info: nil},
+			})
+			re.NoError(err)
+		}
+	}
+
+	// The sub tables are still spread over all the shards, starting from the least loaded one.
+	shardNodes, err := coordinator.NewLeastTableShardPicker().PickShards(ctx, c.GetMetadata().GetClusterSnapshot(), test.DefaultShardTotal)
+	re.NoError(err)
+	re.Len(shardNodes, test.DefaultShardTotal)
+	re.Equal(storage.ShardID(0), shardNodes[0].ID)
+	shardIDs := map[storage.ShardID]struct{}{}
+	for _, shardNode := range shardNodes {
+		shardIDs[shardNode.ID] = struct{}{}
+	}
+	re.Len(shardIDs, test.DefaultShardTotal)
+}
+
 func checkPartitionTable(ctx context.Context, shardPicker coordinator.ShardPicker, t *testing.T, nodeNumber int, shardNumber int, subTableNumber int, maxDifference int) {
 	re := require.New(t)
 
@@ -123,3 +156,31 @@ func checkPartitionTable(ctx context.Context, shardPicker coordinator.ShardPicke
 	maxTableNumber := nodeTableNumberSlice[len(nodeTableNumberSlice)-1]
 	re.LessOrEqual(maxTableNumber-minTableNumber, maxDifference)
 }
+
+func TestNewShardPicker(t *testing.T) {
+	re := require.New(t)
+
+	re.Contains(coordinator.RegisteredShardPickers(), coordinator.DefaultShardPicker)
+	for _, name := range []string{"", coordinator.ShardPickerLeastTable} {
+		shardPicker, err := coordinator.NewShardPicker(name)
+		re.NoError(err)
+		re.NotNil(shardPicker)
+	}
+	_, err := coordinator.NewShardPicker("unknown")
+	re.True(coderr.Is(err, coordinator.ErrUnknownShardPicker.Code()))
+}
+
+func TestParseShardPickers(t *testing.T) {
+	re := require.New(t)
+
+	pickers, err := coordinator.ParseShardPickers([]string{" cluster0 = least_table "})
+	re.NoError(err)
+	re.Equal(map[string]string{"cluster0": coordinator.ShardPickerLeastTable}, pickers)
+
+	_, err = coordinator.ParseShardPickers([]string{"cluster0=unknown"})
+	re.True(coderr.Is(err, coordinator.ErrUnknownShardPicker.Code()))
+	for _, rawPickers := range [][]string{{"cluster0"}, {"=least_table"}, {"cluster0=least_table", "cluster0=least_table"}} {
+		_, err = coordinator.ParseShardPickers(rawPickers)
+		re.True(coderr.Is(err, coordinator.ErrParseShardPicker.Code()), "rawPickers:%v", rawPickers)
+	}
+}
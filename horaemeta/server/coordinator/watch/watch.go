@@ -22,6 +22,7 @@ package watch
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -61,6 +62,25 @@ type ShardWatch interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
 	RegisteringEventCallback(eventCallback ShardEventCallback)
+	// Resync reads all the shard locks from etcd and compares them with the current leaders of the shards (shardID -> nodeName),
+	// and the callbacks are re-applied to the shards whose leader mismatches the lock holder.
+	Resync(ctx context.Context, currentLeaders map[storage.ShardID]string) (ResyncResult, error)
+}
+
+// ResyncResult describes the corrections applied by a resync of the shard watch.
+type ResyncResult struct {
+	// Locks is the number of the shard locks found in etcd.
+	Locks int `json:"locks"`
+	// Registered is the shards whose lock holder is not the current leader, on which the register callbacks are re-applied.
+	// They are not counted as corrections, because the leaders are only updated when the nodes report their shards.
+	Registered []storage.ShardID `json:"registered"`
+	// Expired is the shards on which the expire callbacks are re-applied, and their stale leaders are dropped.
+	Expired []storage.ShardID `json:"expired"`
+}
+
+// Corrections returns the number of the shards whose stale leaders are dropped by the resync.
+func (r ResyncResult) Corrections() int {
+	return len(r.Expired)
 }
 
 // EtcdShardWatch used to watch the distributed lock of shard, and provide the corresponding callback function.
@@ -92,6 +112,10 @@ func (n NoopShardWatch) Stop(_ context.Context) error {
 
 func (n NoopShardWatch) RegisteringEventCallback(_ ShardEventCallback) {}
 
+func (n NoopShardWatch) Resync(_ context.Context, _ map[storage.ShardID]string) (ResyncResult, error) {
+	return ResyncResult{Locks: 0, Registered: []storage.ShardID{}, Expired: []storage.ShardID{}}, nil
+}
+
 func NewEtcdShardWatch(logger *zap.Logger, clusterName string, rootPath string, client *clientv3.Client) ShardWatch {
 	return &EtcdShardWatch{
 		logger:         logger,
@@ -136,11 +160,90 @@ func (w *EtcdShardWatch) RegisteringEventCallback(eventCallback ShardEventCallba
 	w.eventCallbacks = append(w.eventCallbacks, eventCallback)
 }
 
+// Resync is used to recover from the events missed by the watch, e.g. during etcd disruptions.
+func (w *EtcdShardWatch) Resync(ctx context.Context, currentLeaders map[storage.ShardID]string) (ResyncResult, error) {
+	result := ResyncResult{Locks: 0, Registered: []storage.ShardID{}, Expired: []storage.ShardID{}}
+
+	shardKeyPrefix := encodeShardKeyPrefix(w.rootPath, w.clusterName, shardPath)
+	resp, err := w.etcdClient.Get(ctx, shardKeyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return result, errors.WithMessage(err, "get shard locks failed")
+	}
+
+	lockHolders := make(map[storage.ShardID]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		shardID, err := decodeShardKey(string(kv.Key))
+		if err != nil {
+			return result, err
+		}
+		shardLockValue, err := convertShardLockValueToPB(kv.Value)
+		if err != nil {
+			return result, err
+		}
+		lockHolders[storage.ShardID(shardID)] = shardLockValue.NodeName
+	}
+	result.Locks = len(lockHolders)
+
+	for shardID, leader := range currentLeaders {
+		holder, ok := lockHolders[shardID]
+		if ok && holder == leader {
+			continue
+		}
+		w.logger.Info("resync expired shard", zap.Uint32("shardID", uint32(shardID)), zap.String("oldLeader", leader), zap.String("lockHolder", holder))
+		if err := w.applyExpired(ctx, shardID, leader); err != nil {
+			return result, err
+		}
+		result.Expired = append(result.Expired, shardID)
+	}
+
+	for shardID, holder := range lockHolders {
+		if leader, ok := currentLeaders[shardID]; ok && holder == leader {
+			continue
+		}
+		w.logger.Info("resync registered shard", zap.Uint32("shardID", uint32(shardID)), zap.String("newLeader", holder))
+		if err := w.applyRegistered(ctx, shardID, holder); err != nil {
+			return result, err
+		}
+		result.Registered = append(result.Registered, shardID)
+	}
+
+	sort.Slice(result.Expired, func(i, j int) bool { return result.Expired[i] < result.Expired[j] })
+	sort.Slice(result.Registered, func(i, j int) bool { return result.Registered[i] < result.Registered[j] })
+	return result, nil
+}
+
+func (w *EtcdShardWatch) applyExpired(ctx context.Context, shardID storage.ShardID, oldLeader string) error {
+	for _, callback := range w.eventCallbacks {
+		if err := callback.OnShardExpired(ctx, ShardExpireEvent{
+			clusterName:   w.clusterName,
+			ShardID:       shardID,
+			OldLeaderNode: oldLeader,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *EtcdShardWatch) applyRegistered(ctx context.Context, shardID storage.ShardID, newLeader string) error {
+	for _, callback := range w.eventCallbacks {
+		if err := callback.OnShardRegistered(ctx, ShardRegisterEvent{
+			clusterName:   w.clusterName,
+			ShardID:       shardID,
+			NewLeaderNode: newLeader,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *EtcdShardWatch) startWatch(ctx context.Context, path string) error {
 	w.logger.Info("register shard watch", zap.String("watchPath", path))
+	// The cancel is set before the watch goroutine starts, so that Stop can always cancel it.
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
 	go func() {
-		ctxWithCancel, cancel := context.WithCancel(ctx)
-		w.cancel = cancel
 		respChan := w.etcdClient.Watch(ctxWithCancel, path, clientv3.WithPrefix(), clientv3.WithPrevKV())
 		for resp := range respChan {
 			for _, event := range resp.Events {
@@ -165,15 +268,7 @@ func (w *EtcdShardWatch) processEvent(ctx context.Context, event *clientv3.Event
 			return err
 		}
 		w.logger.Info("receive delete event", zap.String("preKV", fmt.Sprintf("%v", event.PrevKv)), zap.String("event", fmt.Sprintf("%v", event)), zap.Uint64("shardID", shardID), zap.String("oldLeader", shardLockValue.NodeName))
-		for _, callback := range w.eventCallbacks {
-			if err := callback.OnShardExpired(ctx, ShardExpireEvent{
-				clusterName:   w.clusterName,
-				ShardID:       storage.ShardID(shardID),
-				OldLeaderNode: shardLockValue.NodeName,
-			}); err != nil {
-				return err
-			}
-		}
+		return w.applyExpired(ctx, storage.ShardID(shardID), shardLockValue.NodeName)
 	case mvccpb.PUT:
 		shardID, err := decodeShardKey(string(event.Kv.Key))
 		if err != nil {
@@ -184,15 +279,7 @@ func (w *EtcdShardWatch) processEvent(ctx context.Context, event *clientv3.Event
 			return err
 		}
 		w.logger.Info("receive put event", zap.String("event", fmt.Sprintf("%v", event)), zap.Uint64("shardID", shardID), zap.String("oldLeader", shardLockValue.NodeName))
-		for _, callback := range w.eventCallbacks {
-			if err := callback.OnShardRegistered(ctx, ShardRegisterEvent{
-				clusterName:   w.clusterName,
-				ShardID:       storage.ShardID(shardID),
-				NewLeaderNode: shardLockValue.NodeName,
-			}); err != nil {
-				return err
-			}
-		}
+		return w.applyRegistered(ctx, storage.ShardID(shardID), shardLockValue.NodeName)
 	}
 	return nil
 }
@@ -73,6 +73,60 @@ func TestWatch(t *testing.T) {
 	re.Equal(1, testCallback.result)
 }
 
+func TestResync(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
+	watch := NewEtcdShardWatch(zap.NewNop(), TestClusterName, TestRootPath, client)
+	callback := &recordShardEventCallback{
+		registered: map[storage.ShardID]string{},
+		expired:    map[storage.ShardID]string{},
+	}
+	watch.RegisteringEventCallback(callback)
+
+	putShardLock := func(shardID uint64, nodeName string) {
+		b, err := proto.Marshal(&metaeventpb.ShardLockValue{NodeName: nodeName})
+		re.NoError(err)
+		_, err = client.Put(ctx, encodeShardKey(TestRootPath, TestClusterName, TestShardPath, shardID), string(b))
+		re.NoError(err)
+	}
+	// Shard 0 is consistent, shard 1 moves to node1, shard 2 has no leader in meta, and the lock of shard 3 is missing.
+	putShardLock(0, "node0")
+	putShardLock(1, "node1")
+	putShardLock(2, "node2")
+	currentLeaders := map[storage.ShardID]string{0: "node0", 1: "node0", 3: "node3"}
+
+	result, err := watch.Resync(ctx, currentLeaders)
+	re.NoError(err)
+	re.Equal(3, result.Locks)
+	re.Equal([]storage.ShardID{1, 2}, result.Registered)
+	re.Equal([]storage.ShardID{1, 3}, result.Expired)
+	re.Equal(2, result.Corrections())
+	re.Equal(map[storage.ShardID]string{1: "node1", 2: "node2"}, callback.registered)
+	re.Equal(map[storage.ShardID]string{1: "node0", 3: "node3"}, callback.expired)
+
+	// Nothing is corrected if meta is consistent with etcd.
+	result, err = watch.Resync(ctx, map[storage.ShardID]string{0: "node0", 1: "node1", 2: "node2"})
+	re.NoError(err)
+	re.Equal(0, result.Corrections())
+}
+
+type recordShardEventCallback struct {
+	registered map[storage.ShardID]string
+	expired    map[storage.ShardID]string
+}
+
+func (c *recordShardEventCallback) OnShardRegistered(_ context.Context, event ShardRegisterEvent) error {
+	c.registered[event.ShardID] = event.NewLeaderNode
+	return nil
+}
+
+func (c *recordShardEventCallback) OnShardExpired(_ context.Context, event ShardExpireEvent) error {
+	c.expired[event.ShardID] = event.OldLeaderNode
+	return nil
+}
+
 type testShardEventCallback struct {
 	result int
 	re     *require.Assertions
@@ -0,0 +1,269 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package etcdutil
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/etcdserver"
+	"go.etcd.io/etcd/server/v3/etcdserver/api/v3compactor"
+	"go.uber.org/zap"
+)
+
+// CompactionConfig is the auto-compaction config of the embedded etcd, and the runtime retention tightens the configured one.
+type CompactionConfig struct {
+	Mode      string `json:"mode"`
+	Retention string `json:"retention"`
+	// RuntimeRetention is the retention updated at runtime, empty means only the configured retention takes effect.
+	RuntimeRetention string `json:"runtimeRetention"`
+}
+
+// compactionRetentionKey is the key of the runtime retention of the auto-compaction under the root path.
+const compactionRetentionKey = "etcd_compaction_retention"
+
+// compactionWatchRetryInterval is the interval to retry watching the runtime retention after the watch is broken.
+const compactionWatchRetryInterval = time.Second * 5
+
+// ParseCompactionRetention parses the retention with the same rules as etcd:
+// an integer is treated as hours in periodic mode or as revisions in revision mode, and a duration string is only allowed in periodic mode.
+func ParseCompactionRetention(mode, retention string) (time.Duration, error) {
+	switch mode {
+	case v3compactor.ModePeriodic, v3compactor.ModeRevision:
+	default:
+		return 0, ErrInvalidCompactionConfig.WithCausef("unknown compaction mode:%s", mode)
+	}
+
+	if h, err := strconv.Atoi(retention); err == nil {
+		if h < 0 {
+			return 0, ErrInvalidCompactionConfig.WithCausef("negative compaction retention:%s", retention)
+		}
+		if mode == v3compactor.ModeRevision {
+			return time.Duration(int64(h)), nil
+		}
+		return time.Duration(int64(h)) * time.Hour, nil
+	}
+
+	if mode == v3compactor.ModeRevision {
+		return 0, ErrInvalidCompactionConfig.WithCausef("compaction retention must be an integer in revision mode, retention:%s", retention)
+	}
+	d, err := time.ParseDuration(retention)
+	if err != nil {
+		return 0, ErrInvalidCompactionConfig.WithCausef("parse compaction retention, retention:%s, err:%v", retention, err)
+	}
+	if d < 0 {
+		return 0, ErrInvalidCompactionConfig.WithCausef("negative compaction retention:%s", retention)
+	}
+	return d, nil
+}
+
+// CompactionController applies the runtime retention of the auto-compaction of the embedded etcd. The runtime retention is persisted in
+// etcd, so that it is applied by all the members, and survives the restarts and the leader changes.
+// The auto-compaction configured for the embedded etcd is kept as is, and the tighter retention of the two compactors takes effect, so the
+// runtime retention must not be longer than the configured one.
+type CompactionController struct {
+	logger *zap.Logger
+	server *etcdserver.EtcdServer
+	// key is the key of the runtime retention persisted in etcd.
+	key string
+
+	// lock is used to protect the following fields.
+	lock      sync.Mutex
+	config    CompactionConfig
+	compactor v3compactor.Compactor
+}
+
+func NewCompactionController(logger *zap.Logger, server *etcdserver.EtcdServer, rootPath string, config CompactionConfig) *CompactionController {
+	return &CompactionController{
+		logger:    logger,
+		server:    server,
+		key:       path.Join(rootPath, compactionRetentionKey),
+		lock:      sync.Mutex{},
+		config:    config,
+		compactor: nil,
+	}
+}
+
+func (c *CompactionController) Stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.compactor != nil {
+		c.compactor.Stop()
+		c.compactor = nil
+	}
+}
+
+func (c *CompactionController) GetConfig() CompactionConfig {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.config
+}
+
+// ValidateRuntimeRetention checks the runtime retention, which must not be longer than the configured retention. The empty retention
+// clears the runtime retention.
+func (c *CompactionController) ValidateRuntimeRetention(retention string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, err := c.parseRuntimeRetentionWithLock(retention)
+	return err
+}
+
+// PutRuntimeRetention persists the runtime retention, and applies it to the local member at once. The other members apply it once they
+// watch the change.
+func (c *CompactionController) PutRuntimeRetention(ctx context.Context, client *clientv3.Client, retention string) error {
+	if err := c.ValidateRuntimeRetention(retention); err != nil {
+		return err
+	}
+
+	var err error
+	if len(retention) == 0 {
+		_, err = client.Delete(ctx, c.key)
+	} else {
+		_, err = client.Put(ctx, c.key, retention)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "persist compaction retention, key:%s", c.key)
+	}
+
+	return c.applyRuntimeRetention(retention)
+}
+
+// Watch applies the runtime retention persisted and its subsequent changes until the ctx is done.
+func (c *CompactionController) Watch(ctx context.Context, client *clientv3.Client) {
+	for {
+		if err := c.watchOnce(ctx, client); err != nil {
+			c.logger.Warn("watch compaction retention failed", zap.String("key", c.key), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(compactionWatchRetryInterval):
+		}
+	}
+}
+
+func (c *CompactionController) watchOnce(ctx context.Context, client *clientv3.Client) error {
+	resp, err := client.Get(ctx, c.key)
+	if err != nil {
+		return errors.WithMessage(err, "get compaction retention")
+	}
+	retention := ""
+	if len(resp.Kvs) > 0 {
+		retention = string(resp.Kvs[0].Value)
+	}
+	c.applyPersistedRetention(retention)
+
+	watchChan := client.Watch(ctx, c.key, clientv3.WithRev(resp.Header.Revision+1))
+	for watchResp := range watchChan {
+		if err := watchResp.Err(); err != nil {
+			return errors.WithMessage(err, "watch compaction retention")
+		}
+		for _, event := range watchResp.Events {
+			retention := ""
+			if event.Type == clientv3.EventTypePut {
+				retention = string(event.Kv.Value)
+			}
+			c.applyPersistedRetention(retention)
+		}
+	}
+	return nil
+}
+
+func (c *CompactionController) applyPersistedRetention(retention string) {
+	if err := c.applyRuntimeRetention(retention); err != nil {
+		c.logger.Error("apply persisted compaction retention failed", zap.String("retention", retention), zap.Error(err))
+	}
+}
+
+// applyRuntimeRetention restarts the compaction with the runtime retention, and the empty retention stops it.
+func (c *CompactionController) applyRuntimeRetention(retention string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if retention == c.config.RuntimeRetention {
+		return nil
+	}
+
+	parsedRetention, err := c.parseRuntimeRetentionWithLock(retention)
+	if err != nil {
+		return err
+	}
+
+	var newCompactor v3compactor.Compactor
+	if parsedRetention > 0 {
+		newCompactor, err = v3compactor.New(c.logger, c.config.Mode, parsedRetention, c.server.KV(), leaderCompactable{server: c.server})
+		if err != nil {
+			return ErrInvalidCompactionConfig.WithCause(err)
+		}
+	}
+
+	if c.compactor != nil {
+		c.compactor.Stop()
+	}
+	c.compactor = newCompactor
+	if c.compactor != nil {
+		c.compactor.Run()
+	}
+
+	c.logger.Info("compaction runtime retention is updated", zap.String("oldRetention", c.config.RuntimeRetention), zap.String("newRetention", retention))
+	c.config.RuntimeRetention = retention
+	return nil
+}
+
+func (c *CompactionController) parseRuntimeRetentionWithLock(retention string) (time.Duration, error) {
+	if len(retention) == 0 {
+		return 0, nil
+	}
+
+	parsedRetention, err := ParseCompactionRetention(c.config.Mode, retention)
+	if err != nil {
+		return 0, err
+	}
+	configuredRetention, err := ParseCompactionRetention(c.config.Mode, c.config.Retention)
+	if err != nil {
+		return 0, err
+	}
+	if configuredRetention > 0 && parsedRetention > configuredRetention {
+		return 0, ErrInvalidCompactionConfig.WithCausef("runtime retention must not be longer than the configured retention, retention:%s, configuredRetention:%s", retention, c.config.Retention)
+	}
+	return parsedRetention, nil
+}
+
+// leaderCompactable only issues the compaction on the etcd leader, just like the compactor inside etcd.
+type leaderCompactable struct {
+	server *etcdserver.EtcdServer
+}
+
+func (l leaderCompactable) Compact(ctx context.Context, r *pb.CompactionRequest) (*pb.CompactionResponse, error) {
+	if l.server.Lead() != uint64(l.server.ID()) {
+		return &pb.CompactionResponse{}, nil
+	}
+	return l.server.Compact(ctx, r)
+}
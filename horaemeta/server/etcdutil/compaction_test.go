@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package etcdutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseCompactionRetention(t *testing.T) {
+	re := require.New(t)
+
+	retention, err := ParseCompactionRetention("periodic", "2")
+	re.NoError(err)
+	re.Equal(2*time.Hour, retention)
+
+	retention, err = ParseCompactionRetention("periodic", "30m")
+	re.NoError(err)
+	re.Equal(30*time.Minute, retention)
+
+	retention, err = ParseCompactionRetention("revision", "1000")
+	re.NoError(err)
+	re.Equal(time.Duration(1000), retention)
+
+	retention, err = ParseCompactionRetention("periodic", "0")
+	re.NoError(err)
+	re.Equal(time.Duration(0), retention)
+
+	_, err = ParseCompactionRetention("revision", "30m")
+	re.Error(err)
+	_, err = ParseCompactionRetention("periodic", "-1")
+	re.Error(err)
+	_, err = ParseCompactionRetention("periodic", "abc")
+	re.Error(err)
+	_, err = ParseCompactionRetention("unknown", "1")
+	re.Error(err)
+}
+
+func TestCompactionRuntimeRetention(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	etcd, client, closeSrv := PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+
+	config := CompactionConfig{Mode: "periodic", Retention: "1h", RuntimeRetention: ""}
+	controller := NewCompactionController(zap.NewNop(), etcd.Server, "/rootPath", config)
+	defer controller.Stop()
+	// The other member applies the runtime retention by watching it.
+	watcher := NewCompactionController(zap.NewNop(), etcd.Server, "/rootPath", config)
+	defer watcher.Stop()
+	go watcher.Watch(ctx, client)
+
+	// The runtime retention can only tighten the configured retention.
+	re.Error(controller.ValidateRuntimeRetention("2h"))
+	re.Error(controller.PutRuntimeRetention(ctx, client, "abc"))
+	re.NoError(controller.ValidateRuntimeRetention("30m"))
+
+	re.NoError(controller.PutRuntimeRetention(ctx, client, "30m"))
+	re.Equal("30m", controller.GetConfig().RuntimeRetention)
+	re.Equal("1h", controller.GetConfig().Retention)
+	re.Eventually(func() bool {
+		return watcher.GetConfig().RuntimeRetention == "30m"
+	}, time.Second*5, time.Millisecond*10)
+
+	// The empty retention clears the runtime retention.
+	re.NoError(controller.PutRuntimeRetention(ctx, client, ""))
+	re.Empty(controller.GetConfig().RuntimeRetention)
+	re.Eventually(func() bool {
+		return watcher.GetConfig().RuntimeRetention == ""
+	}, time.Second*5, time.Millisecond*10)
+}
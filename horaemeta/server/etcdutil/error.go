@@ -25,4 +25,6 @@ var (
 	ErrEtcdKVGet         = coderr.NewCodeError(coderr.Internal, "etcd KV get failed")
 	ErrEtcdKVGetResponse = coderr.NewCodeError(coderr.Internal, "etcd invalid get value response must only one")
 	ErrEtcdKVGetNotFound = coderr.NewCodeError(coderr.Internal, "etcd KV get value not found")
+
+	ErrInvalidCompactionConfig = coderr.NewCodeError(coderr.BadRequest, "invalid etcd compaction config")
 )
@@ -26,4 +26,5 @@ var (
 	ErrAllocID             = coderr.NewCodeError(coderr.Internal, "alloc id")
 	ErrCollectID           = coderr.NewCodeError(coderr.Internal, "collect invalid id")
 	ErrCollectNotSupported = coderr.NewCodeError(coderr.Internal, "collect is not supported")
+	ErrSkipNotSupported    = coderr.NewCodeError(coderr.Internal, "skip is not supported")
 )
@@ -28,4 +28,7 @@ type Allocator interface {
 
 	// Collect collect unused id to reused in alloc
 	Collect(ctx context.Context, id uint64) error
+
+	// SkipTo skips the ids less than the next, and the ids allocated afterwards are not less than it.
+	SkipTo(ctx context.Context, next uint64) error
 }
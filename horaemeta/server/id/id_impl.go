@@ -92,6 +92,55 @@ func (a *AllocatorImpl) Collect(_ context.Context, _ uint64) error {
 	return ErrCollectNotSupported
 }
 
+func (a *AllocatorImpl) SkipTo(ctx context.Context, next uint64) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.isInitialized {
+		if err := a.slowRebaseLocked(ctx); err != nil {
+			return errors.WithMessage(err, "skip id")
+		}
+		a.isInitialized = true
+	}
+
+	if next <= a.base {
+		return nil
+	}
+	if next <= a.end {
+		a.base = next
+		return nil
+	}
+
+	if err := a.skipRebaseLocked(ctx, next); err != nil {
+		return errors.WithMessage(err, "skip id")
+	}
+	return nil
+}
+
+// skipRebaseLocked moves the end persisted in storage from the current end to the next, and allocates the ids from the next.
+func (a *AllocatorImpl) skipRebaseLocked(ctx context.Context, next uint64) error {
+	newEnd := next + uint64(a.allocStep)
+
+	endEquals := clientv3.Compare(clientv3.Value(a.key), "=", encodeID(a.end))
+	opPutEnd := clientv3.OpPut(a.key, encodeID(newEnd))
+
+	resp, err := a.kv.Txn(ctx).
+		If(endEquals).
+		Then(opPutEnd).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "put end id failed, key:%s, old value:%d, new value:%d", a.key, a.end, newEnd)
+	} else if !resp.Succeeded {
+		return ErrTxnPutEndID.WithCausef("txn put end id failed, endEquals failed, key:%s, value:%d, resp:%v", a.key, a.end, resp)
+	}
+
+	a.base = next
+	a.end = newEnd
+
+	a.logger.Info("Allocator skips to a new base id", zap.String("key", a.key), zap.Uint64("id", a.base))
+	return nil
+}
+
 func (a *AllocatorImpl) slowRebaseLocked(ctx context.Context) error {
 	resp, err := a.kv.Get(ctx, a.key)
 	if err != nil {
@@ -166,6 +215,22 @@ func (a *AllocatorImpl) doRebaseLocked(ctx context.Context, currEnd uint64) erro
 	return nil
 }
 
+// LoadEndID returns the end id persisted under the key, and all the ids allocated by the allocators with the key are less than it.
+// Zero is returned if no id is allocated yet.
+func LoadEndID(ctx context.Context, logger *zap.Logger, kv clientv3.KV, key string) (uint64, error) {
+	resp, err := kv.Get(ctx, key)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "get end id failed, key:%s", key)
+	}
+	if n := len(resp.Kvs); n > 1 {
+		return 0, etcdutil.ErrEtcdKVGetResponse.WithCausef("%v", resp.Kvs)
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return decodeID(logger, string(resp.Kvs[0].Value)), nil
+}
+
 func encodeID(value uint64) string {
 	return fmt.Sprintf("%d", value)
 }
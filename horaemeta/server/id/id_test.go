@@ -59,3 +59,61 @@ func testAllocIDValue(t *testing.T, kv clientv3.KV, start, size int) {
 		re.Equal(uint64(i), value)
 	}
 }
+
+func TestLoadEndID(t *testing.T) {
+	re := require.New(t)
+	_, kv, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	key := defaultRootPath + defaultAllocIDKey
+	end, err := LoadEndID(ctx, zap.NewNop(), kv, key)
+	re.NoError(err)
+	re.Equal(uint64(0), end)
+
+	alloc := NewAllocatorImpl(zap.NewNop(), kv, key, defaultStep)
+	for i := 0; i < defaultStep+1; i++ {
+		_, err := alloc.Alloc(ctx)
+		re.NoError(err)
+	}
+	end, err = LoadEndID(ctx, zap.NewNop(), kv, key)
+	re.NoError(err)
+	re.Equal(uint64(2*defaultStep), end)
+}
+
+func TestSkipTo(t *testing.T) {
+	re := require.New(t)
+	_, kv, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	key := defaultRootPath + defaultAllocIDKey
+	alloc := NewAllocatorImpl(zap.NewNop(), kv, key, defaultStep)
+	value, err := alloc.Alloc(ctx)
+	re.NoError(err)
+	re.Equal(uint64(0), value)
+
+	// Skip in the allocated batch, and skipping backwards does nothing.
+	re.NoError(alloc.SkipTo(ctx, 3))
+	re.NoError(alloc.SkipTo(ctx, 1))
+	value, err = alloc.Alloc(ctx)
+	re.NoError(err)
+	re.Equal(uint64(3), value)
+
+	// Skip beyond the allocated batch, and the end persisted in storage is moved forward.
+	next := uint64(10 * defaultStep)
+	re.NoError(alloc.SkipTo(ctx, next))
+	value, err = alloc.Alloc(ctx)
+	re.NoError(err)
+	re.Equal(next, value)
+	end, err := LoadEndID(ctx, zap.NewNop(), kv, key)
+	re.NoError(err)
+	re.Equal(next+defaultStep, end)
+
+	// The ids skipped are not allocated by the new allocator either.
+	value, err = NewAllocatorImpl(zap.NewNop(), kv, key, defaultStep).Alloc(ctx)
+	re.NoError(err)
+	re.Equal(next+defaultStep, value)
+}
@@ -109,3 +109,7 @@ func (a *ReusableAllocatorImpl) Collect(_ context.Context, id uint64) error {
 	a.existIDs.Remove(id)
 	return nil
 }
+
+func (a *ReusableAllocatorImpl) SkipTo(_ context.Context, _ uint64) error {
+	return ErrSkipNotSupported
+}
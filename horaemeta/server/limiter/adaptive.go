@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// adaptiveDecreaseFactor is applied to the multiplier once the backend is unhealthy, so that the pressure is relieved quickly.
+	adaptiveDecreaseFactor = 0.5
+	// adaptiveIncreaseStep is added to the multiplier once the backend is healthy, so that the limit is restored gradually.
+	adaptiveIncreaseStep = 0.1
+)
+
+type AdaptiveOptions struct {
+	// LatencyThreshold is the backend latency beyond which the backend is considered unhealthy.
+	LatencyThreshold time.Duration
+	// MinMultiplier is the lower bound of the multiplier applied to the limit.
+	MinMultiplier float64
+	// ProbeInterval is the interval of probing the backend latency.
+	ProbeInterval time.Duration
+}
+
+// AdaptiveController adjusts the effective rate of the flow limiter according to the measured backend latency: the rate is halved
+// whenever the latency exceeds the threshold, and restored step by step when the latency is back to normal.
+type AdaptiveController struct {
+	limiter *FlowLimiter
+	opts    AdaptiveOptions
+}
+
+func NewAdaptiveController(limiter *FlowLimiter, opts AdaptiveOptions) *AdaptiveController {
+	return &AdaptiveController{
+		limiter: limiter,
+		opts:    opts,
+	}
+}
+
+// Observe adjusts the multiplier of the flow limiter according to the backend latency, and returns the new multiplier.
+func (c *AdaptiveController) Observe(latency time.Duration) float64 {
+	current := c.limiter.AdaptiveMultiplier()
+
+	var next float64
+	if latency > c.opts.LatencyThreshold {
+		next = max(current*adaptiveDecreaseFactor, c.opts.MinMultiplier)
+	} else {
+		next = min(current+adaptiveIncreaseStep, 1)
+	}
+
+	if next != current {
+		log.Info("adjust adaptive multiplier of flow limiter", zap.Duration("latency", latency), zap.Float64("from", current), zap.Float64("to", next))
+		c.limiter.setAdaptiveMultiplier(next)
+	}
+	return next
+}
+
+// Run probes the backend periodically until the ctx is done, and the failed probe is treated as the one exceeding the threshold.
+func (c *AdaptiveController) Run(ctx context.Context, probe func(ctx context.Context) error) {
+	ticker := time.NewTicker(c.opts.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, c.opts.ProbeInterval)
+		start := time.Now()
+		err := probe(probeCtx)
+		latency := time.Since(start)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("probe backend latency failed", zap.Error(err))
+			latency = max(latency, c.opts.LatencyThreshold+1)
+		}
+		c.Observe(latency)
+	}
+}
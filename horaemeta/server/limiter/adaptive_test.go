@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdaptiveLimiter() *FlowLimiter {
+	return NewFlowLimiter(config.LimiterConfig{
+		Limit:  1000,
+		Burst:  1000,
+		Enable: true,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 1,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
+	})
+}
+
+func TestAdaptiveController(t *testing.T) {
+	re := require.New(t)
+	flowLimiter := newTestAdaptiveLimiter()
+	controller := NewAdaptiveController(flowLimiter, AdaptiveOptions{
+		LatencyThreshold: 100 * time.Millisecond,
+		MinMultiplier:    0.2,
+		ProbeInterval:    time.Second,
+	})
+	re.Equal(1.0, flowLimiter.AdaptiveMultiplier())
+
+	// The healthy backend keeps the limit unchanged.
+	re.Equal(1.0, controller.Observe(10*time.Millisecond))
+
+	// The slow backend halves the limit until the min multiplier is reached.
+	re.Equal(0.5, controller.Observe(200*time.Millisecond))
+	re.InDelta(500, float64(flowLimiter.l.Limit()), 1e-6)
+	re.Equal(0.25, controller.Observe(200*time.Millisecond))
+	re.Equal(0.2, controller.Observe(200*time.Millisecond))
+	re.Equal(0.2, controller.Observe(200*time.Millisecond))
+
+	// The multiplier is kept when the config is updated.
+	cfg := *flowLimiter.GetConfig()
+	cfg.Limit = 2000
+	re.NoError(flowLimiter.UpdateLimiter(cfg))
+	re.Equal(0.2, flowLimiter.AdaptiveMultiplier())
+	re.InDelta(400, float64(flowLimiter.l.Limit()), 1e-6)
+
+	// The limit is restored gradually when the backend is healthy again.
+	re.InDelta(0.3, controller.Observe(10*time.Millisecond), 1e-9)
+	for i := 0; i < 10; i++ {
+		controller.Observe(10 * time.Millisecond)
+	}
+	re.Equal(1.0, flowLimiter.AdaptiveMultiplier())
+	re.InDelta(2000, float64(flowLimiter.l.Limit()), 1e-6)
+}
+
+func TestAdaptiveControllerRun(t *testing.T) {
+	re := require.New(t)
+	flowLimiter := newTestAdaptiveLimiter()
+	controller := NewAdaptiveController(flowLimiter, AdaptiveOptions{
+		LatencyThreshold: time.Second,
+		MinMultiplier:    0.5,
+		ProbeInterval:    time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The failed probes are treated as the slow ones.
+		controller.Run(ctx, func(_ context.Context) error {
+			return errors.New("probe failed")
+		})
+	}()
+
+	re.Eventually(func() bool {
+		return flowLimiter.AdaptiveMultiplier() == 0.5
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
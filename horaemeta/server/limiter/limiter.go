@@ -21,11 +21,22 @@ package limiter
 
 import (
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/config"
 	"golang.org/x/time/rate"
 )
 
+// Operation is the kind of the operations limited by the flow limiter, and each kind consumes its own number of tokens.
+type Operation int
+
+const (
+	OperationCreateTable Operation = iota
+	OperationCreatePartitionTable
+	OperationDropTable
+	OperationRouteTables
+)
+
 type FlowLimiter struct {
 	// enable is used to control the switch of the limiter.
 	enable bool
@@ -36,6 +47,10 @@ type FlowLimiter struct {
 	limit int
 	// burst is the maximum number of tokens.
 	burst int
+	// costs is the number of tokens consumed by each kind of operations.
+	costs map[Operation]int
+	// adaptiveMultiplier scales the limit down when the backend is unhealthy, and it is always in (0, 1].
+	adaptiveMultiplier float64
 }
 
 func NewFlowLimiter(config config.LimiterConfig) *FlowLimiter {
@@ -47,6 +62,18 @@ func NewFlowLimiter(config config.LimiterConfig) *FlowLimiter {
 		lock:   sync.RWMutex{},
 		limit:  config.Limit,
 		burst:  config.Burst,
+		costs:  buildCosts(config),
+
+		adaptiveMultiplier: 1,
+	}
+}
+
+func buildCosts(config config.LimiterConfig) map[Operation]int {
+	return map[Operation]int{
+		OperationCreateTable:          config.CreateTableCost,
+		OperationCreatePartitionTable: config.CreatePartitionTableCost,
+		OperationDropTable:            config.DropTableCost,
+		OperationRouteTables:          config.RouteTablesCost,
 	}
 }
 
@@ -57,22 +84,110 @@ func (f *FlowLimiter) Allow() bool {
 	return f.l.Allow()
 }
 
+// AllowN tells whether n tokens can be consumed now.
+// ErrCostExceedsBurst is returned if n is larger than the burst, because such an operation would never be allowed.
+func (f *FlowLimiter) AllowN(n int) (bool, error) {
+	if !f.enable {
+		return true, nil
+	}
+
+	f.lock.RLock()
+	burst := f.burst
+	f.lock.RUnlock()
+	if n > burst {
+		return false, ErrCostExceedsBurst.WithCausef("cost:%d, burst:%d", n, burst)
+	}
+
+	return f.l.AllowN(time.Now(), n), nil
+}
+
+// Cost returns the number of tokens consumed by the operation, which is at least one.
+func (f *FlowLimiter) Cost(op Operation) int {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return max(f.costs[op], 1)
+}
+
+// IsOverloaded tells whether the tokens are used up, and no token is consumed by the check.
+func (f *FlowLimiter) IsOverloaded() bool {
+	if !f.enable {
+		return false
+	}
+	return f.l.Tokens() < 1
+}
+
 func (f *FlowLimiter) UpdateLimiter(config config.LimiterConfig) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	f.updateLimiterLocked(config)
+	return nil
+}
+
+// CompareAndUpdateLimiter updates the limiter with the config made from the current one by modify, so that the fields not touched
+// by modify are kept. If matches is not nil, the update is rejected with ErrLimiterConfigConflict unless the current config matches,
+// which prevents clobbering the concurrent updates. The effective config after the update is returned.
+func (f *FlowLimiter) CompareAndUpdateLimiter(matches func(config.LimiterConfig) bool, modify func(config.LimiterConfig) config.LimiterConfig) (config.LimiterConfig, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	current := f.getConfigLocked()
+	if matches != nil && !matches(current) {
+		return current, ErrLimiterConfigConflict.WithCausef("current:%+v", current)
+	}
+
+	newConfig := modify(current)
+	f.updateLimiterLocked(newConfig)
+	return newConfig, nil
+}
+
+func (f *FlowLimiter) updateLimiterLocked(config config.LimiterConfig) {
 	f.enable = config.Enable
-	f.l.SetLimit(rate.Limit(config.Limit))
+	f.l.SetLimit(effectiveLimit(config.Limit, f.adaptiveMultiplier))
 	f.l.SetBurst(config.Burst)
 	f.limit = config.Limit
 	f.burst = config.Burst
-	return nil
+	f.costs = buildCosts(config)
+}
+
+// AdaptiveMultiplier returns the current multiplier applied to the configured limit, and one means the limit is not reduced.
+func (f *FlowLimiter) AdaptiveMultiplier() float64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.adaptiveMultiplier
+}
+
+func (f *FlowLimiter) setAdaptiveMultiplier(multiplier float64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.adaptiveMultiplier = multiplier
+	f.l.SetLimit(effectiveLimit(f.limit, multiplier))
+}
+
+func effectiveLimit(limit int, multiplier float64) rate.Limit {
+	return rate.Limit(float64(limit) * multiplier)
 }
 
 func (f *FlowLimiter) GetConfig() *config.LimiterConfig {
-	return &config.LimiterConfig{
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	cfg := f.getConfigLocked()
+	return &cfg
+}
+
+func (f *FlowLimiter) getConfigLocked() config.LimiterConfig {
+	return config.LimiterConfig{
 		Enable: f.enable,
 		Limit:  f.limit,
 		Burst:  f.burst,
+
+		CreateTableCost:          f.costs[OperationCreateTable],
+		CreatePartitionTableCost: f.costs[OperationCreatePartitionTable],
+		DropTableCost:            f.costs[OperationDropTable],
+		RouteTablesCost:          f.costs[OperationRouteTables],
 	}
 }
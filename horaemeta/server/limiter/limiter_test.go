@@ -23,6 +23,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/config"
 	"github.com/stretchr/testify/require"
 )
@@ -41,6 +42,11 @@ func TestFlowLimiter(t *testing.T) {
 		Limit:  defaultInitialLimiterRate,
 		Burst:  defaultInitialLimiterCapacity,
 		Enable: defaultEnableLimiter,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 1,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
 	})
 
 	for i := 0; i < defaultInitialLimiterCapacity; i++ {
@@ -58,6 +64,11 @@ func TestFlowLimiter(t *testing.T) {
 		Limit:  defaultUpdateLimiterRate,
 		Burst:  defaultUpdateLimiterCapacity,
 		Enable: defaultEnableLimiter,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 1,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
 	})
 	re.NoError(err)
 
@@ -72,3 +83,120 @@ func TestFlowLimiter(t *testing.T) {
 		re.Equal(true, flag)
 	}
 }
+
+func TestFlowLimiterOverloaded(t *testing.T) {
+	re := require.New(t)
+	flowLimiter := NewFlowLimiter(config.LimiterConfig{
+		Limit:  1,
+		Burst:  2,
+		Enable: true,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 1,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
+	})
+
+	re.False(flowLimiter.IsOverloaded())
+	re.True(flowLimiter.Allow())
+	re.True(flowLimiter.Allow())
+	// The check itself must not consume any token.
+	re.True(flowLimiter.IsOverloaded())
+	re.True(flowLimiter.IsOverloaded())
+
+	// A disabled limiter is never overloaded.
+	err := flowLimiter.UpdateLimiter(config.LimiterConfig{
+		Limit:  1,
+		Burst:  2,
+		Enable: false,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 1,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
+	})
+	re.NoError(err)
+	re.False(flowLimiter.IsOverloaded())
+}
+
+func TestFlowLimiterCost(t *testing.T) {
+	re := require.New(t)
+	flowLimiter := NewFlowLimiter(config.LimiterConfig{
+		Limit:  1,
+		Burst:  10,
+		Enable: true,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 4,
+		DropTableCost:            0,
+		RouteTablesCost:          20,
+	})
+
+	re.Equal(1, flowLimiter.Cost(OperationCreateTable))
+	re.Equal(4, flowLimiter.Cost(OperationCreatePartitionTable))
+	// The cost is at least one.
+	re.Equal(1, flowLimiter.Cost(OperationDropTable))
+
+	// Two partition table creations consume 8 tokens, and the third one can't be allowed by the remaining ones.
+	for _, expected := range []bool{true, true, false} {
+		allowed, err := flowLimiter.AllowN(flowLimiter.Cost(OperationCreatePartitionTable))
+		re.NoError(err)
+		re.Equal(expected, allowed)
+	}
+	allowed, err := flowLimiter.AllowN(flowLimiter.Cost(OperationCreateTable))
+	re.NoError(err)
+	re.True(allowed)
+
+	// The cost larger than the burst is rejected even if the bucket is full, because it would never be allowed.
+	re.Equal(20, flowLimiter.GetConfig().RouteTablesCost)
+	limiterWithFullBucket := NewFlowLimiter(*flowLimiter.GetConfig())
+	allowed, err = limiterWithFullBucket.AllowN(limiterWithFullBucket.Cost(OperationRouteTables))
+	re.ErrorIs(err, ErrCostExceedsBurst)
+	re.False(allowed)
+	allowed, err = limiterWithFullBucket.AllowN(limiterWithFullBucket.GetConfig().Burst)
+	re.NoError(err)
+	re.True(allowed)
+}
+
+func TestFlowLimiterCompareAndUpdate(t *testing.T) {
+	re := require.New(t)
+	initialConfig := config.LimiterConfig{
+		Limit:  10,
+		Burst:  20,
+		Enable: true,
+
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 4,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
+	}
+	flowLimiter := NewFlowLimiter(initialConfig)
+
+	// Only the limit is changed, and the other fields are kept.
+	effectiveConfig, err := flowLimiter.CompareAndUpdateLimiter(nil, func(current config.LimiterConfig) config.LimiterConfig {
+		current.Limit = 100
+		return current
+	})
+	re.NoError(err)
+	expectConfig := initialConfig
+	expectConfig.Limit = 100
+	re.Equal(expectConfig, effectiveConfig)
+	re.Equal(expectConfig, *flowLimiter.GetConfig())
+
+	// The update based on the stale config is rejected.
+	_, err = flowLimiter.CompareAndUpdateLimiter(func(current config.LimiterConfig) bool { return current == initialConfig }, func(current config.LimiterConfig) config.LimiterConfig {
+		current.Burst = 200
+		return current
+	})
+	re.Error(err)
+	re.True(coderr.Is(err, ErrLimiterConfigConflict.Code()))
+	re.Equal(expectConfig, *flowLimiter.GetConfig())
+
+	effectiveConfig, err = flowLimiter.CompareAndUpdateLimiter(func(current config.LimiterConfig) bool { return current == expectConfig }, func(current config.LimiterConfig) config.LimiterConfig {
+		current.Burst = 200
+		return current
+	})
+	re.NoError(err)
+	re.Equal(200, effectiveConfig.Burst)
+	re.Equal(100, effectiveConfig.Limit)
+}
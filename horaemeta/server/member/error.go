@@ -31,4 +31,9 @@ var (
 	ErrGrantLease         = coderr.NewCodeError(coderr.Internal, "grant lease")
 	ErrRevokeLease        = coderr.NewCodeError(coderr.Internal, "revoke lease")
 	ErrCloseLease         = coderr.NewCodeError(coderr.Internal, "close lease")
+	ErrRenewLease         = coderr.NewCodeError(coderr.Internal, "renew lease")
+	ErrGetLeaseTTL        = coderr.NewCodeError(coderr.Internal, "get lease ttl")
+	ErrNotLeader          = coderr.NewCodeError(coderr.BadRequest, "local member is not the leader")
+	ErrMoveEtcdLeader     = coderr.NewCodeError(coderr.Internal, "move etcd leader")
+	ErrWaitNewLeader      = coderr.NewCodeError(coderr.Internal, "wait for new leader")
 )
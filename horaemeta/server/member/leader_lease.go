@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package member
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeaderLease describes the lease of the leader key held by the local leader.
+type LeaderLease struct {
+	ID int64
+	// GrantedTTLSec is the ttl the lease is granted with.
+	GrantedTTLSec int64
+	// RemainingTTLSec is the remaining ttl of the lease in etcd.
+	RemainingTTLSec int64
+	// ExpireTime is when the local leader considers the lease expired, and it is refreshed by every successful renewal.
+	ExpireTime time.Time
+}
+
+// GetLeaderLease returns the lease of the leader key, and ErrNotLeader is returned if the local member is not the leader.
+func (m *Member) GetLeaderLease(ctx context.Context) (LeaderLease, error) {
+	var emptyLease LeaderLease
+	l := m.leaderLease.Load()
+	if l == nil {
+		return emptyLease, ErrNotLeader.WithCausef("member:%s", m.Name)
+	}
+
+	ttl, err := l.timeToLive(ctx)
+	if err != nil {
+		return emptyLease, err
+	}
+	return LeaderLease{
+		ID:              int64(l.ID),
+		GrantedTTLSec:   l.ttlSec,
+		RemainingTTLSec: ttl,
+		ExpireTime:      l.getExpireTime(),
+	}, nil
+}
+
+// RenewLeaderLease renews the lease of the leader key once without waiting for the periodical renewal, and returns the renewed lease.
+func (m *Member) RenewLeaderLease(ctx context.Context) (LeaderLease, error) {
+	var emptyLease LeaderLease
+	l := m.leaderLease.Load()
+	if l == nil {
+		return emptyLease, ErrNotLeader.WithCausef("member:%s", m.Name)
+	}
+
+	m.logger.Info("force to renew leader lease", zap.Int64("lease-id", int64(l.ID)))
+	switch l.renewOnce(ctx) {
+	case renewLeaseAlive:
+	case renewLeaseExpired:
+		return emptyLease, ErrRenewLease.WithCausef("lease is expired, lease:%d", l.ID)
+	default:
+		return emptyLease, ErrRenewLease.WithCausef("keep alive failed, lease:%d", l.ID)
+	}
+	m.logger.Info("leader lease is renewed", zap.Int64("lease-id", int64(l.ID)), zap.Time("expired-at", l.getExpireTime()))
+
+	return m.GetLeaderLease(ctx)
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package member
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderLease(t *testing.T) {
+	re := require.New(t)
+	etcd, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+
+	watchCtx := &mockWatchCtx{
+		stopped: false,
+		client:  client,
+		srv:     etcd.Server,
+	}
+	rpcTimeout := time.Duration(10) * time.Second
+	leaseTTLSec := int64(5)
+	mem := NewMember("", 0, "mem0", "endpoint0", client, nil, rpcTimeout)
+
+	// The member has no lease before being elected.
+	ctx, cancelWatch := context.WithCancel(context.Background())
+	_, err := mem.GetLeaderLease(ctx)
+	re.True(coderr.Is(err, ErrNotLeader.Code()))
+	_, err = mem.RenewLeaderLease(ctx)
+	re.True(coderr.Is(err, ErrNotLeader.Code()))
+
+	watchedDone := make(chan struct{}, 1)
+	go func() {
+		NewLeaderWatcher(watchCtx, mem, leaseTTLSec, false).Watch(ctx, nil)
+		watchedDone <- struct{}{}
+	}()
+
+	var lease LeaderLease
+	re.Eventually(func() bool {
+		lease, err = mem.GetLeaderLease(ctx)
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	re.NotZero(lease.ID)
+	re.Equal(leaseTTLSec, lease.GrantedTTLSec)
+	re.Positive(lease.RemainingTTLSec)
+	re.LessOrEqual(lease.RemainingTTLSec, leaseTTLSec)
+	re.True(lease.ExpireTime.After(time.Now()))
+
+	// The renewed lease is the same one and doesn't expire earlier.
+	renewed, err := mem.RenewLeaderLease(ctx)
+	re.NoError(err)
+	re.Equal(lease.ID, renewed.ID)
+	re.False(renewed.ExpireTime.Before(lease.ExpireTime))
+
+	// The lease is cleared after the member loses the leadership.
+	cancelWatch()
+	<-watchedDone
+	_, err = mem.GetLeaderLease(context.Background())
+	re.True(coderr.Is(err, ErrNotLeader.Code()))
+}
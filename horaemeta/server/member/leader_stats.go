@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package member
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metastoragepb"
+)
+
+const (
+	// recentElectionWindow is the window in which the elections are considered recent.
+	recentElectionWindow = time.Hour
+	// maxRecordedElections bounds the number of the election times kept in memory.
+	maxRecordedElections = 1024
+)
+
+// LeaderStats describes the current leader and the elections observed by the member.
+type LeaderStats struct {
+	LeaderName     string
+	LeaderEndpoint string
+	IsLocal        bool
+	// LeaderSince is when the member observes the current leader for the first time, which is the time being elected for the local leader.
+	// It is zero if no leader is observed.
+	LeaderSince time.Time
+	// RecentElections is the number of the elections observed within the RecentElectionWindow.
+	RecentElections      int
+	RecentElectionWindow time.Duration
+	// TotalElections is the number of the elections observed since the member starts.
+	TotalElections uint64
+}
+
+// leaderStatsRecorder records the elections observed by the member, and every election is identified by the revision of the leader key.
+type leaderStatsRecorder struct {
+	lock           sync.Mutex
+	leader         *metastoragepb.Member
+	leaderRevision int64
+	leaderSince    time.Time
+	// electedAt contains the times of the recent elections in ascending order.
+	electedAt      []time.Time
+	totalElections uint64
+}
+
+func newLeaderStatsRecorder() *leaderStatsRecorder {
+	return &leaderStatsRecorder{
+		lock:           sync.Mutex{},
+		leader:         nil,
+		leaderRevision: 0,
+		leaderSince:    time.Time{},
+		electedAt:      []time.Time{},
+		totalElections: 0,
+	}
+}
+
+// observe records the leader with the revision of its leader key, and a new election is counted if the revision changes.
+func (r *leaderStatsRecorder) observe(leader *metastoragepb.Member, revision int64, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.leader != nil && r.leaderRevision == revision {
+		return
+	}
+
+	r.leader = leader
+	r.leaderRevision = revision
+	r.leaderSince = now
+	r.totalElections++
+	r.electedAt = append(r.electedAt, now)
+	if len(r.electedAt) > maxRecordedElections {
+		r.electedAt = r.electedAt[len(r.electedAt)-maxRecordedElections:]
+	}
+}
+
+func (r *leaderStatsRecorder) stats(selfEndpoint string, now time.Time) LeaderStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	recentElections := 0
+	for _, electedAt := range r.electedAt {
+		if now.Sub(electedAt) <= recentElectionWindow {
+			recentElections++
+		}
+	}
+
+	stats := LeaderStats{
+		LeaderName:           "",
+		LeaderEndpoint:       "",
+		IsLocal:              false,
+		LeaderSince:          r.leaderSince,
+		RecentElections:      recentElections,
+		RecentElectionWindow: recentElectionWindow,
+		TotalElections:       r.totalElections,
+	}
+	if r.leader != nil {
+		stats.LeaderName = r.leader.Name
+		stats.LeaderEndpoint = r.leader.Endpoint
+		stats.IsLocal = r.leader.Endpoint == selfEndpoint
+	}
+	return stats
+}
+
+// GetLeaderStats returns the current leader and the elections observed by the member.
+func (m *Member) GetLeaderStats() LeaderStats {
+	return m.leaderStats.stats(m.Endpoint, time.Now())
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package member
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metastoragepb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderStatsRecorder(t *testing.T) {
+	re := require.New(t)
+	recorder := newLeaderStatsRecorder()
+	now := time.Now()
+
+	stats := recorder.stats("127.0.0.1:2379", now)
+	re.Empty(stats.LeaderName)
+	re.True(stats.LeaderSince.IsZero())
+	re.Equal(0, stats.RecentElections)
+
+	mem0 := &metastoragepb.Member{Name: "mem0", Id: 0, Endpoint: "127.0.0.1:2379"}
+	mem1 := &metastoragepb.Member{Name: "mem1", Id: 1, Endpoint: "127.0.0.1:2380"}
+	recorder.observe(mem0, 10, now.Add(-2*recentElectionWindow))
+	// The same election observed again isn't counted.
+	recorder.observe(mem0, 10, now.Add(-recentElectionWindow/2))
+	recorder.observe(mem1, 20, now.Add(-time.Minute))
+	recorder.observe(mem0, 30, now.Add(-time.Second))
+
+	stats = recorder.stats("127.0.0.1:2379", now)
+	re.Equal("mem0", stats.LeaderName)
+	re.True(stats.IsLocal)
+	re.Equal(now.Add(-time.Second), stats.LeaderSince)
+	re.Equal(2, stats.RecentElections)
+	re.Equal(uint64(3), stats.TotalElections)
+}
@@ -181,6 +181,38 @@ func (l *lease) getExpireTime() time.Time {
 	return l.expireTime
 }
 
+// `renewOnce` renews the lease by calling `lease.KeepAliveOnce` once, and the l.expireTime is updated if the lease is alive.
+func (l *lease) renewOnce(ctx context.Context) renewLeaseResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	resp, err := l.rawLease.KeepAliveOnce(ctx, l.ID)
+	if err != nil {
+		l.logger.Error("lease keep alive failed", zap.Error(err))
+		return renewLeaseFailed
+	}
+	if resp.TTL < 0 {
+		l.logger.Warn("lease is expired")
+		return renewLeaseExpired
+	}
+
+	expireAt := start.Add(time.Duration(resp.TTL) * time.Second)
+	updated := l.setExpireTimeIfNewer(expireAt)
+	l.logger.Debug("got next expired time", zap.Time("expired-at", expireAt), zap.Bool("updated", updated))
+	return renewLeaseAlive
+}
+
+// timeToLive returns the remaining ttl of the lease in etcd, which is negative if the lease is expired or revoked.
+func (l *lease) timeToLive(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	resp, err := l.rawLease.TimeToLive(ctx, l.ID)
+	if err != nil {
+		return 0, ErrGetLeaseTTL.WithCause(err)
+	}
+	return resp.TTL, nil
+}
+
 // `renewLeaseBg` keeps the lease alive by periodically call `lease.KeepAliveOnce`.
 // The l.expireTime will be updated during renewing and the renew lease result (whether alive) will be told to caller by `renewed` channel.
 func (l *lease) renewLeaseBg(ctx context.Context, interval time.Duration, renewed chan<- bool) {
@@ -189,27 +221,7 @@ func (l *lease) renewLeaseBg(ctx context.Context, interval time.Duration, renewe
 
 L:
 	for {
-		renewOnce := func() renewLeaseResult {
-			start := time.Now()
-			ctx1, cancel := context.WithTimeout(ctx, l.timeout)
-			defer cancel()
-			resp, err := l.rawLease.KeepAliveOnce(ctx1, l.ID)
-			if err != nil {
-				l.logger.Error("lease keep alive failed", zap.Error(err))
-				return renewLeaseFailed
-			}
-			if resp.TTL < 0 {
-				l.logger.Warn("lease is expired")
-				return renewLeaseExpired
-			}
-
-			expireAt := start.Add(time.Duration(resp.TTL) * time.Second)
-			updated := l.setExpireTimeIfNewer(expireAt)
-			l.logger.Debug("got next expired time", zap.Time("expired-at", expireAt), zap.Bool("updated", updated))
-			return renewLeaseAlive
-		}
-
-		renewRes := renewOnce()
+		renewRes := l.renewOnce(ctx)
 
 		// Init the timer for next keep alive action.
 		t := time.After(interval)
@@ -23,6 +23,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -35,7 +36,11 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-const leaderCheckInterval = time.Duration(100) * time.Millisecond
+const (
+	leaderCheckInterval = time.Duration(100) * time.Millisecond
+	// waitNewLeaderInterval is the interval of checking whether a new leader is elected after stepping down.
+	waitNewLeaderInterval = time.Duration(100) * time.Millisecond
+)
 
 // Member manages the leadership and the role of the node in the horaemeta cluster.
 type Member struct {
@@ -49,6 +54,16 @@ type Member struct {
 	leader           *metastoragepb.Member
 	rpcTimeout       time.Duration
 	logger           *zap.Logger
+
+	// stepDownCh notifies the leader to give up the leadership.
+	stepDownCh chan struct{}
+	// campaignSuppressedUntil is the unix nano time before which the member won't campaign the leadership after
+	// stepping down, so that other members have the chance to take over the leadership.
+	campaignSuppressedUntil atomic.Int64
+	// leaderStats records the elections observed by the member.
+	leaderStats *leaderStatsRecorder
+	// leaderLease is the lease of the leader key, which is set only when the local member is the leader.
+	leaderLease atomic.Pointer[lease]
 }
 
 func formatLeaderKey(rootPath string) string {
@@ -69,6 +84,11 @@ func NewMember(rootPath string, id uint64, name, endpoint string, etcdCli *clien
 		leader:           nil,
 		rpcTimeout:       rpcTimeout,
 		logger:           logger,
+
+		stepDownCh:              make(chan struct{}, 1),
+		campaignSuppressedUntil: atomic.Int64{},
+		leaderStats:             newLeaderStatsRecorder(),
+		leaderLease:             atomic.Pointer[lease]{},
 	}
 }
 
@@ -219,6 +239,9 @@ func (m *Member) CampaignAndKeepLeader(ctx context.Context, leaseTTLSec int64, l
 		Id:       m.ID,
 		Endpoint: m.Endpoint,
 	}
+	m.leaderStats.observe(m.leader, resp.Header.Revision, time.Now())
+	m.leaderLease.Store(newLease)
+	defer m.leaderLease.CompareAndSwap(newLease, nil)
 
 	if callbacks != nil {
 		// The leader has been elected and trigger the callbacks.
@@ -229,6 +252,12 @@ func (m *Member) CampaignAndKeepLeader(ctx context.Context, leaseTTLSec int64, l
 		}()
 	}
 
+	// Drop the stale step down request made before being elected.
+	select {
+	case <-m.stepDownCh:
+	default:
+	}
+
 	// Keep the leadership by renewing the lease periodically after success in campaigning leader.
 	closeLeaseWg.Add(1)
 	go func() {
@@ -243,6 +272,11 @@ func (m *Member) CampaignAndKeepLeader(ctx context.Context, leaseTTLSec int64, l
 
 	for {
 		select {
+		case <-m.stepDownCh:
+			m.logger.Info("no longer a leader because of stepping down")
+			// The leader key is deleted when the lease is closed, and the cache is refreshed by the leader watcher.
+			m.leader = nil
+			return nil
 		case <-leaderCheckTicker.C:
 			if newLease.IsExpired() {
 				m.logger.Info("no longer a leader because lease has expired")
@@ -260,6 +294,84 @@ func (m *Member) CampaignAndKeepLeader(ctx context.Context, leaseTTLSec int64, l
 	}
 }
 
+// StepDown gives up the leadership of the local member, and returns the new leader once it is elected.
+// The local member won't campaign the leadership within the campaignBackoff, and the etcd leader is moved to another
+// member first if the etcd is embedded, because only the etcd leader can campaign the leadership in that case.
+func (m *Member) StepDown(ctx context.Context, campaignBackoff time.Duration) (GetLeaderAddrResp, error) {
+	var emptyResp GetLeaderAddrResp
+	resp, err := m.getLeader(ctx)
+	if err != nil {
+		return emptyResp, err
+	}
+	if resp.Leader == nil || !resp.IsLocal {
+		return emptyResp, ErrNotLeader.WithCausef("leader:%v", resp.Leader)
+	}
+
+	if m.etcdLeaderGetter != nil {
+		if err := m.moveEtcdLeader(ctx); err != nil {
+			return emptyResp, err
+		}
+	}
+
+	m.campaignSuppressedUntil.Store(time.Now().Add(campaignBackoff).UnixNano())
+	select {
+	case m.stepDownCh <- struct{}{}:
+	default:
+	}
+	m.logger.Info("try to step down as leader", zap.Duration("campaign-backoff", campaignBackoff))
+
+	ticker := time.NewTicker(waitNewLeaderInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return emptyResp, ErrWaitNewLeader.WithCause(ctx.Err())
+		case <-ticker.C:
+		}
+
+		resp, err := m.getLeader(ctx)
+		if err != nil {
+			m.logger.Warn("fail to get leader after stepping down", zap.Error(err))
+			continue
+		}
+		if resp.Leader != nil && !resp.IsLocal {
+			m.logger.Info("new leader is elected after stepping down", zap.String("new-leader", resp.Leader.Name))
+			return GetLeaderAddrResp{
+				LeaderEndpoint: resp.Leader.Endpoint,
+				IsLocal:        false,
+			}, nil
+		}
+	}
+}
+
+// moveEtcdLeader transfers the leadership of the embedded etcd to another voting member.
+func (m *Member) moveEtcdLeader(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.rpcTimeout)
+	defer cancel()
+
+	memberListResp, err := m.etcdCli.MemberList(ctx)
+	if err != nil {
+		return ErrMoveEtcdLeader.WithCause(err)
+	}
+	for _, member := range memberListResp.Members {
+		if member.ID == m.ID || member.IsLearner {
+			continue
+		}
+		if _, err := m.etcdCli.MoveLeader(ctx, member.ID); err != nil {
+			return ErrMoveEtcdLeader.WithCause(err)
+		}
+		m.logger.Info("etcd leader is moved", zap.String("new-etcd-leader", member.Name))
+		return nil
+	}
+
+	return ErrMoveEtcdLeader.WithCausef("no other voting member found")
+}
+
+// isCampaignSuppressed returns true if the member has stepped down recently and should not campaign the leadership.
+func (m *Member) isCampaignSuppressed() bool {
+	return time.Now().UnixNano() < m.campaignSuppressedUntil.Load()
+}
+
 func (m *Member) Marshal() (string, error) {
 	memPB := &metastoragepb.Member{
 		Name:     m.Name,
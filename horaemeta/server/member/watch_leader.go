@@ -36,6 +36,7 @@ const (
 	waitReasonFailEtcd    = "fail to access etcd"
 	waitReasonResetLeader = "leader is reset"
 	waitReasonElectLeader = "leader is electing"
+	waitReasonStepDown    = "leader has stepped down recently"
 	waitReasonNoWait      = ""
 )
 
@@ -155,6 +156,10 @@ func (l *LeaderWatcher) Watch(ctx context.Context, callbacks LeadershipEventCall
 		if memLeader == nil {
 			// Leader does not exist.
 			// A new leader should be elected and the etcd leader should be elected as the new leader.
+			if l.self.isCampaignSuppressed() {
+				wait = waitReasonStepDown
+				continue
+			}
 			if l.leadershipChecker.ShouldCampaign(l.self) {
 				// Campaign the leader and block until leader changes.
 				if err := l.self.CampaignAndKeepLeader(ctx, l.leaseTTLSec, l.leadershipChecker, callbacks); err != nil {
@@ -171,6 +176,7 @@ func (l *LeaderWatcher) Watch(ctx context.Context, callbacks LeadershipEventCall
 		} else {
 			// Cache leader in memory.
 			l.self.leader = memLeader
+			l.self.leaderStats.observe(memLeader, resp.Revision, time.Now())
 			log.Info("update leader cache", zap.String("endpoint", memLeader.Endpoint))
 
 			// Leader does exist.
@@ -90,3 +90,54 @@ func TestWatchLeaderSingle(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.Nil(t, resp.Leader)
 }
+
+func TestWatchLeaderStepDown(t *testing.T) {
+	etcd, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	defer closeSrv()
+
+	watchCtx := &mockWatchCtx{
+		stopped: false,
+		client:  client,
+		srv:     etcd.Server,
+	}
+	rpcTimeout := time.Duration(10) * time.Second
+	leaseTTLSec := int64(1)
+	members := []*Member{
+		NewMember("", 0, "mem0", "endpoint0", client, nil, rpcTimeout),
+		NewMember("", 1, "mem1", "endpoint1", client, nil, rpcTimeout),
+	}
+
+	ctx, cancelWatch := context.WithCancel(context.Background())
+	defer cancelWatch()
+	for _, mem := range members {
+		leaderWatcher := NewLeaderWatcher(watchCtx, mem, leaseTTLSec, false)
+		go leaderWatcher.Watch(ctx, nil)
+	}
+
+	// Wait for the leader elected.
+	var leader, follower *Member
+	assert.Eventually(t, func() bool {
+		resp, err := members[0].getLeader(ctx)
+		if err != nil || resp.Leader == nil {
+			return false
+		}
+		if resp.IsLocal {
+			leader, follower = members[0], members[1]
+		} else {
+			leader, follower = members[1], members[0]
+		}
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The follower can't step down.
+	_, err := follower.StepDown(ctx, time.Minute)
+	assert.Error(t, err)
+
+	// The leadership is taken over by the other member after stepping down.
+	stepDownCtx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+	resp, err := leader.StepDown(stepDownCtx, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, follower.Endpoint, resp.LeaderEndpoint)
+	assert.False(t, resp.IsLocal)
+}
@@ -33,6 +33,9 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/member"
@@ -42,6 +45,7 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/server/v3/embed"
@@ -50,6 +54,9 @@ import (
 	"google.golang.org/grpc/keepalive"
 )
 
+// expiredNodeCleanupInterval is the interval of cleaning up the expired nodes.
+const expiredNodeCleanupInterval = time.Minute
+
 type Server struct {
 	isClosed int32
 	status   *status.ServerStatus
@@ -66,6 +73,8 @@ type Server struct {
 	member  *member.Member
 	etcdCli *clientv3.Client
 	etcdSrv *embed.Etcd
+	// compaction manages the auto-compaction of the embedded etcd, and it is nil if the embedded etcd is disabled.
+	compaction *etcdutil.CompactionController
 
 	// httpService contains http server and api set.
 	httpService *http.Service
@@ -74,6 +83,9 @@ type Server struct {
 	bgJobWg sync.WaitGroup
 	// bgJobCancel can be used to cancel all pending background jobs.
 	bgJobCancel func()
+
+	// recentErrors records the errors of the http handlers, the grpc handlers and the procedures for triage.
+	recentErrors *coderr.RecentErrors
 }
 
 // CreateServer creates the server instance without starting any services or background jobs.
@@ -94,12 +106,24 @@ func CreateServer(cfg *config.Config) (*Server, error) {
 		member:         nil,
 		etcdCli:        nil,
 		etcdSrv:        nil,
+		compaction:     nil,
 		httpService:    nil,
 		bgJobWg:        sync.WaitGroup{},
 		bgJobCancel:    nil,
+
+		recentErrors: coderr.NewRecentErrors(cfg.RecentErrorsCapacity),
 	}
 
-	grpcService := metagrpc.NewService(cfg.GrpcHandleTimeout(), srv)
+	if cfg.EnableFaultInjection {
+		log.Warn("fault injection of procedures is enabled, it must only be used for testing")
+		procedure.EnableFaultInjection()
+	}
+
+	unknownCluster, err := unknownClusterOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	grpcService := metagrpc.NewService(cfg.GrpcHandleTimeout(), cfg.HeartbeatErrorBackoff(), unknownCluster, staleRouteOptions(cfg), srv.recentErrors, srv)
 	etcdCfg.ServiceRegister = func(grpcSrv *grpc.Server) {
 		grpcSrv.RegisterService(&metaservicepb.MetaRpcService_ServiceDesc, grpcService)
 	}
@@ -144,6 +168,10 @@ func (srv *Server) Close() {
 
 	srv.stopBgJobs()
 
+	if srv.compaction != nil {
+		srv.compaction.Stop()
+	}
+
 	if srv.etcdCli != nil {
 		err := srv.etcdCli.Close()
 		if err != nil {
@@ -177,6 +205,12 @@ func (srv *Server) startEmbedEtcd(ctx context.Context) error {
 	}
 	srv.etcdSrv = etcdSrv
 
+	srv.compaction = etcdutil.NewCompactionController(log.GetLogger(), etcdSrv.Server, srv.cfg.StorageRootPath, etcdutil.CompactionConfig{
+		Mode:             srv.cfg.AutoCompactionMode,
+		Retention:        srv.cfg.AutoCompactionRetention,
+		RuntimeRetention: "",
+	})
+
 	return nil
 }
 
@@ -226,7 +260,11 @@ func (srv *Server) startGrpcServer(_ context.Context) error {
 	opts := srv.buildGrpcOptions()
 	server := grpc.NewServer(opts...)
 
-	grpcService := metagrpc.NewService(srv.cfg.GrpcHandleTimeout(), srv)
+	unknownCluster, err := unknownClusterOptions(srv.cfg)
+	if err != nil {
+		return err
+	}
+	grpcService := metagrpc.NewService(srv.cfg.GrpcHandleTimeout(), srv.cfg.HeartbeatErrorBackoff(), unknownCluster, staleRouteOptions(srv.cfg), srv.recentErrors, srv)
 	server.RegisterService(&metaservicepb.MetaRpcService_ServiceDesc, grpcService)
 	addr := fmt.Sprintf(":%d", srv.cfg.GrpcPort)
 	lis, err := net.Listen("tcp", addr)
@@ -243,8 +281,46 @@ func (srv *Server) startGrpcServer(_ context.Context) error {
 
 // startServer starts involved services.
 func (srv *Server) startServer(_ context.Context) error {
-	if srv.cfg.MaxScanLimit <= 1 {
-		return ErrStartServer.WithCausef("scan limit must be greater than 1")
+	scanLimit := storage.ScanLimit{Min: srv.cfg.MinScanLimit, Max: srv.cfg.MaxScanLimit}
+	if err := scanLimit.Validate(); err != nil {
+		return ErrStartServer.WithCausef("invalid scan limit, err:%v", err)
+	}
+
+	readyShardStatuses := make([]storage.ShardStatus, 0, len(srv.cfg.ReadyShardStatuses))
+	for _, rawStatus := range srv.cfg.ReadyShardStatuses {
+		status, err := metadata.ParseShardStatus(rawStatus)
+		if err != nil {
+			return err
+		}
+		readyShardStatuses = append(readyShardStatuses, status)
+	}
+
+	offlineShardPolicy, err := metadata.ParseOfflineShardPolicy(srv.cfg.CreateTableOfflineShardPolicy)
+	if err != nil {
+		return ErrStartServer.WithCausef("invalid create table offline shard policy, err:%v", err)
+	}
+
+	shardPickers, err := coordinator.ParseShardPickers(srv.cfg.ShardPickers)
+	if err != nil {
+		return ErrStartServer.WithCausef("invalid shard pickers, err:%v", err)
+	}
+
+	// The procedure timeouts are left unchecked by the config, so the invalid ones fail the startup here.
+	procedureTimeouts, err := procedure.ParseTimeouts(srv.cfg.ProcedureTimeouts)
+	if err != nil {
+		return ErrStartServer.WithCausef("invalid procedure timeouts, err:%v", err)
+	}
+
+	procedureStorageOptions := procedure.StorageOptions{EtcdPrefix: srv.cfg.ProcedureStorageEtcdPrefix}
+
+	clusterKeyPrefixes, err := storage.ParseClusterKeyPrefixes(srv.cfg.ClusterKeyPrefixes)
+	if err != nil {
+		return ErrStartServer.WithCausef("invalid cluster key prefixes, err:%v", err)
+	}
+
+	nodePickerHash := metadata.NodePickerHash{Function: srv.cfg.NodePickerHashFunction, Seed: srv.cfg.NodePickerHashSeed}
+	if err := nodepicker.ValidateHashFunction(nodePickerHash.Function); err != nil {
+		return ErrStartServer.WithCausef("invalid node picker hash function, err:%v", err)
 	}
 
 	storage := storage.NewStorageWithEtcdBackend(srv.etcdCli, srv.cfg.StorageRootPath,
@@ -252,6 +328,8 @@ func (srv *Server) startServer(_ context.Context) error {
 			MaxScanLimit: srv.cfg.MaxScanLimit,
 			MinScanLimit: srv.cfg.MinScanLimit,
 			MaxOpsPerTxn: srv.cfg.MaxOpsPerTxn,
+			ReadTimeout:  srv.cfg.EtcdReadTimeout(),
+			WriteTimeout: srv.cfg.EtcdWriteTimeout(),
 		})
 
 	topologyType, err := metadata.ParseTopologyType(srv.cfg.TopologyType)
@@ -259,14 +337,43 @@ func (srv *Server) startServer(_ context.Context) error {
 		return err
 	}
 
-	manager, err := cluster.NewManagerImpl(storage, srv.etcdCli, srv.etcdCli, srv.cfg.StorageRootPath, srv.cfg.IDAllocatorStep, topologyType)
+	manager, err := cluster.NewManagerImpl(storage, srv.etcdCli, srv.etcdCli, cluster.ManagerOptions{
+		RootPath:                          srv.cfg.StorageRootPath,
+		IDAllocatorStep:                   srv.cfg.IDAllocatorStep,
+		ClusterKeyPrefixes:                clusterKeyPrefixes,
+		TopologyType:                      topologyType,
+		EnableSchemaAutoCreation:          srv.cfg.EnableSchemaAutoCreation,
+		SchedulerConcurrency:              srv.cfg.SchedulerConcurrency,
+		MaxShardVersionDelta:              srv.cfg.MaxShardVersionDelta,
+		ReadyShardStatuses:                readyShardStatuses,
+		NodePickerHash:                    nodePickerHash,
+		EnableProcedureCheckpoint:         srv.cfg.EnableProcedureCheckpoint,
+		CreateTableOfflineShardPolicy:     offlineShardPolicy,
+		ShardPickers:                      shardPickers,
+		ProcedureTimeouts:                 procedureTimeouts,
+		ShardOscillationThreshold:         metadata.ShardOscillationThreshold{MaxMoves: srv.cfg.ShardOscillationMaxMoves, Window: srv.cfg.ShardOscillationWindow()},
+		PreferredLeaderStabilizationDelay: srv.cfg.PreferredLeaderStabilizationDelay(),
+		NodeStatsHistoryOptions:           metadata.NodeStatsHistoryOptions{Capacity: srv.cfg.NodeStatsHistoryCapacity, Interval: srv.cfg.NodeStatsHistoryInterval()},
+		MaxInflightCreatesPerShard:        srv.cfg.MaxInflightCreatesPerShard,
+		MaxPartitionSubTables:             srv.cfg.MaxPartitionSubTables,
+		SubTableDispatchConcurrency:       srv.cfg.SubTableDispatchConcurrency,
+		ProcedureStorageOptions:           procedureStorageOptions,
+		RecentErrors:                      srv.recentErrors,
+	})
 	if err != nil {
 		return err
 	}
 	srv.clusterManager = manager
+	if err := prometheus.Register(cluster.NewDiagnoseCollector(manager)); err != nil {
+		log.Warn("register diagnose metrics", zap.Error(err))
+	}
 	srv.flowLimiter = limiter.NewFlowLimiter(srv.cfg.FlowLimiter)
 
-	api := http.NewAPI(manager, srv.status, http.NewForwardClient(srv.member, srv.cfg.HTTPPort), srv.flowLimiter, srv.etcdCli)
+	var embeddedEtcdEndpoint string
+	if srv.etcdSrv != nil {
+		embeddedEtcdEndpoint = srv.etcdCfg.AdvertiseClientUrls[0].String()
+	}
+	api := http.NewAPI(manager, srv.status, http.NewForwardClient(srv.member, srv.cfg.HTTPPort), srv.flowLimiter, srv.etcdCli, srv.compaction, embeddedEtcdEndpoint, srv.cfg.SlowRequestThreshold(), srv.cfg.EffectiveConfig(), srv.cfg.EnableSafeMode, srv.recentErrors)
 	httpService := http.NewHTTPService(srv.cfg.HTTPPort, time.Second*10, time.Second*10, api.NewAPIRouter())
 	go func() {
 		err := httpService.Start()
@@ -286,6 +393,10 @@ func (srv *Server) startBgJobs(ctx context.Context) {
 
 	go srv.watchLeader(bgJobCtx)
 	go srv.watchEtcdLeaderPriority(bgJobCtx)
+	go srv.purgeFinishedProcedures(bgJobCtx)
+	go srv.cleanupExpiredNodes(bgJobCtx)
+	go srv.adjustFlowLimiter(bgJobCtx)
+	go srv.watchCompactionRetention(bgJobCtx)
 }
 
 func (srv *Server) stopBgJobs() {
@@ -317,6 +428,116 @@ func (srv *Server) watchEtcdLeaderPriority(_ context.Context) {
 	defer srv.bgJobWg.Done()
 }
 
+// purgeFinishedProcedures purges the finished procedures of all clusters periodically to bound the growth of the procedure records.
+// Only the leader holds the clusters, so it is a no-op on the followers.
+func (srv *Server) purgeFinishedProcedures(ctx context.Context) {
+	srv.bgJobWg.Add(1)
+	defer srv.bgJobWg.Done()
+
+	interval := srv.cfg.ProcedurePurgeInterval()
+	if interval <= 0 {
+		log.Info("purging finished procedures is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		clusters, err := srv.clusterManager.ListClusters(ctx)
+		if err != nil {
+			log.Error("list clusters failed", zap.Error(err))
+			continue
+		}
+		for _, c := range clusters {
+			result, err := c.PurgeFinishedProcedures(ctx, srv.cfg.ProcedureRetention(), false)
+			if err != nil {
+				log.Error("purge finished procedures failed", zap.String("clusterName", c.GetMetadata().Name()), zap.Error(err))
+				continue
+			}
+			log.Info("purge finished procedures", zap.String("clusterName", c.GetMetadata().Name()), zap.Int("purged", result.Purged))
+		}
+	}
+}
+
+// adjustFlowLimiter probes the latency of etcd periodically, and reduces the rate of the flow limiter when etcd is slow, so that the
+// requests consuming etcd are throttled harder before etcd is overwhelmed.
+func (srv *Server) adjustFlowLimiter(ctx context.Context) {
+	srv.bgJobWg.Add(1)
+	defer srv.bgJobWg.Done()
+
+	if !srv.cfg.EnableAdaptiveFlowLimiter {
+		log.Info("adaptive flow limiter is disabled")
+		return
+	}
+
+	controller := limiter.NewAdaptiveController(srv.flowLimiter, limiter.AdaptiveOptions{
+		LatencyThreshold: srv.cfg.AdaptiveLatencyThreshold(),
+		MinMultiplier:    float64(srv.cfg.AdaptiveMinLimitPercent) / 100,
+		ProbeInterval:    srv.cfg.AdaptiveProbeInterval(),
+	})
+	controller.Run(ctx, func(ctx context.Context) error {
+		_, err := srv.etcdCli.Get(ctx, srv.cfg.StorageRootPath, clientv3.WithCountOnly())
+		return err
+	})
+}
+
+// watchCompactionRetention applies the runtime retention of the auto-compaction persisted in etcd to the embedded etcd, so that every
+// member compacts with the same retention no matter which member it is updated through.
+func (srv *Server) watchCompactionRetention(ctx context.Context) {
+	srv.bgJobWg.Add(1)
+	defer srv.bgJobWg.Done()
+
+	if srv.compaction == nil {
+		return
+	}
+
+	srv.compaction.Watch(ctx, srv.etcdCli)
+}
+
+// cleanupExpiredNodes removes the nodes expired longer than the retention from the registered nodes of all clusters
+// periodically, so that the registered nodes reflect the actual members of the clusters.
+// Only the leader holds the clusters, so it is a no-op on the followers.
+func (srv *Server) cleanupExpiredNodes(ctx context.Context) {
+	srv.bgJobWg.Add(1)
+	defer srv.bgJobWg.Done()
+
+	if !srv.cfg.EnableNodeCleanup {
+		log.Info("cleaning up expired nodes is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(expiredNodeCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		clusters, err := srv.clusterManager.ListClusters(ctx)
+		if err != nil {
+			log.Error("list clusters failed", zap.Error(err))
+			continue
+		}
+		for _, c := range clusters {
+			removedNodes, err := c.GetMetadata().CleanupExpiredNodes(ctx, srv.cfg.ExpiredNodeRetention(), time.Now())
+			if err != nil {
+				log.Error("clean up expired nodes failed", zap.String("clusterName", c.GetMetadata().Name()), zap.Error(err))
+			}
+			if len(removedNodes) > 0 {
+				log.Info("clean up expired nodes", zap.String("clusterName", c.GetMetadata().Name()), zap.Strings("removedNodes", removedNodes))
+			}
+		}
+	}
+}
+
 func (srv *Server) createDefaultCluster(ctx context.Context) error {
 	resp, err := srv.member.GetLeaderAddr(ctx)
 	if err != nil {
@@ -325,18 +546,11 @@ func (srv *Server) createDefaultCluster(ctx context.Context) error {
 
 	// Create default cluster by the leader.
 	if resp.IsLocal {
-		topologyType, err := metadata.ParseTopologyType(srv.cfg.TopologyType)
+		opts, err := defaultClusterOpts(srv.cfg)
 		if err != nil {
 			return err
 		}
-		defaultCluster, err := srv.clusterManager.CreateCluster(ctx, srv.cfg.DefaultClusterName,
-			metadata.CreateClusterOpts{
-				NodeCount:                   uint32(srv.cfg.DefaultClusterNodeCount),
-				ShardTotal:                  uint32(srv.cfg.DefaultClusterShardTotal),
-				EnableSchedule:              srv.cfg.EnableSchedule,
-				TopologyType:                topologyType,
-				ProcedureExecutingBatchSize: srv.cfg.ProcedureExecutingBatchSize,
-			})
+		defaultCluster, err := srv.clusterManager.CreateCluster(ctx, srv.cfg.DefaultClusterName, opts)
 		if err != nil {
 			log.Warn("create default cluster failed", zap.Error(err))
 			if coderr.Is(err, metadata.ErrClusterAlreadyExists.Code()) {
@@ -352,6 +566,45 @@ func (srv *Server) createDefaultCluster(ctx context.Context) error {
 	return nil
 }
 
+// defaultClusterOpts builds the options of creating the default cluster from the config.
+func defaultClusterOpts(cfg *config.Config) (metadata.CreateClusterOpts, error) {
+	topologyType, err := metadata.ParseTopologyType(cfg.TopologyType)
+	if err != nil {
+		var opts metadata.CreateClusterOpts
+		return opts, err
+	}
+	return metadata.CreateClusterOpts{
+		NodeCount:                   uint32(cfg.DefaultClusterNodeCount),
+		ShardTotal:                  uint32(cfg.DefaultClusterShardTotal),
+		EnableSchedule:              cfg.EnableSchedule,
+		TopologyType:                topologyType,
+		ProcedureExecutingBatchSize: cfg.ProcedureExecutingBatchSize,
+		ShardIDs:                    nil,
+	}, nil
+}
+
+// unknownClusterOptions builds the options of handling the heartbeats to the unknown clusters, which are created with the settings of the default cluster.
+func unknownClusterOptions(cfg *config.Config) (metagrpc.UnknownClusterOptions, error) {
+	createOpts, err := defaultClusterOpts(cfg)
+	if err != nil {
+		var opts metagrpc.UnknownClusterOptions
+		return opts, err
+	}
+	return metagrpc.UnknownClusterOptions{
+		AutoCreate:  cfg.EnableUnknownClusterAutoCreation,
+		CreateOpts:  createOpts,
+		ErrorWindow: cfg.UnknownClusterErrorWindow(),
+	}, nil
+}
+
+// staleRouteOptions builds the options of serving the last known routes when the live metadata is unavailable.
+func staleRouteOptions(cfg *config.Config) metagrpc.StaleRouteOptions {
+	return metagrpc.StaleRouteOptions{
+		Enable: cfg.EnableStaleRouteFallback,
+		MaxAge: cfg.StaleRouteMaxAge(),
+	}
+}
+
 func (srv *Server) buildGrpcOptions() []grpc.ServerOption {
 	keepalivePolicy := keepalive.EnforcementPolicy{
 		MinTime:             time.Duration(srv.cfg.GrpcServiceKeepAlivePingMinIntervalSec) * time.Second,
@@ -361,6 +614,8 @@ func (srv *Server) buildGrpcOptions() []grpc.ServerOption {
 		grpc.MaxSendMsgSize(srv.cfg.GrpcServiceMaxSendMsgSize),
 		grpc.MaxRecvMsgSize(srv.cfg.GrpcServiceMaxSendMsgSize),
 		grpc.KeepaliveEnforcementPolicy(keepalivePolicy),
+		grpc.MaxConcurrentStreams(uint32(srv.cfg.GrpcServiceMaxConcurrentStreams)),
+		grpc.ChainUnaryInterceptor(metagrpc.SlowRequestInterceptor(srv.cfg.SlowRequestThreshold())),
 	}
 	return opts
 }
@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// SlowRequestInterceptor logs a warning for the unary request whose latency exceeds the threshold, zero threshold disables the logging.
+func SlowRequestInterceptor(threshold time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if cost := time.Since(start); threshold > 0 && cost > threshold {
+			log.Warn("slow grpc request", zap.String("method", info.FullMethod), zap.Duration("cost", cost), zap.Duration("threshold", threshold), zap.Error(err))
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpcmetadata "google.golang.org/grpc/metadata"
+)
+
+// RouteFailuresKey is the key of the grpc header returned with the RouteTables response if some tables failed to be routed, which carries one
+// value per failed table formatted as "reason:tableName", sorted, and the reason is one of metadata.RouteFailureReason, e.g. "tableNotFound:t0".
+// The reason never contains ':', so the table name is everything after the first ':'. The header is binary because the table names may be
+// arbitrary bytes, and it is absent if all the tables are routed. The failed tables are still returned in the entries of the response with
+// empty node shards for compatibility, except the ones not found.
+const RouteFailuresKey = "x-horaedb-route-failures-bin"
+
+// setRouteFailuresHeader sets the reasons of the tables failed to be routed into the grpc header.
+func setRouteFailuresHeader(ctx context.Context, failedTables map[string]metadata.RouteFailureReason) {
+	if len(failedTables) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(failedTables))
+	for tableName, reason := range failedTables {
+		values = append(values, string(reason)+":"+tableName)
+	}
+	sort.Strings(values)
+	if err := grpc.SetHeader(ctx, grpcmetadata.MD{RouteFailuresKey: values}); err != nil {
+		log.Warn("set route failures header failed", zap.Error(err))
+	}
+}
+
+// forwardRouteFailuresHeader passes the route failures header of the response forwarded from the leader to the client.
+func forwardRouteFailuresHeader(ctx context.Context, header grpcmetadata.MD) {
+	values := header.Get(RouteFailuresKey)
+	if len(values) == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, grpcmetadata.MD{RouteFailuresKey: values}); err != nil {
+		log.Warn("set route failures header failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	grpcmetadata "google.golang.org/grpc/metadata"
+)
+
+// headerRecorder records the grpc headers set by the service.
+type headerRecorder struct {
+	header grpcmetadata.MD
+}
+
+func (r *headerRecorder) Method() string {
+	return "RouteTables"
+}
+
+func (r *headerRecorder) SetHeader(md grpcmetadata.MD) error {
+	r.header = grpcmetadata.Join(r.header, md)
+	return nil
+}
+
+func (r *headerRecorder) SendHeader(md grpcmetadata.MD) error {
+	return r.SetHeader(md)
+}
+
+func (r *headerRecorder) SetTrailer(_ grpcmetadata.MD) error {
+	return nil
+}
+
+func TestRouteTablesFailuresHeader(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := newTestClusterManager(t)
+	c, err := manager.CreateCluster(ctx, testClusterName, newTestClusterOpts())
+	re.NoError(err)
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, []storage.ShardNode{
+		{ID: 0, ShardRole: storage.ShardRoleLeader, NodeName: "node0"},
+	}))
+	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, "public")
+	re.NoError(err)
+	_, err = c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       0,
+		LatestVersion: 0,
+		SchemaName:    "public",
+		TableName:     "table0",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	handler := &testHandler{clusterManager: manager, leaderErr: nil}
+	service := NewService(time.Second*10, 0, UnknownClusterOptions{AutoCreate: false, CreateOpts: newTestClusterOpts(), ErrorWindow: 0},
+		StaleRouteOptions{Enable: false, MaxAge: 0}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity), handler)
+
+	// The header is absent if all the tables are routed.
+	recorder := &headerRecorder{header: nil}
+	resp, err := service.RouteTables(grpc.NewContextWithServerTransportStream(ctx, recorder), newRouteTablesRequest("public", "table0"))
+	re.NoError(err)
+	re.Equal(uint32(coderr.Ok), resp.GetHeader().GetCode())
+	re.Empty(recorder.header.Get(RouteFailuresKey))
+
+	// The reasons of the failed tables are returned in the header without failing the routed ones.
+	recorder = &headerRecorder{header: nil}
+	resp, err = service.RouteTables(grpc.NewContextWithServerTransportStream(ctx, recorder), newRouteTablesRequest("public", "table0", "table2", "table1"))
+	re.NoError(err)
+	re.Equal(uint32(coderr.Ok), resp.GetHeader().GetCode())
+	re.Contains(resp.GetEntries(), "table0")
+	re.Equal([]string{"tableNotFound:table1", "tableNotFound:table2"}, recorder.header.Get(RouteFailuresKey))
+
+	// The header of the response forwarded from the leader is passed to the client.
+	recorder = &headerRecorder{header: nil}
+	forwardRouteFailuresHeader(grpc.NewContextWithServerTransportStream(ctx, recorder), grpcmetadata.MD{RouteFailuresKey: []string{"shardLeaderless:table3"}})
+	re.Equal([]string{"shardLeaderless:table3"}, recorder.header.Get(RouteFailuresKey))
+}
@@ -22,6 +22,7 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,22 +40,53 @@ import (
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpcmetadata "google.golang.org/grpc/metadata"
 )
 
+// NodeShuttingDownKey is the key of the grpc metadata carried by the heartbeat, which indicates the node is going to shut down.
+// TODO: move it into the NodeInfo of the heartbeat request once the proto supports it.
+const NodeShuttingDownKey = "x-horaedb-node-shutting-down"
+
+// HeartbeatRetryBackoffKey is the key of the grpc header returned with the heartbeat response, whose value is the duration in milliseconds the node is suggested to wait before the next heartbeat.
+// The header is absent if no backoff is needed.
+// TODO: move it into the NodeHeartbeatResponse once the proto supports it.
+const HeartbeatRetryBackoffKey = "x-horaedb-heartbeat-retry-backoff-ms"
+
+// TableShardLeaderKey is the key of the grpc header returned with the CreateTable response, whose value is the endpoint of the node leading the shard of the created table.
+// The value is looked up from the cluster view after the creation, so it is the best-known leader and may be stale if the leader changes concurrently.
+// The header is absent if the shard has no leader.
+// TODO: move it into the CreateTableResponse once the proto supports it.
+const TableShardLeaderKey = "x-horaedb-table-shard-leader"
+
 type Service struct {
 	metaservicepb.UnimplementedMetaRpcServiceServer
 	opTimeout time.Duration
-	h         Handler
+	// heartbeatErrorBackoff is the backoff suggested to the nodes when the server is degraded, zero disables the suggestion.
+	heartbeatErrorBackoff time.Duration
+	unknownCluster        UnknownClusterOptions
+	unknownClusterErrors  *unknownClusterErrors
+	staleRoute            StaleRouteOptions
+	lastKnownRoutes       *lastKnownRoutes
+	// recentErrors records the errors responded by the service for triage.
+	recentErrors *coderr.RecentErrors
+	h            Handler
 
 	// Store as map[string]*grpc.ClientConn
 	// TODO: remove unavailable connection
 	conns sync.Map
 }
 
-func NewService(opTimeout time.Duration, h Handler) *Service {
+func NewService(opTimeout time.Duration, heartbeatErrorBackoff time.Duration, unknownCluster UnknownClusterOptions, staleRoute StaleRouteOptions, recentErrors *coderr.RecentErrors, h Handler) *Service {
 	return &Service{
 		UnimplementedMetaRpcServiceServer: metaservicepb.UnimplementedMetaRpcServiceServer{},
 		opTimeout:                         opTimeout,
+		heartbeatErrorBackoff:             heartbeatErrorBackoff,
+		unknownCluster:                    unknownCluster,
+		unknownClusterErrors:              newUnknownClusterErrors(unknownCluster.ErrorWindow),
+		staleRoute:                        staleRoute,
+		lastKnownRoutes:                   newLastKnownRoutes(),
+		recentErrors:                      recentErrors,
 		h:                                 h,
 		conns:                             sync.Map{},
 	}
@@ -72,12 +104,24 @@ type Handler interface {
 func (s *Service) NodeHeartbeat(ctx context.Context, req *metaservicepb.NodeHeartbeatRequest) (*metaservicepb.NodeHeartbeatResponse, error) {
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.NodeHeartbeatResponse{Header: responseHeader(err, "grpc heartbeat")}, nil
+		return &metaservicepb.NodeHeartbeatResponse{Header: s.responseHeader(err, "grpc heartbeat")}, nil
 	}
 
+	shuttingDown := isNodeShuttingDown(ctx)
+
 	// Forward request to the leader.
 	if metaClient != nil {
-		return metaClient.NodeHeartbeat(ctx, req)
+		forwardCtx := ctx
+		if shuttingDown {
+			forwardCtx = grpcmetadata.AppendToOutgoingContext(ctx, NodeShuttingDownKey, "true")
+		}
+		// Pass the backoff suggested by the leader through to the node.
+		var header grpcmetadata.MD
+		resp, err := metaClient.NodeHeartbeat(forwardCtx, req, grpc.Header(&header))
+		if values := header.Get(HeartbeatRetryBackoffKey); len(values) > 0 {
+			setHeartbeatRetryBackoffHeader(ctx, values[0])
+		}
+		return resp, err
 	}
 
 	shardInfos := make([]metadata.ShardInfo, 0, len(req.Info.ShardInfos))
@@ -92,6 +136,8 @@ func (s *Service) NodeHeartbeat(ctx context.Context, req *metaservicepb.NodeHear
 				Lease:       req.GetInfo().Lease,
 				Zone:        req.GetInfo().Zone,
 				NodeVersion: req.GetInfo().BinaryVersion,
+
+				ShuttingDown: shuttingDown,
 			},
 			LastTouchTime: uint64(time.Now().UnixMilli()),
 			State:         storage.NodeStateOnline,
@@ -100,9 +146,17 @@ func (s *Service) NodeHeartbeat(ctx context.Context, req *metaservicepb.NodeHear
 
 	log.Info("[NodeHeartbeat]", zap.String("clusterName", req.GetHeader().ClusterName), zap.String("name", req.Info.Endpoint), zap.String("info", fmt.Sprintf("%+v", registeredNode)))
 
-	err = s.h.GetClusterManager().RegisterNode(ctx, req.GetHeader().GetClusterName(), registeredNode)
+	clusterName := req.GetHeader().GetClusterName()
+	err = s.registerNode(ctx, clusterName, registeredNode)
+	if backoff := s.heartbeatRetryBackoff(err != nil); backoff > 0 {
+		log.Warn("suggest heartbeat backoff", zap.String("name", req.Info.Endpoint), zap.Duration("backoff", backoff), zap.Error(err))
+		setHeartbeatRetryBackoffHeader(ctx, strconv.FormatInt(backoff.Milliseconds(), 10))
+	}
 	if err != nil {
-		return &metaservicepb.NodeHeartbeatResponse{Header: responseHeader(err, "grpc heartbeat")}, nil
+		if coderr.Is(err, metadata.ErrClusterNotFound.Code()) {
+			return &metaservicepb.NodeHeartbeatResponse{Header: s.unknownClusterResponseHeader(err, req.Info.Endpoint, clusterName)}, nil
+		}
+		return &metaservicepb.NodeHeartbeatResponse{Header: s.responseHeader(err, "grpc heartbeat")}, nil
 	}
 
 	return &metaservicepb.NodeHeartbeatResponse{
@@ -110,11 +164,49 @@ func (s *Service) NodeHeartbeat(ctx context.Context, req *metaservicepb.NodeHear
 	}, nil
 }
 
+// heartbeatRetryBackoff suggests how long the node should wait before the next heartbeat.
+// The failure of registering node usually means the etcd is degraded, and every signal of degradation adds a base backoff.
+func (s *Service) heartbeatRetryBackoff(registerFailed bool) time.Duration {
+	if s.heartbeatErrorBackoff <= 0 {
+		return 0
+	}
+
+	var backoff time.Duration
+	if registerFailed {
+		backoff += s.heartbeatErrorBackoff
+	}
+	if flowLimiter, err := s.h.GetFlowLimiter(); err == nil && flowLimiter.IsOverloaded() {
+		backoff += s.heartbeatErrorBackoff
+	}
+	return backoff
+}
+
+func setHeartbeatRetryBackoffHeader(ctx context.Context, backoffMs string) {
+	if err := grpc.SetHeader(ctx, grpcmetadata.Pairs(HeartbeatRetryBackoffKey, backoffMs)); err != nil {
+		log.Warn("set heartbeat backoff header failed", zap.Error(err))
+	}
+}
+
+func setTableShardLeaderHeader(ctx context.Context, leader string) {
+	if err := grpc.SetHeader(ctx, grpcmetadata.Pairs(TableShardLeaderKey, leader)); err != nil {
+		log.Warn("set table shard leader header failed", zap.Error(err))
+	}
+}
+
+func isNodeShuttingDown(ctx context.Context) bool {
+	md, ok := grpcmetadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	values := md.Get(NodeShuttingDownKey)
+	return len(values) > 0 && strings.EqualFold(values[0], "true")
+}
+
 // AllocSchemaID implements gRPC HoraeMetaServer.
 func (s *Service) AllocSchemaID(ctx context.Context, req *metaservicepb.AllocSchemaIdRequest) (*metaservicepb.AllocSchemaIdResponse, error) {
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.AllocSchemaIdResponse{Header: responseHeader(err, "grpc alloc schema id")}, nil
+		return &metaservicepb.AllocSchemaIdResponse{Header: s.responseHeader(err, "grpc alloc schema id")}, nil
 	}
 
 	// Forward request to the leader.
@@ -126,7 +218,7 @@ func (s *Service) AllocSchemaID(ctx context.Context, req *metaservicepb.AllocSch
 
 	schemaID, _, err := s.h.GetClusterManager().AllocSchemaID(ctx, req.GetHeader().GetClusterName(), req.GetName())
 	if err != nil {
-		return &metaservicepb.AllocSchemaIdResponse{Header: responseHeader(err, "grpc alloc schema id")}, nil
+		return &metaservicepb.AllocSchemaIdResponse{Header: s.responseHeader(err, "grpc alloc schema id")}, nil
 	}
 
 	return &metaservicepb.AllocSchemaIdResponse{
@@ -140,7 +232,7 @@ func (s *Service) AllocSchemaID(ctx context.Context, req *metaservicepb.AllocSch
 func (s *Service) GetTablesOfShards(ctx context.Context, req *metaservicepb.GetTablesOfShardsRequest) (*metaservicepb.GetTablesOfShardsResponse, error) {
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.GetTablesOfShardsResponse{Header: responseHeader(err, "grpc get tables of shards")}, nil
+		return &metaservicepb.GetTablesOfShardsResponse{Header: s.responseHeader(err, "grpc get tables of shards")}, nil
 	}
 
 	// Forward request to the leader.
@@ -157,7 +249,7 @@ func (s *Service) GetTablesOfShards(ctx context.Context, req *metaservicepb.GetT
 
 	tables, err := s.h.GetClusterManager().GetTablesByShardIDs(req.GetHeader().GetClusterName(), req.GetHeader().GetNode(), shardIDs)
 	if err != nil {
-		return &metaservicepb.GetTablesOfShardsResponse{Header: responseHeader(err, "grpc get tables of shards")}, nil
+		return &metaservicepb.GetTablesOfShardsResponse{Header: s.responseHeader(err, "grpc get tables of shards")}, nil
 	}
 
 	result := convertToGetTablesOfShardsResponse(tables)
@@ -168,18 +260,28 @@ func (s *Service) GetTablesOfShards(ctx context.Context, req *metaservicepb.GetT
 func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTableRequest) (*metaservicepb.CreateTableResponse, error) {
 	start := time.Now()
 	// Since there may be too many table creation requests, a flow limiter is added here.
-	if ok, err := s.allow(); !ok {
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, "create table grpc request is rejected by flow limiter")}, nil
+	op := limiter.OperationCreateTable
+	if req.GetPartitionTableInfo() != nil {
+		op = limiter.OperationCreatePartitionTable
+	}
+	if ok, err := s.allow(op); !ok {
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, "create table grpc request is rejected by flow limiter")}, nil
 	}
 
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
 	}
 
 	// Forward request to the leader.
 	if metaClient != nil {
-		return metaClient.CreateTable(ctx, req)
+		// Pass the shard leader found by the leader through to the client.
+		var header grpcmetadata.MD
+		resp, err := metaClient.CreateTable(ctx, req, grpc.Header(&header))
+		if values := header.Get(TableShardLeaderKey); len(values) > 0 {
+			setTableShardLeaderHeader(ctx, values[0])
+		}
+		return resp, err
 	}
 
 	log.Info("[CreateTable]", zap.String("schemaName", req.SchemaName), zap.String("clusterName", req.GetHeader().ClusterName), zap.String("tableName", req.GetName()))
@@ -188,7 +290,17 @@ func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTabl
 	c, err := clusterManager.GetCluster(ctx, req.GetHeader().GetClusterName())
 	if err != nil {
 		log.Error("fail to create table", zap.Error(err))
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
+	}
+
+	// Reject the request early if the table quota of the cluster is exhausted.
+	numTables := 1
+	if req.GetPartitionTableInfo() != nil {
+		numTables = len(req.GetPartitionTableInfo().GetSubTableNames())
+	}
+	if err := c.GetMetadata().CheckTableQuota(c.GetMetadata().GetClusterSnapshot(), numTables); err != nil {
+		log.Warn("fail to create table, table quota exceeded", zap.Error(err))
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
 	}
 
 	errorCh := make(chan error, 1)
@@ -211,18 +323,23 @@ func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTabl
 	})
 	if err != nil {
 		log.Error("fail to create table, factory create procedure", zap.Error(err))
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
 	}
 
 	err = c.GetProcedureManager().Submit(ctx, p)
 	if err != nil {
 		log.Error("fail to create table, manager submit procedure", zap.Error(err))
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
 	}
 
 	select {
 	case ret := <-resultCh:
 		log.Info("create table finish", zap.String("tableName", req.Name), zap.Int64("costTime", time.Since(start).Milliseconds()))
+		if leader, ok := c.GetMetadata().GetShardLeader(ret.ShardVersionUpdate.ShardID); ok {
+			setTableShardLeaderHeader(ctx, leader.NodeName)
+		} else {
+			log.Warn("shard leader of the created table not found", zap.String("tableName", req.Name), zap.Uint32("shardID", uint32(ret.ShardVersionUpdate.ShardID)))
+		}
 		return &metaservicepb.CreateTableResponse{
 			Header: okResponseHeader(),
 			CreatedTable: &metaservicepb.TableInfo{
@@ -239,7 +356,7 @@ func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTabl
 		}, nil
 	case err = <-errorCh:
 		log.Warn("create table failed", zap.String("tableName", req.Name), zap.Int64("costTime", time.Since(start).Milliseconds()), zap.Error(err))
-		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
+		return &metaservicepb.CreateTableResponse{Header: s.responseHeader(err, err.Error())}, nil
 	}
 }
 
@@ -247,13 +364,13 @@ func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTabl
 func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableRequest) (*metaservicepb.DropTableResponse, error) {
 	start := time.Now()
 	// Since there may be too many table dropping requests, a flow limiter is added here.
-	if ok, err := s.allow(); !ok {
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table grpc request is rejected by flow limiter")}, nil
+	if ok, err := s.allow(limiter.OperationDropTable); !ok {
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table grpc request is rejected by flow limiter")}, nil
 	}
 
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table")}, nil
 	}
 
 	// Forward request to the leader.
@@ -267,7 +384,7 @@ func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableReq
 	c, err := clusterManager.GetCluster(ctx, req.GetHeader().GetClusterName())
 	if err != nil {
 		log.Error("fail to drop table", zap.Error(err))
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table")}, nil
 	}
 
 	errorCh := make(chan error, 1)
@@ -290,7 +407,7 @@ func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableReq
 	})
 	if err != nil {
 		log.Error("fail to drop table", zap.Error(err))
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table")}, nil
 	}
 	if !ok {
 		log.Warn("table may have been dropped already")
@@ -300,7 +417,7 @@ func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableReq
 	err = c.GetProcedureManager().Submit(ctx, procedure)
 	if err != nil {
 		log.Error("fail to drop table, manager submit procedure", zap.Error(err), zap.Int64("costTime", time.Since(start).Milliseconds()))
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table")}, nil
 	}
 
 	select {
@@ -312,42 +429,63 @@ func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableReq
 		}, nil
 	case err = <-errorCh:
 		log.Info("drop table failed", zap.String("tableName", req.Name), zap.Int64("costTime", time.Since(start).Milliseconds()))
-		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
+		return &metaservicepb.DropTableResponse{Header: s.responseHeader(err, "drop table")}, nil
 	}
 }
 
 // RouteTables implements gRPC HoraeMetaServer.
 func (s *Service) RouteTables(ctx context.Context, req *metaservicepb.RouteTablesRequest) (*metaservicepb.RouteTablesResponse, error) {
 	// Since there may be too many table routing requests, a flow limiter is added here.
-	if ok, err := s.allow(); !ok {
-		return &metaservicepb.RouteTablesResponse{Header: responseHeader(err, "routeTables grpc request is rejected by flow limiter")}, nil
+	if ok, err := s.allow(limiter.OperationRouteTables); !ok {
+		return &metaservicepb.RouteTablesResponse{Header: s.responseHeader(err, "routeTables grpc request is rejected by flow limiter")}, nil
 	}
 
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.RouteTablesResponse{Header: responseHeader(err, "grpc routeTables")}, nil
+		// The leader is unavailable, so the last known routes may be served instead.
+		if staleResp, ok := s.serveLastKnownRoutes(ctx, req, err); ok {
+			return staleResp, nil
+		}
+		return &metaservicepb.RouteTablesResponse{Header: s.responseHeader(err, "grpc routeTables")}, nil
 	}
 
 	log.Debug("[RouteTable]", zap.String("schemaName", req.SchemaName), zap.String("clusterName", req.GetHeader().ClusterName), zap.String("tableNames", strings.Join(req.TableNames, ",")))
 
 	// Forward request to the leader.
 	if metaClient != nil {
-		return metaClient.RouteTables(ctx, req)
+		var header grpcmetadata.MD
+		resp, err := metaClient.RouteTables(ctx, req, grpc.Header(&header))
+		if err != nil {
+			// The errors of the leader are returned in the response header, so the error here means the leader is unreachable.
+			if staleResp, ok := s.serveLastKnownRoutes(ctx, req, err); ok {
+				return staleResp, nil
+			}
+			return resp, err
+		}
+		forwardRouteFailuresHeader(ctx, header)
+		s.recordLastKnownRoutes(req, resp)
+		return resp, nil
 	}
 
 	routeTableResult, err := s.h.GetClusterManager().RouteTables(ctx, req.GetHeader().GetClusterName(), req.GetSchemaName(), req.GetTableNames())
 	if err != nil {
-		return &metaservicepb.RouteTablesResponse{Header: responseHeader(err, "grpc routeTables")}, nil
+		return &metaservicepb.RouteTablesResponse{Header: s.responseHeader(err, "grpc routeTables")}, nil
 	}
 
-	return convertRouteTableResult(routeTableResult), nil
+	if len(routeTableResult.FailedTables) > 0 {
+		log.Debug("some tables failed to be routed", zap.String("clusterName", req.GetHeader().GetClusterName()), zap.String("schemaName", req.GetSchemaName()), zap.Any("failedTables", routeTableResult.FailedTables))
+	}
+	setRouteFailuresHeader(ctx, routeTableResult.FailedTables)
+	resp := convertRouteTableResult(routeTableResult)
+	s.recordLastKnownRoutes(req, resp)
+	return resp, nil
 }
 
 // GetNodes implements gRPC HoraeMetaServer.
 func (s *Service) GetNodes(ctx context.Context, req *metaservicepb.GetNodesRequest) (*metaservicepb.GetNodesResponse, error) {
 	metaClient, err := s.getForwardedMetaClient(ctx)
 	if err != nil {
-		return &metaservicepb.GetNodesResponse{Header: responseHeader(err, "grpc get nodes")}, nil
+		return &metaservicepb.GetNodesResponse{Header: s.responseHeader(err, "grpc get nodes")}, nil
 	}
 
 	// Forward request to the leader.
@@ -360,7 +498,7 @@ func (s *Service) GetNodes(ctx context.Context, req *metaservicepb.GetNodesReque
 	nodesResult, err := s.h.GetClusterManager().GetNodeShards(ctx, req.GetHeader().GetClusterName())
 	if err != nil {
 		log.Error("fail to get nodes", zap.Error(err))
-		return &metaservicepb.GetNodesResponse{Header: responseHeader(err, "grpc get nodes")}, nil
+		return &metaservicepb.GetNodesResponse{Header: s.responseHeader(err, "grpc get nodes")}, nil
 	}
 
 	return convertToGetNodesResponse(nodesResult), nil
@@ -394,6 +532,8 @@ func convertRouteTableResult(routeTablesResult metadata.RouteTablesResult) *meta
 				ShardInfo: &metaservicepb.ShardInfo{
 					Id:   uint32(nodeShard.ShardNode.ID),
 					Role: storage.ConvertShardRoleToPB(nodeShard.ShardNode.ShardRole),
+					// Clients compare the shard version to detect stale routes.
+					Version: nodeShard.ShardInfo.Version,
 				},
 			})
 		}
@@ -430,13 +570,14 @@ func convertToGetNodesResponse(nodesResult metadata.GetNodeShardsResult) *metase
 }
 
 func okResponseHeader() *commonpb.ResponseHeader {
-	return responseHeader(nil, "")
+	return &commonpb.ResponseHeader{Code: coderr.Ok, Error: ""}
 }
 
-func responseHeader(err error, msg string) *commonpb.ResponseHeader {
+func (s *Service) responseHeader(err error, msg string) *commonpb.ResponseHeader {
 	if err == nil {
 		return &commonpb.ResponseHeader{Code: coderr.Ok, Error: msg}
 	}
+	s.recentErrors.Record("grpc", errors.WithMessage(err, msg))
 
 	code, ok := coderr.GetCauseCode(err)
 	if ok {
@@ -446,12 +587,16 @@ func responseHeader(err error, msg string) *commonpb.ResponseHeader {
 	return &commonpb.ResponseHeader{Code: coderr.Internal, Error: msg}
 }
 
-func (s *Service) allow() (bool, error) {
+func (s *Service) allow(op limiter.Operation) (bool, error) {
 	flowLimiter, err := s.h.GetFlowLimiter()
 	if err != nil {
 		return false, errors.WithMessage(err, "get flow limiter failed")
 	}
-	if !flowLimiter.Allow() {
+	allowed, err := flowLimiter.AllowN(flowLimiter.Cost(op))
+	if err != nil {
+		return false, errors.WithMessage(err, "flow limiter")
+	}
+	if !allowed {
 		return false, ErrFlowLimit.WithCausef("the current flow has reached the threshold")
 	}
 	return true, nil
@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/apache/incubator-horaedb-meta/server/limiter"
+	"github.com/apache/incubator-horaedb-meta/server/member"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRootPath    = "/horaemeta"
+	testClusterName = "cluster0"
+)
+
+// testHandler serves the requests locally as the leader with the cluster manager, and the leader is unavailable if leaderErr is set.
+type testHandler struct {
+	clusterManager cluster.Manager
+	leaderErr      error
+}
+
+func (h *testHandler) GetClusterManager() cluster.Manager {
+	return h.clusterManager
+}
+
+func (h *testHandler) GetLeader(_ context.Context) (member.GetLeaderAddrResp, error) {
+	if h.leaderErr != nil {
+		return member.GetLeaderAddrResp{LeaderEndpoint: "", IsLocal: false}, h.leaderErr
+	}
+	return member.GetLeaderAddrResp{LeaderEndpoint: "", IsLocal: true}, nil
+}
+
+func (h *testHandler) GetFlowLimiter() (*limiter.FlowLimiter, error) {
+	return limiter.NewFlowLimiter(config.LimiterConfig{
+		Enable: false, Limit: 0, Burst: 0, CreateTableCost: 0, CreatePartitionTableCost: 0, DropTableCost: 0, RouteTablesCost: 0,
+	}), nil
+}
+
+// newTestClusterManager starts a cluster manager backed by the embedded etcd.
+func newTestClusterManager(t *testing.T) cluster.Manager {
+	re := require.New(t)
+	_, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	t.Cleanup(closeSrv)
+
+	clusterStorage := storage.NewStorageWithEtcdBackend(client, testRootPath, storage.Options{
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
+	})
+	manager, err := cluster.NewManagerImpl(clusterStorage, client, client, cluster.ManagerOptions{
+		RootPath:                          testRootPath,
+		IDAllocatorStep:                   20,
+		ClusterKeyPrefixes:                nil,
+		TopologyType:                      storage.TopologyTypeStatic,
+		EnableSchemaAutoCreation:          true,
+		SchedulerConcurrency:              2,
+		MaxShardVersionDelta:              metadata.DefaultMaxShardVersionDelta,
+		ReadyShardStatuses:                []storage.ShardStatus{storage.ShardStatusReady},
+		NodePickerHash:                    metadata.NodePickerHash{Function: "", Seed: 0},
+		EnableProcedureCheckpoint:         false,
+		CreateTableOfflineShardPolicy:     metadata.OfflineShardPolicyFail,
+		ShardPickers:                      nil,
+		ProcedureTimeouts:                 procedure.Timeouts{},
+		ShardOscillationThreshold:         metadata.ShardOscillationThreshold{MaxMoves: 0, Window: 0},
+		PreferredLeaderStabilizationDelay: 0,
+		NodeStatsHistoryOptions:           metadata.NodeStatsHistoryOptions{Capacity: 0, Interval: 0},
+		MaxInflightCreatesPerShard:        0,
+		MaxPartitionSubTables:             0,
+		SubTableDispatchConcurrency:       0,
+		ProcedureStorageOptions:           procedure.StorageOptions{EtcdPrefix: ""},
+		RecentErrors:                      coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity),
+	})
+	re.NoError(err)
+	re.NoError(manager.Start(context.Background()))
+	t.Cleanup(func() {
+		_ = manager.Stop(context.Background())
+	})
+	return manager
+}
+
+func newTestClusterOpts() metadata.CreateClusterOpts {
+	return metadata.CreateClusterOpts{
+		NodeCount:                   1,
+		ShardTotal:                  2,
+		EnableSchedule:              false,
+		TopologyType:                storage.TopologyTypeStatic,
+		ProcedureExecutingBatchSize: 100,
+		ShardIDs:                    nil,
+	}
+}
+
+func newHeartbeatRequest(clusterName, nodeName string) *metaservicepb.NodeHeartbeatRequest {
+	return &metaservicepb.NodeHeartbeatRequest{
+		Header: &metaservicepb.RequestHeader{Node: nodeName, ClusterName: clusterName},
+		Info: &metaservicepb.NodeInfo{
+			Endpoint:      nodeName,
+			Lease:         0,
+			Zone:          "",
+			BinaryVersion: "",
+			ShardInfos:    nil,
+		},
+	}
+}
+
+func newTestService(manager cluster.Manager, unknownCluster UnknownClusterOptions, recentErrors *coderr.RecentErrors) *Service {
+	return NewService(time.Second*10, 0, unknownCluster, StaleRouteOptions{Enable: false, MaxAge: 0}, recentErrors, &testHandler{clusterManager: manager, leaderErr: nil})
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpcmetadata "google.golang.org/grpc/metadata"
+)
+
+// StaleRouteAgeKey is the key of the grpc header returned with the RouteTables response served from the last known routes, whose value is the age
+// in milliseconds of the oldest route in the response. The header is absent if the routes are fresh, and the clients decide whether to trust the
+// stale routes by it.
+// TODO: move it into the RouteTablesResponse once the proto supports it.
+const StaleRouteAgeKey = "x-horaedb-route-stale-age-ms"
+
+// maxLastKnownRoutes bounds the number of the routes kept for the fallback.
+const maxLastKnownRoutes = 100000
+
+// StaleRouteOptions determines whether the last known routes are served when the leader is unavailable.
+type StaleRouteOptions struct {
+	Enable bool
+	// MaxAge determines the max age of the last known routes to be served, zero means unlimited.
+	MaxAge time.Duration
+}
+
+type lastKnownRoute struct {
+	entry                  *metaservicepb.RouteEntry
+	clusterTopologyVersion uint64
+	routedAt               time.Time
+}
+
+// lastKnownRoutes keeps the routes of the tables served recently, so that they can be served when the live metadata is unavailable.
+type lastKnownRoutes struct {
+	lock sync.RWMutex
+	// routes is the last known routes, cluster/schema/table -> route.
+	routes map[string]lastKnownRoute
+}
+
+func newLastKnownRoutes() *lastKnownRoutes {
+	return &lastKnownRoutes{
+		lock:   sync.RWMutex{},
+		routes: make(map[string]lastKnownRoute),
+	}
+}
+
+func lastKnownRouteKey(clusterName, schemaName, tableName string) string {
+	return clusterName + "/" + schemaName + "/" + tableName
+}
+
+// update records the routes of the response, and the routes of the tables missing in the response are dropped.
+func (r *lastKnownRoutes) update(req *metaservicepb.RouteTablesRequest, resp *metaservicepb.RouteTablesResponse, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, tableName := range req.GetTableNames() {
+		key := lastKnownRouteKey(req.GetHeader().GetClusterName(), req.GetSchemaName(), tableName)
+		entry, ok := resp.GetEntries()[tableName]
+		if !ok {
+			delete(r.routes, key)
+			continue
+		}
+
+		if _, exists := r.routes[key]; !exists && len(r.routes) >= maxLastKnownRoutes {
+			// Evict an arbitrary route to make room.
+			for k := range r.routes {
+				delete(r.routes, k)
+				break
+			}
+		}
+		r.routes[key] = lastKnownRoute{entry: entry, clusterTopologyVersion: resp.GetClusterTopologyVersion(), routedAt: now}
+	}
+}
+
+// get returns the response made of the last known routes of the tables and the age of the oldest route, and false is returned unless all the
+// tables have their routes not older than the maxAge.
+func (r *lastKnownRoutes) get(req *metaservicepb.RouteTablesRequest, maxAge time.Duration, now time.Time) (*metaservicepb.RouteTablesResponse, time.Duration, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if len(req.GetTableNames()) == 0 {
+		return nil, 0, false
+	}
+
+	entries := make(map[string]*metaservicepb.RouteEntry, len(req.GetTableNames()))
+	var oldest lastKnownRoute
+	for _, tableName := range req.GetTableNames() {
+		route, ok := r.routes[lastKnownRouteKey(req.GetHeader().GetClusterName(), req.GetSchemaName(), tableName)]
+		if !ok || (maxAge > 0 && now.Sub(route.routedAt) > maxAge) {
+			return nil, 0, false
+		}
+		entries[tableName] = route.entry
+		if oldest.entry == nil || route.routedAt.Before(oldest.routedAt) {
+			oldest = route
+		}
+	}
+
+	return &metaservicepb.RouteTablesResponse{
+		Header: okResponseHeader(),
+		// The version of the oldest route is reported, so that the clients treat the whole response as stale as its oldest route.
+		ClusterTopologyVersion: oldest.clusterTopologyVersion,
+		Entries:                entries,
+	}, now.Sub(oldest.routedAt), true
+}
+
+// recordLastKnownRoutes records the routes of the successful response for the fallback if it is enabled.
+func (s *Service) recordLastKnownRoutes(req *metaservicepb.RouteTablesRequest, resp *metaservicepb.RouteTablesResponse) {
+	if !s.staleRoute.Enable || resp.GetHeader().GetCode() != coderr.Ok {
+		return
+	}
+	s.lastKnownRoutes.update(req, resp, time.Now())
+}
+
+// serveLastKnownRoutes returns the response made of the last known routes when the leader is unavailable, and false is returned if the fallback
+// is disabled or any route is missing. It must not be used for the errors returned by the leader, e.g. the table is not found, which are not stale.
+func (s *Service) serveLastKnownRoutes(ctx context.Context, req *metaservicepb.RouteTablesRequest, leaderErr error) (*metaservicepb.RouteTablesResponse, bool) {
+	if !s.staleRoute.Enable {
+		return nil, false
+	}
+
+	staleResp, age, ok := s.lastKnownRoutes.get(req, s.staleRoute.MaxAge, time.Now())
+	if !ok {
+		return nil, false
+	}
+	log.Warn("serve last known routes", zap.String("clusterName", req.GetHeader().GetClusterName()), zap.String("schemaName", req.GetSchemaName()), zap.Int("tables", len(req.GetTableNames())), zap.Duration("age", age), zap.Error(leaderErr))
+	if err := grpc.SetHeader(ctx, grpcmetadata.Pairs(StaleRouteAgeKey, strconv.FormatInt(age.Milliseconds(), 10))); err != nil {
+		log.Warn("set stale route header failed", zap.Error(err))
+	}
+	return staleResp, true
+}
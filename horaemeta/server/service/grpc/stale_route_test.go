@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouteTablesRequest(schemaName string, tableNames ...string) *metaservicepb.RouteTablesRequest {
+	return &metaservicepb.RouteTablesRequest{
+		Header:     &metaservicepb.RequestHeader{Node: "node0", ClusterName: testClusterName},
+		SchemaName: schemaName,
+		TableNames: tableNames,
+	}
+}
+
+func TestRouteTablesStaleFallback(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := newTestClusterManager(t)
+	c, err := manager.CreateCluster(ctx, testClusterName, newTestClusterOpts())
+	re.NoError(err)
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, []storage.ShardNode{
+		{ID: 0, ShardRole: storage.ShardRoleLeader, NodeName: "node0"},
+	}))
+	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, "public")
+	re.NoError(err)
+	_, err = c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       0,
+		LatestVersion: 0,
+		SchemaName:    "public",
+		TableName:     "table0",
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	handler := &testHandler{clusterManager: manager, leaderErr: nil}
+	service := NewService(time.Second*10, 0, UnknownClusterOptions{AutoCreate: false, CreateOpts: newTestClusterOpts(), ErrorWindow: 0},
+		StaleRouteOptions{Enable: true, MaxAge: 0}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity), handler)
+
+	// The routes served by the leader are recorded.
+	resp, err := service.RouteTables(ctx, newRouteTablesRequest("public", "table0"))
+	re.NoError(err)
+	re.Equal(uint32(coderr.Ok), resp.GetHeader().GetCode())
+	re.Contains(resp.GetEntries(), "table0")
+
+	// The errors returned by the leader are not covered by the last known routes.
+	service.lastKnownRoutes.update(newRouteTablesRequest("unknown", "table0"), resp, time.Now())
+	resp, err = service.RouteTables(ctx, newRouteTablesRequest("unknown", "table0"))
+	re.NoError(err)
+	re.NotEqual(uint32(coderr.Ok), resp.GetHeader().GetCode())
+	re.Empty(resp.GetEntries())
+
+	// The last known routes are served when the leader is unavailable.
+	handler.leaderErr = errors.New("leader is unavailable")
+	resp, err = service.RouteTables(ctx, newRouteTablesRequest("public", "table0"))
+	re.NoError(err)
+	re.Equal(uint32(coderr.Ok), resp.GetHeader().GetCode())
+	re.Contains(resp.GetEntries(), "table0")
+
+	// The routing still fails if any route is unknown.
+	resp, err = service.RouteTables(ctx, newRouteTablesRequest("public", "table0", "table1"))
+	re.NoError(err)
+	re.NotEqual(uint32(coderr.Ok), resp.GetHeader().GetCode())
+}
+
+func TestRouteTablesStaleFallbackDisabled(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := newTestClusterManager(t)
+	handler := &testHandler{clusterManager: manager, leaderErr: nil}
+	service := NewService(time.Second*10, 0, UnknownClusterOptions{AutoCreate: false, CreateOpts: newTestClusterOpts(), ErrorWindow: 0},
+		StaleRouteOptions{Enable: false, MaxAge: 0}, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity), handler)
+
+	// Nothing is recorded or served if the fallback is disabled.
+	entries := map[string]*metaservicepb.RouteEntry{"table0": {Table: nil, NodeShards: nil}}
+	service.recordLastKnownRoutes(newRouteTablesRequest("public", "table0"), &metaservicepb.RouteTablesResponse{Header: okResponseHeader(), ClusterTopologyVersion: 0, Entries: entries})
+	re.Empty(service.lastKnownRoutes.routes)
+
+	handler.leaderErr = errors.New("leader is unavailable")
+	resp, err := service.RouteTables(ctx, newRouteTablesRequest("public", "table0"))
+	re.NoError(err)
+	re.NotEqual(uint32(coderr.Ok), resp.GetHeader().GetCode())
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/commonpb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// UnknownClusterOptions determines how the heartbeats registering the nodes to an unknown cluster are handled.
+type UnknownClusterOptions struct {
+	// AutoCreate determines whether the unknown cluster is created with the CreateOpts, otherwise the heartbeat is rejected.
+	AutoCreate bool
+	CreateOpts metadata.CreateClusterOpts
+	// ErrorWindow determines the window in which the repeated rejections of the same node and cluster are only reported once, zero disables the suppression.
+	ErrorWindow time.Duration
+}
+
+// unknownClusterErrors tracks the last reported rejection of the heartbeats to the unknown clusters, so that the nodes configured with a wrong cluster name
+// don't flood the logs and the recent errors by heartbeating every few seconds.
+type unknownClusterErrors struct {
+	window time.Duration
+
+	lock sync.Mutex
+	// lastReported is the time of the last reported rejection, node/cluster -> time.
+	lastReported map[string]time.Time
+}
+
+func newUnknownClusterErrors(window time.Duration) *unknownClusterErrors {
+	return &unknownClusterErrors{
+		window:       window,
+		lock:         sync.Mutex{},
+		lastReported: make(map[string]time.Time),
+	}
+}
+
+// shouldReport returns true if the rejection of the node to the cluster is not reported in the window, and marks it as reported at now.
+func (e *unknownClusterErrors) shouldReport(nodeName, clusterName string, now time.Time) bool {
+	if e.window <= 0 {
+		return true
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	key := nodeName + "/" + clusterName
+	if last, ok := e.lastReported[key]; ok && now.Sub(last) < e.window {
+		return false
+	}
+
+	// Drop the stale entries so that the map doesn't grow with the nodes never heartbeating again.
+	for k, last := range e.lastReported {
+		if now.Sub(last) >= e.window {
+			delete(e.lastReported, k)
+		}
+	}
+	e.lastReported[key] = now
+	return true
+}
+
+// registerNode registers the node to the cluster, and creates the cluster first if it is unknown and the auto creation is enabled.
+func (s *Service) registerNode(ctx context.Context, clusterName string, registeredNode metadata.RegisteredNode) error {
+	clusterManager := s.h.GetClusterManager()
+	err := clusterManager.RegisterNode(ctx, clusterName, registeredNode)
+	if err == nil || !s.unknownCluster.AutoCreate || !coderr.Is(err, metadata.ErrClusterNotFound.Code()) {
+		return err
+	}
+
+	c, err := clusterManager.CreateCluster(ctx, clusterName, s.unknownCluster.CreateOpts)
+	if err != nil && !coderr.Is(err, metadata.ErrClusterAlreadyExists.Code()) {
+		return errors.WithMessagef(err, "create unknown cluster, clusterName:%s", clusterName)
+	}
+	if err == nil {
+		log.Info("create unknown cluster registered by node", zap.String("clusterName", c.GetMetadata().Name()), zap.String("node", registeredNode.Node.Name))
+	}
+
+	return clusterManager.RegisterNode(ctx, clusterName, registeredNode)
+}
+
+// unknownClusterResponseHeader builds the response header rejecting the heartbeat to the unknown cluster, and the rejection is only logged and recorded
+// once in the error window.
+func (s *Service) unknownClusterResponseHeader(err error, nodeName, clusterName string) *commonpb.ResponseHeader {
+	const msg = "grpc heartbeat"
+	if s.unknownClusterErrors.shouldReport(nodeName, clusterName, time.Now()) {
+		log.Warn("reject heartbeat to unknown cluster", zap.String("node", nodeName), zap.String("clusterName", clusterName), zap.Duration("suppressWindow", s.unknownCluster.ErrorWindow), zap.Error(err))
+		return s.responseHeader(err, msg)
+	}
+
+	return &commonpb.ResponseHeader{Code: coderr.NotFound, Error: msg}
+}
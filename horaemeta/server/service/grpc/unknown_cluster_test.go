@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeatUnknownClusterRejected(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := newTestClusterManager(t)
+	recentErrors := coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity)
+	service := newTestService(manager, UnknownClusterOptions{
+		AutoCreate:  false,
+		CreateOpts:  newTestClusterOpts(),
+		ErrorWindow: time.Minute,
+	}, recentErrors)
+
+	// The repeated rejections in the window are only recorded once.
+	for i := 0; i < 3; i++ {
+		resp, err := service.NodeHeartbeat(ctx, newHeartbeatRequest(testClusterName, "node0"))
+		re.NoError(err)
+		re.Equal(uint32(coderr.NotFound), resp.Header.Code)
+	}
+	re.Len(recentErrors.List(), 1)
+
+	// The rejection of another node is reported separately.
+	resp, err := service.NodeHeartbeat(ctx, newHeartbeatRequest(testClusterName, "node1"))
+	re.NoError(err)
+	re.Equal(uint32(coderr.NotFound), resp.Header.Code)
+	re.Len(recentErrors.List(), 2)
+
+	// The cluster is never created.
+	_, err = manager.GetCluster(ctx, testClusterName)
+	re.Error(err)
+}
+
+func TestHeartbeatUnknownClusterAutoCreated(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := newTestClusterManager(t)
+	recentErrors := coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity)
+	service := newTestService(manager, UnknownClusterOptions{
+		AutoCreate:  true,
+		CreateOpts:  newTestClusterOpts(),
+		ErrorWindow: time.Minute,
+	}, recentErrors)
+
+	for _, nodeName := range []string{"node0", "node1"} {
+		resp, err := service.NodeHeartbeat(ctx, newHeartbeatRequest(testClusterName, nodeName))
+		re.NoError(err)
+		re.Equal(uint32(coderr.Ok), resp.Header.Code)
+	}
+	re.Empty(recentErrors.List())
+
+	c, err := manager.GetCluster(ctx, testClusterName)
+	re.NoError(err)
+	re.Equal(uint32(newTestClusterOpts().ShardTotal), c.GetMetadata().GetTotalShardNum())
+	for _, nodeName := range []string{"node0", "node1"} {
+		_, ok := c.GetMetadata().GetRegisteredNodeByName(nodeName)
+		re.True(ok)
+	}
+}
+
+func TestUnknownClusterErrorsShouldReport(t *testing.T) {
+	re := require.New(t)
+	now := time.Now()
+
+	errs := newUnknownClusterErrors(time.Minute)
+	re.True(errs.shouldReport("node0", "cluster0", now))
+	re.False(errs.shouldReport("node0", "cluster0", now.Add(time.Second)))
+	re.True(errs.shouldReport("node0", "cluster1", now.Add(time.Second)))
+	re.True(errs.shouldReport("node1", "cluster0", now.Add(time.Second)))
+	re.True(errs.shouldReport("node0", "cluster0", now.Add(time.Minute)))
+	// The stale entries are dropped once the window passes.
+	re.True(errs.shouldReport("node2", "cluster0", now.Add(time.Minute*3)))
+	re.Len(errs.lastReported, 1)
+
+	// The suppression is disabled by the zero window.
+	errs = newUnknownClusterErrors(0)
+	re.True(errs.shouldReport("node0", "cluster0", now))
+	re.True(errs.shouldReport("node0", "cluster0", now))
+}
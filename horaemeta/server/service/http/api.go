@@ -27,6 +27,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -36,48 +40,115 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/member"
 	"github.com/apache/incubator-horaedb-meta/server/status"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
 
-func NewAPI(clusterManager cluster.Manager, serverStatus *status.ServerStatus, forwardClient *ForwardClient, flowLimiter *limiter.FlowLimiter, etcdClient *clientv3.Client) *API {
+func NewAPI(clusterManager cluster.Manager, serverStatus *status.ServerStatus, forwardClient *ForwardClient, flowLimiter *limiter.FlowLimiter, etcdClient *clientv3.Client, compaction *etcdutil.CompactionController, embeddedEtcdEndpoint string, slowRequestThreshold time.Duration, effectiveConfig []config.EffectiveItem, safeMode bool, recentErrors *coderr.RecentErrors) *API {
 	return &API{
-		clusterManager: clusterManager,
-		serverStatus:   serverStatus,
-		forwardClient:  forwardClient,
-		flowLimiter:    flowLimiter,
-		etcdAPI:        NewEtcdAPI(etcdClient, forwardClient),
+		clusterManager:       clusterManager,
+		serverStatus:         serverStatus,
+		forwardClient:        forwardClient,
+		flowLimiter:          flowLimiter,
+		slowRequestThreshold: slowRequestThreshold,
+		effectiveConfig:      effectiveConfig,
+		safeMode:             safeMode,
+		recentErrors:         recentErrors,
+		etcdAPI:              NewEtcdAPI(etcdClient, forwardClient, compaction, embeddedEtcdEndpoint),
 	}
 }
 
 func (a *API) NewAPIRouter() *Router {
-	router := New().WithPrefix(apiPrefix).WithInstrumentation(printRequestInfo)
+	router := New().WithPrefixes(apiPrefix, apiV2Prefix).WithInstrumentation(printRequestInfo).WithInstrumentation(a.logSlowRequest)
 
 	// Register API.
-	router.Post("/getShardTables", wrap(a.getShardTables, true, a.forwardClient))
-	router.Post("/transferLeader", wrap(a.transferLeader, true, a.forwardClient))
-	router.Post("/split", wrap(a.split, true, a.forwardClient))
-	router.Post("/route", wrap(a.route, true, a.forwardClient))
-	router.Del("/table", wrap(a.dropTable, true, a.forwardClient))
-	router.Post("/getNodeShards", wrap(a.getNodeShards, true, a.forwardClient))
-	router.Del("/nodeShards", wrap(a.dropNodeShards, true, a.forwardClient))
-	router.Get("/flowLimiter", wrap(a.getFlowLimiter, true, a.forwardClient))
-	router.Put("/flowLimiter", wrap(a.updateFlowLimiter, true, a.forwardClient))
-	router.Get("/health", wrap(a.health, false, a.forwardClient))
+	router.Post("/getShardTables", a.wrap(a.getShardTables, true))
+	router.Post("/transferLeader", a.wrap(a.transferLeader, true))
+	router.Post("/split", a.wrap(a.split, true))
+	router.Post("/route", a.wrap(a.route, true))
+	router.Del("/table", a.wrap(a.destructive(a.dropTable), true))
+	router.Post("/getNodeShards", a.wrap(a.getNodeShards, true))
+	router.Del("/nodeShards", a.wrap(a.destructive(a.dropNodeShards), true))
+	router.Get("/flowLimiter", a.wrap(a.getFlowLimiter, true))
+	router.Put("/flowLimiter", a.wrap(a.updateFlowLimiter, true))
+	router.Get("/scanLimit", a.wrap(a.getScanLimit, true))
+	router.Put("/scanLimit", a.wrap(a.updateScanLimit, true))
+	router.Get("/health", a.wrap(a.health, false))
+	router.Post("/leader/stepDown", a.wrap(a.stepDown, true))
+	router.Get("/metadata/export", a.wrapStream(a.exportMetadata, true))
+
+	router.Get(fmt.Sprintf("/nodes/:%s/clusters", nodeNameParam), a.wrap(a.listNodeClusters, true))
 
 	// Register cluster API.
-	router.Get("/clusters", wrap(a.listClusters, true, a.forwardClient))
-	router.Post("/clusters", wrap(a.createCluster, true, a.forwardClient))
-	router.Put(fmt.Sprintf("/clusters/:%s", clusterNameParam), wrap(a.updateCluster, true, a.forwardClient))
-	router.Get(fmt.Sprintf("/clusters/:%s/procedure", clusterNameParam), wrap(a.listProcedures, true, a.forwardClient))
-	router.Get(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.listShardAffinities, true, a.forwardClient))
-	router.Post(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.addShardAffinities, true, a.forwardClient))
-	router.Del(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.removeShardAffinities, true, a.forwardClient))
-	router.Post("/table/query", wrap(a.queryTable, true, a.forwardClient))
+	router.Get("/clusters", a.wrap(a.listClusters, true))
+	router.Post("/clusters", a.wrap(a.createCluster, true))
+	router.Put(fmt.Sprintf("/clusters/:%s", clusterNameParam), a.wrap(a.updateCluster, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/clone", clusterNameParam), a.wrap(a.cloneCluster, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/compare", clusterNameParam), a.wrap(a.compareClusters, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/schemas", clusterNameParam), a.wrap(a.createSchema, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/schemas/:%s/tableCount", clusterNameParam, schemaNameParam), a.wrap(a.getSchemaTableCount, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/schemas/:%s/shardSpread", clusterNameParam, schemaNameParam), a.wrap(a.getSchemaShardSpread, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/schemas/:%s/tables", clusterNameParam, schemaNameParam), a.wrap(a.destructive(a.dropSchemaTables), true))
+	router.Get(fmt.Sprintf("/clusters/:%s/orphanTables", clusterNameParam), a.wrap(a.listOrphanTables, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/orphanTables/drop", clusterNameParam), a.wrap(a.destructive(a.dropOrphanTables), true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedure", clusterNameParam), a.wrap(a.listProcedures, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/procedure/purge", clusterNameParam), a.wrap(a.destructive(a.purgeFinishedProcedures), true))
+	router.Post(fmt.Sprintf("/clusters/:%s/procedure/cancel", clusterNameParam), a.wrap(a.destructive(a.cancelProcedures), true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedure/:%s", clusterNameParam, procedureIDParam), a.wrap(a.getProcedure, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedureStats", clusterNameParam), a.wrap(a.getProcedureStats, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedureKindCounts", clusterNameParam), a.wrap(a.getProcedureKindCounts, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedure/:%s/export", clusterNameParam, procedureIDParam), a.wrap(a.exportProcedure, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedure/:%s/dispatches", clusterNameParam, procedureIDParam), a.wrap(a.getProcedureDispatches, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/procedure/replay", clusterNameParam), a.wrap(a.replayProcedure, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/tableAssignments", clusterNameParam), a.wrap(a.listTableAssignments, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/tableAssignments", clusterNameParam), a.wrap(a.destructive(a.clearTableAssignment), true))
+	router.Get("/shardAffinities", a.wrap(a.listAllShardAffinities, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), a.wrap(a.listShardAffinities, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), a.wrap(a.addShardAffinities, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), a.wrap(a.removeShardAffinities, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardAffinities/validate", clusterNameParam), a.wrap(a.validateShardAffinities, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardAffinities/removeByFilter", clusterNameParam), a.wrap(a.removeShardAffinitiesByFilter, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardMaintenance", clusterNameParam), a.wrap(a.listMaintenanceShards, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardMaintenance", clusterNameParam), a.wrap(a.setShardsMaintenance, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/shardMaintenance", clusterNameParam), a.wrap(a.clearShardsMaintenance, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardMinNodeVersions", clusterNameParam), a.wrap(a.listShardMinNodeVersions, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardMinNodeVersions", clusterNameParam), a.wrap(a.setShardsMinNodeVersion, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/shardMinNodeVersions", clusterNameParam), a.wrap(a.clearShardsMinNodeVersion, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/preferredLeaders", clusterNameParam), a.wrap(a.listPreferredLeaders, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/preferredLeaders", clusterNameParam), a.wrap(a.setPreferredLeader, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/preferredLeaders", clusterNameParam), a.wrap(a.clearPreferredLeader, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/nodePickerStrategy", clusterNameParam), a.wrap(a.getNodePickerStrategy, true))
+	router.Put(fmt.Sprintf("/clusters/:%s/nodePickerStrategy", clusterNameParam), a.wrap(a.updateNodePickerStrategy, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardPermutation", clusterNameParam), a.wrap(a.getShardPermutation, true))
+	router.Put(fmt.Sprintf("/clusters/:%s/shardPermutation", clusterNameParam), a.wrap(a.updateShardPermutation, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/rebalanceShards", clusterNameParam), a.wrap(a.rebalanceShards, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardWatch/resync", clusterNameParam), a.wrap(a.resyncShardWatch, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardNodes", clusterNameParam), a.wrap(a.listShardNodes, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardCount", clusterNameParam), a.wrap(a.getShardCount, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/nodeStatsHistory", clusterNameParam), a.wrap(a.listNodeStatsHistory, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/nodes/:%s/statsHistory", clusterNameParam, nodeNameParam), a.wrap(a.getNodeStatsHistory, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/shards/:%s/leaderHistory", clusterNameParam, shardIDParam), a.wrap(a.getShardLeaderHistory, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/shards/:%s/preWarm", clusterNameParam, shardIDParam), a.wrap(a.preWarmShard, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/topologyVersion", clusterNameParam), a.wrap(a.getTopologyVersion, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/quota", clusterNameParam), a.wrap(a.getClusterQuota, true))
+	router.Put(fmt.Sprintf("/clusters/:%s/quota", clusterNameParam), a.wrap(a.updateClusterQuota, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/defaultPartitionCount", clusterNameParam), a.wrap(a.getDefaultPartitionCount, true))
+	router.Put(fmt.Sprintf("/clusters/:%s/defaultPartitionCount", clusterNameParam), a.wrap(a.updateDefaultPartitionCount, true))
+	router.Get(fmt.Sprintf("/clusters/:%s/tableIDRanges", clusterNameParam), a.wrap(a.listTableIDRanges, true))
+	router.Post(fmt.Sprintf("/clusters/:%s/tableIDRanges", clusterNameParam), a.wrap(a.createTableIDRange, true))
+	router.Del(fmt.Sprintf("/clusters/:%s/tableIDRanges/:%s", clusterNameParam, schemaNameParam), a.wrap(a.destructive(a.deleteTableIDRange), true))
+	router.Get(fmt.Sprintf("/clusters/:%s/partitionTables/:%s", clusterNameParam, tableNameParam), a.wrap(a.getPartitionTableLayout, true))
+	router.Post("/table/query", a.wrap(a.queryTable, true))
+	router.Post("/table/exists", a.wrap(a.tableExists, true))
 
 	// Register debug API.
 	router.DebugGet("/pprof/profile", pprof.Profile)
@@ -88,18 +159,41 @@ func (a *API) NewAPIRouter() *Router {
 	router.DebugGet("/pprof/block", a.pprofBlock)
 	router.DebugGet("/pprof/goroutine", a.pprofGoroutine)
 	router.DebugGet("/pprof/threadCreate", a.pprofThreadCreate)
-	router.DebugGet(fmt.Sprintf("/diagnose/:%s/shards", clusterNameParam), wrap(a.diagnoseShards, true, a.forwardClient))
-	router.DebugGet("/leader", wrap(a.getLeader, false, a.forwardClient))
-	router.DebugGet(fmt.Sprintf("/clusters/:%s/enableSchedule", clusterNameParam), wrap(a.getEnableSchedule, true, a.forwardClient))
-	router.DebugPut(fmt.Sprintf("/clusters/:%s/enableSchedule", clusterNameParam), wrap(a.updateEnableSchedule, true, a.forwardClient))
+	router.DebugGet(fmt.Sprintf("/diagnose/:%s/shards", clusterNameParam), a.wrap(a.diagnoseShards, true))
+	router.DebugGet(fmt.Sprintf("/diagnose/:%s/replicas", clusterNameParam), a.wrap(a.diagnoseReplicas, true))
+	router.DebugGet(fmt.Sprintf("/diagnose/:%s/heartbeats", clusterNameParam), a.wrap(a.diagnoseHeartbeats, true))
+	router.DebugGet("/leader", a.wrap(a.getLeader, false))
+	router.DebugGet("/leader/stats", a.wrap(a.getLeaderStats, true))
+	router.DebugGet("/leader/lease", a.wrap(a.getLeaderLease, true))
+	router.DebugPost("/leader/lease/renew", a.wrap(a.renewLeaderLease, true))
+	router.DebugGet("/config", a.wrap(a.getEffectiveConfig, false))
+	router.DebugGet("/errors", a.wrap(a.listRecentErrors, false))
+	router.DebugGet("/status", a.wrap(a.getServerStatus, false))
+	router.DebugPost("/status/reset", a.wrap(a.destructive(a.resetServerStatus), false))
+	router.DebugGet("/etcd/status", a.wrap(a.etcdAPI.getStatus, false))
+	router.DebugGet("/faultInjection", a.wrap(a.listFaults, true))
+	router.DebugPut("/faultInjection", a.wrap(a.destructive(a.updateFaults), true))
+	router.DebugGet(fmt.Sprintf("/clusters/:%s/enableSchedule", clusterNameParam), a.wrap(a.getEnableSchedule, true))
+	router.DebugPut(fmt.Sprintf("/clusters/:%s/enableSchedule", clusterNameParam), a.wrap(a.updateEnableSchedule, true))
+	router.DebugGet(fmt.Sprintf("/clusters/:%s/schedulers", clusterNameParam), a.wrap(a.listSchedulers, true))
+	router.DebugPost(fmt.Sprintf("/clusters/:%s/closeTableOnShard", clusterNameParam), a.wrap(a.destructive(a.closeTableOnShard), true))
+	router.DebugPost(fmt.Sprintf("/clusters/:%s/closeGhostShard", clusterNameParam), a.wrap(a.destructive(a.closeGhostShard), true))
+	router.DebugPost(fmt.Sprintf("/clusters/:%s/advanceShardVersion", clusterNameParam), a.wrap(a.destructive(a.advanceShardVersion), true))
+	router.DebugPost(fmt.Sprintf("/clusters/:%s/nodes/:%s/expire", clusterNameParam, nodeNameParam), a.wrap(a.destructive(a.expireNode), true))
+	router.DebugPost(fmt.Sprintf("/clusters/:%s/simulateNodeLoss", clusterNameParam), a.wrap(a.simulateNodeLoss, true))
+
+	// Register metrics API.
+	router.RootGet("/metrics", promhttp.Handler().ServeHTTP)
 
 	// Register ETCD API.
-	router.Post("/etcd/promoteLearner", wrap(a.etcdAPI.promoteLearner, false, a.forwardClient))
-	router.Put("/etcd/member", wrap(a.etcdAPI.addMember, false, a.forwardClient))
-	router.Get("/etcd/member", wrap(a.etcdAPI.getMember, false, a.forwardClient))
-	router.Post("/etcd/member", wrap(a.etcdAPI.updateMember, false, a.forwardClient))
-	router.Del("/etcd/member", wrap(a.etcdAPI.removeMember, false, a.forwardClient))
-	router.Post("/etcd/moveLeader", wrap(a.etcdAPI.moveLeader, false, a.forwardClient))
+	router.Post("/etcd/promoteLearner", a.wrap(a.etcdAPI.promoteLearner, false))
+	router.Put("/etcd/member", a.wrap(a.etcdAPI.addMember, false))
+	router.Get("/etcd/member", a.wrap(a.etcdAPI.getMember, false))
+	router.Post("/etcd/member", a.wrap(a.etcdAPI.updateMember, false))
+	router.Del("/etcd/member", a.wrap(a.destructive(a.etcdAPI.removeMember), false))
+	router.Post("/etcd/moveLeader", a.wrap(a.etcdAPI.moveLeader, false))
+	router.Get("/etcd/compaction", a.wrap(a.etcdAPI.getCompaction, false))
+	router.Put("/etcd/compaction", a.wrap(a.etcdAPI.updateCompaction, true))
 
 	return router
 }
@@ -113,6 +207,69 @@ func (a *API) getLeader(req *http.Request) apiFuncResult {
 	return okResult(leaderAddr)
 }
 
+// getLeaderStats returns how long the current leader has held the leadership and how many elections are observed by the leader.
+func (a *API) getLeaderStats(_ *http.Request) apiFuncResult {
+	stats := a.forwardClient.GetLeaderStats()
+	result := LeaderStatsResult{
+		LeaderName:              stats.LeaderName,
+		LeaderEndpoint:          stats.LeaderEndpoint,
+		LeaderSince:             0,
+		UptimeMs:                0,
+		RecentElections:         stats.RecentElections,
+		RecentElectionWindowSec: int64(stats.RecentElectionWindow.Seconds()),
+		TotalElections:          stats.TotalElections,
+	}
+	if !stats.LeaderSince.IsZero() {
+		result.LeaderSince = stats.LeaderSince.UnixMilli()
+		result.UptimeMs = time.Since(stats.LeaderSince).Milliseconds()
+	}
+	return okResult(result)
+}
+
+// getLeaderLease returns the lease of the leader key, which helps to diagnose the premature leader loss.
+func (a *API) getLeaderLease(req *http.Request) apiFuncResult {
+	lease, err := a.forwardClient.GetLeaderLease(req.Context())
+	if err != nil {
+		return errResult(ErrGetLeaderLease, err.Error())
+	}
+	return okResult(convertLeaderLease(lease))
+}
+
+// renewLeaderLease forces the leader to renew the lease of the leader key once.
+func (a *API) renewLeaderLease(req *http.Request) apiFuncResult {
+	log.Info("try to renew leader lease")
+	lease, err := a.forwardClient.RenewLeaderLease(req.Context())
+	if err != nil {
+		log.Error("renew leader lease failed", zap.Error(err))
+		return errResult(ErrRenewLeaderLease, err.Error())
+	}
+	return okResult(convertLeaderLease(lease))
+}
+
+func convertLeaderLease(lease member.LeaderLease) LeaderLeaseResult {
+	return LeaderLeaseResult{
+		LeaseID:         lease.ID,
+		GrantedTTLSec:   lease.GrantedTTLSec,
+		RemainingTTLSec: lease.RemainingTTLSec,
+		ExpireTime:      lease.ExpireTime.UnixMilli(),
+	}
+}
+
+// stepDown makes the leader give up the leadership for the graceful maintenance, and returns the new leader once elected.
+func (a *API) stepDown(req *http.Request) apiFuncResult {
+	ctx, cancel := context.WithTimeout(req.Context(), stepDownTimeout)
+	defer cancel()
+
+	log.Info("try to step down as leader")
+	newLeaderEndpoint, err := a.forwardClient.StepDown(ctx, stepDownCampaignBackoff)
+	if err != nil {
+		log.Error("step down as leader failed", zap.Error(err))
+		return errResult(ErrStepDown, err.Error())
+	}
+
+	return okResult(StepDownResult{NewLeaderEndpoint: newLeaderEndpoint})
+}
+
 func (a *API) getShardTables(req *http.Request) apiFuncResult {
 	var getShardTablesReq GetShardTablesRequest
 	err := json.NewDecoder(req.Body).Decode(&getShardTablesReq)
@@ -161,6 +318,7 @@ func (a *API) transferLeader(req *http.Request) apiFuncResult {
 		ShardID:           storage.ShardID(transferLeaderRequest.ShardID),
 		OldLeaderNodeName: transferLeaderRequest.OldLeaderNodeName,
 		NewLeaderNodeName: transferLeaderRequest.NewLeaderNodeName,
+		Reason:            coordinator.TransferLeaderReasonManual,
 	})
 	if err != nil {
 		log.Error("create transfer leader procedure failed", zap.Error(err))
@@ -314,6 +472,41 @@ func (a *API) listClusters(req *http.Request) apiFuncResult {
 	return okResult(clusterMetadatas)
 }
 
+// listNodeClusters lists the clusters in which the node is registered ordered by the cluster name, and the list is empty if the
+// node is not registered in any cluster.
+func (a *API) listNodeClusters(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	nodeName := Param(ctx, nodeNameParam)
+	if len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	clusters, err := a.clusterManager.ListClusters(ctx)
+	if err != nil {
+		return errResult(ErrGetCluster, err.Error())
+	}
+
+	now := time.Now()
+	ret := []NodeCluster{}
+	for _, c := range clusters {
+		registeredNode, ok := c.GetMetadata().GetRegisteredNodeByName(nodeName)
+		if !ok {
+			continue
+		}
+		ret = append(ret, NodeCluster{
+			ClusterName:   c.GetMetadata().Name(),
+			LastTouchTime: registeredNode.Node.LastTouchTime,
+			Expired:       registeredNode.IsExpired(now),
+			ShardCount:    len(registeredNode.ShardInfos),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClusterName < ret[j].ClusterName
+	})
+
+	return okResult(ret)
+}
+
 func (a *API) createCluster(req *http.Request) apiFuncResult {
 	var createClusterRequest CreateClusterRequest
 	err := json.NewDecoder(req.Body).Decode(&createClusterRequest)
@@ -323,19 +516,14 @@ func (a *API) createCluster(req *http.Request) apiFuncResult {
 
 	log.Info("create cluster request", zap.String("request", fmt.Sprintf("%+v", createClusterRequest)))
 
-	if createClusterRequest.ProcedureExecutingBatchSize == 0 {
-		return errResult(ErrInvalidParamsForCreateCluster, "expect positive procedureExecutingBatchSize")
+	topologyType, errs := validateCreateClusterRequest(createClusterRequest)
+	if len(errs) > 0 {
+		return validationErrResult(ErrInvalidParamsForCreateCluster, errs)
 	}
 
 	if _, err := a.clusterManager.GetCluster(req.Context(), createClusterRequest.Name); err == nil {
 		log.Error("cluster already exists", zap.String("clusterName", createClusterRequest.Name))
-		return errResult(ErrGetCluster, fmt.Sprintf("cluster: %s already exists", createClusterRequest.Name))
-	}
-
-	topologyType, err := metadata.ParseTopologyType(createClusterRequest.TopologyType)
-	if err != nil {
-		log.Error("parse topology type failed", zap.Error(err))
-		return errResult(ErrParseRequest, err.Error())
+		return errResult(metadata.ErrClusterAlreadyExists, fmt.Sprintf("cluster: %s already exists", createClusterRequest.Name))
 	}
 
 	ctx := context.Background()
@@ -345,16 +533,93 @@ func (a *API) createCluster(req *http.Request) apiFuncResult {
 		EnableSchedule:              createClusterRequest.EnableSchedule,
 		TopologyType:                topologyType,
 		ProcedureExecutingBatchSize: createClusterRequest.ProcedureExecutingBatchSize,
+		ShardIDs:                    createClusterRequest.ShardIDs,
 	}
 	c, err := a.clusterManager.CreateCluster(ctx, createClusterRequest.Name, createClusterOpts)
 	if err != nil {
 		log.Error("create cluster failed", zap.Error(err))
+		if coderr.Is(err, metadata.ErrClusterAlreadyExists.Code()) {
+			return errResult(metadata.ErrClusterAlreadyExists, err.Error())
+		}
 		return errResult(metadata.ErrCreateCluster, err.Error())
 	}
 
 	return okResult(c.GetMetadata().GetClusterID())
 }
 
+func (a *API) cloneCluster(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var cloneClusterRequest CloneClusterRequest
+	if err := json.NewDecoder(req.Body).Decode(&cloneClusterRequest); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(cloneClusterRequest.Name) == 0 {
+		return errResult(ErrParseRequest, "name of the new cluster could not be empty")
+	}
+
+	log.Info("clone cluster request", zap.String("sourceCluster", clusterName), zap.String("request", fmt.Sprintf("%+v", cloneClusterRequest)))
+
+	source, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+	if _, err := a.clusterManager.GetCluster(ctx, cloneClusterRequest.Name); err == nil {
+		log.Error("cluster already exists", zap.String("clusterName", cloneClusterRequest.Name))
+		return errResult(metadata.ErrClusterAlreadyExists, fmt.Sprintf("cluster: %s already exists", cloneClusterRequest.Name))
+	}
+
+	c, err := a.clusterManager.CloneCluster(ctx, clusterName, cloneClusterRequest.Name)
+	if err != nil {
+		log.Error("clone cluster failed", zap.Error(err))
+		return errResult(ErrCloneCluster, err.Error())
+	}
+
+	result := CloneClusterResult{
+		ClusterID:    c.GetMetadata().GetClusterID(),
+		CopiedTables: 0,
+	}
+	if cloneClusterRequest.CopyTables {
+		copiedTables, err := c.GetMetadata().CopyTableMetadataFrom(ctx, source.GetMetadata())
+		if err != nil {
+			log.Error("copy table metadata failed", zap.Error(err))
+			return errResult(ErrCloneCluster, fmt.Sprintf("cluster is created but copy table metadata failed, clusterName: %s, err: %s", cloneClusterRequest.Name, err.Error()))
+		}
+		result.CopiedTables = copiedTables
+	}
+
+	return okResult(result)
+}
+
+// compareClusters reports the structural differences between the shard and table layouts of the cluster and the target cluster,
+// which is used to verify the layout produced by a clone or migration.
+func (a *API) compareClusters(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	targetClusterName := req.URL.Query().Get(targetClusterQuery)
+	if len(targetClusterName) == 0 {
+		return errResult(ErrParseRequest, "target could not be empty")
+	}
+
+	source, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+	target, err := a.clusterManager.GetCluster(ctx, targetClusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", targetClusterName, err.Error()))
+	}
+
+	return okResult(metadata.CompareTopology(source.GetMetadata(), target.GetMetadata()))
+}
+
 func (a *API) updateCluster(req *http.Request) apiFuncResult {
 	clusterName := Param(req.Context(), clusterNameParam)
 	if len(clusterName) == 0 {
@@ -369,59 +634,82 @@ func (a *API) updateCluster(req *http.Request) apiFuncResult {
 
 	log.Info("update cluster request", zap.String("request", fmt.Sprintf("%+v", updateClusterRequest)))
 
+	topologyType, errs := validateUpdateClusterRequest(updateClusterRequest)
+	if len(errs) > 0 {
+		return validationErrResult(ErrInvalidParamsForUpdateCluster, errs)
+	}
+
 	c, err := a.clusterManager.GetCluster(req.Context(), clusterName)
 	if err != nil {
 		log.Error("get cluster failed", zap.Error(err))
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	topologyType, err := metadata.ParseTopologyType(updateClusterRequest.TopologyType)
-	if err != nil {
-		log.Error("parse topology type", zap.Error(err))
-		return errResult(ErrParseTopology, err.Error())
-	}
-
 	if err := a.clusterManager.UpdateCluster(req.Context(), clusterName, metadata.UpdateClusterOpts{
 		TopologyType:                topologyType,
 		ProcedureExecutingBatchSize: updateClusterRequest.ProcedureExecutingBatchSize,
 	}); err != nil {
 		log.Error("update cluster failed", zap.Error(err))
+		if errors.Is(err, metadata.ErrUnsafeTopologyTypeChange) {
+			return errResult(metadata.ErrUnsafeTopologyTypeChange, err.Error())
+		}
 		return errResult(metadata.ErrUpdateCluster, err.Error())
 	}
 
 	return okResult(c.GetMetadata().GetClusterID())
 }
 
-func (a *API) getFlowLimiter(_ *http.Request) apiFuncResult {
-	limiter := a.flowLimiter.GetConfig()
-	return okResult(limiter)
-}
+// getTopologyVersion returns the version of the cluster view only, which is much cheaper than routing tables for clients to detect topology changes.
+func (a *API) getTopologyVersion(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
 
-func (a *API) updateFlowLimiter(req *http.Request) apiFuncResult {
-	var updateFlowLimiterRequest UpdateFlowLimiterRequest
-	err := json.NewDecoder(req.Body).Decode(&updateFlowLimiterRequest)
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
 	if err != nil {
-		log.Error("decode request body failed", zap.Error(err))
-		return errResult(ErrParseRequest, err.Error())
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	log.Info("update flow limiter request", zap.String("request", fmt.Sprintf("%+v", updateFlowLimiterRequest)))
+	return okResult(TopologyVersion{Version: c.GetMetadata().GetClusterViewVersion()})
+}
 
-	newLimiterConfig := config.LimiterConfig{
-		Enable: updateFlowLimiterRequest.Enable,
-		Limit:  updateFlowLimiterRequest.Limit,
-		Burst:  updateFlowLimiterRequest.Burst,
+// listShardNodes dumps the shard node mapping in the cluster view as a flat list ordered by the shard id.
+func (a *API) listShardNodes(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
 
-	if err := a.flowLimiter.UpdateLimiter(newLimiterConfig); err != nil {
-		log.Error("update flow limiter failed", zap.Error(err))
-		return errResult(ErrUpdateFlowLimiter, err.Error())
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	return okResult(statusSuccess)
+	topology := c.GetMetadata().GetClusterSnapshot().Topology
+	ret := make([]ShardNodeItem, 0, len(topology.ClusterView.ShardNodes))
+	for _, shardNode := range topology.ClusterView.ShardNodes {
+		ret = append(ret, ShardNodeItem{
+			ShardID:  shardNode.ID,
+			NodeName: shardNode.NodeName,
+			Role:     storage.ConvertShardRoleToString(shardNode.ShardRole),
+			Version:  topology.ShardViewsMapping[shardNode.ID].Version,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].ShardID != ret[j].ShardID {
+			return ret[i].ShardID < ret[j].ShardID
+		}
+		return ret[i].NodeName < ret[j].NodeName
+	})
+
+	return okResult(ret)
 }
 
-func (a *API) listProcedures(req *http.Request) apiFuncResult {
+// getShardCount returns the number of the shards and the shards not assigned to any node yet, which tells whether all the shards are assigned.
+func (a *API) getShardCount(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
@@ -433,16 +721,32 @@ func (a *API) listProcedures(req *http.Request) apiFuncResult {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	infos, err := c.GetProcedureManager().ListRunningProcedure(ctx)
-	if err != nil {
-		log.Error("list running procedure failed", zap.Error(err))
-		return errResult(procedure.ErrListRunningProcedure, fmt.Sprintf("clusterName: %s", clusterName))
+	topology := c.GetMetadata().GetClusterSnapshot().Topology
+	assignedShards := make(map[storage.ShardID]struct{}, len(topology.ClusterView.ShardNodes))
+	for _, shardNode := range topology.ClusterView.ShardNodes {
+		assignedShards[shardNode.ID] = struct{}{}
 	}
 
-	return okResult(infos)
+	ret := ShardCountResult{
+		Total:            len(topology.ShardViewsMapping),
+		Assigned:         0,
+		UnassignedShards: []storage.ShardID{},
+	}
+	for shardID := range topology.ShardViewsMapping {
+		if _, ok := assignedShards[shardID]; ok {
+			ret.Assigned++
+			continue
+		}
+		ret.UnassignedShards = append(ret.UnassignedShards, shardID)
+	}
+	sort.Slice(ret.UnassignedShards, func(i, j int) bool { return ret.UnassignedShards[i] < ret.UnassignedShards[j] })
+
+	return okResult(ret)
 }
 
-func (a *API) listShardAffinities(req *http.Request) apiFuncResult {
+// listNodeStatsHistory returns the utilization history of all the nodes sampled from their heartbeats, nodeName -> samples.
+// The history is kept in the memory of the leader, and only covers the heartbeats since it becomes the leader.
+func (a *API) listNodeStatsHistory(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
@@ -454,109 +758,125 @@ func (a *API) listShardAffinities(req *http.Request) apiFuncResult {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	affinityRules, err := c.GetSchedulerManager().ListShardAffinityRules(ctx)
-	if err != nil {
-		return errResult(ErrListAffinityRules, fmt.Sprintf("err: %v", err))
+	history := c.GetMetadata().ListNodeStatsHistory()
+	ret := make(map[string][]NodeStatsSample, len(history))
+	for nodeName, samples := range history {
+		ret[nodeName] = convertNodeStatsSamples(samples)
 	}
-
-	return okResult(affinityRules)
+	return okResult(ret)
 }
 
-func (a *API) addShardAffinities(req *http.Request) apiFuncResult {
+// getNodeStatsHistory returns the utilization history of the node from the oldest to the latest sample.
+func (a *API) getNodeStatsHistory(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
 		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
-
-	var affinities []scheduler.ShardAffinity
-	err := json.NewDecoder(req.Body).Decode(&affinities)
-	if err != nil {
-		log.Error("decode request body failed", zap.Error(err))
-		return errResult(ErrParseRequest, err.Error())
+	nodeName := Param(ctx, nodeNameParam)
+	if len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
 	}
 
-	log.Info("try to apply shard affinity rule", zap.String("cluster", clusterName), zap.String("affinity", fmt.Sprintf("%+v", affinities)))
-
 	c, err := a.clusterManager.GetCluster(ctx, clusterName)
 	if err != nil {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	err = c.GetSchedulerManager().AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: affinities})
-	if err != nil {
-		log.Error("failed to apply shard affinity rule", zap.String("cluster", clusterName), zap.String("affinity", fmt.Sprintf("%+v", affinities)))
-		return errResult(ErrAddAffinityRule, fmt.Sprintf("err: %v", err))
+	return okResult(convertNodeStatsSamples(c.GetMetadata().GetNodeStatsHistory(nodeName)))
+}
+
+func convertNodeStatsSamples(samples []metadata.NodeStatsSample) []NodeStatsSample {
+	ret := make([]NodeStatsSample, 0, len(samples))
+	for _, sample := range samples {
+		ret = append(ret, NodeStatsSample{
+			Timestamp:         sample.Timestamp,
+			ShardCount:        sample.ShardCount,
+			LeaderShardCount:  sample.LeaderShardCount,
+			UnreadyShardCount: sample.UnreadyShardCount,
+			TableCount:        sample.TableCount,
+			ShuttingDown:      sample.ShuttingDown,
+		})
+	}
+	return ret
+}
+
+func (a *API) getClusterQuota(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
 
-	log.Info("finish applying shard affinity rule", zap.String("cluster", clusterName), zap.String("rules", fmt.Sprintf("%+v", affinities)))
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
 
-	return okResult(nil)
+	topology := c.GetMetadata().GetClusterSnapshot().Topology
+	return okResult(ClusterQuota{
+		MaxTables:  c.GetMetadata().GetMaxTables(),
+		TableCount: topology.TableCount(),
+	})
 }
 
-func (a *API) removeShardAffinities(req *http.Request) apiFuncResult {
+func (a *API) updateClusterQuota(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
 		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
 
-	var decodedReq RemoveShardAffinitiesRequest
-	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	var updateClusterQuotaRequest UpdateClusterQuotaRequest
+	err := json.NewDecoder(req.Body).Decode(&updateClusterQuotaRequest)
 	if err != nil {
-		log.Error("decode request body failed", zap.Error(err))
 		return errResult(ErrParseRequest, err.Error())
 	}
 
+	log.Info("update cluster quota request", zap.String("clusterName", clusterName), zap.String("request", fmt.Sprintf("%+v", updateClusterQuotaRequest)))
+
 	c, err := a.clusterManager.GetCluster(ctx, clusterName)
 	if err != nil {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	for _, shardID := range decodedReq.ShardIDs {
-		log.Info("try to remove shard affinity rule", zap.String("cluster", clusterName), zap.Int("shardID", int(shardID)))
-		err := c.GetSchedulerManager().RemoveShardAffinityRule(ctx, shardID)
-		if err != nil {
-			log.Error("failed to remove shard affinity rule", zap.String("cluster", clusterName), zap.Int("shardID", int(shardID)), zap.Error(err))
-			return errResult(ErrRemoveAffinityRule, fmt.Sprintf("err: %s", err))
-		}
+	if err := c.GetMetadata().UpdateMaxTables(ctx, updateClusterQuotaRequest.MaxTables); err != nil {
+		log.Error("update cluster quota failed", zap.String("clusterName", clusterName), zap.Error(err))
+		return errResult(ErrUpdateClusterQuota, err.Error())
 	}
 
-	return okResult(nil)
+	return okResult(statusSuccess)
 }
 
-func (a *API) queryTable(r *http.Request) apiFuncResult {
-	var req QueryTableRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		return errResult(ErrParseRequest, err.Error())
-	}
-
-	if len(req.Names) != 0 {
-		tables, err := a.clusterManager.GetTables(req.ClusterName, req.SchemaName, req.Names)
-		if err != nil {
-			return errResult(ErrTable, err.Error())
-		}
-		return okResult(tables)
-	}
-
-	ids := make([]storage.TableID, 0, len(req.IDs))
-	for _, id := range req.IDs {
-		ids = append(ids, storage.TableID(id))
+func (a *API) getDefaultPartitionCount(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
 
-	tables, err := a.clusterManager.GetTablesByIDs(req.ClusterName, ids)
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
 	if err != nil {
-		return errResult(ErrTable, err.Error())
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
-	return okResult(tables)
+
+	return okResult(DefaultPartitionCount{
+		Count:      c.GetMetadata().GetDefaultPartitionCount(),
+		ShardTotal: c.GetMetadata().GetTotalShardNum(),
+	})
 }
 
-func (a *API) getEnableSchedule(r *http.Request) apiFuncResult {
-	ctx := r.Context()
+// updateDefaultPartitionCount updates the partition count applied to the partition tables created without partitions.
+func (a *API) updateDefaultPartitionCount(req *http.Request) apiFuncResult {
+	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
-		clusterName = config.DefaultClusterName
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq UpdateDefaultPartitionCountRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
 	}
 
 	c, err := a.clusterManager.GetCluster(ctx, clusterName)
@@ -564,19 +884,23 @@ func (a *API) getEnableSchedule(r *http.Request) apiFuncResult {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	enableSchedule, err := c.GetSchedulerManager().GetEnableSchedule(r.Context())
-	if err != nil {
-		return errResult(ErrGetEnableSchedule, err.Error())
+	log.Info("update default partition count request", zap.String("clusterName", clusterName), zap.Uint32("count", decodedReq.Count))
+	if err := c.GetMetadata().UpdateDefaultPartitionCount(ctx, decodedReq.Count); err != nil {
+		log.Error("update default partition count failed", zap.String("clusterName", clusterName), zap.Error(err))
+		if coderr.Is(err, metadata.ErrInvalidPartitionCount.Code()) {
+			return errResult(metadata.ErrInvalidPartitionCount, err.Error())
+		}
+		return errResult(ErrUpdateDefaultPartitionCount, err.Error())
 	}
 
-	return okResult(enableSchedule)
+	return okResult(statusSuccess)
 }
 
-func (a *API) updateEnableSchedule(r *http.Request) apiFuncResult {
-	ctx := r.Context()
+func (a *API) listTableIDRanges(req *http.Request) apiFuncResult {
+	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
-		clusterName = config.DefaultClusterName
+		return errResult(ErrParseRequest, "clusterName could not be empty")
 	}
 
 	c, err := a.clusterManager.GetCluster(ctx, clusterName)
@@ -584,25 +908,56 @@ func (a *API) updateEnableSchedule(r *http.Request) apiFuncResult {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	var req UpdateEnableScheduleRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
+	return okResult(c.GetMetadata().ListTableIDRanges())
+}
+
+// createTableIDRange reserves the range of the table ids for the schema, which can't be changed once created.
+func (a *API) createTableIDRange(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq CreateTableIDRangeRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
 		return errResult(ErrParseRequest, err.Error())
 	}
 
-	err = c.GetSchedulerManager().UpdateEnableSchedule(r.Context(), req.Enable)
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
 	if err != nil {
-		return errResult(ErrUpdateEnableSchedule, err.Error())
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	return okResult(req.Enable)
+	log.Info("create table id range request", zap.String("clusterName", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().CreateTableIDRange(ctx, storage.TableIDRange{
+		SchemaName: decodedReq.SchemaName,
+		Start:      decodedReq.Start,
+		End:        decodedReq.End,
+	}); err != nil {
+		log.Error("create table id range failed", zap.String("clusterName", clusterName), zap.Error(err))
+		if errors.Is(err, metadata.ErrInvalidTableIDRange) {
+			return errResult(metadata.ErrInvalidTableIDRange, err.Error())
+		}
+		if errors.Is(err, metadata.ErrTableIDRangeOverlap) {
+			return errResult(metadata.ErrTableIDRangeOverlap, err.Error())
+		}
+		return errResult(ErrCreateTableIDRange, err.Error())
+	}
+
+	return okResult(statusSuccess)
 }
 
-func (a *API) diagnoseShards(req *http.Request) apiFuncResult {
+// deleteTableIDRange deletes the table id range of the schema, which is only allowed if no table id has been allocated in it.
+func (a *API) deleteTableIDRange(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
 	if len(clusterName) == 0 {
-		clusterName = config.DefaultClusterName
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	schemaName := Param(ctx, schemaNameParam)
+	if len(schemaName) == 0 {
+		return errResult(ErrParseRequest, "schemaName could not be empty")
 	}
 
 	c, err := a.clusterManager.GetCluster(ctx, clusterName)
@@ -610,41 +965,1731 @@ func (a *API) diagnoseShards(req *http.Request) apiFuncResult {
 		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
 	}
 
-	registeredNodes, err := a.clusterManager.ListRegisteredNodes(ctx, clusterName)
-	if err != nil {
-		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	log.Info("delete table id range request", zap.String("clusterName", clusterName), zap.String("schemaName", schemaName))
+	if err := c.GetMetadata().DeleteTableIDRange(ctx, schemaName); err != nil {
+		log.Error("delete table id range failed", zap.String("clusterName", clusterName), zap.Error(err))
+		if errors.Is(err, metadata.ErrTableIDRangeNotFound) {
+			return errResult(metadata.ErrTableIDRangeNotFound, err.Error())
+		}
+		if errors.Is(err, metadata.ErrTableIDRangeInUse) {
+			return errResult(metadata.ErrTableIDRangeInUse, err.Error())
+		}
+		return errResult(ErrDeleteTableIDRange, err.Error())
 	}
 
-	ret := DiagnoseShardResult{
-		UnregisteredShards: []storage.ShardID{},
-		UnreadyShards:      make(map[storage.ShardID]DiagnoseShardStatus),
+	return okResult(statusSuccess)
+}
+
+// exportMetadata streams the metadata of all the clusters as a readable json document for auditing and diffing.
+func (a *API) exportMetadata(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	clusters, err := a.clusterManager.ListClusters(ctx)
+	if err != nil {
+		log.Error("list clusters failed", zap.Error(err))
+		respondErrorsOf(req)(w, ErrExportMetadata, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	// The status has been sent, so the failure can only be logged, and the client gets a truncated json document.
+	if err := newMetadataExporter(w).export(clusters); err != nil {
+		log.Error("export metadata failed", zap.Error(err))
+	}
+}
+
+func (a *API) getNodePickerStrategy(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(NodePickerStrategy{
+		Strategy:             c.GetMetadata().GetNodePickerStrategy(),
+		RegisteredStrategies: nodepicker.RegisteredStrategies(),
+	})
+}
+
+// updateNodePickerStrategy changes the node picker strategy of the cluster, which takes effect when the schedulers are
+// initialized next time.
+func (a *API) updateNodePickerStrategy(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq UpdateNodePickerStrategyRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update node picker strategy", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetSchedulerManager().UpdateNodePickerStrategy(ctx, decodedReq.Strategy); err != nil {
+		log.Error("failed to update node picker strategy", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateNodePickerStrategy, err.Error())
+	}
+
+	return okResult(statusSuccess)
+}
+
+func (a *API) getShardPermutation(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(ShardPermutation{Enabled: c.GetMetadata().IsShardPermutationEnabled()})
+}
+
+// updateShardPermutation enables or disables the permutation of the shard ids before placement, and the shards are
+// rebalanced by the schedulers afterwards.
+func (a *API) updateShardPermutation(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq UpdateShardPermutationRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("update shard permutation", zap.String("cluster", clusterName), zap.Bool("enabled", decodedReq.Enabled))
+	if err := c.GetMetadata().SetShardPermutation(ctx, decodedReq.Enabled); err != nil {
+		log.Error("failed to update shard permutation", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateShardPermutation, err.Error())
+	}
+
+	return okResult(statusSuccess)
+}
+
+func (a *API) getFlowLimiter(_ *http.Request) apiFuncResult {
+	return okResult(FlowLimiterResult{
+		LimiterConfig:      *a.flowLimiter.GetConfig(),
+		AdaptiveMultiplier: a.flowLimiter.AdaptiveMultiplier(),
+	})
+}
+
+func (a *API) updateFlowLimiter(req *http.Request) apiFuncResult {
+	var updateFlowLimiterRequest UpdateFlowLimiterRequest
+	err := json.NewDecoder(req.Body).Decode(&updateFlowLimiterRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("update flow limiter request", zap.String("request", fmt.Sprintf("%+v", updateFlowLimiterRequest)))
+
+	if updateFlowLimiterRequest.Limit != nil && *updateFlowLimiterRequest.Limit <= 0 {
+		return errResult(ErrParseRequest, fmt.Sprintf("limit must be positive, limit:%d", *updateFlowLimiterRequest.Limit))
+	}
+	if updateFlowLimiterRequest.Burst != nil && *updateFlowLimiterRequest.Burst <= 0 {
+		return errResult(ErrParseRequest, fmt.Sprintf("burst must be positive, burst:%d", *updateFlowLimiterRequest.Burst))
+	}
+	for _, cost := range []*int{updateFlowLimiterRequest.CreateTableCost, updateFlowLimiterRequest.CreatePartitionTableCost, updateFlowLimiterRequest.DropTableCost, updateFlowLimiterRequest.RouteTablesCost} {
+		if cost != nil && *cost <= 0 {
+			return errResult(ErrParseRequest, fmt.Sprintf("cost of the operation must be positive, cost:%d", *cost))
+		}
+	}
+
+	var matches func(config.LimiterConfig) bool
+	if updateFlowLimiterRequest.Expected != nil {
+		matches = updateFlowLimiterRequest.Expected.matches
+	}
+	// The omitted fields are filled with the current config atomically, so that the partial updates don't clobber each other.
+	_, err = a.flowLimiter.CompareAndUpdateLimiter(matches, func(currentConfig config.LimiterConfig) config.LimiterConfig {
+		return config.LimiterConfig{
+			Enable: valueOrDefault(updateFlowLimiterRequest.Enable, currentConfig.Enable),
+			Limit:  valueOrDefault(updateFlowLimiterRequest.Limit, currentConfig.Limit),
+			Burst:  valueOrDefault(updateFlowLimiterRequest.Burst, currentConfig.Burst),
+
+			CreateTableCost:          valueOrDefault(updateFlowLimiterRequest.CreateTableCost, currentConfig.CreateTableCost),
+			CreatePartitionTableCost: valueOrDefault(updateFlowLimiterRequest.CreatePartitionTableCost, currentConfig.CreatePartitionTableCost),
+			DropTableCost:            valueOrDefault(updateFlowLimiterRequest.DropTableCost, currentConfig.DropTableCost),
+			RouteTablesCost:          valueOrDefault(updateFlowLimiterRequest.RouteTablesCost, currentConfig.RouteTablesCost),
+		}
+	})
+	if err != nil {
+		log.Error("update flow limiter failed", zap.Error(err))
+		if coderr.Is(err, limiter.ErrLimiterConfigConflict.Code()) {
+			return errResult(limiter.ErrLimiterConfigConflict, err.Error())
+		}
+		return errResult(ErrUpdateFlowLimiter, err.Error())
+	}
+
+	return okResult(statusSuccess)
+}
+
+func (a *API) getScanLimit(_ *http.Request) apiFuncResult {
+	limit := a.clusterManager.GetScanLimit()
+	return okResult(ScanLimitResult{
+		MinScanLimit: limit.Min,
+		MaxScanLimit: limit.Max,
+	})
+}
+
+func (a *API) updateScanLimit(req *http.Request) apiFuncResult {
+	var updateScanLimitRequest UpdateScanLimitRequest
+	err := json.NewDecoder(req.Body).Decode(&updateScanLimitRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("update scan limit request", zap.String("request", fmt.Sprintf("%+v", updateScanLimitRequest)))
+
+	if err := a.clusterManager.UpdateMaxScanLimit(req.Context(), updateScanLimitRequest.MaxScanLimit); err != nil {
+		log.Error("update scan limit failed", zap.Error(err))
+		return errResult(ErrUpdateScanLimit, err.Error())
+	}
+
+	return okResult(statusSuccess)
+}
+
+// valueOrDefault returns the value if it is specified, otherwise the default value.
+func valueOrDefault[T any](value *T, defaultValue T) T {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
+
+// fieldMatches returns true if the expected value is omitted or equals to the actual one.
+func fieldMatches[T comparable](expected *T, actual T) bool {
+	return expected == nil || *expected == actual
+}
+
+func (a *API) listProcedures(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	infos, err := c.GetProcedureManager().ListRunningProcedure(ctx)
+	if err != nil {
+		log.Error("list running procedure failed", zap.Error(err))
+		return errResult(procedure.ErrListRunningProcedure, fmt.Sprintf("clusterName: %s", clusterName))
+	}
+
+	return okResult(infos)
+}
+
+// getProcedure returns the info of the running procedure, including its progress if it is made of multiple steps.
+func (a *API) getProcedure(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	procedureID, err := strconv.ParseUint(Param(ctx, procedureIDParam), 10, 64)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid procedureID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	infos, err := c.GetProcedureManager().ListRunningProcedure(ctx)
+	if err != nil {
+		log.Error("list running procedure failed", zap.Error(err))
+		return errResult(procedure.ErrListRunningProcedure, fmt.Sprintf("clusterName: %s", clusterName))
+	}
+	for _, info := range infos {
+		if info.ID == procedureID {
+			return okResult(info)
+		}
+	}
+
+	return errResult(procedure.ErrProcedureNotFound, fmt.Sprintf("procedure is not running, clusterName: %s, procedureID: %d", clusterName, procedureID))
+}
+
+func (a *API) purgeFinishedProcedures(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var purgeReq PurgeProceduresRequest
+	if err := json.NewDecoder(req.Body).Decode(&purgeReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if purgeReq.RetentionSec < 0 {
+		return errResult(ErrParseRequest, fmt.Sprintf("retentionSec could not be negative, retentionSec:%d", purgeReq.RetentionSec))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	result, err := c.PurgeFinishedProcedures(ctx, time.Duration(purgeReq.RetentionSec)*time.Second, purgeReq.DryRun)
+	if err != nil {
+		log.Error("purge finished procedures failed", zap.Error(err))
+		return errResult(ErrPurgeProcedures, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(result)
+}
+
+// cancelProcedures cancels all the running procedures of the kind, e.g. all the splits during an incident.
+func (a *API) cancelProcedures(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var cancelReq CancelProceduresRequest
+	if err := json.NewDecoder(req.Body).Decode(&cancelReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	kind, err := procedure.ParseKind(cancelReq.Kind)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Warn("cancel procedures", zap.String("clusterName", clusterName), zap.String("kind", cancelReq.Kind))
+	result, err := c.GetProcedureManager().CancelProceduresOfKind(ctx, kind)
+	if err != nil {
+		log.Error("cancel procedures failed", zap.Error(err))
+		return errResult(ErrCancelProcedures, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(result)
+}
+
+func (a *API) getProcedureStats(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	window := procedure.DefaultStatsWindow
+	if rawWindowSec := req.URL.Query().Get(windowSecQuery); len(rawWindowSec) > 0 {
+		windowSec, err := strconv.ParseInt(rawWindowSec, 10, 64)
+		if err != nil || windowSec <= 0 {
+			return errResult(ErrParseRequest, fmt.Sprintf("windowSec must be a positive integer, windowSec:%s", rawWindowSec))
+		}
+		window = time.Duration(windowSec) * time.Second
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	stats, err := c.ProcedureStats(ctx, window)
+	if err != nil {
+		log.Error("collect procedure stats failed", zap.Error(err))
+		return errResult(ErrProcedureStats, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(stats)
+}
+
+// getProcedureKindCounts returns the number of the running procedures of every kind, which is a minimal signal for the dashboards
+// compared with the procedure stats.
+func (a *API) getProcedureKindCounts(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	counts, err := c.ProcedureKindCounts(ctx)
+	if err != nil {
+		log.Error("count running procedures failed", zap.Error(err))
+		return errResult(ErrProcedureStats, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(counts)
+}
+
+// exportProcedure exports the inputs of a DDL or split procedure, the other kinds are rejected as not replayable.
+func (a *API) exportProcedure(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	procedureID, err := strconv.ParseUint(Param(ctx, procedureIDParam), 10, 64)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid procedureID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	def, err := c.ExportProcedure(ctx, procedureID)
+	if err != nil {
+		log.Error("export procedure failed", zap.Uint64("procedureID", procedureID), zap.Error(err))
+		if coderr.Is(err, coordinator.ErrProcedureNotReplayable.Code()) {
+			return errResult(coordinator.ErrProcedureNotReplayable, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+		}
+		return errResult(ErrExportProcedure, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(def)
+}
+
+// getProcedureDispatches returns the events dispatched to the data nodes by the procedure from the oldest, including their targets, results and latencies.
+func (a *API) getProcedureDispatches(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	procedureID, err := strconv.ParseUint(Param(ctx, procedureIDParam), 10, 64)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid procedureID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetProcedureDispatches(procedureID))
+}
+
+// getShardLeaderHistory returns the recent leader changes of the shard from the oldest to the newest.
+func (a *API) getShardLeaderHistory(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	shardID, err := strconv.ParseUint(Param(ctx, shardIDParam), 10, 32)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid shardID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+	if _, ok := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[storage.ShardID(shardID)]; !ok {
+		return errResult(ErrParseRequest, fmt.Sprintf("shard not found, shardID:%d", shardID))
+	}
+
+	history, err := c.GetMetadata().GetShardLeaderHistory(ctx, storage.ShardID(shardID))
+	if err != nil {
+		log.Error("get shard leader history failed", zap.Uint64("shardID", shardID), zap.Error(err))
+		return errResult(ErrGetShardLeaderHistory, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(history)
+}
+
+// preWarmShard hints the leader of the shard to pre-allocate the resources before many tables are created on the shard, e.g. by a bulk load.
+func (a *API) preWarmShard(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	shardID, err := strconv.ParseUint(Param(ctx, shardIDParam), 10, 32)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid shardID, err: %s", err.Error()))
+	}
+
+	var preWarmReq PreWarmShardRequest
+	if err := json.NewDecoder(req.Body).Decode(&preWarmReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	nodeName, dispatched, err := c.PreWarmShard(ctx, storage.ShardID(shardID), preWarmReq.ExpectedTableCount)
+	if err != nil {
+		return errResult(ErrPreWarmShard, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(PreWarmShardResult{
+		ShardID:    storage.ShardID(shardID),
+		NodeName:   nodeName,
+		Dispatched: dispatched,
+	})
+}
+
+// replayProcedure submits a new procedure reconstructed from the definition exported by exportProcedure.
+func (a *API) replayProcedure(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var def coordinator.ProcedureDefinition
+	if err := json.NewDecoder(req.Body).Decode(&def); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	procedureID, err := c.ReplayProcedure(ctx, def)
+	if err != nil {
+		log.Error("replay procedure failed", zap.Uint64("sourceProcedureID", def.SourceID), zap.Error(err))
+		if coderr.Is(err, coordinator.ErrProcedureNotReplayable.Code()) {
+			return errResult(coordinator.ErrProcedureNotReplayable, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+		}
+		return errResult(ErrReplayProcedure, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(procedureID)
+}
+
+// listTableAssignments lists the shards persisted by the shard picker for the tables being created.
+func (a *API) listTableAssignments(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetMetadata().ListTableAssignments())
+}
+
+// clearTableAssignment removes the stale shard persisted by the shard picker for the table, which mis-routes the table created again.
+func (a *API) clearTableAssignment(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq ClearTableAssignmentRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.SchemaName) == 0 || len(decodedReq.TableName) == 0 {
+		return errResult(ErrParseRequest, "schemaName and tableName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Warn("try to clear table assignment", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().ClearTableAssignment(ctx, decodedReq.SchemaName, decodedReq.TableName, decodedReq.ShardID); err != nil {
+		log.Error("failed to clear table assignment", zap.String("cluster", clusterName), zap.Error(err))
+		for _, expectedErr := range []coderr.CodeError{metadata.ErrSchemaNotFound, metadata.ErrTableAssignmentNotFound, metadata.ErrTableAssignmentMismatch} {
+			if errors.Is(err, expectedErr) {
+				return errResult(expectedErr, err.Error())
+			}
+		}
+		return errResult(ErrClearTableAssignment, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) listShardAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	affinityRules, err := c.GetSchedulerManager().ListShardAffinityRules(ctx)
+	if err != nil {
+		return errResult(ErrListAffinityRules, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(affinityRules)
+}
+
+// listAllShardAffinities lists the shard affinity rules of all the clusters, and a cluster whose rules can't be listed is
+// reported with its error instead of failing the whole request.
+func (a *API) listAllShardAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusters, err := a.clusterManager.ListClusters(ctx)
+	if err != nil {
+		log.Error("list clusters failed", zap.Error(err))
+		return errResult(ErrListAffinityRules, err.Error())
+	}
+
+	result := make([]ClusterShardAffinityRules, 0, len(clusters))
+	for _, c := range clusters {
+		clusterName := c.GetMetadata().Name()
+		rules, err := c.GetSchedulerManager().ListShardAffinityRules(ctx)
+		if err != nil {
+			log.Warn("list shard affinity rules failed", zap.String("cluster", clusterName), zap.Error(err))
+			result = append(result, ClusterShardAffinityRules{ClusterName: clusterName, Rules: nil, Error: err.Error()})
+			continue
+		}
+		result = append(result, ClusterShardAffinityRules{ClusterName: clusterName, Rules: rules, Error: ""})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ClusterName < result[j].ClusterName
+	})
+
+	return okResult(result)
+}
+
+func (a *API) addShardAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var affinities []scheduler.ShardAffinity
+	err := json.NewDecoder(req.Body).Decode(&affinities)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("try to apply shard affinity rule", zap.String("cluster", clusterName), zap.String("affinity", fmt.Sprintf("%+v", affinities)))
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	if errs := validateShardAffinities(c.GetMetadata().GetClusterSnapshot(), affinities); len(errs) > 0 {
+		return validationErrResult(ErrInvalidShardAffinities, errs)
+	}
+
+	err = c.GetSchedulerManager().AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: affinities})
+	if err != nil {
+		log.Error("failed to apply shard affinity rule", zap.String("cluster", clusterName), zap.String("affinity", fmt.Sprintf("%+v", affinities)))
+		return errResult(ErrAddAffinityRule, fmt.Sprintf("err: %v", err))
+	}
+
+	log.Info("finish applying shard affinity rule", zap.String("cluster", clusterName), zap.String("rules", fmt.Sprintf("%+v", affinities)))
+
+	return okResult(nil)
+}
+
+// validateShardAffinities checks whether the proposed shard affinities can be satisfied by the current topology without applying them.
+func (a *API) validateShardAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var affinities []scheduler.ShardAffinity
+	if err := json.NewDecoder(req.Body).Decode(&affinities); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	affinityRules, err := c.GetSchedulerManager().ListShardAffinityRules(ctx)
+	if err != nil {
+		return errResult(ErrListAffinityRules, fmt.Sprintf("err: %v", err))
+	}
+	var existing []scheduler.ShardAffinity
+	for _, rule := range affinityRules {
+		existing = append(existing, rule.Affinities...)
+	}
+
+	problems := scheduler.ValidateShardAffinities(c.GetMetadata().GetClusterSnapshot(), existing, affinities, time.Now())
+	return okResult(ValidateShardAffinitiesResult{
+		Valid:    len(problems) == 0,
+		Problems: problems,
+	})
+}
+
+func (a *API) removeShardAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq RemoveShardAffinitiesRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.ShardIDs) == 0 {
+		return validationErrResult(ErrInvalidShardAffinities, []string{"shardIDs could not be empty"})
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	for _, shardID := range decodedReq.ShardIDs {
+		log.Info("try to remove shard affinity rule", zap.String("cluster", clusterName), zap.Int("shardID", int(shardID)))
+		err := c.GetSchedulerManager().RemoveShardAffinityRule(ctx, shardID)
+		if err != nil {
+			log.Error("failed to remove shard affinity rule", zap.String("cluster", clusterName), zap.Int("shardID", int(shardID)), zap.Error(err))
+			return errResult(ErrRemoveAffinityRule, fmt.Sprintf("err: %s", err))
+		}
+	}
+
+	return okResult(nil)
+}
+
+// removeShardAffinitiesByFilter removes the shard affinity rules selected by the filter, which helps to clean up the stale
+// rules after the topology changes.
+func (a *API) removeShardAffinitiesByFilter(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var filter manager.ShardAffinityFilter
+	err := json.NewDecoder(req.Body).Decode(&filter)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if err := filter.Validate(); err != nil {
+		return validationErrResult(ErrInvalidShardAffinities, []string{err.Error()})
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to remove shard affinity rules by filter", zap.String("cluster", clusterName), zap.Any("filter", filter))
+	result, err := c.GetSchedulerManager().RemoveShardAffinityRulesByFilter(ctx, filter)
+	if err != nil {
+		log.Error("failed to remove shard affinity rules by filter", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrRemoveAffinityRule, fmt.Sprintf("err: %s", err))
+	}
+
+	return okResult(result)
+}
+
+func (a *API) listMaintenanceShards(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetMetadata().GetMaintenanceShards())
+}
+
+func (a *API) setShardsMaintenance(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq SetShardsMaintenanceRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to set shards maintenance", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().SetShardsMaintenance(ctx, decodedReq.ShardIDs, decodedReq.Reason); err != nil {
+		log.Error("failed to set shards maintenance", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrShardMaintenance, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+// createSchema provisions the schema explicitly, which is required before using it if the schema auto creation is disabled.
+func (a *API) createSchema(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq CreateSchemaRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.Name) == 0 {
+		return errResult(ErrParseRequest, "name could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to create schema", zap.String("cluster", clusterName), zap.String("schema", decodedReq.Name))
+	schema, exists, err := c.GetMetadata().GetOrCreateSchema(ctx, decodedReq.Name)
+	if err != nil {
+		log.Error("failed to create schema", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrCreateSchema, err.Error())
+	}
+
+	return okResult(CreateSchemaResult{
+		SchemaID: schema.ID,
+		Created:  !exists,
+	})
+}
+
+// dropSchemaTables drops all the tables of the schema, and the confirm query must be the name of the schema.
+func (a *API) dropSchemaTables(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	schemaName := Param(ctx, schemaNameParam)
+	if len(schemaName) == 0 {
+		return errResult(ErrParseRequest, "schemaName could not be empty")
+	}
+	if req.URL.Query().Get(confirmQuery) != schemaName {
+		return errResult(ErrParseRequest, fmt.Sprintf("the %s query must be the schema name to drop all its tables", confirmQuery))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("drop all tables of schema", zap.String("cluster", clusterName), zap.String("schema", schemaName))
+	result, err := c.GetProcedureFactory().DropSchemaTables(ctx, c.GetMetadata(), c.GetProcedureManager(), schemaName)
+	if err != nil {
+		log.Error("drop all tables of schema failed", zap.String("cluster", clusterName), zap.String("schema", schemaName), zap.Error(err))
+		if coderr.Is(err, metadata.ErrSchemaNotFound.Code()) {
+			return errResult(metadata.ErrSchemaNotFound, err.Error())
+		}
+		return errResult(ErrDropSchemaTables, err.Error())
+	}
+	log.Info("drop all tables of schema finish", zap.String("cluster", clusterName), zap.String("schema", schemaName), zap.Int("dropped", result.NumDropped), zap.Int("failed", result.NumFailed))
+
+	return okResult(result)
+}
+
+// getSchemaTableCount returns the number of tables of the schema without listing them.
+func (a *API) getSchemaTableCount(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	schemaName := Param(ctx, schemaNameParam)
+	if len(schemaName) == 0 {
+		return errResult(ErrParseRequest, "schemaName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	tableCount, err := c.GetMetadata().GetTableCountOfSchema(schemaName)
+	if err != nil {
+		return errResult(metadata.ErrSchemaNotFound, err.Error())
+	}
+
+	return okResult(SchemaTableCount{
+		SchemaName: schemaName,
+		TableCount: tableCount,
+	})
+}
+
+// getSchemaShardSpread returns the shards hosting the tables of the schema and the number of the tables on every shard, which
+// helps to find the schemas concentrated on few shards.
+func (a *API) getSchemaShardSpread(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	schemaName := Param(ctx, schemaNameParam)
+	if len(schemaName) == 0 {
+		return errResult(ErrParseRequest, "schemaName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	spread, err := c.GetMetadata().GetShardSpreadOfSchema(ctx, schemaName)
+	if err != nil {
+		return errResult(metadata.ErrSchemaNotFound, err.Error())
+	}
+
+	shards := make([]SchemaShardTableCount, 0, len(spread.ShardTableCounts))
+	for shardID, tableCount := range spread.ShardTableCounts {
+		shards = append(shards, SchemaShardTableCount{ShardID: shardID, TableCount: tableCount})
+	}
+	// The most loaded shards come first.
+	sort.Slice(shards, func(i, j int) bool {
+		if shards[i].TableCount != shards[j].TableCount {
+			return shards[i].TableCount > shards[j].TableCount
+		}
+		return shards[i].ShardID < shards[j].ShardID
+	})
+
+	return okResult(SchemaShardSpreadResult{
+		SchemaName:           schemaName,
+		TableCount:           spread.TableCount,
+		ShardCount:           len(shards),
+		Shards:               shards,
+		PartitionTableCount:  spread.PartitionTableCount,
+		UnassignedTableCount: spread.UnassignedTableCount,
+	})
+}
+
+// listOrphanTables returns the tables whose metadata exists but which are on no shard, e.g. the ones left by the failed creations
+// or drops.
+func (a *API) listOrphanTables(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(OrphanTablesResult{Tables: convertOrphanTables(c.GetMetadata().FindOrphanTables())})
+}
+
+// dropOrphanTables drops the metadata of the orphan tables older than the min age.
+func (a *API) dropOrphanTables(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var dropReq DropOrphanTablesRequest
+	if err := json.NewDecoder(req.Body).Decode(&dropReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("drop orphan tables", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", dropReq)))
+	dropped, err := c.GetMetadata().DropOrphanTables(ctx, time.Duration(dropReq.MinAgeSec)*time.Second, time.Now(), dropReq.DryRun)
+	if errors.Is(err, metadata.ErrInvalidOrphanTableAge) {
+		return errResult(ErrParseRequest, fmt.Sprintf("minAgeSec must be at least %d, minAgeSec:%d", int64(metadata.MinOrphanTableAge/time.Second), dropReq.MinAgeSec))
+	}
+	if err != nil {
+		log.Error("drop orphan tables failed", zap.String("cluster", clusterName), zap.Int("dropped", len(dropped)), zap.Error(err))
+		return errResult(ErrDropOrphanTables, fmt.Sprintf("clusterName: %s, dropped: %d, err: %s", clusterName, len(dropped), err.Error()))
+	}
+
+	return okResult(DropOrphanTablesResult{
+		DryRun:  dropReq.DryRun,
+		Dropped: convertOrphanTables(dropped),
+	})
+}
+
+func convertOrphanTables(orphans []metadata.OrphanTable) []OrphanTable {
+	ret := make([]OrphanTable, 0, len(orphans))
+	for _, orphan := range orphans {
+		ret = append(ret, OrphanTable{
+			SchemaName: orphan.SchemaName,
+			TableName:  orphan.Table.Name,
+			TableID:    orphan.Table.ID,
+			CreatedAt:  orphan.Table.CreatedAt,
+		})
+	}
+	return ret
+}
+
+// expireNode makes the node expired immediately, and its shards will be reassigned by the next scheduling.
+func (a *API) expireNode(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	nodeName := Param(ctx, nodeNameParam)
+	if len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to expire node", zap.String("cluster", clusterName), zap.String("node", nodeName))
+	if err := c.GetMetadata().ExpireNode(ctx, nodeName); err != nil {
+		log.Error("failed to expire node", zap.String("cluster", clusterName), zap.String("node", nodeName), zap.Error(err))
+		return errResult(ErrExpireNode, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+// simulateNodeLoss previews where the shards go if the node is lost, and nothing is applied.
+func (a *API) simulateNodeLoss(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq SimulateNodeLossRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.NodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	reassignments, err := c.GetSchedulerManager().SimulateNodeLoss(ctx, decodedReq.NodeName)
+	if err != nil {
+		log.Error("failed to simulate node loss", zap.String("cluster", clusterName), zap.String("node", decodedReq.NodeName), zap.Error(err))
+		return errResult(ErrSimulateNodeLoss, err.Error())
+	}
+
+	return okResult(SimulateNodeLossResult{
+		NodeName:      decodedReq.NodeName,
+		Reassignments: reassignments,
+	})
+}
+
+// rebalanceShards places only the given shards like the rebalanced scheduler does and transfers their leaders, and the planned moves are returned.
+func (a *API) rebalanceShards(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq RebalanceShardsRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.ShardIDs) == 0 {
+		return errResult(ErrParseRequest, "shardIDs could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("rebalance shards request", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	moves, err := c.GetSchedulerManager().RebalanceShards(ctx, decodedReq.ShardIDs, decodedReq.DryRun)
+	if err != nil {
+		log.Error("rebalance shards failed", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrRebalanceShards, err.Error())
+	}
+
+	return okResult(RebalanceShardsResult{
+		DryRun: decodedReq.DryRun,
+		Moves:  moves,
+	})
+}
+
+// resyncShardWatch forces the shard watch to resync the shard locks from etcd, and the corrections applied are returned.
+// It is used to recover the stale cluster view when the watch misses some events.
+func (a *API) resyncShardWatch(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("resync shard watch request", zap.String("cluster", clusterName))
+	result, err := c.GetSchedulerManager().ResyncShardWatch(ctx)
+	if err != nil {
+		log.Error("resync shard watch failed", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrResyncShardWatch, err.Error())
+	}
+
+	return okResult(result)
+}
+
+func (a *API) closeTableOnShard(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq CloseTableOnShardRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.SchemaName) == 0 || len(decodedReq.Table) == 0 {
+		return errResult(ErrParseRequest, "schemaName and table could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to close table on shard", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.CloseTableOnShard(ctx, decodedReq.SchemaName, decodedReq.Table, decodedReq.ShardID); err != nil {
+		log.Error("failed to close table on shard", zap.String("cluster", clusterName), zap.Error(err))
+		for _, expectedErr := range []coderr.CodeError{metadata.ErrTableNotFound, metadata.ErrTableNotOnShard, procedure.ErrShardLocked} {
+			if errors.Is(err, expectedErr) {
+				return errResult(expectedErr, err.Error())
+			}
+		}
+		return errResult(ErrCloseTableOnShard, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+// closeGhostShard instructs the node to close the shard which is reported by it but not assigned to it in the cluster view.
+func (a *API) closeGhostShard(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq CloseGhostShardRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.NodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to close ghost shard", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.CloseGhostShard(ctx, decodedReq.NodeName, decodedReq.ShardID); err != nil {
+		log.Error("failed to close ghost shard", zap.String("cluster", clusterName), zap.Error(err))
+		if coderr.Is(err, metadata.ErrShardNotGhost.Code()) {
+			return errResult(metadata.ErrShardNotGhost, err.Error())
+		}
+		return errResult(ErrCloseGhostShard, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) advanceShardVersion(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq AdvanceShardVersionRequest
+	if err := json.NewDecoder(req.Body).Decode(&decodedReq); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Warn("try to advance shard version manually", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	prevVersion, err := c.GetMetadata().AdvanceShardVersion(ctx, decodedReq.ShardID, decodedReq.Version)
+	if err != nil {
+		log.Error("failed to advance shard version", zap.String("cluster", clusterName), zap.Error(err))
+		if coderr.Is(err, metadata.ErrShardNotFound.Code()) {
+			return errResult(metadata.ErrShardNotFound, err.Error())
+		}
+		if coderr.Is(err, metadata.ErrShardVersionDecrease.Code()) {
+			return errResult(metadata.ErrShardVersionDecrease, err.Error())
+		}
+		return errResult(ErrAdvanceShardVersion, err.Error())
+	}
+
+	return okResult(AdvanceShardVersionResult{
+		ShardID:     decodedReq.ShardID,
+		PrevVersion: prevVersion,
+		Version:     decodedReq.Version,
+	})
+}
+
+func (a *API) clearShardsMaintenance(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq ClearShardsMaintenanceRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to clear shards maintenance", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().ClearShardsMaintenance(ctx, decodedReq.ShardIDs); err != nil {
+		log.Error("failed to clear shards maintenance", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrShardMaintenance, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) listShardMinNodeVersions(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetMetadata().GetShardMinNodeVersions())
+}
+
+// setShardsMinNodeVersion requires the shards to be placed on the nodes not older than the given version, which is
+// useful to migrate the shards to the upgraded nodes during the rolling upgrade.
+func (a *API) setShardsMinNodeVersion(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq SetShardsMinNodeVersionRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to set shards min node version", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().SetShardsMinNodeVersion(ctx, decodedReq.ShardIDs, decodedReq.MinNodeVersion); err != nil {
+		log.Error("failed to set shards min node version", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrShardMinNodeVersion, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) clearShardsMinNodeVersion(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq ClearShardsMinNodeVersionRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to clear shards min node version", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().ClearShardsMinNodeVersion(ctx, decodedReq.ShardIDs); err != nil {
+		log.Error("failed to clear shards min node version", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrShardMinNodeVersion, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) listPreferredLeaders(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetMetadata().GetPreferredLeaders())
+}
+
+// setPreferredLeader makes the leader of the shards prefer the node, and the leadership falls back to other nodes when
+// it is offline and moves back after it has been online for a while.
+func (a *API) setPreferredLeader(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq SetPreferredLeaderRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.NodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to set preferred leader", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().SetPreferredLeader(ctx, decodedReq.ShardIDs, decodedReq.NodeName); err != nil {
+		log.Error("failed to set preferred leader", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrPreferredLeader, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) clearPreferredLeader(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq ClearPreferredLeaderRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to clear preferred leader", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", decodedReq)))
+	if err := c.GetMetadata().ClearPreferredLeader(ctx, decodedReq.ShardIDs); err != nil {
+		log.Error("failed to clear preferred leader", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrPreferredLeader, err.Error())
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) queryTable(r *http.Request) apiFuncResult {
+	var req QueryTableRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	if len(req.Names) != 0 {
+		tables, err := a.clusterManager.GetTables(req.ClusterName, req.SchemaName, req.Names)
+		if err != nil {
+			return errResult(ErrTable, err.Error())
+		}
+		return okResult(tables)
+	}
+
+	ids := make([]storage.TableID, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		ids = append(ids, storage.TableID(id))
 	}
-	shards := c.GetShards()
 
-	registeredShards := make(map[storage.ShardID]struct{}, len(shards))
-	// Check if there are unready shards.
-	for _, node := range registeredNodes {
-		for _, shardInfo := range node.ShardInfos {
-			if shardInfo.Status != storage.ShardStatusReady {
-				ret.UnreadyShards[shardInfo.ID] = DiagnoseShardStatus{
-					NodeName: node.Node.Name,
-					Status:   storage.ConvertShardStatusToString(shardInfo.Status),
-				}
-			}
-			registeredShards[shardInfo.ID] = struct{}{}
+	tables, err := a.clusterManager.GetTablesByIDs(req.ClusterName, ids)
+	if err != nil {
+		return errResult(ErrTable, err.Error())
+	}
+	return okResult(tables)
+}
+
+// tableExists checks the existence of a batch of tables, and the tables in an unknown schema are considered as not existing.
+func (a *API) tableExists(r *http.Request) apiFuncResult {
+	var req TableExistsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(req.Tables) > maxTableExistsBatchSize {
+		return errResult(ErrTableExistsBatchTooLarge, fmt.Sprintf("tables:%d, max:%d", len(req.Tables), maxTableExistsBatchSize))
+	}
+
+	c, err := a.clusterManager.GetCluster(r.Context(), req.ClusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", req.ClusterName, err.Error()))
+	}
+
+	result := make(TableExistsResult, len(req.Tables))
+	for _, table := range req.Tables {
+		_, exists, err := c.GetMetadata().GetTable(table.SchemaName, table.TableName)
+		if err != nil && !coderr.Is(err, metadata.ErrSchemaNotFound.Code()) {
+			return errResult(ErrTable, err.Error())
+		}
+
+		if _, ok := result[table.SchemaName]; !ok {
+			result[table.SchemaName] = map[string]bool{}
+		}
+		result[table.SchemaName][table.TableName] = exists
+	}
+
+	return okResult(result)
+}
+
+func (a *API) getPartitionTableLayout(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	tableName := Param(ctx, tableNameParam)
+	if len(tableName) == 0 {
+		return errResult(ErrParseRequest, "tableName could not be empty")
+	}
+	schemaName := req.URL.Query().Get(schemaNameQuery)
+	if len(schemaName) == 0 {
+		return errResult(ErrParseRequest, "schema could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	layout, err := c.GetMetadata().GetPartitionTableLayout(ctx, schemaName, tableName)
+	if err != nil {
+		log.Error("get partition table layout failed", zap.Error(err))
+		switch {
+		case coderr.Is(err, metadata.ErrTableNotFound.Code()):
+			return errResult(metadata.ErrTableNotFound, err.Error())
+		case coderr.Is(err, metadata.ErrTableNotPartitioned.Code()):
+			return errResult(metadata.ErrTableNotPartitioned, err.Error())
+		default:
+			return errResult(ErrTable, err.Error())
+		}
+	}
+
+	return okResult(layout)
+}
+
+func (a *API) listFaults(_ *http.Request) apiFuncResult {
+	if !procedure.IsFaultInjectionEnabled() {
+		return errResult(procedure.ErrFaultInjectionDisabled, "set enable-fault-injection in config to enable it")
+	}
+
+	return okResult(procedure.ListFaults())
+}
+
+func (a *API) updateFaults(req *http.Request) apiFuncResult {
+	var updateFaultsRequest UpdateFaultsRequest
+	if err := json.NewDecoder(req.Body).Decode(&updateFaultsRequest); err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Warn("update injected faults", zap.String("request", fmt.Sprintf("%+v", updateFaultsRequest)))
+
+	if err := procedure.SetFaults(updateFaultsRequest.Faults); err != nil {
+		return errResult(procedure.ErrFaultInjectionDisabled, err.Error())
+	}
+
+	return okResult(procedure.ListFaults())
+}
+
+func (a *API) listSchedulers(r *http.Request) apiFuncResult {
+	ctx := r.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(c.GetSchedulerManager().DescribeSchedulers())
+}
+
+func (a *API) getEnableSchedule(r *http.Request) apiFuncResult {
+	ctx := r.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	enableSchedule, err := c.GetSchedulerManager().GetEnableSchedule(r.Context())
+	if err != nil {
+		return errResult(ErrGetEnableSchedule, err.Error())
+	}
+
+	return okResult(enableSchedule)
+}
+
+func (a *API) updateEnableSchedule(r *http.Request) apiFuncResult {
+	ctx := r.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	var req UpdateEnableScheduleRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	if req.ExpectedEnable != nil {
+		err = c.GetSchedulerManager().CompareAndSwapEnableSchedule(r.Context(), *req.ExpectedEnable, req.Enable)
+	} else {
+		err = c.GetSchedulerManager().UpdateEnableSchedule(r.Context(), req.Enable)
+	}
+	if err != nil {
+		if coderr.Is(err, manager.ErrEnableScheduleConflict.Code()) {
+			return errResult(manager.ErrEnableScheduleConflict, err.Error())
 		}
+		return errResult(ErrUpdateEnableSchedule, err.Error())
+	}
+
+	return okResult(req.Enable)
+}
+
+func (a *API) diagnoseShards(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
 	}
 
-	// Check if there are unregistered shards.
-	for _, shard := range shards {
-		if _, ok := registeredShards[shard]; !ok {
-			ret.UnregisteredShards = append(ret.UnregisteredShards, shard)
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	now := time.Now()
+	ret := DiagnoseShardResult{
+		UnregisteredShards:   snapshot.FindUnregisteredShards(),
+		UnreadyShards:        make(map[storage.ShardID]DiagnoseShardStatus),
+		MaintenanceShards:    c.GetMetadata().GetMaintenanceShards(),
+		GhostShards:          []DiagnoseGhostShard{},
+		OscillatingShards:    c.GetSchedulerManager().ListOscillatingShards(),
+		NoEligibleNodeShards: snapshot.FindShardsWithoutEligibleNode(now),
+	}
+
+	for _, ghostShard := range snapshot.FindGhostShards(now) {
+		ret.GhostShards = append(ret.GhostShards, DiagnoseGhostShard{
+			ShardID:  ghostShard.ShardID,
+			NodeName: ghostShard.NodeName,
+			Unknown:  ghostShard.Unknown,
+		})
+	}
+
+	for _, unreadyShard := range snapshot.FindUnreadyShards() {
+		ret.UnreadyShards[unreadyShard.ShardID] = DiagnoseShardStatus{
+			NodeName: unreadyShard.NodeName,
+			Status:   storage.ConvertShardStatusToString(unreadyShard.Status),
 		}
 	}
 
 	return okResult(ret)
 }
 
+// diagnoseReplicas reports the shards with fewer healthy replicas than the expected replication factor in the cluster view.
+func (a *API) diagnoseReplicas(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
+	}
+
+	replicationFactor, err := strconv.Atoi(req.URL.Query().Get(replicationFactorQuery))
+	if err != nil || replicationFactor < 1 {
+		return errResult(ErrParseRequest, "replicationFactor must be a positive integer")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	underReplicatedShards := c.GetMetadata().GetClusterSnapshot().FindUnderReplicatedShards(replicationFactor, time.Now())
+	ret := DiagnoseReplicaResult{
+		ReplicationFactor:     replicationFactor,
+		UnderReplicatedShards: make([]DiagnoseReplicaShard, 0, len(underReplicatedShards)),
+	}
+	for _, shard := range underReplicatedShards {
+		ret.UnderReplicatedShards = append(ret.UnderReplicatedShards, DiagnoseReplicaShard{
+			ShardID:         shard.ShardID,
+			HealthyReplicas: shard.HealthyReplicas,
+			MissingReplicas: shard.MissingReplicas,
+		})
+	}
+
+	return okResult(ret)
+}
+
+// diagnoseHeartbeats reports the heartbeat lag of the registered nodes in descending order, which helps to catch the
+// degrading nodes before they are expired.
+func (a *API) diagnoseHeartbeats(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		clusterName = config.DefaultClusterName
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	now := time.Now()
+	registeredNodes := c.GetMetadata().GetRegisteredNodes()
+	ret := make([]DiagnoseHeartbeatNode, 0, len(registeredNodes))
+	for _, node := range registeredNodes {
+		ret = append(ret, DiagnoseHeartbeatNode{
+			NodeName:      node.Node.Name,
+			LastTouchTime: node.Node.LastTouchTime,
+			LagMs:         node.HeartbeatLag(now).Milliseconds(),
+			Expired:       node.IsExpired(now),
+			ShuttingDown:  node.IsShuttingDown(),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].LagMs != ret[j].LagMs {
+			return ret[i].LagMs > ret[j].LagMs
+		}
+		return ret[i].NodeName < ret[j].NodeName
+	})
+
+	return okResult(ret)
+}
+
+// getEffectiveConfig returns the config items of this server with their effective values and sources.
+func (a *API) getEffectiveConfig(_ *http.Request) apiFuncResult {
+	return okResult(a.effectiveConfig)
+}
+
+// listRecentErrors lists the recent errors recorded by this server, and the newest one comes first.
+func (a *API) listRecentErrors(_ *http.Request) apiFuncResult {
+	return okResult(a.recentErrors.List())
+}
+
 func (a *API) health(_ *http.Request) apiFuncResult {
 	isServerHealthy := a.serverStatus.IsHealthy()
 	if isServerHealthy {
@@ -653,6 +2698,49 @@ func (a *API) health(_ *http.Request) apiFuncResult {
 	return errResult(ErrHealthCheck, fmt.Sprintf("server heath check failed, status is %v", a.serverStatus.Get()))
 }
 
+// getServerStatus returns the raw status of the requested server, which drives the health check.
+func (a *API) getServerStatus(_ *http.Request) apiFuncResult {
+	current := a.serverStatus.Get()
+	return okResult(ServerStatusResult{
+		Status:  current.String(),
+		Code:    int32(current),
+		Healthy: current == status.StatusRunning,
+	})
+}
+
+// resetServerStatus resets the status of the requested server to running if it is degraded after a transient issue, so that
+// the process needn't be restarted. The reset is rejected unless etcd is reachable and the leader is known. The waiting and terminated
+// servers are never reset, because their startup is not finished or has failed.
+func (a *API) resetServerStatus(req *http.Request) apiFuncResult {
+	previous := a.serverStatus.Get()
+	if previous == status.StatusRunning {
+		return okResult(ResetServerStatusResult{Reset: false, PreviousStatus: previous.String(), Status: previous.String()})
+	}
+	if previous != status.StatusDegraded {
+		return errResult(ErrResetServerStatus, fmt.Sprintf("only the degraded server can be reset, status:%s", previous))
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), statusResetCheckTimeout)
+	defer cancel()
+	// Any linearizable read proves that etcd is reachable and has a quorum.
+	if _, err := a.etcdAPI.etcdClient.Get(ctx, "health", clientv3.WithCountOnly()); err != nil {
+		log.Error("reject resetting server status, etcd is unreachable", zap.Error(err))
+		return errResult(ErrResetServerStatus, fmt.Sprintf("etcd is unreachable, err: %s", err.Error()))
+	}
+	leaderAddr, err := a.forwardClient.GetLeaderAddr(ctx)
+	if err != nil {
+		log.Error("reject resetting server status, leader is unknown", zap.Error(err))
+		return errResult(ErrResetServerStatus, fmt.Sprintf("leader is unknown, err: %s", err.Error()))
+	}
+
+	if !a.serverStatus.CompareAndSet(previous, status.StatusRunning) {
+		return errResult(ErrResetServerStatus, fmt.Sprintf("server status is changed concurrently, previous:%s, current:%s", previous, a.serverStatus.Get()))
+	}
+	log.Warn("reset server status", zap.String("previousStatus", previous.String()), zap.String("leader", leaderAddr))
+
+	return okResult(ResetServerStatusResult{Reset: true, PreviousStatus: previous.String(), Status: status.StatusRunning.String()})
+}
+
 func (a *API) pprofHeap(writer http.ResponseWriter, req *http.Request) {
 	pprof.Handler("heap").ServeHTTP(writer, req)
 }
@@ -690,53 +2778,110 @@ func printRequestInfo(handlerName string, handler http.HandlerFunc) http.Handler
 	}
 }
 
-func respondForward(w http.ResponseWriter, response *http.Response) {
-	b, err := io.ReadAll(response.Body)
-	if err != nil {
-		log.Error("read response failed", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// logSlowRequest logs a warning for the request whose latency exceeds the threshold.
+func (a *API) logSlowRequest(handlerName string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		handler.ServeHTTP(writer, request)
+		if cost := time.Since(start); a.slowRequestThreshold > 0 && cost > a.slowRequestThreshold {
+			log.Warn("slow http request", zap.String("handlerName", handlerName), zap.String("method", request.Method), zap.String("url", request.URL.String()), zap.Duration("cost", cost), zap.Duration("threshold", a.slowRequestThreshold))
+		}
 	}
+}
 
+// respondForward copies the response of the leader, and the body is streamed so that the large responses are not buffered.
+func respondForward(w http.ResponseWriter, response *http.Response) {
 	for key, valArr := range response.Header {
 		for _, val := range valArr {
 			w.Header().Add(key, val)
 		}
 	}
 	w.WriteHeader(response.StatusCode)
-	if n, err := w.Write(b); err != nil {
-		log.Error("write response failed", zap.Int("msg", n), zap.Error(err))
+	if _, err := io.Copy(w, response.Body); err != nil {
+		log.Error("copy forwarded response failed", zap.Error(err))
 	}
 }
 
+// forwardIfNotLeader forwards the request to the leader if this server is not the leader, and returns whether the request
+// has been responded.
+func forwardIfNotLeader(w http.ResponseWriter, r *http.Request, forwardClient *ForwardClient) bool {
+	resp, isLeader, err := forwardClient.forwardToLeader(r)
+	if err != nil {
+		log.Error("forward to leader failed", zap.Error(err))
+		respondErrorsOf(r)(w, ErrForwardToLeader, err.Error(), nil)
+		return true
+	}
+	if isLeader {
+		return false
+	}
+	// nolint:staticcheck
+	defer resp.Body.Close()
+	respondForward(w, resp)
+	return true
+}
+
+// isV2Request returns whether the request is served by the v2 apis, whose responses are wrapped in the v2 envelope.
+func isV2Request(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, apiV2Prefix+"/")
+}
+
 func respond(w http.ResponseWriter, data interface{}) {
 	statusMessage := statusSuccess
-	b, err := json.Marshal(&response{
+	writeResponse(w, http.StatusOK, &response{
 		Status: statusMessage,
 		Data:   data,
 		Error:  "",
 		Msg:    "",
+		Errors: nil,
 	})
-	if err != nil {
-		log.Error("marshal json response failed", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if n, err := w.Write(b); err != nil {
-		log.Error("write response failed", zap.Int("msg", n), zap.Error(err))
-	}
+func respondV2(w http.ResponseWriter, data interface{}) {
+	writeResponse(w, http.StatusOK, &responseV2{
+		Status: statusSuccess,
+		Data:   data,
+		Code:   coderr.Ok,
+		Error:  "",
+		Errors: nil,
+	})
 }
 
-func respondError(w http.ResponseWriter, apiErr coderr.CodeError, msg string) {
-	b, err := json.Marshal(&response{
+// respondErrors responds the error, and lists all the validation failures in the response as well.
+func respondErrors(w http.ResponseWriter, apiErr coderr.CodeError, msg string, errs []string) {
+	writeResponse(w, apiErr.Code().ToHTTPCode(), &response{
 		Status: statusError,
 		Data:   nil,
 		Error:  apiErr.Error(),
 		Msg:    msg,
+		Errors: errs,
+	})
+}
+
+// respondErrorsV2 is like respondErrors, but the response is wrapped in the v2 envelope.
+func respondErrorsV2(w http.ResponseWriter, apiErr coderr.CodeError, msg string, errs []string) {
+	errMsg := apiErr.Desc()
+	if len(msg) > 0 {
+		errMsg = fmt.Sprintf("%s: %s", errMsg, msg)
+	}
+	writeResponse(w, apiErr.Code().ToHTTPCode(), &responseV2{
+		Status: statusError,
+		Data:   nil,
+		Code:   apiErr.Code(),
+		Error:  errMsg,
+		Errors: errs,
 	})
+}
+
+// respondErrorsOf returns the function responding the errors in the envelope of the api version of the request.
+func respondErrorsOf(req *http.Request) func(w http.ResponseWriter, apiErr coderr.CodeError, msg string, errs []string) {
+	if isV2Request(req) {
+		return respondErrorsV2
+	}
+	return respondErrors
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, resp interface{}) {
+	b, err := json.Marshal(resp)
 	if err != nil {
 		log.Error("marshal json response failed", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -744,31 +2889,70 @@ func respondError(w http.ResponseWriter, apiErr coderr.CodeError, msg string) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(apiErr.Code().ToHTTPCode())
+	w.WriteHeader(statusCode)
 	if n, err := w.Write(b); err != nil {
 		log.Error("write response failed", zap.Int("msg", n), zap.Error(err))
 	}
 }
 
-func wrap(f apiFunc, needForward bool, forwardClient *ForwardClient) http.HandlerFunc {
+// wrapStream is like wrap, but the handler writes the response by itself, which is used to stream the large responses.
+func (a *API) wrapStream(f http.HandlerFunc, needForward bool) http.HandlerFunc {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if needForward {
-			resp, isLeader, err := forwardClient.forwardToLeader(r)
-			if err != nil {
-				log.Error("forward to leader failed", zap.Error(err))
-				respondError(w, ErrForwardToLeader, err.Error())
-				return
-			}
-			if !isLeader {
-				// nolint:staticcheck
-				defer resp.Body.Close()
-				respondForward(w, resp)
-				return
-			}
+		if needForward && forwardIfNotLeader(w, r, a.forwardClient) {
+			return
+		}
+		f(w, r)
+	})
+	return hf
+}
+
+// withInitiator attaches the initiator in the query of the mutating request to its context, so the procedures submitted by
+// the request are tagged with it.
+func withInitiator(req *http.Request) (*http.Request, error) {
+	if req.Method == http.MethodGet {
+		return req, nil
+	}
+	initiator := strings.TrimSpace(req.URL.Query().Get(initiatorQuery))
+	if len(initiator) == 0 {
+		return req, nil
+	}
+	if len(initiator) > maxInitiatorLen {
+		return nil, errors.Errorf("initiator is too long, len:%d, max:%d", len(initiator), maxInitiatorLen)
+	}
+	return req.WithContext(procedure.WithInitiator(req.Context(), initiator)), nil
+}
+
+// destructive marks the api as destructive, which is rejected if the safe mode is on. The request forwarded to the leader is
+// checked against the safe mode of the leader.
+func (a *API) destructive(f apiFunc) apiFunc {
+	return func(r *http.Request) apiFuncResult {
+		if a.safeMode {
+			log.Warn("reject destructive request in safe mode", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+			return errResult(ErrDisabledInSafeMode, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		}
+		return f(r)
+	}
+}
+
+func (a *API) wrap(f apiFunc, needForward bool) http.HandlerFunc {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if needForward && forwardIfNotLeader(w, r, a.forwardClient) {
+			return
+		}
+		respondErrs := respondErrorsOf(r)
+		r, err := withInitiator(r)
+		if err != nil {
+			respondErrs(w, ErrParseRequest, err.Error(), nil)
+			return
 		}
 		result := f(r)
 		if result.err != nil {
-			respondError(w, result.err, result.errMsg)
+			a.recentErrors.Record(fmt.Sprintf("http %s %s", r.Method, r.URL.Path), result.err.WithCausef("%s", result.errMsg))
+			respondErrs(w, result.err, result.errMsg, result.errs)
+			return
+		}
+		if isV2Request(r) {
+			respondV2(w, result.data)
 			return
 		}
 		respond(w, result.data)
@@ -0,0 +1,500 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/apache/incubator-horaedb-meta/server/limiter"
+	"github.com/apache/incubator-horaedb-meta/server/member"
+	"github.com/apache/incubator-horaedb-meta/server/status"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metastoragepb"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRootPath    = "/horaemeta"
+	testClusterName = "cluster0"
+	testSchemaName  = "public"
+)
+
+// leaderChecker keeps the local member as the leader.
+type leaderChecker struct{}
+
+func (leaderChecker) ShouldCampaign(_ *member.Member) bool {
+	return true
+}
+
+func (leaderChecker) IsValidLeader(_ *metastoragepb.Member) bool {
+	return true
+}
+
+// testResponse is the response of the v1 apis whose data is left undecoded.
+type testResponse struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+	Error  string          `json:"error"`
+	Msg    string          `json:"msg"`
+	Errors []string        `json:"errors"`
+}
+
+// testResponseV2 is the response of the v2 apis whose data is left undecoded.
+type testResponseV2 struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+	Code   coderr.Code     `json:"code"`
+	Error  string          `json:"error"`
+	Errors []string        `json:"errors"`
+}
+
+// newTestServer serves the apis of a cluster manager backed by the embedded etcd, and the local member is the leader so that
+// the requests are not forwarded.
+func newTestServer(t *testing.T) (*httptest.Server, cluster.Manager) {
+	api, manager := newTestAPI(t, false)
+	srv := httptest.NewServer(api.NewAPIRouter())
+	t.Cleanup(srv.Close)
+	return srv, manager
+}
+
+func newTestAPI(t *testing.T, safeMode bool) (*API, cluster.Manager) {
+	re := require.New(t)
+	_, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	t.Cleanup(closeSrv)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mem := member.NewMember(testRootPath, 0, "mem0", "127.0.0.1:2379", client, nil, time.Second*10)
+	go func() {
+		_ = mem.CampaignAndKeepLeader(ctx, 5, leaderChecker{}, nil)
+	}()
+	re.Eventually(func() bool {
+		resp, err := mem.GetLeaderAddr(ctx)
+		return err == nil && resp.IsLocal
+	}, time.Second*10, time.Millisecond*10)
+
+	clusterStorage := storage.NewStorageWithEtcdBackend(client, testRootPath, storage.Options{
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
+	})
+	manager, err := cluster.NewManagerImpl(clusterStorage, client, client, cluster.ManagerOptions{
+		RootPath:                          testRootPath,
+		IDAllocatorStep:                   20,
+		ClusterKeyPrefixes:                nil,
+		TopologyType:                      storage.TopologyTypeStatic,
+		EnableSchemaAutoCreation:          true,
+		SchedulerConcurrency:              2,
+		MaxShardVersionDelta:              metadata.DefaultMaxShardVersionDelta,
+		ReadyShardStatuses:                []storage.ShardStatus{storage.ShardStatusReady},
+		NodePickerHash:                    metadata.NodePickerHash{Function: "", Seed: 0},
+		EnableProcedureCheckpoint:         false,
+		CreateTableOfflineShardPolicy:     metadata.OfflineShardPolicyFail,
+		ShardPickers:                      nil,
+		ProcedureTimeouts:                 procedure.Timeouts{},
+		ShardOscillationThreshold:         metadata.ShardOscillationThreshold{MaxMoves: 0, Window: 0},
+		PreferredLeaderStabilizationDelay: 0,
+		NodeStatsHistoryOptions:           metadata.NodeStatsHistoryOptions{Capacity: 0, Interval: 0},
+		MaxInflightCreatesPerShard:        0,
+		MaxPartitionSubTables:             0,
+		SubTableDispatchConcurrency:       0,
+		ProcedureStorageOptions:           procedure.StorageOptions{EtcdPrefix: ""},
+		RecentErrors:                      coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity),
+	})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+	t.Cleanup(func() {
+		_ = manager.Stop(context.Background())
+	})
+
+	flowLimiter := limiter.NewFlowLimiter(config.LimiterConfig{
+		Enable:                   false,
+		Limit:                    10,
+		Burst:                    20,
+		CreateTableCost:          1,
+		CreatePartitionTableCost: 4,
+		DropTableCost:            1,
+		RouteTablesCost:          1,
+	})
+	api := NewAPI(manager, status.NewServerStatus(), NewForwardClient(mem, 0), flowLimiter, client, nil, "", 0, nil, safeMode, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+	return api, manager
+}
+
+// createTestCluster creates the cluster with the test schema.
+func createTestCluster(ctx context.Context, t *testing.T, manager cluster.Manager) *cluster.Cluster {
+	re := require.New(t)
+	c, err := manager.CreateCluster(ctx, testClusterName, metadata.CreateClusterOpts{
+		NodeCount:                   1,
+		ShardTotal:                  2,
+		EnableSchedule:              false,
+		TopologyType:                storage.TopologyTypeStatic,
+		ProcedureExecutingBatchSize: 100,
+		ShardIDs:                    nil,
+	})
+	re.NoError(err)
+	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, testSchemaName)
+	re.NoError(err)
+	return c
+}
+
+func doTestRequest(t *testing.T, method, url string) (int, testResponse) {
+	return doTestRequestWithBody(t, method, url, nil)
+}
+
+// doTestRequestWithBody sends the body encoded as json if it is not nil.
+func doTestRequestWithBody(t *testing.T, method, url string, body any) (int, testResponse) {
+	statusCode, respBody := sendTestRequest(t, method, url, body)
+	var decoded testResponse
+	require.NoError(t, json.Unmarshal(respBody, &decoded))
+	return statusCode, decoded
+}
+
+// doTestRequestV2 is like doTestRequestWithBody, but decodes the response in the v2 envelope, and returns the names of the fields
+// present in the response as well.
+func doTestRequestV2(t *testing.T, method, url string, body any) (int, testResponseV2, []string) {
+	re := require.New(t)
+	statusCode, respBody := sendTestRequest(t, method, url, body)
+	var decoded testResponseV2
+	re.NoError(json.Unmarshal(respBody, &decoded))
+	var fields map[string]json.RawMessage
+	re.NoError(json.Unmarshal(respBody, &fields))
+	fieldNames := make([]string, 0, len(fields))
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+	return statusCode, decoded, fieldNames
+}
+
+func sendTestRequest(t *testing.T, method, url string, body any) (int, []byte) {
+	re := require.New(t)
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		re.NoError(err)
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	re.NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	re.NoError(err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	re.NoError(err)
+	return resp.StatusCode, respBody
+}
+
+func TestDropSchemaTables(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	srv, manager := newTestServer(t)
+	createTestCluster(ctx, t, manager)
+
+	url := func(clusterName, schemaName, confirm string) string {
+		return srv.URL + "/api/v1/clusters/" + clusterName + "/schemas/" + schemaName + "/tables?confirm=" + confirm
+	}
+
+	// The schema name must be confirmed.
+	statusCode, resp := doTestRequest(t, http.MethodDelete, url(testClusterName, testSchemaName, ""))
+	re.Equal(http.StatusBadRequest, statusCode)
+	re.Equal(statusError, resp.Status)
+
+	// The unknown schema is not found.
+	statusCode, _ = doTestRequest(t, http.MethodDelete, url(testClusterName, "unknown", "unknown"))
+	re.Equal(http.StatusNotFound, statusCode)
+
+	// The unknown cluster is rejected.
+	statusCode, _ = doTestRequest(t, http.MethodDelete, url("unknown", testSchemaName, testSchemaName))
+	re.NotEqual(http.StatusOK, statusCode)
+
+	// Nothing is dropped from the empty schema.
+	statusCode, resp = doTestRequest(t, http.MethodDelete, url(testClusterName, testSchemaName, testSchemaName))
+	re.Equal(http.StatusOK, statusCode)
+	var result coordinator.DropSchemaTablesResult
+	re.NoError(json.Unmarshal(resp.Data, &result))
+	re.Equal(0, result.NumDropped)
+	re.Equal(0, result.NumFailed)
+	re.Empty(result.Tables)
+}
+
+func TestClearTableAssignmentErrors(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	srv, manager := newTestServer(t)
+	createTestCluster(ctx, t, manager)
+
+	url := srv.URL + "/api/v1/clusters/" + testClusterName + "/tableAssignments"
+	clear := func(schemaName string) (int, testResponse) {
+		return doTestRequestWithBody(t, http.MethodDelete, url, ClearTableAssignmentRequest{
+			SchemaName: schemaName,
+			TableName:  "unassigned",
+			ShardID:    0,
+		})
+	}
+
+	// Both errors are NotFound, but each is reported as itself.
+	statusCode, resp := clear(testSchemaName)
+	re.Equal(http.StatusNotFound, statusCode)
+	re.Equal(metadata.ErrTableAssignmentNotFound.Error(), resp.Error)
+
+	statusCode, resp = clear("unknown")
+	re.Equal(http.StatusNotFound, statusCode)
+	re.Equal(metadata.ErrSchemaNotFound.Error(), resp.Error)
+}
+
+func TestTableIDRangeErrors(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	srv, manager := newTestServer(t)
+	createTestCluster(ctx, t, manager)
+
+	url := srv.URL + "/api/v1/clusters/" + testClusterName + "/tableIDRanges"
+	statusCode, _ := doTestRequestWithBody(t, http.MethodPost, url, CreateTableIDRangeRequest{SchemaName: "schema0", Start: 10000, End: 20000})
+	re.Equal(http.StatusOK, statusCode)
+
+	// The overlapping range is rejected as invalid params.
+	statusCode, resp := doTestRequestWithBody(t, http.MethodPost, url, CreateTableIDRangeRequest{SchemaName: "schema1", Start: 15000, End: 25000})
+	re.Equal(http.StatusBadRequest, statusCode)
+	re.Equal(metadata.ErrTableIDRangeOverlap.Error(), resp.Error)
+
+	// The unused range can be deleted only once.
+	statusCode, _ = doTestRequest(t, http.MethodDelete, url+"/schema0")
+	re.Equal(http.StatusOK, statusCode)
+	statusCode, resp = doTestRequest(t, http.MethodDelete, url+"/schema0")
+	re.Equal(http.StatusNotFound, statusCode)
+	re.Equal(metadata.ErrTableIDRangeNotFound.Error(), resp.Error)
+
+	// The range is free after being deleted.
+	statusCode, _ = doTestRequestWithBody(t, http.MethodPost, url, CreateTableIDRangeRequest{SchemaName: "schema1", Start: 15000, End: 25000})
+	re.Equal(http.StatusOK, statusCode)
+}
+
+func TestV2Envelope(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	srv, manager := newTestServer(t)
+	createTestCluster(ctx, t, manager)
+
+	// The data is the same as the v1 api, and the successful response carries no error fields.
+	statusCode, resp, fields := doTestRequestV2(t, http.MethodGet, srv.URL+"/api/v2/clusters", nil)
+	re.Equal(http.StatusOK, statusCode)
+	re.Equal(statusSuccess, resp.Status)
+	re.Equal([]string{"data", "status"}, fields)
+	_, respV1 := doTestRequest(t, http.MethodGet, srv.URL+"/api/v1/clusters")
+	re.JSONEq(string(respV1.Data), string(resp.Data))
+
+	// The error carries its code, and the message is merged into the error.
+	schemaTablesURL := "/clusters/" + testClusterName + "/schemas/unknown/tables?confirm=unknown"
+	statusCode, resp, fields = doTestRequestV2(t, http.MethodDelete, srv.URL+"/api/v2"+schemaTablesURL, nil)
+	re.Equal(http.StatusNotFound, statusCode)
+	re.Equal(statusError, resp.Status)
+	re.Equal(coderr.Code(coderr.NotFound), resp.Code)
+	re.True(strings.HasPrefix(resp.Error, metadata.ErrSchemaNotFound.Desc()+": "), "error:%s", resp.Error)
+	re.Equal([]string{"code", "error", "status"}, fields)
+
+	// The v1 api keeps its envelope.
+	statusCode, respV1 = doTestRequest(t, http.MethodDelete, srv.URL+"/api/v1"+schemaTablesURL)
+	re.Equal(http.StatusNotFound, statusCode)
+	re.Equal(metadata.ErrSchemaNotFound.Error(), respV1.Error)
+	re.NotEmpty(respV1.Msg)
+
+	// All the validation failures are listed.
+	statusCode, resp, _ = doTestRequestV2(t, http.MethodPost, srv.URL+"/api/v2/clusters", CreateClusterRequest{})
+	re.Equal(http.StatusBadRequest, statusCode)
+	re.Equal(coderr.Code(coderr.BadRequest), resp.Code)
+	re.True(strings.HasPrefix(resp.Error, ErrInvalidParamsForCreateCluster.Desc()+": "), "error:%s", resp.Error)
+	_, respV1 = doTestRequestWithBody(t, http.MethodPost, srv.URL+"/api/v1/clusters", CreateClusterRequest{})
+	re.NotEmpty(resp.Errors)
+	re.Equal(respV1.Errors, resp.Errors)
+
+	// The request rejected before reaching the api is wrapped in the v2 envelope as well.
+	initiator := strings.Repeat("a", maxInitiatorLen+1)
+	statusCode, resp, _ = doTestRequestV2(t, http.MethodDelete, srv.URL+"/api/v2"+schemaTablesURL+"&initiator="+initiator, nil)
+	re.Equal(http.StatusBadRequest, statusCode)
+	re.Equal(coderr.Code(coderr.BadRequest), resp.Code)
+	re.True(strings.HasPrefix(resp.Error, ErrParseRequest.Desc()+": "), "error:%s", resp.Error)
+}
+
+func TestSafeModeRejectsDestructiveRoutes(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	api, manager := newTestAPI(t, true)
+	createTestCluster(ctx, t, manager)
+	router := api.NewAPIRouter()
+
+	// The mutating routes not listed here must be rejected in the safe mode, so that a new one is either marked destructive or
+	// added here on purpose.
+	nonDestructiveRoutes := map[string]struct{}{
+		"POST /getShardTables":                                   {},
+		"POST /transferLeader":                                   {},
+		"POST /split":                                            {},
+		"POST /route":                                            {},
+		"POST /getNodeShards":                                    {},
+		"PUT /flowLimiter":                                       {},
+		"PUT /scanLimit":                                         {},
+		"POST /leader/stepDown":                                  {},
+		"POST /clusters":                                         {},
+		"PUT /clusters/:cluster":                                 {},
+		"POST /clusters/:cluster/clone":                          {},
+		"POST /clusters/:cluster/schemas":                        {},
+		"POST /clusters/:cluster/procedure/replay":               {},
+		"POST /clusters/:cluster/shardAffinities":                {},
+		"DELETE /clusters/:cluster/shardAffinities":              {},
+		"POST /clusters/:cluster/shardAffinities/validate":       {},
+		"POST /clusters/:cluster/shardAffinities/removeByFilter": {},
+		"POST /clusters/:cluster/shardMaintenance":               {},
+		"DELETE /clusters/:cluster/shardMaintenance":             {},
+		"POST /clusters/:cluster/shardMinNodeVersions":           {},
+		"DELETE /clusters/:cluster/shardMinNodeVersions":         {},
+		"POST /clusters/:cluster/preferredLeaders":               {},
+		"DELETE /clusters/:cluster/preferredLeaders":             {},
+		"PUT /clusters/:cluster/nodePickerStrategy":              {},
+		"PUT /clusters/:cluster/shardPermutation":                {},
+		"POST /clusters/:cluster/rebalanceShards":                {},
+		"POST /clusters/:cluster/shardWatch/resync":              {},
+		"PUT /clusters/:cluster/quota":                           {},
+		"PUT /clusters/:cluster/defaultPartitionCount":           {},
+		"POST /clusters/:cluster/tableIDRanges":                  {},
+		"POST /clusters/:cluster/shards/:shardID/preWarm":        {},
+		"POST /table/query":                                      {},
+		"POST /table/exists":                                     {},
+		"POST /debug/leader/lease/renew":                         {},
+		"PUT /debug/clusters/:cluster/enableSchedule":            {},
+		"POST /debug/clusters/:cluster/simulateNodeLoss":         {},
+		"POST /etcd/promoteLearner":                              {},
+		"PUT /etcd/member":                                       {},
+		"POST /etcd/member":                                      {},
+		"POST /etcd/moveLeader":                                  {},
+		"PUT /etcd/compaction":                                   {},
+	}
+
+	numDestructive := 0
+	for _, r := range router.listRoutes() {
+		if r.method == http.MethodGet || r.method == http.MethodHead || r.method == http.MethodOptions {
+			continue
+		}
+		path := strings.TrimPrefix(strings.TrimPrefix(r.path, apiPrefix), apiV2Prefix)
+		if _, ok := nonDestructiveRoutes[r.method+" "+path]; ok {
+			continue
+		}
+
+		segments := strings.Split(r.path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = testClusterName
+			}
+		}
+		req := httptest.NewRequest(r.method, strings.Join(segments, "/"), strings.NewReader("{}"))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		var resp testResponseV2
+		re.NoError(json.Unmarshal(recorder.Body.Bytes(), &resp), "route:%s %s", r.method, r.path)
+		re.Equal(ErrDisabledInSafeMode.Code().ToHTTPCode(), recorder.Code, "route:%s %s, error:%s", r.method, r.path, resp.Error)
+		re.Contains(resp.Error, ErrDisabledInSafeMode.Desc(), "route:%s %s", r.method, r.path)
+		numDestructive++
+	}
+	re.Positive(numDestructive)
+}
+
+func TestUpdateFlowLimiter(t *testing.T) {
+	re := require.New(t)
+	srv, _ := newTestServer(t)
+	url := srv.URL + "/api/v1/flowLimiter"
+
+	getConfig := func() FlowLimiterResult {
+		statusCode, resp := doTestRequest(t, http.MethodGet, url)
+		re.Equal(http.StatusOK, statusCode)
+		var result FlowLimiterResult
+		re.NoError(json.Unmarshal(resp.Data, &result))
+		return result
+	}
+	intPtr := func(v int) *int { return &v }
+
+	// Only the specified fields are updated, and the response is kept as before.
+	statusCode, resp := doTestRequestWithBody(t, http.MethodPut, url, UpdateFlowLimiterRequest{Limit: intPtr(100)})
+	re.Equal(http.StatusOK, statusCode)
+	re.JSONEq(`"`+statusSuccess+`"`, string(resp.Data))
+	re.Equal(100, getConfig().Limit)
+	re.Equal(20, getConfig().Burst)
+
+	// Only the fields specified in the expected config are compared.
+	statusCode, _ = doTestRequestWithBody(t, http.MethodPut, url, UpdateFlowLimiterRequest{
+		Burst:    intPtr(50),
+		Expected: &ExpectedFlowLimiter{Limit: intPtr(100)},
+	})
+	re.Equal(http.StatusOK, statusCode)
+	re.Equal(50, getConfig().Burst)
+
+	statusCode, resp = doTestRequestWithBody(t, http.MethodPut, url, UpdateFlowLimiterRequest{
+		Burst:    intPtr(60),
+		Expected: &ExpectedFlowLimiter{Limit: intPtr(10)},
+	})
+	re.Equal(http.StatusConflict, statusCode)
+	re.Equal(limiter.ErrLimiterConfigConflict.Error(), resp.Error)
+	re.Equal(50, getConfig().Burst)
+
+	// The limit and the burst must be positive.
+	for _, req := range []UpdateFlowLimiterRequest{{Limit: intPtr(0)}, {Burst: intPtr(-1)}, {CreateTableCost: intPtr(0)}} {
+		statusCode, _ = doTestRequestWithBody(t, http.MethodPut, url, req)
+		re.Equal(http.StatusBadRequest, statusCode)
+	}
+	re.Equal(100, getConfig().Limit)
+	re.Equal(50, getConfig().Burst)
+}
+
+func TestResetServerStatus(t *testing.T) {
+	re := require.New(t)
+	api, _ := newTestAPI(t, false)
+	srv := httptest.NewServer(api.NewAPIRouter())
+	t.Cleanup(srv.Close)
+	resetURL := srv.URL + DebugPrefix + "/status/reset"
+
+	// The server whose startup is not finished or has failed is never reset.
+	for _, s := range []status.Status{status.StatusWaiting, status.Terminated} {
+		api.serverStatus.Set(s)
+		statusCode, resp := doTestRequest(t, http.MethodPost, resetURL)
+		re.Equal(http.StatusInternalServerError, statusCode)
+		re.NotEqual(statusSuccess, resp.Status)
+		re.Equal(s, api.serverStatus.Get())
+	}
+
+	// The degraded server is reset once the readiness checks pass.
+	api.serverStatus.Set(status.StatusDegraded)
+	statusCode, resp := doTestRequest(t, http.MethodPost, resetURL)
+	re.Equal(http.StatusOK, statusCode)
+	re.Equal(statusSuccess, resp.Status)
+	re.Equal(status.StatusRunning, api.serverStatus.Get())
+}
@@ -0,0 +1,376 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	metahttp "github.com/apache/incubator-horaedb-meta/server/service/http"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	apiPrefix   = "/api/v1"
+	debugPrefix = "/debug"
+
+	statusSuccess = "success"
+
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultTimeout      = 30 * time.Second
+)
+
+// Options configures the Client.
+type Options struct {
+	// Endpoints are the http addresses of the HoraeMeta members, e.g. `http://127.0.0.1:8080`.
+	// Any member is accepted because the members forward the requests to the leader.
+	Endpoints []string
+	// MaxRetries is the max number of retries of a failed request, zero means the default and negative disables the retries.
+	MaxRetries int
+	// RetryBackoff is the wait before retrying a failed request, zero means the default.
+	RetryBackoff time.Duration
+	// Initiator tags the procedures submitted by the requests of the client, empty leaves them untagged.
+	Initiator string
+	// HTTPClient sends the requests, nil means a client with the default timeout.
+	HTTPClient *http.Client
+}
+
+// Client is the typed client of the HoraeMeta http admin api.
+//
+// The failures caused by an unavailable member or an unknown leader, e.g. during the leader election, are retried on the next endpoint.
+// The requests not safe to be applied twice are only retried if they are known not to be sent.
+type Client struct {
+	endpoints    []string
+	maxRetries   int
+	retryBackoff time.Duration
+	initiator    string
+	httpClient   *http.Client
+
+	lock sync.Mutex
+	// current is the index of the endpoint to send the requests to, which moves to the next endpoint on the retryable failures.
+	current int
+}
+
+func New(opts Options) (*Client, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, ErrInvalidOptions.WithCausef("endpoints could not be empty")
+	}
+	endpoints := make([]string, 0, len(opts.Endpoints))
+	for _, endpoint := range opts.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return nil, ErrInvalidOptions.WithCausef("invalid endpoint:%s, err:%v", endpoint, err)
+		}
+		endpoints = append(endpoints, strings.TrimRight(endpoint, "/"))
+	}
+
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	retryBackoff := opts.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Transport:     nil,
+			CheckRedirect: nil,
+			Jar:           nil,
+			Timeout:       defaultTimeout,
+		}
+	}
+
+	return &Client{
+		endpoints:    endpoints,
+		maxRetries:   maxRetries,
+		retryBackoff: retryBackoff,
+		initiator:    opts.Initiator,
+		httpClient:   httpClient,
+		lock:         sync.Mutex{},
+		current:      0,
+	}, nil
+}
+
+// CreateCluster creates the cluster and returns its id.
+func (c *Client) CreateCluster(ctx context.Context, req metahttp.CreateClusterRequest) (storage.ClusterID, error) {
+	var clusterID storage.ClusterID
+	// The creation of an existing cluster is rejected, so it is safe to retry.
+	attempts, err := c.doWithAttempts(ctx, http.MethodPost, apiPrefix+"/clusters", req, true, &clusterID)
+	if err != nil && attempts > 1 && isClusterAlreadyExists(err) {
+		// The retry is rejected if the former attempt whose response is lost has created the cluster.
+		return c.createdClusterID(ctx, req, err)
+	}
+	return clusterID, err
+}
+
+// createdClusterID returns the id of the existing cluster if it is created with the options of the request, otherwise the rejection is returned.
+// Only the options kept in the cluster metadata are compared.
+func (c *Client) createdClusterID(ctx context.Context, req metahttp.CreateClusterRequest, rejection error) (storage.ClusterID, error) {
+	clusters, err := c.ListClusters(ctx)
+	if err != nil {
+		return 0, errors.WithMessagef(rejection, "list clusters to check the existing cluster, err:%v", err)
+	}
+	for _, cluster := range clusters {
+		if cluster.Name != req.Name {
+			continue
+		}
+		if cluster.MinNodeCount == req.NodeCount && cluster.ShardTotal == req.ShardTotal && string(cluster.TopologyType) == req.TopologyType &&
+			cluster.ProcedureExecutingBatchSize == req.ProcedureExecutingBatchSize {
+			return cluster.ID, nil
+		}
+		break
+	}
+	return 0, rejection
+}
+
+// ListClusters lists the metadata of all the clusters.
+func (c *Client) ListClusters(ctx context.Context) ([]storage.Cluster, error) {
+	var clusters []storage.Cluster
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/clusters", nil, true, &clusters)
+	return clusters, err
+}
+
+// TransferLeader transfers the leader of the shard to the new node.
+func (c *Client) TransferLeader(ctx context.Context, req metahttp.TransferLeaderRequest) error {
+	return c.do(ctx, http.MethodPost, apiPrefix+"/transferLeader", req, true, nil)
+}
+
+// Split splits the tables out of the shard into a new shard, and returns the id of the new shard.
+func (c *Client) Split(ctx context.Context, req metahttp.SplitRequest) (storage.ShardID, error) {
+	var newShardID storage.ShardID
+	// Every split allocates a new shard, so it is not retried once it may have been sent.
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/split", req, false, &newShardID)
+	return newShardID, err
+}
+
+// ListProcedures lists the running procedures of the cluster.
+func (c *Client) ListProcedures(ctx context.Context, clusterName string) ([]*procedure.Info, error) {
+	var infos []*procedure.Info
+	err := c.do(ctx, http.MethodGet, clusterPath(clusterName, "procedure"), nil, true, &infos)
+	return infos, err
+}
+
+// ListShardAffinities lists the shard affinity rules of the cluster, scheduler name -> rule.
+func (c *Client) ListShardAffinities(ctx context.Context, clusterName string) (map[string]scheduler.ShardAffinityRule, error) {
+	var rules map[string]scheduler.ShardAffinityRule
+	err := c.do(ctx, http.MethodGet, clusterPath(clusterName, "shardAffinities"), nil, true, &rules)
+	return rules, err
+}
+
+// ListAllShardAffinities lists the shard affinity rules of all the clusters.
+func (c *Client) ListAllShardAffinities(ctx context.Context) ([]metahttp.ClusterShardAffinityRules, error) {
+	var rules []metahttp.ClusterShardAffinityRules
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/shardAffinities", nil, true, &rules)
+	return rules, err
+}
+
+// AddShardAffinities adds the shard affinities to the cluster as a rule.
+func (c *Client) AddShardAffinities(ctx context.Context, clusterName string, affinities []scheduler.ShardAffinity) error {
+	return c.do(ctx, http.MethodPost, clusterPath(clusterName, "shardAffinities"), affinities, true, nil)
+}
+
+// RemoveShardAffinities removes the shard affinities of the shards from the cluster.
+func (c *Client) RemoveShardAffinities(ctx context.Context, clusterName string, shardIDs []storage.ShardID) error {
+	req := metahttp.RemoveShardAffinitiesRequest{ShardIDs: shardIDs}
+	return c.do(ctx, http.MethodDelete, clusterPath(clusterName, "shardAffinities"), req, true, nil)
+}
+
+// RemoveShardAffinitiesByFilter removes the shard affinity rules selected by the filter from the cluster.
+func (c *Client) RemoveShardAffinitiesByFilter(ctx context.Context, clusterName string, filter manager.ShardAffinityFilter) (manager.RemoveShardAffinitiesResult, error) {
+	var result manager.RemoveShardAffinitiesResult
+	err := c.do(ctx, http.MethodPost, clusterPath(clusterName, "shardAffinities/removeByFilter"), filter, true, &result)
+	return result, err
+}
+
+// ValidateShardAffinities checks whether the shard affinities can be satisfied by the current topology of the cluster without applying them.
+func (c *Client) ValidateShardAffinities(ctx context.Context, clusterName string, affinities []scheduler.ShardAffinity) (metahttp.ValidateShardAffinitiesResult, error) {
+	var result metahttp.ValidateShardAffinitiesResult
+	err := c.do(ctx, http.MethodPost, clusterPath(clusterName, "shardAffinities/validate"), affinities, true, &result)
+	return result, err
+}
+
+// Leader returns the endpoint of the current leader known by the member serving the request.
+func (c *Client) Leader(ctx context.Context) (string, error) {
+	var leaderAddr string
+	err := c.do(ctx, http.MethodGet, debugPrefix+"/leader", nil, true, &leaderAddr)
+	return leaderAddr, err
+}
+
+func clusterPath(clusterName, subPath string) string {
+	return fmt.Sprintf("%s/clusters/%s/%s", apiPrefix, url.PathEscape(clusterName), subPath)
+}
+
+// response is the envelope of the responses of the http api.
+type response struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Msg    string          `json:"msg,omitempty"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// do sends the request and decodes the data of the response into the result, and the retryable failures are retried on the next endpoint.
+// The request is retried only if it is known not to be sent when the idempotent is false.
+func (c *Client) do(ctx context.Context, method, path string, reqBody any, idempotent bool, result any) error {
+	_, err := c.doWithAttempts(ctx, method, path, reqBody, idempotent, result)
+	return err
+}
+
+// doWithAttempts is like do, but it also returns the number of the attempts sending the request.
+func (c *Client) doWithAttempts(ctx context.Context, method, path string, reqBody any, idempotent bool, result any) (int, error) {
+	var body []byte
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return 0, ErrSendRequest.WithCausef("marshal request body, err:%v", err)
+		}
+		body = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		endpoint := c.currentEndpoint()
+		retryable, err := c.send(ctx, endpoint, method, path, body, idempotent, result)
+		if err == nil {
+			return attempt + 1, nil
+		}
+		if !retryable {
+			return attempt + 1, err
+		}
+		if attempt >= c.maxRetries {
+			return attempt + 1, ErrRetriesExceeded.WithCausef("attempts:%d, last err:%v", attempt+1, err)
+		}
+
+		c.moveToNextEndpoint(endpoint)
+		select {
+		case <-ctx.Done():
+			return attempt + 1, ErrSendRequest.WithCause(ctx.Err())
+		case <-time.After(c.retryBackoff):
+		}
+	}
+}
+
+// send sends the request to the endpoint once, and tells whether the failure is retryable.
+func (c *Client) send(ctx context.Context, endpoint, method, path string, body []byte, idempotent bool, result any) (bool, error) {
+	u := endpoint + path
+	if method != http.MethodGet && len(c.initiator) > 0 {
+		u += "?" + url.Values{"initiator": []string{c.initiator}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return false, ErrSendRequest.WithCause(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ErrSendRequest.WithCause(err)
+		}
+		return idempotent || isDialError(err), ErrSendRequest.WithCausef("endpoint:%s, err:%v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return idempotent, ErrDecodeResponse.WithCausef("read response body, endpoint:%s, err:%v", endpoint, err)
+	}
+
+	var decoded response
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		// The response not from the HoraeMeta, e.g. a proxy in the middle, is only retried if the request is idempotent.
+		return idempotent && resp.StatusCode >= http.StatusInternalServerError, ErrDecodeResponse.WithCausef("http status:%d, body:%s, err:%v", resp.StatusCode, b, err)
+	}
+	if decoded.Status != statusSuccess {
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Err:        decoded.Error,
+			Msg:        decoded.Msg,
+			Errors:     decoded.Errors,
+		}
+		// The member fails to forward the request mostly because the leader is unknown or unreachable, and the forwarded request may have been applied.
+		return idempotent && isForwardFailure(apiErr), apiErr
+	}
+
+	if result != nil && len(decoded.Data) > 0 {
+		if err := json.Unmarshal(decoded.Data, result); err != nil {
+			return false, ErrDecodeResponse.WithCausef("decode data:%s, err:%v", decoded.Data, err)
+		}
+	}
+	return false, nil
+}
+
+func (c *Client) currentEndpoint() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.endpoints[c.current]
+}
+
+// moveToNextEndpoint moves to the next endpoint if the failed endpoint is still the current one, so that the concurrent failures only move once.
+func (c *Client) moveToNextEndpoint(failed string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.endpoints[c.current] == failed {
+		c.current = (c.current + 1) % len(c.endpoints)
+	}
+}
+
+// isDialError tells whether the request fails to connect, which means the request is not sent at all.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// IsForwardFailure tells whether the error is caused by failing to forward the request to the leader.
+func IsForwardFailure(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && isForwardFailure(apiErr)
+}
+
+func isForwardFailure(apiErr *APIError) bool {
+	return apiErr.Err == metahttp.ErrForwardToLeader.Error()
+}
+
+func isClusterAlreadyExists(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Err == metadata.ErrClusterAlreadyExists.Error()
+}
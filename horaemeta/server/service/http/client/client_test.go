@@ -0,0 +1,384 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/apache/incubator-horaedb-meta/server/member"
+	metahttp "github.com/apache/incubator-horaedb-meta/server/service/http"
+	"github.com/apache/incubator-horaedb-meta/server/status"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/metastoragepb"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	testRootPath    = "/horaemeta"
+	testClusterName = "cluster0"
+)
+
+// leaderChecker keeps the local member as the leader.
+type leaderChecker struct{}
+
+func (leaderChecker) ShouldCampaign(_ *member.Member) bool {
+	return true
+}
+
+func (leaderChecker) IsValidLeader(_ *metastoragepb.Member) bool {
+	return true
+}
+
+// testMember serves the requests with the handler and counts them.
+type testMember struct {
+	server *httptest.Server
+	calls  atomic.Int32
+}
+
+func newTestMember(t *testing.T, handler http.Handler) *testMember {
+	m := &testMember{server: nil, calls: atomic.Int32{}}
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.calls.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// testRouters are the routers of the real http apis served by the members of a HoraeMeta cluster backed by the embedded etcd.
+type testRouters struct {
+	// leader serves the requests locally.
+	leader http.Handler
+	// follower fails to forward the requests because it never finds the leader.
+	follower http.Handler
+}
+
+func newTestRouters(t *testing.T) testRouters {
+	re := require.New(t)
+	_, client, closeSrv := etcdutil.PrepareEtcdServerAndClient(t)
+	t.Cleanup(closeSrv)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	leader := member.NewMember(testRootPath, 0, "mem0", "127.0.0.1:2379", client, nil, time.Second*10)
+	go func() {
+		_ = leader.CampaignAndKeepLeader(ctx, 5, leaderChecker{}, nil)
+	}()
+	re.Eventually(func() bool {
+		resp, err := leader.GetLeaderAddr(ctx)
+		return err == nil && resp.IsLocal
+	}, time.Second*10, time.Millisecond*10)
+	follower := member.NewMember(testRootPath, 1, "mem1", "127.0.0.1:2380", client, nil, time.Second*10)
+
+	clusterStorage := storage.NewStorageWithEtcdBackend(client, testRootPath, storage.Options{
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0,
+	})
+	clusterManager, err := cluster.NewManagerImpl(clusterStorage, client, client, cluster.ManagerOptions{
+		RootPath:                          testRootPath,
+		IDAllocatorStep:                   20,
+		ClusterKeyPrefixes:                nil,
+		TopologyType:                      storage.TopologyTypeStatic,
+		EnableSchemaAutoCreation:          true,
+		SchedulerConcurrency:              2,
+		MaxShardVersionDelta:              metadata.DefaultMaxShardVersionDelta,
+		ReadyShardStatuses:                []storage.ShardStatus{storage.ShardStatusReady},
+		NodePickerHash:                    metadata.NodePickerHash{Function: "", Seed: 0},
+		EnableProcedureCheckpoint:         false,
+		CreateTableOfflineShardPolicy:     metadata.OfflineShardPolicyFail,
+		ShardPickers:                      nil,
+		ProcedureTimeouts:                 procedure.Timeouts{},
+		ShardOscillationThreshold:         metadata.ShardOscillationThreshold{MaxMoves: 0, Window: 0},
+		PreferredLeaderStabilizationDelay: 0,
+		NodeStatsHistoryOptions:           metadata.NodeStatsHistoryOptions{Capacity: 0, Interval: 0},
+		MaxInflightCreatesPerShard:        0,
+		MaxPartitionSubTables:             0,
+		SubTableDispatchConcurrency:       0,
+		ProcedureStorageOptions:           procedure.StorageOptions{EtcdPrefix: ""},
+		RecentErrors:                      coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity),
+	})
+	re.NoError(err)
+	re.NoError(clusterManager.Start(ctx))
+	t.Cleanup(func() {
+		_ = clusterManager.Stop(context.Background())
+	})
+
+	newRouter := func(m *member.Member, etcdClient *clientv3.Client) http.Handler {
+		api := metahttp.NewAPI(clusterManager, status.NewServerStatus(), metahttp.NewForwardClient(m, 0), nil, etcdClient, nil, "", 0, nil, false, coderr.NewRecentErrors(coderr.DefaultRecentErrorsCapacity))
+		return api.NewAPIRouter()
+	}
+	return testRouters{leader: newRouter(leader, client), follower: newRouter(follower, client)}
+}
+
+func newTestClient(t *testing.T, endpoints ...string) *Client {
+	c, err := New(Options{
+		Endpoints:    endpoints,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		Initiator:    "test",
+		HTTPClient:   nil,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func newCreateClusterRequest(name string) metahttp.CreateClusterRequest {
+	return metahttp.CreateClusterRequest{
+		Name:                        name,
+		NodeCount:                   1,
+		ShardTotal:                  2,
+		EnableSchedule:              false,
+		TopologyType:                storage.TopologyTypeStatic,
+		ProcedureExecutingBatchSize: 100,
+		ShardIDs:                    nil,
+	}
+}
+
+// loseFirstResponse serves the first request with the handler but drops the connection instead of responding, as if the response is lost.
+func loseFirstResponse(handler http.Handler) http.Handler {
+	var lost atomic.Bool
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !lost.CompareAndSwap(false, true) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	})
+}
+
+func TestNewClientInvalidOptions(t *testing.T) {
+	re := require.New(t)
+
+	_, err := New(Options{Endpoints: nil, MaxRetries: 0, RetryBackoff: 0, Initiator: "", HTTPClient: nil})
+	re.True(coderr.Is(err, ErrInvalidOptions.Code()))
+	_, err = New(Options{Endpoints: []string{"127.0.0.1:8080"}, MaxRetries: 0, RetryBackoff: 0, Initiator: "", HTTPClient: nil})
+	re.True(coderr.Is(err, ErrInvalidOptions.Code()))
+}
+
+func TestClientCreateCluster(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, routers.leader)
+
+	c := newTestClient(t, leader.server.URL)
+	clusterID, err := c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	re.NoError(err)
+
+	clusters, err := c.ListClusters(ctx)
+	re.NoError(err)
+	re.Len(clusters, 1)
+	re.Equal(clusterID, clusters[0].ID)
+	re.Equal(testClusterName, clusters[0].Name)
+
+	// The creation of the existing cluster is rejected if it is not a retry.
+	_, err = c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	var apiErr *APIError
+	re.ErrorAs(err, &apiErr)
+	re.Equal(http.StatusConflict, apiErr.StatusCode)
+	re.True(isClusterAlreadyExists(err))
+}
+
+func TestClientCreateClusterRetryAfterLostResponse(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, loseFirstResponse(routers.leader))
+
+	// The rejected retry finds the cluster created by the first attempt by listing the clusters.
+	c := newTestClient(t, leader.server.URL)
+	clusterID, err := c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	re.NoError(err)
+	re.Equal(int32(3), leader.calls.Load())
+
+	clusters, err := c.ListClusters(ctx)
+	re.NoError(err)
+	re.Len(clusters, 1)
+	re.Equal(clusterID, clusters[0].ID)
+}
+
+func TestClientCreateClusterRetryMismatched(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, routers.leader)
+	c := newTestClient(t, leader.server.URL)
+	_, err := c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	re.NoError(err)
+
+	// The existing cluster created with other options is not taken as the one created by the former attempt.
+	req := newCreateClusterRequest(testClusterName)
+	req.ShardTotal = 4
+	_, err = c.createdClusterID(ctx, req, ErrSendRequest)
+	re.True(coderr.Is(err, ErrSendRequest.Code()))
+	clusterID, err := c.createdClusterID(ctx, newCreateClusterRequest(testClusterName), ErrSendRequest)
+	re.NoError(err)
+	clusters, err := c.ListClusters(ctx)
+	re.NoError(err)
+	re.Equal(clusters[0].ID, clusterID)
+}
+
+// newDynamicCreateClusterRequest creates the cluster of the dynamic topology whose schedulers support the shard affinities.
+func newDynamicCreateClusterRequest(name string) metahttp.CreateClusterRequest {
+	req := newCreateClusterRequest(name)
+	req.TopologyType = storage.TopologyTypeDynamic
+	return req
+}
+
+func TestClientShardAffinities(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, routers.leader)
+	c := newTestClient(t, leader.server.URL)
+	_, err := c.CreateCluster(ctx, newDynamicCreateClusterRequest(testClusterName))
+	re.NoError(err)
+
+	affinities := []scheduler.ShardAffinity{{ShardID: 1, NumAllowedOtherShards: 0}}
+	re.NoError(c.AddShardAffinities(ctx, testClusterName, affinities))
+	listed, err := c.ListShardAffinities(ctx, testClusterName)
+	re.NoError(err)
+	// Only the rebalanced scheduler supports the shard affinities.
+	re.Equal(affinities, listed["rebalanced_scheduler"].Affinities)
+
+	allRules, err := c.ListAllShardAffinities(ctx)
+	re.NoError(err)
+	re.Len(allRules, 1)
+	re.Equal(testClusterName, allRules[0].ClusterName)
+	re.Equal(listed, allRules[0].Rules)
+
+	re.NoError(c.RemoveShardAffinities(ctx, testClusterName, []storage.ShardID{1}))
+	listed, err = c.ListShardAffinities(ctx, testClusterName)
+	re.NoError(err)
+	re.Empty(listed["rebalanced_scheduler"].Affinities)
+}
+
+func TestClientRemoveShardAffinitiesByFilter(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, routers.leader)
+	c := newTestClient(t, leader.server.URL)
+	_, err := c.CreateCluster(ctx, newDynamicCreateClusterRequest(testClusterName))
+	re.NoError(err)
+
+	re.NoError(c.AddShardAffinities(ctx, testClusterName, []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 0}, {ShardID: 1, NumAllowedOtherShards: 0}}))
+	result, err := c.RemoveShardAffinitiesByFilter(ctx, testClusterName, manager.ShardAffinityFilter{All: true, NodeName: "", UnknownShards: false, OfflineNodes: false})
+	re.NoError(err)
+	re.ElementsMatch([]storage.ShardID{0, 1}, result.Removed)
+	re.Empty(result.Failed)
+}
+
+func TestClientAPIError(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	leader := newTestMember(t, routers.leader)
+	c := newTestClient(t, leader.server.URL)
+	_, err := c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	re.NoError(err)
+	calls := leader.calls.Load()
+
+	err = c.AddShardAffinities(ctx, testClusterName, []scheduler.ShardAffinity{{ShardID: 100, NumAllowedOtherShards: 0}, {ShardID: 100, NumAllowedOtherShards: 0}})
+	var apiErr *APIError
+	re.ErrorAs(err, &apiErr)
+	re.Equal(http.StatusBadRequest, apiErr.StatusCode)
+	re.Equal(metahttp.ErrInvalidShardAffinities.Error(), apiErr.Err)
+	re.NotEmpty(apiErr.Errors)
+	// The errors other than the forward failures are not retried.
+	re.Equal(calls+1, leader.calls.Load())
+}
+
+func TestClientRetryOnForwardFailure(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	routers := newTestRouters(t)
+	follower := newTestMember(t, routers.follower)
+	leader := newTestMember(t, routers.leader)
+
+	c := newTestClient(t, follower.server.URL, leader.server.URL)
+	_, err := c.CreateCluster(ctx, newCreateClusterRequest(testClusterName))
+	re.NoError(err)
+	re.Equal(int32(1), follower.calls.Load())
+	re.Equal(int32(1), leader.calls.Load())
+
+	// The client sticks to the endpoint serving the last request.
+	_, err = c.ListClusters(ctx)
+	re.NoError(err)
+	re.Equal(int32(1), follower.calls.Load())
+	re.Equal(int32(2), leader.calls.Load())
+}
+
+func TestClientRetryOnUnreachableEndpoint(t *testing.T) {
+	re := require.New(t)
+	routers := newTestRouters(t)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	leader := newTestMember(t, routers.leader)
+
+	// The split is retried because the unreachable endpoint refuses the connection and the request is not sent, and the leader rejects
+	// the split of the unknown cluster.
+	c := newTestClient(t, unreachable.URL, leader.server.URL)
+	_, err := c.Split(context.Background(), metahttp.SplitRequest{ClusterName: testClusterName, SchemaName: "public", ShardID: 1, SplitTables: []string{"t0"}, NodeName: "node0"})
+	var apiErr *APIError
+	re.ErrorAs(err, &apiErr)
+	re.False(IsForwardFailure(err))
+	re.Equal(int32(1), leader.calls.Load())
+}
+
+func TestClientNonIdempotentNotRetried(t *testing.T) {
+	re := require.New(t)
+	routers := newTestRouters(t)
+	follower := newTestMember(t, routers.follower)
+	leader := newTestMember(t, routers.leader)
+
+	c := newTestClient(t, follower.server.URL, leader.server.URL)
+	_, err := c.Split(context.Background(), metahttp.SplitRequest{ClusterName: testClusterName, SchemaName: "public", ShardID: 1, SplitTables: []string{"t0"}, NodeName: "node0"})
+	re.True(IsForwardFailure(err))
+	re.Equal(int32(1), follower.calls.Load())
+	re.Equal(int32(0), leader.calls.Load())
+}
+
+func TestClientRetriesExceeded(t *testing.T) {
+	re := require.New(t)
+	routers := newTestRouters(t)
+	follower := newTestMember(t, routers.follower)
+
+	c := newTestClient(t, follower.server.URL)
+	_, err := c.ListClusters(context.Background())
+	re.True(coderr.Is(err, ErrRetriesExceeded.Code()))
+	re.Equal(int32(3), follower.calls.Load())
+}
@@ -0,0 +1,49 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
+)
+
+var (
+	ErrInvalidOptions  = coderr.NewCodeError(coderr.InvalidParams, "invalid client options")
+	ErrSendRequest     = coderr.NewCodeError(coderr.Internal, "send request")
+	ErrDecodeResponse  = coderr.NewCodeError(coderr.Internal, "decode response")
+	ErrRetriesExceeded = coderr.NewCodeError(coderr.Internal, "retries exceeded")
+)
+
+// APIError is the error responded by the HoraeMeta http api.
+type APIError struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+	// Err is the description of the error, e.g. `(#500)get cluster, cause:<nil>`.
+	Err string
+	// Msg is the detailed message of the error.
+	Msg string
+	// Errors lists all the validation failures of the request, empty if the request isn't rejected by the validation.
+	Errors []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("http status:%d, error:%s, msg:%s", e.StatusCode, e.Err, e.Msg)
+}
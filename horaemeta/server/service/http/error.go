@@ -24,6 +24,8 @@ import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 var (
 	ErrParseRequest                  = coderr.NewCodeError(coderr.BadRequest, "parse request params")
 	ErrInvalidParamsForCreateCluster = coderr.NewCodeError(coderr.BadRequest, "invalid params to create cluster")
+	ErrInvalidParamsForUpdateCluster = coderr.NewCodeError(coderr.BadRequest, "invalid params to update cluster")
+	ErrInvalidShardAffinities        = coderr.NewCodeError(coderr.BadRequest, "invalid shard affinities")
 	ErrTable                         = coderr.NewCodeError(coderr.Internal, "table")
 	ErrRoute                         = coderr.NewCodeError(coderr.Internal, "route table")
 	ErrGetNodeShards                 = coderr.NewCodeError(coderr.Internal, "get node shards")
@@ -35,8 +37,11 @@ var (
 	ErrForwardToLeader               = coderr.NewCodeError(coderr.Internal, "forward to leader")
 	ErrParseLeaderAddr               = coderr.NewCodeError(coderr.Internal, "parse leader addr")
 	ErrHealthCheck                   = coderr.NewCodeError(coderr.Internal, "server health check")
+	ErrResetServerStatus             = coderr.NewCodeError(coderr.Internal, "reset server status")
 	ErrParseTopology                 = coderr.NewCodeError(coderr.Internal, "parse topology type")
 	ErrUpdateFlowLimiter             = coderr.NewCodeError(coderr.Internal, "update flow limiter")
+	ErrUpdateScanLimit               = coderr.NewCodeError(coderr.BadRequest, "update scan limit")
+	ErrUpdateClusterQuota            = coderr.NewCodeError(coderr.Internal, "update cluster quota")
 	ErrGetEnableSchedule             = coderr.NewCodeError(coderr.Internal, "get enableSchedule")
 	ErrUpdateEnableSchedule          = coderr.NewCodeError(coderr.Internal, "update enableSchedule")
 	ErrAddLearner                    = coderr.NewCodeError(coderr.Internal, "add member as learner")
@@ -46,4 +51,39 @@ var (
 	ErrListAffinityRules             = coderr.NewCodeError(coderr.Internal, "list affinity rules")
 	ErrAddAffinityRule               = coderr.NewCodeError(coderr.Internal, "add affinity rule")
 	ErrRemoveAffinityRule            = coderr.NewCodeError(coderr.Internal, "remove affinity rule")
+	ErrShardMaintenance              = coderr.NewCodeError(coderr.Internal, "shard maintenance")
+	ErrShardMinNodeVersion           = coderr.NewCodeError(coderr.BadRequest, "shard min node version")
+	ErrPreferredLeader               = coderr.NewCodeError(coderr.BadRequest, "preferred leader")
+	ErrUpdateNodePickerStrategy      = coderr.NewCodeError(coderr.BadRequest, "update node picker strategy")
+	ErrUpdateShardPermutation        = coderr.NewCodeError(coderr.Internal, "update shard permutation")
+	ErrCloseTableOnShard             = coderr.NewCodeError(coderr.Internal, "close table on shard")
+	ErrCloseGhostShard               = coderr.NewCodeError(coderr.Internal, "close ghost shard")
+	ErrDropOrphanTables              = coderr.NewCodeError(coderr.Internal, "drop orphan tables")
+	ErrCreateSchema                  = coderr.NewCodeError(coderr.Internal, "create schema")
+	ErrExpireNode                    = coderr.NewCodeError(coderr.Internal, "expire node")
+	ErrPurgeProcedures               = coderr.NewCodeError(coderr.Internal, "purge procedures")
+	ErrCancelProcedures              = coderr.NewCodeError(coderr.Internal, "cancel procedures")
+	ErrCloneCluster                  = coderr.NewCodeError(coderr.Internal, "clone cluster")
+	ErrExportProcedure               = coderr.NewCodeError(coderr.Internal, "export procedure")
+	ErrReplayProcedure               = coderr.NewCodeError(coderr.Internal, "replay procedure")
+	ErrTableExistsBatchTooLarge      = coderr.NewCodeError(coderr.BadRequest, "too many tables in a table existence request")
+	ErrStepDown                      = coderr.NewCodeError(coderr.Internal, "step down leader")
+	ErrGetLeaderLease                = coderr.NewCodeError(coderr.Internal, "get leader lease")
+	ErrRenewLeaderLease              = coderr.NewCodeError(coderr.Internal, "renew leader lease")
+	ErrExportMetadata                = coderr.NewCodeError(coderr.Internal, "export metadata")
+	ErrSimulateNodeLoss              = coderr.NewCodeError(coderr.BadRequest, "simulate node loss")
+	ErrEtcdCompaction                = coderr.NewCodeError(coderr.BadRequest, "etcd compaction")
+	ErrEtcdStatus                    = coderr.NewCodeError(coderr.Internal, "etcd status")
+	ErrDropSchemaTables              = coderr.NewCodeError(coderr.Internal, "drop schema tables")
+	ErrGetShardLeaderHistory         = coderr.NewCodeError(coderr.Internal, "get shard leader history")
+	ErrRebalanceShards               = coderr.NewCodeError(coderr.BadRequest, "rebalance shards")
+	ErrAdvanceShardVersion           = coderr.NewCodeError(coderr.Internal, "advance shard version")
+	ErrProcedureStats                = coderr.NewCodeError(coderr.Internal, "procedure stats")
+	ErrClearTableAssignment          = coderr.NewCodeError(coderr.Internal, "clear table assignment")
+	ErrUpdateDefaultPartitionCount   = coderr.NewCodeError(coderr.Internal, "update default partition count")
+	ErrDisabledInSafeMode            = coderr.NewCodeError(coderr.Forbidden, "disabled in safe mode")
+	ErrResyncShardWatch              = coderr.NewCodeError(coderr.Internal, "resync shard watch")
+	ErrCreateTableIDRange            = coderr.NewCodeError(coderr.Internal, "create table id range")
+	ErrDeleteTableIDRange            = coderr.NewCodeError(coderr.Internal, "delete table id range")
+	ErrPreWarmShard                  = coderr.NewCodeError(coderr.BadRequest, "pre-warm shard")
 )
@@ -25,6 +25,7 @@ import (
 	"net/http"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
@@ -32,6 +33,10 @@ import (
 type EtcdAPI struct {
 	etcdClient    *clientv3.Client
 	forwardClient *ForwardClient
+	// compaction is nil if the embedded etcd is disabled.
+	compaction *etcdutil.CompactionController
+	// embeddedEndpoint is the client endpoint of the embedded etcd, and it is empty if the embedded etcd is disabled.
+	embeddedEndpoint string
 }
 
 type AddMemberRequest struct {
@@ -55,10 +60,32 @@ type MoveLeaderRequest struct {
 	MemberName string `json:"memberName"`
 }
 
-func NewEtcdAPI(etcdClient *clientv3.Client, forwardClient *ForwardClient) EtcdAPI {
+type UpdateCompactionRequest struct {
+	Retention string `json:"retention"`
+}
+
+// EtcdStatus is the raft status of the embedded etcd, and the ids are formatted in hex like etcdctl.
+type EtcdStatus struct {
+	Endpoint         string   `json:"endpoint"`
+	Version          string   `json:"version"`
+	MemberID         string   `json:"memberID"`
+	LeaderID         string   `json:"leaderID"`
+	IsLeader         bool     `json:"isLeader"`
+	IsLearner        bool     `json:"isLearner"`
+	RaftTerm         uint64   `json:"raftTerm"`
+	RaftIndex        uint64   `json:"raftIndex"`
+	RaftAppliedIndex uint64   `json:"raftAppliedIndex"`
+	DBSize           int64    `json:"dbSize"`
+	DBSizeInUse      int64    `json:"dbSizeInUse"`
+	Errors           []string `json:"errors"`
+}
+
+func NewEtcdAPI(etcdClient *clientv3.Client, forwardClient *ForwardClient, compaction *etcdutil.CompactionController, embeddedEndpoint string) EtcdAPI {
 	return EtcdAPI{
-		etcdClient:    etcdClient,
-		forwardClient: forwardClient,
+		etcdClient:       etcdClient,
+		forwardClient:    forwardClient,
+		compaction:       compaction,
+		embeddedEndpoint: embeddedEndpoint,
 	}
 }
 
@@ -202,3 +229,61 @@ func (a *EtcdAPI) moveLeader(req *http.Request) apiFuncResult {
 
 	return errResult(ErrGetMember, fmt.Sprintf("member not found, member name: %s", moveLeaderRequest.MemberName))
 }
+
+func (a *EtcdAPI) getCompaction(_ *http.Request) apiFuncResult {
+	if a.compaction == nil {
+		return errResult(ErrEtcdCompaction, "embedded etcd is disabled")
+	}
+
+	return okResult(a.compaction.GetConfig())
+}
+
+// updateCompaction persists the runtime retention of the auto-compaction, which is applied by all the members with the embedded etcd.
+// The empty retention clears the runtime retention.
+func (a *EtcdAPI) updateCompaction(req *http.Request) apiFuncResult {
+	if a.compaction == nil {
+		return errResult(ErrEtcdCompaction, "embedded etcd is disabled")
+	}
+
+	var updateCompactionRequest UpdateCompactionRequest
+	err := json.NewDecoder(req.Body).Decode(&updateCompactionRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	if err := a.compaction.PutRuntimeRetention(req.Context(), a.etcdClient, updateCompactionRequest.Retention); err != nil {
+		log.Error("update compaction retention failed", zap.Error(err))
+		return errResult(ErrEtcdCompaction, err.Error())
+	}
+
+	return okResult(a.compaction.GetConfig())
+}
+
+// getStatus returns the raft status of the embedded etcd of the requested node.
+func (a *EtcdAPI) getStatus(req *http.Request) apiFuncResult {
+	if len(a.embeddedEndpoint) == 0 {
+		return errResult(ErrEtcdStatus, "embedded etcd is disabled")
+	}
+
+	resp, err := a.etcdClient.Status(req.Context(), a.embeddedEndpoint)
+	if err != nil {
+		log.Error("get etcd status failed", zap.String("endpoint", a.embeddedEndpoint), zap.Error(err))
+		return errResult(ErrEtcdStatus, err.Error())
+	}
+
+	return okResult(EtcdStatus{
+		Endpoint:         a.embeddedEndpoint,
+		Version:          resp.Version,
+		MemberID:         fmt.Sprintf("%x", resp.Header.MemberId),
+		LeaderID:         fmt.Sprintf("%x", resp.Leader),
+		IsLeader:         resp.Header.MemberId == resp.Leader,
+		IsLearner:        resp.IsLearner,
+		RaftTerm:         resp.RaftTerm,
+		RaftIndex:        resp.RaftIndex,
+		RaftAppliedIndex: resp.RaftAppliedIndex,
+		DBSize:           resp.DbSize,
+		DBSizeInUse:      resp.DbSizeInUse,
+		Errors:           resp.Errors,
+	})
+}
@@ -58,6 +58,31 @@ func (s *ForwardClient) GetLeaderAddr(ctx context.Context) (string, error) {
 	return resp.LeaderEndpoint, nil
 }
 
+// StepDown gives up the leadership of the local member, and returns the endpoint of the new leader.
+func (s *ForwardClient) StepDown(ctx context.Context, campaignBackoff time.Duration) (string, error) {
+	resp, err := s.member.StepDown(ctx, campaignBackoff)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.LeaderEndpoint, nil
+}
+
+// GetLeaderStats returns the current leader and the elections observed by the local member.
+func (s *ForwardClient) GetLeaderStats() member.LeaderStats {
+	return s.member.GetLeaderStats()
+}
+
+// GetLeaderLease returns the lease of the leader key held by the local leader.
+func (s *ForwardClient) GetLeaderLease(ctx context.Context) (member.LeaderLease, error) {
+	return s.member.GetLeaderLease(ctx)
+}
+
+// RenewLeaderLease renews the lease of the leader key held by the local leader once.
+func (s *ForwardClient) RenewLeaderLease(ctx context.Context) (member.LeaderLease, error) {
+	return s.member.RenewLeaderLease(ctx)
+}
+
 func (s *ForwardClient) getForwardedAddr(ctx context.Context) (string, bool, error) {
 	resp, err := s.member.GetLeaderAddr(ctx)
 	if err != nil {
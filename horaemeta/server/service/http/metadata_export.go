@@ -0,0 +1,238 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+)
+
+// metadataExportFlushThreshold is the number of the buffered bytes which triggers flushing the exported metadata to the client.
+const metadataExportFlushThreshold = 64 * 1024
+
+// metadataExporter writes the metadata of the clusters as a readable json document:
+//
+//	{"clusters":[{"cluster":{...},"clusterView":{...},"shardViews":[...],"schemas":[{"schema":{...},"tables":[...]}]}]}
+//
+// The clusters, schemas and tables are ordered by name, and the shard views are ordered by shard id, so the exported
+// documents are diff-friendly. The tables are encoded one by one, so the whole metadata is never held in memory.
+type metadataExporter struct {
+	w       *bufio.Writer
+	flusher http.Flusher
+}
+
+func newMetadataExporter(w io.Writer) *metadataExporter {
+	flusher, _ := w.(http.Flusher)
+	return &metadataExporter{
+		w:       bufio.NewWriterSize(w, metadataExportFlushThreshold),
+		flusher: flusher,
+	}
+}
+
+func (e *metadataExporter) writeRaw(s string) error {
+	_, err := e.w.WriteString(s)
+	return err
+}
+
+func (e *metadataExporter) writeValue(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithMessage(err, "marshal exported metadata")
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+// writeField writes `"key":value` with the leading comma if it is not the first field.
+func (e *metadataExporter) writeField(key string, v any, first bool) error {
+	if !first {
+		if err := e.writeRaw(","); err != nil {
+			return err
+		}
+	}
+	if err := e.writeValue(key); err != nil {
+		return err
+	}
+	if err := e.writeRaw(":"); err != nil {
+		return err
+	}
+	return e.writeValue(v)
+}
+
+// maybeFlush sends the buffered data to the client once the buffer is nearly full.
+func (e *metadataExporter) maybeFlush() error {
+	if e.w.Available() > e.w.Size()/4 {
+		return nil
+	}
+	return e.flush()
+}
+
+func (e *metadataExporter) flush() error {
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+func (e *metadataExporter) export(clusters []*cluster.Cluster) error {
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].GetMetadata().Name() < clusters[j].GetMetadata().Name()
+	})
+
+	if err := e.writeRaw(`{"clusters":[`); err != nil {
+		return err
+	}
+	for i, c := range clusters {
+		if i > 0 {
+			if err := e.writeRaw(","); err != nil {
+				return err
+			}
+		}
+		if err := e.exportCluster(c); err != nil {
+			return errors.WithMessagef(err, "export cluster, clusterName:%s", c.GetMetadata().Name())
+		}
+	}
+	if err := e.writeRaw("]}\n"); err != nil {
+		return err
+	}
+
+	return e.flush()
+}
+
+func (e *metadataExporter) exportCluster(c *cluster.Cluster) error {
+	clusterMetadata := c.GetMetadata()
+	topology := clusterMetadata.GetClusterSnapshot().Topology
+	shardViews := make([]ExportedShardView, 0, len(topology.ShardViewsMapping))
+	for _, shardView := range topology.ShardViewsMapping {
+		shardViews = append(shardViews, ExportedShardView{
+			ShardID:   shardView.ShardID,
+			Version:   shardView.Version,
+			TableIDs:  shardView.TableIDs,
+			CreatedAt: shardView.CreatedAt,
+		})
+	}
+	sort.Slice(shardViews, func(i, j int) bool { return shardViews[i].ShardID < shardViews[j].ShardID })
+
+	clusterView := topology.ClusterView
+	shardNodes := make([]ShardNodeItem, 0, len(clusterView.ShardNodes))
+	for _, shardNode := range clusterView.ShardNodes {
+		shardNodes = append(shardNodes, ShardNodeItem{
+			ShardID:  shardNode.ID,
+			NodeName: shardNode.NodeName,
+			Role:     storage.ConvertShardRoleToString(shardNode.ShardRole),
+			Version:  topology.ShardViewsMapping[shardNode.ID].Version,
+		})
+	}
+	sort.Slice(shardNodes, func(i, j int) bool {
+		if shardNodes[i].ShardID != shardNodes[j].ShardID {
+			return shardNodes[i].ShardID < shardNodes[j].ShardID
+		}
+		return shardNodes[i].NodeName < shardNodes[j].NodeName
+	})
+
+	storageMetadata := clusterMetadata.GetStorageMetadata()
+	if err := e.writeRaw("{"); err != nil {
+		return err
+	}
+	if err := e.writeField("cluster", ExportedCluster{
+		ID:                          storageMetadata.ID,
+		Name:                        storageMetadata.Name,
+		MinNodeCount:                storageMetadata.MinNodeCount,
+		ShardTotal:                  storageMetadata.ShardTotal,
+		TopologyType:                storageMetadata.TopologyType,
+		ProcedureExecutingBatchSize: storageMetadata.ProcedureExecutingBatchSize,
+		CreatedAt:                   storageMetadata.CreatedAt,
+		ModifiedAt:                  storageMetadata.ModifiedAt,
+	}, true); err != nil {
+		return err
+	}
+	if err := e.writeField("clusterView", ExportedClusterView{
+		Version:    clusterView.Version,
+		State:      storage.ConvertClusterStateToString(clusterView.State),
+		ShardNodes: shardNodes,
+		CreatedAt:  clusterView.CreatedAt,
+	}, false); err != nil {
+		return err
+	}
+	if err := e.writeField("shardViews", shardViews, false); err != nil {
+		return err
+	}
+
+	if err := e.writeRaw(`,"schemas":[`); err != nil {
+		return err
+	}
+	schemas := clusterMetadata.GetSchemas()
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	for i, schema := range schemas {
+		if i > 0 {
+			if err := e.writeRaw(","); err != nil {
+				return err
+			}
+		}
+		if err := e.writeRaw("{"); err != nil {
+			return err
+		}
+		if err := e.writeField("schema", ExportedSchema{
+			ID:        schema.ID,
+			Name:      schema.Name,
+			CreatedAt: schema.CreatedAt,
+		}, true); err != nil {
+			return err
+		}
+		if err := e.writeRaw(`,"tables":[`); err != nil {
+			return err
+		}
+		tables := clusterMetadata.GetTablesOfSchema(schema.Name)
+		sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+		for j, table := range tables {
+			if j > 0 {
+				if err := e.writeRaw(","); err != nil {
+					return err
+				}
+			}
+			if err := e.writeValue(ExportedTable{
+				ID:            table.ID,
+				Name:          table.Name,
+				CreatedAt:     table.CreatedAt,
+				PartitionInfo: table.PartitionInfo.Info,
+			}); err != nil {
+				return err
+			}
+			if err := e.maybeFlush(); err != nil {
+				return err
+			}
+		}
+		if err := e.writeRaw("]}"); err != nil {
+			return err
+		}
+	}
+
+	return e.writeRaw("]}")
+}
@@ -25,27 +25,48 @@ import (
 
 type param string
 
+// route is a registered route, and the routes are listed to check them all at once, e.g. whether the mutating ones respect the safe mode.
+type route struct {
+	method string
+	path   string
+}
+
 const DebugPrefix = "/debug"
 
 // Router wraps httprouter.Router and adds support for prefixed sub-routers,
 // per-request context injections and instrumentation.
 type Router struct {
-	rtr    *httprouter.Router
-	prefix string
-	instrh func(handlerName string, handler http.HandlerFunc) http.HandlerFunc
+	rtr *httprouter.Router
+	// prefixes are the prefixes of the registered routes, and every route is registered under all of them.
+	prefixes []string
+	instrh   func(handlerName string, handler http.HandlerFunc) http.HandlerFunc
+	// routes is shared by the routers derived from the same router.
+	routes *[]route
 }
 
 func New() *Router {
 	return &Router{
-		rtr:    httprouter.New(),
-		prefix: "",
-		instrh: nil,
+		rtr:      httprouter.New(),
+		prefixes: []string{""},
+		instrh:   nil,
+		routes:   &[]route{},
 	}
 }
 
 // WithPrefix returns a router that prefixes all registered routes with prefix.
 func (r *Router) WithPrefix(prefix string) *Router {
-	return &Router{rtr: r.rtr, prefix: r.prefix + prefix, instrh: r.instrh}
+	return r.WithPrefixes(prefix)
+}
+
+// WithPrefixes returns a router that registers all the routes under every one of the prefixes, e.g. the prefixes of the api versions.
+func (r *Router) WithPrefixes(prefixes ...string) *Router {
+	newPrefixes := make([]string, 0, len(r.prefixes)*len(prefixes))
+	for _, oldPrefix := range r.prefixes {
+		for _, prefix := range prefixes {
+			newPrefixes = append(newPrefixes, oldPrefix+prefix)
+		}
+	}
+	return &Router{rtr: r.rtr, prefixes: newPrefixes, instrh: r.instrh, routes: r.routes}
 }
 
 // WithInstrumentation returns a router with instrumentation support.
@@ -56,7 +77,7 @@ func (r *Router) WithInstrumentation(instrh func(handlerName string, handler htt
 			return newInstrh(handlerName, r.instrh(handlerName, handler))
 		}
 	}
-	return &Router{rtr: r.rtr, prefix: r.prefix, instrh: instrh}
+	return &Router{rtr: r.rtr, prefixes: r.prefixes, instrh: instrh, routes: r.routes}
 }
 
 // ServeHTTP implements http.Handler.
@@ -66,42 +87,74 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 // Get registers a new GET route.
 func (r *Router) Get(path string, h http.HandlerFunc) {
-	r.rtr.GET(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodGet, prefix+path, r.handle(path, h))
+	}
 }
 
 // DebugGet registers a new GET route without prefix.
 func (r *Router) DebugGet(path string, h http.HandlerFunc) {
-	r.rtr.GET(DebugPrefix+path, r.handle(path, h))
+	r.register(http.MethodGet, DebugPrefix+path, r.handle(path, h))
+}
+
+// RootGet registers a new GET route without any prefix.
+func (r *Router) RootGet(path string, h http.HandlerFunc) {
+	r.register(http.MethodGet, path, r.handle(path, h))
 }
 
 // Options registers a new OPTIONS route.
 func (r *Router) Options(path string, h http.HandlerFunc) {
-	r.rtr.OPTIONS(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodOptions, prefix+path, r.handle(path, h))
+	}
 }
 
 // Del registers a new DELETE route.
 func (r *Router) Del(path string, h http.HandlerFunc) {
-	r.rtr.DELETE(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodDelete, prefix+path, r.handle(path, h))
+	}
 }
 
 // Put registers a new PUT route.
 func (r *Router) Put(path string, h http.HandlerFunc) {
-	r.rtr.PUT(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodPut, prefix+path, r.handle(path, h))
+	}
 }
 
 // DebugPut registers a new PUT route without prefix.
 func (r *Router) DebugPut(path string, h http.HandlerFunc) {
-	r.rtr.PUT(DebugPrefix+path, r.handle(path, h))
+	r.register(http.MethodPut, DebugPrefix+path, r.handle(path, h))
+}
+
+// DebugPost registers a new POST route with the debug prefix.
+func (r *Router) DebugPost(path string, h http.HandlerFunc) {
+	r.register(http.MethodPost, DebugPrefix+path, r.handle(path, h))
 }
 
 // Post registers a new POST route.
 func (r *Router) Post(path string, h http.HandlerFunc) {
-	r.rtr.POST(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodPost, prefix+path, r.handle(path, h))
+	}
 }
 
 // Head registers a new HEAD route.
 func (r *Router) Head(path string, h http.HandlerFunc) {
-	r.rtr.HEAD(r.prefix+path, r.handle(path, h))
+	for _, prefix := range r.prefixes {
+		r.register(http.MethodHead, prefix+path, r.handle(path, h))
+	}
+}
+
+func (r *Router) register(method, path string, h httprouter.Handle) {
+	r.rtr.Handle(method, path, h)
+	*r.routes = append(*r.routes, route{method: method, path: path})
+}
+
+// listRoutes returns all the registered routes in the order of the registration.
+func (r *Router) listRoutes() []route {
+	return append([]route{}, *r.routes...)
 }
 
 // handle turns a HandlerFunc into a httprouter.Handle.
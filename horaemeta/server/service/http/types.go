@@ -21,20 +21,59 @@ package http
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/status"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 )
 
 const (
 	statusSuccess    string = "success"
 	statusError      string = "error"
 	clusterNameParam string = "cluster"
+	tableNameParam   string = "table"
+	procedureIDParam string = "procedureID"
+	shardIDParam     string = "shardID"
+	nodeNameParam    string = "node"
+	schemaNameParam  string = "schema"
+	schemaNameQuery  string = "schema"
+
+	replicationFactorQuery string = "replicationFactor"
+	// initiatorQuery is accepted by the mutating endpoints to tag the submitted procedures with who initiates them.
+	initiatorQuery string = "initiator"
+	// windowSecQuery is the window in seconds of the finished procedures counted in the procedure stats.
+	windowSecQuery string = "windowSec"
+	// confirmQuery must be the name of the schema to drop all its tables, which prevents dropping them by accident.
+	confirmQuery string = "confirm"
+	// targetClusterQuery is the name of the cluster compared with.
+	targetClusterQuery string = "target"
+	// maxInitiatorLen is the max length of the initiator.
+	maxInitiatorLen int = 128
+
+	// maxTableExistsBatchSize is the max number of tables checked in a single table existence request.
+	maxTableExistsBatchSize int = 1000
 
 	apiPrefix string = "/api/v1"
+	// apiV2Prefix serves the same apis as the apiPrefix, and the responses are wrapped in the v2 envelope.
+	apiV2Prefix string = "/api/v2"
+)
+
+const (
+	// stepDownTimeout is the max duration waiting for the new leader after stepping down.
+	stepDownTimeout = 30 * time.Second
+	// stepDownCampaignBackoff is the duration in which the stepped down member won't campaign the leadership again.
+	stepDownCampaignBackoff = 10 * time.Second
+	// statusResetCheckTimeout bounds the checks of etcd and the leader before resetting the server status.
+	statusResetCheckTimeout = 5 * time.Second
 )
 
 type response struct {
@@ -42,12 +81,26 @@ type response struct {
 	Data   interface{} `json:"data,omitempty"`
 	Error  string      `json:"error,omitempty"`
 	Msg    string      `json:"msg,omitempty"`
+	// Errors lists all the validation failures of the request, so that the client can fix them at once.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// responseV2 is the response envelope of the v2 apis, which carries the code of the error for the clients to tell the errors apart,
+// and the details of the error are merged into the error instead of the msg.
+type responseV2 struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Code   coderr.Code `json:"code,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// Errors lists all the validation failures of the request, so that the client can fix them at once.
+	Errors []string `json:"errors,omitempty"`
 }
 
 type apiFuncResult struct {
 	data   interface{}
 	err    coderr.CodeError
 	errMsg string
+	errs   []string
 }
 
 func okResult(data interface{}) apiFuncResult {
@@ -55,6 +108,7 @@ func okResult(data interface{}) apiFuncResult {
 		data:   data,
 		err:    nil,
 		errMsg: "",
+		errs:   nil,
 	}
 }
 
@@ -63,6 +117,17 @@ func errResult(err coderr.CodeError, errMsg string) apiFuncResult {
 		data:   nil,
 		err:    err,
 		errMsg: errMsg,
+		errs:   nil,
+	}
+}
+
+// validationErrResult reports all the validation failures of the request, and errs must not be empty.
+func validationErrResult(err coderr.CodeError, errs []string) apiFuncResult {
+	return apiFuncResult{
+		data:   nil,
+		err:    err,
+		errMsg: strings.Join(errs, "; "),
+		errs:   errs,
 	}
 }
 
@@ -75,6 +140,14 @@ type API struct {
 
 	forwardClient *ForwardClient
 	flowLimiter   *limiter.FlowLimiter
+	// slowRequestThreshold is the latency beyond which the request is logged as a warning, zero disables the logging.
+	slowRequestThreshold time.Duration
+	// effectiveConfig is the config items of the server with their sources, which is immutable after the server starts.
+	effectiveConfig []config.EffectiveItem
+	// safeMode disables the destructive endpoints, e.g. dropping tables and removing etcd members.
+	safeMode bool
+	// recentErrors records the errors responded by the handlers for triage.
+	recentErrors *coderr.RecentErrors
 
 	etcdAPI EtcdAPI
 }
@@ -88,6 +161,65 @@ type DiagnoseShardResult struct {
 	// shardID -> nodeName
 	UnregisteredShards []storage.ShardID                       `json:"unregisteredShards"`
 	UnreadyShards      map[storage.ShardID]DiagnoseShardStatus `json:"unreadyShards"`
+	// shardID -> maintenance reason, these shards are skipped by the schedulers.
+	MaintenanceShards map[storage.ShardID]string `json:"maintenanceShards"`
+	// The shards reported by the nodes but not assigned to them in the cluster view.
+	GhostShards []DiagnoseGhostShard `json:"ghostShards"`
+	// The shards whose leader moves too frequently, and their further moves are suppressed by the scheduler manager.
+	OscillatingShards []manager.OscillatingShard `json:"oscillatingShards"`
+	// The shards which can't be scheduled because no eligible node is online, which tells a stuck cluster from an idle one.
+	NoEligibleNodeShards []storage.ShardID `json:"noEligibleNodeShards"`
+}
+
+type DiagnoseGhostShard struct {
+	ShardID  storage.ShardID `json:"shardID"`
+	NodeName string          `json:"nodeName"`
+	// Unknown is true if the shard doesn't exist in the cluster at all, otherwise it is assigned to other nodes.
+	Unknown bool `json:"unknown"`
+}
+
+type CloseGhostShardRequest struct {
+	NodeName string          `json:"nodeName"`
+	ShardID  storage.ShardID `json:"shardID"`
+}
+
+type AdvanceShardVersionRequest struct {
+	ShardID storage.ShardID `json:"shardID"`
+	Version uint64          `json:"version"`
+}
+
+type AdvanceShardVersionResult struct {
+	ShardID     storage.ShardID `json:"shardID"`
+	PrevVersion uint64          `json:"prevVersion"`
+	Version     uint64          `json:"version"`
+}
+
+type DiagnoseReplicaShard struct {
+	ShardID         storage.ShardID `json:"shardID"`
+	HealthyReplicas int             `json:"healthyReplicas"`
+	MissingReplicas int             `json:"missingReplicas"`
+}
+
+type DiagnoseReplicaResult struct {
+	ReplicationFactor     int                    `json:"replicationFactor"`
+	UnderReplicatedShards []DiagnoseReplicaShard `json:"underReplicatedShards"`
+}
+
+// NodeCluster describes a cluster in which the node is registered.
+type NodeCluster struct {
+	ClusterName   string `json:"clusterName"`
+	LastTouchTime uint64 `json:"lastTouchTime"`
+	Expired       bool   `json:"expired"`
+	ShardCount    int    `json:"shardCount"`
+}
+
+type DiagnoseHeartbeatNode struct {
+	NodeName      string `json:"nodeName"`
+	LastTouchTime uint64 `json:"lastTouchTime"`
+	// LagMs is the milliseconds elapsed since the last heartbeat of the node.
+	LagMs        int64 `json:"lagMs"`
+	Expired      bool  `json:"expired"`
+	ShuttingDown bool  `json:"shuttingDown"`
 }
 
 type QueryTableRequest struct {
@@ -97,6 +229,42 @@ type QueryTableRequest struct {
 	IDs         []uint64 `json:"ids"`
 }
 
+type TableExistsRequest struct {
+	ClusterName string            `json:"clusterName"`
+	Tables      []SchemaTableName `json:"tables"`
+}
+
+type SchemaTableName struct {
+	SchemaName string `json:"schemaName"`
+	TableName  string `json:"tableName"`
+}
+
+// TableExistsResult contains the existence of the requested tables, schemaName -> tableName -> exists.
+type TableExistsResult map[string]map[string]bool
+
+type StepDownResult struct {
+	NewLeaderEndpoint string `json:"newLeaderEndpoint"`
+}
+
+type LeaderStatsResult struct {
+	LeaderName     string `json:"leaderName"`
+	LeaderEndpoint string `json:"leaderEndpoint"`
+	// LeaderSince is the unix timestamp in milliseconds since when the leader holds the leadership, and it is zero if no leader is observed.
+	LeaderSince             int64  `json:"leaderSince"`
+	UptimeMs                int64  `json:"uptimeMs"`
+	RecentElections         int    `json:"recentElections"`
+	RecentElectionWindowSec int64  `json:"recentElectionWindowSec"`
+	TotalElections          uint64 `json:"totalElections"`
+}
+
+type LeaderLeaseResult struct {
+	LeaseID         int64 `json:"leaseID"`
+	GrantedTTLSec   int64 `json:"grantedTTLSec"`
+	RemainingTTLSec int64 `json:"remainingTTLSec"`
+	// ExpireTime is the unix timestamp in milliseconds when the leader considers the lease expired.
+	ExpireTime int64 `json:"expireTime"`
+}
+
 type GetShardTablesRequest struct {
 	ClusterName string   `json:"clusterName"`
 	ShardIDs    []uint32 `json:"shardIDs"`
@@ -145,6 +313,22 @@ type CreateClusterRequest struct {
 	EnableSchedule              bool   `json:"enableSchedule"`
 	TopologyType                string `json:"topologyType"`
 	ProcedureExecutingBatchSize uint32 `json:"procedureExecutingBatchSize"`
+	// ShardIDs is optional, and the shards of the cluster take these ids instead of the allocated ones if it is specified, e.g. to keep
+	// the shard ids of the deleted cluster, and every id must be less than the shard total. The stale shards of the deleted cluster must be
+	// closed on the nodes before reusing the ids.
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+}
+
+type CloneClusterRequest struct {
+	// Name is the name of the new cluster.
+	Name string `json:"name"`
+	// CopyTables copies the table metadata of the source cluster without data.
+	CopyTables bool `json:"copyTables"`
+}
+
+type CloneClusterResult struct {
+	ClusterID    storage.ClusterID `json:"clusterID"`
+	CopiedTables int               `json:"copiedTables"`
 }
 
 type UpdateClusterRequest struct {
@@ -155,16 +339,354 @@ type UpdateClusterRequest struct {
 	ProcedureExecutingBatchSize uint32 `json:"procedureExecutingBatchSize"`
 }
 
+type UpdateClusterQuotaRequest struct {
+	// MaxTables is the max number of tables the cluster can hold, zero means unlimited.
+	MaxTables uint64 `json:"maxTables"`
+}
+
+type ShardNodeItem struct {
+	ShardID  storage.ShardID `json:"shardID"`
+	NodeName string          `json:"nodeName"`
+	Role     string          `json:"role"`
+	// Version is the version of the shard view, zero if the shard view is missing.
+	Version uint64 `json:"version"`
+}
+
+// ShardCountResult summarizes the assignment of the shards in the cluster view.
+type ShardCountResult struct {
+	// Total is the number of the shards in the shard views.
+	Total int `json:"total"`
+	// Assigned is the number of the shards assigned to at least one node.
+	Assigned int `json:"assigned"`
+	// UnassignedShards is the shards assigned to no node, ordered by the shard id.
+	UnassignedShards []storage.ShardID `json:"unassignedShards"`
+}
+
+// NodeStatsSample is the utilization of a node sampled from its heartbeat.
+type NodeStatsSample struct {
+	// Timestamp is the unix milliseconds when the sample is taken.
+	Timestamp         int64 `json:"timestamp"`
+	ShardCount        int   `json:"shardCount"`
+	LeaderShardCount  int   `json:"leaderShardCount"`
+	UnreadyShardCount int   `json:"unreadyShardCount"`
+	TableCount        int   `json:"tableCount"`
+	ShuttingDown      bool  `json:"shuttingDown"`
+}
+
+type ExportedCluster struct {
+	ID                          storage.ClusterID    `json:"id"`
+	Name                        string               `json:"name"`
+	MinNodeCount                uint32               `json:"minNodeCount"`
+	ShardTotal                  uint32               `json:"shardTotal"`
+	TopologyType                storage.TopologyType `json:"topologyType"`
+	ProcedureExecutingBatchSize uint32               `json:"procedureExecutingBatchSize"`
+	CreatedAt                   uint64               `json:"createdAt"`
+	ModifiedAt                  uint64               `json:"modifiedAt"`
+}
+
+type ExportedClusterView struct {
+	Version    uint64          `json:"version"`
+	State      string          `json:"state"`
+	ShardNodes []ShardNodeItem `json:"shardNodes"`
+	CreatedAt  uint64          `json:"createdAt"`
+}
+
+type ExportedShardView struct {
+	ShardID   storage.ShardID   `json:"shardID"`
+	Version   uint64            `json:"version"`
+	TableIDs  []storage.TableID `json:"tableIDs"`
+	CreatedAt uint64            `json:"createdAt"`
+}
+
+type ExportedSchema struct {
+	ID        storage.SchemaID `json:"id"`
+	Name      string           `json:"name"`
+	CreatedAt uint64           `json:"createdAt"`
+}
+
+type ExportedTable struct {
+	ID        storage.TableID `json:"id"`
+	Name      string          `json:"name"`
+	CreatedAt uint64          `json:"createdAt"`
+	// PartitionInfo is only set for the partition tables.
+	PartitionInfo *clusterpb.PartitionInfo `json:"partitionInfo,omitempty"`
+}
+
+type SimulateNodeLossRequest struct {
+	NodeName string `json:"nodeName"`
+}
+
+type SimulateNodeLossResult struct {
+	NodeName      string                      `json:"nodeName"`
+	Reassignments []manager.ShardReassignment `json:"reassignments"`
+}
+
+type RebalanceShardsRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+	// DryRun only plans the moves without applying them.
+	DryRun bool `json:"dryRun"`
+}
+
+type RebalanceShardsResult struct {
+	DryRun bool                        `json:"dryRun"`
+	Moves  []manager.ShardReassignment `json:"moves"`
+}
+
+type TopologyVersion struct {
+	Version uint64 `json:"version"`
+}
+
+type DefaultPartitionCount struct {
+	// Count is the number of the partitions of the partition tables created without partitions, zero means no default.
+	// The partitions specified by the client always take precedence over it.
+	Count uint32 `json:"count"`
+	// ShardTotal is the upper bound of the count.
+	ShardTotal uint32 `json:"shardTotal"`
+}
+
+type UpdateDefaultPartitionCountRequest struct {
+	Count uint32 `json:"count"`
+}
+
+type CreateTableIDRangeRequest struct {
+	SchemaName string `json:"schemaName"`
+	// The table ids of the schema are allocated in [Start, End).
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+type ClusterQuota struct {
+	MaxTables  uint64 `json:"maxTables"`
+	TableCount int    `json:"tableCount"`
+}
+
+type NodePickerStrategy struct {
+	// Strategy is the node picker strategy of the cluster, empty means the default strategy.
+	Strategy             string   `json:"strategy"`
+	RegisteredStrategies []string `json:"registeredStrategies"`
+}
+
+type UpdateNodePickerStrategyRequest struct {
+	Strategy string `json:"strategy"`
+}
+
+type PreWarmShardRequest struct {
+	// ExpectedTableCount is the number of the tables expected to be created on the shard, zero if unknown.
+	ExpectedTableCount uint32 `json:"expectedTableCount"`
+}
+
+type PreWarmShardResult struct {
+	ShardID  storage.ShardID `json:"shardID"`
+	NodeName string          `json:"nodeName"`
+	// Dispatched is false if the hint fails to be sent to the node, which is tolerated because the hint is best-effort.
+	Dispatched bool `json:"dispatched"`
+}
+
+type ShardPermutation struct {
+	Enabled bool `json:"enabled"`
+}
+
+type UpdateShardPermutationRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type FlowLimiterResult struct {
+	config.LimiterConfig
+	// AdaptiveMultiplier scales the configured limit into the effective one, and it is less than one when the limit is reduced due to
+	// the slow backend.
+	AdaptiveMultiplier float64 `json:"adaptiveMultiplier"`
+}
+
+type ServerStatusResult struct {
+	Status  string `json:"status"`
+	Code    int32  `json:"code"`
+	Healthy bool   `json:"healthy"`
+}
+
+type ResetServerStatusResult struct {
+	// Reset is false if the server is healthy already and nothing is changed.
+	Reset          bool   `json:"reset"`
+	PreviousStatus string `json:"previousStatus"`
+	Status         string `json:"status"`
+}
+
 type UpdateFlowLimiterRequest struct {
-	Enable bool `json:"enable"`
-	Limit  int  `json:"limit"`
-	Burst  int  `json:"burst"`
+	// Enable, Limit and Burst are kept unchanged if they are omitted.
+	Enable *bool `json:"enable"`
+	Limit  *int  `json:"limit"`
+	Burst  *int  `json:"burst"`
+	// The costs of the operations are kept unchanged if they are omitted, and the specified ones must be positive.
+	CreateTableCost          *int `json:"createTableCost"`
+	CreatePartitionTableCost *int `json:"createPartitionTableCost"`
+	DropTableCost            *int `json:"dropTableCost"`
+	RouteTablesCost          *int `json:"routeTablesCost"`
+	// Expected is optional, and the update is rejected if the current config differs from it, which prevents clobbering the concurrent updates.
+	Expected *ExpectedFlowLimiter `json:"expected"`
+}
+
+// ExpectedFlowLimiter is the flow limiter config expected by the update, and only the specified fields are compared.
+type ExpectedFlowLimiter struct {
+	Enable                   *bool `json:"enable"`
+	Limit                    *int  `json:"limit"`
+	Burst                    *int  `json:"burst"`
+	CreateTableCost          *int  `json:"createTableCost"`
+	CreatePartitionTableCost *int  `json:"createPartitionTableCost"`
+	DropTableCost            *int  `json:"dropTableCost"`
+	RouteTablesCost          *int  `json:"routeTablesCost"`
+}
+
+// matches returns true if the specified fields equal to the ones of the config.
+func (e ExpectedFlowLimiter) matches(limiterConfig config.LimiterConfig) bool {
+	return fieldMatches(e.Enable, limiterConfig.Enable) &&
+		fieldMatches(e.Limit, limiterConfig.Limit) &&
+		fieldMatches(e.Burst, limiterConfig.Burst) &&
+		fieldMatches(e.CreateTableCost, limiterConfig.CreateTableCost) &&
+		fieldMatches(e.CreatePartitionTableCost, limiterConfig.CreatePartitionTableCost) &&
+		fieldMatches(e.DropTableCost, limiterConfig.DropTableCost) &&
+		fieldMatches(e.RouteTablesCost, limiterConfig.RouteTablesCost)
+}
+
+type ScanLimitResult struct {
+	MinScanLimit int `json:"minScanLimit"`
+	MaxScanLimit int `json:"maxScanLimit"`
+}
+
+// UpdateScanLimitRequest updates the max scan limit only, and the min scan limit is configured as its lower bound.
+type UpdateScanLimitRequest struct {
+	MaxScanLimit int `json:"maxScanLimit"`
 }
 
 type UpdateEnableScheduleRequest struct {
 	Enable bool `json:"enable"`
+	// ExpectedEnable is optional, and the update is applied only if the current enableSchedule equals to it when provided.
+	ExpectedEnable *bool `json:"expectedEnable,omitempty"`
+}
+
+type SchemaTableCount struct {
+	SchemaName string `json:"schemaName"`
+	TableCount int    `json:"tableCount"`
+}
+
+type SchemaShardSpreadResult struct {
+	SchemaName string `json:"schemaName"`
+	TableCount int    `json:"tableCount"`
+	// ShardCount is the number of the distinct shards hosting the tables of the schema.
+	ShardCount int                     `json:"shardCount"`
+	Shards     []SchemaShardTableCount `json:"shards"`
+	// PartitionTableCount is the number of the partition tables, which are not on any shard while their sub tables are.
+	PartitionTableCount  int `json:"partitionTableCount"`
+	UnassignedTableCount int `json:"unassignedTableCount"`
+}
+
+type SchemaShardTableCount struct {
+	ShardID    storage.ShardID `json:"shardID"`
+	TableCount int             `json:"tableCount"`
+}
+
+type OrphanTable struct {
+	SchemaName string          `json:"schemaName"`
+	TableName  string          `json:"tableName"`
+	TableID    storage.TableID `json:"tableID"`
+	// CreatedAt is the creation time of the table in milliseconds.
+	CreatedAt uint64 `json:"createdAt"`
+}
+
+type OrphanTablesResult struct {
+	Tables []OrphanTable `json:"tables"`
+}
+
+type DropOrphanTablesRequest struct {
+	// MinAgeSec is the minimum age of the orphan tables to be dropped, so that the tables still in creation are not dropped. It is
+	// required and must not be less than metadata.MinOrphanTableAge.
+	MinAgeSec int64 `json:"minAgeSec"`
+	// DryRun only lists the orphan tables to be dropped without dropping them.
+	DryRun bool `json:"dryRun"`
+}
+
+type DropOrphanTablesResult struct {
+	DryRun  bool          `json:"dryRun"`
+	Dropped []OrphanTable `json:"dropped"`
+}
+
+type ValidateShardAffinitiesResult struct {
+	Valid    bool                        `json:"valid"`
+	Problems []scheduler.AffinityProblem `json:"problems"`
+}
+
+// ClusterShardAffinityRules is the shard affinity rules of a cluster, and Error is set instead if they can't be listed.
+type ClusterShardAffinityRules struct {
+	ClusterName string                                 `json:"clusterName"`
+	Rules       map[string]scheduler.ShardAffinityRule `json:"rules"`
+	Error       string                                 `json:"error,omitempty"`
+}
+
+type ClearTableAssignmentRequest struct {
+	SchemaName string `json:"schemaName"`
+	TableName  string `json:"tableName"`
+	// ShardID must be the shard currently assigned to the table.
+	ShardID storage.ShardID `json:"shardID"`
 }
 
 type RemoveShardAffinitiesRequest struct {
 	ShardIDs []storage.ShardID `json:"shardIDs"`
 }
+
+type SetShardsMaintenanceRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+	Reason   string            `json:"reason"`
+}
+
+type ClearShardsMaintenanceRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+}
+
+type SetShardsMinNodeVersionRequest struct {
+	ShardIDs       []storage.ShardID `json:"shardIDs"`
+	MinNodeVersion string            `json:"minNodeVersion"`
+}
+
+type ClearShardsMinNodeVersionRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+}
+
+type SetPreferredLeaderRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+	NodeName string            `json:"nodeName"`
+}
+
+type ClearPreferredLeaderRequest struct {
+	ShardIDs []storage.ShardID `json:"shardIDs"`
+}
+
+type CreateSchemaRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateSchemaResult struct {
+	SchemaID storage.SchemaID `json:"schemaID"`
+	// Created is false if the schema already exists.
+	Created bool `json:"created"`
+}
+
+type CloseTableOnShardRequest struct {
+	SchemaName string          `json:"schemaName"`
+	Table      string          `json:"table"`
+	ShardID    storage.ShardID `json:"shardID"`
+}
+
+type PurgeProceduresRequest struct {
+	// RetentionSec is the minimum age of the finished procedures to be purged.
+	RetentionSec int64 `json:"retentionSec"`
+	// DryRun only counts the procedures to be purged without deleting them.
+	DryRun bool `json:"dryRun"`
+}
+
+type CancelProceduresRequest struct {
+	// Kind is the name of the kind of the procedures to be cancelled, e.g. `split`.
+	Kind string `json:"kind"`
+}
+
+type UpdateFaultsRequest struct {
+	// Faults replaces all the injected faults, and empty faults means to clear them.
+	Faults []procedure.Fault `json:"faults"`
+}
@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package http
+
+import (
+	"fmt"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+)
+
+// The validations below collect all the failures of the request instead of stopping at the first one, so that the client can fix them at once.
+
+func validateCreateClusterRequest(req CreateClusterRequest) (storage.TopologyType, []string) {
+	var errs []string
+	if len(req.Name) == 0 {
+		errs = append(errs, "Name could not be empty")
+	}
+	if req.NodeCount == 0 {
+		errs = append(errs, "expect positive NodeCount")
+	}
+	if req.ShardTotal == 0 {
+		errs = append(errs, "expect positive ShardTotal")
+	}
+	if req.ProcedureExecutingBatchSize == 0 {
+		errs = append(errs, "expect positive procedureExecutingBatchSize")
+	}
+	topologyType, err := metadata.ParseTopologyType(req.TopologyType)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("invalid topologyType:%s", req.TopologyType))
+	}
+	if err := metadata.ValidateShardIDs(req.ShardIDs, req.ShardTotal); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return topologyType, errs
+}
+
+func validateUpdateClusterRequest(req UpdateClusterRequest) (storage.TopologyType, []string) {
+	var errs []string
+	if req.ProcedureExecutingBatchSize == 0 {
+		errs = append(errs, "expect positive procedureExecutingBatchSize")
+	}
+	topologyType, err := metadata.ParseTopologyType(req.TopologyType)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("invalid topologyType:%s", req.TopologyType))
+	}
+	return topologyType, errs
+}
+
+// validateShardAffinities checks the shards of the affinities to add exist in the snapshot and are not duplicated.
+func validateShardAffinities(snapshot metadata.Snapshot, affinities []scheduler.ShardAffinity) []string {
+	if len(affinities) == 0 {
+		return []string{"affinities could not be empty"}
+	}
+
+	var errs []string
+	seen := make(map[storage.ShardID]struct{}, len(affinities))
+	for i, affinity := range affinities {
+		if _, ok := snapshot.Topology.ShardViewsMapping[affinity.ShardID]; !ok {
+			errs = append(errs, fmt.Sprintf("affinities[%d]: shard not found, shardID:%d", i, affinity.ShardID))
+		}
+		if _, ok := seen[affinity.ShardID]; ok {
+			errs = append(errs, fmt.Sprintf("affinities[%d]: duplicated shardID:%d", i, affinity.ShardID))
+		}
+		seen[affinity.ShardID] = struct{}{}
+	}
+	return errs
+}
@@ -27,8 +27,26 @@ const (
 	StatusWaiting Status = iota
 	StatusRunning
 	Terminated
+	// StatusDegraded is the unhealthy status of the started server after a transient issue, which can be reset to running once
+	// the readiness checks pass again.
+	StatusDegraded
 )
 
+func (s Status) String() string {
+	switch s {
+	case StatusWaiting:
+		return "waiting"
+	case StatusRunning:
+		return "running"
+	case Terminated:
+		return "terminated"
+	case StatusDegraded:
+		return "degraded"
+	default:
+		return "unknown"
+	}
+}
+
 type ServerStatus struct {
 	status Status
 }
@@ -43,6 +61,11 @@ func (s *ServerStatus) Set(status Status) {
 	atomic.StoreInt32((*int32)(&s.status), int32(status))
 }
 
+// CompareAndSet sets the status only if the current one is old, and returns whether it is set.
+func (s *ServerStatus) CompareAndSet(old, status Status) bool {
+	return atomic.CompareAndSwapInt32((*int32)(&s.status), int32(old), int32(status))
+}
+
 func (s *ServerStatus) Get() Status {
 	return Status(atomic.LoadInt32((*int32)(&s.status)))
 }
@@ -34,4 +34,11 @@ var (
 	ErrDeleteTableAgain          = coderr.NewCodeError(coderr.Internal, "storage delete table")
 	ErrCreateShardViewAgain      = coderr.NewCodeError(coderr.Internal, "storage create shard view")
 	ErrUpdateShardViewConflict   = coderr.NewCodeError(coderr.Internal, "storage update shard view")
+	ErrInvalidScanLimit          = coderr.NewCodeError(coderr.InvalidParams, "storage invalid scan limit")
+
+	ErrUpdateShardLeaderHistoryConflict = coderr.NewCodeError(coderr.Internal, "storage update shard leader history")
+	ErrDuplicateClusterKeyPrefix        = coderr.NewCodeError(coderr.InvalidParams, "storage duplicate cluster key prefix")
+	ErrParseClusterKeyPrefix            = coderr.NewCodeError(coderr.InvalidParams, "storage parse cluster key prefix")
+	ErrCreateTableIDRangeAgain          = coderr.NewCodeError(coderr.Internal, "storage create table id range")
+	ErrDeleteTableIDRangeAgain          = coderr.NewCodeError(coderr.Internal, "storage delete table id range")
 )
@@ -37,6 +37,11 @@ const (
 	latestVersion = "latest_version"
 	info          = "info"
 	tableAssign   = "table_assign"
+	leaderHistory = "shard_leader_history"
+	tableIDRange  = "table_id_range"
+	settings      = "settings"
+	scanLimit     = "scan_limit"
+	tenant        = "tenant"
 )
 
 // makeSchemaKey returns the key path to the schema meta info.
@@ -112,6 +117,42 @@ func makeNodeKey(rootPath string, clusterID uint32, nodeName string) string {
 	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), node, nodeName)
 }
 
+// makeShardLeaderHistoryKey returns the leader history key path of the shard.
+func makeShardLeaderHistoryKey(rootPath string, clusterID uint32, shardID uint32) string {
+	// Example:
+	//	v1/cluster/1/shard_leader_history/1 -> json encoded []ShardLeaderChange
+	//	v1/cluster/1/shard_leader_history/2 -> json encoded []ShardLeaderChange
+	//	v1/cluster/1/shard_leader_history/3 -> json encoded []ShardLeaderChange
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), leaderHistory, fmtID(uint64(shardID)))
+}
+
+// makeTableIDRangeKey returns the key path to the table id range of the schema.
+func makeTableIDRangeKey(rootPath string, clusterID uint32, schemaName string) string {
+	// Example:
+	//	v1/cluster/1/table_id_range/schema1 -> json encoded TableIDRange
+	//	v1/cluster/1/table_id_range/schema2 -> json encoded TableIDRange
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), tableIDRange, schemaName)
+}
+
+// makeTableIDRangePrefixKey returns the prefix key path of the table id ranges of the cluster.
+func makeTableIDRangePrefixKey(rootPath string, clusterID uint32) string {
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), tableIDRange) + "/"
+}
+
+// makeClusterSettingsKey returns the key path to the runtime settings of the cluster.
+func makeClusterSettingsKey(rootPath string, clusterID uint32) string {
+	// Example:
+	//	v1/cluster/1/settings -> json encoded ClusterSettings
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), settings)
+}
+
+// makeScanLimitKey returns the key path to the runtime scan limit, which is shared by all the clusters.
+func makeScanLimitKey(rootPath string) string {
+	// Example:
+	//	v1/scan_limit -> json encoded ScanLimitSettings
+	return path.Join(rootPath, version, scanLimit)
+}
+
 // makeTableKey returns the table meta info key path.
 func makeTableKey(rootPath string, clusterID uint32, schemaID uint32, tableID uint64) string {
 	// Example:
@@ -142,6 +183,45 @@ func makeTableAssignPrefixKey(rootPath string, clusterID uint32, schemaID uint32
 	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), schema, fmtID(uint64(schemaID)), tableAssign)
 }
 
+// ClusterRootPath returns the root path of the keys scoped to the cluster, which is isolated in the tenant path if the key prefix is given.
+func ClusterRootPath(rootPath, keyPrefix string) string {
+	if len(keyPrefix) == 0 {
+		return rootPath
+	}
+	// Example:
+	//	{rootPath}/tenant/{keyPrefix}/v1/cluster/1/schema/info/1 -> pb.Schema
+	return path.Join(rootPath, tenant, keyPrefix)
+}
+
+// ParseClusterKeyPrefixes parses the key prefixes of the clusters formatted as `clusterName=keyPrefix`, and the key prefixes must be
+// unique so that the keys of different clusters never collide.
+func ParseClusterKeyPrefixes(rawPrefixes []string) (map[string]string, error) {
+	prefixes := make(map[string]string, len(rawPrefixes))
+	clusterNames := make(map[string]string, len(rawPrefixes))
+	for _, rawPrefix := range rawPrefixes {
+		rawName, rawKeyPrefix, ok := strings.Cut(rawPrefix, "=")
+		if !ok {
+			return nil, ErrParseClusterKeyPrefix.WithCausef("key prefix must be formatted as clusterName=keyPrefix, keyPrefix:%s", rawPrefix)
+		}
+		clusterName, keyPrefix := strings.TrimSpace(rawName), strings.TrimSpace(rawKeyPrefix)
+		if len(clusterName) == 0 {
+			return nil, ErrParseClusterKeyPrefix.WithCausef("cluster name is empty, keyPrefix:%s", rawPrefix)
+		}
+		if len(keyPrefix) == 0 || strings.Contains(keyPrefix, "/") || keyPrefix == "." || keyPrefix == ".." {
+			return nil, ErrParseClusterKeyPrefix.WithCausef("key prefix must be a non-empty path segment, keyPrefix:%s", rawPrefix)
+		}
+		if _, ok := prefixes[clusterName]; ok {
+			return nil, ErrParseClusterKeyPrefix.WithCausef("duplicate cluster name, clusterName:%s", clusterName)
+		}
+		if otherName, ok := clusterNames[keyPrefix]; ok {
+			return nil, ErrDuplicateClusterKeyPrefix.WithCausef("key prefix:%s, clusterName:%s, conflicting clusterName:%s", keyPrefix, clusterName, otherName)
+		}
+		prefixes[clusterName] = keyPrefix
+		clusterNames[keyPrefix] = clusterName
+	}
+	return prefixes, nil
+}
+
 func fmtID(id uint64) string {
 	return fmt.Sprintf("%020d", id)
 }
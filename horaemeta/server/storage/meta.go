@@ -75,6 +75,37 @@ type Storage interface {
 	ListNodes(ctx context.Context, req ListNodesRequest) (ListNodesResult, error)
 	// CreateOrUpdateNode create or update node in specified cluster.
 	CreateOrUpdateNode(ctx context.Context, req CreateOrUpdateNodeRequest) error
+	// DeleteNode delete node in specified cluster.
+	DeleteNode(ctx context.Context, req DeleteNodeRequest) error
+
+	// AppendShardLeaderChange appends the change to the bounded leader history of the shard.
+	AppendShardLeaderChange(ctx context.Context, req AppendShardLeaderChangeRequest) error
+	// ListShardLeaderChanges lists the leader history of the shard.
+	ListShardLeaderChanges(ctx context.Context, req ListShardLeaderChangesRequest) (ListShardLeaderChangesResult, error)
+
+	// ListTableIDRanges lists the table id ranges of the schemas in specified cluster.
+	ListTableIDRanges(ctx context.Context, req ListTableIDRangesRequest) (ListTableIDRangesResult, error)
+	// CreateTableIDRange create the table id range of the schema, return error if the range of the schema already exists.
+	CreateTableIDRange(ctx context.Context, req CreateTableIDRangeRequest) error
+	// DeleteTableIDRange delete the table id range of the schema, return error if the range of the schema doesn't exist.
+	DeleteTableIDRange(ctx context.Context, req DeleteTableIDRangeRequest) error
+
+	// GetClusterSettings get the runtime settings of the cluster, the zero settings are returned if they have never been put.
+	GetClusterSettings(ctx context.Context, req GetClusterSettingsRequest) (GetClusterSettingsResult, error)
+	// PutClusterSettings put the runtime settings of the cluster as a whole.
+	PutClusterSettings(ctx context.Context, req PutClusterSettingsRequest) error
+
+	// SetClusterKeyPrefix isolates the keys scoped to the cluster under the key prefix, which must be unique among the clusters.
+	// The keys of the cluster are placed under the root path if the key prefix is empty.
+	SetClusterKeyPrefix(clusterID ClusterID, keyPrefix string) error
+
+	// GetScanLimit get the limits of the number of keys in a scan.
+	GetScanLimit() ScanLimit
+	// LoadScanLimit applies the max scan limit persisted by UpdateMaxScanLimit, and the configured one is kept if it has never been updated.
+	LoadScanLimit(ctx context.Context) error
+	// UpdateMaxScanLimit persists the max limit of the number of keys, which will be applied to the subsequent scans.
+	// The min scan limit is configured only, and the max limit must not be less than it.
+	UpdateMaxScanLimit(ctx context.Context, maxScanLimit int) error
 }
 
 // NewStorageWithEtcdBackend creates a new storage with etcd backend.
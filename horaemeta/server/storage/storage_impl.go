@@ -21,9 +21,12 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
@@ -35,14 +38,41 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-type Options struct {
+// maxShardLeaderHistoryAppendAttempts bounds the attempts of appending a change to the shard leader history on conflicts.
+const maxShardLeaderHistoryAppendAttempts = 5
 
+type Options struct {
 	// MaxScanLimit is the max limit of the number of keys in a scan.
 	MaxScanLimit int
 	// MinScanLimit is the min limit of the number of keys in a scan.
 	MinScanLimit int
 	// MaxOpsPerTxn is th max number of the operations allowed in a txn.
 	MaxOpsPerTxn int
+	// ReadTimeout bounds a single get or scan, zero means no timeout other than the caller's.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds a single put, delete or txn, zero means no timeout other than the caller's.
+	WriteTimeout time.Duration
+}
+
+// ScanLimit is the limits of the number of keys in a scan.
+type ScanLimit struct {
+	Min int
+	Max int
+}
+
+// Validate checks the limits, the max limit is used as the batch size of the scan, so it must be greater than 1 and not less than
+// the min limit.
+func (l ScanLimit) Validate() error {
+	if l.Min < 1 {
+		return ErrInvalidScanLimit.WithCausef("min scan limit must be positive, min:%d", l.Min)
+	}
+	if l.Max <= 1 {
+		return ErrInvalidScanLimit.WithCausef("max scan limit must be greater than 1, max:%d", l.Max)
+	}
+	if l.Min > l.Max {
+		return ErrInvalidScanLimit.WithCausef("min scan limit must not be greater than max scan limit, min:%d, max:%d", l.Min, l.Max)
+	}
+	return nil
 }
 
 // metaStorageImpl is the base underlying storage endpoint for all other upper
@@ -51,17 +81,142 @@ type Options struct {
 type metaStorageImpl struct {
 	client *clientv3.Client
 
-	opts Options
+	// scanLimitLock is used to protect the scan limits in the opts, which can be updated at runtime.
+	scanLimitLock sync.RWMutex
+	opts          Options
 
 	rootPath string
+
+	// clusterKeyPrefixLock is used to protect the key prefixes of the clusters, which are registered when the clusters are loaded.
+	clusterKeyPrefixLock sync.RWMutex
+	clusterKeyPrefixes   map[ClusterID]string
 }
 
 // newEtcdBackend is used to create a new etcd backend.
 func newEtcdStorage(client *clientv3.Client, rootPath string, opts Options) Storage {
-	return &metaStorageImpl{client, opts, rootPath}
+	return &metaStorageImpl{
+		client:        client,
+		scanLimitLock: sync.RWMutex{},
+		opts:          opts,
+		rootPath:      rootPath,
+
+		clusterKeyPrefixLock: sync.RWMutex{},
+		clusterKeyPrefixes:   map[ClusterID]string{},
+	}
+}
+
+func (s *metaStorageImpl) SetClusterKeyPrefix(clusterID ClusterID, keyPrefix string) error {
+	s.clusterKeyPrefixLock.Lock()
+	defer s.clusterKeyPrefixLock.Unlock()
+
+	if keyPrefix == "" {
+		delete(s.clusterKeyPrefixes, clusterID)
+		return nil
+	}
+
+	for id, prefix := range s.clusterKeyPrefixes {
+		if id != clusterID && prefix == keyPrefix {
+			return ErrDuplicateClusterKeyPrefix.WithCausef("key prefix:%s, clusterID:%d, conflicting clusterID:%d", keyPrefix, clusterID, id)
+		}
+	}
+	s.clusterKeyPrefixes[clusterID] = keyPrefix
+	return nil
+}
+
+// clusterRootPath returns the root path of the keys scoped to the cluster.
+func (s *metaStorageImpl) clusterRootPath(clusterID ClusterID) string {
+	s.clusterKeyPrefixLock.RLock()
+	defer s.clusterKeyPrefixLock.RUnlock()
+
+	return ClusterRootPath(s.rootPath, s.clusterKeyPrefixes[clusterID])
+}
+
+// withReadTimeout derives the context of a read operation from the caller's context.
+func (s *metaStorageImpl) withReadTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.opts.ReadTimeout)
+}
+
+// withWriteTimeout derives the context of a write operation from the caller's context.
+func (s *metaStorageImpl) withWriteTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.opts.WriteTimeout)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (s *metaStorageImpl) GetScanLimit() ScanLimit {
+	s.scanLimitLock.RLock()
+	defer s.scanLimitLock.RUnlock()
+
+	return ScanLimit{
+		Min: s.opts.MinScanLimit,
+		Max: s.opts.MaxScanLimit,
+	}
+}
+
+func (s *metaStorageImpl) LoadScanLimit(ctx context.Context) error {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	key := makeScanLimitKey(s.rootPath)
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return errors.WithMessagef(err, "get scan limit, key:%s", key)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil
+	}
+	var settings ScanLimitSettings
+	if err := json.Unmarshal(resp.Kvs[0].Value, &settings); err != nil {
+		return ErrDecode.WithCausef("decode scan limit, err:%v", err)
+	}
+
+	s.scanLimitLock.Lock()
+	defer s.scanLimitLock.Unlock()
+
+	limit := ScanLimit{Min: s.opts.MinScanLimit, Max: settings.MaxScanLimit}
+	if err := limit.Validate(); err != nil {
+		// The configured min scan limit may be raised after the max one is persisted, and the configured max one is kept then.
+		log.Warn("ignore the persisted max scan limit", zap.Int("maxScanLimit", settings.MaxScanLimit), zap.Error(err))
+		return nil
+	}
+	s.opts.MaxScanLimit = limit.Max
+	return nil
+}
+
+func (s *metaStorageImpl) UpdateMaxScanLimit(ctx context.Context, maxScanLimit int) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	s.scanLimitLock.Lock()
+	defer s.scanLimitLock.Unlock()
+
+	limit := ScanLimit{Min: s.opts.MinScanLimit, Max: maxScanLimit}
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(ScanLimitSettings{MaxScanLimit: maxScanLimit})
+	if err != nil {
+		return ErrEncode.WithCausef("encode scan limit, err:%v", err)
+	}
+	key := makeScanLimitKey(s.rootPath)
+	if _, err := s.client.Put(ctx, key, string(value)); err != nil {
+		return errors.WithMessagef(err, "put scan limit, key:%s", key)
+	}
+
+	s.opts.MaxScanLimit = limit.Max
+	return nil
 }
 
 func (s *metaStorageImpl) GetCluster(ctx context.Context, clusterID ClusterID) (Cluster, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
 	clusterKey := makeClusterKey(s.rootPath, uint32(clusterID))
 
 	var cluster Cluster
@@ -80,9 +235,12 @@ func (s *metaStorageImpl) GetCluster(ctx context.Context, clusterID ClusterID) (
 }
 
 func (s *metaStorageImpl) ListClusters(ctx context.Context) (ListClustersResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
 	startKey := makeClusterKey(s.rootPath, 0)
 	endKey := makeClusterKey(s.rootPath, math.MaxUint32)
-	rangeLimit := s.opts.MaxScanLimit
+	rangeLimit := s.GetScanLimit().Max
 
 	var clusters []Cluster
 	do := func(key string, value []byte) error {
@@ -107,6 +265,9 @@ func (s *metaStorageImpl) ListClusters(ctx context.Context) (ListClustersResult,
 
 // CreateCluster return error if the cluster already exists.
 func (s *metaStorageImpl) CreateCluster(ctx context.Context, req CreateClusterRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	c := convertClusterToPB(req.Cluster)
 	value, err := proto.Marshal(&c)
 	if err != nil {
@@ -134,6 +295,9 @@ func (s *metaStorageImpl) CreateCluster(ctx context.Context, req CreateClusterRe
 
 // UpdateCluster return an error if the cluster does not exist.
 func (s *metaStorageImpl) UpdateCluster(ctx context.Context, req UpdateClusterRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	c := convertClusterToPB(req.Cluster)
 	value, err := proto.Marshal(&c)
 	if err != nil {
@@ -160,14 +324,17 @@ func (s *metaStorageImpl) UpdateCluster(ctx context.Context, req UpdateClusterRe
 
 // CreateClusterView return error if the cluster view already exists.
 func (s *metaStorageImpl) CreateClusterView(ctx context.Context, req CreateClusterViewRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	clusterViewPB := convertClusterViewToPB(req.ClusterView)
 	value, err := proto.Marshal(&clusterViewPB)
 	if err != nil {
 		return ErrEncode.WithCausef("encode cluster view, clusterID:%d, err:%v", clusterViewPB.ClusterId, err)
 	}
 
-	key := makeClusterViewKey(s.rootPath, clusterViewPB.ClusterId, fmtID(clusterViewPB.Version))
-	latestVersionKey := makeClusterViewLatestVersionKey(s.rootPath, clusterViewPB.ClusterId)
+	key := makeClusterViewKey(s.clusterRootPath(ClusterID(clusterViewPB.ClusterId)), clusterViewPB.ClusterId, fmtID(clusterViewPB.Version))
+	latestVersionKey := makeClusterViewLatestVersionKey(s.clusterRootPath(ClusterID(clusterViewPB.ClusterId)), clusterViewPB.ClusterId)
 
 	// Check if the key and latest version key exists, if not，create cluster view and latest version; Otherwise, the cluster view already exists and return an error.
 	latestVersionKeyMissing := clientv3util.KeyMissing(latestVersionKey)
@@ -189,14 +356,17 @@ func (s *metaStorageImpl) CreateClusterView(ctx context.Context, req CreateClust
 }
 
 func (s *metaStorageImpl) GetClusterView(ctx context.Context, req GetClusterViewRequest) (GetClusterViewResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
 	var viewRes GetClusterViewResult
-	key := makeClusterViewLatestVersionKey(s.rootPath, uint32(req.ClusterID))
+	key := makeClusterViewLatestVersionKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
 	version, err := etcdutil.Get(ctx, s.client, key)
 	if err != nil {
 		return viewRes, errors.WithMessagef(err, "get cluster view latest version, clusterID:%d, key:%s", req.ClusterID, key)
 	}
 
-	key = makeClusterViewKey(s.rootPath, uint32(req.ClusterID), version)
+	key = makeClusterViewKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), version)
 	value, err := etcdutil.Get(ctx, s.client, key)
 	if err != nil {
 		return viewRes, errors.WithMessagef(err, "get cluster view, clusterID:%d, key:%s", req.ClusterID, key)
@@ -214,6 +384,9 @@ func (s *metaStorageImpl) GetClusterView(ctx context.Context, req GetClusterView
 }
 
 func (s *metaStorageImpl) UpdateClusterView(ctx context.Context, req UpdateClusterViewRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	clusterViewPB := convertClusterViewToPB(req.ClusterView)
 
 	value, err := proto.Marshal(&clusterViewPB)
@@ -221,8 +394,8 @@ func (s *metaStorageImpl) UpdateClusterView(ctx context.Context, req UpdateClust
 		return ErrEncode.WithCausef("encode cluster view, clusterID:%d, err:%v", req.ClusterID, err)
 	}
 
-	key := makeClusterViewKey(s.rootPath, uint32(req.ClusterID), fmtID(clusterViewPB.Version))
-	latestVersionKey := makeClusterViewLatestVersionKey(s.rootPath, uint32(req.ClusterID))
+	key := makeClusterViewKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), fmtID(clusterViewPB.Version))
+	latestVersionKey := makeClusterViewLatestVersionKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
 
 	// Check whether the latest version is equal to that in etcd. If it is equal，update cluster view and latest version; Otherwise, return an error.
 	latestVersionEquals := clientv3.Compare(clientv3.Value(latestVersionKey), "=", fmtID(req.LatestVersion))
@@ -244,9 +417,12 @@ func (s *metaStorageImpl) UpdateClusterView(ctx context.Context, req UpdateClust
 }
 
 func (s *metaStorageImpl) ListSchemas(ctx context.Context, req ListSchemasRequest) (ListSchemasResult, error) {
-	startKey := makeSchemaKey(s.rootPath, uint32(req.ClusterID), 0)
-	endKey := makeSchemaKey(s.rootPath, uint32(req.ClusterID), math.MaxUint32)
-	rangeLimit := s.opts.MaxScanLimit
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	startKey := makeSchemaKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), 0)
+	endKey := makeSchemaKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), math.MaxUint32)
+	rangeLimit := s.GetScanLimit().Max
 
 	var schemas []Schema
 	do := func(key string, value []byte) error {
@@ -269,13 +445,16 @@ func (s *metaStorageImpl) ListSchemas(ctx context.Context, req ListSchemasReques
 
 // CreateSchema return error if the schema already exists.
 func (s *metaStorageImpl) CreateSchema(ctx context.Context, req CreateSchemaRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	schema := convertSchemaToPB(req.Schema)
 	value, err := proto.Marshal(&schema)
 	if err != nil {
 		return ErrDecode.WithCausef("encode schema, clusterID:%d, schemaID:%d, err:%v", req.ClusterID, schema.Id, err)
 	}
 
-	key := makeSchemaKey(s.rootPath, uint32(req.ClusterID), schema.Id)
+	key := makeSchemaKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), schema.Id)
 
 	// Check if the key exists, if not，create schema; Otherwise, the schema already exists and return an error.
 	keyMissing := clientv3util.KeyMissing(key)
@@ -296,14 +475,17 @@ func (s *metaStorageImpl) CreateSchema(ctx context.Context, req CreateSchemaRequ
 
 // CreateTable return error if the table already exists.
 func (s *metaStorageImpl) CreateTable(ctx context.Context, req CreateTableRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	table := convertTableToPB(req.Table)
 	value, err := proto.Marshal(&table)
 	if err != nil {
 		return ErrEncode.WithCausef("encode table, clusterID:%d, schemaID:%d, tableID:%d, err:%v", req.ClusterID, req.Table.ID, table.Id, err)
 	}
 
-	key := makeTableKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), table.Id)
-	nameToIDKey := makeNameToIDKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), table.Name)
+	key := makeTableKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), table.Id)
+	nameToIDKey := makeNameToIDKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), table.Name)
 
 	// Check if the key and the name to id key exists, if not，create table; Otherwise, the table already exists and return an error.
 	idKeyMissing := clientv3util.KeyMissing(key)
@@ -325,8 +507,11 @@ func (s *metaStorageImpl) CreateTable(ctx context.Context, req CreateTableReques
 }
 
 func (s *metaStorageImpl) GetTable(ctx context.Context, req GetTableRequest) (GetTableResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
 	var res GetTableResult
-	value, err := etcdutil.Get(ctx, s.client, makeNameToIDKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), req.TableName))
+	value, err := etcdutil.Get(ctx, s.client, makeNameToIDKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), req.TableName))
 	if err == etcdutil.ErrEtcdKVGetNotFound {
 		res.Exists = false
 		return res, nil
@@ -340,7 +525,7 @@ func (s *metaStorageImpl) GetTable(ctx context.Context, req GetTableRequest) (Ge
 		return res, errors.WithMessagef(err, "string to int failed")
 	}
 
-	key := makeTableKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), tableID)
+	key := makeTableKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), tableID)
 	value, err = etcdutil.Get(ctx, s.client, key)
 	if err != nil {
 		return res, errors.WithMessagef(err, "get table, clusterID:%d, schemaID:%d, tableID:%d, key:%s", req.ClusterID, req.SchemaID, tableID, key)
@@ -359,9 +544,12 @@ func (s *metaStorageImpl) GetTable(ctx context.Context, req GetTableRequest) (Ge
 }
 
 func (s *metaStorageImpl) ListTables(ctx context.Context, req ListTableRequest) (ListTablesResult, error) {
-	startKey := makeTableKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), 0)
-	endKey := makeTableKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), math.MaxUint64)
-	rangeLimit := s.opts.MaxScanLimit
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	startKey := makeTableKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), 0)
+	endKey := makeTableKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), math.MaxUint64)
+	rangeLimit := s.GetScanLimit().Max
 
 	var tables []Table
 	do := func(key string, value []byte) error {
@@ -384,7 +572,10 @@ func (s *metaStorageImpl) ListTables(ctx context.Context, req ListTableRequest)
 }
 
 func (s *metaStorageImpl) DeleteTable(ctx context.Context, req DeleteTableRequest) error {
-	nameKey := makeNameToIDKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	nameKey := makeNameToIDKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
 
 	value, err := etcdutil.Get(ctx, s.client, nameKey)
 	if err != nil {
@@ -396,7 +587,7 @@ func (s *metaStorageImpl) DeleteTable(ctx context.Context, req DeleteTableReques
 		return errors.WithMessagef(err, "string to int failed")
 	}
 
-	key := makeTableKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), tableID)
+	key := makeTableKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), tableID)
 
 	nameKeyExists := clientv3util.KeyExists(nameKey)
 	idKeyExists := clientv3util.KeyExists(key)
@@ -419,7 +610,10 @@ func (s *metaStorageImpl) DeleteTable(ctx context.Context, req DeleteTableReques
 }
 
 func (s *metaStorageImpl) AssignTableToShard(ctx context.Context, req AssignTableToShardRequest) error {
-	key := makeTableAssignKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	key := makeTableAssignKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
 
 	// Check if the key exists, if not，save table assign result; Otherwise, the table assign result already exists and return an error.
 	keyMissing := clientv3util.KeyMissing(key)
@@ -440,7 +634,10 @@ func (s *metaStorageImpl) AssignTableToShard(ctx context.Context, req AssignTabl
 }
 
 func (s *metaStorageImpl) DeleteTableAssignedShard(ctx context.Context, req DeleteTableAssignedRequest) error {
-	key := makeTableAssignKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	key := makeTableAssignKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID), req.TableName)
 
 	keyExists := clientv3util.KeyExists(key)
 	opDeleteAssignTable := clientv3.OpDelete(key)
@@ -460,8 +657,11 @@ func (s *metaStorageImpl) DeleteTableAssignedShard(ctx context.Context, req Dele
 }
 
 func (s *metaStorageImpl) ListTableAssignedShard(ctx context.Context, req ListAssignTableRequest) (ListTableAssignedShardResult, error) {
-	key := makeTableAssignPrefixKey(s.rootPath, uint32(req.ClusterID), uint32(req.SchemaID))
-	rangeLimit := s.opts.MaxScanLimit
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	key := makeTableAssignPrefixKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.SchemaID))
+	rangeLimit := s.GetScanLimit().Max
 
 	var tableAssigns []TableAssign
 	do := func(key string, value []byte) error {
@@ -493,8 +693,8 @@ func (s *metaStorageImpl) createNShardViews(ctx context.Context, clusterID Clust
 			return ErrEncode.WithCausef("encode shard clusterView, clusterID:%d, shardID:%d, err:%v", clusterID, shardView.ShardID, err)
 		}
 
-		key := makeShardViewKey(s.rootPath, uint32(clusterID), uint32(shardView.ShardID), fmtID(shardView.Version))
-		latestVersionKey := makeShardViewLatestVersionKey(s.rootPath, uint32(clusterID), uint32(shardView.ShardID))
+		key := makeShardViewKey(s.clusterRootPath(clusterID), uint32(clusterID), uint32(shardView.ShardID), fmtID(shardView.Version))
+		latestVersionKey := makeShardViewLatestVersionKey(s.clusterRootPath(clusterID), uint32(clusterID), uint32(shardView.ShardID))
 
 		// Check if the key and latest version key exists, if not，create shard clusterView and latest version; Otherwise, the shard clusterView already exists and return an error.
 		ifConds = append(ifConds, clientv3util.KeyMissing(key), clientv3util.KeyMissing(latestVersionKey))
@@ -525,7 +725,11 @@ func (s *metaStorageImpl) CreateShardViews(ctx context.Context, req CreateShardV
 			end = numShardViews
 		}
 
-		if err := s.createNShardViews(ctx, req.ClusterID, req.ShardViews[start:end], ifConds, opCreates); err != nil {
+		// Every txn is bounded by the write timeout on its own.
+		txnCtx, cancel := s.withWriteTimeout(ctx)
+		err := s.createNShardViews(txnCtx, req.ClusterID, req.ShardViews[start:end], ifConds, opCreates)
+		cancel()
+		if err != nil {
 			return err
 		}
 		ifConds = ifConds[:0]
@@ -536,9 +740,12 @@ func (s *metaStorageImpl) CreateShardViews(ctx context.Context, req CreateShardV
 }
 
 func (s *metaStorageImpl) ListShardViews(ctx context.Context, req ListShardViewsRequest) (ListShardViewsResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
 	var listRes ListShardViewsResult
 	var shardViews []ShardView
-	prefix := makeShardViewVersionKey(s.rootPath, uint32(req.ClusterID))
+	prefix := makeShardViewVersionKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
 	keys, err := etcdutil.List(ctx, s.client, prefix)
 	if err != nil {
 		return listRes, errors.WithMessagef(err, "list shard view, clusterID:%d", req.ClusterID)
@@ -559,7 +766,7 @@ func (s *metaStorageImpl) ListShardViews(ctx context.Context, req ListShardViews
 				return listRes, errors.WithMessagef(err, "list shard view latest version, clusterID:%d, shardID:%d, key:%s", req.ClusterID, shardID, key)
 			}
 
-			key = makeShardViewKey(s.rootPath, uint32(req.ClusterID), uint32(shardID), version)
+			key = makeShardViewKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(shardID), version)
 			value, err := etcdutil.Get(ctx, s.client, key)
 			if err != nil {
 				return listRes, errors.WithMessagef(err, "list shard view, clusterID:%d, shardID:%d, key:%s", req.ClusterID, shardID, key)
@@ -581,15 +788,18 @@ func (s *metaStorageImpl) ListShardViews(ctx context.Context, req ListShardViews
 }
 
 func (s *metaStorageImpl) UpdateShardView(ctx context.Context, req UpdateShardViewRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	shardViewPB := convertShardViewToPB(req.ShardView)
 	value, err := proto.Marshal(&shardViewPB)
 	if err != nil {
 		return ErrEncode.WithCausef("encode shard view, clusterID:%d, shardID:%d, err:%v", req.ClusterID, req.ShardView.ShardID, err)
 	}
 
-	key := makeShardViewKey(s.rootPath, uint32(req.ClusterID), shardViewPB.ShardId, fmtID(shardViewPB.GetVersion()))
-	oldTopologyKey := makeShardViewKey(s.rootPath, uint32(req.ClusterID), shardViewPB.ShardId, fmtID(req.PrevVersion))
-	latestVersionKey := makeShardViewLatestVersionKey(s.rootPath, uint32(req.ClusterID), shardViewPB.ShardId)
+	key := makeShardViewKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), shardViewPB.ShardId, fmtID(shardViewPB.GetVersion()))
+	oldTopologyKey := makeShardViewKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), shardViewPB.ShardId, fmtID(req.PrevVersion))
+	latestVersionKey := makeShardViewLatestVersionKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), shardViewPB.ShardId)
 
 	// Check whether the latest version is equal to that in etcd. If it is equal，update shard clusterView and latest version; Otherwise, return an error.
 	opPutLatestVersion := clientv3.OpPut(latestVersionKey, fmtID(shardViewPB.Version))
@@ -617,9 +827,12 @@ func (s *metaStorageImpl) UpdateShardView(ctx context.Context, req UpdateShardVi
 }
 
 func (s *metaStorageImpl) ListNodes(ctx context.Context, req ListNodesRequest) (ListNodesResult, error) {
-	startKey := makeNodeKey(s.rootPath, uint32(req.ClusterID), string([]byte{0}))
-	endKey := makeNodeKey(s.rootPath, uint32(req.ClusterID), string([]byte{255}))
-	rangeLimit := s.opts.MaxScanLimit
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	startKey := makeNodeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), string([]byte{0}))
+	endKey := makeNodeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), string([]byte{255}))
+	rangeLimit := s.GetScanLimit().Max
 
 	var nodes []Node
 	do := func(key string, value []byte) error {
@@ -643,9 +856,12 @@ func (s *metaStorageImpl) ListNodes(ctx context.Context, req ListNodesRequest) (
 }
 
 func (s *metaStorageImpl) CreateOrUpdateNode(ctx context.Context, req CreateOrUpdateNodeRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
 	nodePB := convertNodeToPB(req.Node)
 
-	key := makeNodeKey(s.rootPath, uint32(req.ClusterID), req.Node.Name)
+	key := makeNodeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), req.Node.Name)
 
 	value, err := proto.Marshal(&nodePB)
 	if err != nil {
@@ -659,3 +875,222 @@ func (s *metaStorageImpl) CreateOrUpdateNode(ctx context.Context, req CreateOrUp
 
 	return nil
 }
+
+// AppendShardLeaderChange appends the change to the leader history of the shard, which is encoded in json as a whole since
+// there is no protobuf message for it. The history is updated in a txn comparing its revision, and the append is retried on
+// conflicts, so the concurrent changes are not lost unless the conflicts persist.
+func (s *metaStorageImpl) AppendShardLeaderChange(ctx context.Context, req AppendShardLeaderChangeRequest) error {
+	key := makeShardLeaderHistoryKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.Change.ShardID))
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := s.withWriteTimeout(ctx)
+		appended, err := s.tryAppendShardLeaderChange(attemptCtx, key, req)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if appended {
+			return nil
+		}
+		if attempt >= maxShardLeaderHistoryAppendAttempts {
+			return ErrUpdateShardLeaderHistoryConflict.WithCausef("clusterID:%d, shardID:%d, key:%s, attempts:%d", req.ClusterID, req.Change.ShardID, key, attempt)
+		}
+		log.Warn("append shard leader change conflicts, retry", zap.Uint32("clusterID", uint32(req.ClusterID)), zap.Uint32("shardID", uint32(req.Change.ShardID)), zap.Int("attempt", attempt))
+	}
+}
+
+// tryAppendShardLeaderChange appends the change to the history read, and false is returned if the history is updated concurrently.
+func (s *metaStorageImpl) tryAppendShardLeaderChange(ctx context.Context, key string, req AppendShardLeaderChangeRequest) (bool, error) {
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return false, errors.WithMessagef(err, "get shard leader history, clusterID:%d, shardID:%d, key:%s", req.ClusterID, req.Change.ShardID, key)
+	}
+	var changes []ShardLeaderChange
+	modRevision := int64(0)
+	if len(resp.Kvs) > 0 {
+		modRevision = resp.Kvs[0].ModRevision
+		if err := json.Unmarshal(resp.Kvs[0].Value, &changes); err != nil {
+			return false, ErrDecode.WithCausef("decode shard leader history, clusterID:%d, shardID:%d, err:%v", req.ClusterID, req.Change.ShardID, err)
+		}
+	}
+
+	changes = append(changes, req.Change)
+	if req.Retention > 0 {
+		changes = trimExpiredShardLeaderChanges(changes, req.Change.Timestamp, req.Retention)
+	}
+	if req.MaxHistoryLen > 0 && len(changes) > req.MaxHistoryLen {
+		changes = changes[len(changes)-req.MaxHistoryLen:]
+	}
+	value, err := json.Marshal(changes)
+	if err != nil {
+		return false, ErrEncode.WithCausef("encode shard leader history, clusterID:%d, shardID:%d, err:%v", req.ClusterID, req.Change.ShardID, err)
+	}
+
+	txnResp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return false, errors.WithMessagef(err, "append shard leader change, clusterID:%d, shardID:%d, key:%s", req.ClusterID, req.Change.ShardID, key)
+	}
+	return txnResp.Succeeded, nil
+}
+
+// trimExpiredShardLeaderChanges removes the changes older than the retention before the nowMs, and the changes are ordered from
+// the oldest to the newest.
+func trimExpiredShardLeaderChanges(changes []ShardLeaderChange, nowMs uint64, retention time.Duration) []ShardLeaderChange {
+	retentionMs := uint64(retention.Milliseconds())
+	if nowMs <= retentionMs {
+		return changes
+	}
+	expiredBefore := nowMs - retentionMs
+	for i, change := range changes {
+		if change.Timestamp >= expiredBefore {
+			return changes[i:]
+		}
+	}
+	return changes[:0]
+}
+
+func (s *metaStorageImpl) ListShardLeaderChanges(ctx context.Context, req ListShardLeaderChangesRequest) (ListShardLeaderChangesResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	var result ListShardLeaderChangesResult
+
+	key := makeShardLeaderHistoryKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), uint32(req.ShardID))
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return result, errors.WithMessagef(err, "get shard leader history, clusterID:%d, shardID:%d, key:%s", req.ClusterID, req.ShardID, key)
+	}
+	if len(resp.Kvs) == 0 {
+		result.Changes = []ShardLeaderChange{}
+		return result, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &result.Changes); err != nil {
+		return result, ErrDecode.WithCausef("decode shard leader history, clusterID:%d, shardID:%d, err:%v", req.ClusterID, req.ShardID, err)
+	}
+
+	return result, nil
+}
+
+func (s *metaStorageImpl) ListTableIDRanges(ctx context.Context, req ListTableIDRangesRequest) (ListTableIDRangesResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	key := makeTableIDRangePrefixKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
+
+	ranges := []TableIDRange{}
+	do := func(key string, value []byte) error {
+		var idRange TableIDRange
+		if err := json.Unmarshal(value, &idRange); err != nil {
+			return ErrDecode.WithCausef("decode table id range, key:%s, clusterID:%d, err:%v", key, req.ClusterID, err)
+		}
+		ranges = append(ranges, idRange)
+		return nil
+	}
+
+	if err := etcdutil.ScanWithPrefix(ctx, s.client, key, do); err != nil {
+		return ListTableIDRangesResult{}, errors.WithMessagef(err, "scan table id ranges, clusterID:%d, prefix key:%s", req.ClusterID, key)
+	}
+
+	return ListTableIDRangesResult{Ranges: ranges}, nil
+}
+
+// CreateTableIDRange return error if the table id range of the schema already exists.
+func (s *metaStorageImpl) CreateTableIDRange(ctx context.Context, req CreateTableIDRangeRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	value, err := json.Marshal(req.Range)
+	if err != nil {
+		return ErrEncode.WithCausef("encode table id range, clusterID:%d, schema:%s, err:%v", req.ClusterID, req.Range.SchemaName, err)
+	}
+
+	key := makeTableIDRangeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), req.Range.SchemaName)
+	resp, err := s.client.Txn(ctx).
+		If(clientv3util.KeyMissing(key)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "create table id range, clusterID:%d, schema:%s, key:%s", req.ClusterID, req.Range.SchemaName, key)
+	}
+	if !resp.Succeeded {
+		return ErrCreateTableIDRangeAgain.WithCausef("table id range may already exist, clusterID:%d, schema:%s, key:%s", req.ClusterID, req.Range.SchemaName, key)
+	}
+
+	return nil
+}
+
+// DeleteTableIDRange return error if the table id range of the schema doesn't exist.
+func (s *metaStorageImpl) DeleteTableIDRange(ctx context.Context, req DeleteTableIDRangeRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	key := makeTableIDRangeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), req.SchemaName)
+	resp, err := s.client.Txn(ctx).
+		If(clientv3util.KeyExists(key)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "delete table id range, clusterID:%d, schema:%s, key:%s", req.ClusterID, req.SchemaName, key)
+	}
+	if !resp.Succeeded {
+		return ErrDeleteTableIDRangeAgain.WithCausef("table id range may have been deleted, clusterID:%d, schema:%s, key:%s", req.ClusterID, req.SchemaName, key)
+	}
+
+	return nil
+}
+
+// GetClusterSettings returns the zero settings if they have never been put, which are encoded in json since there is no protobuf
+// message for them.
+func (s *metaStorageImpl) GetClusterSettings(ctx context.Context, req GetClusterSettingsRequest) (GetClusterSettingsResult, error) {
+	ctx, cancel := s.withReadTimeout(ctx)
+	defer cancel()
+
+	var result GetClusterSettingsResult
+
+	key := makeClusterSettingsKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return result, errors.WithMessagef(err, "get cluster settings, clusterID:%d, key:%s", req.ClusterID, key)
+	}
+	if len(resp.Kvs) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &result.Settings); err != nil {
+		return result, ErrDecode.WithCausef("decode cluster settings, clusterID:%d, err:%v", req.ClusterID, err)
+	}
+
+	return result, nil
+}
+
+func (s *metaStorageImpl) PutClusterSettings(ctx context.Context, req PutClusterSettingsRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	value, err := json.Marshal(req.Settings)
+	if err != nil {
+		return ErrEncode.WithCausef("encode cluster settings, clusterID:%d, err:%v", req.ClusterID, err)
+	}
+
+	key := makeClusterSettingsKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID))
+	if _, err := s.client.Put(ctx, key, string(value)); err != nil {
+		return errors.WithMessagef(err, "put cluster settings, clusterID:%d, key:%s", req.ClusterID, key)
+	}
+
+	return nil
+}
+
+func (s *metaStorageImpl) DeleteNode(ctx context.Context, req DeleteNodeRequest) error {
+	ctx, cancel := s.withWriteTimeout(ctx)
+	defer cancel()
+
+	key := makeNodeKey(s.clusterRootPath(req.ClusterID), uint32(req.ClusterID), req.NodeName)
+
+	_, err := s.client.Delete(ctx, key)
+	if err != nil {
+		return errors.WithMessagef(err, "delete node, clusterID:%d, node name:%s, key:%s", req.ClusterID, req.NodeName, key)
+	}
+
+	return nil
+}
@@ -22,9 +22,12 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -338,6 +341,395 @@ func TestStorage_CreateOrUpdateNode(t *testing.T) {
 		re.Equal(ret.Nodes[i].Name, expectNodes[i].Name)
 		re.Equal(ret.Nodes[i].LastTouchTime, expectNodes[i].LastTouchTime)
 	}
+
+	// Test to delete node.
+	err = s.DeleteNode(ctx, DeleteNodeRequest{
+		ClusterID: defaultClusterID,
+		NodeName:  expectNodes[0].Name,
+	})
+	re.NoError(err)
+	ret, err = s.ListNodes(ctx, ListNodesRequest{
+		ClusterID: defaultClusterID,
+	})
+	re.NoError(err)
+	re.Equal(defaultCount-1, len(ret.Nodes))
+	for _, node := range ret.Nodes {
+		re.NotEqual(expectNodes[0].Name, node.Name)
+	}
+}
+
+func TestStorage_AppendAndListShardLeaderChanges(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	// The history of the shard without any change is empty.
+	ret, err := s.ListShardLeaderChanges(ctx, ListShardLeaderChangesRequest{
+		ClusterID: defaultClusterID,
+		ShardID:   0,
+	})
+	re.NoError(err)
+	re.Empty(ret.Changes)
+
+	// Only the newest changes are kept.
+	maxHistoryLen := 3
+	for i := 0; i < defaultCount; i++ {
+		err := s.AppendShardLeaderChange(ctx, AppendShardLeaderChangeRequest{
+			ClusterID: defaultClusterID,
+			Change: ShardLeaderChange{
+				ShardID:   0,
+				OldNode:   fmt.Sprintf(nameFormat, i),
+				NewNode:   fmt.Sprintf(nameFormat, i+1),
+				Reason:    "test",
+				Timestamp: uint64(i),
+			},
+			MaxHistoryLen: maxHistoryLen,
+		})
+		re.NoError(err)
+	}
+	ret, err = s.ListShardLeaderChanges(ctx, ListShardLeaderChangesRequest{
+		ClusterID: defaultClusterID,
+		ShardID:   0,
+	})
+	re.NoError(err)
+	re.Len(ret.Changes, maxHistoryLen)
+	for i, change := range ret.Changes {
+		idx := defaultCount - maxHistoryLen + i
+		re.Equal(fmt.Sprintf(nameFormat, idx), change.OldNode)
+		re.Equal(fmt.Sprintf(nameFormat, idx+1), change.NewNode)
+		re.Equal(uint64(idx), change.Timestamp)
+	}
+
+	// The histories of the shards are separated.
+	ret, err = s.ListShardLeaderChanges(ctx, ListShardLeaderChangesRequest{
+		ClusterID: defaultClusterID,
+		ShardID:   1,
+	})
+	re.NoError(err)
+	re.Empty(ret.Changes)
+
+	// The changes older than the retention are trimmed.
+	retention := time.Millisecond * 10
+	for _, timestamp := range []uint64{100, 105, 120} {
+		err := s.AppendShardLeaderChange(ctx, AppendShardLeaderChangeRequest{
+			ClusterID: defaultClusterID,
+			Change: ShardLeaderChange{
+				ShardID:   2,
+				OldNode:   "",
+				NewNode:   fmt.Sprintf(nameFormat, timestamp),
+				Reason:    "test",
+				Timestamp: timestamp,
+			},
+			MaxHistoryLen: 0,
+			Retention:     retention,
+		})
+		re.NoError(err)
+	}
+	ret, err = s.ListShardLeaderChanges(ctx, ListShardLeaderChangesRequest{
+		ClusterID: defaultClusterID,
+		ShardID:   2,
+	})
+	re.NoError(err)
+	re.Len(ret.Changes, 1)
+	re.Equal(uint64(120), ret.Changes[0].Timestamp)
+}
+
+func TestStorage_AppendShardLeaderChangesConcurrently(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	// The concurrent changes conflict with each other, and they are all appended by retries.
+	numChanges := 3
+	errs := make(chan error, numChanges)
+	for i := 0; i < numChanges; i++ {
+		go func(i int) {
+			errs <- s.AppendShardLeaderChange(ctx, AppendShardLeaderChangeRequest{
+				ClusterID: defaultClusterID,
+				Change: ShardLeaderChange{
+					ShardID:   0,
+					OldNode:   "",
+					NewNode:   fmt.Sprintf(nameFormat, i),
+					Reason:    "test",
+					Timestamp: uint64(i),
+				},
+				MaxHistoryLen: 0,
+				Retention:     0,
+			})
+		}(i)
+	}
+	for i := 0; i < numChanges; i++ {
+		re.NoError(<-errs)
+	}
+
+	ret, err := s.ListShardLeaderChanges(ctx, ListShardLeaderChangesRequest{
+		ClusterID: defaultClusterID,
+		ShardID:   0,
+	})
+	re.NoError(err)
+	re.Len(ret.Changes, numChanges)
+}
+
+func TestStorage_CreateAndListTableIDRanges(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	ret, err := s.ListTableIDRanges(ctx, ListTableIDRangesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Empty(ret.Ranges)
+
+	expectRanges := make([]TableIDRange, 0, defaultCount)
+	for i := 0; i < defaultCount; i++ {
+		idRange := TableIDRange{
+			SchemaName: fmt.Sprintf(nameFormat, i),
+			Start:      uint64(i * 100),
+			End:        uint64((i + 1) * 100),
+		}
+		err := s.CreateTableIDRange(ctx, CreateTableIDRangeRequest{ClusterID: defaultClusterID, Range: idRange})
+		re.NoError(err)
+		expectRanges = append(expectRanges, idRange)
+	}
+
+	// The range of the schema can't be created again.
+	err = s.CreateTableIDRange(ctx, CreateTableIDRangeRequest{ClusterID: defaultClusterID, Range: expectRanges[0]})
+	re.Error(err)
+
+	ret, err = s.ListTableIDRanges(ctx, ListTableIDRangesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.ElementsMatch(expectRanges, ret.Ranges)
+
+	// The deleted range is not listed, and can't be deleted again.
+	err = s.DeleteTableIDRange(ctx, DeleteTableIDRangeRequest{ClusterID: defaultClusterID, SchemaName: expectRanges[0].SchemaName})
+	re.NoError(err)
+	err = s.DeleteTableIDRange(ctx, DeleteTableIDRangeRequest{ClusterID: defaultClusterID, SchemaName: expectRanges[0].SchemaName})
+	re.Error(err)
+
+	ret, err = s.ListTableIDRanges(ctx, ListTableIDRangesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.ElementsMatch(expectRanges[1:], ret.Ranges)
+}
+
+func TestStorage_GetAndPutClusterSettings(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	// The zero settings are returned if they have never been put.
+	ret, err := s.GetClusterSettings(ctx, GetClusterSettingsRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	var emptySettings ClusterSettings
+	re.Equal(emptySettings, ret.Settings)
+
+	settings := ClusterSettings{
+		MaxTables:         100,
+		MaintenanceShards: map[ShardID]string{1: "maintenance"},
+	}
+	re.NoError(s.PutClusterSettings(ctx, PutClusterSettingsRequest{ClusterID: defaultClusterID, Settings: settings}))
+	ret, err = s.GetClusterSettings(ctx, GetClusterSettingsRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Equal(settings, ret.Settings)
+}
+
+func TestStorage_UpdateScanLimit(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	re.Equal(ScanLimit{Min: 10, Max: 100}, s.GetScanLimit())
+
+	// The max limit less than the configured min limit is rejected and the current one is kept.
+	for _, maxScanLimit := range []int{0, 1, 9} {
+		re.Error(s.UpdateMaxScanLimit(ctx, maxScanLimit))
+	}
+	re.Equal(ScanLimit{Min: 10, Max: 100}, s.GetScanLimit())
+
+	// The scan with a small batch size still returns all the nodes.
+	re.NoError(s.UpdateMaxScanLimit(ctx, 10))
+	re.Equal(ScanLimit{Min: 10, Max: 10}, s.GetScanLimit())
+	for i := 0; i < defaultCount; i++ {
+		err := s.CreateOrUpdateNode(ctx, CreateOrUpdateNodeRequest{
+			ClusterID: defaultClusterID,
+			Node: Node{
+				Name:          fmt.Sprintf(nameFormat, i),
+				NodeStats:     NewEmptyNodeStats(),
+				LastTouchTime: uint64(time.Now().UnixMilli()),
+				State:         NodeStateOnline,
+			},
+		})
+		re.NoError(err)
+	}
+	ret, err := s.ListNodes(ctx, ListNodesRequest{
+		ClusterID: defaultClusterID,
+	})
+	re.NoError(err)
+	re.Len(ret.Nodes, defaultCount)
+
+	// The max limit is restored by the storage on the new leader.
+	client := s.(*metaStorageImpl).client
+	newStorage := newEtcdStorage(client, defaultRootPath, Options{MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0})
+	re.NoError(newStorage.LoadScanLimit(ctx))
+	re.Equal(ScanLimit{Min: 10, Max: 10}, newStorage.GetScanLimit())
+
+	// The persisted max limit less than the raised min limit is ignored.
+	newStorage = newEtcdStorage(client, defaultRootPath, Options{MaxScanLimit: 100, MinScanLimit: 20, MaxOpsPerTxn: 32, ReadTimeout: 0, WriteTimeout: 0})
+	re.NoError(newStorage.LoadScanLimit(ctx))
+	re.Equal(ScanLimit{Min: 20, Max: 100}, newStorage.GetScanLimit())
+}
+
+func TestStorage_OperationTimeout(t *testing.T) {
+	re := require.New(t)
+	s := &metaStorageImpl{
+		client:        nil,
+		scanLimitLock: sync.RWMutex{},
+		opts:          Options{MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: time.Minute, WriteTimeout: time.Second},
+		rootPath:      defaultRootPath,
+
+		clusterKeyPrefixLock: sync.RWMutex{},
+		clusterKeyPrefixes:   map[ClusterID]string{},
+	}
+
+	readCtx, cancel := s.withReadTimeout(context.Background())
+	defer cancel()
+	readDeadline, ok := readCtx.Deadline()
+	re.True(ok)
+
+	writeCtx, cancel := s.withWriteTimeout(context.Background())
+	defer cancel()
+	writeDeadline, ok := writeCtx.Deadline()
+	re.True(ok)
+	re.True(writeDeadline.Before(readDeadline))
+
+	// The caller's deadline is kept if it is earlier.
+	callerCtx, callerCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer callerCancel()
+	callerDeadline, _ := callerCtx.Deadline()
+	readCtx, cancel = s.withReadTimeout(callerCtx)
+	defer cancel()
+	readDeadline, _ = readCtx.Deadline()
+	re.Equal(callerDeadline, readDeadline)
+
+	// A zero timeout adds no deadline.
+	s.opts.WriteTimeout = 0
+	writeCtx, cancel = s.withWriteTimeout(context.Background())
+	defer cancel()
+	_, ok = writeCtx.Deadline()
+	re.False(ok)
+}
+
+func TestStorage_ClusterKeyPrefix(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	createNode := func(clusterID ClusterID, name string) {
+		err := s.CreateOrUpdateNode(ctx, CreateOrUpdateNodeRequest{
+			ClusterID: clusterID,
+			Node:      Node{Name: name, NodeStats: NodeStats{}, LastTouchTime: uint64(time.Now().UnixMilli()), State: NodeStateOnline},
+		})
+		re.NoError(err)
+	}
+	createNode(defaultClusterID, fmt.Sprintf(nameFormat, 0))
+
+	// The keys of the cluster are isolated once the key prefix is set.
+	re.NoError(s.SetClusterKeyPrefix(defaultClusterID, "tenantA"))
+	ret, err := s.ListNodes(ctx, ListNodesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Empty(ret.Nodes)
+
+	createNode(defaultClusterID, fmt.Sprintf(nameFormat, 1))
+	ret, err = s.ListNodes(ctx, ListNodesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Len(ret.Nodes, 1)
+	re.Equal(fmt.Sprintf(nameFormat, 1), ret.Nodes[0].Name)
+
+	key := makeNodeKey(ClusterRootPath(defaultRootPath, "tenantA"), uint32(defaultClusterID), fmt.Sprintf(nameFormat, 1))
+	re.True(strings.HasPrefix(key, "/meta/tenant/tenantA/v1/cluster/"), "key:%s", key)
+	resp, err := s.(*metaStorageImpl).client.Get(ctx, key)
+	re.NoError(err)
+	re.Len(resp.Kvs, 1)
+
+	// The key prefix can't be shared by different clusters.
+	err = s.SetClusterKeyPrefix(defaultClusterID+1, "tenantA")
+	re.True(coderr.Is(err, ErrDuplicateClusterKeyPrefix.Code()))
+	re.NoError(s.SetClusterKeyPrefix(defaultClusterID+1, "tenantB"))
+
+	// The keys are placed under the root path again after the key prefix is cleared.
+	re.NoError(s.SetClusterKeyPrefix(defaultClusterID, ""))
+	ret, err = s.ListNodes(ctx, ListNodesRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Len(ret.Nodes, 1)
+	re.Equal(fmt.Sprintf(nameFormat, 0), ret.Nodes[0].Name)
+}
+
+func TestStorage_PrefixedCluster(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	re.NoError(s.SetClusterKeyPrefix(defaultClusterID, "tenantA"))
+	cluster := Cluster{
+		ID:                          defaultClusterID,
+		Name:                        fmt.Sprintf(nameFormat, 0),
+		MinNodeCount:                1,
+		ShardTotal:                  1,
+		TopologyType:                TopologyTypeStatic,
+		ProcedureExecutingBatchSize: 100,
+		CreatedAt:                   uint64(time.Now().UnixMilli()),
+		ModifiedAt:                  0,
+	}
+	re.NoError(s.CreateCluster(ctx, CreateClusterRequest{Cluster: cluster}))
+
+	// The cluster key stays under the root path even if the cluster has a key prefix.
+	got, err := s.GetCluster(ctx, defaultClusterID)
+	re.NoError(err)
+	re.Equal(cluster.Name, got.Name)
+
+	cluster.TopologyType = TopologyTypeDynamic
+	cluster.ModifiedAt = uint64(time.Now().UnixMilli())
+	re.NoError(s.UpdateCluster(ctx, UpdateClusterRequest{Cluster: cluster}))
+	got, err = s.GetCluster(ctx, defaultClusterID)
+	re.NoError(err)
+	re.Equal(TopologyType(TopologyTypeDynamic), got.TopologyType)
+
+	ret, err := s.ListClusters(ctx)
+	re.NoError(err)
+	re.Len(ret.Clusters, 1)
+	re.Equal(got, ret.Clusters[0])
+}
+
+func TestParseClusterKeyPrefixes(t *testing.T) {
+	re := require.New(t)
+
+	prefixes, err := ParseClusterKeyPrefixes([]string{"cluster0=tenantA", " cluster1 = tenantB "})
+	re.NoError(err)
+	re.Equal(map[string]string{"cluster0": "tenantA", "cluster1": "tenantB"}, prefixes)
+
+	prefixes, err = ParseClusterKeyPrefixes(nil)
+	re.NoError(err)
+	re.Empty(prefixes)
+
+	for _, rawPrefixes := range [][]string{
+		{"cluster0"},
+		{"=tenantA"},
+		{"cluster0="},
+		{"cluster0=a/b"},
+		{"cluster0=.."},
+		{"cluster0=tenantA", "cluster0=tenantB"},
+	} {
+		_, err = ParseClusterKeyPrefixes(rawPrefixes)
+		re.True(coderr.Is(err, ErrParseClusterKeyPrefix.Code()), "rawPrefixes:%v", rawPrefixes)
+	}
+
+	_, err = ParseClusterKeyPrefixes([]string{"cluster0=tenantA", "cluster1=tenantA"})
+	re.True(coderr.Is(err, ErrDuplicateClusterKeyPrefix.Code()))
 }
 
 func newTestStorage(t *testing.T) Storage {
@@ -353,7 +745,7 @@ func newTestStorage(t *testing.T) Storage {
 	})
 	assert.NoError(t, err)
 
-	ops := Options{MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32}
+	ops := Options{MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 32, ReadTimeout: defaultRequestTimeout, WriteTimeout: defaultRequestTimeout}
 
 	return newEtcdStorage(client, defaultRootPath, ops)
 }
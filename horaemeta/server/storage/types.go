@@ -196,6 +196,104 @@ type CreateOrUpdateNodeRequest struct {
 	Node      Node
 }
 
+type DeleteNodeRequest struct {
+	ClusterID ClusterID
+	NodeName  string
+}
+
+type AppendShardLeaderChangeRequest struct {
+	ClusterID ClusterID
+	Change    ShardLeaderChange
+	// MaxHistoryLen is the max number of the changes kept for the shard, and the oldest ones are trimmed.
+	MaxHistoryLen int
+	// Retention is how long the changes are kept before the appended change, zero means the changes are kept until trimmed by the
+	// MaxHistoryLen.
+	Retention time.Duration
+}
+
+type ListShardLeaderChangesRequest struct {
+	ClusterID ClusterID
+	ShardID   ShardID
+}
+
+type ListShardLeaderChangesResult struct {
+	// Changes are ordered from the oldest to the newest.
+	Changes []ShardLeaderChange
+}
+
+// ShardLeaderChange records that the leader of the shard is changed from the old node to the new node, and the old node is
+// empty if the shard has no leader before.
+type ShardLeaderChange struct {
+	ShardID   ShardID `json:"shardID"`
+	OldNode   string  `json:"oldNode"`
+	NewNode   string  `json:"newNode"`
+	Reason    string  `json:"reason"`
+	Timestamp uint64  `json:"timestamp"`
+}
+
+type ListTableIDRangesRequest struct {
+	ClusterID ClusterID
+}
+
+type ListTableIDRangesResult struct {
+	Ranges []TableIDRange
+}
+
+type CreateTableIDRangeRequest struct {
+	ClusterID ClusterID
+	Range     TableIDRange
+}
+
+type DeleteTableIDRangeRequest struct {
+	ClusterID  ClusterID
+	SchemaName string
+}
+
+// TableIDRange is the range of the ids allocated to the tables of the schema, [Start, End).
+type TableIDRange struct {
+	SchemaName string `json:"schemaName"`
+	Start      uint64 `json:"start"`
+	End        uint64 `json:"end"`
+}
+
+type GetClusterSettingsRequest struct {
+	ClusterID ClusterID
+}
+
+type GetClusterSettingsResult struct {
+	Settings ClusterSettings
+}
+
+type PutClusterSettingsRequest struct {
+	ClusterID ClusterID
+	Settings  ClusterSettings
+}
+
+// ClusterSettings is the runtime settings of the cluster updated through the api, which are persisted so that they survive the
+// leader changes of the meta cluster.
+type ClusterSettings struct {
+	// MaxTables is the max number of tables the cluster can hold, zero means unlimited.
+	MaxTables uint64 `json:"maxTables"`
+	// MaintenanceShards is the shards skipped by the schedulers, shardID -> reason.
+	MaintenanceShards map[ShardID]string `json:"maintenanceShards"`
+	// ShardMinNodeVersions is the min node version required by the shards, shardID -> version.
+	ShardMinNodeVersions map[ShardID]string `json:"shardMinNodeVersions"`
+	// PreferredLeaders is the node preferred to be the leader of the shards, shardID -> nodeName.
+	PreferredLeaders map[ShardID]string `json:"preferredLeaders"`
+	// NodePickerStrategy is the strategy of the node picker used by the schedulers, empty means the default strategy.
+	NodePickerStrategy string `json:"nodePickerStrategy"`
+	// ShardPermutation tells whether the shard ids are permuted before placement.
+	ShardPermutation bool `json:"shardPermutation"`
+	// DefaultPartitionCount is the number of the partitions of the partition tables created without partitions, zero means no default.
+	DefaultPartitionCount uint32 `json:"defaultPartitionCount"`
+}
+
+// ScanLimitSettings is the runtime scan limit updated through the api, which is persisted at the root path since the storage is
+// shared by all the clusters.
+type ScanLimitSettings struct {
+	MaxScanLimit int `json:"maxScanLimit"`
+}
+
 type Cluster struct {
 	ID                          ClusterID
 	Name                        string
@@ -279,6 +377,8 @@ type NodeStats struct {
 	Lease       uint32
 	Zone        string
 	NodeVersion string
+	// ShuttingDown indicates the node is going to shut down and its shards should be transferred away, it is not persisted.
+	ShuttingDown bool
 }
 
 func NewEmptyNodeStats() NodeStats {
@@ -557,6 +657,8 @@ func convertNodeStatsPB(stats *clusterpb.NodeStats) NodeStats {
 		Lease:       stats.Lease,
 		Zone:        stats.Zone,
 		NodeVersion: stats.NodeVersion,
+
+		ShuttingDown: false,
 	}
 }
 
@@ -603,3 +705,25 @@ func ConvertShardStatusToString(status ShardStatus) string {
 	}
 	return "unknown"
 }
+
+func ConvertShardRoleToString(role ShardRole) string {
+	switch role {
+	case ShardRoleLeader:
+		return "leader"
+	case ShardRoleFollower:
+		return "follower"
+	}
+	return "unknown"
+}
+
+func ConvertClusterStateToString(state ClusterState) string {
+	switch state {
+	case ClusterStateEmpty:
+		return "empty"
+	case ClusterStateStable:
+		return "stable"
+	case ClusterStatePrepare:
+		return "prepare"
+	}
+	return "unknown"
+}